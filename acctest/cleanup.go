@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Cleanups is a registry of the destroy functions of the resources created
+// by an acceptance test, like the images it built, run once the test is
+// over even when it fails or panics.
+type Cleanups struct {
+	l     sync.Mutex
+	funcs []cleanup
+}
+
+type cleanup struct {
+	name    string
+	destroy func() error
+}
+
+// Register adds destroy, which destroys the resource called name, to the
+// functions run by Run. Register can be called concurrently.
+func (c *Cleanups) Register(name string, destroy func() error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.funcs = append(c.funcs, cleanup{name: name, destroy: destroy})
+}
+
+// Run runs the destroy functions registered, in the reverse order of their
+// registration, and forgets them. A failing or panicking function doesn't
+// prevent the next ones from running: Run returns all their errors joined.
+func (c *Cleanups) Run() error {
+	c.l.Lock()
+	funcs := c.funcs
+	c.funcs = nil
+	c.l.Unlock()
+
+	var errs []error
+	for i := len(funcs) - 1; i >= 0; i-- {
+		if err := funcs[i].run(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to destroy %s: %w", funcs[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c cleanup) run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return c.destroy()
+}
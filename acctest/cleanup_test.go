@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanups(t *testing.T) {
+	var ran []string
+	c := new(Cleanups)
+	c.Register("image", func() error {
+		ran = append(ran, "image")
+		return nil
+	})
+	c.Register("snapshot", func() error {
+		ran = append(ran, "snapshot")
+		panic("boom")
+	})
+	c.Register("instance", func() error {
+		ran = append(ran, "instance")
+		return errors.New("not found")
+	})
+
+	err := c.Run()
+	if expected := []string{"instance", "snapshot", "image"}; !reflect.DeepEqual(ran, expected) {
+		t.Fatalf("bad order: %v", ran)
+	}
+	if err == nil || !strings.Contains(err.Error(), "failed to destroy instance: not found") || !strings.Contains(err.Error(), "failed to destroy snapshot: panic: boom") {
+		t.Fatalf("bad error: %v", err)
+	}
+
+	// The functions only run once.
+	if err := c.Run(); err != nil || len(ran) != 3 {
+		t.Fatalf("unexpected second run: %v, %v", ran, err)
+	}
+}
@@ -250,5 +250,85 @@ the new implementation to the `BuildersAccTest` map in
 Once you finish these steps, you should be ready to run your new provisioner
 acceptance test by setting the name used in the BuildersAccTest map as your
 `ACC_TEST_BUILDERS` environment variable.
+
+# Cleaning Up Plugin Acceptance Tests
+
+Resources created by a `PluginTestCase`, like the images it builds, are
+registered in its `Cleanups`, whose destroy functions run once the test case is
+over, even when its checks fail or panic:
+
+```go
+
+	cleanups := new(acctest.Cleanups)
+	testCase := &acctest.PluginTestCase{
+	  Name:     "amazon-ebs-basic",
+	  Template: template,
+	  Cleanups: cleanups,
+	  Check: func(buildCommand *exec.Cmd, logfile string) error {
+	    manifest, err := testutils.GetArtifact("manifest.json")
+	    if err != nil {
+	      return err
+	    }
+	    for _, build := range manifest.Builds {
+	      id := build.ArtifactId
+	      cleanups.Register(id, func() error { return deregisterImage(id) })
+	    }
+	    // ...
+	  },
+	}
+
+```
+
+Set `Parallel` to run a test case in parallel with the others, in its own
+working directory; `TestPlugins` runs several test cases as subtests.
+
+Tests interrupted before their cleanups ran leak resources. Register a
+`Sweeper` destroying them, and run the sweepers instead of the tests with the
+`-sweep` flag, once the `TestMain` of the package calls `acctest.TestMain`:
+
+```go
+
+	func init() {
+	  acctest.AddSweeper(&acctest.Sweeper{
+	    Name: "amazon-ami",
+	    F: func(region string) error {
+	      return deregisterImagesNamed(region, "packer-acc-*")
+	    },
+	  })
+	}
+
+	func TestMain(m *testing.M) {
+	  acctest.TestMain(m)
+	}
+
+```
+
+	go test ./builder/ebs -v -sweep=us-east-1,eu-west-1
+
+# Composing Plugin Acceptance Test Templates
+
+Rather than copying template strings across tests, compose them with a
+`TemplateBuilder`. Rendering fails on invalid HCL2 and on references to
+undeclared variables or datasources, before packer runs:
+
+```go
+
+	b := acctest.NewTemplateBuilder().
+	  Variable("region", cty.StringVal("us-east-1")).
+	  Source("amazon-ebs", "basic", acctest.Attrs{
+	    "region":   acctest.Expr("var.region"),
+	    "ami_name": "packer-acc-test",
+	  }).
+	  Build([]string{"amazon-ebs.basic"})
+
+	testCase := &acctest.PluginTestCase{
+	  Name:     "amazon-ebs-basic",
+	  Template: b.MustRender(t),
+	}
+
+```
+
+`Value` evaluates an attribute of the rendered template, for tests to assert
+on the configuration their plugin receives.
 */
 package acctest
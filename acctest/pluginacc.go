@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"testing"
 )
 
@@ -55,11 +56,36 @@ type PluginTestCase struct {
 	Template string
 	// Type is the type of the plugin.
 	Type string
+	// Parallel runs the test case in parallel with the other parallel
+	// tests, in its own working directory, so that the files packer
+	// creates, like output directories, don't collide. Relative paths in
+	// Template are then relative to that directory: reference the files of
+	// the package with absolute paths.
+	Parallel bool
+	// Cleanups holds the destroy functions of the resources created by the
+	// test case, run before Teardown once the test case is over, even when
+	// Check fails or panics. Setup and Check register them, for example for
+	// the artifacts listed in the manifest of the build. TestPlugin sets it
+	// when nil.
+	Cleanups *Cleanups
 }
 
 // TestTeardownFunc is the callback used for Teardown in TestCase.
 type TestTeardownFunc func() error
 
+// TestPlugins runs each test case as a subtest of t, the ones with Parallel
+// set in parallel.
+func TestPlugins(t *testing.T, testCases ...*PluginTestCase) {
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.Name, func(t *testing.T) {
+			TestPlugin(t, testCase)
+		})
+	}
+}
+
+var unsafeWorkDirChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
 //nolint:errcheck
 func TestPlugin(t *testing.T, testCase *PluginTestCase) {
 	if os.Getenv(TestEnvVar) == "" {
@@ -67,6 +93,43 @@ func TestPlugin(t *testing.T, testCase *PluginTestCase) {
 		return
 	}
 
+	// The working directory of packer, where the template and logs are
+	// written: the current directory, unless running in parallel.
+	workDir := ""
+	if testCase.Parallel {
+		t.Parallel()
+		dir, err := os.MkdirTemp("", "packer-acc-"+unsafeWorkDirChars.ReplaceAllString(testCase.Name, "_")+"-")
+		if err != nil {
+			t.Fatalf("bad: failed to create working directory: %s", err)
+		}
+		workDir = dir
+	}
+	// inWorkDir returns the path of file for packer, and its absolute path.
+	inWorkDir := func(file string) (string, string) {
+		if workDir == "" {
+			cwd, _ := os.Getwd()
+			return "./" + file, filepath.Join(cwd, file)
+		}
+		return file, filepath.Join(workDir, file)
+	}
+
+	if testCase.Cleanups == nil {
+		testCase.Cleanups = new(Cleanups)
+	}
+	// Clean up anything created in the plugin run, even when the checks
+	// panic.
+	defer func() {
+		if err := testCase.Cleanups.Run(); err != nil {
+			t.Logf("bad: failed to clean up test-created resources: %s", err.Error())
+		}
+		if testCase.Teardown != nil {
+			cleanErr := testCase.Teardown()
+			if cleanErr != nil {
+				t.Logf("bad: failed to clean up test-created resources: %s", cleanErr.Error())
+			}
+		}
+	}()
+
 	if testCase.Setup != nil {
 		err := testCase.Setup()
 		if err != nil {
@@ -74,18 +137,18 @@ func TestPlugin(t *testing.T, testCase *PluginTestCase) {
 		}
 	}
 
-	logfile := fmt.Sprintf("packer_log_%s.txt", testCase.Name)
+	logfile, logfilePath := inWorkDir(fmt.Sprintf("packer_log_%s.txt", testCase.Name))
 
 	extension := ".pkr.hcl"
 	if err := json.Unmarshal([]byte(testCase.Template), &(map[string]interface{}{})); err == nil {
 		extension = ".json"
 	}
-	templatePath := fmt.Sprintf("./%s%s", testCase.Name, extension)
+	templatePath, templateAbsPath := inWorkDir(fmt.Sprintf("%s%s", testCase.Name, extension))
 
 	// Write config hcl2 template
 	out := bytes.NewBuffer(nil)
 	fmt.Fprintf(out, testCase.Template)
-	outputFile, err := os.Create(templatePath)
+	outputFile, err := os.Create(templateAbsPath)
 	if err != nil {
 		t.Fatalf("bad: failed to create template file: %s", err.Error())
 	}
@@ -102,22 +165,21 @@ func TestPlugin(t *testing.T, testCase *PluginTestCase) {
 	}
 
 	if testCase.Init {
-		initLogfile := fmt.Sprintf("packer_init_log_%s.txt", testCase.Name)
+		_, initLogfilePath := inWorkDir(fmt.Sprintf("packer_init_log_%s.txt", testCase.Name))
 		initCommand := exec.Command(packerbin, "init", templatePath)
+		initCommand.Dir = workDir
 		initCommand.Env = append(initCommand.Env, os.Environ()...)
-		initCommand.Env = append(initCommand.Env, "PACKER_LOG=1", fmt.Sprintf("PACKER_LOG_PATH=%s", initLogfile))
+		initCommand.Env = append(initCommand.Env, "PACKER_LOG=1", fmt.Sprintf("PACKER_LOG_PATH=%s", initLogfilePath))
 		initCommand.Run()
 
 		if testCase.CheckInit != nil {
-			if err := testCase.CheckInit(initCommand, initLogfile); err != nil {
-				cwd, _ := os.Getwd()
+			if err := testCase.CheckInit(initCommand, initLogfilePath); err != nil {
 				t.Fatalf(fmt.Sprintf("Error running plugin acceptance"+
 					" tests: %s\nLogs can be found at %s\nand the "+
 					"acceptance test template can be found at %s",
-					err.Error(), filepath.Join(cwd, initLogfile),
-					filepath.Join(cwd, templatePath)))
+					err.Error(), initLogfilePath, templateAbsPath))
 			} else {
-				os.Remove(initLogfile)
+				os.Remove(initLogfilePath)
 			}
 		}
 	}
@@ -130,32 +192,26 @@ func TestPlugin(t *testing.T, testCase *PluginTestCase) {
 
 	// Run build
 	buildCommand := exec.Command(packerbin, buildArgs...)
+	buildCommand.Dir = workDir
 	buildCommand.Env = append(buildCommand.Env, os.Environ()...)
 	buildCommand.Env = append(buildCommand.Env, "PACKER_LOG=1",
-		fmt.Sprintf("PACKER_LOG_PATH=%s", logfile))
+		fmt.Sprintf("PACKER_LOG_PATH=%s", logfilePath))
 	buildCommand.Run()
 
 	// Check for test custom pass/fail before we clean up
 	var checkErr error
 	if testCase.Check != nil {
-		checkErr = testCase.Check(buildCommand, logfile)
-	}
-	// Clean up anything created in the plugin run
-	if testCase.Teardown != nil {
-		cleanErr := testCase.Teardown()
-		if cleanErr != nil {
-			t.Logf("bad: failed to clean up test-created resources: %s", cleanErr.Error())
-		}
+		checkErr = testCase.Check(buildCommand, logfilePath)
 	}
 
 	// Fail test if check failed.
 	if checkErr != nil {
-		cwd, _ := os.Getwd()
 		t.Fatalf(fmt.Sprintf("Error running plugin acceptance"+
 			" tests: %s\nLogs can be found at %s\nand the "+
 			"acceptance test template can be found at %s",
-			checkErr.Error(), filepath.Join(cwd, logfile),
-			filepath.Join(cwd, templatePath)))
+			checkErr.Error(), logfilePath, templateAbsPath))
+	} else if workDir != "" {
+		os.RemoveAll(workDir)
 	} else {
 		os.Remove(templatePath)
 		os.Remove(logfile)
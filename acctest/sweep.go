@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	sweepFlag    = flag.String("sweep", "", "comma separated list of the locations, like regions, to sweep the leftovers of acceptance tests from")
+	sweepRunFlag = flag.String("sweep-run", "", "comma separated list of the sweepers to run, all of them by default")
+)
+
+// Sweeper destroys the resources that acceptance tests leaked, because they
+// were interrupted before their cleanups ran. Sweepers run instead of the
+// tests when the test binary is given the -sweep flag:
+//
+//	go test ./... -v -sweep=us-east-1,eu-west-1
+//
+// Only resources created by tests must be swept: give them a name or a tag
+// that tells them apart, like a "packer-acc-" prefix.
+type Sweeper struct {
+	// Name is the name of the sweeper, usually the type of the resources it
+	// destroys, like "amazon-ami".
+	Name string
+	// Dependencies are the names of the sweepers to run first, sweeping
+	// the resources that depend on the ones of this sweeper, like instances
+	// using a key pair.
+	Dependencies []string
+	// F destroys the leftovers in location, one of the values of -sweep.
+	F func(location string) error
+}
+
+var sweepers = struct {
+	sync.Mutex
+	m map[string]*Sweeper
+}{m: map[string]*Sweeper{}}
+
+// AddSweeper registers s, usually from an init function of a test file. It
+// panics when a sweeper with the same name is already registered.
+func AddSweeper(s *Sweeper) {
+	sweepers.Lock()
+	defer sweepers.Unlock()
+	if _, ok := sweepers.m[s.Name]; ok {
+		panic(fmt.Sprintf("sweeper %q registered twice", s.Name))
+	}
+	sweepers.m[s.Name] = s
+}
+
+// TestMain runs the sweepers when -sweep is set, or the tests otherwise.
+// Call it from the TestMain of the packages registering sweepers:
+//
+//	func TestMain(m *testing.M) {
+//		acctest.TestMain(m)
+//	}
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *sweepFlag == "" {
+		os.Exit(m.Run())
+	}
+	if err := Sweep(splitList(*sweepFlag), splitList(*sweepRunFlag)); err != nil {
+		fmt.Fprintf(os.Stderr, "Sweeping failed: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Sweep runs the sweepers called names, all of them if names is empty, in
+// each of locations. Sweepers run after their dependencies, which run even
+// when not named. A failing sweeper doesn't stop the others: Sweep returns
+// all the errors joined.
+func Sweep(locations []string, names []string) error {
+	sweepers.Lock()
+	defer sweepers.Unlock()
+	if len(names) == 0 {
+		for name := range sweepers.m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	order, err := sweepOrder(sweepers.m, names)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, location := range locations {
+		for _, s := range order {
+			log.Printf("[INFO] Running sweeper %s in %s", s.Name, location)
+			if err := s.F(location); err != nil {
+				errs = append(errs, fmt.Errorf("sweeper %s in %s: %w", s.Name, location, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sweepOrder returns the sweepers called names and their dependencies, each
+// one after its dependencies.
+func sweepOrder(all map[string]*Sweeper, names []string) ([]*Sweeper, error) {
+	var order []*Sweeper
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("dependency cycle involving sweeper %q", name)
+		}
+		s, ok := all[name]
+		if !ok {
+			return fmt.Errorf("unknown sweeper %q", name)
+		}
+		visiting[name] = true
+		for _, dep := range s.Dependencies {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		order = append(order, s)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
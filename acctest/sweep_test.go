@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSweepOrder(t *testing.T) {
+	all := map[string]*Sweeper{
+		"key-pair": {Name: "key-pair", Dependencies: []string{"instance"}},
+		"image":    {Name: "image", Dependencies: []string{"instance"}},
+		"instance": {Name: "instance"},
+		"a":        {Name: "a", Dependencies: []string{"b"}},
+		"b":        {Name: "b", Dependencies: []string{"a"}},
+		"orphan":   {Name: "orphan", Dependencies: []string{"unknown"}},
+	}
+
+	order, err := sweepOrder(all, []string{"key-pair", "image"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var names []string
+	for _, s := range order {
+		names = append(names, s.Name)
+	}
+	if expected := []string{"instance", "key-pair", "image"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad order: %v", names)
+	}
+
+	if _, err := sweepOrder(all, []string{"a"}); err == nil {
+		t.Fatal("expected a cycle error")
+	}
+	if _, err := sweepOrder(all, []string{"orphan"}); err == nil {
+		t.Fatal("expected an unknown sweeper error")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	var swept []string
+	AddSweeper(&Sweeper{Name: "test-sweeper", F: func(location string) error {
+		swept = append(swept, location)
+		return nil
+	}})
+	defer func() {
+		sweepers.Lock()
+		delete(sweepers.m, "test-sweeper")
+		sweepers.Unlock()
+	}()
+
+	if err := Sweep(splitList("us-east-1, eu-west-1"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected := []string{"us-east-1", "eu-west-1"}; !reflect.DeepEqual(swept, expected) {
+		t.Fatalf("bad locations: %v", swept)
+	}
+}
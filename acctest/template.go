@@ -0,0 +1,292 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// Attrs are the attributes of a block of a template. Values are cty.Values,
+// Exprs, or Go values convertible with gocty, like strings, numbers, slices
+// and maps.
+type Attrs map[string]interface{}
+
+// Expr is an HCL2 expression, like `var.region` or
+// `data.amazon-ami.ubuntu.id`, set as is in the template.
+type Expr string
+
+// Block is a block of a template, like the provisioner of a build.
+type Block struct {
+	Type   string
+	Labels []string
+	Attrs  Attrs
+	Blocks []Block
+}
+
+// TemplateBuilder composes the HCL2 template of a PluginTestCase, instead of
+// copying template strings from test to test:
+//
+//	b := acctest.NewTemplateBuilder().
+//		Variable("region", cty.StringVal("eu-west-1")).
+//		Data("amazon-ami", "ubuntu", acctest.Attrs{"region": acctest.Expr("var.region")}).
+//		Source("amazon-ebs", "basic", acctest.Attrs{
+//			"region":     acctest.Expr("var.region"),
+//			"source_ami": acctest.Expr("data.amazon-ami.ubuntu.id"),
+//		}).
+//		Build([]string{"amazon-ebs.basic"}, acctest.Block{
+//			Type:   "provisioner",
+//			Labels: []string{"shell"},
+//			Attrs:  acctest.Attrs{"inline": []string{"echo hello"}},
+//		})
+//	testCase.Template = b.MustRender(t)
+//
+// Rendering checks that the template is valid HCL2 and that the variables
+// and datasources it references are declared. Value evaluates the rendered
+// attributes, for tests to assert on the configuration of their plugin.
+type TemplateBuilder struct {
+	file      *hclwrite.File
+	variables map[string]cty.Value
+	data      map[string]bool
+	sources   map[string]bool
+	err       error
+}
+
+// NewTemplateBuilder returns a builder of an empty template.
+func NewTemplateBuilder() *TemplateBuilder {
+	return &TemplateBuilder{
+		file:      hclwrite.NewEmptyFile(),
+		variables: map[string]cty.Value{},
+		data:      map[string]bool{},
+		sources:   map[string]bool{},
+	}
+}
+
+// Variable declares the input variable name, of the type of value and with
+// value as default.
+func (b *TemplateBuilder) Variable(name string, value cty.Value) *TemplateBuilder {
+	b.variables[name] = value
+	block := b.appendBlock("variable", name)
+	block.Body().SetAttributeRaw("type", hclwrite.TokensForIdentifier(typeexpr.TypeString(value.Type())))
+	block.Body().SetAttributeValue("default", value)
+	return b
+}
+
+// Data adds the datasource of type typ called name.
+func (b *TemplateBuilder) Data(typ, name string, attrs Attrs, blocks ...Block) *TemplateBuilder {
+	b.data[typ+"."+name] = true
+	return b.Block(Block{Type: "data", Labels: []string{typ, name}, Attrs: attrs, Blocks: blocks})
+}
+
+// Source adds the source of type typ called name.
+func (b *TemplateBuilder) Source(typ, name string, attrs Attrs, blocks ...Block) *TemplateBuilder {
+	b.sources[typ+"."+name] = true
+	return b.Block(Block{Type: "source", Labels: []string{typ, name}, Attrs: attrs, Blocks: blocks})
+}
+
+// Build adds a build of sources, referenced as "type.name", with blocks
+// like provisioners and post-processors.
+func (b *TemplateBuilder) Build(sources []string, blocks ...Block) *TemplateBuilder {
+	for _, s := range sources {
+		if !b.sources[s] {
+			b.fail(fmt.Errorf("build: undeclared source %q", s))
+		}
+	}
+	refs := make([]string, len(sources))
+	for i, s := range sources {
+		refs[i] = fmt.Sprintf("%q", "source."+s)
+	}
+	return b.Block(Block{
+		Type:   "build",
+		Attrs:  Attrs{"sources": Expr("[" + strings.Join(refs, ", ") + "]")},
+		Blocks: blocks,
+	})
+}
+
+// Block adds a top level block, like packer with its required_plugins.
+func (b *TemplateBuilder) Block(block Block) *TemplateBuilder {
+	b.writeBlock(b.file.Body(), block)
+	return b
+}
+
+func (b *TemplateBuilder) appendBlock(typ string, labels ...string) *hclwrite.Block {
+	body := b.file.Body()
+	if len(body.Blocks()) > 0 {
+		body.AppendNewline()
+	}
+	return body.AppendNewBlock(typ, labels)
+}
+
+func (b *TemplateBuilder) writeBlock(parent *hclwrite.Body, block Block) {
+	var w *hclwrite.Block
+	if parent == b.file.Body() {
+		w = b.appendBlock(block.Type, block.Labels...)
+	} else {
+		w = parent.AppendNewBlock(block.Type, block.Labels)
+	}
+
+	names := make([]string, 0, len(block.Attrs))
+	for name := range block.Attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := setAttribute(w.Body(), name, block.Attrs[name]); err != nil {
+			b.fail(fmt.Errorf("%s %s: %s: %s", block.Type, strings.Join(block.Labels, "."), name, err))
+		}
+	}
+	for _, nested := range block.Blocks {
+		b.writeBlock(w.Body(), nested)
+	}
+}
+
+func setAttribute(body *hclwrite.Body, name string, value interface{}) error {
+	switch v := value.(type) {
+	case Expr:
+		f, diags := hclwrite.ParseConfig([]byte("v = "+string(v)+"\n"), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			return fmt.Errorf("invalid expression %q: %s", v, diags.Error())
+		}
+		body.SetAttributeRaw(name, f.Body().GetAttribute("v").Expr().BuildTokens(nil))
+	case cty.Value:
+		body.SetAttributeValue(name, v)
+	default:
+		ty, err := gocty.ImpliedType(v)
+		if err != nil {
+			return err
+		}
+		val, err := gocty.ToCtyValue(v, ty)
+		if err != nil {
+			return err
+		}
+		body.SetAttributeValue(name, val)
+	}
+	return nil
+}
+
+func (b *TemplateBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Render returns the template, after checking it.
+func (b *TemplateBuilder) Render() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	src := hclwrite.Format(b.file.Bytes())
+	f, diags := hclsyntax.ParseConfig(src, "template.pkr.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", diags
+	}
+	if err := b.checkReferences(f.Body.(*hclsyntax.Body)); err != nil {
+		return "", err
+	}
+	return string(src), nil
+}
+
+// MustRender returns the template, failing t when it is invalid.
+func (b *TemplateBuilder) MustRender(t testing.TB) string {
+	t.Helper()
+	src, err := b.Render()
+	if err != nil {
+		t.Fatalf("bad: invalid template: %s", err)
+	}
+	return src
+}
+
+// checkReferences checks that the variables and datasources referenced in
+// body are declared.
+func (b *TemplateBuilder) checkReferences(body *hclsyntax.Body) error {
+	for _, attr := range body.Attributes {
+		for _, traversal := range attr.Expr.Variables() {
+			switch traversal.RootName() {
+			case "var":
+				name := traversalStep(traversal, 1)
+				if _, ok := b.variables[name]; !ok {
+					return fmt.Errorf("%s: undeclared variable %q", attr.SrcRange, name)
+				}
+			case "data":
+				name := traversalStep(traversal, 1) + "." + traversalStep(traversal, 2)
+				if !b.data[name] {
+					return fmt.Errorf("%s: undeclared datasource %q", attr.SrcRange, name)
+				}
+			}
+		}
+	}
+	for _, block := range body.Blocks {
+		if err := b.checkReferences(block.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func traversalStep(traversal hcl.Traversal, i int) string {
+	if i >= len(traversal) {
+		return ""
+	}
+	if attr, ok := traversal[i].(hcl.TraverseAttr); ok {
+		return attr.Name
+	}
+	return ""
+}
+
+// Value evaluates the attribute attr of the block at address, like
+// "source.amazon-ebs.basic" or "variable.region", in the rendered template.
+// Variables evaluate to their default and datasources to unknown values.
+func (b *TemplateBuilder) Value(address, attr string) (cty.Value, error) {
+	src, err := b.Render()
+	if err != nil {
+		return cty.NilVal, err
+	}
+	f, _ := hclsyntax.ParseConfig([]byte(src), "template.pkr.hcl", hcl.InitialPos)
+	labels := strings.Split(address, ".")
+	for _, block := range f.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != labels[0] || strings.Join(block.Labels, ".") != strings.Join(labels[1:], ".") {
+			continue
+		}
+		a, ok := block.Body.Attributes[attr]
+		if !ok {
+			return cty.NilVal, fmt.Errorf("%s has no attribute %q", address, attr)
+		}
+		v, diags := a.Expr.Value(b.evalContext())
+		if diags.HasErrors() {
+			return cty.NilVal, diags
+		}
+		return v, nil
+	}
+	return cty.NilVal, fmt.Errorf("no block %s in the template", address)
+}
+
+func (b *TemplateBuilder) evalContext() *hcl.EvalContext {
+	data := map[string]map[string]cty.Value{}
+	for name := range b.data {
+		parts := strings.SplitN(name, ".", 2)
+		if data[parts[0]] == nil {
+			data[parts[0]] = map[string]cty.Value{}
+		}
+		data[parts[0]][parts[1]] = cty.DynamicVal
+	}
+	dataVals := map[string]cty.Value{}
+	for typ, names := range data {
+		dataVals[typ] = cty.ObjectVal(names)
+	}
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var":  cty.ObjectVal(b.variables),
+			"data": cty.ObjectVal(dataVals),
+		},
+	}
+}
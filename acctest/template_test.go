@@ -0,0 +1,76 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func testTemplateBuilder() *TemplateBuilder {
+	return NewTemplateBuilder().
+		Variable("region", cty.StringVal("eu-west-1")).
+		Data("amazon-ami", "ubuntu", Attrs{"region": Expr("var.region")}).
+		Source("amazon-ebs", "basic", Attrs{
+			"region":     Expr("var.region"),
+			"source_ami": Expr("data.amazon-ami.ubuntu.id"),
+			"tags":       map[string]string{"packer-test": "true"},
+		}).
+		Build([]string{"amazon-ebs.basic"}, Block{
+			Type:   "provisioner",
+			Labels: []string{"shell-local"},
+			Attrs:  Attrs{"inline": []string{"echo hello"}},
+		})
+}
+
+func TestTemplateBuilder_Render(t *testing.T) {
+	src := testTemplateBuilder().MustRender(t)
+	for _, expected := range []string{
+		`variable "region" {`,
+		`  type    = string`,
+		`data "amazon-ami" "ubuntu" {`,
+		`  source_ami = data.amazon-ami.ubuntu.id`,
+		`  sources = ["source.amazon-ebs.basic"]`,
+		`  provisioner "shell-local" {`,
+		`    inline = ["echo hello"]`,
+	} {
+		if !strings.Contains(src, expected) {
+			t.Fatalf("%q not found in template:\n%s", expected, src)
+		}
+	}
+}
+
+func TestTemplateBuilder_Render_invalid(t *testing.T) {
+	tcs := map[string]*TemplateBuilder{
+		"undeclared variable": NewTemplateBuilder().Source("null", "a", Attrs{"x": Expr("var.nope")}),
+		"undeclared data":     NewTemplateBuilder().Source("null", "a", Attrs{"x": Expr("data.null.nope.id")}),
+		"undeclared source":   NewTemplateBuilder().Build([]string{"null.nope"}),
+		"invalid expression":  NewTemplateBuilder().Source("null", "a", Attrs{"x": Expr("var.")}),
+	}
+	for name, b := range tcs {
+		if _, err := b.Render(); err == nil {
+			t.Errorf("%s: the template should be invalid", name)
+		}
+	}
+}
+
+func TestTemplateBuilder_Value(t *testing.T) {
+	b := testTemplateBuilder()
+	v, err := b.Value("source.amazon-ebs.basic", "region")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !v.RawEquals(cty.StringVal("eu-west-1")) {
+		t.Fatalf("bad region: %#v", v)
+	}
+	v, err = b.Value("source.amazon-ebs.basic", "source_ami")
+	if err != nil || v.IsKnown() {
+		t.Fatalf("datasource outputs should be unknown: %#v, %v", v, err)
+	}
+	if _, err := b.Value("source.amazon-ebs.nope", "region"); err == nil {
+		t.Fatal("missing blocks should fail")
+	}
+}
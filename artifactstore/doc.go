@@ -0,0 +1,27 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package artifactstore is a local store of build artifacts, that builders and
+post-processors publish to and later builds source from, to chain image
+pipelines, a base image built once then customized by other builds, without
+an external registry:
+
+	store, _ := artifactstore.Default()
+	entry, err := store.PublishArtifact(ctx, "ubuntu-base", artifact, map[string]string{"version": "24.04"})
+	...
+	entry, err = store.Lookup("ubuntu-base", map[string]string{"version": "24.04"})
+	path, err := store.FilePath(entry, "disk.qcow2")
+
+Files are stored once by the SHA-256 of their content, however many entries
+publish them, and entries are JSON documents naming their files, indexed by
+name. Builds source files with their artifact:// URL, like
+artifact://ubuntu-base/disk.qcow2?version=24.04, once Fetcher is added to the
+fetchers of their cache.Cache. Entries unused for longer than the TTL of the
+store, or over its maximum size, are removed by GC, along with the files no
+entry references.
+
+The store is shared by the processes of the host: publishing and GC hold a
+file lock on the store with package filelock.
+*/
+package artifactstore
@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package artifactstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/cache"
+	"github.com/hashicorp/packer-plugin-sdk/iohelper"
+)
+
+// Scheme is the scheme of the URLs of the files of the store:
+// artifact://<name>/<file>, with the metadata the entry must have as query
+// parameters, like artifact://ubuntu-base/disk.qcow2?version=24.04.
+const Scheme = "artifact"
+
+// Fetcher is the cache.Fetcher of the artifact:// URLs. The files are used
+// in place, from the newest entry matching the URL.
+type Fetcher struct {
+	Store *Store
+}
+
+var _ cache.LocalFetcher = new(Fetcher)
+
+// Resolve returns the entry and the name of the file of the artifact:// URL
+// u.
+func (f *Fetcher) Resolve(u *url.URL) (*Entry, string, error) {
+	name, file := u.Host, strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != Scheme || name == "" || file == "" {
+		return nil, "", fmt.Errorf("invalid artifact URL %s, expected %s://<name>/<file>", u, Scheme)
+	}
+	match := map[string]string{}
+	for k, v := range u.Query() {
+		match[k] = v[0]
+	}
+	e, err := f.Store.Lookup(name, match)
+	if err != nil {
+		return nil, "", err
+	}
+	return e, file, nil
+}
+
+func (f *Fetcher) LocalPath(u *url.URL) (string, bool) {
+	e, file, err := f.Resolve(u)
+	if err != nil {
+		return "", false
+	}
+	path, err := f.Store.FilePath(e, file)
+	return path, err == nil
+}
+
+// Fetch copies the file of req.URL to req.Dst, for the caches not using
+// the files in place.
+func (f *Fetcher) Fetch(ctx context.Context, req *cache.FetchRequest) error {
+	e, file, err := f.Resolve(req.URL)
+	if err != nil {
+		return err
+	}
+	path, err := f.Store.FilePath(e, file)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(req.Dst)
+	if err != nil {
+		return err
+	}
+	if _, err := iohelper.CancellableCopy(ctx, dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("Error copying %s: %s", req.URL, err)
+	}
+	return dst.Close()
+}
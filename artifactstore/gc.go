@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package artifactstore
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// tmpTTL is how long GC keeps the temporary files of the store, which are
+// only left by interrupted publications once that long.
+const tmpTTL = 24 * time.Hour
+
+// GC removes the entries that were not used for longer than TTL, then the
+// least recently used entries until their files take at most MaxSize bytes,
+// then the files no entry references. It returns the paths of the removed
+// files.
+func (s *Store) GC(ctx context.Context) ([]string, error) {
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	type used struct {
+		entry    *Entry
+		lastUsed time.Time
+	}
+	var all []used
+	for _, e := range entries {
+		info, err := os.Stat(s.entryPath(e.ID))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, used{e, info.ModTime()})
+	}
+	// Least recently used first.
+	sort.SliceStable(all, func(i, j int) bool { return all[i].lastUsed.Before(all[j].lastUsed) })
+
+	// size returns the size of the files referenced by the entries, each
+	// counted once.
+	size := func(entries []used) int64 {
+		seen := map[string]bool{}
+		total := int64(0)
+		for _, u := range entries {
+			for _, f := range u.entry.Files {
+				if !seen[f.Digest] {
+					seen[f.Digest] = true
+					total += f.Size
+				}
+			}
+		}
+		return total
+	}
+
+	var removed []string
+	now := time.Now()
+	for len(all) > 0 {
+		expired := s.TTL > 0 && now.Sub(all[0].lastUsed) > s.TTL
+		oversized := s.MaxSize > 0 && size(all) > s.MaxSize
+		if !expired && !oversized {
+			break
+		}
+		path := s.entryPath(all[0].entry.ID)
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		log.Printf("[INFO] Removed %s %s from the artifact store", all[0].entry.Name, all[0].entry.ID)
+		removed = append(removed, path)
+		all = all[1:]
+	}
+
+	referenced := map[string]bool{}
+	for _, u := range all {
+		for _, f := range u.entry.Files {
+			referenced[s.blobPath(f.Digest)] = true
+		}
+	}
+	err = filepath.WalkDir(filepath.Join(s.Dir, blobsDir), func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || d.IsDir() || referenced[path] {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed = append(removed, path)
+		return nil
+	})
+	if err != nil {
+		return removed, err
+	}
+
+	tmps, _ := os.ReadDir(filepath.Join(s.Dir, tmpDir))
+	for _, tmp := range tmps {
+		info, err := tmp.Info()
+		if err != nil || now.Sub(info.ModTime()) < tmpTTL {
+			continue
+		}
+		path := filepath.Join(s.Dir, tmpDir, tmp.Name())
+		if err := os.Remove(path); err == nil {
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
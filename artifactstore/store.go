@@ -0,0 +1,425 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package artifactstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+	"github.com/hashicorp/packer-plugin-sdk/iohelper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	blobsDir   = "blobs"
+	entriesDir = "entries"
+	tmpDir     = "tmp"
+	lockFile   = ".lock"
+
+	lockRetryDelay = 100 * time.Millisecond
+)
+
+// ErrNotFound is returned when no entry matches a lookup.
+var ErrNotFound = errors.New("artifact not found in the store")
+
+// nameRe matches the valid entry names, which are the hosts of the
+// artifact:// URLs.
+var nameRe = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+
+// Store is a directory of artifacts. Its fields must not be changed once it's
+// in use.
+type Store struct {
+	// Dir is the directory of the store.
+	Dir string
+	// TTL is how long GC keeps the entries that were not used; forever when
+	// zero.
+	TTL time.Duration
+	// MaxSize is the size in bytes of the files above which GC removes the
+	// least recently used entries; unlimited when zero.
+	MaxSize int64
+}
+
+// New returns a store in dir.
+func New(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+var (
+	defaultStore     *Store
+	defaultStoreErr  error
+	defaultStoreOnce sync.Once
+)
+
+// Default returns the store in the artifacts directory of the Packer cache
+// directory, see packersdk.CachePath.
+func Default() (*Store, error) {
+	defaultStoreOnce.Do(func() {
+		var dir string
+		dir, defaultStoreErr = packersdk.CachePath("artifacts")
+		if defaultStoreErr == nil {
+			defaultStore = New(dir)
+		}
+	})
+	return defaultStore, defaultStoreErr
+}
+
+// File is a file of an entry.
+type File struct {
+	// Name is the name of the file in the entry, like "disk.qcow2".
+	Name string `json:"name"`
+	// Digest is the checksum of the file, like "sha256:ab12...", which
+	// cache.Cache.Get verifies.
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// Entry is a published artifact.
+type Entry struct {
+	// ID identifies the entry, it is the SHA-256 of its name, files,
+	// builder and metadata: publishing the same artifact again gives the
+	// same entry.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// BuilderID is the ID of the builder of the artifact, when known.
+	BuilderID string            `json:"builder_id,omitempty"`
+	Files     []File            `json:"files"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Size returns the size of the files of e.
+func (e *Entry) Size() int64 {
+	size := int64(0)
+	for _, f := range e.Files {
+		size += f.Size
+	}
+	return size
+}
+
+// File returns the file name of e.
+func (e *Entry) File(name string) (File, bool) {
+	for _, f := range e.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// PublishOptions describe an entry to publish.
+type PublishOptions struct {
+	BuilderID string
+	Metadata  map[string]string
+}
+
+// Publish stores the files of paths as the entry name, each under its base
+// name, and returns the entry. Files already in the store are not stored
+// again.
+func (s *Store) Publish(ctx context.Context, name string, paths []string, opts PublishOptions) (*Entry, error) {
+	if !nameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid artifact name %q: it must be made of letters, digits, dots, dashes and underscores", name)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files to publish as %s", name)
+	}
+	if err := os.MkdirAll(filepath.Join(s.Dir, tmpDir), 0755); err != nil {
+		return nil, fmt.Errorf("Error creating the artifact store: %s", err)
+	}
+
+	e := &Entry{Name: name, BuilderID: opts.BuilderID, Metadata: opts.Metadata}
+	// The files are hashed as they are copied, without the lock, then
+	// moved to their place with it, so that GC doesn't remove them first.
+	var tmps []string
+	defer func() {
+		for _, tmp := range tmps {
+			os.Remove(tmp)
+		}
+	}()
+	seen := map[string]bool{}
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if seen[base] {
+			return nil, fmt.Errorf("two files are named %s", base)
+		}
+		seen[base] = true
+		tmp, file, err := s.copyBlob(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		tmps = append(tmps, tmp)
+		file.Name = base
+		e.Files = append(e.Files, file)
+	}
+	e.ID = entryID(e)
+
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	for i, f := range e.Files {
+		dst := s.blobPath(f.Digest)
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmps[i], dst); err != nil {
+			return nil, err
+		}
+	}
+
+	if existing, err := s.Get(e.ID); err == nil {
+		return existing, touch(s.entryPath(e.ID))
+	}
+	e.CreatedAt = time.Now().UTC()
+	if err := s.writeEntry(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// PublishArtifact publishes the files of a, see Publish.
+func (s *Store) PublishArtifact(ctx context.Context, name string, a packersdk.Artifact, metadata map[string]string) (*Entry, error) {
+	return s.Publish(ctx, name, a.Files(), PublishOptions{BuilderID: a.BuilderId(), Metadata: metadata})
+}
+
+// copyBlob copies path to the temporary directory of the store, and returns
+// the copy and the File of path, without a name.
+func (s *Store) copyBlob(ctx context.Context, path string) (string, File, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", File{}, err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(filepath.Join(s.Dir, tmpDir), "blob-")
+	if err != nil {
+		return "", File{}, err
+	}
+	h := sha256.New()
+	size, err := iohelper.CancellableCopy(ctx, io.MultiWriter(tmp, h), src)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", File{}, fmt.Errorf("Error copying %s to the artifact store: %s", path, err)
+	}
+	return tmp.Name(), File{Digest: "sha256:" + hex.EncodeToString(h.Sum(nil)), Size: size}, nil
+}
+
+// entryID returns the ID of e, from what it is made of.
+func entryID(e *Entry) string {
+	b, _ := json.Marshal(struct {
+		Name      string
+		BuilderID string
+		Files     []File
+		Metadata  map[string]string
+	}{e.Name, e.BuilderID, e.Files, e.Metadata})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Store) writeEntry(e *Entry) error {
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(s.Dir, entriesDir), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(s.Dir, tmpDir), "entry-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(b, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.entryPath(e.ID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Get returns the entry id.
+func (s *Store) Get(id string) (*Entry, error) {
+	if !isHex(id) {
+		return nil, fmt.Errorf("invalid entry id %q", id)
+	}
+	b, err := os.ReadFile(s.entryPath(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: no entry %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := new(Entry)
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("Error reading entry %s: %s", id, err)
+	}
+	return e, nil
+}
+
+// Entries returns the entries of the store, the newest first.
+func (s *Store) Entries() ([]*Entry, error) {
+	dirEntries, err := os.ReadDir(filepath.Join(s.Dir, entriesDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	for _, de := range dirEntries {
+		id, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok {
+			continue
+		}
+		e, err := s.Get(id)
+		if errors.Is(err, ErrNotFound) {
+			// Removed by GC meanwhile.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+	return entries, nil
+}
+
+// Lookup returns the newest entry name whose metadata has the values of
+// match, or an error wrapping ErrNotFound.
+func (s *Store) Lookup(name string, match map[string]string) (*Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name != name {
+			continue
+		}
+		matches := true
+		for k, v := range match {
+			if e.Metadata[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			touch(s.entryPath(e.ID))
+			return e, nil
+		}
+	}
+	if len(match) > 0 {
+		return nil, fmt.Errorf("%w: no %s matching %v", ErrNotFound, name, match)
+	}
+	return nil, fmt.Errorf("%w: no %s", ErrNotFound, name)
+}
+
+// FilePath returns the path of the file name of e in the store, which must
+// not be modified, and marks e as used.
+func (s *Store) FilePath(e *Entry, name string) (string, error) {
+	f, ok := e.File(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s has no file %s", ErrNotFound, e.Name, name)
+	}
+	path := s.blobPath(f.Digest)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("file %s of %s: %w", name, e.Name, err)
+	}
+	touch(s.entryPath(e.ID))
+	return path, nil
+}
+
+// Remove removes the entry id; its files are removed by the next GC, unless
+// other entries reference them.
+func (s *Store) Remove(ctx context.Context, id string) error {
+	if !isHex(id) {
+		return fmt.Errorf("invalid entry id %q", id)
+	}
+	lock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	err = os.Remove(s.entryPath(id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: no entry %s", ErrNotFound, id)
+	}
+	return err
+}
+
+func (s *Store) entryPath(id string) string {
+	return filepath.Join(s.Dir, entriesDir, id+".json")
+}
+
+// blobPath returns the path of the file of digest, "sha256:<hex>", under a
+// directory named after its first two digits not to have too many files in
+// one directory.
+func (s *Store) blobPath(digest string) string {
+	algo, sum, _ := strings.Cut(digest, ":")
+	if len(sum) < 2 {
+		return filepath.Join(s.Dir, blobsDir, algo, sum)
+	}
+	return filepath.Join(s.Dir, blobsDir, algo, sum[:2], sum)
+}
+
+// lock locks the store, until ctx is done.
+func (s *Store) lock(ctx context.Context) (*filelock.Flock, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, err
+	}
+	lock := filelock.New(filepath.Join(s.Dir, lockFile))
+	for {
+		ok, err := lock.TryLock()
+		if err != nil {
+			return nil, fmt.Errorf("Error locking the artifact store: %s", err)
+		}
+		if ok {
+			return lock, nil
+		}
+		select {
+		case <-time.After(lockRetryDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// touch marks path as used now, for GC.
+func touch(path string) error {
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
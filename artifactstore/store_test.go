@@ -0,0 +1,161 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package artifactstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/cache"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStore_Publish(t *testing.T) {
+	s := New(t.TempDir())
+	ctx := context.Background()
+	disk := writeFile(t, "disk.qcow2", "disk")
+	e, err := s.Publish(ctx, "ubuntu-base", []string{disk, writeFile(t, "packer.json", "{}")}, PublishOptions{
+		BuilderID: "packer.qemu",
+		Metadata:  map[string]string{"version": "24.04"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	sum := sha256.Sum256([]byte("disk"))
+	if f, _ := e.File("disk.qcow2"); f.Digest != "sha256:"+hex.EncodeToString(sum[:]) || f.Size != 4 {
+		t.Fatalf("bad file: %#v", f)
+	}
+
+	again, err := s.Publish(ctx, "ubuntu-base", []string{disk, writeFile(t, "packer.json", "{}")}, PublishOptions{
+		BuilderID: "packer.qemu",
+		Metadata:  map[string]string{"version": "24.04"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if again.ID != e.ID || !again.CreatedAt.Equal(e.CreatedAt) {
+		t.Fatalf("publishing the same artifact again should give the same entry: %#v", again)
+	}
+
+	// A newer version shares the unchanged file.
+	time.Sleep(10 * time.Millisecond)
+	newer, err := s.Publish(ctx, "ubuntu-base", []string{disk}, PublishOptions{Metadata: map[string]string{"version": "24.10"}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	blobs := 0
+	filepath.WalkDir(filepath.Join(s.Dir, blobsDir), func(_ string, d os.DirEntry, _ error) error {
+		if !d.IsDir() {
+			blobs++
+		}
+		return nil
+	})
+	if blobs != 2 {
+		t.Fatalf("expected 2 files in the store, got %d", blobs)
+	}
+
+	if found, err := s.Lookup("ubuntu-base", nil); err != nil || found.ID != newer.ID {
+		t.Fatalf("expected the newest entry, got %v, %v", found, err)
+	}
+	found, err := s.Lookup("ubuntu-base", map[string]string{"version": "24.04"})
+	if err != nil || found.ID != e.ID {
+		t.Fatalf("expected the matching entry, got %v, %v", found, err)
+	}
+	path, err := s.FilePath(found, "disk.qcow2")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "disk" {
+		t.Fatalf("bad content: %q", b)
+	}
+	if _, err := s.Lookup("debian-base", nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Publish(ctx, "../evil", []string{disk}, PublishOptions{}); err == nil {
+		t.Fatal("expected an invalid name to be rejected")
+	}
+}
+
+func TestStore_PublishArtifact(t *testing.T) {
+	s := New(t.TempDir())
+	a := &packersdk.MockArtifact{BuilderIdValue: "packer.mock", FilesValue: []string{writeFile(t, "image.raw", "raw")}}
+	e, err := s.PublishArtifact(context.Background(), "mock", a, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e.BuilderID != "packer.mock" || len(e.Files) != 1 || e.Files[0].Name != "image.raw" {
+		t.Fatalf("bad entry: %#v", e)
+	}
+}
+
+func TestStore_GC(t *testing.T) {
+	s := New(t.TempDir())
+	ctx := context.Background()
+	old, _ := s.Publish(ctx, "old", []string{writeFile(t, "a", "aaaa"), writeFile(t, "shared", "ssss")}, PublishOptions{})
+	recent, _ := s.Publish(ctx, "recent", []string{writeFile(t, "b", "bbbb"), writeFile(t, "shared", "ssss")}, PublishOptions{})
+	past := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(s.entryPath(old.ID), past, past)
+
+	s.MaxSize = 8
+	removed, err := s.GC(ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// The entry used least recently goes, with the file only it references.
+	if len(removed) != 2 {
+		t.Fatalf("expected an entry and a file removed, got %v", removed)
+	}
+	if _, err := s.Get(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("the old entry should be removed: %v", err)
+	}
+	for _, name := range []string{"b", "shared"} {
+		if _, err := s.FilePath(recent, name); err != nil {
+			t.Fatalf("the files of the recent entry should be kept: %s", err)
+		}
+	}
+
+	s.MaxSize, s.TTL = 0, time.Hour
+	os.Chtimes(s.entryPath(recent.ID), past, past)
+	if removed, err := s.GC(ctx); err != nil || len(removed) != 3 {
+		t.Fatalf("expected the expired entry and its files removed, got %v, %v", removed, err)
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	s := New(t.TempDir())
+	e, err := s.Publish(context.Background(), "ubuntu-base", []string{writeFile(t, "disk.qcow2", "disk")}, PublishOptions{
+		Metadata: map[string]string{"version": "24.04"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	c := cache.New(t.TempDir())
+	c.Fetchers[Scheme] = &Fetcher{Store: s}
+
+	path, err := c.Get(context.Background(), "artifact://ubuntu-base/disk.qcow2?version=24.04", e.Files[0].Digest)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected, _ := s.FilePath(e, "disk.qcow2"); path != expected {
+		t.Fatalf("the file should be used in place, got %s", path)
+	}
+	if _, err := c.Get(context.Background(), "artifact://ubuntu-base/disk.qcow2?version=22.04", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
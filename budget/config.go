@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+// Package budget is a helper module for builder plugin configuration that
+// limits how long a build can run.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Config bounds the time a build can take, so that a build stuck waiting on
+// a machine doesn't keep it running, and billed, forever. Embed it in your
+// builder config using the `mapstructure:",squash"` struct tag, and run the
+// steps of the build with Run.
+type Config struct {
+	// The longest the build can run, for example "2h". Once it is exceeded
+	// the build is cancelled, as if interrupted, and fails with an error
+	// saying so. By default builds have no time limit.
+	MaxBuildTime time.Duration `mapstructure:"max_build_time" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	if c.MaxBuildTime < 0 {
+		errs = append(errs, fmt.Errorf("max_build_time must not be negative, got %s", c.MaxBuildTime))
+	}
+	return errs
+}
+
+// ExceededError is the error of a build cancelled because it ran longer
+// than its max_build_time.
+type ExceededError struct {
+	MaxBuildTime time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("build time budget exceeded: the build ran longer than its max_build_time of %s", e.MaxBuildTime)
+}
+
+// Context returns a copy of ctx that is cancelled once MaxBuildTime elapsed,
+// or ctx itself when there is no limit. The steps and the communicator
+// commands using the returned context are cancelled along with it.
+func (c *Config) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.MaxBuildTime <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, c.MaxBuildTime, &ExceededError{MaxBuildTime: c.MaxBuildTime})
+}
+
+// Err returns the ExceededError of ctx when it was cancelled because the
+// budget was exceeded, nil otherwise.
+func Err(ctx context.Context) error {
+	var exceeded *ExceededError
+	if errors.As(context.Cause(ctx), &exceeded) {
+		return exceeded
+	}
+	return nil
+}
+
+// Run runs runner within the budget. When the budget is exceeded, the
+// ExceededError is put in the state bag under "error", replacing the error
+// of the step that was interrupted, and returned.
+func (c *Config) Run(ctx context.Context, runner multistep.Runner, state multistep.StateBag) error {
+	ctx, cancel := c.Context(ctx)
+	defer cancel()
+
+	runner.Run(ctx, state)
+
+	if err := Err(ctx); err != nil {
+		log.Printf("[WARN] %s", err)
+		state.Put("error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestConfigPrepare(t *testing.T) {
+	c := &Config{}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	c = &Config{MaxBuildTime: -time.Minute}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) != 1 {
+		t.Fatalf("expected an error, got %#v", errs)
+	}
+}
+
+// blockingStep runs until it's cancelled.
+type blockingStep struct {
+	cleanedUp bool
+}
+
+func (s *blockingStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	<-ctx.Done()
+	state.Put("error", ctx.Err())
+	return multistep.ActionHalt
+}
+
+func (s *blockingStep) Cleanup(multistep.StateBag) {
+	s.cleanedUp = true
+}
+
+func TestConfigRun_Exceeded(t *testing.T) {
+	c := &Config{MaxBuildTime: 10 * time.Millisecond}
+	step := new(blockingStep)
+	state := new(multistep.BasicStateBag)
+
+	err := c.Run(context.Background(), &multistep.BasicRunner{Steps: []multistep.Step{step}}, state)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.MaxBuildTime != c.MaxBuildTime {
+		t.Fatalf("expected the budget to be exceeded, got %v", err)
+	}
+	if state.Get("error") != err {
+		t.Fatalf("bad error in state: %v", state.Get("error"))
+	}
+	if !step.cleanedUp {
+		t.Fatal("step should be cleaned up")
+	}
+}
+
+func TestConfigRun_Cancelled(t *testing.T) {
+	c := &Config{MaxBuildTime: time.Hour}
+	state := new(multistep.BasicStateBag)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := c.Run(ctx, &multistep.BasicRunner{Steps: []multistep.Step{new(blockingStep)}}, state); err != nil {
+		t.Fatalf("a cancellation is not a budget error, got %v", err)
+	}
+}
+
+func TestConfigRun_NoLimit(t *testing.T) {
+	c := &Config{}
+	state := new(multistep.BasicStateBag)
+	step := new(blockingStep)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Run(ctx, &multistep.BasicRunner{Steps: []multistep.Step{step}}, state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !step.cleanedUp {
+		t.Fatal("step should be cleaned up")
+	}
+}
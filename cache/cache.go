@@ -0,0 +1,294 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	urlhelper "github.com/hashicorp/go-getter/v2/helper/url"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	partSuffix = ".part"
+	lockSuffix = ".lock"
+
+	lockRetryDelay = 100 * time.Millisecond
+)
+
+// Cache is a directory of downloaded files. Its fields must not be changed
+// once it's in use.
+type Cache struct {
+	// Dir is the directory of the cache.
+	Dir string
+	// Fetchers are the fetchers of the URL schemes, or go-getter forced
+	// getters, the cache can download from.
+	Fetchers map[string]Fetcher
+	// TTL is how long GC keeps the entries that were not used; forever
+	// when zero.
+	TTL time.Duration
+	// MaxSize is the size in bytes above which GC removes the least
+	// recently used entries; unlimited when zero.
+	MaxSize int64
+	// Ui, when set, displays the progress of downloads.
+	Ui packersdk.Ui
+
+	lock     sync.Mutex
+	inflight map[string]*download
+}
+
+// download is an ongoing download, that concurrent Gets of the same file wait
+// for.
+type download struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+// New returns a cache in dir, with the default fetchers.
+func New(dir string) *Cache {
+	return &Cache{Dir: dir, Fetchers: DefaultFetchers()}
+}
+
+var (
+	defaultCache     *Cache
+	defaultCacheErr  error
+	defaultCacheOnce sync.Once
+)
+
+// Default returns the cache in the downloads directory of the Packer cache
+// directory, see packersdk.CachePath.
+func Default() (*Cache, error) {
+	defaultCacheOnce.Do(func() {
+		var dir string
+		dir, defaultCacheErr = packersdk.CachePath("downloads")
+		if defaultCacheErr == nil {
+			defaultCache = New(dir)
+		}
+	})
+	return defaultCache, defaultCacheErr
+}
+
+// Get downloads source into the default cache; see Cache.Get.
+func Get(ctx context.Context, source, checksum string) (string, error) {
+	c, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return c.Get(ctx, source, checksum)
+}
+
+// Get returns the path of source in the cache, downloading it unless it is
+// already there, and verifies it matches checksum. Local files are used in
+// place, see LocalFetcher.
+//
+// checksum is "type:value", a bare value, "file:<url>" for the checksum of
+// source to be read from a checksum file, or "" or "none" not to verify the
+// file. Files are stored by checksum when there is one, so that a file
+// downloaded from another mirror, or with a checksum given another way, is
+// reused, and by URL otherwise.
+func (c *Cache) Get(ctx context.Context, source, checksum string) (string, error) {
+	fetcher, u, err := c.parseSource(source)
+	if err != nil {
+		return "", err
+	}
+	sum, err := c.resolveChecksum(ctx, checksum, u)
+	if err != nil {
+		return "", err
+	}
+	if lf, ok := fetcher.(LocalFetcher); ok {
+		if path, ok := lf.LocalPath(u); ok {
+			if sum != nil {
+				if err := sum.Verify(path); err != nil {
+					return "", err
+				}
+			}
+			return path, nil
+		}
+	}
+	name := entryName(u, sum)
+
+	for {
+		c.lock.Lock()
+		if d, ok := c.inflight[name]; ok {
+			c.lock.Unlock()
+			select {
+			case <-d.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			// Retry the downloads cancelled by the context of another
+			// caller.
+			if isCanceled(d.err) && ctx.Err() == nil {
+				continue
+			}
+			return d.path, d.err
+		}
+		if c.inflight == nil {
+			c.inflight = map[string]*download{}
+		}
+		d := &download{done: make(chan struct{})}
+		c.inflight[name] = d
+		c.lock.Unlock()
+
+		d.path, d.err = c.fetch(ctx, fetcher, u, name, sum)
+
+		c.lock.Lock()
+		delete(c.inflight, name)
+		c.lock.Unlock()
+		close(d.done)
+		return d.path, d.err
+	}
+}
+
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// fetch downloads u to the entry name, holding its file lock so that other
+// processes wait for it.
+func (c *Cache) fetch(ctx context.Context, fetcher Fetcher, u *url.URL, name string, sum *Checksum) (string, error) {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return "", fmt.Errorf("Error creating cache directory: %s", err)
+	}
+	dst := filepath.Join(c.Dir, name)
+	lock, err := lockContext(ctx, dst+lockSuffix)
+	if err != nil {
+		return "", fmt.Errorf("Error locking %s: %w", dst, err)
+	}
+	defer lock.Unlock()
+
+	if _, err := os.Stat(dst); err == nil {
+		if sum == nil {
+			log.Printf("[INFO] Using cached %s for %s", dst, u)
+			return dst, touch(dst)
+		}
+		err := sum.Verify(dst)
+		if err == nil {
+			log.Printf("[INFO] Using cached %s for %s", dst, u)
+			return dst, touch(dst)
+		}
+		log.Printf("[WARN] Downloading %s again: %s", u, err)
+		if err := os.Remove(dst); err != nil {
+			return "", err
+		}
+	}
+
+	part := dst + partSuffix
+	offset := int64(0)
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+		log.Printf("[INFO] Resuming the download of %s after %d bytes", u, offset)
+	}
+	req := &FetchRequest{URL: u, Dst: part, Offset: offset}
+	if c.Ui != nil {
+		req.Progress = packersdk.NewProgressTracker(c.Ui)
+	}
+	if err := fetcher.Fetch(ctx, req); err != nil {
+		// The partial download is kept to be resumed.
+		return "", fmt.Errorf("Error downloading %s: %w", u, err)
+	}
+	if sum != nil {
+		if err := sum.Verify(part); err != nil {
+			os.Remove(part)
+			return "", err
+		}
+	}
+	if err := os.Rename(part, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// lockContext waits for the file lock path, until ctx is done.
+func lockContext(ctx context.Context, path string) (*filelock.Flock, error) {
+	lock := filelock.New(path)
+	for {
+		ok, err := lock.TryLock()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return lock, nil
+		}
+		select {
+		case <-time.After(lockRetryDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// touch marks path as used now, for GC.
+func touch(path string) error {
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
+
+// forcedRe matches the go-getter forced getters, like s3:: in
+// s3::https://s3.amazonaws.com/bucket/file.
+var forcedRe = regexp.MustCompile(`^([A-Za-z0-9]+)::(.+)$`)
+
+// parseSource returns the URL of source and the fetcher to download it with.
+// Local paths are file URLs, and UNC paths smb URLs on Windows.
+func (c *Cache) parseSource(source string) (Fetcher, *url.URL, error) {
+	scheme := ""
+	if m := forcedRe.FindStringSubmatch(source); m != nil {
+		scheme, source = m[1], m[2]
+	}
+	if runtime.GOOS == "windows" && strings.HasPrefix(source, `\\`) && len(source) > 2 && source[2] != '?' {
+		source = "smb://" + filepath.ToSlash(source[2:])
+	}
+	u, err := urlhelper.Parse(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid source %q: %s", source, err)
+	}
+	if u.Scheme == "" || (runtime.GOOS == "windows" && len(u.Scheme) == 1) {
+		// A local path, C:\ being parsed as the C scheme on Windows.
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return nil, nil, err
+		}
+		u = &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	}
+	if scheme == "" {
+		scheme = strings.ToLower(u.Scheme)
+	}
+	fetcher, ok := c.Fetchers[scheme]
+	if !ok {
+		return nil, nil, fmt.Errorf("no fetcher for %s, can't download %s", scheme, source)
+	}
+	return fetcher, u, nil
+}
+
+// entryName returns the name of the entry of u in the cache: the hash of its
+// checksum, or else of its URL, with the extension of its file.
+func entryName(u *url.URL, sum *Checksum) string {
+	key := u.String()
+	if sum != nil {
+		key = sum.String()
+	}
+	hash := sha1.Sum([]byte(key))
+	name := hex.EncodeToString(hash[:])
+	if ext := path.Ext(u.Path); len(ext) > 1 && len(ext) <= 8 && !strings.ContainsAny(ext, `\/:`) {
+		name += ext
+	}
+	return name
+}
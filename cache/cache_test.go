@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testServer serves hello.iso, with range requests, and SHA256SUMS, counting
+// the requests of hello.iso.
+func testServer(t *testing.T, requests *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello.iso", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		http.ServeContent(w, r, "hello.iso", time.Time{}, strings.NewReader("hello"))
+	})
+	mux.HandleFunc("/SHA256SUMS", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(helloSHA256 + "  hello.iso\n"))
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCache_Get(t *testing.T) {
+	var requests int32
+	s := testServer(t, &requests)
+	c := New(t.TempDir())
+
+	for _, checksum := range []string{"sha256:" + helloSHA256, "file:" + s.URL + "/SHA256SUMS", "none"} {
+		path, err := c.Get(context.Background(), s.URL+"/hello.iso", checksum)
+		if err != nil {
+			t.Fatalf("%s: %s", checksum, err)
+		}
+		if b, _ := os.ReadFile(path); string(b) != "hello" {
+			t.Fatalf("bad content: %q", b)
+		}
+		if filepath.Ext(path) != ".iso" {
+			t.Fatalf("the extension should be kept: %s", path)
+		}
+	}
+	// The file is stored once by checksum, and once by URL.
+	if _, err := c.Get(context.Background(), s.URL+"/hello.iso", helloSHA256); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := c.Get(context.Background(), s.URL+"/hello.iso", ""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 downloads, got %d", requests)
+	}
+}
+
+func TestCache_GetChecksumMismatch(t *testing.T) {
+	var requests int32
+	s := testServer(t, &requests)
+	c := New(t.TempDir())
+
+	_, err := c.Get(context.Background(), s.URL+"/hello.iso", "sha256:"+strings.Repeat("0", 64))
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+	entries, _ := os.ReadDir(c.Dir)
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), lockSuffix) {
+			t.Fatalf("the bad download should be removed: %s", e.Name())
+		}
+	}
+}
+
+func TestCache_GetResume(t *testing.T) {
+	var requests int32
+	s := testServer(t, &requests)
+	c := New(t.TempDir())
+
+	checksum := "sha256:" + helloSHA256
+	sum, _ := ParseChecksum(checksum)
+	part := filepath.Join(c.Dir, entryName(mustURL(t, c, s.URL+"/hello.iso"), sum)) + partSuffix
+	if err := os.WriteFile(part, []byte("hel"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path, err := c.Get(context.Background(), s.URL+"/hello.iso", checksum)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "hello" {
+		t.Fatalf("bad content: %q", b)
+	}
+}
+
+func mustURL(t *testing.T, c *Cache, source string) *url.URL {
+	_, u, err := c.parseSource(source)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return u
+}
+
+// blockingFetcher waits for release before writing hello, counting its
+// fetches.
+type blockingFetcher struct {
+	fetches int32
+	release chan struct{}
+}
+
+func (f *blockingFetcher) Fetch(ctx context.Context, req *FetchRequest) error {
+	atomic.AddInt32(&f.fetches, 1)
+	<-f.release
+	return os.WriteFile(req.Dst, []byte("hello"), 0644)
+}
+
+func TestCache_GetConcurrent(t *testing.T) {
+	f := &blockingFetcher{release: make(chan struct{})}
+	c := &Cache{Dir: t.TempDir(), Fetchers: map[string]Fetcher{"test": f}}
+
+	var wg sync.WaitGroup
+	paths := make([]string, 5)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path, err := c.Get(context.Background(), "test://example.com/hello.iso", helloSHA256)
+			if err != nil {
+				t.Errorf("err: %s", err)
+			}
+			paths[i] = path
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(f.release)
+	wg.Wait()
+
+	if f.fetches != 1 {
+		t.Fatalf("expected 1 fetch, got %d", f.fetches)
+	}
+	for _, path := range paths {
+		if path != paths[0] {
+			t.Fatalf("the paths should be the same: %v", paths)
+		}
+	}
+}
+
+func TestCache_GetLocal(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "hello.iso")
+	if err := os.WriteFile(src, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	c := New(t.TempDir())
+
+	path, err := c.Get(context.Background(), src, helloSHA256)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if path != src {
+		t.Fatalf("local files should be used in place, got %s", path)
+	}
+
+	c.Fetchers["file"] = &FileFetcher{Copy: true}
+	path, err = c.Get(context.Background(), src, helloSHA256)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filepath.Dir(path) != c.Dir {
+		t.Fatalf("the file should be copied to the cache, got %s", path)
+	}
+
+	if _, err := c.Get(context.Background(), "ftp://example.com/hello.iso", ""); err == nil {
+		t.Fatal("schemes without fetchers should fail")
+	}
+}
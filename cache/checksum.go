@@ -0,0 +1,184 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// hashes are the supported checksum types, with their hex encoded length.
+var hashes = map[string]struct {
+	new    func() hash.Hash
+	length int
+}{
+	"md5":    {md5.New, 32},
+	"sha1":   {sha1.New, 40},
+	"sha256": {sha256.New, 64},
+	"sha512": {sha512.New, 128},
+}
+
+// Checksum is the expected checksum of a file.
+type Checksum struct {
+	// Type is md5, sha1, sha256 or sha512.
+	Type  string
+	Value []byte
+}
+
+func (c *Checksum) String() string {
+	return c.Type + ":" + hex.EncodeToString(c.Value)
+}
+
+// ChecksumError is returned when a file doesn't match its checksum.
+type ChecksumError struct {
+	Expected *Checksum
+	Actual   []byte
+	File     string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum of %s did not match: expected %s, got %s",
+		e.File, e.Expected, hex.EncodeToString(e.Actual))
+}
+
+// ParseChecksum parses a checksum given as "type:value", or as a bare value,
+// its type guessed from its length. "" and "none" are no checksum, for which
+// ParseChecksum returns nil.
+func ParseChecksum(checksum string) (*Checksum, error) {
+	if checksum == "" || checksum == "none" {
+		return nil, nil
+	}
+	typ, value, ok := strings.Cut(checksum, ":")
+	if !ok {
+		value = checksum
+		typ = typeOfLength(len(value))
+		if typ == "" {
+			return nil, fmt.Errorf("can't guess the type of checksum %q, use type:value", checksum)
+		}
+	}
+	typ = strings.ToLower(typ)
+	h, ok := hashes[typ]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checksum type %q, expected md5, sha1, sha256 or sha512", typ)
+	}
+	b, err := hex.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(value) != h.length {
+		return nil, fmt.Errorf("invalid %s checksum %q", typ, value)
+	}
+	return &Checksum{Type: typ, Value: b}, nil
+}
+
+func typeOfLength(n int) string {
+	for typ, h := range hashes {
+		if h.length == n {
+			return typ
+		}
+	}
+	return ""
+}
+
+// Verify returns a *ChecksumError if the file at path doesn't match c.
+func (c *Checksum) Verify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := hashes[c.Type].new()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("Error computing the checksum of %s: %s", path, err)
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, c.Value) {
+		return &ChecksumError{Expected: c, Actual: actual, File: path}
+	}
+	return nil
+}
+
+// resolveChecksum returns the checksum of source, fetching the checksum file
+// of "file:<url>" checksums, like the SHA256SUMS files of distributions.
+func (c *Cache) resolveChecksum(ctx context.Context, checksum string, source *url.URL) (*Checksum, error) {
+	sumsURL, ok := strings.CutPrefix(checksum, "file:")
+	if !ok {
+		return ParseChecksum(checksum)
+	}
+	fetcher, u, err := c.parseSource(sumsURL)
+	if err != nil {
+		return nil, fmt.Errorf("checksum file: %s", err)
+	}
+	tmp, err := os.CreateTemp("", "packer-checksum")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := fetcher.Fetch(ctx, &FetchRequest{URL: u, Dst: tmp.Name()}); err != nil {
+		return nil, fmt.Errorf("Error fetching checksum file %s: %s", sumsURL, err)
+	}
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sum, err := findChecksum(f, path.Base(source.Path))
+	if err != nil {
+		return nil, fmt.Errorf("checksum file %s: %s", sumsURL, err)
+	}
+	return sum, nil
+}
+
+// findChecksum finds the checksum of the file name in a checksum file, in the
+// GNU "value  name" or BSD "TYPE (name) = value" formats. Files holding a
+// single checksum are taken to be for name.
+func findChecksum(r io.Reader, name string) (*Checksum, error) {
+	var single []string
+	lines := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines++
+		fields := strings.Fields(line)
+
+		// BSD format: SHA256 (name) = value
+		if len(fields) == 4 && fields[2] == "=" && strings.HasPrefix(fields[1], "(") && strings.HasSuffix(fields[1], ")") {
+			if sameFile(strings.Trim(fields[1], "()"), name) {
+				return ParseChecksum(strings.ToLower(fields[0]) + ":" + fields[3])
+			}
+			continue
+		}
+		// GNU format: value  name, or value *name in binary mode.
+		if len(fields) >= 2 && sameFile(strings.TrimPrefix(strings.Join(fields[1:], " "), "*"), name) {
+			return ParseChecksum(fields[0])
+		}
+		if len(fields) == 1 {
+			single = fields
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lines == 1 && single != nil {
+		return ParseChecksum(single[0])
+	}
+	return nil, fmt.Errorf("no checksum found for %s", name)
+}
+
+func sameFile(listed, name string) bool {
+	return path.Base(strings.TrimPrefix(listed, "./")) == name
+}
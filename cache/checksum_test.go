@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestParseChecksum(t *testing.T) {
+	for _, checksum := range []string{"sha256:" + helloSHA256, "SHA256:" + helloSHA256, helloSHA256} {
+		sum, err := ParseChecksum(checksum)
+		if err != nil {
+			t.Fatalf("%s: %s", checksum, err)
+		}
+		if sum.String() != "sha256:"+helloSHA256 {
+			t.Fatalf("bad checksum: %s", sum)
+		}
+	}
+	for _, checksum := range []string{"", "none"} {
+		if sum, err := ParseChecksum(checksum); sum != nil || err != nil {
+			t.Fatalf("%q should be no checksum: %v %v", checksum, sum, err)
+		}
+	}
+	for _, checksum := range []string{"crc32:abcd", "sha256:abcd", "sha256:" + strings.Repeat("z", 64), "abc"} {
+		if _, err := ParseChecksum(checksum); err == nil {
+			t.Fatalf("%q should be rejected", checksum)
+		}
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	cases := []struct {
+		name string
+		file string
+	}{
+		{"gnu", helloSHA256 + "  hello.iso\n" + strings.Repeat("0", 64) + "  other.iso\n"},
+		{"gnu binary", strings.Repeat("0", 64) + " *other.iso\n" + helloSHA256 + " *./hello.iso\n"},
+		{"bsd", "SHA256 (other.iso) = " + strings.Repeat("0", 64) + "\nSHA256 (hello.iso) = " + helloSHA256 + "\n"},
+		{"single", "# the iso\n" + helloSHA256 + "\n"},
+	}
+	for _, tc := range cases {
+		sum, err := findChecksum(strings.NewReader(tc.file), "hello.iso")
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if sum.String() != "sha256:"+helloSHA256 {
+			t.Fatalf("%s: bad checksum %s", tc.name, sum)
+		}
+	}
+
+	if _, err := findChecksum(strings.NewReader(helloSHA256+"  other.iso\n"), "hello.iso"); err == nil {
+		t.Fatal("files not listed should fail")
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package cache downloads the files builders need, like ISOs, into a local cache
+shared by the builds running on the host, and verifies their checksum:
+
+	path, err := cache.Get(ctx, "https://example.com/ubuntu.iso", "sha256:ab12...")
+
+Files are fetched by the Fetcher registered for the scheme of their URL; http,
+https, file, smb, and the s3:: and gcs:: go-getter forced URLs are supported
+by default. Downloads are written next to their destination and resumed when
+an interrupted download is retried, when the fetcher supports it.
+
+Concurrent downloads of the same file are done once: within a process the
+callers wait for the first one, and across processes the file is locked with
+package filelock. Entries unused for longer than the TTL of the cache, or over
+its maximum size, are removed by GC.
+*/
+package cache
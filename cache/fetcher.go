@@ -0,0 +1,273 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	gcs "github.com/hashicorp/go-getter/gcs/v2"
+	s3 "github.com/hashicorp/go-getter/s3/v2"
+	getter "github.com/hashicorp/go-getter/v2"
+
+	"github.com/hashicorp/packer-plugin-sdk/iohelper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// defaultFetchTimeout is the timeout of the go-getter fetchers, long enough
+// for large and slow downloads.
+const defaultFetchTimeout = 30 * time.Minute
+
+// FetchRequest is a file for a Fetcher to download.
+type FetchRequest struct {
+	URL *url.URL
+	// Dst is the path to write the file to.
+	Dst string
+	// Offset is the number of bytes of the file already in Dst, from an
+	// interrupted download. Fetchers supporting it download the rest of the
+	// file only; the others overwrite Dst.
+	Offset int64
+	// Progress tracks the download, when not nil.
+	Progress packersdk.ProgressTracker
+}
+
+// A Fetcher downloads files, for the URL schemes it is registered for in
+// Cache.Fetchers.
+type Fetcher interface {
+	Fetch(ctx context.Context, req *FetchRequest) error
+}
+
+// DefaultFetchers returns the fetchers of the http, https, file and smb URL
+// schemes, and of the s3 and gcs forced go-getter URLs, like
+// s3::https://s3.amazonaws.com/bucket/ubuntu.iso.
+func DefaultFetchers() map[string]Fetcher {
+	httpFetcher := new(HTTPFetcher)
+	return map[string]Fetcher{
+		"http":  httpFetcher,
+		"https": httpFetcher,
+		"file":  new(FileFetcher),
+		"smb": &GetterFetcher{Getters: []getter.Getter{
+			new(getter.SmbClientGetter),
+			new(getter.SmbMountGetter),
+		}},
+		"s3": &GetterFetcher{
+			Getters: []getter.Getter{&s3.Getter{Timeout: defaultFetchTimeout}},
+			Forced:  "s3",
+		},
+		"gcs": &GetterFetcher{
+			Getters: []getter.Getter{&gcs.Getter{Timeout: defaultFetchTimeout}},
+			Forced:  "gcs",
+		},
+	}
+}
+
+// openDst opens the destination of req, for appending when resuming.
+func openDst(req *FetchRequest, resume bool) (*os.File, error) {
+	if resume {
+		return os.OpenFile(req.Dst, os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	return os.OpenFile(req.Dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+// copyProgress copies src to dst until ctx is done, reporting the progress
+// of the download to req.Progress.
+func copyProgress(ctx context.Context, dst io.Writer, src io.Reader, req *FetchRequest, offset, total int64) error {
+	if req.Progress != nil {
+		req.Progress.Start(req.URL.String(), total)
+		defer req.Progress.Finish()
+		req.Progress.Add(offset)
+		src = packersdk.ProgressReader(req.Progress, src)
+	}
+	_, err := iohelper.CancellableCopy(ctx, dst, src)
+	return err
+}
+
+// HTTPFetcher downloads files over HTTP, resuming downloads with range
+// requests.
+type HTTPFetcher struct {
+	// Client is the client to use; http.DefaultClient when nil.
+	Client *http.Client
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, req *FetchRequest) error {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if req.Offset > 0 {
+		httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", req.Offset))
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	resume := false
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusPartialContent:
+		resume = req.Offset > 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial download doesn't match the file anymore.
+		restart := *req
+		restart.Offset = 0
+		return f.Fetch(ctx, &restart)
+	default:
+		return fmt.Errorf("bad response downloading %s: %s", req.URL, resp.Status)
+	}
+
+	dst, err := openDst(req, resume)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	offset := int64(0)
+	if resume {
+		offset = req.Offset
+	}
+	total := int64(0)
+	if resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+	if err := copyProgress(ctx, dst, resp.Body, req, offset, total); err != nil {
+		return fmt.Errorf("Error downloading %s: %s", req.URL, err)
+	}
+	return dst.Close()
+}
+
+// A LocalFetcher is a Fetcher of local files, that the cache uses in place
+// rather than copying them.
+type LocalFetcher interface {
+	Fetcher
+	// LocalPath returns the path of the file of u, and false when the file
+	// must be copied to the cache.
+	LocalPath(u *url.URL) (string, bool)
+}
+
+// FileFetcher fetches local files, used in place unless Copy is set. Copies
+// are resumed from their offset.
+type FileFetcher struct {
+	// Copy copies the files to the cache.
+	Copy bool
+}
+
+func (f *FileFetcher) LocalPath(u *url.URL) (string, bool) {
+	return localPath(u), !f.Copy
+}
+
+func (f *FileFetcher) Fetch(ctx context.Context, req *FetchRequest) error {
+	src, err := os.Open(localPath(req.URL))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	resume := req.Offset > 0 && req.Offset <= info.Size()
+	offset := int64(0)
+	if resume {
+		if offset, err = src.Seek(req.Offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	dst, err := openDst(req, resume)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if err := copyProgress(ctx, dst, src, req, offset, info.Size()); err != nil {
+		return fmt.Errorf("Error copying %s: %s", src.Name(), err)
+	}
+	return dst.Close()
+}
+
+// localPath returns the path of a file URL.
+func localPath(u *url.URL) string {
+	p := u.Path
+	if u.Opaque != "" {
+		p = u.Opaque
+	}
+	if runtime.GOOS == "windows" {
+		// file:///C:/path has the /C:/path path.
+		if len(p) > 2 && p[0] == '/' && p[2] == ':' {
+			p = p[1:]
+		}
+		if u.Host != "" {
+			p = `\\` + u.Host + p
+		}
+	}
+	return filepath.FromSlash(p)
+}
+
+// GetterFetcher downloads files with go-getter getters. It doesn't resume
+// downloads.
+type GetterFetcher struct {
+	Getters []getter.Getter
+	// Forced is the getter forced in the go-getter source, like "s3" for
+	// s3::https://s3.amazonaws.com/bucket/file.
+	Forced string
+}
+
+func (f *GetterFetcher) Fetch(ctx context.Context, req *FetchRequest) error {
+	src := req.URL.String()
+	if f.Forced != "" {
+		src = f.Forced + "::" + src
+	}
+	if err := os.Remove(req.Dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	client := getter.Client{
+		DisableSymlinks: true,
+		Getters:         f.Getters,
+	}
+	getterReq := &getter.Request{
+		Src:     src,
+		Dst:     req.Dst,
+		GetMode: getter.ModeFile,
+		Copy:    true,
+	}
+	if req.Progress != nil {
+		getterReq.ProgressListener = getterProgress{req.Progress}
+	}
+	_, err := client.Get(ctx, getterReq)
+	return err
+}
+
+// getterProgress reports the progress of a go-getter download to a
+// ProgressTracker.
+type getterProgress struct {
+	tracker packersdk.ProgressTracker
+}
+
+func (p getterProgress) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	p.tracker.Start(src, totalSize)
+	p.tracker.Add(currentSize)
+	return &trackedReadCloser{Reader: packersdk.ProgressReader(p.tracker, stream), stream: stream, tracker: p.tracker}
+}
+
+type trackedReadCloser struct {
+	io.Reader
+	stream  io.Closer
+	tracker packersdk.ProgressTracker
+}
+
+func (r *trackedReadCloser) Close() error {
+	r.tracker.Finish()
+	return r.stream.Close()
+}
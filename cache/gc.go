@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cache
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+)
+
+// GC removes the entries of the cache, and the partial downloads, that were
+// not used for longer than TTL, then the least recently used entries until
+// the cache holds at most MaxSize bytes. Entries being downloaded, by this
+// process or another, are kept. It returns the paths of the removed files.
+//
+// The lock files of the entries are kept, as removing them would let two
+// processes lock the same entry.
+func (c *Cache) GC() ([]string, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	total := int64(0)
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), lockSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{filepath.Join(c.Dir, e.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	// Least recently used first.
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var removed []string
+	now := time.Now()
+	for _, f := range files {
+		expired := c.TTL > 0 && now.Sub(f.modTime) > c.TTL
+		oversized := c.MaxSize > 0 && total > c.MaxSize
+		if !expired && !oversized {
+			continue
+		}
+		lock := filelock.New(strings.TrimSuffix(f.path, partSuffix) + lockSuffix)
+		if ok, err := lock.TryLock(); err != nil || !ok {
+			log.Printf("[DEBUG] Keeping %s, in use", f.path)
+			continue
+		}
+		err := os.Remove(f.path)
+		lock.Unlock()
+		if err != nil {
+			return removed, err
+		}
+		log.Printf("[INFO] Removed %s from the cache", f.path)
+		removed = append(removed, f.path)
+		total -= f.size
+	}
+	return removed, nil
+}
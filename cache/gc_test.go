@@ -0,0 +1,50 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+)
+
+func TestCache_GC(t *testing.T) {
+	c := &Cache{Dir: t.TempDir(), TTL: time.Hour, MaxSize: 10}
+	now := time.Now()
+	write := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(c.Dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		os.Chtimes(path, now.Add(-age), now.Add(-age))
+		return path
+	}
+	expired := write("expired.iso", 1, 2*time.Hour)
+	stalePart := write("stale.iso.part", 1, 2*time.Hour)
+	oldest := write("oldest.iso", 6, 30*time.Minute)
+	write("recent.iso", 6, time.Minute)
+	locked := write("locked.iso", 1, 3*time.Hour)
+
+	lock := filelock.New(locked + lockSuffix)
+	if ok, err := lock.TryLock(); !ok || err != nil {
+		t.Fatalf("lock: %v %v", ok, err)
+	}
+	defer lock.Unlock()
+
+	removed, err := c.GC()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{expired, oldest, stalePart}
+	sort.Strings(removed)
+	sort.Strings(expected)
+	if diff := cmp.Diff(expected, removed); diff != "" {
+		t.Fatalf("unexpected removals: %s", diff)
+	}
+}
@@ -126,6 +126,10 @@ func (c *Communicator) UploadDir(dst string, src string, exclude []string) error
 	return err
 }
 
+func (c *Communicator) Capabilities() (packersdk.CommunicatorCapabilities, bool) {
+	return packersdk.CommunicatorCapabilities{}, true
+}
+
 func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
 	return fmt.Errorf("DownloadDir is not implemented for amazon-chroot")
 }
@@ -20,6 +20,10 @@ more expensive because the instance used to perform the build must be kept
 running persistently in order to build images, whereas the other non-chroot
 cloud image builders start instances on-demand to build images as needed.
 
+MountManager tracks the filesystems mounted, the devices mapped and the files
+copied into the chroot, and tears them down in the reverse order, so that
+chroot builders don't have to.
+
 The HashiCorp-maintained Amazon and Azure builder plugins have chroot builders
 which use this option and can serve as an example for how the chroot steps and
 communicator are used.
@@ -0,0 +1,320 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// A Mount is a filesystem mounted within the chroot.
+type Mount struct {
+	// Type is the type of the filesystem, like "proc", or "bind" for bind
+	// mounts.
+	Type string
+	// Source is the device, or the host path of a bind mount.
+	Source string
+	// Path is the path of the mount, relative to the root of the chroot.
+	Path string
+	// Options are passed to mount with -o.
+	Options []string
+}
+
+// DefaultMounts are the special filesystems most chroots need to run
+// package managers and init scripts. They match the chroot_mounts default of
+// the HashiCorp-maintained chroot builders.
+var DefaultMounts = []Mount{
+	{Type: "proc", Source: "proc", Path: "/proc"},
+	{Type: "sysfs", Source: "sysfs", Path: "/sys"},
+	{Type: "bind", Source: "/dev", Path: "/dev"},
+	{Type: "devpts", Source: "devpts", Path: "/dev/pts"},
+	{Type: "binfmt_misc", Source: "binfmt_misc", Path: "/proc/sys/fs/binfmt_misc"},
+}
+
+// MountsFromChrootMounts converts the [type, source, path] triplets of a
+// chroot_mounts option to Mounts.
+func MountsFromChrootMounts(chrootMounts [][]string) ([]Mount, error) {
+	mounts := make([]Mount, 0, len(chrootMounts))
+	for i, m := range chrootMounts {
+		if len(m) != 3 {
+			return nil, fmt.Errorf("chroot_mounts[%d]: expected [type, source, path], got %d elements", i, len(m))
+		}
+		mounts = append(mounts, Mount{Type: m[0], Source: m[1], Path: m[2]})
+	}
+	return mounts, nil
+}
+
+// ResolvConf is how a MountManager provides the DNS configuration of the host
+// to the chroot.
+type ResolvConf string
+
+const (
+	// ResolvConfNone leaves the resolv.conf of the chroot alone.
+	ResolvConfNone ResolvConf = "none"
+	// ResolvConfCopy copies the resolv.conf of the host over the one of the
+	// chroot, which is restored on teardown.
+	ResolvConfCopy ResolvConf = "copy"
+	// ResolvConfBind bind mounts the resolv.conf of the host over the one of
+	// the chroot.
+	ResolvConfBind ResolvConf = "bind"
+)
+
+// undo reverts one change made to the chroot.
+type undo struct {
+	desc string
+	fn   func() error
+}
+
+// MountManager sets up a chroot: it mounts filesystems, maps devices and
+// copies files in, and records each change so that Teardown reverts them in
+// the reverse order, whatever step failed. Chroot builders share it instead
+// of each tracking their mounts:
+//
+//	m := chroot.NewMountManager(mountPath, wrappedCommand)
+//	defer m.TeardownOnPanic()
+//	if err := m.MountAll(chroot.DefaultMounts); err != nil {
+//		...
+//	}
+//	state.Put("mount_extra_cleanup", m)
+//
+// A MountManager is a Cleanup, to be run by StepEarlyCleanup.
+type MountManager struct {
+	// Root is the host path the chroot is mounted at.
+	Root string
+	// Wrap wraps the commands run on the host, usually with sudo.
+	Wrap common.CommandWrapper
+
+	lock  sync.Mutex
+	undos []undo
+}
+
+// NewMountManager returns a manager of the chroot at root, running commands
+// wrapped with wrap.
+func NewMountManager(root string, wrap common.CommandWrapper) *MountManager {
+	return &MountManager{Root: root, Wrap: wrap}
+}
+
+// path returns the host path of path in the chroot.
+func (m *MountManager) path(path string) string {
+	return filepath.Join(m.Root, path)
+}
+
+func (m *MountManager) run(command string) error {
+	if m.Wrap != nil {
+		var err error
+		command, err = m.Wrap(command)
+		if err != nil {
+			return fmt.Errorf("Error creating command: %s", err)
+		}
+	}
+	stderr := new(bytes.Buffer)
+	cmd := common.ShellCommand(command)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return &commandError{err: err, stderr: stderr.String()}
+	}
+	return nil
+}
+
+type commandError struct {
+	err    error
+	stderr string
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("%s\nStderr: %s", e.err, e.stderr)
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
+
+func (m *MountManager) push(desc string, fn func() error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.undos = append(m.undos, undo{desc: desc, fn: fn})
+}
+
+// Mount mounts mt within the chroot, creating its directory.
+func (m *MountManager) Mount(mt Mount) error {
+	path := m.path(mt.Path)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("Error creating mount directory: %s", err)
+	}
+
+	flags := "-t " + mt.Type
+	if mt.Type == "bind" {
+		flags = "--bind"
+	}
+	if len(mt.Options) > 0 {
+		flags += " -o " + shellQuote(strings.Join(mt.Options, ","))
+	}
+	log.Printf("[INFO] Mounting %s at %s", mt.Source, path)
+	if err := m.run(fmt.Sprintf("mount %s %s %s", flags, shellQuote(mt.Source), shellQuote(path))); err != nil {
+		return fmt.Errorf("Error mounting %s: %w", mt.Path, err)
+	}
+	m.push("unmount "+path, func() error { return m.unmount(path) })
+	return nil
+}
+
+// MountAll mounts mounts in order, stopping at the first failure.
+func (m *MountManager) MountAll(mounts []Mount) error {
+	for _, mt := range mounts {
+		if err := m.Mount(mt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmount unmounts path, unless it was already unmounted.
+func (m *MountManager) unmount(path string) error {
+	err := m.run(fmt.Sprintf("grep %s /proc/mounts", shellQuote(path)))
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		log.Printf("[DEBUG] %s is already unmounted", path)
+		return nil
+	}
+	if err := m.run("umount " + shellQuote(path)); err != nil {
+		return fmt.Errorf("Error unmounting %s: %w", path, err)
+	}
+	return nil
+}
+
+// MapDevice creates the device-mapper device name from table, with dmsetup,
+// and returns its path. The device is removed on teardown.
+func (m *MountManager) MapDevice(name, table string) (string, error) {
+	if err := m.run(fmt.Sprintf("dmsetup create %s --table %s", shellQuote(name), shellQuote(table))); err != nil {
+		return "", fmt.Errorf("Error creating device %s: %w", name, err)
+	}
+	m.push("remove device "+name, func() error {
+		if err := m.run("dmsetup remove " + shellQuote(name)); err != nil {
+			return fmt.Errorf("Error removing device %s: %w", name, err)
+		}
+		return nil
+	})
+	return "/dev/mapper/" + name, nil
+}
+
+// CopyFile copies the host file src to dst, in the chroot. If restore is
+// true, the file dst is moved aside and restored on teardown; otherwise the
+// copy is removed on teardown.
+func (m *MountManager) CopyFile(src, dst string, restore bool) error {
+	path := m.path(dst)
+	backup := path + ".packer-chroot-backup"
+	if restore {
+		if err := m.run(fmt.Sprintf("if [ -e %[1]s ] || [ -L %[1]s ]; then mv %[1]s %[2]s; fi", shellQuote(path), shellQuote(backup))); err != nil {
+			return fmt.Errorf("Error backing up %s: %w", dst, err)
+		}
+	}
+	log.Printf("[INFO] Copying '%s' to '%s'", src, path)
+	if err := m.run(fmt.Sprintf("cp %s %s %s", removeDestinationOption(), shellQuote(src), shellQuote(path))); err != nil {
+		if restore {
+			m.run(fmt.Sprintf("if [ -e %[2]s ] || [ -L %[2]s ]; then mv %[2]s %[1]s; fi", shellQuote(path), shellQuote(backup)))
+		}
+		return fmt.Errorf("Error copying file %s: %w", src, err)
+	}
+	m.push("remove "+path, func() error {
+		command := "rm -f " + shellQuote(path)
+		if restore {
+			command = fmt.Sprintf("rm -f %[1]s && if [ -e %[2]s ] || [ -L %[2]s ]; then mv %[2]s %[1]s; fi", shellQuote(path), shellQuote(backup))
+		}
+		if err := m.run(command); err != nil {
+			return fmt.Errorf("Error removing %s: %w", path, err)
+		}
+		return nil
+	})
+	return nil
+}
+
+// CopyFiles copies the host files to the same paths in the chroot, removing
+// them on teardown, like the copy_files option of chroot builders.
+func (m *MountManager) CopyFiles(files []string) error {
+	for _, f := range files {
+		if err := m.CopyFile(f, f, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetupResolvConf provides the /etc/resolv.conf of the host to the chroot,
+// as set by mode. The empty mode is ResolvConfNone.
+func (m *MountManager) SetupResolvConf(mode ResolvConf) error {
+	const resolvConf = "/etc/resolv.conf"
+	switch mode {
+	case "", ResolvConfNone:
+		return nil
+	case ResolvConfCopy:
+		return m.CopyFile(resolvConf, resolvConf, true)
+	case ResolvConfBind:
+		path := m.path(resolvConf)
+		if err := m.run("touch " + shellQuote(path)); err != nil {
+			return fmt.Errorf("Error creating %s: %w", path, err)
+		}
+		return m.Mount(Mount{Type: "bind", Source: resolvConf, Path: resolvConf})
+	default:
+		return fmt.Errorf("unknown resolv.conf mode %q, expected %q, %q or %q", mode, ResolvConfNone, ResolvConfCopy, ResolvConfBind)
+	}
+}
+
+// Teardown reverts the changes made to the chroot, the last one first. It
+// stops at the first failure, leaving the remaining changes to a later call.
+func (m *MountManager) Teardown() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for len(m.undos) > 0 {
+		last := m.undos[len(m.undos)-1]
+		log.Printf("[DEBUG] Chroot teardown: %s", last.desc)
+		if err := last.fn(); err != nil {
+			return err
+		}
+		m.undos = m.undos[:len(m.undos)-1]
+	}
+	return nil
+}
+
+// TeardownOnPanic tears the chroot down if the goroutine panics, before
+// panicking again. It must be deferred directly.
+func (m *MountManager) TeardownOnPanic() {
+	if r := recover(); r != nil {
+		if err := m.Teardown(); err != nil {
+			log.Printf("[ERROR] Chroot teardown after panic: %s", err)
+		}
+		panic(r)
+	}
+}
+
+// CleanupFunc tears the chroot down; see Cleanup.
+func (m *MountManager) CleanupFunc(multistep.StateBag) error {
+	return m.Teardown()
+}
+
+// removeDestinationOption is the option of cp replacing the destination
+// rather than writing through it, if it is a symbolic link.
+func removeDestinationOption() string {
+	if runtime.GOOS == "freebsd" {
+		// The -f option here is closer to GNU --remove-destination than
+		// what POSIX says -f should do.
+		return "-f"
+	}
+	// This is the GNU binutils version.
+	return "--remove-destination"
+}
+
+// shellQuote quotes s for /bin/sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,175 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordingWrapper records the commands it wraps and replaces them with
+// true, or with false for the ones containing fail.
+func recordingWrapper(commands *[]string, fail string) func(string) (string, error) {
+	return func(command string) (string, error) {
+		*commands = append(*commands, command)
+		if fail != "" && strings.Contains(command, fail) {
+			return "false", nil
+		}
+		return "true", nil
+	}
+}
+
+func TestMountManager_Teardown(t *testing.T) {
+	root := t.TempDir()
+	var commands []string
+	m := NewMountManager(root, recordingWrapper(&commands, ""))
+
+	if err := m.MountAll(DefaultMounts[:2]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dev, err := m.MapDevice("root", "0 8 linear /dev/sdf 0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if dev != "/dev/mapper/root" {
+		t.Fatalf("bad device: %s", dev)
+	}
+	if err := m.Mount(Mount{Type: "bind", Source: "/tmp", Path: "/tmp", Options: []string{"ro"}}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	proc, sys, tmp := filepath.Join(root, "proc"), filepath.Join(root, "sys"), filepath.Join(root, "tmp")
+
+	commands = nil
+	if err := m.Teardown(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{
+		"grep '" + tmp + "' /proc/mounts",
+		"umount '" + tmp + "'",
+		"dmsetup remove 'root'",
+		"grep '" + sys + "' /proc/mounts",
+		"umount '" + sys + "'",
+		"grep '" + proc + "' /proc/mounts",
+		"umount '" + proc + "'",
+	}
+	if diff := cmp.Diff(expected, commands); diff != "" {
+		t.Fatalf("unexpected teardown: %s", diff)
+	}
+
+	commands = nil
+	if err := m.Teardown(); err != nil || len(commands) != 0 {
+		t.Fatalf("second teardown should do nothing: %v %#v", err, commands)
+	}
+}
+
+func TestMountManager_TeardownFailure(t *testing.T) {
+	root := t.TempDir()
+	var commands []string
+	m := NewMountManager(root, recordingWrapper(&commands, "umount"))
+	if err := m.MountAll(DefaultMounts[:2]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := m.Teardown(); err == nil {
+		t.Fatal("teardown should fail")
+	}
+	// The failed unmount is kept to be retried.
+	m.Wrap = recordingWrapper(&commands, "")
+	commands = nil
+	if err := m.Teardown(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(commands) != 4 {
+		t.Fatalf("unexpected commands: %#v", commands)
+	}
+}
+
+func TestMountManager_MountFailure(t *testing.T) {
+	root := t.TempDir()
+	var commands []string
+	m := NewMountManager(root, recordingWrapper(&commands, "sysfs"))
+	if err := m.MountAll(DefaultMounts); err == nil {
+		t.Fatal("mounting sysfs should fail")
+	}
+
+	commands = nil
+	if err := m.Teardown(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(commands) != 2 || !strings.HasPrefix(commands[1], "umount") || !strings.Contains(commands[1], "proc") {
+		t.Fatalf("only proc should be unmounted: %#v", commands)
+	}
+}
+
+func TestMountManager_TeardownOnPanic(t *testing.T) {
+	root := t.TempDir()
+	var commands []string
+	m := NewMountManager(root, recordingWrapper(&commands, ""))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("the panic should go through, got %v", r)
+			}
+		}()
+		defer m.TeardownOnPanic()
+		if err := m.Mount(DefaultMounts[0]); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		panic("boom")
+	}()
+
+	if last := commands[len(commands)-1]; !strings.HasPrefix(last, "umount") {
+		t.Fatalf("proc should be unmounted, last command: %s", last)
+	}
+}
+
+func TestMountManager_SetupResolvConf(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "etc", "resolv.conf")
+
+	var commands []string
+	m := NewMountManager(root, recordingWrapper(&commands, ""))
+	if err := m.SetupResolvConf(ResolvConfCopy); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(commands[0], "mv '"+path+"' '"+path+".packer-chroot-backup'") {
+		t.Fatalf("resolv.conf should be backed up: %#v", commands)
+	}
+	commands = nil
+	if err := m.Teardown(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(commands[0], "mv '"+path+".packer-chroot-backup' '"+path+"'") {
+		t.Fatalf("resolv.conf should be restored: %#v", commands)
+	}
+
+	commands = nil
+	if err := m.SetupResolvConf(ResolvConfBind); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if commands[1] != "mount --bind '/etc/resolv.conf' '"+path+"'" {
+		t.Fatalf("resolv.conf should be bind mounted: %#v", commands)
+	}
+
+	if err := m.SetupResolvConf("symlink"); err == nil {
+		t.Fatal("unknown modes should be rejected")
+	}
+}
+
+func TestMountsFromChrootMounts(t *testing.T) {
+	mounts, err := MountsFromChrootMounts([][]string{{"proc", "proc", "/proc"}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff := cmp.Diff([]Mount{DefaultMounts[0]}, mounts); diff != "" {
+		t.Fatalf("unexpected mounts: %s", diff)
+	}
+	if _, err := MountsFromChrootMounts([][]string{{"proc", "/proc"}}); err == nil {
+		t.Fatal("incomplete mounts should be rejected")
+	}
+}
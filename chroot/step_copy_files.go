@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
-	"runtime"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -36,16 +35,7 @@ func (s *StepCopyFiles) Run(ctx context.Context, state multistep.StateBag) multi
 	s.files = make([]string, 0, len(s.Files))
 	if len(s.Files) > 0 {
 		ui.Say("Copying files from host to chroot...")
-		var removeDestinationOption string
-		switch runtime.GOOS {
-		case "freebsd":
-			// The -f option here is closer to GNU --remove-destination than
-			// what POSIX says -f should do.
-			removeDestinationOption = "-f"
-		default:
-			// This is the GNU binutils version.
-			removeDestinationOption = "--remove-destination"
-		}
+		removeDestinationOption := removeDestinationOption()
 		for _, path := range s.Files {
 			ui.Message(path)
 			chrootPath := filepath.Join(mountPath, path)
@@ -82,7 +82,7 @@ func (cmd *Command) run(args []string) error {
 		return errors.New("APIVersion needs to be set")
 	}
 
-	if len(desc.Builders) == 0 && len(desc.PostProcessors) == 0 && len(desc.Datasources) == 0 && len(desc.Provisioners) == 0 {
+	if len(desc.Builders) == 0 && len(desc.PostProcessors) == 0 && len(desc.Datasources) == 0 && len(desc.Provisioners) == 0 && len(desc.Functions) == 0 {
 		return errors.New("this plugin defines no component.")
 	}
 	return nil
@@ -96,6 +96,7 @@ type pluginDescription struct {
 	PostProcessors []string `json:"post_processors"`
 	Datasources    []string `json:"datasources"`
 	Provisioners   []string `json:"provisioners"`
+	Functions      []string `json:"functions"`
 }
 
 func isOldPlugin(pluginName string) bool {
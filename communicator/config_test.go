@@ -4,18 +4,25 @@
 package communicator
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/communicator/credentials"
+	"github.com/hashicorp/packer-plugin-sdk/fips"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/masterzen/winrm"
 	"golang.org/x/crypto/ssh"
@@ -54,6 +61,166 @@ func TestConfig_badtype(t *testing.T) {
 	}
 }
 
+func TestConfig_ssh_transport_url(t *testing.T) {
+	c := testConfig()
+	c.SSHTransportURL = "ftp://tunnel.example.com"
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error for an unsupported scheme, got: %#v", err)
+	}
+
+	c = testConfig()
+	c.SSHTransportURL = "wss://tunnel.example.com/ssh"
+	c.SSHBastionHost = "my.bastion"
+	c.SSHBastionAgentAuth = true
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error when combined with a bastion, got: %#v", err)
+	}
+
+	c = testConfig()
+	c.SSHTransportURL = "wss://tunnel.example.com/ssh"
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestConfig_ssh_bastion_hops(t *testing.T) {
+	c := testConfig()
+	c.SSHBastionHops = []SSHBastionHop{
+		{Host: "bastion.example.com", Username: "jump", AgentAuth: true},
+		{Host: "10.0.0.10", Port: 2222, Username: "jump", Password: "secret"},
+	}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if c.SSHBastionHops[0].Port != 22 || c.SSHBastionHops[1].Port != 2222 {
+		t.Fatalf("bad ports: %#v", c.SSHBastionHops)
+	}
+
+	c = testConfig()
+	c.SSHBastionHops = []SSHBastionHop{
+		{Username: "jump", AgentAuth: true},
+		{Host: "10.0.0.10", CertificateFile: "cert.pub"},
+	}
+	if err := c.Prepare(testContext(t)); len(err) != 3 {
+		t.Fatalf("expected errors for the missing host, auth and key, got: %#v", err)
+	}
+
+	c = testConfig()
+	c.SSHBastionHops = []SSHBastionHop{{Host: "bastion.example.com", AgentAuth: true}}
+	c.SSHBastionHost = "my.bastion"
+	c.SSHBastionAgentAuth = true
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error when combined with ssh_bastion_host, got: %#v", err)
+	}
+}
+
+func TestConfig_ssh_config_file(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	sshConfig := `
+Host builder
+    HostName 192.168.1.20
+    Port 2222
+    User packer
+    IdentityFile ~/.ssh/missing_packer_key
+    ProxyJump jump@bastion.example.com
+
+Host bastion.example.com
+    Port 2200
+`
+	if err := os.WriteFile(path, []byte(sshConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{SSH: SSH{SSHConfigFile: path, SSHConfigHost: "builder"}}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if c.SSHHost != "192.168.1.20" || c.SSHPort != 2222 || c.SSHUsername != "packer" || c.SSHPrivateKeyFile != "" {
+		t.Fatalf("bad settings: %q:%d as %q with %q", c.SSHHost, c.SSHPort, c.SSHUsername, c.SSHPrivateKeyFile)
+	}
+	want := []SSHBastionHop{{Host: "bastion.example.com", Port: 2200, Username: "jump", AgentAuth: true}}
+	if diff := cmp.Diff(want, c.SSHBastionHops); diff != "" {
+		t.Fatalf("unexpected hops: %s", diff)
+	}
+
+	// Settings of the template are kept.
+	c = &Config{SSH: SSH{SSHConfigFile: path, SSHConfigHost: "builder", SSHUsername: "root", SSHBastionHost: "other", SSHBastionAgentAuth: true}}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if c.SSHUsername != "root" || len(c.SSHBastionHops) != 0 {
+		t.Fatalf("template settings were overridden: %q, %#v", c.SSHUsername, c.SSHBastionHops)
+	}
+
+	c = testConfig()
+	c.SSHConfigHost = "builder"
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error for ssh_config_host without ssh_config_file, got: %#v", err)
+	}
+}
+
+func TestConfig_ssh_file_transfer_chunks(t *testing.T) {
+	c := testConfig()
+	c.SSHFileTransferMethod = "sftp"
+	c.SSHFileTransferChunkSize = 1 << 20
+	c.SSHFileTransferParallelism = 4
+	c.SSHFileTransferResume = true
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	c = testConfig()
+	c.SSHFileTransferChunkSize = 1 << 20
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error for chunks with scp, got: %#v", err)
+	}
+
+	c = testConfig()
+	c.SSHFileTransferMethod = "sftp"
+	c.SSHFileTransferVerifyChecksum = true
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error without a chunk size, got: %#v", err)
+	}
+}
+
+func TestConfig_ssh_output_encoding(t *testing.T) {
+	for _, enc := range []string{"auto", "cp850", "ISO-8859-1"} {
+		c := testConfig()
+		c.SSHOutputEncoding = enc
+		if err := c.Prepare(testContext(t)); len(err) > 0 {
+			t.Fatalf("%s: bad: %#v", enc, err)
+		}
+	}
+
+	c := testConfig()
+	c.SSHOutputEncoding = "klingon"
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error for an unknown encoding, got: %#v", err)
+	}
+}
+
+func TestConfig_ssh_powershell(t *testing.T) {
+	c := testConfig()
+	c.SSHPowerShell = true
+	c.SSHElevatedUser = "Administrator"
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	c = testConfig()
+	c.SSHElevatedUser = "Administrator"
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error without ssh_powershell, got: %#v", err)
+	}
+
+	c = testConfig()
+	c.SSHPowerShell = true
+	c.SSHOutputEncoding = "cp850"
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error with ssh_output_encoding, got: %#v", err)
+	}
+}
+
 func TestConfig_winrm_noport(t *testing.T) {
 	c := &Config{
 		Type: "winrm",
@@ -151,6 +318,85 @@ func TestConfig_winrm_use_ntlm(t *testing.T) {
 
 }
 
+func TestConfig_winrm_proxy(t *testing.T) {
+	c := &Config{
+		Type: "winrm",
+		WinRM: WinRM{
+			WinRMUser:  "admin",
+			WinRMProxy: "socks5://127.0.0.1:1080",
+		},
+	}
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error for an unsupported proxy scheme, got: %#v", err)
+	}
+
+	c.WinRMProxy = "http://127.0.0.1:3128"
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if c.WinRMTransportDecorator == nil {
+		t.Fatalf("WinRMTransportDecorator not set.")
+	}
+}
+
+func TestConfig_winrm_fips(t *testing.T) {
+	t.Setenv(fips.EnvVar, "true")
+
+	c := &Config{
+		Type: "winrm",
+		WinRM: WinRM{
+			WinRMUser:   "admin",
+			WinRMUseSSL: true,
+			WinRMProxy:  "http://127.0.0.1:3128",
+		},
+	}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	// Preparing again keeps the decorator of the proxy.
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// The decorator of the builder would be replaced by the FIPS transport.
+	c.WinRMProxy = ""
+	c.WinRMTransportDecorator = func() winrm.Transporter { return &winrm.ClientNTLM{} }
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error for a custom transport, got: %#v", err)
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://env-proxy:3128")
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("NO_PROXY", "bypassed.example.com")
+
+	tc := []struct {
+		proxy    string
+		endpoint string
+		expected string
+	}{
+		{"", "http://winrm.example.com:5985/wsman", "http://env-proxy:3128"},
+		{"http://explicit:8080", "http://winrm.example.com:5985/wsman", "http://explicit:8080"},
+		{"http://explicit:8080", "https://winrm.example.com:5986/wsman", "http://explicit:8080"},
+		{"http://explicit:8080", "http://bypassed.example.com:5985/wsman", ""},
+	}
+	for _, tt := range tc {
+		req, _ := http.NewRequest("POST", tt.endpoint, nil)
+		u, err := ProxyFunc(tt.proxy)(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		actual := ""
+		if u != nil {
+			actual = u.String()
+		}
+		if actual != tt.expected {
+			t.Errorf("ProxyFunc(%q) for %s = %q, expected %q", tt.proxy, tt.endpoint, actual, tt.expected)
+		}
+	}
+}
+
 // generateSSHPrivateKey generates a new RSA SSH private key for use in tests
 //
 // It returns the path in which the key was created.
@@ -574,6 +820,74 @@ func TestSSHConfigFunc_kexAlgos(t *testing.T) {
 	}
 }
 
+func TestConfig_withSSHCredentials(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+
+	c := &Config{
+		Type: "ssh",
+		SSH: SSH{
+			SSHPassword: "from-template",
+			SSHCredentialProviders: credentials.Chain{
+				&credentials.Static{Credentials: credentials.Credentials{
+					Username: "fetched",
+					Password: "fetched-password",
+				}},
+			},
+		},
+	}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("a credential provider should be enough without ssh_username: %#v", err)
+	}
+
+	base, err := c.SSHConfigFunc()(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	sshConfig, err := c.withSSHCredentials(context.Background(), base)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sshConfig.User != "fetched" {
+		t.Fatalf("expected the retrieved username to be used, got %q", sshConfig.User)
+	}
+	// password + keyboard-interactive for each of the retrieved and
+	// configured passwords.
+	if len(sshConfig.Auth) != 4 || len(base.Auth) != 2 {
+		t.Fatalf("unexpected auth methods: %#v", sshConfig.Auth)
+	}
+	if filtered := packersdk.LogSecretFilter.FilterString("fetched-password"); filtered != "<sensitive>" {
+		t.Fatalf("the retrieved password should be filtered from the logs, got %q", filtered)
+	}
+
+	c.SSHCredentialProviders = credentials.Chain{credentials.ProviderFunc(
+		func(context.Context) (*credentials.Credentials, error) { return nil, fmt.Errorf("boom") },
+	)}
+	if _, err := c.withSSHCredentials(context.Background(), base); err == nil {
+		t.Fatalf("expected a failing provider to be reported")
+	}
+
+	// A slow provider gives up when the step is cancelled.
+	c.SSHCredentialProviders = credentials.Chain{credentials.ProviderFunc(
+		func(ctx context.Context) (*credentials.Credentials, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.withSSHCredentials(ctx, base); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Fatalf("expected the retrieval to be cancelled, got %v", err)
+	}
+}
+
+func TestConfig_credential_url(t *testing.T) {
+	c := testConfig()
+	c.SSHCredentialURL = "file:///etc/shadow"
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected an error for an unsupported scheme, got: %#v", err)
+	}
+}
+
 func TestConfig_winrm(t *testing.T) {
 	c := &Config{
 		Type: "winrm",
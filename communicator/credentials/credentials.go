@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package credentials provides a chain of credential sources that the
+// communicators query at connect time.
+//
+// Each Provider looks for credentials in one place: the template, the
+// environment, an SSH agent, files on disk, the output of an external
+// command or a metadata endpoint. A Chain asks each of its providers in turn
+// and returns the first credentials found, so short lived credentials can be
+// fetched right before connecting instead of being written in a template.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/secret"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrNoCredentials is returned by a Provider that did not find any
+// credentials. A Chain moves on to its next provider when it gets this error.
+var ErrNoCredentials = errors.New("no credentials found")
+
+// Credentials are the secrets used to authenticate a communicator. A
+// provider only sets the fields it knows about.
+type Credentials struct {
+	Username   string
+	Password   string
+	PrivateKey []byte
+	// Signers are keys that can sign but cannot be read, for example the
+	// ones held by an SSH agent.
+	Signers []ssh.Signer
+
+	// Source is the name of the provider that returned the credentials.
+	Source string
+}
+
+// Empty reports whether c contains no usable secret. A username alone is
+// not a credential.
+func (c *Credentials) Empty() bool {
+	return c == nil || (c.Password == "" && len(c.PrivateKey) == 0 && len(c.Signers) == 0)
+}
+
+// Destroy zeroes the private key of c and forgets its password, once the
+// communicator authenticated with them. Providers return copies of their
+// credentials, which can be destroyed.
+func (c *Credentials) Destroy() {
+	if c == nil {
+		return
+	}
+	secret.New(c.PrivateKey).Destroy()
+	c.PrivateKey = nil
+	c.Password = ""
+}
+
+// String describes c without its secrets, for them not to be logged.
+func (c *Credentials) String() string {
+	if c.Username == "" {
+		return fmt.Sprintf("credentials from %s", c.Source)
+	}
+	return fmt.Sprintf("credentials of %s from %s", c.Username, c.Source)
+}
+
+// GoString is String, for %#v.
+func (c *Credentials) GoString() string {
+	return c.String()
+}
+
+// Provider retrieves credentials from a single source.
+type Provider interface {
+	// Retrieve returns the credentials of this source, or ErrNoCredentials
+	// if the source has none.
+	Retrieve(ctx context.Context) (*Credentials, error)
+}
+
+// ProviderFunc is an adapter to use an ordinary function as a Provider.
+type ProviderFunc func(ctx context.Context) (*Credentials, error)
+
+func (f ProviderFunc) Retrieve(ctx context.Context) (*Credentials, error) {
+	return f(ctx)
+}
+
+// Chain is a list of providers queried in order.
+type Chain []Provider
+
+// Retrieve returns the credentials of the first provider of the chain that
+// has some, whose password and private key are registered with
+// packersdk.LogSecretFilter so they don't leak in the logs. Providers
+// returning ErrNoCredentials are skipped; any other error stops the chain,
+// since a source that is configured but broken should not silently fall
+// back to another identity.
+func (c Chain) Retrieve(ctx context.Context) (*Credentials, error) {
+	var skipped []string
+	for _, p := range c {
+		creds, err := p.Retrieve(ctx)
+		if errors.Is(err, ErrNoCredentials) || (err == nil && creds.Empty()) {
+			skipped = append(skipped, name(p))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name(p), err)
+		}
+		if creds.Source == "" {
+			creds.Source = name(p)
+		}
+		packersdk.LogSecretFilter.Set(creds.Password)
+		if len(creds.PrivateKey) > 0 {
+			packersdk.LogSecretFilter.SetKey(creds.PrivateKey)
+		}
+		return creds, nil
+	}
+	if len(skipped) == 0 {
+		return nil, ErrNoCredentials
+	}
+	return nil, fmt.Errorf("%w, tried: %s", ErrNoCredentials, strings.Join(skipped, ", "))
+}
+
+type namer interface {
+	Name() string
+}
+
+func name(p Provider) string {
+	if n, ok := p.(namer); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", p)
+}
@@ -0,0 +1,282 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// DefaultTimeout bounds how long the Command and Metadata providers wait for
+// credentials when they have no Timeout set.
+const DefaultTimeout = 30 * time.Second
+
+// Static returns credentials set in the configuration.
+type Static struct {
+	Credentials
+}
+
+func (*Static) Name() string { return "static" }
+
+func (p *Static) Retrieve(context.Context) (*Credentials, error) {
+	if p.Credentials.Empty() {
+		return nil, ErrNoCredentials
+	}
+	creds := p.Credentials
+	// The copy is destroyed once used, not the configured key.
+	creds.PrivateKey = append([]byte(nil), p.PrivateKey...)
+	return &creds, nil
+}
+
+// Env reads credentials from the <Prefix>_USERNAME, <Prefix>_PASSWORD and
+// <Prefix>_PRIVATE_KEY environment variables.
+type Env struct {
+	Prefix string
+}
+
+func (p *Env) Name() string { return "env " + p.Prefix + "_*" }
+
+func (p *Env) Retrieve(context.Context) (*Credentials, error) {
+	creds := &Credentials{
+		Username: os.Getenv(p.Prefix + "_USERNAME"),
+		Password: os.Getenv(p.Prefix + "_PASSWORD"),
+	}
+	if key := os.Getenv(p.Prefix + "_PRIVATE_KEY"); key != "" {
+		creds.PrivateKey = []byte(key)
+	}
+	if creds.Empty() {
+		return nil, ErrNoCredentials
+	}
+	return creds, nil
+}
+
+// Agent returns the keys held by a running SSH agent.
+type Agent struct {
+	// Socket is the path of the agent socket. Defaults to SSH_AUTH_SOCK.
+	Socket string
+}
+
+func (*Agent) Name() string { return "ssh-agent" }
+
+func (p *Agent) Retrieve(ctx context.Context) (*Credentials, error) {
+	socket := p.Socket
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if socket == "" {
+		return nil, ErrNoCredentials
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to SSH agent socket %q: %s", socket, err)
+	}
+	// The connection is kept open: the signers ask the agent to sign every
+	// authentication challenge.
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot list SSH agent keys: %s", err)
+	}
+	if len(signers) == 0 {
+		conn.Close()
+		return nil, ErrNoCredentials
+	}
+	return &Credentials{Signers: signers}, nil
+}
+
+// File reads a private key and/or a password from files. A file that does
+// not exist is skipped. The `~` can be used in paths and will be expanded to
+// the home directory of current user.
+type File struct {
+	PrivateKeyFile string
+	PasswordFile   string
+}
+
+func (*File) Name() string { return "file" }
+
+func (p *File) Retrieve(context.Context) (*Credentials, error) {
+	creds := &Credentials{}
+	if p.PrivateKeyFile != "" {
+		key, err := readFile(p.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		creds.PrivateKey = key
+	}
+	if p.PasswordFile != "" {
+		password, err := readFile(p.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		creds.Password = strings.TrimRight(string(password), "\r\n")
+	}
+	if creds.Empty() {
+		return nil, ErrNoCredentials
+	}
+	return creds, nil
+}
+
+func readFile(path string) ([]byte, error) {
+	path, err := pathing.ExpandUser(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Command runs an external program and reads credentials from its standard
+// output. The output is either a JSON document as described in Document, a
+// PEM encoded private key, or a password on a single line.
+type Command struct {
+	// Command is the program to run followed by its arguments.
+	Command []string
+	Timeout time.Duration
+}
+
+func (p *Command) Name() string {
+	if len(p.Command) == 0 {
+		return "command"
+	}
+	return "command " + p.Command[0]
+}
+
+func (p *Command) Retrieve(ctx context.Context) (*Credentials, error) {
+	if len(p.Command) == 0 {
+		return nil, ErrNoCredentials
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout(p.Timeout))
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseOutput(stdout.Bytes())
+}
+
+// Metadata fetches credentials from an HTTP endpoint, such as a cloud
+// instance metadata service or a secrets broker. The response body must be a
+// JSON document as described in Document. A 404 response means that the
+// endpoint has no credentials.
+type Metadata struct {
+	URL    string
+	Header http.Header
+	// Client is the HTTP client used for the request. Defaults to
+	// http.DefaultClient.
+	Client  *http.Client
+	Timeout time.Duration
+}
+
+func (p *Metadata) Name() string { return "metadata " + p.URL }
+
+func (p *Metadata) Retrieve(ctx context.Context) (*Credentials, error) {
+	if p.URL == "" {
+		return nil, ErrNoCredentials
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout(p.Timeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.Header {
+		req.Header[k] = v
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNoCredentials
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	doc := Document{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("could not decode credentials: %s", err)
+	}
+	return doc.credentials()
+}
+
+// Document is the JSON representation of credentials printed by a Command or
+// served by a Metadata endpoint, for example:
+//
+//	{"username": "packer", "password": "s3cr3t"}
+type Document struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	PrivateKey string `json:"private_key"`
+}
+
+func (d Document) credentials() (*Credentials, error) {
+	creds := &Credentials{
+		Username: d.Username,
+		Password: d.Password,
+	}
+	if d.PrivateKey != "" {
+		creds.PrivateKey = []byte(d.PrivateKey)
+	}
+	if creds.Empty() {
+		return nil, ErrNoCredentials
+	}
+	return creds, nil
+}
+
+func parseOutput(out []byte) (*Credentials, error) {
+	trimmed := bytes.TrimSpace(out)
+	switch {
+	case len(trimmed) == 0:
+		return nil, ErrNoCredentials
+	case trimmed[0] == '{':
+		doc := Document{}
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, fmt.Errorf("could not decode credentials: %s", err)
+		}
+		return doc.credentials()
+	case bytes.HasPrefix(trimmed, []byte("-----BEGIN")):
+		return &Credentials{PrivateKey: trimmed}, nil
+	default:
+		return &Credentials{Password: string(bytes.TrimRight(out, "\r\n"))}, nil
+	}
+}
+
+func timeout(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultTimeout
+	}
+	return d
+}
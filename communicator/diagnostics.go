@@ -0,0 +1,306 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// diagnosticTimeout bounds each network operation of the diagnostics, so that
+// a report is produced quickly even when packets are dropped.
+const diagnosticTimeout = 5 * time.Second
+
+// DiagnosticCheck is the result of one of the checks of Diagnostics.
+type DiagnosticCheck struct {
+	Name string
+	// Detail describes what was found.
+	Detail string
+	// Err is set when the check failed; it tells what is likely wrong.
+	Err error
+}
+
+// Diagnostics explains why a communicator cannot connect to a machine, by
+// checking one after the other that its address resolves, that its port is
+// reachable, that the expected service answers there and that it accepts
+// the configured authentication. Builders call DiagnoseSSH or DiagnoseWinRM
+// once connecting failed, and show the report with Report.
+type Diagnostics struct {
+	// Address that was checked, in host:port form.
+	Address string
+	Checks  []DiagnosticCheck
+}
+
+// OK returns true if every check passed.
+func (d *Diagnostics) OK() bool {
+	for _, c := range d.Checks {
+		if c.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Report writes the result of the checks to ui.
+func (d *Diagnostics) Report(ui packersdk.Ui) {
+	ui.Say(fmt.Sprintf("Connection diagnostics for %s:", d.Address))
+	for _, c := range d.Checks {
+		if c.Err != nil {
+			ui.Error(fmt.Sprintf("  [failed] %s: %s", c.Name, c.Err))
+			continue
+		}
+		ui.Say(fmt.Sprintf("  [ok] %s: %s", c.Name, c.Detail))
+	}
+}
+
+func (d *Diagnostics) add(name, detail string, err error) bool {
+	d.Checks = append(d.Checks, DiagnosticCheck{Name: name, Detail: detail, Err: err})
+	return err == nil
+}
+
+// checkNetwork resolves host and connects to port, returning the connection
+// on success.
+func (d *Diagnostics) checkNetwork(ctx context.Context, host string, port int) net.Conn {
+	if net.ParseIP(host) == nil {
+		lookupCtx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+		addrs, err := net.DefaultResolver.LookupHost(lookupCtx, host)
+		cancel()
+		if err != nil {
+			err = fmt.Errorf("%q does not resolve (%s); check the host name and the DNS configuration", host, err)
+		}
+		if !d.add("DNS resolution", strings.Join(addrs, ", "), err) {
+			return nil
+		}
+	}
+
+	dialer := net.Dialer{Timeout: diagnosticTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", d.Address)
+	if err != nil {
+		_, err = explainDialError(err, port)
+	}
+	if !d.add("TCP connection", "port is open", err) {
+		return nil
+	}
+	return conn
+}
+
+// explainDialError tells whether the port refused the connection or dropped
+// it, for err, the error of a connection to port, and what is likely wrong.
+func explainDialError(err error, port int) (PortState, error) {
+	var opErr *net.OpError
+	switch {
+	case strings.Contains(err.Error(), "connection refused"):
+		return PortClosed, fmt.Errorf("connection refused: nothing listens on port %d yet, "+
+			"the service may not be installed or started", port)
+	case errors.As(err, &opErr) && opErr.Timeout():
+		return PortFiltered, fmt.Errorf("no answer after %s: a firewall, security group or "+
+			"route is likely dropping traffic to port %d", diagnosticTimeout, port)
+	}
+	return PortFiltered, err
+}
+
+// DiagnoseSSH checks the connection path to the SSH server configured in c,
+// at host and port. When a bastion host is configured, the bastion is checked
+// instead since Packer never connects to the machine directly, the first one
+// with bastion hops.
+func DiagnoseSSH(ctx context.Context, c *Config, host string, port int) *Diagnostics {
+	if c.SSHBastionHost != "" {
+		host, port = c.SSHBastionHost, c.SSHBastionPort
+	} else if len(c.SSHBastionHops) > 0 {
+		host, port = c.SSHBastionHops[0].Host, c.SSHBastionHops[0].Port
+	}
+	d := &Diagnostics{Address: net.JoinHostPort(host, strconv.Itoa(port))}
+	conn := d.checkNetwork(ctx, host, port)
+	if conn == nil {
+		return d
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(diagnosticTimeout))
+	banner, err := bufio.NewReader(conn).ReadString('\n')
+	banner = strings.TrimSpace(banner)
+	switch {
+	case err != nil && banner == "":
+		err = fmt.Errorf("the server did not send an SSH banner (%s); "+
+			"the machine may still be booting or something else listens on this port", err)
+	case !strings.HasPrefix(banner, "SSH-"):
+		err = fmt.Errorf("expected an SSH banner, got %q; check ssh_port", banner)
+	default:
+		err = nil
+	}
+	if !d.add("SSH banner", banner, err) {
+		return d
+	}
+	conn.Close()
+
+	offered, err := sshOfferedAuthMethods(ctx, d.Address, c.SSHUsername)
+	if err != nil {
+		d.add("SSH authentication methods", "", fmt.Errorf("could not list them: %s", err))
+		return d
+	}
+	configured := sshConfiguredAuthMethods(c)
+	err = nil
+	if len(configured) > 0 && !intersects(offered, configured) {
+		err = fmt.Errorf("the server offers %s but Packer is configured for %s",
+			strings.Join(offered, ", "), strings.Join(configured, ", "))
+	}
+	d.add("SSH authentication methods", "server offers "+strings.Join(offered, ", "), err)
+	return d
+}
+
+// sshOfferedAuthMethods returns the authentication methods the server offers
+// to user. x/crypto only calls the callback of a method the server offers,
+// and none of them hands out a credential. Failing the password or keyboard
+// interactive callback ends the handshake, so only the first of those two
+// offered is reported; either satisfies a password configuration.
+func sshOfferedAuthMethods(ctx context.Context, address, user string) ([]string, error) {
+	seen := map[string]bool{}
+	errProbe := errors.New("probing")
+	config := &gossh.ClientConfig{
+		User:            user,
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         diagnosticTimeout,
+		Auth: []gossh.AuthMethod{
+			gossh.PublicKeysCallback(func() ([]gossh.Signer, error) {
+				seen["publickey"] = true
+				return nil, nil
+			}),
+			gossh.PasswordCallback(func() (string, error) {
+				seen["password"] = true
+				return "", errProbe
+			}),
+			gossh.KeyboardInteractive(func(string, string, []string, []bool) ([]string, error) {
+				seen["keyboard-interactive"] = true
+				return nil, errProbe
+			}),
+		},
+	}
+
+	dialer := net.Dialer{Timeout: diagnosticTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(diagnosticTimeout))
+	sshConn, _, _, err := gossh.NewClientConn(conn, address, config)
+	if err == nil {
+		// The server accepts anyone.
+		sshConn.Close()
+		return []string{"none"}, nil
+	}
+	if len(seen) == 0 && !strings.Contains(err.Error(), "unable to authenticate") {
+		return nil, err
+	}
+
+	var offered []string
+	for method := range seen {
+		offered = append(offered, method)
+	}
+	sort.Strings(offered)
+	return offered, nil
+}
+
+func sshConfiguredAuthMethods(c *Config) []string {
+	var methods []string
+	// Credentials retrieved at connection time can be of either kind.
+	dynamic := c.SSHCredentialCommand != nil || c.SSHCredentialURL != "" || len(c.SSHCredentialProviders) > 0
+	if c.SSHPrivateKeyFile != "" || len(c.SSHPrivateKey) > 0 || c.SSHAgentAuth || dynamic {
+		methods = append(methods, "publickey")
+	}
+	if c.SSHPassword != "" || dynamic {
+		methods = append(methods, "password", "keyboard-interactive")
+	}
+	return methods
+}
+
+// DiagnoseWinRM checks the connection path to the WinRM service configured in
+// c, at host and port.
+func DiagnoseWinRM(ctx context.Context, c *Config, host string, port int) *Diagnostics {
+	d := &Diagnostics{Address: net.JoinHostPort(host, strconv.Itoa(port))}
+	conn := d.checkNetwork(ctx, host, port)
+	if conn == nil {
+		return d
+	}
+	conn.Close()
+
+	scheme := "http"
+	if c.WinRMUseSSL {
+		scheme = "https"
+	}
+	client := &http.Client{
+		Timeout: diagnosticTimeout,
+		Transport: &http.Transport{
+			// Only the reachability of the service is checked here.
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, scheme+"://"+d.Address+"/wsman", nil)
+	if err != nil {
+		d.add("WinRM endpoint", "", err)
+		return d
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if c.WinRMUseSSL {
+			err = fmt.Errorf("%s; winrm_use_ssl is set, check that the listener uses HTTPS", err)
+		} else {
+			err = fmt.Errorf("%s; winrm_use_ssl is not set, check that the listener uses HTTP", err)
+		}
+		d.add("WinRM endpoint", "", err)
+		return d
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		err = fmt.Errorf("%s has no /wsman endpoint; check winrm_port", d.Address)
+	}
+	if !d.add("WinRM endpoint", scheme+" "+resp.Status, err) {
+		return d
+	}
+
+	var offered []string
+	for _, h := range resp.Header.Values("WWW-Authenticate") {
+		if fields := strings.Fields(h); len(fields) > 0 {
+			offered = append(offered, fields[0])
+		}
+	}
+	if len(offered) == 0 {
+		return d
+	}
+	configured := []string{"Basic"}
+	if c.WinRMUseNTLM {
+		configured = []string{"Negotiate", "NTLM"}
+	}
+	err = nil
+	if !intersects(offered, configured) {
+		err = fmt.Errorf("the service offers %s but Packer is configured for %s; "+
+			"check winrm_use_ntlm and the WinRM Auth settings of the machine",
+			strings.Join(offered, ", "), configured[0])
+	}
+	d.add("WinRM authentication schemes", "service offers "+strings.Join(offered, ", "), err)
+	return d
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
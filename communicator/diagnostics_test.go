@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func listenerHostPort(t *testing.T, l net.Listener) (string, int) {
+	host, portStr, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
+}
+
+// testPasswordSSHServer accepts SSH connections that only offer password
+// authentication, and rejects every password.
+func testPasswordSSHServer(t *testing.T) net.Listener {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config := &gossh.ServerConfig{
+		PasswordCallback: func(gossh.ConnMetadata, []byte) (*gossh.Permissions, error) {
+			t.Error("the diagnostics should never send a password")
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _, _, _ = gossh.NewServerConn(conn, config)
+			}()
+		}
+	}()
+	return l
+}
+
+func TestDiagnoseSSH(t *testing.T) {
+	l := testPasswordSSHServer(t)
+	defer l.Close()
+	host, port := listenerHostPort(t, l)
+
+	// Only a private key is configured.
+	c := &Config{SSH: SSH{SSHUsername: "packer", SSHPrivateKeyFile: "id_ed25519"}}
+	d := DiagnoseSSH(context.Background(), c, host, port)
+	if d.OK() || len(d.Checks) != 3 {
+		t.Fatalf("expected the authentication check to fail, got: %#v", d.Checks)
+	}
+	if err := d.Checks[2].Err; err == nil || !strings.Contains(err.Error(), "offers password but Packer is configured for publickey") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(d.Checks[1].Detail, "SSH-2.0-") {
+		t.Fatalf("unexpected banner: %q", d.Checks[1].Detail)
+	}
+
+	c.SSHPassword = "packer"
+	d = DiagnoseSSH(context.Background(), c, host, port)
+	if !d.OK() {
+		t.Fatalf("expected everything to pass, got: %#v", d.Checks)
+	}
+}
+
+func TestDiagnoseSSH_notSSH(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.Write([]byte("220 smtp.example.com ESMTP\r\n"))
+			conn.Close()
+		}
+	}()
+	defer l.Close()
+	host, port := listenerHostPort(t, l)
+
+	d := DiagnoseSSH(context.Background(), &Config{}, host, port)
+	if err := d.Checks[len(d.Checks)-1].Err; err == nil || !strings.Contains(err.Error(), "expected an SSH banner") {
+		t.Fatalf("unexpected checks: %#v", d.Checks)
+	}
+}
+
+func TestDiagnose_connectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	host, port := listenerHostPort(t, l)
+	l.Close()
+
+	d := DiagnoseWinRM(context.Background(), &Config{}, host, port)
+	if len(d.Checks) != 1 || d.Checks[0].Err == nil || !strings.Contains(d.Checks[0].Err.Error(), "nothing listens on port") {
+		t.Fatalf("unexpected checks: %#v", d.Checks)
+	}
+}
+
+func TestDiagnoseWinRM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/wsman" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Add("WWW-Authenticate", "Negotiate")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+
+	d := DiagnoseWinRM(context.Background(), &Config{}, host, port)
+	if err := d.Checks[len(d.Checks)-1].Err; err == nil || !strings.Contains(err.Error(), "offers Negotiate but Packer is configured for Basic") {
+		t.Fatalf("unexpected checks: %#v", d.Checks)
+	}
+
+	d = DiagnoseWinRM(context.Background(), &Config{WinRM: WinRM{WinRMUseNTLM: true}}, host, port)
+	if !d.OK() {
+		t.Fatalf("expected everything to pass, got: %#v", d.Checks)
+	}
+}
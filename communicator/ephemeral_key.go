@@ -0,0 +1,131 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// EphemeralSSHKey is an SSH key pair that only lives for the duration of a
+// build. The private key is registered with the log secret filter as soon as
+// it is generated, and Destroy overwrites every copy Packer made of it, in
+// memory and on disk. Nothing is overwritten when Destroy is not called,
+// like when the process is killed, or when the build is aborted with
+// `-on-error=abort` and the steps are not cleaned up: the key files are then
+// left in the temporary directory, readable by their owner only.
+//
+// Builders get one from StepSSHKeyGen through the "ephemeral_ssh_key" state
+// key, and inject AuthorizedKey into the instance metadata, user data or
+// cloud-init of the machine they create.
+type EphemeralSSHKey struct {
+	sshkey.Pair
+	Algorithm sshkey.Algorithm
+
+	mu        sync.Mutex
+	files     []string
+	destroyed bool
+}
+
+// NewEphemeralSSHKey generates a key pair of the given algorithm. bits may be
+// zero to use the algorithm's default size.
+func NewEphemeralSSHKey(algorithm sshkey.Algorithm, bits int) (*EphemeralSSHKey, error) {
+	pair, err := sshkey.GeneratePair(algorithm, nil, bits)
+	if err != nil {
+		return nil, err
+	}
+	k := &EphemeralSSHKey{
+		Pair:      *pair,
+		Algorithm: algorithm,
+	}
+
+	packersdk.LogSecretFilter.SetKey(k.Private)
+
+	return k, nil
+}
+
+// AuthorizedKey returns the public key in the single line authorized_keys
+// format, followed by comment if it is not empty.
+func (k *EphemeralSSHKey) AuthorizedKey(comment string) string {
+	line := string(bytes.TrimSpace(k.Public))
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// WriteFile writes the private key to a new file only readable by the
+// current user, for tools that need a key file rather than the key itself,
+// and returns its path. The file is removed by Destroy.
+func (k *EphemeralSSHKey) WriteFile() (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.destroyed {
+		return "", errors.New("ephemeral SSH key was destroyed")
+	}
+
+	f, err := tmp.File("packer-ssh-key")
+	if err != nil {
+		return "", err
+	}
+	k.files = append(k.files, f.Name())
+	defer f.Close()
+
+	if err := f.Chmod(0600); err != nil {
+		return "", err
+	}
+	if _, err := f.Write(k.Private); err != nil {
+		return "", fmt.Errorf("Error writing ephemeral SSH key: %s", err)
+	}
+	return f.Name(), nil
+}
+
+// Destroy zeroes the private key in memory, and overwrites then removes the
+// files written by WriteFile. It is safe to call Destroy more than once.
+func (k *EphemeralSSHKey) Destroy() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.destroyed {
+		return nil
+	}
+	k.destroyed = true
+
+	var errs []error
+	for _, path := range k.files {
+		if err := shred(path, len(k.Private)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	k.files = nil
+	for i := range k.Private {
+		k.Private[i] = 0
+	}
+	return errors.Join(errs...)
+}
+
+func shred(path string, size int) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, werr := f.Write(make([]byte, size))
+	if werr == nil {
+		werr = f.Sync()
+	}
+	f.Close()
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return werr
+}
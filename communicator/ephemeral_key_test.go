@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEphemeralSSHKey(t *testing.T) {
+	k, err := NewEphemeralSSHKey(sshkey.ED25519, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := ssh.ParsePrivateKey(k.Private); err != nil {
+		t.Fatalf("unparseable private key: %s", err)
+	}
+	line := k.AuthorizedKey("packer")
+	if !strings.HasPrefix(line, "ssh-ed25519 ") || !strings.HasSuffix(line, " packer") {
+		t.Fatalf("unexpected authorized key: %q", line)
+	}
+
+	body := strings.Split(string(k.Private), "\n")[1]
+	if filtered := packersdk.LogSecretFilter.FilterString("key: " + body); strings.Contains(filtered, body) {
+		t.Fatalf("private key was not registered as a secret: %q", filtered)
+	}
+
+	path, err := k.WriteFile()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b, _ := os.ReadFile(path); !bytes.Equal(b, k.Private) {
+		t.Fatalf("key file doesn't contain the private key")
+	}
+
+	if err := k.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("key file should have been removed: %v", err)
+	}
+	if !bytes.Equal(k.Private, make([]byte, len(k.Private))) {
+		t.Fatalf("private key should have been zeroed")
+	}
+	if err := k.Destroy(); err != nil {
+		t.Fatalf("second Destroy should be a no-op: %s", err)
+	}
+	if _, err := k.WriteFile(); err == nil {
+		t.Fatalf("expected WriteFile to fail after Destroy")
+	}
+}
+
+func TestStepSSHKeyGen_Cleanup(t *testing.T) {
+	state := testState(t)
+	comm := &Config{Type: "ssh"}
+	step := &StepSSHKeyGen{CommConf: comm}
+	step.SSHTemporaryKeyPairType = "ed25519"
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, err := ssh.ParsePrivateKey(comm.SSHPrivateKey); err != nil {
+		t.Fatalf("the config should hold the private key: %s", err)
+	}
+
+	step.Cleanup(state)
+	if comm.SSHPrivateKey != nil {
+		t.Fatalf("the destroyed private key should be unset, got %q", comm.SSHPrivateKey)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PortState is what a probe of the port of a communicator found.
+type PortState string
+
+const (
+	// PortOpen is a port where the expected service answers, or where
+	// nothing tells it is not the expected service.
+	PortOpen PortState = "open"
+	// PortClosed is a port refusing connections: the machine is up but
+	// nothing listens there.
+	PortClosed PortState = "closed"
+	// PortFiltered is a port not answering: the machine is down or traffic
+	// to it is dropped.
+	PortFiltered PortState = "filtered"
+	// PortUnresolved is a host name that does not resolve.
+	PortUnresolved PortState = "unresolved"
+	// PortWrongService is a port where another service than the one of the
+	// communicator answers.
+	PortWrongService PortState = "wrong-service"
+)
+
+// preflightBannerTimeout is how long a probe waits for the service to
+// identify itself. Services slow to do so are not reported.
+const preflightBannerTimeout = 2 * time.Second
+
+// PreflightResult is the result of Preflight.
+type PreflightResult struct {
+	// Address that was probed, in host:port form.
+	Address string
+	State   PortState
+	// Banner is what the service sent first, if anything.
+	Banner string
+	// Err tells what is likely wrong, nil when the port is open.
+	Err error
+}
+
+// Preflight probes the port of the communicator configured in c once, at
+// host and port, for what would make waiting for the communicator hang
+// until it times out: a host that doesn't resolve, a port closed or
+// filtered, or another service answering there, like a WinRM listener on the
+// SSH port. Unlike the Diagnostics, it doesn't authenticate, and it returns
+// nil when the machine is not connected to directly, through a bastion or a
+// proxy.
+func Preflight(ctx context.Context, c *Config, host string, port int) *PreflightResult {
+	switch c.Type {
+	case "ssh":
+		if c.SSHBastionHost != "" || len(c.SSHBastionHops) > 0 || c.SSHProxyHost != "" || c.SSHTransportURL != "" {
+			return nil
+		}
+	case "winrm":
+		if c.WinRMProxy != "" {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	r := &PreflightResult{Address: net.JoinHostPort(host, strconv.Itoa(port))}
+	if net.ParseIP(host) == nil {
+		lookupCtx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+		_, err := net.DefaultResolver.LookupHost(lookupCtx, host)
+		cancel()
+		if err != nil {
+			r.State = PortUnresolved
+			r.Err = fmt.Errorf("%q does not resolve (%s); check the host name and the DNS configuration", host, err)
+			return r
+		}
+	}
+
+	dialer := net.Dialer{Timeout: diagnosticTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.Address)
+	if err != nil {
+		r.State, r.Err = explainDialError(err, port)
+		return r
+	}
+	defer conn.Close()
+
+	r.State = PortOpen
+	_ = conn.SetDeadline(time.Now().Add(preflightBannerTimeout))
+	if c.Type == "ssh" {
+		// SSH clients send their version first too, which HTTP servers,
+		// like WinRM, answer with an error.
+		_, _ = conn.Write([]byte("SSH-2.0-packer-preflight\r\n"))
+	}
+	banner, _ := bufio.NewReader(conn).ReadString('\n')
+	r.Banner = strings.TrimSpace(banner)
+	if r.Banner == "" {
+		return r
+	}
+
+	isSSH := strings.HasPrefix(r.Banner, "SSH-")
+	switch {
+	case c.Type == "ssh" && !isSSH && strings.HasPrefix(r.Banner, "HTTP/"):
+		r.State = PortWrongService
+		r.Err = fmt.Errorf("an HTTP server answers on port %d, not SSH; "+
+			"check ssh_port, or whether the machine is set up for the WinRM communicator", port)
+	case c.Type == "ssh" && !isSSH:
+		r.State = PortWrongService
+		r.Err = fmt.Errorf("expected an SSH banner on port %d, got %q; check ssh_port", port, r.Banner)
+	case c.Type == "winrm" && isSSH:
+		r.State = PortWrongService
+		r.Err = fmt.Errorf("an SSH server answers on port %d, not WinRM; "+
+			"check winrm_port, or whether the machine is set up for the SSH communicator", port)
+	}
+	return r
+}
+
+// PreflightPolicy tells how StepConnect probes the port of the communicator
+// with Preflight before waiting for it. Another service answering on the
+// port, or a host name that doesn't resolve, fails the build right away.
+type PreflightPolicy struct {
+	// Window is how long a port closed or filtered is probed again, while
+	// the machine boots, before it is reported; 30 seconds when unset.
+	Window time.Duration
+	// Interval is the time between the probes, 2 seconds when unset.
+	Interval time.Duration
+	// FailUnreachable fails the build when the port is still closed or
+	// filtered after Window, instead of only warning and waiting for the
+	// communicator until it times out. Set it when the machine is
+	// expected to be reachable by the time StepConnect runs.
+	FailUnreachable bool
+}
+
+// run probes the port until it is open or the probes are conclusive, and
+// returns the last result, nil when the port is not probed.
+func (p *PreflightPolicy) run(ctx context.Context, c *Config, host string, port int) *PreflightResult {
+	window, interval := p.Window, p.Interval
+	if window <= 0 {
+		window = 30 * time.Second
+	}
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	deadline := time.Now().Add(window)
+	for {
+		r := Preflight(ctx, c, host, port)
+		if r == nil || r.State == PortOpen || r.State == PortWrongService || !time.Now().Before(deadline) {
+			return r
+		}
+		select {
+		case <-ctx.Done():
+			return r
+		case <-time.After(interval):
+		}
+	}
+}
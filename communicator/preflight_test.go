@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// testBannerServer listens on a local port and greets every connection with
+// banner, returning the host and port.
+func testBannerServer(t *testing.T, banner string) (string, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = conn.Write([]byte(banner))
+				_, _ = bufio.NewReader(conn).ReadString('\n')
+			}()
+		}
+	}()
+	return testSplitAddr(t, l.Addr().String())
+}
+
+func testSplitAddr(t *testing.T, addr string) (string, int) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	port, _ := strconv.Atoi(p)
+	return host, port
+}
+
+func TestPreflight_ssh(t *testing.T) {
+	host, port := testBannerServer(t, "SSH-2.0-OpenSSH_9.6\r\n")
+	r := Preflight(context.Background(), &Config{Type: "ssh"}, host, port)
+	if r.State != PortOpen || r.Err != nil || r.Banner != "SSH-2.0-OpenSSH_9.6" {
+		t.Fatalf("bad result: %#v", r)
+	}
+
+	// An SSH server on the WinRM port.
+	r = Preflight(context.Background(), &Config{Type: "winrm"}, host, port)
+	if r.State != PortWrongService || !strings.Contains(r.Err.Error(), "winrm_port") {
+		t.Fatalf("bad result: %#v", r)
+	}
+}
+
+func TestPreflight_wrongService(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	host, port := testSplitAddr(t, srv.Listener.Addr().String())
+
+	r := Preflight(context.Background(), &Config{Type: "ssh"}, host, port)
+	if r.State != PortWrongService || !strings.Contains(r.Err.Error(), "ssh_port") {
+		t.Fatalf("bad result: %#v", r)
+	}
+
+	// WinRM is served over HTTP, which doesn't speak first.
+	r = Preflight(context.Background(), &Config{Type: "winrm"}, host, port)
+	if r.State != PortOpen {
+		t.Fatalf("bad result: %#v", r)
+	}
+}
+
+func TestPreflight_closed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	host, port := testSplitAddr(t, l.Addr().String())
+	l.Close()
+
+	r := Preflight(context.Background(), &Config{Type: "ssh"}, host, port)
+	if r.State != PortClosed || !strings.Contains(r.Err.Error(), "connection refused") {
+		t.Fatalf("bad result: %#v", r)
+	}
+}
+
+func TestPreflight_skipped(t *testing.T) {
+	for _, c := range []*Config{
+		{Type: "ssh", SSH: SSH{SSHBastionHost: "bastion.example.com"}},
+		{Type: "ssh", SSH: SSH{SSHProxyHost: "proxy.example.com"}},
+		{Type: "winrm", WinRM: WinRM{WinRMProxy: "http://proxy.example.com"}},
+		{Type: "none"},
+	} {
+		if r := Preflight(context.Background(), c, "127.0.0.1", 1); r != nil {
+			t.Fatalf("%s should not be probed, got %#v", c.Type, r)
+		}
+	}
+}
+
+func TestStepConnect_preflight(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	host, port := testSplitAddr(t, srv.Listener.Addr().String())
+
+	state := testState(t)
+	step := &StepConnect{
+		Config:    &Config{Type: "ssh"},
+		Host:      func(multistep.StateBag) (string, error) { return host, nil },
+		SSHPort:   func(multistep.StateBag) (int, error) { return port, nil },
+		Preflight: &PreflightPolicy{},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err, _ := state.Get("error").(error); err == nil || !strings.Contains(err.Error(), "ssh_port") {
+		t.Fatalf("bad error: %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, port = testSplitAddr(t, l.Addr().String())
+	l.Close()
+
+	state = testState(t)
+	step.Preflight = &PreflightPolicy{
+		Window:          50 * time.Millisecond,
+		Interval:        10 * time.Millisecond,
+		FailUnreachable: true,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err, _ := state.Get("error").(error); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
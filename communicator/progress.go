@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// UploadWithProgress uploads r to path like comm.Upload, and reports the
+// progress of the upload to ui. size is the number of bytes of r; when it is
+// 0 the size of fi is used, if set.
+func UploadWithProgress(ui packersdk.Ui, comm packersdk.Communicator, path string, r io.Reader, size int64, fi *os.FileInfo) error {
+	if size == 0 && fi != nil && *fi != nil {
+		size = (*fi).Size()
+	}
+	t := packersdk.NewProgressTracker(ui)
+	t.Start(fmt.Sprintf("Uploading %s", path), size)
+	defer t.Finish()
+	return comm.Upload(path, packersdk.ProgressReader(t, r), fi)
+}
+
+// DownloadWithProgress downloads path to w like comm.Download, and reports
+// the progress of the download to ui. size is the expected size of the file,
+// 0 when it isn't known.
+func DownloadWithProgress(ui packersdk.Ui, comm packersdk.Communicator, path string, w io.Writer, size int64) error {
+	t := packersdk.NewProgressTracker(ui)
+	t.Start(fmt.Sprintf("Downloading %s", path), size)
+	defer t.Finish()
+	return comm.Download(path, packersdk.ProgressWriter(t, w))
+}
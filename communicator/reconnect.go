@@ -0,0 +1,331 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+// ReconnectPolicy tells a ReconnectingCommunicator how to connect again once
+// the connection to the guest was lost, for example because it rebooted.
+type ReconnectPolicy struct {
+	// Tries is the maximum number of times to try to connect again each time
+	// the connection is lost. Defaults to 5.
+	Tries int
+	// Delay to wait between tries, doubled after each failure up to
+	// MaxDelay. Default to 5 seconds and 1 minute.
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// HealthCheckInterval is how often the health check of the connection
+	// runs, see ReconnectingCommunicator.HealthCheck. Defaults to 10
+	// seconds.
+	HealthCheckInterval time.Duration
+}
+
+// ReconnectingCommunicator is a Communicator connecting again to the guest,
+// authentication included, when an operation fails because the connection
+// was lost, and trying the operation again when that is safe: starting a
+// command, uploading from a seekable reader, downloading when nothing was
+// written yet, and transferring directories. A command that was running when
+// the connection was lost is not started again, its exit status is
+// CmdDisconnect; guestexec.RunWithResetRetry can run it again, with Reconnect
+// as its ResetPolicy.Reconnect.
+type ReconnectingCommunicator struct {
+	// Ui, if set, is told about reconnections.
+	Ui packersdk.Ui
+
+	policy  ReconnectPolicy
+	connect func(context.Context) (packersdk.Communicator, error)
+
+	lock   sync.Mutex
+	comm   packersdk.Communicator
+	gen    int
+	broken bool
+}
+
+var _ packersdk.Communicator = new(ReconnectingCommunicator)
+var _ packersdk.CommunicatorWithContext = new(ReconnectingCommunicator)
+
+// NewReconnectingCommunicator returns a ReconnectingCommunicator using comm
+// until the connection is lost, and connect to get a new one.
+func NewReconnectingCommunicator(comm packersdk.Communicator, connect func(context.Context) (packersdk.Communicator, error), policy ReconnectPolicy) *ReconnectingCommunicator {
+	if policy.Tries <= 0 {
+		policy.Tries = 5
+	}
+	if policy.Delay <= 0 {
+		policy.Delay = 5 * time.Second
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = time.Minute
+	}
+	if policy.HealthCheckInterval <= 0 {
+		policy.HealthCheckInterval = 10 * time.Second
+	}
+	return &ReconnectingCommunicator{policy: policy, connect: connect, comm: comm}
+}
+
+// IsDisconnect reports whether err tells that the connection to the guest
+// was lost, as opposed to the operation failing on the guest.
+func IsDisconnect(err error) bool {
+	if err == nil {
+		return false
+	}
+	if guestexec.IsConnectionReset(err, 0) || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// Errors coming from plugins lost their type on the way.
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"eof",
+		"connection refused",
+		"use of closed network connection",
+		"i/o timeout",
+		"no route to host",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// current returns the connection to use and its generation, connecting again
+// first if it is known to be lost.
+func (c *ReconnectingCommunicator) current(ctx context.Context) (packersdk.Communicator, int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.broken {
+		if err := c.reconnectLocked(ctx); err != nil {
+			return nil, c.gen, err
+		}
+	}
+	return c.comm, c.gen, nil
+}
+
+// markBroken records that the connection of generation gen was lost, for the
+// next operation to connect again.
+func (c *ReconnectingCommunicator) markBroken(gen int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.gen == gen {
+		c.broken = true
+	}
+}
+
+// Reconnect connects to the guest again, trying up to the number of tries of
+// the policy.
+func (c *ReconnectingCommunicator) Reconnect(ctx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.reconnectLocked(ctx)
+}
+
+func (c *ReconnectingCommunicator) reconnectLocked(ctx context.Context) error {
+	backoff := &retry.Backoff{InitialBackoff: c.policy.Delay, MaxBackoff: c.policy.MaxDelay, Multiplier: 2}
+	try := 0
+	err := retry.Config{
+		Tries:      c.policy.Tries,
+		RetryDelay: backoff.Linear,
+	}.Run(ctx, func(ctx context.Context) error {
+		try++
+		log.Printf("[INFO] Connecting to the guest again, try %d/%d", try, c.policy.Tries)
+		if c.Ui != nil {
+			c.Ui.Say(fmt.Sprintf("Connection to the guest lost, connecting again (%d/%d)...", try, c.policy.Tries))
+		}
+		comm, err := c.connect(ctx)
+		if err != nil {
+			log.Printf("[WARN] Connecting to the guest again failed: %s", err)
+			return err
+		}
+		c.comm = comm
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not connect to the guest again: %w", err)
+	}
+	c.gen++
+	c.broken = false
+	if c.Ui != nil {
+		c.Ui.Say("Connected to the guest again!")
+	}
+	return nil
+}
+
+// HealthCheck runs check at the interval of the policy until stop is called,
+// and makes the next operation connect again when it fails, rather than fail
+// itself on a dead connection.
+func (c *ReconnectingCommunicator) HealthCheck(check func(context.Context) error) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.policy.HealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			c.lock.Lock()
+			gen, broken := c.gen, c.broken
+			c.lock.Unlock()
+			if broken {
+				continue
+			}
+			checkCtx, checkCancel := context.WithTimeout(ctx, c.policy.HealthCheckInterval)
+			err := check(checkCtx)
+			checkCancel()
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[WARN] Health check of the connection to the guest failed: %s", err)
+				c.markBroken(gen)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// TCPHealthCheck returns a health check dialing address, which fails once the
+// guest is gone.
+func TCPHealthCheck(address string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// do runs op with the current connection, connecting again and running it
+// again, if retryable, when the connection was lost.
+func (c *ReconnectingCommunicator) do(ctx context.Context, retryable func() bool, op func(packersdk.Communicator) error) error {
+	comm, gen, err := c.current(ctx)
+	if err != nil {
+		return err
+	}
+	err = op(comm)
+	if !IsDisconnect(err) {
+		return err
+	}
+	c.markBroken(gen)
+	if !retryable() {
+		return err
+	}
+	log.Printf("[INFO] Lost the connection to the guest: %s", err)
+	comm, _, cerr := c.current(ctx)
+	if cerr != nil {
+		return fmt.Errorf("%s; %s", err, cerr)
+	}
+	return op(comm)
+}
+
+func always() bool { return true }
+
+func (c *ReconnectingCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	var gen int
+	err := c.do(ctx, always, func(comm packersdk.Communicator) error {
+		c.lock.Lock()
+		gen = c.gen
+		c.lock.Unlock()
+		return comm.Start(ctx, cmd)
+	})
+	if err == nil {
+		go func() {
+			if cmd.Wait() == packersdk.CmdDisconnect {
+				c.markBroken(gen)
+			}
+		}()
+	}
+	return err
+}
+
+func (c *ReconnectingCommunicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	return c.UploadContext(context.Background(), dst, r, fi)
+}
+
+func (c *ReconnectingCommunicator) UploadContext(ctx context.Context, dst string, r io.Reader, fi *os.FileInfo) error {
+	seeker, seekable := r.(io.Seeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+	retryable := func() bool {
+		if !seekable {
+			return false
+		}
+		_, err := seeker.Seek(start, io.SeekStart)
+		return err == nil
+	}
+	return c.do(ctx, retryable, func(comm packersdk.Communicator) error {
+		return packersdk.UploadContext(ctx, comm, dst, r, fi)
+	})
+}
+
+func (c *ReconnectingCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	return c.UploadDirContext(context.Background(), dst, src, exclude)
+}
+
+func (c *ReconnectingCommunicator) UploadDirContext(ctx context.Context, dst string, src string, exclude []string) error {
+	return c.do(ctx, always, func(comm packersdk.Communicator) error {
+		return packersdk.UploadDirContext(ctx, comm, dst, src, exclude)
+	})
+}
+
+// countingWriter counts what was written to it.
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (c *ReconnectingCommunicator) Download(src string, w io.Writer) error {
+	return c.DownloadContext(context.Background(), src, w)
+}
+
+func (c *ReconnectingCommunicator) DownloadContext(ctx context.Context, src string, w io.Writer) error {
+	cw := &countingWriter{Writer: w}
+	return c.do(ctx, func() bool { return cw.n == 0 }, func(comm packersdk.Communicator) error {
+		return packersdk.DownloadContext(ctx, comm, src, cw)
+	})
+}
+
+func (c *ReconnectingCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	return c.DownloadDirContext(context.Background(), src, dst, exclude)
+}
+
+func (c *ReconnectingCommunicator) DownloadDirContext(ctx context.Context, src string, dst string, exclude []string) error {
+	return c.do(ctx, always, func(comm packersdk.Communicator) error {
+		return packersdk.DownloadDirContext(ctx, comm, src, dst, exclude)
+	})
+}
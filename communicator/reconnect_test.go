@@ -0,0 +1,207 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// droppingCommunicator is a MockCommunicator whose uploads fail with io.EOF
+// once dead is set, as when the guest rebooted.
+type droppingCommunicator struct {
+	packersdk.MockCommunicator
+	dead bool
+}
+
+func (c *droppingCommunicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	if c.dead {
+		io.Copy(io.Discard, r)
+		return io.EOF
+	}
+	return c.MockCommunicator.Upload(path, r, fi)
+}
+
+func testReconnecting(t *testing.T, first packersdk.Communicator) (*ReconnectingCommunicator, *int32) {
+	var connects int32
+	rc := NewReconnectingCommunicator(first, func(context.Context) (packersdk.Communicator, error) {
+		if atomic.AddInt32(&connects, 1) == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return new(droppingCommunicator), nil
+	}, ReconnectPolicy{Tries: 3, Delay: time.Millisecond, HealthCheckInterval: time.Millisecond})
+	return rc, &connects
+}
+
+func TestReconnectingCommunicator_upload(t *testing.T) {
+	rc, connects := testReconnecting(t, &droppingCommunicator{dead: true})
+
+	if err := rc.Upload("/tmp/file", strings.NewReader("content"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if *connects != 2 {
+		t.Fatalf("bad connects: %d", *connects)
+	}
+	comm := rc.comm.(*droppingCommunicator)
+	if comm.UploadData != "content" {
+		t.Fatalf("the upload should be retried from the start: %q", comm.UploadData)
+	}
+}
+
+func TestReconnectingCommunicator_notRetryable(t *testing.T) {
+	rc, connects := testReconnecting(t, &droppingCommunicator{dead: true})
+
+	// A reader that can't be rewound is not uploaded again, but the next
+	// operation reconnects.
+	err := rc.Upload("/tmp/file", io.MultiReader(strings.NewReader("content")), nil)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("bad error: %v", err)
+	}
+	if *connects != 0 {
+		t.Fatalf("bad connects: %d", *connects)
+	}
+	if err := rc.UploadDir("/tmp", "dir", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if *connects != 2 {
+		t.Fatalf("bad connects: %d", *connects)
+	}
+}
+
+func TestReconnectingCommunicator_triesExhausted(t *testing.T) {
+	rc := NewReconnectingCommunicator(&droppingCommunicator{dead: true}, func(context.Context) (packersdk.Communicator, error) {
+		return nil, errors.New("connection refused")
+	}, ReconnectPolicy{Tries: 2, Delay: time.Millisecond})
+
+	err := rc.Upload("/tmp/file", strings.NewReader("content"), nil)
+	if err == nil || !strings.Contains(err.Error(), "could not connect to the guest again") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestReconnectingCommunicator_healthCheck(t *testing.T) {
+	first := new(droppingCommunicator)
+	rc, connects := testReconnecting(t, first)
+
+	var checks int32
+	stop := rc.HealthCheck(func(context.Context) error {
+		if atomic.AddInt32(&checks, 1) >= 2 {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rc.lock.Lock()
+		broken := rc.broken
+		rc.lock.Unlock()
+		if broken {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the failed health check should mark the connection as lost")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+
+	cmd := &packersdk.RemoteCmd{Command: "true"}
+	if err := rc.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+	if first.StartCalled || *connects != 2 {
+		t.Fatalf("the command should run on a new connection, connects: %d", *connects)
+	}
+}
+
+func TestTCPHealthCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	check := TCPHealthCheck(l.Addr().String())
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	l.Close()
+	if err := check(context.Background()); err == nil {
+		t.Fatal("the health check should fail once the port is closed")
+	}
+}
+
+func TestIsDisconnect(t *testing.T) {
+	tests := []struct {
+		Err  error
+		Want bool
+	}{
+		{nil, false},
+		{io.EOF, true},
+		{fmt.Errorf("upload: %w", io.ErrUnexpectedEOF), true},
+		{errors.New("dial tcp 10.0.0.1:22: connect: connection refused"), true},
+		{errors.New("scp: /etc/file: Permission denied"), false},
+	}
+	for _, tt := range tests {
+		if got := IsDisconnect(tt.Err); got != tt.Want {
+			t.Errorf("IsDisconnect(%v) = %v", tt.Err, got)
+		}
+	}
+}
+
+// countingConnectStep connects to a new droppingCommunicator each time it
+// runs.
+type countingConnectStep struct {
+	connects int
+}
+
+func (s *countingConnectStep) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	s.connects++
+	state.Put("communicator", new(droppingCommunicator))
+	return multistep.ActionContinue
+}
+
+func (s *countingConnectStep) Cleanup(multistep.StateBag) {}
+
+func TestStepConnect_reconnect(t *testing.T) {
+	state := testState(t)
+	substep := new(countingConnectStep)
+	step := &StepConnect{
+		Config:        &Config{Type: "custom"},
+		Host:          func(multistep.StateBag) (string, error) { return "", errors.New("no host") },
+		CustomConnect: map[string]multistep.Step{"custom": substep},
+		Reconnect:     &ReconnectPolicy{Delay: time.Millisecond},
+	}
+	defer step.Cleanup(state)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	rc, ok := state.Get("communicator").(*ReconnectingCommunicator)
+	if !ok {
+		t.Fatalf("bad communicator: %#v", state.Get("communicator"))
+	}
+
+	rc.comm.(*droppingCommunicator).dead = true
+	if err := rc.Upload("/tmp/file", bytes.NewReader([]byte("content")), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if substep.connects != 2 {
+		t.Fatalf("bad connects: %d", substep.connects)
+	}
+	if state.Get("communicator") != rc {
+		t.Fatal("the state should keep the reconnecting communicator")
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Session holds the parameters that worked the last time Packer connected to
+// a host. Computing them can be slow: builders may have to wait for a cloud
+// API to hand out a Windows password, and credential commands or metadata
+// endpoints are queried on each attempt.
+type Session struct {
+	// SSHConfig is the SSH client configuration that authenticated.
+	SSHConfig *gossh.ClientConfig
+	// WinRMUser and WinRMPassword authenticated over WinRM.
+	WinRMUser     string
+	WinRMPassword string
+}
+
+type cachedSession struct {
+	session Session
+	stored  time.Time
+}
+
+// SessionCache remembers Sessions by communicator type, host and port, so
+// that connecting again to a machine, for example after it rebooted or after
+// `pause_before_connecting`, reuses them. A nil *SessionCache caches nothing.
+// It is safe for concurrent use.
+type SessionCache struct {
+	// TTL after which a session is forgotten. Zero means never.
+	TTL time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]cachedSession
+}
+
+func sessionKey(kind, host string, port int) string {
+	return kind + "://" + net.JoinHostPort(host, fmt.Sprint(port))
+}
+
+// Get returns the session stored for the given communicator type and
+// address, if any.
+func (c *SessionCache) Get(kind, host string, port int) (Session, bool) {
+	if c == nil {
+		return Session{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := sessionKey(kind, host, port)
+	cached, ok := c.sessions[key]
+	if !ok {
+		return Session{}, false
+	}
+	if c.TTL > 0 && time.Since(cached.stored) > c.TTL {
+		delete(c.sessions, key)
+		return Session{}, false
+	}
+	return cached.session, true
+}
+
+// Put stores the session that connected to the given communicator type and
+// address.
+func (c *SessionCache) Put(kind, host string, port int, session Session) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sessions == nil {
+		c.sessions = map[string]cachedSession{}
+	}
+	c.sessions[sessionKey(kind, host, port)] = cachedSession{session: session, stored: time.Now()}
+}
+
+// Forget removes the session stored for the given communicator type and
+// address, typically because it does not authenticate anymore.
+func (c *SessionCache) Forget(kind, host string, port int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := sessionKey(kind, host, port)
+	if _, ok := c.sessions[key]; ok {
+		log.Printf("[DEBUG] Forgetting cached session parameters for %s", key)
+		delete(c.sessions, key)
+	}
+}
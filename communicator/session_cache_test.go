@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionCache(t *testing.T) {
+	cache := new(SessionCache)
+	if _, ok := cache.Get("winrm", "10.0.0.1", 5986); ok {
+		t.Fatal("empty cache should not have sessions")
+	}
+
+	cache.Put("winrm", "10.0.0.1", 5986, Session{WinRMUser: "Administrator", WinRMPassword: "s3cr3t"})
+	session, ok := cache.Get("winrm", "10.0.0.1", 5986)
+	if !ok || session.WinRMPassword != "s3cr3t" {
+		t.Fatalf("unexpected session: %#v, %t", session, ok)
+	}
+	if _, ok := cache.Get("winrm", "10.0.0.1", 5985); ok {
+		t.Fatal("sessions should be stored by port")
+	}
+	if _, ok := cache.Get("ssh", "10.0.0.1", 5986); ok {
+		t.Fatal("sessions should be stored by communicator type")
+	}
+
+	cache.Forget("winrm", "10.0.0.1", 5986)
+	if _, ok := cache.Get("winrm", "10.0.0.1", 5986); ok {
+		t.Fatal("session should have been forgotten")
+	}
+
+	cache.TTL = time.Millisecond
+	cache.Put("ssh", "10.0.0.1", 22, Session{})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("ssh", "10.0.0.1", 22); ok {
+		t.Fatal("session should have expired")
+	}
+}
+
+func TestSessionCache_nil(t *testing.T) {
+	var cache *SessionCache
+	cache.Put("ssh", "10.0.0.1", 22, Session{})
+	if _, ok := cache.Get("ssh", "10.0.0.1", 22); ok {
+		t.Fatal("a nil cache should not cache anything")
+	}
+	cache.Forget("ssh", "10.0.0.1", 22)
+}
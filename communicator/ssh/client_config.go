@@ -0,0 +1,399 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+)
+
+// maxIncludeDepth limits the nesting of Include directives, which could
+// otherwise include each other forever.
+const maxIncludeDepth = 16
+
+// ClientConfig is an OpenSSH client configuration, like `~/.ssh/config`, as
+// read by ParseClientConfig. Only the settings Packer can use are kept: see
+// HostConfig.
+type ClientConfig struct {
+	blocks []*clientConfigBlock
+}
+
+// clientConfigBlock is a Host or Match block, or the settings before the
+// first one, which apply to all hosts.
+type clientConfigBlock struct {
+	// patterns are the patterns of a Host block, nil before the first
+	// block.
+	patterns []string
+	// match is set for Match blocks, which are not supported and never
+	// apply.
+	match  bool
+	params [][2]string
+}
+
+func (b *clientConfigBlock) matches(host string) bool {
+	if b.match {
+		return false
+	}
+	if b.patterns == nil {
+		return true
+	}
+	matched := false
+	for _, p := range b.patterns {
+		if strings.HasPrefix(p, "!") {
+			if matchHostPattern(p[1:], host) {
+				return false
+			}
+			continue
+		}
+		if matchHostPattern(p, host) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchHostPattern matches host against p, where `*` matches any number of
+// characters and `?` one character, ignoring case.
+func matchHostPattern(p, host string) bool {
+	p, host = strings.ToLower(p), strings.ToLower(host)
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			for i := len(host); i >= 0; i-- {
+				if matchHostPattern(p[1:], host[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if host == "" {
+				return false
+			}
+		default:
+			if host == "" || host[0] != p[0] {
+				return false
+			}
+		}
+		p, host = p[1:], host[1:]
+	}
+	return host == ""
+}
+
+// ParseClientConfigFile reads the OpenSSH client configuration file at
+// path. The `~` can be used in path and will be expanded to the home
+// directory of current user.
+func ParseClientConfigFile(path string) (*ClientConfig, error) {
+	path, err := pathing.ExpandUser(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &clientConfigParser{config: new(ClientConfig)}
+	if err := p.parseFile(path, 0); err != nil {
+		return nil, err
+	}
+	return p.config, nil
+}
+
+// ParseClientConfig reads an OpenSSH client configuration. Relative paths
+// of Include directives are relative to `~/.ssh`, as for the configuration
+// of the user.
+func ParseClientConfig(r io.Reader) (*ClientConfig, error) {
+	p := &clientConfigParser{config: new(ClientConfig)}
+	if err := p.parse(r, "config", 0); err != nil {
+		return nil, err
+	}
+	return p.config, nil
+}
+
+type clientConfigParser struct {
+	config *ClientConfig
+	// block is the block settings are added to.
+	block *clientConfigBlock
+}
+
+func (p *clientConfigParser) parseFile(path string, depth int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.parse(f, path, depth)
+}
+
+func (p *clientConfigParser) parse(r io.Reader, name string, depth int) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		key, args, err := splitClientConfigLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", name, line, err)
+		}
+		if key == "" {
+			continue
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("%s:%d: %s requires an argument", name, line, key)
+		}
+		switch key {
+		case "host":
+			p.block = &clientConfigBlock{patterns: args}
+			p.config.blocks = append(p.config.blocks, p.block)
+		case "match":
+			p.block = &clientConfigBlock{match: true}
+			p.config.blocks = append(p.config.blocks, p.block)
+		case "include":
+			if depth >= maxIncludeDepth {
+				return fmt.Errorf("%s:%d: too many nested includes", name, line)
+			}
+			for _, arg := range args {
+				if err := p.include(arg, depth+1); err != nil {
+					return fmt.Errorf("%s:%d: %s", name, line, err)
+				}
+			}
+		default:
+			if p.block == nil {
+				p.block = new(clientConfigBlock)
+				p.config.blocks = append(p.config.blocks, p.block)
+			}
+			p.block.params = append(p.block.params, [2]string{key, strings.Join(args, " ")})
+		}
+	}
+	return scanner.Err()
+}
+
+// include parses the files matching pattern, relative to `~/.ssh` unless
+// absolute, like OpenSSH does for the configuration of the user.
+func (p *clientConfigParser) include(pattern string, depth int) error {
+	pattern, err := pathing.ExpandUser(pattern)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(pattern) {
+		home, err := pathing.ExpandUser("~")
+		if err != nil {
+			return err
+		}
+		pattern = filepath.Join(home, ".ssh", pattern)
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := p.parseFile(path, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitClientConfigLine returns the lower case keyword of a line and its
+// arguments, which can be double quoted. Keywords and arguments are
+// separated by spaces or a `=`.
+func splitClientConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	key, rest := strings.ToLower(line[:i]), strings.TrimLeft(line[i:], " \t")
+	if strings.HasPrefix(rest, "=") {
+		rest = strings.TrimLeft(rest[1:], " \t")
+	}
+
+	var args []string
+	for rest != "" {
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated quote in %s", key)
+			}
+			args = append(args, rest[1:end+1])
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			if strings.HasPrefix(rest[:end], "#") {
+				break
+			}
+			args = append(args, rest[:end])
+			rest = rest[end:]
+		}
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return key, args, nil
+}
+
+// HostConfig is the configuration of a host in a ClientConfig.
+type HostConfig struct {
+	// HostName is the real host name to connect to, the host itself when
+	// the configuration doesn't set it.
+	HostName string
+	// Port is the port to connect to, 0 when not set.
+	Port int
+	// User is the user to log in as, "" when not set.
+	User string
+	// IdentityFiles are the paths of the private keys to authenticate with,
+	// with `~` and tokens like `%h` expanded, in order.
+	IdentityFiles []string
+	// ProxyJump is the list of jump hosts to connect through, see
+	// ParseProxyJump, "" when not set.
+	ProxyJump string
+}
+
+// Lookup returns the configuration of host. As with OpenSSH, the first
+// value found for a setting is used, except for IdentityFile whose values
+// are all kept.
+func (c *ClientConfig) Lookup(host string) (HostConfig, error) {
+	hc := HostConfig{}
+	var port string
+	seen := map[string]bool{}
+	for _, b := range c.blocks {
+		if !b.matches(host) {
+			continue
+		}
+		for _, param := range b.params {
+			key, value := param[0], param[1]
+			if key == "identityfile" {
+				hc.IdentityFiles = append(hc.IdentityFiles, value)
+				continue
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			switch key {
+			case "hostname":
+				hc.HostName = value
+			case "port":
+				port = value
+			case "user":
+				hc.User = value
+			case "proxyjump":
+				hc.ProxyJump = value
+			}
+		}
+	}
+
+	if hc.HostName == "" {
+		hc.HostName = host
+	}
+	hc.HostName = strings.ReplaceAll(hc.HostName, "%h", host)
+	if port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			return hc, fmt.Errorf("Port of %s is invalid: %q", host, port)
+		}
+		hc.Port = p
+	}
+	for i, path := range hc.IdentityFiles {
+		path, err := hc.expandTokens(path, host)
+		if err != nil {
+			return hc, fmt.Errorf("IdentityFile of %s is invalid: %s", host, err)
+		}
+		if hc.IdentityFiles[i], err = pathing.ExpandUser(path); err != nil {
+			return hc, fmt.Errorf("IdentityFile of %s is invalid: %s", host, err)
+		}
+	}
+	return hc, nil
+}
+
+// expandTokens expands the `%d`, `%h`, `%n`, `%p`, `%r`, `%u` and `%%`
+// tokens of s.
+func (hc HostConfig) expandTokens(s, host string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", fmt.Errorf("%q ends with %%", s)
+		}
+		switch s[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'd':
+			home, err := pathing.ExpandUser("~")
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(home)
+		case 'h':
+			b.WriteString(hc.HostName)
+		case 'n':
+			b.WriteString(host)
+		case 'p':
+			port := hc.Port
+			if port == 0 {
+				port = 22
+			}
+			b.WriteString(strconv.Itoa(port))
+		case 'r':
+			b.WriteString(hc.User)
+		case 'u':
+			u, err := user.Current()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(u.Username)
+		default:
+			return "", fmt.Errorf("%q: unsupported token %%%c", s, s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// JumpHost is a host of a ProxyJump setting.
+type JumpHost struct {
+	User string
+	Host string
+	// Port is 0 when not set.
+	Port int
+}
+
+// ParseProxyJump parses s, a ProxyJump setting: a comma separated list of
+// `[user@]host[:port]` jump hosts, or `none`.
+func ParseProxyJump(s string) ([]JumpHost, error) {
+	if s == "" || strings.EqualFold(s, "none") {
+		return nil, nil
+	}
+	var hops []JumpHost
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimPrefix(strings.TrimSpace(spec), "ssh://")
+		var hop JumpHost
+		if i := strings.LastIndex(spec, "@"); i >= 0 {
+			hop.User, spec = spec[:i], spec[i+1:]
+		}
+		hop.Host = spec
+		if host, port, err := net.SplitHostPort(spec); err == nil {
+			p, err := strconv.Atoi(port)
+			if err != nil || p <= 0 || p > 65535 {
+				return nil, fmt.Errorf("invalid port in jump host %q", spec)
+			}
+			hop.Host, hop.Port = host, p
+		}
+		if hop.Host == "" {
+			return nil, fmt.Errorf("invalid jump host %q", spec)
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testClientConfig = `
+# Settings for all hosts
+IdentityFile /keys/default
+
+Host build-* !build-legacy
+    HostName %h.internal.example.com
+    User packer
+    IdentityFile "/keys/%n id"
+    ProxyJump jump@bastion:2222,10.0.0.1
+
+Host build-legacy
+    Port=2200
+    User root
+
+Match host build-*
+    User nobody
+
+Host *
+    User fallback
+    Port 22
+`
+
+func TestClientConfig_Lookup(t *testing.T) {
+	config, err := ParseClientConfig(strings.NewReader(testClientConfig))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	tests := map[string]HostConfig{
+		"build-centos": {
+			HostName:      "build-centos.internal.example.com",
+			Port:          22,
+			User:          "packer",
+			IdentityFiles: []string{"/keys/default", "/keys/build-centos id"},
+			ProxyJump:     "jump@bastion:2222,10.0.0.1",
+		},
+		"build-legacy": {
+			HostName:      "build-legacy",
+			Port:          2200,
+			User:          "root",
+			IdentityFiles: []string{"/keys/default"},
+		},
+		"other": {
+			HostName:      "other",
+			Port:          22,
+			User:          "fallback",
+			IdentityFiles: []string{"/keys/default"},
+		},
+	}
+	for host, want := range tests {
+		got, err := config.Lookup(host)
+		if err != nil {
+			t.Fatalf("%s: %s", host, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s: unexpected config: %s", host, diff)
+		}
+	}
+}
+
+func TestParseClientConfigFile_include(t *testing.T) {
+	dir := t.TempDir()
+	included := filepath.Join(dir, "hosts.conf")
+	if err := os.WriteFile(included, []byte("Host db\n  User postgres\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte("Include "+filepath.Join(dir, "*.conf")+"\nHost *\n  User fallback\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ParseClientConfigFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if hc, _ := config.Lookup("db"); hc.User != "postgres" {
+		t.Fatalf("bad user %q", hc.User)
+	}
+	if hc, _ := config.Lookup("web"); hc.User != "fallback" {
+		t.Fatalf("bad user %q", hc.User)
+	}
+}
+
+func TestParseClientConfig_errors(t *testing.T) {
+	for _, src := range []string{
+		"Host\n",
+		"User \"packer\n",
+	} {
+		if _, err := ParseClientConfig(strings.NewReader(src)); err == nil {
+			t.Errorf("%q: expected an error", src)
+		}
+	}
+
+	config, err := ParseClientConfig(strings.NewReader("Port ssh\nIdentityFile ~/.ssh/%z\n"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := config.Lookup("host"); err == nil {
+		t.Fatal("expected an error for the invalid port")
+	}
+}
+
+func TestParseProxyJump(t *testing.T) {
+	got, err := ParseProxyJump("jump@bastion:2222, [fd00::1]:22,ssh://admin@gw")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := []JumpHost{
+		{User: "jump", Host: "bastion", Port: 2222},
+		{Host: "fd00::1", Port: 22},
+		{User: "admin", Host: "gw"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected hops: %s", diff)
+	}
+
+	if hops, err := ParseProxyJump("none"); err != nil || hops != nil {
+		t.Fatalf("none should disable jumps, got %#v, %v", hops, err)
+	}
+	if _, err := ParseProxyJump("bastion:http"); err == nil {
+		t.Fatal("expected an error for the invalid port")
+	}
+}
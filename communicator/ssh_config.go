@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"os"
+	"os/user"
+
+	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
+)
+
+// loadSSHConfigFile fills the settings not set in the template from the
+// configuration of SSHConfigHost, or SSHHost, in SSHConfigFile.
+func (c *SSH) loadSSHConfigFile() error {
+	sshConfig, err := helperssh.ParseClientConfigFile(c.SSHConfigFile)
+	if err != nil {
+		return err
+	}
+	host := c.SSHConfigHost
+	if host == "" {
+		host = c.SSHHost
+	}
+	hc, err := sshConfig.Lookup(host)
+	if err != nil {
+		return err
+	}
+
+	if c.SSHHost == "" {
+		c.SSHHost = hc.HostName
+	}
+	if c.SSHPort == 0 {
+		c.SSHPort = hc.Port
+	}
+	if c.SSHUsername == "" {
+		c.SSHUsername = hc.User
+	}
+	if c.SSHPrivateKeyFile == "" && c.SSHPassword == "" && !c.SSHAgentAuth {
+		c.SSHPrivateKeyFile = firstExistingFile(hc.IdentityFiles)
+	}
+
+	if c.SSHBastionHost != "" || len(c.SSHBastionHops) > 0 || c.SSHProxyHost != "" || c.SSHTransportURL != "" {
+		return nil
+	}
+	jumps, err := helperssh.ParseProxyJump(hc.ProxyJump)
+	if err != nil {
+		return err
+	}
+	for _, jump := range jumps {
+		jc, err := sshConfig.Lookup(jump.Host)
+		if err != nil {
+			return err
+		}
+		hop := SSHBastionHop{
+			Host:           jc.HostName,
+			Port:           jump.Port,
+			Username:       jump.User,
+			PrivateKeyFile: firstExistingFile(jc.IdentityFiles),
+		}
+		if hop.Port == 0 {
+			hop.Port = jc.Port
+		}
+		if hop.Username == "" {
+			hop.Username = jc.User
+		}
+		if hop.Username == "" {
+			// OpenSSH logs in to jump hosts as the local user by default.
+			if u, err := user.Current(); err == nil {
+				hop.Username = u.Username
+			}
+		}
+		// Without identity files, OpenSSH would try the keys of the agent.
+		hop.AgentAuth = hop.PrivateKeyFile == ""
+		c.SSHBastionHops = append(c.SSHBastionHops, hop)
+	}
+	return nil
+}
+
+// firstExistingFile returns the first of paths that exists, as OpenSSH
+// ignores the identity files that don't.
+func firstExistingFile(paths []string) string {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+)
+
+// SSHMetricsGeneratedData are the names of the generated data StepConnectSSH
+// sets with the measures of the SSH connection, so that slow builds can be
+// correlated with the network conditions. Builders connecting with SSH
+// should add them to the generated data they return from Prepare.
+//
+// The times are in milliseconds and the throughput in bytes per second.
+// The connection is measured once connected, and the uploads when the step
+// cleans up: provisioners see no uploads, post-processors see them all.
+var SSHMetricsGeneratedData = []string{
+	"SSHConnectTime",
+	"SSHHandshakeTime",
+	"SSHLatency",
+	"SSHUploadedBytes",
+	"SSHUploadThroughput",
+}
+
+// SSHMetricsMachineCategory is the category of the machine readable events
+// StepConnectSSH writes with the SSHMetrics of the connection.
+const SSHMetricsMachineCategory = "ssh-metrics"
+
+// SSHMetrics are the measures of an SSH connection reported by
+// StepConnectSSH.
+type SSHMetrics struct {
+	// Phase is "connect" for the measures made once connected, and
+	// "cleanup" for the ones made at the end of the build, uploads
+	// included.
+	Phase           string  `json:"phase"`
+	ConnectTimeMs   int64   `json:"connect_time_ms"`
+	HandshakeTimeMs int64   `json:"handshake_time_ms"`
+	LatencyMs       int64   `json:"latency_ms"`
+	UploadedBytes   int64   `json:"uploaded_bytes"`
+	UploadTimeMs    int64   `json:"upload_time_ms"`
+	UploadBytesPerS float64 `json:"upload_bytes_per_second"`
+}
+
+func newSSHMetrics(phase string, m ssh.Metrics) SSHMetrics {
+	return SSHMetrics{
+		Phase:           phase,
+		ConnectTimeMs:   m.ConnectTime.Milliseconds(),
+		HandshakeTimeMs: m.HandshakeTime.Milliseconds(),
+		LatencyMs:       m.Latency.Milliseconds(),
+		UploadedBytes:   m.UploadedBytes,
+		UploadTimeMs:    m.UploadTime.Milliseconds(),
+		UploadBytesPerS: m.UploadThroughput(),
+	}
+}
+
+// machineDataUi is implemented by the Uis writing typed machine events, like
+// packersdk.MachineReadableUi.
+type machineDataUi interface {
+	MachineData(t string, data interface{}) error
+}
+
+// sshPingTimeout bounds the latency measure of a connection that stopped
+// answering.
+var sshPingTimeout = 10 * time.Second
+
+// reportSSHMetrics puts the measures of comm in the generated data of the
+// build, and writes them to the log and as a machine readable event. The
+// latency is measured first when ping is true.
+func reportSSHMetrics(ctx context.Context, state multistep.StateBag, comm ssh.MetricsCommunicator, phase string, ping bool) {
+	if ping {
+		ctx, cancel := context.WithTimeout(ctx, sshPingTimeout)
+		_, err := comm.Ping(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("[DEBUG] Could not measure the SSH latency: %s", err)
+		}
+	}
+
+	m := newSSHMetrics(phase, comm.Metrics())
+	log.Printf("[INFO] SSH metrics (%s): connect %dms, handshake %dms, latency %dms, uploaded %d bytes at %.0f bytes/s",
+		phase, m.ConnectTimeMs, m.HandshakeTimeMs, m.LatencyMs, m.UploadedBytes, m.UploadBytesPerS)
+
+	gd := &packerbuilderdata.GeneratedData{State: state}
+	gd.Put("SSHConnectTime", m.ConnectTimeMs)
+	gd.Put("SSHHandshakeTime", m.HandshakeTimeMs)
+	gd.Put("SSHLatency", m.LatencyMs)
+	gd.Put("SSHUploadedBytes", m.UploadedBytes)
+	gd.Put("SSHUploadThroughput", int64(m.UploadBytesPerS))
+
+	if mui, ok := state.Get("ui").(machineDataUi); ok {
+		if err := mui.MachineData(SSHMetricsMachineCategory, m); err != nil {
+			log.Printf("[DEBUG] Could not write the SSH metrics: %s", err)
+		}
+	}
+}
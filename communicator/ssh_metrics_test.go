@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+)
+
+type mockMetricsCommunicator struct {
+	metrics ssh.Metrics
+	pings   int
+}
+
+func (c *mockMetricsCommunicator) Ping(context.Context) (time.Duration, error) {
+	c.pings++
+	c.metrics.Latency = 25 * time.Millisecond
+	return c.metrics.Latency, nil
+}
+
+func (c *mockMetricsCommunicator) Metrics() ssh.Metrics { return c.metrics }
+
+func TestReportSSHMetrics(t *testing.T) {
+	var out bytes.Buffer
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packersdk.MachineReadableUi{Writer: &out})
+	comm := &mockMetricsCommunicator{metrics: ssh.Metrics{
+		ConnectTime:   120 * time.Millisecond,
+		HandshakeTime: 80 * time.Millisecond,
+	}}
+
+	reportSSHMetrics(context.Background(), state, comm, "connect", true)
+	if comm.pings != 1 {
+		t.Fatalf("the latency should be measured once, got %d pings", comm.pings)
+	}
+	expected := map[string]interface{}{
+		"SSHConnectTime":      int64(120),
+		"SSHHandshakeTime":    int64(80),
+		"SSHLatency":          int64(25),
+		"SSHUploadedBytes":    int64(0),
+		"SSHUploadThroughput": int64(0),
+	}
+	if gd := state.Get("generated_data"); !reflect.DeepEqual(gd, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, gd)
+	}
+	for _, name := range SSHMetricsGeneratedData {
+		if _, ok := expected[name]; !ok {
+			t.Fatalf("%s is not set", name)
+		}
+	}
+
+	comm.metrics.UploadedBytes = 4000
+	comm.metrics.UploadTime = 2 * time.Second
+	reportSSHMetrics(context.Background(), state, comm, "cleanup", false)
+	if comm.pings != 1 {
+		t.Fatalf("the latency should not be measured again, got %d pings", comm.pings)
+	}
+	gd := state.Get("generated_data").(map[string]interface{})
+	if gd["SSHUploadedBytes"] != int64(4000) || gd["SSHUploadThroughput"] != int64(2000) {
+		t.Fatalf("bad upload metrics: %#v", gd)
+	}
+
+	var reported []SSHMetrics
+	dec := packersdk.NewUiEventDecoder(&out)
+	for {
+		e, err := dec.Decode()
+		if err != nil {
+			break
+		}
+		if e.Type != packersdk.UiEventMachine || e.Payload.Category != SSHMetricsMachineCategory {
+			continue
+		}
+		var m SSHMetrics
+		if err := json.Unmarshal(e.Payload.Data, &m); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		reported = append(reported, m)
+	}
+	if len(reported) != 2 || reported[0].Phase != "connect" || reported[1].Phase != "cleanup" {
+		t.Fatalf("bad events: %#v", reported)
+	}
+	if reported[1].LatencyMs != 25 || reported[1].UploadBytesPerS != 2000 {
+		t.Fatalf("bad cleanup event: %#v", reported[1])
+	}
+}
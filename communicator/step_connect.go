@@ -7,9 +7,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/none"
 	gossh "golang.org/x/crypto/ssh"
@@ -45,7 +48,27 @@ type StepConnect struct {
 	// existing types.
 	CustomConnect map[string]multistep.Step
 
-	substep multistep.Step
+	// Proxy, if set, is the proxy configuration of the communicators, used
+	// unless `ssh_proxy_host` or `winrm_proxy` are set: SSH connects
+	// through its SOCKS5 proxy, and WinRM through its HTTP(S) proxies.
+	Proxy *packernet.ProxyConfig
+
+	// Reconnect, if set, makes the communicator put in the state a
+	// ReconnectingCommunicator, connecting again with this policy when the
+	// connection is lost, for provisioners expecting the guest to reboot,
+	// like when installing Windows updates, to survive it. The connection
+	// is health-checked by connecting to the port of the communicator.
+	Reconnect *ReconnectPolicy
+
+	// Preflight, if set, probes the port of the SSH or WinRM communicator
+	// before waiting for it, to fail fast with what is likely wrong, like
+	// another service answering on the port, instead of waiting until
+	// the communicator times out. The port is not probed through a proxy
+	// or a bastion.
+	Preflight *PreflightPolicy
+
+	substep         multistep.Step
+	stopHealthCheck func()
 }
 
 func (s *StepConnect) pause(pauseLen time.Duration, ctx context.Context) bool {
@@ -69,12 +92,14 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 			Host:      s.Host,
 			SSHConfig: s.SSHConfig,
 			SSHPort:   s.SSHPort,
+			Proxy:     s.Proxy,
 		},
 		"winrm": &StepConnectWinRM{
 			Config:      s.Config,
 			Host:        s.Host,
 			WinRMConfig: s.WinRMConfig,
 			WinRMPort:   s.WinRMPort,
+			Proxy:       s.Proxy,
 		},
 	}
 	for k, v := range s.CustomConnect {
@@ -114,6 +139,14 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 		log.Printf("[DEBUG] Unable to get address during connection step: %s", err)
 	}
 
+	if s.Config.SessionCache == nil {
+		s.Config.SessionCache = new(SessionCache)
+	}
+
+	if s.Preflight != nil && !s.preflight(ctx, state) {
+		return multistep.ActionHalt
+	}
+
 	s.substep = step
 	action := s.substep.Run(ctx, state)
 	if action == multistep.ActionHalt {
@@ -135,6 +168,10 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 		}
 	}
 
+	if s.Reconnect != nil {
+		s.reconnecting(state)
+	}
+
 	// Put communicator config into state so we can pass it to provisioners
 	// for specialized interpolation later
 	state.Put("communicator_config", s.Config)
@@ -142,7 +179,103 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 	return multistep.ActionContinue
 }
 
+// preflight probes the port of the communicator with the Preflight policy.
+// It returns false when the build must halt, with the error in the state.
+func (s *StepConnect) preflight(ctx context.Context, state multistep.StateBag) bool {
+	ui := state.Get("ui").(packersdk.Ui)
+	if s.Proxy != nil && s.Config.Type == "ssh" && s.Proxy.SOCKS5Proxy != "" {
+		return true
+	}
+	if s.Proxy != nil && s.Config.Type == "winrm" && !s.Config.WinRMNoProxy {
+		return true
+	}
+
+	host, err := s.Host(state)
+	if err != nil {
+		log.Printf("[DEBUG] Unable to get address for the preflight probe: %s", err)
+		return true
+	}
+	port := s.Config.Port()
+	portFn := s.SSHPort
+	if s.Config.Type == "winrm" {
+		portFn = s.WinRMPort
+	}
+	if portFn != nil {
+		if port, err = portFn(state); err != nil {
+			log.Printf("[DEBUG] Unable to get port for the preflight probe: %s", err)
+			return true
+		}
+	}
+
+	r := s.Preflight.run(ctx, s.Config, host, port)
+	if r == nil || r.State == PortOpen || ctx.Err() != nil {
+		return true
+	}
+	log.Printf("[INFO] Preflight probe of %s: %s", r.Address, r.State)
+	if r.State == PortWrongService || r.State == PortUnresolved || s.Preflight.FailUnreachable {
+		err := fmt.Errorf("Preflight check of %s failed: %s", r.Address, r.Err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return false
+	}
+	ui.Message(fmt.Sprintf("Port %s is %s: %s. Waiting for the communicator anyway...", r.Address, r.State, r.Err))
+	return true
+}
+
+// reconnecting replaces the communicator of the state with a
+// ReconnectingCommunicator running the connect substep again to reconnect.
+func (s *StepConnect) reconnecting(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	var rc *ReconnectingCommunicator
+	connect := func(ctx context.Context) (packersdk.Communicator, error) {
+		// The substep reports through the state, which must keep the
+		// reconnecting communicator, and the error of the build if any.
+		previousErr, hadErr := state.GetOk("error")
+		action := s.substep.Run(ctx, state)
+		comm, _ := state.Get("communicator").(packersdk.Communicator)
+		err, _ := state.Get("error").(error)
+		state.Put("communicator", rc)
+		if hadErr {
+			state.Put("error", previousErr)
+		} else {
+			state.Remove("error")
+		}
+		if action == multistep.ActionHalt || comm == nil {
+			if err == nil {
+				err = fmt.Errorf("could not connect to the guest")
+			}
+			return nil, err
+		}
+		return comm, nil
+	}
+	rc = NewReconnectingCommunicator(state.Get("communicator").(packersdk.Communicator), connect, *s.Reconnect)
+	rc.Ui = ui
+	state.Put("communicator", rc)
+
+	host, err := s.Host(state)
+	if err != nil {
+		log.Printf("[DEBUG] No host to health-check the connection to: %s", err)
+		return
+	}
+	port := s.Config.Port()
+	switch {
+	case s.Config.Type == "ssh" && s.SSHPort != nil:
+		port, err = s.SSHPort(state)
+	case s.Config.Type == "winrm" && s.WinRMPort != nil:
+		port, err = s.WinRMPort(state)
+	}
+	if err != nil || port == 0 {
+		log.Printf("[DEBUG] No port to health-check the connection to: %v", err)
+		return
+	}
+	s.stopHealthCheck = rc.HealthCheck(TCPHealthCheck(net.JoinHostPort(host, strconv.Itoa(port))))
+}
+
 func (s *StepConnect) Cleanup(state multistep.StateBag) {
+	if s.stopHealthCheck != nil {
+		s.stopHealthCheck()
+		s.stopHealthCheck = nil
+	}
 	if s.substep != nil {
 		s.substep.Cleanup(state)
 	}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -17,9 +18,12 @@ import (
 	"golang.org/x/term"
 
 	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
+	"github.com/hashicorp/packer-plugin-sdk/fips"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/pathing"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/powershell"
 	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
 	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
@@ -29,12 +33,21 @@ import (
 // StepConnectSSH is a step that only connects to SSH.
 //
 // In general, you should use StepConnect.
+//
+// It measures the connection and the uploads, reported in the generated
+// data named by SSHMetricsGeneratedData and as machine readable events of
+// category SSHMetricsMachineCategory.
 type StepConnectSSH struct {
 	// All the fields below are documented on StepConnect
 	Config    *Config
 	Host      func(multistep.StateBag) (string, error)
 	SSHConfig func(multistep.StateBag) (*gossh.ClientConfig, error)
 	SSHPort   func(multistep.StateBag) (int, error)
+	Proxy     *packernet.ProxyConfig
+
+	// metrics measures the last connection, nil when the communicator
+	// doesn't.
+	metrics ssh.MetricsCommunicator
 }
 
 func (s *StepConnectSSH) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -70,12 +83,16 @@ func (s *StepConnectSSH) Run(ctx context.Context, state multistep.StateBag) mult
 
 			ui.Say("Connected to SSH!")
 			state.Put("communicator", comm)
+			if s.metrics != nil {
+				reportSSHMetrics(ctx, state, s.metrics, "connect", true)
+			}
 			return multistep.ActionContinue
 		case <-timeout:
 			err := fmt.Errorf("Timeout waiting for SSH.")
 			state.Put("error", err)
 			ui.Error(err.Error())
 			cancel()
+			s.diagnose(ctx, state, ui)
 			return multistep.ActionHalt
 		case <-ctx.Done():
 			// The step sequence was cancelled, so cancel waiting for SSH
@@ -88,7 +105,29 @@ func (s *StepConnectSSH) Run(ctx context.Context, state multistep.StateBag) mult
 	}
 }
 
-func (s *StepConnectSSH) Cleanup(multistep.StateBag) {
+func (s *StepConnectSSH) Cleanup(state multistep.StateBag) {
+	// The connection may be gone by now, so its latency is not measured
+	// again.
+	if s.metrics != nil {
+		reportSSHMetrics(context.Background(), state, s.metrics, "cleanup", false)
+	}
+}
+
+// diagnose reports why the machine could not be reached over SSH.
+func (s *StepConnectSSH) diagnose(ctx context.Context, state multistep.StateBag, ui packersdk.Ui) {
+	host, err := s.Host(state)
+	if err != nil {
+		log.Printf("[DEBUG] No host to diagnose the connection to: %s", err)
+		return
+	}
+	port := s.Config.SSHPort
+	if s.SSHPort != nil {
+		if port, err = s.SSHPort(state); err != nil {
+			log.Printf("[DEBUG] No port to diagnose the connection to: %s", err)
+			return
+		}
+	}
+	DiagnoseSSH(ctx, s.Config, host, port).Report(ui)
 }
 
 func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Context) (packersdk.Communicator, error) {
@@ -97,8 +136,10 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 	// do this one before entering the retry loop.
 	var bProto, bAddr string
 	var bConf *gossh.ClientConfig
+	var bHops []ssh.BastionHop
 	var pAddr string
 	var pAuth *proxy.Auth
+	var tURL *url.URL
 	if s.Config.SSHBastionHost != "" {
 		// The protocol is hardcoded for now, but may be configurable one day
 		bProto = "tcp"
@@ -111,6 +152,18 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 		bConf = conf
 	}
 
+	for i, hop := range s.Config.SSHBastionHops {
+		conf, err := sshBastionHopConfig(hop, false)
+		if err != nil {
+			return nil, fmt.Errorf("Error configuring bastion hop %d: %s", i, err)
+		}
+		bHops = append(bHops, ssh.BastionHop{
+			Proto:  "tcp",
+			Addr:   net.JoinHostPort(hop.Host, fmt.Sprint(hop.Port)),
+			Config: conf,
+		})
+	}
+
 	if s.Config.SSHProxyHost != "" {
 		pAddr = net.JoinHostPort(s.Config.SSHProxyHost, fmt.Sprint(s.Config.SSHProxyPort))
 		if s.Config.SSHProxyUsername != "" {
@@ -121,6 +174,14 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 
 	}
 
+	if s.Config.SSHTransportURL != "" {
+		u, err := url.Parse(s.Config.SSHTransportURL)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing ssh_transport_url: %s", err)
+		}
+		tURL = u
+	}
+
 	handshakeAttempts := 0
 
 	var comm packersdk.Communicator
@@ -156,12 +217,26 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 		}
 		state.Put("communicator_config", s.Config)
 
-		// Retrieve the SSH configuration
-		sshConfig, err := s.SSHConfig(state)
-		if err != nil {
-			log.Printf("[DEBUG] Error getting SSH config: %s", err)
-			continue
+		// Retrieve the SSH configuration, unless one already connected to
+		// this host.
+		session, cached := s.Config.SessionCache.Get("ssh", host, port)
+		sshConfig := session.SSHConfig
+		if cached && sshConfig != nil {
+			log.Printf("[DEBUG] Reusing the SSH configuration that connected to %s", host)
+		} else {
+			cached = false
+			sshConfig, err = s.SSHConfig(state)
+			if err != nil {
+				log.Printf("[DEBUG] Error getting SSH config: %s", err)
+				continue
+			}
+			sshConfig, err = s.Config.withSSHCredentials(ctx, sshConfig)
+			if err != nil {
+				log.Printf("[DEBUG] %s", err)
+				continue
+			}
 		}
+		fips.ApplySSHClient(sshConfig)
 
 		// Attempt to connect to SSH port
 		var connFunc func() (net.Conn, error)
@@ -172,9 +247,29 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 			// We're using a bastion host, so use the bastion connfunc
 			connFunc = ssh.BastionConnectFunc(
 				bProto, bAddr, bConf, "tcp", address)
+		} else if len(bHops) > 0 {
+			log.Printf("[INFO] connecting with SSH to host %s through %d bastion hops",
+				address, len(bHops))
+			connFunc = ssh.BastionChainConnectFunc(bHops, "tcp", address)
 		} else if pAddr != "" {
 			// Connect via SOCKS5 proxy
 			connFunc = ssh.ProxyConnectFunc(pAddr, pAuth, "tcp", address)
+		} else if tURL != nil {
+			log.Printf("[INFO] connecting with SSH to host %s through %s",
+				address, tURL.Redacted())
+			switch tURL.Scheme {
+			case "ws", "wss":
+				connFunc = ssh.WebSocketConnectFunc(tURL, s.Config.SSHTransportToken)
+			default:
+				connFunc = ssh.HTTPConnectFunc(tURL, s.Config.SSHTransportToken, address)
+			}
+		} else if s.Proxy != nil && s.Proxy.SOCKS5Proxy != "" {
+			log.Printf("[INFO] connecting with SSH to host %s through the SOCKS5 proxy", address)
+			connFunc = func() (net.Conn, error) {
+				dialCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+				defer cancel()
+				return s.Proxy.DialContext(dialCtx, "tcp", address)
+			}
 		} else {
 			// No bastion host, connect directly
 			connFunc = ssh.ConnectFunc("tcp", address)
@@ -217,6 +312,19 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 			Timeout:                s.Config.SSHReadWriteTimeout,
 			Tunnels:                tunnels,
 		}
+		if s.Config.SSHOutputEncoding == ssh.AutoOutputEncoding {
+			config.DetectOutputEncoding = true
+		} else if s.Config.SSHOutputEncoding != "" {
+			config.OutputEncoding, _ = ssh.LookupEncoding(s.Config.SSHOutputEncoding)
+		}
+		if s.Config.SSHFileTransferChunkSize > 0 {
+			config.ChunkedUpload = &ssh.ChunkedUploadConfig{
+				ChunkSize:      s.Config.SSHFileTransferChunkSize,
+				Parallelism:    s.Config.SSHFileTransferParallelism,
+				Resume:         s.Config.SSHFileTransferResume,
+				VerifyChecksum: s.Config.SSHFileTransferVerifyChecksum,
+			}
+		}
 
 		log.Printf("[INFO] Attempting SSH connection to %s...", address)
 		comm, err = ssh.New(address, config)
@@ -226,6 +334,12 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 			// Only count this as an attempt if we were able to attempt
 			// to authenticate. Note this is very brittle since it depends
 			// on the string of the error... but I don't see any other way.
+			if cached && strings.Contains(err.Error(), "authenticate") {
+				// The credentials changed since the last connection, so
+				// get them again instead of counting a failed attempt.
+				s.Config.SessionCache.Forget("ssh", host, port)
+				continue
+			}
 			if strings.Contains(err.Error(), "authenticate") {
 				log.Printf(
 					"[DEBUG] Detected authentication error. Increasing handshake attempts.")
@@ -249,16 +363,36 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 			continue
 		}
 
+		s.Config.SessionCache.Put("ssh", host, port, Session{SSHConfig: sshConfig})
+		s.metrics, _ = comm.(ssh.MetricsCommunicator)
 		break
 	}
 
+	if s.Config.SSHPowerShell {
+		comm = powershell.New(comm, &powershell.Config{
+			ElevatedUser:     s.Config.SSHElevatedUser,
+			ElevatedPassword: s.Config.SSHElevatedPassword,
+		})
+	}
 	return comm, nil
 }
 
 func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
+	return sshBastionHopConfig(SSHBastionHop{
+		Username:        config.SSHBastionUsername,
+		Password:        config.SSHBastionPassword,
+		AgentAuth:       config.SSHBastionAgentAuth,
+		PrivateKeyFile:  config.SSHBastionPrivateKeyFile,
+		CertificateFile: config.SSHBastionCertificateFile,
+	}, config.SSHBastionInteractive)
+}
+
+// sshBastionHopConfig returns the client configuration used to authenticate
+// with the bastion host of hop.
+func sshBastionHopConfig(hop SSHBastionHop, interactive bool) (*gossh.ClientConfig, error) {
 	auth := make([]gossh.AuthMethod, 0, 2)
 
-	if config.SSHBastionInteractive {
+	if interactive {
 		var c io.ReadWriteCloser
 		if term.IsTerminal(int(os.Stdin.Fd())) {
 			c = os.Stdin
@@ -273,22 +407,22 @@ func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
 		auth = append(auth, gossh.KeyboardInteractive(ssh.KeyboardInteractive(c)))
 	}
 
-	if config.SSHBastionPassword != "" {
+	if hop.Password != "" {
 		auth = append(auth,
-			gossh.Password(config.SSHBastionPassword),
+			gossh.Password(hop.Password),
 			gossh.KeyboardInteractive(
-				ssh.PasswordKeyboardInteractive(config.SSHBastionPassword)))
+				ssh.PasswordKeyboardInteractive(hop.Password)))
 	}
 
-	if config.SSHBastionPrivateKeyFile != "" {
-		path, err := pathing.ExpandUser(config.SSHBastionPrivateKeyFile)
+	if hop.PrivateKeyFile != "" {
+		path, err := pathing.ExpandUser(hop.PrivateKeyFile)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"Error expanding path for SSH bastion private key: %s", err)
 		}
 
-		if config.SSHBastionCertificateFile != "" {
-			identityPath, err := pathing.ExpandUser(config.SSHBastionCertificateFile)
+		if hop.CertificateFile != "" {
+			identityPath, err := pathing.ExpandUser(hop.CertificateFile)
 			if err != nil {
 				return nil, fmt.Errorf("Error expanding path for SSH bastion identity certificate: %s", err)
 			}
@@ -306,7 +440,7 @@ func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
 		}
 	}
 
-	if config.SSHBastionAgentAuth {
+	if hop.AgentAuth {
 		authSock := os.Getenv("SSH_AUTH_SOCK")
 		if authSock == "" {
 			return nil, fmt.Errorf("SSH_AUTH_SOCK is not set")
@@ -320,9 +454,11 @@ func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
 		auth = append(auth, gossh.PublicKeysCallback(agent.NewClient(sshAgent).Signers))
 	}
 
-	return &gossh.ClientConfig{
-		User:            config.SSHBastionUsername,
+	bConf := &gossh.ClientConfig{
+		User:            hop.Username,
 		Auth:            auth,
 		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
-	}, nil
+	}
+	fips.ApplySSHClient(bConf)
+	return bConf, nil
 }
@@ -17,7 +17,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/fips"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packernet "github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/winrm"
 	winrmcmd "github.com/masterzen/winrm"
@@ -41,6 +43,7 @@ type StepConnectWinRM struct {
 	Host        func(multistep.StateBag) (string, error)
 	WinRMConfig func(multistep.StateBag) (*WinRMConfig, error)
 	WinRMPort   func(multistep.StateBag) (int, error)
+	Proxy       *packernet.ProxyConfig
 }
 
 func (s *StepConnectWinRM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -78,6 +81,7 @@ func (s *StepConnectWinRM) Run(ctx context.Context, state multistep.StateBag) mu
 			state.Put("error", err)
 			ui.Error(err.Error())
 			cancel()
+			s.diagnose(ctx, state, ui)
 			return multistep.ActionHalt
 		case <-ctx.Done():
 			// The step sequence was cancelled, so cancel waiting for WinRM
@@ -90,11 +94,41 @@ func (s *StepConnectWinRM) Run(ctx context.Context, state multistep.StateBag) mu
 	}
 }
 
-func (s *StepConnectWinRM) Cleanup(multistep.StateBag) {
+func (s *StepConnectWinRM) Cleanup(state multistep.StateBag) {
+	// Stop the PowerShell runspace of the communicator, if any.
+	if comm, ok := state.Get("communicator").(io.Closer); ok {
+		if err := comm.Close(); err != nil {
+			log.Printf("[DEBUG] Error closing the WinRM communicator: %s", err)
+		}
+	}
+}
+
+// diagnose reports why the machine could not be reached over WinRM.
+func (s *StepConnectWinRM) diagnose(ctx context.Context, state multistep.StateBag, ui packersdk.Ui) {
+	host, err := s.Host(state)
+	if err != nil {
+		log.Printf("[DEBUG] No host to diagnose the connection to: %s", err)
+		return
+	}
+	port := s.Config.WinRMPort
+	if s.WinRMPort != nil {
+		if port, err = s.WinRMPort(state); err != nil {
+			log.Printf("[DEBUG] No port to diagnose the connection to: %s", err)
+			return
+		}
+	}
+	DiagnoseWinRM(ctx, s.Config, host, port).Report(ui)
 }
 
 func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Context) (packersdk.Communicator, error) {
 	var comm packersdk.Communicator
+	// What connected, to be cached once WinRM is known to work.
+	var connected struct {
+		host    string
+		port    int
+		session Session
+	}
+	fromCache := false
 	first := true
 	for {
 		// Don't check for cancel or wait on first iteration
@@ -129,7 +163,13 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 
 		user := s.Config.WinRMUser
 		password := s.Config.WinRMPassword
-		if s.WinRMConfig != nil {
+		session, cached := s.Config.SessionCache.Get("winrm", host, port)
+		if cached {
+			log.Printf("[DEBUG] Reusing the WinRM credentials that connected to %s", host)
+			user, password = session.WinRMUser, session.WinRMPassword
+			s.Config.WinRMPassword = password
+		}
+		if s.WinRMConfig != nil && !cached {
 			config, err := s.WinRMConfig(state)
 			if err != nil {
 				log.Printf("[DEBUG] Error getting WinRM config: %s", err)
@@ -145,15 +185,37 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 			}
 		}
 
-		if s.Config.WinRMNoProxy {
+		if chain := s.Config.WinRMCredentialChain(); len(chain) > 0 && !cached {
+			creds, err := chain.Retrieve(ctx)
+			if err != nil {
+				log.Printf("[DEBUG] Error retrieving WinRM credentials: %s", err)
+				continue
+			}
+			if creds.Username != "" {
+				user = creds.Username
+			}
+			if creds.Password != "" {
+				password = creds.Password
+				s.Config.WinRMPassword = password
+			}
+		}
+
+		proxyFunc := ProxyFunc(s.Config.WinRMProxy)
+		if s.Proxy != nil && s.Config.WinRMProxy == "" {
+			proxy := *s.Proxy
+			if s.Config.WinRMNoProxy {
+				proxy.NoProxy = append(append([]string(nil), proxy.NoProxy...), net.JoinHostPort(host, fmt.Sprint(port)))
+			}
+			proxyFunc = proxy.ProxyFunc()
+			s.Config.WinRMTransportDecorator = proxyFuncTransportDecorator(proxyFunc, s.Config.WinRMUseNTLM)
+		} else if s.Config.WinRMNoProxy {
 			if err := setNoProxy(host, port); err != nil {
 				return nil, fmt.Errorf("Error setting no_proxy: %s", err)
 			}
-			if s.Config.WinRMUseNTLM {
-				s.Config.WinRMTransportDecorator = ProxyTransportDecoratorWithNTLM
-			} else {
-				s.Config.WinRMTransportDecorator = ProxyTransportDecorator
-			}
+			s.Config.WinRMTransportDecorator = ProxyTransportDecoratorFunc(s.Config.WinRMProxy, s.Config.WinRMUseNTLM)
+		}
+		if fips.Enabled() {
+			s.Config.WinRMTransportDecorator = fipsTransportDecorator(proxyFunc, user, password)
 		}
 
 		log.Println("[INFO] Attempting WinRM connection...")
@@ -166,12 +228,16 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 			Https:              s.Config.WinRMUseSSL,
 			Insecure:           s.Config.WinRMInsecure,
 			TransportDecorator: s.Config.WinRMTransportDecorator,
+			PersistentRunspace: s.Config.WinRMPersistentRunspace,
 		})
 		if err != nil {
 			log.Printf("[ERROR] WinRM connection err: %s", err)
 			continue
 		}
 
+		fromCache = cached
+		connected.host, connected.port = host, port
+		connected.session = Session{WinRMUser: user, WinRMPassword: password}
 		break
 	}
 	// run an "echo" command to make sure winrm is actually connected before moving on.
@@ -196,6 +262,12 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 
 		if err != nil {
 			log.Printf("Communication connection err: %s", err)
+			if fromCache && strings.Contains(err.Error(), "401") {
+				// The cached credentials are not valid anymore, get them
+				// again.
+				s.Config.SessionCache.Forget("winrm", connected.host, connected.port)
+				return s.waitForWinRM(state, ctx)
+			}
 			continue
 		}
 
@@ -207,6 +279,7 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 		}
 		break
 	}
+	s.Config.SessionCache.Put("winrm", connected.host, connected.port, connected.session)
 
 	return comm, nil
 }
@@ -238,6 +311,39 @@ func envProxyFunc() func(*url.URL) (*url.URL, error) {
 	return envProxyFuncValue
 }
 
+// ProxyFunc returns a proxy selection function for WinRM requests. When proxy
+// is empty the HTTP_PROXY and HTTPS_PROXY environment variables are used,
+// otherwise proxy is used for both http and https endpoints. In both cases
+// NO_PROXY is reloaded from the environment on every request.
+func ProxyFunc(proxy string) func(*http.Request) (*url.URL, error) {
+	if proxy == "" {
+		return RefreshProxyFromEnvironment
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		cfg := httpproxy.FromEnvironment()
+		cfg.HTTPProxy = proxy
+		cfg.HTTPSProxy = proxy
+		return cfg.ProxyFunc()(req.URL)
+	}
+}
+
+// ProxyTransportDecoratorFunc returns a WinRM transport decorator that routes
+// requests through proxy, or through the proxy set in the environment when
+// proxy is empty. If useNTLM is true the returned transport authenticates
+// using NTLM.
+func ProxyTransportDecoratorFunc(proxy string, useNTLM bool) func() winrmcmd.Transporter {
+	return proxyFuncTransportDecorator(ProxyFunc(proxy), useNTLM)
+}
+
+// proxyFuncTransportDecorator returns a WinRM transport decorator that routes
+// requests through the proxies returned by proxyFunc.
+func proxyFuncTransportDecorator(proxyFunc func(*http.Request) (*url.URL, error), useNTLM bool) func() winrmcmd.Transporter {
+	if useNTLM {
+		return func() winrmcmd.Transporter { return winrmcmd.NewClientNTLMWithProxyFunc(proxyFunc) }
+	}
+	return func() winrmcmd.Transporter { return winrmcmd.NewClientWithProxyFunc(proxyFunc) }
+}
+
 // ProxyTransportDecorator is a custom Transporter that reloads HTTP Proxy settings at client runtime.
 // The net/http ProxyFromEnvironment only loads the environment once, when the
 // code is initialized rather than when it's executed. This means that if your
@@ -14,9 +14,16 @@ import (
 )
 
 // StepSSHKeyGen is a Packer build step that generates SSH key pairs.
+//
+// Produces:
+//
+//	ephemeral_ssh_key *EphemeralSSHKey - the generated key pair, unset when
+//	  an existing private key is used.
 type StepSSHKeyGen struct {
 	CommConf *Config
 	SSHTemporaryKeyPair
+
+	key *EphemeralSSHKey
 }
 
 // Run executes the Packer build step that generates SSH key pairs.
@@ -57,7 +64,7 @@ func (s *StepSSHKeyGen) Run(ctx context.Context, state multistep.StateBag) multi
 	}
 
 	ui.Say(fmt.Sprintf("Creating temporary %s SSH key for instance...", strings.ToUpper(a.String())))
-	pair, err := sshkey.GeneratePair(a, nil, s.SSHTemporaryKeyPairBits)
+	key, err := NewEphemeralSSHKey(a, s.SSHTemporaryKeyPairBits)
 	if err != nil {
 		err := fmt.Errorf("Error creating temporary SSH key: %s", err)
 		state.Put("error", err)
@@ -65,11 +72,27 @@ func (s *StepSSHKeyGen) Run(ctx context.Context, state multistep.StateBag) multi
 		return multistep.ActionHalt
 	}
 
-	comm.SSHPrivateKey = pair.Private
-	comm.SSHPublicKey = pair.Public
+	s.key = key
+	comm.SSHPrivateKey = key.Private
+	comm.SSHPublicKey = key.Public
+	state.Put("ephemeral_ssh_key", key)
 
 	return multistep.ActionContinue
 }
 
-// Nothing to clean up. SSH keys are associated with a single GCE instance.
-func (s *StepSSHKeyGen) Cleanup(state multistep.StateBag) {}
+// Cleanup destroys the generated key pair, if any, and unsets the zeroed
+// SSHPrivateKey of the config, so that nothing reads it afterwards. A key that
+// was read from ssh_private_key_file is left untouched.
+func (s *StepSSHKeyGen) Cleanup(state multistep.StateBag) {
+	if s.key == nil {
+		return
+	}
+	if k := s.CommConf.SSHPrivateKey; len(k) > 0 && len(s.key.Private) > 0 && &k[0] == &s.key.Private[0] {
+		s.CommConf.SSHPrivateKey = nil
+	}
+	if err := s.key.Destroy(); err != nil {
+		ui := state.Get("ui").(packersdk.Ui)
+		ui.Error(fmt.Sprintf("Error destroying temporary SSH key: %s", err))
+	}
+	s.key = nil
+}
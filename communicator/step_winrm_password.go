@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/random"
+)
+
+// DefaultWinRMPasswordLength is the length of the passwords generated by
+// GenerateWinRMPassword when no length is given.
+const DefaultWinRMPasswordLength = 24
+
+// WinRMPasswordSymbols are the symbols of the passwords generated by
+// GenerateWinRMPassword. They can be passed unquoted through cmd.exe,
+// PowerShell, XML unattend files and user data scripts.
+const WinRMPasswordSymbols = "#*+,-.:=@_~"
+
+// WinRMPasswordPolicy is the policy of the passwords generated by
+// GenerateWinRMPassword. It satisfies the default Windows complexity
+// requirements: the password contains lower case and upper case letters,
+// digits and symbols. Builders can start from it to meet stricter rules of
+// their platform.
+var WinRMPasswordPolicy = random.PasswordPolicy{
+	Length:     DefaultWinRMPasswordLength,
+	Symbols:    WinRMPasswordSymbols,
+	MinLower:   1,
+	MinUpper:   1,
+	MinDigits:  1,
+	MinSymbols: 1,
+}
+
+// GenerateWinRMPassword returns a random password following
+// WinRMPasswordPolicy, picked with the source of the random package. length
+// defaults to DefaultWinRMPasswordLength and cannot be less than 8.
+func GenerateWinRMPassword(length int) (string, error) {
+	if length == 0 {
+		length = DefaultWinRMPasswordLength
+	}
+	if length < 8 {
+		return "", fmt.Errorf("WinRM passwords must be at least 8 characters long, got %d", length)
+	}
+	policy := WinRMPasswordPolicy
+	policy.Length = length
+	return random.Password(policy)
+}
+
+// StepWinRMPassword is a Packer build step that generates a temporary WinRM
+// password when none is configured, the same way StepSSHKeyGen generates a
+// temporary SSH key. The password is set on the communicator configuration
+// and registered with the log secret filter.
+//
+// Produces:
+//
+//	winrm_password string - the password used to connect to WinRM.
+type StepWinRMPassword struct {
+	CommConf *Config
+	// Length of the generated password, defaults to
+	// DefaultWinRMPasswordLength.
+	Length int
+	// Inject is called with the generated password so that the builder can
+	// set it on the machine it creates, for example in its user data or
+	// through the cloud provider API. It is not called when the password
+	// comes from the configuration.
+	Inject func(state multistep.StateBag, password string) error
+}
+
+func (s *StepWinRMPassword) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	comm := s.CommConf
+
+	if comm.WinRMPassword != "" {
+		ui.Say("Using configured WinRM password")
+		state.Put("winrm_password", comm.WinRMPassword)
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Creating temporary WinRM password...")
+	password, err := GenerateWinRMPassword(s.Length)
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary WinRM password: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	packersdk.LogSecretFilter.Set(password)
+
+	if s.Inject != nil {
+		if err := s.Inject(state, password); err != nil {
+			err := fmt.Errorf("Error setting temporary WinRM password: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	comm.WinRMPassword = password
+	state.Put("winrm_password", password)
+	return multistep.ActionContinue
+}
+
+func (s *StepWinRMPassword) Cleanup(state multistep.StateBag) {}
+
+// StepRotateWinRMPassword replaces the WinRM password on the machine with a
+// new random one that is never shown, so the temporary password is useless
+// once the image is captured. Add it after the provisioning steps; the
+// existing WinRM session cannot be used once the password changed.
+type StepRotateWinRMPassword struct {
+	CommConf *Config
+	// Rotate changes the password of the WinRM user on the machine. It
+	// defaults to running `net user` through the communicator.
+	Rotate func(ctx context.Context, state multistep.StateBag, password string) error
+}
+
+func (s *StepRotateWinRMPassword) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	password, err := GenerateWinRMPassword(0)
+	if err != nil {
+		err := fmt.Errorf("Error creating new WinRM password: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	packersdk.LogSecretFilter.Set(password)
+
+	rotate := s.Rotate
+	if rotate == nil {
+		rotate = s.netUser
+	}
+
+	ui.Say("Rotating WinRM password...")
+	if err := rotate(ctx, state, password); err != nil {
+		err := fmt.Errorf("Error rotating WinRM password: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.CommConf.WinRMPassword = password
+	state.Put("winrm_password", password)
+	return multistep.ActionContinue
+}
+
+func (s *StepRotateWinRMPassword) netUser(ctx context.Context, state multistep.StateBag, password string) error {
+	comm, ok := state.Get("communicator").(packersdk.Communicator)
+	if !ok {
+		return fmt.Errorf("no communicator to run the command with")
+	}
+	cmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf(`net user "%s" %s`, s.CommConf.WinRMUser, password),
+	}
+	if err := cmd.RunWithUi(ctx, comm, state.Get("ui").(packersdk.Ui)); err != nil {
+		return err
+	}
+	if cmd.ExitStatus() != 0 {
+		return fmt.Errorf("net user exited with a non-zero exit status: %d", cmd.ExitStatus())
+	}
+	return nil
+}
+
+func (s *StepRotateWinRMPassword) Cleanup(state multistep.StateBag) {}
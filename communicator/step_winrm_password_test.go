@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/random"
+)
+
+func TestGenerateWinRMPassword(t *testing.T) {
+	if _, err := GenerateWinRMPassword(6); err == nil {
+		t.Fatalf("expected short passwords to be rejected")
+	}
+
+	for i := 0; i < 50; i++ {
+		password, err := GenerateWinRMPassword(8)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if len(password) != 8 {
+			t.Fatalf("unexpected length: %q", password)
+		}
+		for _, set := range []string{random.PossibleLowerCase, random.PossibleUpperCase, random.PossibleNumbers, WinRMPasswordSymbols} {
+			if !strings.ContainsAny(password, set) {
+				t.Fatalf("%q has no character of %q", password, set)
+			}
+		}
+	}
+
+	// The passwords come from the source of the random package.
+	defer random.SetSource(random.SetSource(random.NewSeededSource(42)))
+	first, _ := GenerateWinRMPassword(0)
+	random.SetSource(random.NewSeededSource(42))
+	if second, _ := GenerateWinRMPassword(0); first != second {
+		t.Fatalf("expected the same password from the same seed, got %q and %q", first, second)
+	}
+}
+
+func TestStepWinRMPassword(t *testing.T) {
+	state := testState(t)
+	config := &Config{Type: "winrm", WinRM: WinRM{WinRMUser: "Administrator"}}
+
+	var injected string
+	step := &StepWinRMPassword{
+		CommConf: config,
+		Inject: func(_ multistep.StateBag, password string) error {
+			injected = password
+			return nil
+		},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if injected == "" || injected != config.WinRMPassword || state.Get("winrm_password") != injected {
+		t.Fatalf("generated password was not propagated: %q / %q", injected, config.WinRMPassword)
+	}
+	if packersdk.LogSecretFilter.FilterString(injected) != "<sensitive>" {
+		t.Fatalf("generated password was not registered as a secret")
+	}
+
+	// A configured password is kept as is.
+	injected = ""
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if injected != "" {
+		t.Fatalf("Inject should not be called with a configured password")
+	}
+}
+
+func TestStepRotateWinRMPassword(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	config := &Config{Type: "winrm", WinRM: WinRM{WinRMUser: "Administrator", WinRMPassword: "old"}}
+
+	step := &StepRotateWinRMPassword{CommConf: config}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %v", action, state.Get("error"))
+	}
+	if config.WinRMPassword == "old" {
+		t.Fatalf("password was not rotated")
+	}
+	expected := `net user "Administrator" ` + config.WinRMPassword
+	if !comm.StartCalled || comm.StartCmd.Command != expected {
+		t.Fatalf("unexpected command: %#v", comm.StartCmd)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/fips"
+	winrmcmd "github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// fipsTransport is the WinRM transport used in FIPS mode. The transports of
+// the winrm package build their TLS configuration themselves, with the default
+// cipher suites of crypto/tls; this one restricts it with fips.ApplyTLS. It
+// only authenticates with basic authentication, NTLM not being allowed in
+// FIPS mode, and the credentials are the ones of the connection it is made
+// for, since the winrm client doesn't hand them to its transport.
+type fipsTransport struct {
+	proxyFunc func(*http.Request) (*url.URL, error)
+	user      string
+	password  string
+
+	url       string
+	transport http.RoundTripper
+}
+
+// fipsTransportDecorator returns a WinRM transport decorator restricting TLS
+// to the algorithms approved by FIPS 140, routing requests through the
+// proxies returned by proxyFunc and authenticating as user.
+func fipsTransportDecorator(proxyFunc func(*http.Request) (*url.URL, error), user, password string) func() winrmcmd.Transporter {
+	return func() winrmcmd.Transporter {
+		return &fipsTransport{proxyFunc: proxyFunc, user: user, password: password}
+	}
+}
+
+func (t *fipsTransport) Transport(endpoint *winrmcmd.Endpoint) error {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: endpoint.Insecure,
+		ServerName:         endpoint.TLSServerName,
+	}
+	if len(endpoint.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(endpoint.CACert) {
+			return errors.New("Unable to read certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	fips.ApplyTLS(tlsConfig)
+
+	scheme := "http"
+	if endpoint.HTTPS {
+		scheme = "https"
+	}
+	t.url = fmt.Sprintf("%s://%s/wsman", scheme, net.JoinHostPort(endpoint.Host, fmt.Sprint(endpoint.Port)))
+	t.transport = &http.Transport{
+		Proxy:           t.proxyFunc,
+		TLSClientConfig: tlsConfig,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ResponseHeaderTimeout: endpoint.Timeout,
+	}
+	return nil
+}
+
+func (t *fipsTransport) Post(_ *winrmcmd.Client, request *soap.SoapMessage) (string, error) {
+	req, err := http.NewRequest("POST", t.url, strings.NewReader(request.String()))
+	if err != nil {
+		return "", fmt.Errorf("impossible to create http request %w", err)
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	req.SetBasicAuth(t.user, t.password)
+	resp, err := (&http.Client{Transport: t.transport}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unknown error %w", err)
+	}
+	defer resp.Body.Close()
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "application/soap+xml") {
+		return "", fmt.Errorf("http response error: %d - invalid content type", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error while reading request body %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("http error %d: %s", resp.StatusCode, body)
+	}
+	return string(body), nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/fips"
+	winrmcmd "github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// testWinRMServer starts an HTTPS server answering the SOAP requests of the
+// packer user with the cipher suites of tlsConfig, and returns the endpoint
+// to reach it.
+func testWinRMServer(t *testing.T, tlsConfig *tls.Config) *winrmcmd.Endpoint {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "packer" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		_, _ = w.Write([]byte("<ok/>"))
+	}))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	host, p, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(p)
+	return &winrmcmd.Endpoint{
+		Host:          host,
+		Port:          port,
+		HTTPS:         true,
+		TLSServerName: "example.com",
+		CACert:        pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+	}
+}
+
+func testFIPSPost(t *testing.T, endpoint *winrmcmd.Endpoint) (string, error) {
+	transport := fipsTransportDecorator(nil, "packer", "secret")()
+	if err := transport.Transport(endpoint); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return transport.Post(nil, soap.NewMessage())
+}
+
+func TestFIPSTransport(t *testing.T) {
+	t.Setenv(fips.EnvVar, "true")
+
+	endpoint := testWinRMServer(t, &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	})
+	body, err := testFIPSPost(t, endpoint)
+	if err != nil || body != "<ok/>" {
+		t.Fatalf("body: %q, err: %v", body, err)
+	}
+
+	// A server only offering suites that are not approved is refused.
+	endpoint = testWinRMServer(t, &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256},
+	})
+	if _, err := testFIPSPost(t, endpoint); err == nil {
+		t.Fatal("expected the handshake to fail with a ChaCha20 only server")
+	}
+}
+
+func TestFIPSTransport_unauthorized(t *testing.T) {
+	endpoint := testWinRMServer(t, nil)
+	transport := fipsTransportDecorator(nil, "packer", "wrong")()
+	if err := transport.Transport(endpoint); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := transport.Post(nil, soap.NewMessage()); err == nil {
+		t.Fatal("expected an error with bad credentials")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// lookPath finds the programs compressing faster than the Go
+// implementations; tests replace it.
+var lookPath = exec.LookPath
+
+func init() {
+	Register("gzip", gzipBackend{})
+	Register("zstd", zstdBackend{})
+	Register("xz", xzBackend{})
+}
+
+// gzipBackend compresses with pigz, which compresses on several threads,
+// when it is installed, and with compress/gzip otherwise.
+type gzipBackend struct{}
+
+func (gzipBackend) Extension() string { return ".gz" }
+
+func (gzipBackend) NewWriter(ctx context.Context, w io.Writer, opts Options) (io.WriteCloser, error) {
+	if opts.Level < 0 || opts.Level > 9 {
+		return nil, fmt.Errorf("gzip: invalid level %d, expected 1 to 9", opts.Level)
+	}
+	if path, err := lookPath("pigz"); err == nil {
+		args := []string{"-c", "-p", strconv.Itoa(opts.threads())}
+		if opts.Level > 0 {
+			args = append(args, "-"+strconv.Itoa(opts.Level))
+		}
+		return startCommand(ctx, w, path, args...)
+	}
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+func (gzipBackend) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// zstdBackend compresses on Options.Threads goroutines.
+type zstdBackend struct{}
+
+func (zstdBackend) Extension() string { return ".zst" }
+
+func (zstdBackend) NewWriter(_ context.Context, w io.Writer, opts Options) (io.WriteCloser, error) {
+	if opts.Level < 0 || opts.Level > 22 {
+		return nil, fmt.Errorf("zstd: invalid level %d, expected 1 to 22", opts.Level)
+	}
+	zopts := []zstd.EOption{zstd.WithEncoderConcurrency(opts.threads())}
+	if opts.Level > 0 {
+		zopts = append(zopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.Level)))
+	}
+	return zstd.NewWriter(w, zopts...)
+}
+
+func (zstdBackend) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+// xzBackend compresses with the xz program, on several threads, when it is
+// installed, and with github.com/ulikunitz/xz otherwise, which ignores the
+// level and the threads.
+type xzBackend struct{}
+
+func (xzBackend) Extension() string { return ".xz" }
+
+func (xzBackend) NewWriter(ctx context.Context, w io.Writer, opts Options) (io.WriteCloser, error) {
+	if opts.Level < 0 || opts.Level > 9 {
+		return nil, fmt.Errorf("xz: invalid level %d, expected 1 to 9", opts.Level)
+	}
+	if path, err := lookPath("xz"); err == nil {
+		args := []string{"-c", "-T", strconv.Itoa(opts.threads())}
+		if opts.Level > 0 {
+			args = append(args, "-"+strconv.Itoa(opts.Level))
+		}
+		return startCommand(ctx, w, path, args...)
+	}
+	log.Printf("[DEBUG] xz is not installed, compressing on a single thread")
+	return xz.NewWriter(w)
+}
+
+func (xzBackend) NewReader(r io.Reader) (io.ReadCloser, error) {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(xr), nil
+}
+
+// commandWriter pipes what it is written to a program writing the result to
+// the writer of the stream.
+type commandWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr bytes.Buffer
+
+	waited  bool
+	waitErr error
+}
+
+func startCommand(ctx context.Context, w io.Writer, path string, args ...string) (*commandWriter, error) {
+	c := &commandWriter{cmd: exec.CommandContext(ctx, path, args...)}
+	c.cmd.Stdout = w
+	c.cmd.Stderr = &c.stderr
+	stdin, err := c.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	c.stdin = stdin
+	log.Printf("[DEBUG] Compressing with %s %s", path, strings.Join(args, " "))
+	if err := c.cmd.Start(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *commandWriter) Write(p []byte) (int, error) {
+	n, err := c.stdin.Write(p)
+	if err != nil {
+		// The program exited: its error tells why.
+		if waitErr := c.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close waits for the program to write the end of the stream.
+func (c *commandWriter) Close() error {
+	return c.wait()
+}
+
+func (c *commandWriter) wait() error {
+	if c.waited {
+		return c.waitErr
+	}
+	c.waited = true
+	c.stdin.Close()
+	if err := c.cmd.Wait(); err != nil {
+		c.waitErr = fmt.Errorf("%s: %s: %s", c.cmd.Path, err, strings.TrimSpace(c.stderr.String()))
+	}
+	return c.waitErr
+}
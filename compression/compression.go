@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package compression compresses artifacts with pluggable backends: gzip,
+// parallelized by pigz when it is installed, zstd, compressing on all the
+// CPUs, and xz, threaded by the xz program when it is installed. Builders
+// and post-processors compress with CompressStream, picking a backend by
+// name, or "auto" to let the host decide.
+package compression
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/iohelper"
+)
+
+// Auto is the name selecting the backend AutoSelect picks.
+const Auto = "auto"
+
+// Options tune a compression.
+type Options struct {
+	// Level is the compression level, in the range of the backend, like 1
+	// to 9 for gzip. The default level of the backend is used when 0.
+	Level int
+	// Threads is the number of threads compressing, the number of CPUs
+	// when 0. Backends that can't compress in parallel ignore it.
+	Threads int
+}
+
+// threads returns the number of threads to compress with.
+func (o Options) threads() int {
+	if o.Threads > 0 {
+		return o.Threads
+	}
+	return runtime.NumCPU()
+}
+
+// Backend is a compression format, and the implementations compressing to
+// and decompressing from it.
+type Backend interface {
+	// Extension is the extension of the files compressed by the backend,
+	// like ".gz".
+	Extension() string
+	// NewWriter returns a writer compressing what it is written to w, until
+	// it is closed or ctx is done. Closing it doesn't close w.
+	NewWriter(ctx context.Context, w io.Writer, opts Options) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	backendsLock sync.RWMutex
+	backends     = map[string]Backend{}
+	// backendOrder is the order backends were registered in.
+	backendOrder []string
+)
+
+// Register adds b to the backends, for plugins to add formats or to plug
+// hardware accelerated implementations in. It panics if a backend of the
+// same name is already registered.
+func Register(name string, b Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+	if _, ok := backends[name]; ok || name == Auto {
+		panic(fmt.Sprintf("compression: backend %q registered twice", name))
+	}
+	backends[name] = b
+	backendOrder = append(backendOrder, name)
+}
+
+// Lookup returns the backend registered as name, or the one AutoSelect picks
+// when name is "auto".
+func Lookup(name string) (Backend, bool) {
+	if name == Auto {
+		name = AutoSelect()
+	}
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+	b, ok := backends[name]
+	return b, ok
+}
+
+// Names returns the names of the backends, in the order they were
+// registered.
+func Names() []string {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+	return append([]string(nil), backendOrder...)
+}
+
+// AutoSelect returns the name of the backend fastest on this host: zstd,
+// which compresses on all the CPUs, when there are several, and gzip
+// otherwise.
+func AutoSelect() string {
+	if runtime.NumCPU() > 1 {
+		return "zstd"
+	}
+	return "gzip"
+}
+
+// CompressStream compresses src to dst with the backend registered as name,
+// or "auto", until ctx is done. It returns the number of bytes read from
+// src.
+func CompressStream(ctx context.Context, dst io.Writer, src io.Reader, name string, opts Options) (int64, error) {
+	b, ok := Lookup(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown compression %q, expected one of %v or %q", name, Names(), Auto)
+	}
+	w, err := b.NewWriter(ctx, dst, opts)
+	if err != nil {
+		return 0, err
+	}
+	n, err := iohelper.CancellableCopy(ctx, w, src)
+	if err != nil {
+		w.Close()
+		return n, err
+	}
+	return n, w.Close()
+}
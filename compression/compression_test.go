@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package compression
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// withoutPrograms makes the backends use their Go implementations.
+func withoutPrograms(t *testing.T) {
+	old := lookPath
+	lookPath = func(file string) (string, error) { return "", exec.ErrNotFound }
+	t.Cleanup(func() { lookPath = old })
+}
+
+func testData() []byte {
+	return bytes.Repeat([]byte("packer compresses disk images\n"), 10000)
+}
+
+func roundTrip(t *testing.T, name string, opts Options) {
+	t.Helper()
+	data := testData()
+	var compressed bytes.Buffer
+	n, err := CompressStream(context.Background(), &compressed, bytes.NewReader(data), name, opts)
+	if err != nil {
+		t.Fatalf("%s: err: %s", name, err)
+	}
+	if n != int64(len(data)) {
+		t.Fatalf("%s: read %d bytes, expected %d", name, n, len(data))
+	}
+	if compressed.Len() >= len(data) {
+		t.Fatalf("%s: %d bytes compressed to %d", name, len(data), compressed.Len())
+	}
+
+	b, _ := Lookup(name)
+	r, err := b.NewReader(&compressed)
+	if err != nil {
+		t.Fatalf("%s: err: %s", name, err)
+	}
+	defer r.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: err: %s", name, err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("%s: decompressed data differs", name)
+	}
+}
+
+func TestCompressStream_goImplementations(t *testing.T) {
+	withoutPrograms(t)
+	for _, name := range []string{"gzip", "zstd", "xz"} {
+		roundTrip(t, name, Options{})
+		roundTrip(t, name, Options{Level: 9, Threads: 2})
+	}
+}
+
+func TestCompressStream_programs(t *testing.T) {
+	tested := 0
+	for name, program := range map[string]string{"gzip": "pigz", "xz": "xz"} {
+		if _, err := exec.LookPath(program); err != nil {
+			continue
+		}
+		tested++
+		roundTrip(t, name, Options{Level: 6, Threads: 2})
+	}
+	if tested == 0 {
+		t.Skip("neither pigz nor xz are installed")
+	}
+}
+
+func TestCompressStream_programFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "pigz")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho 'pigz: out of cheese' >&2\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := lookPath
+	lookPath = func(string) (string, error) { return script, nil }
+	defer func() { lookPath = old }()
+
+	_, err := CompressStream(context.Background(), io.Discard, bytes.NewReader(testData()), "gzip", Options{})
+	if err == nil || !strings.Contains(err.Error(), "out of cheese") {
+		t.Fatalf("expected the error of the program, got %v", err)
+	}
+}
+
+func TestCompressStream_auto(t *testing.T) {
+	withoutPrograms(t)
+	roundTrip(t, Auto, Options{})
+
+	b, ok := Lookup(Auto)
+	if !ok {
+		t.Fatal("no backend for auto")
+	}
+	expected, _ := Lookup(AutoSelect())
+	if b != expected {
+		t.Fatalf("auto is %#v, expected %#v", b, expected)
+	}
+}
+
+func TestCompressStream_errors(t *testing.T) {
+	withoutPrograms(t)
+	src := bytes.NewReader(testData())
+	if _, err := CompressStream(context.Background(), io.Discard, src, "lzma", Options{}); err == nil {
+		t.Fatal("expected an error for an unknown backend")
+	}
+	if _, err := CompressStream(context.Background(), io.Discard, src, "gzip", Options{Level: 10}); err == nil {
+		t.Fatal("expected an error for an invalid level")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := CompressStream(ctx, io.Discard, src, "zstd", Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the copy to be cancelled, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	names := Names()
+	if len(names) < 3 || names[0] != "gzip" || names[1] != "zstd" || names[2] != "xz" {
+		t.Fatalf("bad names: %v", names)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering gzip twice to panic")
+		}
+	}()
+	Register("gzip", gzipBackend{})
+}
@@ -0,0 +1,151 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// concurrencyProbe records the highest number of tasks running at the same
+// time.
+type concurrencyProbe struct {
+	running, max int32
+}
+
+func (p *concurrencyProbe) task(ctx context.Context) error {
+	n := atomic.AddInt32(&p.running, 1)
+	defer atomic.AddInt32(&p.running, -1)
+	for {
+		max := atomic.LoadInt32(&p.max)
+		if n <= max || atomic.CompareAndSwapInt32(&p.max, max, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return nil
+}
+
+func TestGroup_limit(t *testing.T) {
+	probe := new(concurrencyProbe)
+	g := WithContext(context.Background())
+	g.Limit = 2
+	for i := 0; i < 8; i++ {
+		g.Go("upload", probe.task)
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if probe.max != 2 {
+		t.Fatalf("bad concurrency: %d", probe.max)
+	}
+}
+
+func TestGroup_sharedSemaphore(t *testing.T) {
+	probe := new(concurrencyProbe)
+	sem := SharedSemaphore("test-uploads", 3)
+	if SharedSemaphore("test-uploads", 10) != sem || sem.Size() != 3 {
+		t.Fatal("the shared semaphore should be created once")
+	}
+
+	// Two groups, like two steps, share the semaphore.
+	var groups []*Group
+	for i := 0; i < 2; i++ {
+		g := &Group{Semaphore: sem}
+		for j := 0; j < 4; j++ {
+			g.Go("upload", probe.task)
+		}
+		groups = append(groups, g)
+	}
+	for _, g := range groups {
+		if err := g.Wait(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if probe.max != 3 {
+		t.Fatalf("bad concurrency: %d", probe.max)
+	}
+}
+
+func TestGroup_errors(t *testing.T) {
+	var buf strings.Builder
+	ui := &packersdk.BasicUi{Writer: &buf, ErrorWriter: &buf}
+	g := &Group{Ui: ui}
+	g.Go("copy to eu-west-1", func(context.Context) error { return errors.New("quota exceeded") })
+	g.Go("copy to us-east-1", func(context.Context) error { return nil })
+	g.Go("copy to ap-south-1", func(context.Context) error { return errors.New("access denied") })
+
+	err := g.Wait()
+	var multi *packersdk.MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("bad error: %v", err)
+	}
+	for _, want := range []string{"copy to eu-west-1: quota exceeded", "copy to ap-south-1: access denied"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("the error should contain %q: %s", want, err)
+		}
+	}
+	if !strings.Contains(buf.String(), "copy to eu-west-1 failed: quota exceeded") {
+		t.Fatalf("the failures should be reported to the Ui: %q", buf.String())
+	}
+}
+
+func TestGroup_failFast(t *testing.T) {
+	g := WithContext(context.Background())
+	g.FailFast = true
+	g.Go("first", func(context.Context) error {
+		return errors.New("boom")
+	})
+	for i := 0; i < 5; i++ {
+		g.Go("next", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}
+	err := g.Wait()
+	var multi *packersdk.MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || !strings.Contains(err.Error(), "first: boom") {
+		t.Fatalf("only the failure should be returned: %v", err)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	var sum int32
+	err := ForEach(context.Background(), 3, 10, func(_ context.Context, i int) error {
+		atomic.AddInt32(&sum, int32(i))
+		return nil
+	})
+	if err != nil || sum != 45 {
+		t.Fatalf("bad sum %d: %v", sum, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = ForEach(ctx, 1, 2, func(context.Context, int) error { return nil })
+	if !errors.Is(err.(*packersdk.MultiError).Errors[0], context.Canceled) {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(1)
+	if !s.TryAcquire() || s.TryAcquire() {
+		t.Fatal("the semaphore should have one slot")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("bad error: %v", err)
+	}
+	s.Release()
+	if err := s.Acquire(context.Background()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package concurrency
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// sharedQueueName is the name of the SharedQueue of Config.
+const sharedQueueName = "cloud_operations"
+
+// Config throttles the cloud operations of the builds of a plugin, so that
+// building many images at once doesn't get the calls to the API of the
+// cloud throttled:
+//
+//	max_concurrent_operations            = 8
+//	max_concurrent_operations_per_region = 4
+//	region_max_concurrent_operations = {
+//	  "ap-southeast-3" = 1
+//	}
+//
+// Embed it in your builder config using the `mapstructure:",squash"` struct
+// tag, and run the operations through the Queue it returns.
+type Config struct {
+	// The number of cloud operations, like image copies or uploads, the
+	// builds of the plugin run at the same time. Defaults to `0`, no limit.
+	MaxConcurrentOperations int `mapstructure:"max_concurrent_operations" required:"false"`
+	// The number of cloud operations the builds of the plugin run at the
+	// same time in each region. Defaults to `0`, no limit.
+	MaxConcurrentOperationsPerRegion int `mapstructure:"max_concurrent_operations_per_region" required:"false"`
+	// The number of cloud operations the builds of the plugin run at the
+	// same time in the given regions, overriding
+	// `max_concurrent_operations_per_region`.
+	RegionMaxConcurrentOperations map[string]int `mapstructure:"region_max_concurrent_operations" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	if c.MaxConcurrentOperations < 0 {
+		errs = append(errs, fmt.Errorf("max_concurrent_operations must be positive"))
+	}
+	if c.MaxConcurrentOperationsPerRegion < 0 {
+		errs = append(errs, fmt.Errorf("max_concurrent_operations_per_region must be positive"))
+	}
+	for region, n := range c.RegionMaxConcurrentOperations {
+		if n < 0 {
+			errs = append(errs, fmt.Errorf("region_max_concurrent_operations[%q] must be positive", region))
+		}
+	}
+	return errs
+}
+
+// Limits returns the limits of the queue of c.
+func (c *Config) Limits() QueueLimits {
+	return QueueLimits{
+		MaxConcurrent:       c.MaxConcurrentOperations,
+		MaxConcurrentPerKey: c.MaxConcurrentOperationsPerRegion,
+		KeyLimits:           c.RegionMaxConcurrentOperations,
+	}
+}
+
+// Queue returns the queue the builds of the plugin process run their cloud
+// operations through, keyed by region. It is created with the limits of the
+// config of the first build calling it; the builds of a process share the
+// same plugin configuration.
+func (c *Config) Queue() *Queue {
+	return SharedQueue(sharedQueueName, c.Limits())
+}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package concurrency runs the operations of builders in parallel, like the
+// uploads of disks or the copies of snapshots to other regions, with a
+// bounded number of them at the same time, cancellation and the errors of
+// every operation reported. A Queue throttles the cloud operations of all
+// the builds of a plugin process together.
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// A Group runs tasks concurrently, like errgroup.Group, and waits for them.
+// Unlike errgroup.Group, it returns the errors of every task that failed,
+// and can report them to a Ui as they happen.
+type Group struct {
+	// Ui, when set, is told about the tasks that fail.
+	Ui packersdk.Ui
+	// Limit is the number of tasks running at the same time, unlimited when
+	// 0.
+	Limit int
+	// Semaphore, when set, limits the tasks of the group together with the
+	// other operations it limits, on top of Limit.
+	Semaphore *Semaphore
+	// FailFast cancels the context of the tasks once one fails.
+	FailFast bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	limit  *Semaphore
+	wg     sync.WaitGroup
+
+	l    sync.Mutex
+	errs []error
+	// failed is set once a task failed with FailFast set.
+	failed bool
+}
+
+// WithContext returns a group whose tasks run with a context derived from
+// ctx, cancelled when Wait returns, or a task fails with FailFast set.
+func WithContext(ctx context.Context) *Group {
+	g := &Group{}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	return g
+}
+
+func (g *Group) init() {
+	g.once.Do(func() {
+		if g.ctx == nil {
+			g.ctx, g.cancel = context.WithCancel(context.Background())
+		}
+		if g.Limit > 0 {
+			g.limit = NewSemaphore(g.Limit)
+		}
+	})
+}
+
+// Go runs fn in a new goroutine, once the limits of the group let it.
+// name describes the task in its error, like "copy to eu-west-1". Tasks
+// not started yet when the context of the group is done fail with its
+// error, without running.
+func (g *Group) Go(name string, fn func(ctx context.Context) error) {
+	g.init()
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		g.fail(name, g.run(fn))
+	}()
+}
+
+func (g *Group) run(fn func(ctx context.Context) error) error {
+	for _, s := range []*Semaphore{g.limit, g.Semaphore} {
+		if s == nil {
+			continue
+		}
+		if err := s.Acquire(g.ctx); err != nil {
+			return err
+		}
+		defer s.Release()
+	}
+	if err := g.ctx.Err(); err != nil {
+		return err
+	}
+	return fn(g.ctx)
+}
+
+// fail records the error of the task name, if any.
+func (g *Group) fail(name string, err error) {
+	if err == nil {
+		return
+	}
+	g.l.Lock()
+	if g.failed && errors.Is(err, context.Canceled) {
+		// The task was cancelled by the failure of another one.
+		g.l.Unlock()
+		return
+	}
+	g.failed = g.failed || g.FailFast
+	g.errs = append(g.errs, fmt.Errorf("%s: %w", name, err))
+	g.l.Unlock()
+	if g.Ui != nil {
+		g.Ui.Error(i18n.Sprintf("%s failed: %s", name, err))
+	}
+	if g.FailFast {
+		g.cancel()
+	}
+}
+
+// Wait waits for the tasks to return, and returns their errors, as a
+// *packersdk.MultiError, nil when they all succeeded.
+func (g *Group) Wait() error {
+	g.init()
+	g.wg.Wait()
+	g.cancel()
+	g.l.Lock()
+	defer g.l.Unlock()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	return packersdk.MultiErrorAppend(nil, g.errs...)
+}
+
+// ForEach calls fn for each index from 0 to n, with at most limit calls
+// running at the same time, unlimited when 0, and returns their errors like
+// Group.Wait. It is a bounded worker pool for the slices of a step, like
+// the regions to copy an image to.
+func ForEach(ctx context.Context, limit, n int, fn func(ctx context.Context, i int) error) error {
+	g := WithContext(ctx)
+	g.Limit = limit
+	for i := 0; i < n; i++ {
+		i := i
+		g.Go(fmt.Sprintf("item %d", i), func(ctx context.Context) error {
+			return fn(ctx, i)
+		})
+	}
+	return g.Wait()
+}
@@ -0,0 +1,258 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package concurrency
+
+import (
+	"context"
+	"sync"
+)
+
+// QueueLimits are the limits of a Queue. A limit of 0 is no limit.
+type QueueLimits struct {
+	// MaxConcurrent is the number of operations running at the same time,
+	// all keys together.
+	MaxConcurrent int
+	// MaxConcurrentPerKey is the number of operations running at the same
+	// time on each key, like a region.
+	MaxConcurrentPerKey int
+	// KeyLimits override MaxConcurrentPerKey for some keys, like a region
+	// whose API throttles more than the others.
+	KeyLimits map[string]int
+}
+
+func (l QueueLimits) keyLimit(key string) int {
+	if n, ok := l.KeyLimits[key]; ok {
+		return n
+	}
+	return l.MaxConcurrentPerKey
+}
+
+// A Queue throttles the cloud operations of the builds of a plugin process,
+// so that the dozens of builds Packer may start at once don't get their API
+// calls throttled. Operations wait in line for the limits to let them run.
+// The builds waiting take turns, so that a build queuing many operations
+// doesn't starve the others, and an operation on a key at its limit doesn't
+// hold up the operations on the other keys.
+type Queue struct {
+	limits QueueLimits
+
+	mu           sync.Mutex
+	running      int
+	runningByKey map[string]int
+	// waiting holds the operations waiting by owner, in order.
+	waiting map[string][]*queueTicket
+	// owners is the order owners take turns in, and next the index of the
+	// owner whose turn it is.
+	owners []string
+	next   int
+}
+
+type queueTicket struct {
+	key     string
+	ready   chan struct{}
+	granted bool
+}
+
+// NewQueue returns a queue with limits.
+func NewQueue(limits QueueLimits) *Queue {
+	return &Queue{
+		limits:       limits,
+		runningByKey: map[string]int{},
+		waiting:      map[string][]*queueTicket{},
+	}
+}
+
+var sharedQueues = struct {
+	sync.Mutex
+	queues map[string]*Queue
+}{queues: map[string]*Queue{}}
+
+// SharedQueue returns the queue name of the process, created with limits by
+// the first call, so that the builds of a plugin, which each have their own
+// builder and config, are throttled together.
+func SharedQueue(name string, limits QueueLimits) *Queue {
+	sharedQueues.Lock()
+	defer sharedQueues.Unlock()
+	q, ok := sharedQueues.queues[name]
+	if !ok {
+		q = NewQueue(limits)
+		sharedQueues.queues[name] = q
+	}
+	return q
+}
+
+// Acquire waits until the limits let an operation of owner on key run, or
+// ctx is done. owner identifies whose turn it is, typically the name of the
+// build, and key is what the per-key limits apply to, typically the region;
+// both can be empty. The returned function releases the operation; calling
+// it more than once is a no-op.
+func (q *Queue) Acquire(ctx context.Context, owner, key string) (func(), error) {
+	t := &queueTicket{key: key, ready: make(chan struct{})}
+	q.mu.Lock()
+	if len(q.waiting) == 0 && q.fits(key) {
+		q.start(t)
+		q.mu.Unlock()
+	} else {
+		if _, ok := q.waiting[owner]; !ok {
+			q.owners = append(q.owners, owner)
+		}
+		q.waiting[owner] = append(q.waiting[owner], t)
+		// The operations waiting may be held by the limit of their key
+		// only.
+		q.dispatch()
+		q.mu.Unlock()
+
+		select {
+		case <-t.ready:
+		case <-ctx.Done():
+			q.mu.Lock()
+			if t.granted {
+				// The operation was let run as ctx was done.
+				q.finish(key)
+			} else {
+				q.remove(owner, t)
+			}
+			q.dispatch()
+			q.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.finish(key)
+			q.dispatch()
+		})
+	}, nil
+}
+
+// Do runs fn once the limits let it, like Acquire.
+func (q *Queue) Do(ctx context.Context, owner, key string, fn func(ctx context.Context) error) error {
+	release, err := q.Acquire(ctx, owner, key)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx)
+}
+
+// QueueStats are the numbers of operations of a Queue.
+type QueueStats struct {
+	Running int
+	Waiting int
+}
+
+// Stats returns the numbers of operations running and waiting in q.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := QueueStats{Running: q.running}
+	for _, tickets := range q.waiting {
+		s.Waiting += len(tickets)
+	}
+	return s
+}
+
+// fits tells whether the limits let one more operation run on key.
+func (q *Queue) fits(key string) bool {
+	if q.limits.MaxConcurrent > 0 && q.running >= q.limits.MaxConcurrent {
+		return false
+	}
+	if n := q.limits.keyLimit(key); n > 0 && q.runningByKey[key] >= n {
+		return false
+	}
+	return true
+}
+
+func (q *Queue) start(t *queueTicket) {
+	q.running++
+	q.runningByKey[t.key]++
+	t.granted = true
+	close(t.ready)
+}
+
+func (q *Queue) finish(key string) {
+	q.running--
+	if q.runningByKey[key]--; q.runningByKey[key] == 0 {
+		delete(q.runningByKey, key)
+	}
+}
+
+// dispatch lets the waiting operations the limits allow run, the owners
+// taking turns.
+func (q *Queue) dispatch() {
+	for len(q.owners) > 0 {
+		if q.limits.MaxConcurrent > 0 && q.running >= q.limits.MaxConcurrent {
+			return
+		}
+		started := false
+		for i := 0; i < len(q.owners); i++ {
+			idx := (q.next + i) % len(q.owners)
+			owner := q.owners[idx]
+			t := q.firstFitting(owner)
+			if t == nil {
+				continue
+			}
+			q.remove(owner, t)
+			q.start(t)
+			// The next owner in line has the next turn; remove may have
+			// dropped this one from the line.
+			if idx < len(q.owners) && q.owners[idx] == owner {
+				idx++
+			}
+			q.next = idx
+			if len(q.owners) > 0 {
+				q.next %= len(q.owners)
+			}
+			started = true
+			break
+		}
+		if !started {
+			return
+		}
+	}
+}
+
+func (q *Queue) firstFitting(owner string) *queueTicket {
+	for _, t := range q.waiting[owner] {
+		if q.fits(t.key) {
+			return t
+		}
+	}
+	return nil
+}
+
+// remove takes t out of the line of owner, and owner out of the owners
+// when it has nothing else waiting.
+func (q *Queue) remove(owner string, t *queueTicket) {
+	tickets := q.waiting[owner]
+	for i := range tickets {
+		if tickets[i] == t {
+			tickets = append(tickets[:i:i], tickets[i+1:]...)
+			break
+		}
+	}
+	if len(tickets) > 0 {
+		q.waiting[owner] = tickets
+		return
+	}
+	delete(q.waiting, owner)
+	for i, o := range q.owners {
+		if o == owner {
+			q.owners = append(q.owners[:i:i], q.owners[i+1:]...)
+			if q.next > i {
+				q.next--
+			}
+			break
+		}
+	}
+	if len(q.owners) > 0 {
+		q.next %= len(q.owners)
+	} else {
+		q.next = 0
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// maxTracker records the highest number of operations running at once, by
+// key.
+type maxTracker struct {
+	sync.Mutex
+	running map[string]int
+	max     map[string]int
+}
+
+func (m *maxTracker) run(key string) {
+	m.Lock()
+	m.running[key]++
+	m.running[""]++
+	for _, k := range []string{key, ""} {
+		if m.running[k] > m.max[k] {
+			m.max[k] = m.running[k]
+		}
+	}
+	m.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	m.Lock()
+	m.running[key]--
+	m.running[""]--
+	m.Unlock()
+}
+
+func TestQueue_limits(t *testing.T) {
+	q := NewQueue(QueueLimits{MaxConcurrent: 3, MaxConcurrentPerKey: 2, KeyLimits: map[string]int{"a": 1}})
+	m := &maxTracker{running: map[string]int{}, max: map[string]int{}}
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		key := []string{"a", "b", "c"}[i%3]
+		owner := fmt.Sprintf("build-%d", i%4)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := q.Do(context.Background(), owner, key, func(context.Context) error {
+				m.run(key)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if m.max[""] > 3 || m.max["a"] > 1 || m.max["b"] > 2 || m.max["c"] > 2 {
+		t.Fatalf("limits exceeded: %v", m.max)
+	}
+	if s := q.Stats(); s != (QueueStats{}) {
+		t.Fatalf("bad stats: %#v", s)
+	}
+}
+
+// waitForWaiting waits for n operations to wait in q.
+func waitForWaiting(t *testing.T, q *Queue, n int) {
+	t.Helper()
+	for i := 0; q.Stats().Waiting != n; i++ {
+		if i > 1000 {
+			t.Fatalf("expected %d operations waiting, got %d", n, q.Stats().Waiting)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueue_fairness(t *testing.T) {
+	q := NewQueue(QueueLimits{MaxConcurrent: 1})
+	release, err := q.Acquire(context.Background(), "a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	queue := func(owner string, n int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Do(context.Background(), owner, "", func(context.Context) error {
+				mu.Lock()
+				order = append(order, owner)
+				mu.Unlock()
+				return nil
+			})
+		}()
+		waitForWaiting(t, q, n)
+	}
+	queue("a", 1)
+	queue("a", 2)
+	queue("a", 3)
+	queue("b", 4)
+	release()
+	wg.Wait()
+
+	// b doesn't wait for all the operations a queued before it.
+	if expected := []string{"a", "b", "a", "a"}; !reflect.DeepEqual(order, expected) {
+		t.Fatalf("expected %v, got %v", expected, order)
+	}
+}
+
+func TestQueue_keyDoesNotBlockOthers(t *testing.T) {
+	q := NewQueue(QueueLimits{MaxConcurrentPerKey: 1})
+	release, _ := q.Acquire(context.Background(), "build", "eu-west-1")
+	defer release()
+
+	done := make(chan struct{})
+	go func() {
+		q.Do(context.Background(), "build", "eu-west-1", func(context.Context) error { return nil })
+		close(done)
+	}()
+	waitForWaiting(t, q, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := q.Do(ctx, "build", "us-east-1", func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("an operation on another region should not wait: %s", err)
+	}
+
+	release()
+	<-done
+}
+
+func TestQueue_cancel(t *testing.T) {
+	q := NewQueue(QueueLimits{MaxConcurrent: 1})
+	release, _ := q.Acquire(context.Background(), "a", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := q.Acquire(ctx, "b", "")
+		errc <- err
+	}()
+	waitForWaiting(t, q, 1)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the acquisition to be cancelled, got %v", err)
+	}
+	if s := q.Stats(); s.Waiting != 0 || s.Running != 1 {
+		t.Fatalf("bad stats: %#v", s)
+	}
+
+	release()
+	release()
+	if s := q.Stats(); s.Running != 0 {
+		t.Fatalf("releasing twice should be a no-op, got %#v", s)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := &Config{MaxConcurrentOperations: -1, RegionMaxConcurrentOperations: map[string]int{"eu-west-1": -2}}
+	if errs := c.Prepare(nil); len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+
+	c = &Config{MaxConcurrentOperations: 4, MaxConcurrentOperationsPerRegion: 2}
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	if c.Queue() != SharedQueue(sharedQueueName, QueueLimits{}) {
+		t.Fatal("the queue should be shared by the process")
+	}
+	if limits := c.Queue().limits; limits.MaxConcurrent != 4 || limits.MaxConcurrentPerKey != 2 {
+		t.Fatalf("bad limits: %#v", limits)
+	}
+}
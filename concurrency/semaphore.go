@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package concurrency
+
+import (
+	"context"
+	"sync"
+)
+
+// A Semaphore limits the number of operations running at the same time,
+// like the uploads of a builder. Unlike the limit of a Group, it can be
+// shared by several groups and steps.
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// NewSemaphore returns a semaphore letting n operations run at the same
+// time, at least one.
+func NewSemaphore(n int) *Semaphore {
+	if n < 1 {
+		n = 1
+	}
+	return &Semaphore{slots: make(chan struct{}, n)}
+}
+
+// Size returns the number of operations the semaphore lets run at the same
+// time.
+func (s *Semaphore) Size() int {
+	return cap(s.slots)
+}
+
+// Acquire waits for a slot, until ctx is done. Every successful Acquire
+// must be followed by a Release.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes a slot if there is one free, and tells whether it did.
+func (s *Semaphore) TryAcquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees the slot of an operation.
+func (s *Semaphore) Release() {
+	select {
+	case <-s.slots:
+	default:
+		panic("concurrency: Release without Acquire")
+	}
+}
+
+var shared = struct {
+	sync.Mutex
+	semaphores map[string]*Semaphore
+}{semaphores: map[string]*Semaphore{}}
+
+// SharedSemaphore returns the semaphore name of the process, created with
+// the size n by the first call, so that the steps of a plugin limit their
+// operations together without passing the semaphore around. For example,
+// every step uploading to a cloud can use SharedSemaphore("uploads", 4).
+func SharedSemaphore(name string, n int) *Semaphore {
+	shared.Lock()
+	defer shared.Unlock()
+	s, ok := shared.semaphores[name]
+	if !ok {
+		s = NewSemaphore(n)
+		shared.semaphores[name] = s
+	}
+	return s
+}
@@ -0,0 +1,268 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package configdigest serializes prepared configurations in a canonical
+// form, and digests it, to cache what a build computes from its
+// configuration, record it in a manifest, or tell whether it changed since
+// the last build.
+//
+// The canonical form is JSON, with the fields named after their mapstructure
+// tag, and objects sorted by key. Unset fields are left out, so that adding a
+// field to a configuration does not change the digest of the configurations
+// that don't set it. Secrets are left out too:
+//
+//   - the fields tagged `sensitive:"true"`,
+//   - the values of the sensitive variables of an embedded
+//     common.PackerConfig, wherever they appear.
+package configdigest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+)
+
+// SensitiveTag is the struct tag marking the fields left out of the canonical
+// form, with the value "true", the same tag that registers the values of the
+// fields with the secret filter.
+const SensitiveTag = config.SensitiveTag
+
+// redacted replaces the values of the sensitive variables.
+const redacted = "<sensitive>"
+
+var packerConfigType = reflect.TypeOf(common.PackerConfig{})
+
+// Canonical returns the canonical JSON form of config, usually a pointer to
+// the configuration struct of a component once prepared.
+func Canonical(config interface{}) ([]byte, error) {
+	w := &walker{secrets: sensitiveValues(reflect.ValueOf(config))}
+	v, err := w.walk(reflect.ValueOf(config))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Digest returns the SHA-256 digest of the canonical form of config, written
+// `sha256:<hex>` like the digests of the manifest package.
+func Digest(config interface{}) (string, error) {
+	b, err := Canonical(config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+type walker struct {
+	secrets []string
+}
+
+func (w *walker) walk(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if m, ok := marshaler(v); ok {
+			return m()
+		}
+		return w.walk(v.Elem())
+	}
+	if m, ok := marshaler(v); ok {
+		return m()
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return v.Interface(), nil
+	case reflect.String:
+		return w.redact(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return w.redact(string(v.Bytes())), nil
+		}
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			e, err := w.walk(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = e
+		}
+		return res, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		res := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			e, err := w.walk(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			res[w.redact(fmt.Sprint(iter.Key().Interface()))] = e
+		}
+		return res, nil
+	case reflect.Struct:
+		res := map[string]interface{}{}
+		if err := w.fields(v, res); err != nil {
+			return nil, err
+		}
+		return res, nil
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported configuration value of type %s", v.Type())
+}
+
+// fields adds the set fields of the struct v to res, the squashed ones in
+// place.
+func (w *walker) fields(v reflect.Value, res map[string]interface{}) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get(SensitiveTag) == "true" {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.IsZero() {
+			continue
+		}
+
+		squash := strings.Contains(opts, "squash") || (field.Anonymous && name == "")
+		if squash && fv.Kind() == reflect.Ptr {
+			fv = fv.Elem()
+		}
+		if squash && fv.Kind() == reflect.Struct {
+			if err := w.fields(fv, res); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if t == packerConfigType && field.Name == "PackerUserVars" {
+			fv = reflect.ValueOf(publicVariables(v.Interface().(common.PackerConfig)))
+		}
+		if name == "" {
+			name = field.Name
+		}
+		e, err := w.walk(fv)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		res[name] = e
+	}
+	return nil
+}
+
+// publicVariables returns the user variables of config that are not
+// sensitive.
+func publicVariables(config common.PackerConfig) map[string]string {
+	vars := make(map[string]string, len(config.PackerUserVars))
+	for name, value := range config.PackerUserVars {
+		if !contains(config.PackerSensitiveVars, name) {
+			vars[name] = value
+		}
+	}
+	return vars
+}
+
+// redact replaces the secrets in s, which may have been interpolated in any
+// field.
+func (w *walker) redact(s string) string {
+	for _, secret := range w.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// sensitiveValues returns the values of the sensitive variables of the
+// common.PackerConfig squashed in the configuration v.
+func sensitiveValues(v reflect.Value) []string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	if v.Type() == packerConfigType {
+		config := v.Interface().(common.PackerConfig)
+		var secrets []string
+		for _, name := range config.PackerSensitiveVars {
+			if value := config.PackerUserVars[name]; value != "" {
+				secrets = append(secrets, value)
+			}
+		}
+		return secrets
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.IsExported() && (field.Anonymous || strings.Contains(field.Tag.Get("mapstructure"), "squash")) {
+			if secrets := sensitiveValues(v.Field(i)); secrets != nil {
+				return secrets
+			}
+		}
+	}
+	return nil
+}
+
+// marshaler returns how to encode v when it knows how to encode itself, like
+// time.Time.
+func marshaler(v reflect.Value) (func() (interface{}, error), bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+	switch m := v.Interface().(type) {
+	case encoding.TextMarshaler:
+		return func() (interface{}, error) {
+			b, err := m.MarshalText()
+			return string(b), err
+		}, true
+	case json.Marshaler:
+		return func() (interface{}, error) {
+			b, err := m.MarshalJSON()
+			return json.RawMessage(b), err
+		}, true
+	}
+	return nil, false
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
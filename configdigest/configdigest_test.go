@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package configdigest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+)
+
+type testConfig struct {
+	common.PackerConfig `mapstructure:",squash"`
+	Comm                communicator.Config `mapstructure:",squash"`
+
+	Region   string            `mapstructure:"region"`
+	Timeout  time.Duration     `mapstructure:"timeout"`
+	Tags     map[string]string `mapstructure:"tags"`
+	Disks    []testDisk        `mapstructure:"disk"`
+	Token    string            `mapstructure:"token" sensitive:"true"`
+	Internal string            `mapstructure:"-"`
+
+	ctx struct{}
+}
+
+type testDisk struct {
+	Size int `mapstructure:"size"`
+}
+
+func TestCanonical(t *testing.T) {
+	c := &testConfig{
+		PackerConfig: common.PackerConfig{
+			PackerBuildName:     "ubuntu",
+			PackerUserVars:      map[string]string{"region": "eu-west-1", "password": "hunter2"},
+			PackerSensitiveVars: []string{"password"},
+		},
+		Comm: communicator.Config{
+			Type: "ssh",
+			SSH:  communicator.SSH{SSHUsername: "user-hunter2", SSHPassword: "hunter2"},
+		},
+		Region:   "eu-west-1",
+		Timeout:  5 * time.Minute,
+		Tags:     map[string]string{"b": "2", "a": "1"},
+		Disks:    []testDisk{{Size: 10}, {}},
+		Token:    "secret",
+		Internal: "ignored",
+	}
+	b, err := Canonical(c)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `{"communicator":"ssh","disk":[{"size":10},{}],"packer_build_name":"ubuntu",` +
+		`"packer_sensitive_variables":["password"],"packer_user_variables":{"region":"eu-west-1"},` +
+		`"region":"eu-west-1","ssh_username":"user-<sensitive>","tags":{"a":"1","b":"2"},"timeout":300000000000}`
+	if diff := cmp.Diff(expected, string(b)); diff != "" {
+		t.Fatalf("unexpected canonical form: %s", diff)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	a := &testConfig{Region: "eu-west-1", Tags: map[string]string{"a": "1", "b": "2"}, Token: "one"}
+	digest, err := Digest(a)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	b := &testConfig{Region: "eu-west-1", Tags: map[string]string{"b": "2", "a": "1"}, Token: "two"}
+	if other, _ := Digest(b); other != digest {
+		t.Fatalf("secrets and ordering should not change the digest: %s != %s", other, digest)
+	}
+	b.Region = "us-east-1"
+	if other, _ := Digest(b); other == digest {
+		t.Fatal("a changed configuration should change the digest")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package featureflag
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Config sets the feature flags of a plugin from the configuration of a
+// component. Embedding it with `mapstructure:",squash"` gives the component a
+// `features` option. The flags are set for the whole plugin process, over
+// their environment variables.
+type Config struct {
+	// The feature flags to set, by name, like
+	// `features = { "parallel-upload" = "true" }`. The flags of a plugin are
+	// listed by `packer-plugin-<name> describe`, and can also be set with a
+	// `PACKER_PLUGIN_FEATURE_<NAME>` environment variable.
+	Features map[string]string `mapstructure:"features"`
+}
+
+// Prepare sets the flags of Features, and fails for the flags that don't
+// exist or whose value doesn't parse.
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	names := make([]string, 0, len(c.Features))
+	for name := range c.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if _, ok := Lookup(name); !ok {
+			err := fmt.Errorf("features: unknown feature flag %q", name)
+			if suggestion := didyoumean.NameSuggestion(name, registeredNames()); suggestion != "" {
+				err = fmt.Errorf("%s, did you mean %q?", err, suggestion)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if err := Set(name, c.Features[name]); err != nil {
+			errs = append(errs, fmt.Errorf("features: %s", err))
+		}
+	}
+	return errs
+}
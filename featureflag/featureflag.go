@@ -0,0 +1,323 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package featureflag lets plugins gate experimental or deprecated behavior
+// behind flags users opt in to, or out of, consistently: with the `features`
+// option of the components embedding Config, or a
+// PACKER_PLUGIN_FEATURE_<NAME> environment variable.
+//
+// Plugins register their flags from an init function:
+//
+//	func init() {
+//		featureflag.Register(featureflag.Flag{
+//			Name:        "parallel-upload",
+//			Description: "Upload the files of provisioners in parallel.",
+//			Kind:        featureflag.KindBool,
+//			Default:     "false",
+//			Stage:       featureflag.Experimental,
+//		})
+//	}
+//
+// and query them with the accessor of their kind, like
+// featureflag.Bool("parallel-upload"). The flags of a plugin are listed by
+// its describe command.
+package featureflag
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Kind is the type of the value of a flag.
+type Kind int
+
+const (
+	KindBool Kind = iota
+	KindString
+	KindInt
+	KindDuration
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindBool:
+		return "bool"
+	case KindString:
+		return "string"
+	case KindInt:
+		return "int"
+	case KindDuration:
+		return "duration"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// A Stage tells how stable the behavior behind a flag is.
+type Stage string
+
+const (
+	// Experimental behavior may change or go away in any release.
+	Experimental Stage = "experimental"
+	// Beta behavior is complete, and may still change.
+	Beta Stage = "beta"
+	// Stable behavior is there to stay, the flag lets users opt out.
+	Stable Stage = "stable"
+	// Deprecated flags are going away, see Flag.RemovedIn. Setting them
+	// warns.
+	Deprecated Stage = "deprecated"
+)
+
+// A Flag is a feature of a plugin users can opt in to, or out of.
+type Flag struct {
+	// Name is the name of the flag, like "parallel-upload".
+	Name string
+	// Description tells users what the flag does.
+	Description string
+	Kind        Kind
+	// Default is the value of the flag when users don't set it, as they
+	// would set it: "true", "42" or "5m".
+	Default string
+	Stage   Stage
+	// DeprecatedIn and RemovedIn are the versions of the plugin the flag
+	// was deprecated in, and is going to be removed in, for Deprecated
+	// flags.
+	DeprecatedIn string
+	RemovedIn    string
+}
+
+// EnvVar returns the environment variable setting the flag name:
+// PACKER_PLUGIN_FEATURE_ followed by name in upper case, with dashes and
+// dots replaced by underscores.
+func EnvVar(name string) string {
+	return "PACKER_PLUGIN_FEATURE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+var registry = struct {
+	sync.RWMutex
+	flags map[string]Flag
+	order []string
+	// overrides are the values set by Set, which take precedence over the
+	// environment.
+	overrides map[string]string
+	// warned are the deprecated flags a warning was logged for.
+	warned map[string]bool
+}{
+	flags:     map[string]Flag{},
+	overrides: map[string]string{},
+	warned:    map[string]bool{},
+}
+
+// Register makes f available to users. It is meant to be called from an
+// init function, and panics when the flag is registered twice or its
+// default value doesn't parse.
+func Register(f Flag) {
+	registry.Lock()
+	defer registry.Unlock()
+	if f.Name == "" {
+		panic("featureflag: flag without a name")
+	}
+	if _, ok := registry.flags[f.Name]; ok {
+		panic(fmt.Sprintf("featureflag: flag %q registered twice", f.Name))
+	}
+	if _, err := parse(f.Kind, f.Default); err != nil {
+		panic(fmt.Sprintf("featureflag: bad default of flag %q: %s", f.Name, err))
+	}
+	if f.Stage == "" {
+		f.Stage = Experimental
+	}
+	registry.flags[f.Name] = f
+	registry.order = append(registry.order, f.Name)
+}
+
+// Lookup returns the flag name.
+func Lookup(name string) (Flag, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	f, ok := registry.flags[name]
+	return f, ok
+}
+
+// Flags returns the registered flags, in the order they were registered.
+func Flags() []Flag {
+	registry.RLock()
+	defer registry.RUnlock()
+	flags := make([]Flag, 0, len(registry.order))
+	for _, name := range registry.order {
+		flags = append(flags, registry.flags[name])
+	}
+	return flags
+}
+
+// registeredNames returns the names of the registered flags.
+func registeredNames() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]string(nil), registry.order...)
+}
+
+// Set sets the flag name to value for the rest of the process, over its
+// environment variable. It fails when the flag doesn't exist or value
+// doesn't parse.
+func Set(name, value string) error {
+	f, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown feature flag %q", name)
+	}
+	if _, err := parse(f.Kind, value); err != nil {
+		return fmt.Errorf("feature flag %q: %s", name, err)
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	registry.overrides[name] = value
+	return nil
+}
+
+// Unset forgets the value Set set for the flag name.
+func Unset(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.overrides, name)
+}
+
+// raw returns the value of the flag name as set by the user, and whether
+// it is set.
+func raw(f Flag) (string, bool) {
+	registry.RLock()
+	v, ok := registry.overrides[f.Name]
+	registry.RUnlock()
+	if ok {
+		return v, true
+	}
+	return os.LookupEnv(EnvVar(f.Name))
+}
+
+// value returns the value of the flag name of kind k. Querying flags that
+// don't exist, or with the accessor of another kind, is a bug of the
+// plugin: it panics.
+func value(name string, k Kind) interface{} {
+	f, ok := Lookup(name)
+	if !ok {
+		panic(fmt.Sprintf("featureflag: unknown flag %q", name))
+	}
+	if f.Kind != k {
+		panic(fmt.Sprintf("featureflag: flag %q is a %s, not a %s", name, f.Kind, k))
+	}
+	if s, ok := raw(f); ok {
+		v, err := parse(k, s)
+		if err == nil {
+			warnDeprecated(f)
+			return v
+		}
+		log.Printf("[WARN] Ignoring %s: %s", EnvVar(name), err)
+	}
+	v, _ := parse(k, f.Default)
+	return v
+}
+
+// warnDeprecated logs, once, that the deprecated flag f is set.
+func warnDeprecated(f Flag) {
+	if f.Stage != Deprecated {
+		return
+	}
+	registry.Lock()
+	warned := registry.warned[f.Name]
+	registry.warned[f.Name] = true
+	registry.Unlock()
+	if !warned {
+		log.Printf("[WARN] %s", deprecation(f))
+	}
+}
+
+// deprecation returns the deprecation message of f.
+func deprecation(f Flag) string {
+	msg := fmt.Sprintf("The %q feature flag is deprecated", f.Name)
+	if f.DeprecatedIn != "" {
+		msg += " since " + f.DeprecatedIn
+	}
+	if f.RemovedIn != "" {
+		msg += " and will be removed in " + f.RemovedIn
+	}
+	return msg + "."
+}
+
+// Deprecations returns the deprecation messages of the deprecated flags
+// users set, for components to return as warnings of Prepare.
+func Deprecations() []string {
+	var msgs []string
+	for _, f := range Flags() {
+		if _, set := raw(f); set && f.Stage == Deprecated {
+			msgs = append(msgs, deprecation(f))
+		}
+	}
+	return msgs
+}
+
+func parse(k Kind, s string) (interface{}, error) {
+	switch k {
+	case KindBool:
+		return strconv.ParseBool(s)
+	case KindString:
+		return s, nil
+	case KindInt:
+		return strconv.Atoi(s)
+	case KindDuration:
+		return time.ParseDuration(s)
+	}
+	return nil, fmt.Errorf("unknown kind %s", k)
+}
+
+// Bool returns the value of the bool flag name.
+func Bool(name string) bool {
+	return value(name, KindBool).(bool)
+}
+
+// String returns the value of the string flag name.
+func String(name string) string {
+	return value(name, KindString).(string)
+}
+
+// Int returns the value of the int flag name.
+func Int(name string) int {
+	return value(name, KindInt).(int)
+}
+
+// Duration returns the value of the duration flag name.
+func Duration(name string) time.Duration {
+	return value(name, KindDuration).(time.Duration)
+}
+
+// Description describes a flag in the output of the describe command of
+// plugins.
+type Description struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Kind         string `json:"kind"`
+	Default      string `json:"default"`
+	Stage        Stage  `json:"stage"`
+	EnvVar       string `json:"env_var"`
+	DeprecatedIn string `json:"deprecated_in,omitempty"`
+	RemovedIn    string `json:"removed_in,omitempty"`
+}
+
+// Describe returns the descriptions of the registered flags.
+func Describe() []Description {
+	var descs []Description
+	for _, f := range Flags() {
+		descs = append(descs, Description{
+			Name:         f.Name,
+			Description:  f.Description,
+			Kind:         f.Kind.String(),
+			Default:      f.Default,
+			Stage:        f.Stage,
+			EnvVar:       EnvVar(f.Name),
+			DeprecatedIn: f.DeprecatedIn,
+			RemovedIn:    f.RemovedIn,
+		})
+	}
+	return descs
+}
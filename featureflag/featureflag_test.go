@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package featureflag
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	Register(Flag{Name: "test-parallel-upload", Description: "Upload in parallel.", Kind: KindBool, Default: "false"})
+	Register(Flag{Name: "test-workers", Kind: KindInt, Default: "4", Stage: Beta})
+	Register(Flag{Name: "test-timeout", Kind: KindDuration, Default: "5m", Stage: Stable})
+	Register(Flag{Name: "test-legacy-scp", Kind: KindBool, Default: "true", Stage: Deprecated, DeprecatedIn: "v1.2.0", RemovedIn: "v2.0.0"})
+}
+
+func TestAccessors(t *testing.T) {
+	if Bool("test-parallel-upload") || Int("test-workers") != 4 || Duration("test-timeout") != 5*time.Minute {
+		t.Fatal("unset flags should have their default value")
+	}
+
+	t.Setenv("PACKER_PLUGIN_FEATURE_TEST_PARALLEL_UPLOAD", "true")
+	t.Setenv("PACKER_PLUGIN_FEATURE_TEST_WORKERS", "lots")
+	if !Bool("test-parallel-upload") {
+		t.Fatal("flags should be read from the environment")
+	}
+	if Int("test-workers") != 4 {
+		t.Fatal("invalid values should be ignored")
+	}
+
+	defer Unset("test-parallel-upload")
+	if err := Set("test-parallel-upload", "false"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if Bool("test-parallel-upload") {
+		t.Fatal("values set should take precedence over the environment")
+	}
+	if err := Set("test-workers", "lots"); err == nil {
+		t.Fatal("invalid values should not be set")
+	}
+}
+
+func TestAccessors_misuse(t *testing.T) {
+	for name, access := range map[string]func(){
+		"unknown flag": func() { Bool("test-unknown") },
+		"wrong kind":   func() { Int("test-parallel-upload") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s should panic", name)
+				}
+			}()
+			access()
+		}()
+	}
+}
+
+func TestRegister_invalid(t *testing.T) {
+	for _, f := range []Flag{
+		{Name: "test-workers", Kind: KindInt, Default: "4"},
+		{Name: "test-bad-default", Kind: KindDuration, Default: "soon"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q should panic", f.Name)
+				}
+			}()
+			Register(f)
+		}()
+	}
+}
+
+func TestConfig_Prepare(t *testing.T) {
+	defer Unset("test-workers")
+	defer Unset("test-legacy-scp")
+
+	c := &Config{Features: map[string]string{
+		"test-workers":    "8",
+		"test-legacy-scp": "false",
+		"test-worker":     "2",
+		"test-timeout":    "forever",
+	}}
+	errs := c.Prepare(nil)
+	if len(errs) != 2 {
+		t.Fatalf("bad errors: %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), `"test-timeout"`) || !strings.Contains(errs[1].Error(), `did you mean "test-workers"?`) {
+		t.Fatalf("bad errors: %v", errs)
+	}
+	if Int("test-workers") != 8 || Bool("test-legacy-scp") {
+		t.Fatal("the valid flags should be set")
+	}
+
+	want := []string{`The "test-legacy-scp" feature flag is deprecated since v1.2.0 and will be removed in v2.0.0.`}
+	if diff := cmp.Diff(want, Deprecations()); diff != "" {
+		t.Fatalf("unexpected deprecations: %s", diff)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	descs := Describe()
+	if len(descs) != 4 {
+		t.Fatalf("bad descriptions: %#v", descs)
+	}
+	want := Description{
+		Name:        "test-parallel-upload",
+		Description: "Upload in parallel.",
+		Kind:        "bool",
+		Default:     "false",
+		Stage:       Experimental,
+		EnvVar:      "PACKER_PLUGIN_FEATURE_TEST_PARALLEL_UPLOAD",
+	}
+	if diff := cmp.Diff(want, descs[0]); diff != "" {
+		t.Fatalf("unexpected description: %s", diff)
+	}
+}
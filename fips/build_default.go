@@ -0,0 +1,10 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !fips
+
+package fips
+
+// buildEnabled is false by default, FIPS mode can still be enabled at runtime
+// through the PACKER_FIPS_MODE environment variable.
+const buildEnabled = false
@@ -0,0 +1,9 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build fips
+
+package fips
+
+// buildEnabled forces FIPS mode on for binaries built with the fips tag.
+const buildEnabled = true
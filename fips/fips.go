@@ -0,0 +1,208 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package fips restricts the cryptographic primitives used by the SDK to the
+// ones approved by FIPS 140.
+//
+// FIPS mode is enabled either by building the plugin with the `fips` build
+// tag, or at runtime by setting the PACKER_FIPS_MODE environment variable to
+// a true value. When enabled, SDK components such as the communicators and
+// the random package only use approved algorithms, and configuration that
+// would require a non-approved algorithm is rejected during Prepare.
+package fips
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// EnvVar is the environment variable that enables FIPS mode at runtime.
+const EnvVar = "PACKER_FIPS_MODE"
+
+var (
+	// SSHCiphers are the approved SSH ciphers, in preference order.
+	SSHCiphers = []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+	}
+
+	// SSHKeyExchanges are the approved SSH key exchange algorithms, in
+	// preference order.
+	SSHKeyExchanges = []string{
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+	}
+
+	// SSHMACs are the approved SSH MAC algorithms, in preference order.
+	SSHMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512",
+	}
+
+	// SSHHostKeyAlgorithms are the approved host key and public key
+	// authentication algorithms, in preference order.
+	SSHHostKeyAlgorithms = []string{
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512,
+	}
+
+	// TLSCipherSuites are the approved TLS 1.2 cipher suites. TLS 1.3 suites
+	// are not configurable in crypto/tls and are all approved.
+	TLSCipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}
+
+	// TLSCurves are the approved elliptic curves for TLS key exchange.
+	TLSCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+)
+
+// MinRSAKeySize is the smallest RSA modulus, in bits, allowed in FIPS mode.
+const MinRSAKeySize = 2048
+
+// Enabled reports whether FIPS mode is active, either because the SDK was
+// built with the `fips` build tag or because PACKER_FIPS_MODE is set to a
+// true value.
+func Enabled() bool {
+	if buildEnabled {
+		return true
+	}
+	on, _ := strconv.ParseBool(os.Getenv(EnvVar))
+	return on
+}
+
+// ApplySSH restricts an ssh.Config to approved algorithms. Algorithms that
+// were already set on the config are kept as long as they are approved.
+// ApplySSH does nothing when FIPS mode is disabled.
+func ApplySSH(c *ssh.Config) {
+	if !Enabled() {
+		return
+	}
+	c.Ciphers = restrict(c.Ciphers, SSHCiphers)
+	c.KeyExchanges = restrict(c.KeyExchanges, SSHKeyExchanges)
+	c.MACs = restrict(c.MACs, SSHMACs)
+}
+
+// ApplySSHClient is like ApplySSH but also restricts the host key algorithms
+// accepted by an ssh.ClientConfig.
+func ApplySSHClient(c *ssh.ClientConfig) {
+	if !Enabled() {
+		return
+	}
+	ApplySSH(&c.Config)
+	c.HostKeyAlgorithms = restrict(c.HostKeyAlgorithms, SSHHostKeyAlgorithms)
+}
+
+// ApplyTLS restricts a tls.Config to TLS 1.2 or later with approved cipher
+// suites and curves. ApplyTLS does nothing when FIPS mode is disabled.
+func ApplyTLS(c *tls.Config) {
+	if !Enabled() {
+		return
+	}
+	if c.MinVersion < tls.VersionTLS12 {
+		c.MinVersion = tls.VersionTLS12
+	}
+	c.CipherSuites = restrictUint16(c.CipherSuites, TLSCipherSuites)
+	c.CurvePreferences = restrictCurves(c.CurvePreferences, TLSCurves)
+}
+
+// ValidateSSHCiphers returns an error if any of the ciphers is not approved.
+func ValidateSSHCiphers(ciphers []string) error {
+	return validate("cipher", ciphers, SSHCiphers)
+}
+
+// ValidateSSHKeyExchanges returns an error if any of the key exchange
+// algorithms is not approved.
+func ValidateSSHKeyExchanges(kex []string) error {
+	return validate("key exchange algorithm", kex, SSHKeyExchanges)
+}
+
+// ValidateSSHKeyType returns an error if a key of the given type ("rsa",
+// "ecdsa", "ed25519", "dsa") and size cannot be used. A bits value of zero
+// means the default size for that type.
+func ValidateSSHKeyType(keyType string, bits int) error {
+	switch strings.ToLower(keyType) {
+	case "", "rsa":
+		if bits != 0 && bits < MinRSAKeySize {
+			return fmt.Errorf("%d bits rsa keys are not allowed in FIPS mode, use at least %d bits", bits, MinRSAKeySize)
+		}
+		return nil
+	case "ecdsa":
+		return nil
+	default:
+		return fmt.Errorf("%s keys are not allowed in FIPS mode, use rsa or ecdsa", keyType)
+	}
+}
+
+func validate(kind string, values, approved []string) error {
+	for _, v := range values {
+		if !contains(approved, v) {
+			return fmt.Errorf("%s %q is not allowed in FIPS mode, valid values are: %s",
+				kind, v, strings.Join(approved, ", "))
+		}
+	}
+	return nil
+}
+
+// restrict returns the values of current that are approved, or all the
+// approved values when current is empty or has no approved value.
+func restrict(current, approved []string) []string {
+	var out []string
+	for _, v := range current {
+		if contains(approved, v) {
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, approved...)
+	}
+	return out
+}
+
+func restrictUint16(current, approved []uint16) []uint16 {
+	var out []uint16
+	for _, v := range current {
+		for _, a := range approved {
+			if v == a {
+				out = append(out, v)
+				break
+			}
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, approved...)
+	}
+	return out
+}
+
+func restrictCurves(current, approved []tls.CurveID) []tls.CurveID {
+	var out []tls.CurveID
+	for _, v := range current {
+		for _, a := range approved {
+			if v == a {
+				out = append(out, v)
+				break
+			}
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, approved...)
+	}
+	return out
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package fips
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEnabled(t *testing.T) {
+	t.Setenv(EnvVar, "")
+	if Enabled() != buildEnabled {
+		t.Fatalf("expected Enabled() to be %t without %s", buildEnabled, EnvVar)
+	}
+
+	t.Setenv(EnvVar, "1")
+	if !Enabled() {
+		t.Fatalf("expected Enabled() to be true with %s=1", EnvVar)
+	}
+}
+
+func TestApplySSHClient(t *testing.T) {
+	t.Setenv(EnvVar, "true")
+
+	c := &ssh.ClientConfig{}
+	c.Ciphers = []string{"arcfour", "aes256-ctr"}
+	ApplySSHClient(c)
+
+	if !reflect.DeepEqual(c.Ciphers, []string{"aes256-ctr"}) {
+		t.Fatalf("unexpected ciphers: %#v", c.Ciphers)
+	}
+	if !reflect.DeepEqual(c.KeyExchanges, SSHKeyExchanges) {
+		t.Fatalf("unexpected key exchanges: %#v", c.KeyExchanges)
+	}
+	if !reflect.DeepEqual(c.MACs, SSHMACs) {
+		t.Fatalf("unexpected macs: %#v", c.MACs)
+	}
+	if !reflect.DeepEqual(c.HostKeyAlgorithms, SSHHostKeyAlgorithms) {
+		t.Fatalf("unexpected host key algorithms: %#v", c.HostKeyAlgorithms)
+	}
+}
+
+func TestApplyTLS(t *testing.T) {
+	t.Setenv(EnvVar, "true")
+
+	c := &tls.Config{MinVersion: tls.VersionTLS10}
+	ApplyTLS(c)
+	if c.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("unexpected min version: %x", c.MinVersion)
+	}
+	if !reflect.DeepEqual(c.CipherSuites, TLSCipherSuites) {
+		t.Fatalf("unexpected cipher suites: %#v", c.CipherSuites)
+	}
+}
+
+func TestValidateSSHKeyType(t *testing.T) {
+	tc := []struct {
+		keyType string
+		bits    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"rsa", 4096, false},
+		{"rsa", 1024, true},
+		{"ecdsa", 521, false},
+		{"ed25519", 0, true},
+		{"dsa", 1024, true},
+	}
+	for _, tt := range tc {
+		err := ValidateSSHKeyType(tt.keyType, tt.bits)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateSSHKeyType(%q, %d) error = %v, wantErr %t", tt.keyType, tt.bits, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSSHCiphers(t *testing.T) {
+	if err := ValidateSSHCiphers([]string{"aes128-ctr"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ValidateSSHCiphers([]string{"chacha20-poly1305@openssh.com"}); err == nil {
+		t.Fatalf("expected chacha20-poly1305 to be rejected")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Assertions describe what must be on the guest once it is provisioned, to
+// catch images missing what they were built for before they are published.
+type Assertions struct {
+	// Files are the remote paths of files or directories that must exist.
+	Files []string
+	// Packages that must be installed.
+	Packages []string
+	// Services that must be running.
+	Services []string
+}
+
+// AssertionsError lists the assertions that failed.
+type AssertionsError struct {
+	MissingFiles    []string
+	MissingPackages []string
+	StoppedServices []string
+}
+
+func (e *AssertionsError) Error() string {
+	var b strings.Builder
+	b.WriteString("Guest assertions failed:")
+	report := func(what string, names []string) {
+		for _, name := range names {
+			fmt.Fprintf(&b, "\n  - %s: %s", what, name)
+		}
+	}
+	report("missing file", e.MissingFiles)
+	report("missing package", e.MissingPackages)
+	report("service not running", e.StoppedServices)
+	return b.String()
+}
+
+// Check runs every assertion of a on the guest and returns an
+// *AssertionsError reporting all the ones that failed.
+func (a *Assertions) Check(ctx context.Context, comm packersdk.Communicator, commands *GuestCommands) error {
+	failed := new(AssertionsError)
+	var err error
+	if failed.MissingFiles, err = failing(ctx, comm, a.Files, commands.StatPath); err != nil {
+		return err
+	}
+	if failed.MissingPackages, err = failing(ctx, comm, a.Packages, commands.HasPackage); err != nil {
+		return err
+	}
+	if failed.StoppedServices, err = failing(ctx, comm, a.Services, commands.HasService); err != nil {
+		return err
+	}
+
+	if len(failed.MissingFiles)+len(failed.MissingPackages)+len(failed.StoppedServices) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// failing returns the names whose command exits with a non-zero status.
+func failing(ctx context.Context, comm packersdk.Communicator, names []string, command func(string) string) ([]string, error) {
+	var failed []string
+	for _, name := range names {
+		_, _, status, err := startCommand(ctx, comm, command(name))
+		if err != nil {
+			return nil, err
+		}
+		if status != 0 {
+			failed = append(failed, name)
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAssertions(t *testing.T) {
+	commands, _ := NewGuestCommands(UnixOSType, false)
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{
+		commands.StatPath("/etc/nginx/nginx.conf"): {},
+		commands.HasPackage("nginx"):               {},
+		commands.HasPackage("curl"):                {status: 1},
+		commands.HasService("nginx"):               {},
+		commands.HasService("cron"):                {status: 3},
+	}}
+
+	ok := &Assertions{
+		Files:    []string{"/etc/nginx/nginx.conf"},
+		Packages: []string{"nginx"},
+		Services: []string{"nginx"},
+	}
+	if err := ok.Check(context.Background(), comm, commands); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	failing := &Assertions{
+		Files:    []string{"/etc/nginx/nginx.conf", "/opt/app"},
+		Packages: []string{"nginx", "curl"},
+		Services: []string{"cron"},
+	}
+	err := failing.Check(context.Background(), comm, commands)
+	var aerr *AssertionsError
+	if !errors.As(err, &aerr) {
+		t.Fatalf("expected an AssertionsError, got %v", err)
+	}
+	expected := &AssertionsError{
+		MissingFiles:    []string{"/opt/app"},
+		MissingPackages: []string{"curl"},
+		StoppedServices: []string{"cron"},
+	}
+	if !reflect.DeepEqual(aerr, expected) {
+		t.Fatalf("bad failures: %#v", aerr)
+	}
+	report := "Guest assertions failed:\n" +
+		"  - missing file: /opt/app\n" +
+		"  - missing package: curl\n" +
+		"  - service not running: cron"
+	if err.Error() != report {
+		t.Fatalf("bad report: %s", err)
+	}
+}
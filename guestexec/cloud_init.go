@@ -0,0 +1,249 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// DefaultCloudInitTimeout is how long WaitForCloudInit waits by default.
+	DefaultCloudInitTimeout      = 15 * time.Minute
+	defaultCloudInitPollInterval = 5 * time.Second
+)
+
+const (
+	cloudInitStatusWait    = "cloud-init status --wait"
+	cloudInitInstalled     = "test -d /var/lib/cloud"
+	cloudInitBootFinished  = "test -f /var/lib/cloud/instance/boot-finished"
+	cloudInitResult        = "cat /var/lib/cloud/data/result.json"
+	cloudInitLogErrors     = "grep -E ' ERROR|Traceback' /var/log/cloud-init.log | tail -n 20"
+	cloudbaseInitStatus    = `powershell.exe -Command "(Get-Service cloudbase-init -ErrorAction SilentlyContinue).Status"`
+	cloudbaseInitLogErrors = `powershell.exe -Command "Select-String -Path 'C:\Program Files\Cloudbase Solutions\Cloudbase-Init\log\cloudbase-init.log' -Pattern ' ERROR ' -ErrorAction SilentlyContinue | Select-Object -Last 20 | ForEach-Object { $_.Line }"`
+)
+
+// CloudInitOptions configures WaitForCloudInit.
+type CloudInitOptions struct {
+	// Sudo runs the commands reading the logs of cloud-init with sudo.
+	Sudo bool
+	// Timeout is how long to wait; DefaultCloudInitTimeout when zero.
+	Timeout time.Duration
+	// PollInterval is the wait between two checks, when the guest can't
+	// wait by itself, as old cloud-init versions and cloudbase-init; 5s
+	// when zero.
+	PollInterval time.Duration
+}
+
+// CloudInitError is returned by WaitForCloudInit when cloud-init, or
+// cloudbase-init, failed to configure the guest.
+type CloudInitError struct {
+	// Status is the status reported by cloud-init, like "error".
+	Status string
+	// Errors are the errors found in the results or the logs of
+	// cloud-init, the last ones when there are many.
+	Errors []string
+}
+
+func (e *CloudInitError) Error() string {
+	msg := fmt.Sprintf("cloud-init failed with status %q", e.Status)
+	if len(e.Errors) > 0 {
+		msg += ":\n  " + strings.Join(e.Errors, "\n  ")
+	}
+	return msg
+}
+
+// WaitForCloudInit waits for cloud-init, or cloudbase-init on Windows guests,
+// to finish configuring a guest of type osType, and returns a
+// *CloudInitError with the errors found in its results and logs when it
+// failed. Guests without cloud-init are not waited for.
+//
+// On Unix, it uses `cloud-init status --wait`, and polls for the
+// boot-finished file with versions that predate it.
+func WaitForCloudInit(ctx context.Context, comm packersdk.Communicator, osType string, opts CloudInitOptions) error {
+	commands, err := NewGuestCommands(osType, opts.Sudo)
+	if err != nil {
+		return err
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCloudInitTimeout
+	}
+	w := &cloudInitWaiter{comm: comm, commands: commands, interval: opts.PollInterval}
+	if w.interval <= 0 {
+		w.interval = defaultCloudInitPollInterval
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if commands.Family() == WindowsOSType {
+		err = w.waitCloudbaseInit(waitCtx)
+	} else {
+		err = w.waitCloudInit(waitCtx)
+	}
+	if err != nil && waitCtx.Err() != nil && ctx.Err() == nil {
+		return fmt.Errorf("Timeout waiting for cloud-init after %s", timeout)
+	}
+	return err
+}
+
+type cloudInitWaiter struct {
+	comm     packersdk.Communicator
+	commands *GuestCommands
+	interval time.Duration
+}
+
+// start runs command, returning early when ctx is done: the communicator may
+// not stop a command waiting for cloud-init.
+func (w *cloudInitWaiter) start(ctx context.Context, command string) (string, int, error) {
+	type result struct {
+		stdout string
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stdout, _, status, err := startCommand(ctx, w.comm, command)
+		done <- result{stdout, status, err}
+	}()
+	select {
+	case r := <-done:
+		return r.stdout, r.status, r.err
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	}
+}
+
+func (w *cloudInitWaiter) waitCloudInit(ctx context.Context) error {
+	stdout, status, err := w.start(ctx, cloudInitStatusWait)
+	if err != nil {
+		return err
+	}
+	state := parseCloudInitStatus(stdout)
+	switch {
+	case state == "error":
+		return w.cloudInitError(ctx, state)
+	case state != "" && (status == 0 || status == 2):
+		// 2 is for recoverable errors, reported by recent versions.
+		if status == 2 {
+			log.Printf("[WARN] cloud-init finished with recoverable errors")
+		}
+		return nil
+	case state != "":
+		return w.cloudInitError(ctx, state)
+	}
+
+	// cloud-init is missing, or too old to have `status --wait`.
+	if _, status, err := w.start(ctx, cloudInitInstalled); err != nil {
+		return err
+	} else if status != 0 {
+		log.Printf("[INFO] cloud-init is not installed on the guest, not waiting for it")
+		return nil
+	}
+	log.Printf("[DEBUG] `cloud-init status --wait` exited with status %d, polling for boot-finished", status)
+	for {
+		_, status, err := w.start(ctx, cloudInitBootFinished)
+		if err != nil {
+			return err
+		}
+		if status == 0 {
+			break
+		}
+		select {
+		case <-time.After(w.interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if errs := w.cloudInitResultErrors(ctx); len(errs) > 0 {
+		return &CloudInitError{Status: "error", Errors: errs}
+	}
+	return nil
+}
+
+// parseCloudInitStatus returns the status in the output of `cloud-init
+// status`, like "done", or "" when there is none.
+func parseCloudInitStatus(stdout string) string {
+	for _, line := range strings.Split(stdout, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "status:"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// cloudInitError returns the error of cloud-init finishing with status,
+// with the errors from its results, or else from its log.
+func (w *cloudInitWaiter) cloudInitError(ctx context.Context, status string) error {
+	errs := w.cloudInitResultErrors(ctx)
+	if len(errs) == 0 {
+		errs = w.logErrors(ctx, w.commands.sudo(cloudInitLogErrors))
+	}
+	return &CloudInitError{Status: status, Errors: errs}
+}
+
+// cloudInitResultErrors returns the errors of the result.json file written
+// by cloud-init when it finishes.
+func (w *cloudInitWaiter) cloudInitResultErrors(ctx context.Context) []string {
+	stdout, status, err := w.start(ctx, w.commands.sudo(cloudInitResult))
+	if err != nil || status != 0 {
+		return nil
+	}
+	var result struct {
+		V1 struct {
+			Errors []string `json:"errors"`
+		} `json:"v1"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		log.Printf("[DEBUG] Could not parse the result of cloud-init: %s", err)
+		return nil
+	}
+	return result.V1.Errors
+}
+
+func (w *cloudInitWaiter) logErrors(ctx context.Context, command string) []string {
+	stdout, _, err := w.start(ctx, command)
+	if err != nil {
+		return nil
+	}
+	var errs []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			errs = append(errs, line)
+		}
+	}
+	return errs
+}
+
+// waitCloudbaseInit waits for the cloudbase-init service to stop, which it
+// does once it ran its plugins.
+func (w *cloudInitWaiter) waitCloudbaseInit(ctx context.Context) error {
+	for {
+		stdout, _, err := w.start(ctx, cloudbaseInitStatus)
+		if err != nil {
+			return err
+		}
+		switch strings.TrimSpace(stdout) {
+		case "":
+			log.Printf("[INFO] cloudbase-init is not installed on the guest, not waiting for it")
+			return nil
+		case "Stopped":
+			if errs := w.logErrors(ctx, cloudbaseInitLogErrors); len(errs) > 0 {
+				return &CloudInitError{Status: "error", Errors: errs}
+			}
+			return nil
+		}
+		select {
+		case <-time.After(w.interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
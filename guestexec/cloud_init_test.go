@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForCloudInit(t *testing.T) {
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{
+		cloudInitStatusWait: {stdout: "\nstatus: done\n"},
+	}}
+	if err := WaitForCloudInit(context.Background(), comm, UnixOSType, CloudInitOptions{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm.results[cloudInitStatusWait] = scriptedResult{stdout: "status: done\n", status: 2}
+	if err := WaitForCloudInit(context.Background(), comm, UnixOSType, CloudInitOptions{}); err != nil {
+		t.Fatalf("recoverable errors should not fail: %s", err)
+	}
+}
+
+func TestWaitForCloudInit_error(t *testing.T) {
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{
+		cloudInitStatusWait:          {stdout: "status: error\n", status: 1},
+		"sudo " + cloudInitResult:    {stdout: `{"v1": {"datasource": null, "errors": ["('scripts-user', RuntimeError('Runparts: 1 failures'))"]}}`},
+		"sudo " + cloudInitLogErrors: {stdout: "unused\n"},
+	}}
+	err := WaitForCloudInit(context.Background(), comm, UnixOSType, CloudInitOptions{Sudo: true})
+	var ciErr *CloudInitError
+	if !errors.As(err, &ciErr) {
+		t.Fatalf("expected a CloudInitError, got: %v", err)
+	}
+	if ciErr.Status != "error" || len(ciErr.Errors) != 1 || !strings.Contains(ciErr.Errors[0], "scripts-user") {
+		t.Fatalf("unexpected error: %#v", ciErr)
+	}
+
+	// Without result.json, the errors come from the log.
+	delete(comm.results, "sudo "+cloudInitResult)
+	comm.results["sudo "+cloudInitLogErrors] = scriptedResult{stdout: "2024-01-01 util.py[WARNING] ERROR running\nTraceback (most recent call last):\n"}
+	err = WaitForCloudInit(context.Background(), comm, UnixOSType, CloudInitOptions{Sudo: true})
+	if !errors.As(err, &ciErr) || len(ciErr.Errors) != 2 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitForCloudInit_old(t *testing.T) {
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{
+		cloudInitStatusWait:   {stdout: "usage: cloud-init [-h] ...\n", status: 2},
+		cloudInitInstalled:    {},
+		cloudInitBootFinished: {},
+		cloudInitResult:       {stdout: `{"v1": {"errors": []}}`},
+	}}
+	if err := WaitForCloudInit(context.Background(), comm, UnixOSType, CloudInitOptions{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm.results[cloudInitBootFinished] = scriptedResult{status: 1}
+	err := WaitForCloudInit(context.Background(), comm, UnixOSType, CloudInitOptions{
+		Timeout:      50 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err == nil || !strings.Contains(err.Error(), "Timeout waiting for cloud-init") {
+		t.Fatalf("expected a timeout, got: %v", err)
+	}
+}
+
+func TestWaitForCloudInit_notInstalled(t *testing.T) {
+	// Unknown commands exit with 127.
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{}}
+	if err := WaitForCloudInit(context.Background(), comm, UnixOSType, CloudInitOptions{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestWaitForCloudbaseInit(t *testing.T) {
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{
+		cloudbaseInitStatus:    {stdout: "Stopped\r\n"},
+		cloudbaseInitLogErrors: {stdout: "2024-01-01 1234 ERROR cloudbaseinit.init [-] plugin failed\r\n"},
+	}}
+	err := WaitForCloudInit(context.Background(), comm, WindowsOSType, CloudInitOptions{})
+	var ciErr *CloudInitError
+	if !errors.As(err, &ciErr) || len(ciErr.Errors) != 1 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	comm.results[cloudbaseInitLogErrors] = scriptedResult{}
+	if err := WaitForCloudInit(context.Background(), comm, WindowsOSType, CloudInitOptions{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -10,5 +10,17 @@ simple and repeatable.
 Note that to successfully use this package your provisioner must have knowledge
 of the guest type, which is not information that builders generally collect --
 your provisioner will have to require guest information in its config.
+
+The commands of each kind of guest OS are kept in an OSProfile. Besides the
+unix and windows profiles, alpine, freebsd, macos and windows-core are
+registered, and plugins can add their own with RegisterOSProfile.
+DetectOSProfile finds the profile of a guest when the config doesn't say.
+
+CompletionMarkers record on the guest which provisioners completed, so that
+running a failed build again can skip the idempotent ones.
+
+StaticNetwork writes the static network configuration given by a builder in
+the format of the guest, netplan, ifcfg files or PowerShell, and applies it,
+for networks without DHCP.
 */
 package guestexec
@@ -12,78 +12,113 @@ const UnixOSType = "unix"
 const WindowsOSType = "windows"
 const DefaultOSType = UnixOSType
 
-type guestOSTypeCommand struct {
-	chmod     string
-	mkdir     string
-	removeDir string
-	statPath  string
-	mv        string
-}
-
-var guestOSTypeCommands = map[string]guestOSTypeCommand{
-	UnixOSType: {
-		chmod:     "chmod %s '%s'",
-		mkdir:     "mkdir -p '%s'",
-		removeDir: "rm -rf '%s'",
-		statPath:  "stat '%s'",
-		mv:        "mv '%s' '%s'",
-	},
-	WindowsOSType: {
-		chmod:     "echo 'skipping chmod %s %s'", // no-op
-		mkdir:     "powershell.exe -Command \"New-Item -ItemType directory -Force -ErrorAction SilentlyContinue -Path %s\"",
-		removeDir: "powershell.exe -Command \"rm %s -recurse -force\"",
-		statPath:  "powershell.exe -Command { if (test-path %s) { exit 0 } else { exit 1 } }",
-		mv:        "powershell.exe -Command \"mv %s %s -force\"",
-	},
-}
-
+// GuestCommands returns the commands of the OS profile GuestOSType.
 type GuestCommands struct {
 	GuestOSType string
 	Sudo        bool
 }
 
+// NewGuestCommands returns the commands of the OS profile osType, like
+// UnixOSType or one registered with RegisterOSProfile. Sudo elevates the
+// commands that modify the guest, with the mechanism of the profile.
 func NewGuestCommands(osType string, sudo bool) (*GuestCommands, error) {
-	_, ok := guestOSTypeCommands[osType]
-	if !ok {
+	if _, ok := LookupOSProfile(osType); !ok {
 		return nil, fmt.Errorf("Invalid osType: \"%s\"", osType)
 	}
 	return &GuestCommands{GuestOSType: osType, Sudo: sudo}, nil
 }
 
+// Profile returns the OS profile of the commands.
+func (g *GuestCommands) Profile() OSProfile {
+	p, _ := LookupOSProfile(g.GuestOSType)
+	return p
+}
+
+// Family returns the family of the OS profile of the commands, UnixOSType or
+// WindowsOSType, for the code that only cares about those.
+func (g *GuestCommands) Family() string {
+	return g.Profile().Family
+}
+
+// TempDir returns the system temporary directory of the guest.
+func (g *GuestCommands) TempDir() string {
+	return g.Profile().TempDir
+}
+
 func (g *GuestCommands) Chmod(path string, mode string) string {
-	return g.sudo(fmt.Sprintf(g.commands().chmod, mode, g.escapePath(path)))
+	return g.sudo(fmt.Sprintf(g.commands().Chmod, mode, g.escapePath(path)))
 }
 
 func (g *GuestCommands) CreateDir(path string) string {
-	return g.sudo(fmt.Sprintf(g.commands().mkdir, g.escapePath(path)))
+	return g.sudo(fmt.Sprintf(g.commands().Mkdir, g.escapePath(path)))
 }
 
 func (g *GuestCommands) RemoveDir(path string) string {
-	return g.sudo(fmt.Sprintf(g.commands().removeDir, g.escapePath(path)))
+	return g.sudo(fmt.Sprintf(g.commands().RemoveDir, g.escapePath(path)))
 }
 
-func (g *GuestCommands) commands() guestOSTypeCommand {
-	return guestOSTypeCommands[g.GuestOSType]
+func (g *GuestCommands) commands() OSProfile {
+	return g.Profile()
 }
 
 func (g *GuestCommands) escapePath(path string) string {
-	if g.GuestOSType == WindowsOSType {
+	if g.Family() == WindowsOSType {
 		return strings.Replace(path, " ", "` ", -1)
 	}
 	return path
 }
 
 func (g *GuestCommands) StatPath(path string) string {
-	return g.sudo(fmt.Sprintf(g.commands().statPath, g.escapePath(path)))
+	return g.sudo(fmt.Sprintf(g.commands().StatPath, g.escapePath(path)))
 }
 
 func (g *GuestCommands) MovePath(srcPath string, dstPath string) string {
-	return g.sudo(fmt.Sprintf(g.commands().mv, g.escapePath(srcPath), g.escapePath(dstPath)))
+	return g.sudo(fmt.Sprintf(g.commands().Move, g.escapePath(srcPath), g.escapePath(dstPath)))
+}
+
+// FreeSpace returns a command printing the number of bytes available on the
+// filesystem holding path.
+func (g *GuestCommands) FreeSpace(path string) string {
+	return fmt.Sprintf(g.commands().FreeSpace, g.escapePath(path))
+}
+
+// FreeMemory returns a command printing the number of bytes of memory
+// available.
+func (g *GuestCommands) FreeMemory() string {
+	return g.commands().FreeMemory
+}
+
+// HasBinary returns a command exiting with a zero status if the binary name
+// can be found in the PATH.
+func (g *GuestCommands) HasBinary(name string) string {
+	return fmt.Sprintf(g.commands().HasBinary, g.escapePath(name))
+}
+
+// CreateTempDir returns a command creating a new directory in the temporary
+// directory of the guest user, with a name beginning with prefix, and
+// printing its path. Only the user, and the system on Windows, can access
+// the directory. It never runs with sudo, the directory must belong to the
+// user.
+func (g *GuestCommands) CreateTempDir(prefix string) string {
+	return fmt.Sprintf(g.commands().CreateTempDir, prefix)
+}
+
+// HasPackage returns a command exiting with a zero status if the package name
+// is installed, by dpkg, rpm or apk on Unix, any provider of Get-Package on
+// Windows.
+func (g *GuestCommands) HasPackage(name string) string {
+	return fmt.Sprintf(g.commands().HasPackage, name)
+}
+
+// HasService returns a command exiting with a zero status if the service
+// name is running.
+func (g *GuestCommands) HasService(name string) string {
+	return fmt.Sprintf(g.commands().HasService, name)
 }
 
 func (g *GuestCommands) sudo(cmd string) string {
-	if g.GuestOSType == UnixOSType && g.Sudo {
-		return "sudo " + cmd
+	if elevate := g.commands().Elevate; elevate != "" && g.Sudo {
+		return elevate + " " + cmd
 	}
 	return cmd
 }
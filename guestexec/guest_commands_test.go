@@ -4,6 +4,7 @@
 package guestexec
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -196,3 +197,69 @@ func TestMovePath(t *testing.T) {
 		t.Fatalf("Unexpected Windows remove dir cmd: %s", cmd)
 	}
 }
+
+func TestFreeSpace(t *testing.T) {
+	// *nix, never run with sudo
+	guestCmd, err := NewGuestCommands(UnixOSType, true)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", UnixOSType)
+	}
+	cmd := guestCmd.FreeSpace("/tmp")
+	if cmd != `df -Pk '/tmp' | awk 'NR==2 {printf "%.0f\n", $4 * 1024}'` {
+		t.Fatalf("Unexpected Unix free space cmd: %s", cmd)
+	}
+
+	// Windows OS
+	guestCmd, err = NewGuestCommands(WindowsOSType, false)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", WindowsOSType)
+	}
+	cmd = guestCmd.FreeSpace("C:\\Windows\\Temp")
+	if cmd != "powershell.exe -Command \"(Get-Item C:\\Windows\\Temp).PSDrive.Free\"" {
+		t.Fatalf("Unexpected Windows free space cmd: %s", cmd)
+	}
+}
+
+func TestHasBinary(t *testing.T) {
+	// *nix
+	guestCmd, err := NewGuestCommands(UnixOSType, false)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", UnixOSType)
+	}
+	cmd := guestCmd.HasBinary("ansible-playbook")
+	if cmd != "command -v 'ansible-playbook'" {
+		t.Fatalf("Unexpected Unix has binary cmd: %s", cmd)
+	}
+
+	// Windows OS
+	guestCmd, err = NewGuestCommands(WindowsOSType, false)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", WindowsOSType)
+	}
+	cmd = guestCmd.HasBinary("choco")
+	if cmd != "powershell.exe -Command \"if (Get-Command choco -ErrorAction SilentlyContinue) { exit 0 } else { exit 1 }\"" {
+		t.Fatalf("Unexpected Windows has binary cmd: %s", cmd)
+	}
+}
+
+func TestCreateTempDir(t *testing.T) {
+	// *nix, never with sudo
+	guestCmd, err := NewGuestCommands(UnixOSType, true)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", UnixOSType)
+	}
+	cmd := guestCmd.CreateTempDir("packer-")
+	if cmd != `mktemp -d "${TMPDIR:-/tmp}/packer-XXXXXXXX"` {
+		t.Fatalf("Unexpected Unix temp dir cmd: %s", cmd)
+	}
+
+	// Windows OS
+	guestCmd, err = NewGuestCommands(WindowsOSType, false)
+	if err != nil {
+		t.Fatalf("Failed to create new GuestCommands for OS: %s", WindowsOSType)
+	}
+	cmd = guestCmd.CreateTempDir("packer-")
+	if !strings.Contains(cmd, "('packer-' + [guid]::NewGuid())") || !strings.Contains(cmd, "/inheritance:r") {
+		t.Fatalf("Unexpected Windows temp dir cmd: %s", cmd)
+	}
+}
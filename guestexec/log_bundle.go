@@ -0,0 +1,190 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// DefaultLogMaxBytes is the size logs are truncated to in a LogBundle without
+// MaxBytes.
+const DefaultLogMaxBytes = 10 * 1024 * 1024
+
+// LogBundle describes the guest logs to collect to triage a failed build.
+type LogBundle struct {
+	// Files are the remote paths of the log files. Files that don't exist
+	// are skipped.
+	Files []string
+	// Commands are run on the guest, and their output is added to the
+	// bundle, for example `journalctl -b --no-pager`.
+	Commands []string
+	// MaxBytes is the size each file or output is truncated to, its last
+	// bytes being the most relevant to a failure. It defaults to
+	// DefaultLogMaxBytes.
+	MaxBytes int64
+}
+
+// DefaultLogBundle returns the logs usually telling why the setup of a guest
+// of type osType failed: the ones of cloud-init on Unix, and of Windows setup
+// and sysprep on Windows.
+func DefaultLogBundle(osType string) *LogBundle {
+	if p, ok := LookupOSProfile(osType); ok && p.Family == WindowsOSType {
+		return &LogBundle{
+			Files: []string{
+				`C:/Windows/Panther/setupact.log`,
+				`C:/Windows/Panther/setuperr.log`,
+				`C:/Windows/Panther/UnattendGC/setupact.log`,
+				`C:/Windows/System32/Sysprep/Panther/setupact.log`,
+				`C:/Windows/System32/Sysprep/Panther/setuperr.log`,
+			},
+			Commands: []string{
+				`powershell.exe -Command "Get-WinEvent -LogName System -MaxEvents 200 | Format-List"`,
+			},
+		}
+	}
+	return &LogBundle{
+		Files: []string{
+			"/var/log/cloud-init.log",
+			"/var/log/cloud-init-output.log",
+			"/var/log/syslog",
+			"/var/log/messages",
+		},
+		Commands: []string{
+			"journalctl -b --no-pager",
+			"dmesg",
+		},
+	}
+}
+
+// Collect downloads the logs of b and writes them to w as a gzipped tarball.
+// Files are stored under their remote path, and the output of the commands
+// under `commands/`. The logs that cannot be collected are listed in an
+// `errors.txt` entry rather than failing the collection, as a partial
+// bundle is still worth having; errors are only returned when w cannot be
+// written.
+func (b *LogBundle) Collect(ctx context.Context, comm packersdk.Communicator, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	max := b.MaxBytes
+	if max <= 0 {
+		max = DefaultLogMaxBytes
+	}
+	var failures []string
+	for _, file := range b.Files {
+		out := &tailBuffer{max: max}
+		if err := comm.Download(file, out); err != nil {
+			log.Printf("[DEBUG] Could not collect log %s: %s", file, err)
+			failures = append(failures, fmt.Sprintf("%s: %s", file, err))
+			continue
+		}
+		if err := add(logEntryName(file), out.Bytes()); err != nil {
+			return err
+		}
+	}
+	for i, command := range b.Commands {
+		out := &tailBuffer{max: max}
+		cmd := &packersdk.RemoteCmd{Command: command, Stdout: out, Stderr: out}
+		if err := comm.Start(ctx, cmd); err != nil {
+			log.Printf("[DEBUG] Could not run %q: %s", command, err)
+			failures = append(failures, fmt.Sprintf("%s: %s", command, err))
+			continue
+		}
+		status := cmd.Wait()
+		data := append([]byte(fmt.Sprintf("$ %s\n# exit status %d\n", command, status)), out.Bytes()...)
+		if err := add(fmt.Sprintf("commands/%02d-%s.txt", i, commandEntryName(command)), data); err != nil {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		if err := add("errors.txt", []byte(strings.Join(failures, "\n")+"\n")); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// logEntryName returns the name of the tarball entry of a remote path, which
+// must be relative and use forward slashes.
+func logEntryName(remote string) string {
+	name := strings.ReplaceAll(remote, `\`, "/")
+	if len(name) >= 2 && name[1] == ':' {
+		name = strings.ToLower(name[:1]) + name[2:]
+	}
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+var unsafeEntryChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// commandEntryName returns a short file name describing command.
+func commandEntryName(command string) string {
+	name := strings.Trim(unsafeEntryChars.ReplaceAllString(command, "_"), "_")
+	if len(name) > 40 {
+		name = name[:40]
+	}
+	return name
+}
+
+// tailBuffer keeps the last max bytes written to it. Commands write both
+// their stdout and stderr to it, possibly concurrently.
+type tailBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	max       int64
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n, _ := b.buf.Write(p)
+	if over := int64(b.buf.Len()) - b.max; over > 0 {
+		b.buf.Next(int(over))
+		b.truncated = true
+	}
+	return n, nil
+}
+
+// Bytes returns the bytes kept, preceded by a note when some were dropped.
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.truncated {
+		return b.buf.Bytes()
+	}
+	note := fmt.Sprintf("[truncated to the last %d bytes]\n", b.max)
+	return append([]byte(note), b.buf.Bytes()...)
+}
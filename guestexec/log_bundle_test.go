@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// logsCommunicator serves files on top of the results of its commands.
+type logsCommunicator struct {
+	scriptedCommunicator
+
+	files map[string]string
+}
+
+func (c *logsCommunicator) Download(path string, w io.Writer) error {
+	data, ok := c.files[path]
+	if !ok {
+		return fmt.Errorf("no such file")
+	}
+	_, err := io.WriteString(w, data)
+	return err
+}
+
+func readBundle(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	entries := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		data, _ := io.ReadAll(tr)
+		entries[hdr.Name] = string(data)
+	}
+}
+
+func TestLogBundle_Collect(t *testing.T) {
+	comm := &logsCommunicator{
+		scriptedCommunicator: scriptedCommunicator{results: map[string]scriptedResult{
+			"dmesg": {stdout: "kernel\n", status: 1},
+		}},
+		files: map[string]string{
+			"/var/log/cloud-init.log":         "cloud-init failed\n",
+			`C:\Windows\Panther\setupact.log`: "0123456789",
+		},
+	}
+	bundle := &LogBundle{
+		Files:    []string{"/var/log/cloud-init.log", "/var/log/missing.log", `C:\Windows\Panther\setupact.log`},
+		Commands: []string{"dmesg"},
+		MaxBytes: 4,
+	}
+
+	var out bytes.Buffer
+	if err := bundle.Collect(context.Background(), comm, &out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	entries := readBundle(t, &out)
+	expected := map[string]string{
+		"var/log/cloud-init.log":         "[truncated to the last 4 bytes]\nled\n",
+		"c/Windows/Panther/setupact.log": "[truncated to the last 4 bytes]\n6789",
+		"commands/00-dmesg.txt":          "$ dmesg\n# exit status 1\n[truncated to the last 4 bytes]\nnel\n",
+		"errors.txt":                     "/var/log/missing.log: no such file\n",
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("bad bundle: %#v", entries)
+	}
+}
+
+func TestLogEntryName(t *testing.T) {
+	cases := map[string]string{
+		"/var/log/syslog":           "var/log/syslog",
+		"../../etc/passwd":          "etc/passwd",
+		`C:\Windows\Temp\setup.log`: "c/Windows/Temp/setup.log",
+	}
+	for remote, expected := range cases {
+		if name := logEntryName(remote); name != expected {
+			t.Errorf("%s: got %q, expected %q", remote, name, expected)
+		}
+	}
+	if name := commandEntryName("journalctl -b --no-pager"); name != "journalctl_-b_--no-pager" || strings.Contains(name, " ") {
+		t.Errorf("bad command name %q", name)
+	}
+}
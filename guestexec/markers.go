@@ -0,0 +1,163 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// DefaultUnixMarkersDir is the directory of the completion markers on
+	// Unix guests, kept across reboots unlike /tmp.
+	DefaultUnixMarkersDir = "/var/tmp/packer-provisioned"
+	// DefaultWindowsMarkersDir is the directory of the completion markers
+	// on Windows guests.
+	DefaultWindowsMarkersDir = "C:/Windows/Temp/packer-provisioned"
+)
+
+// CompletionMarkers records on the guest which provisioners completed, so
+// that when a failed build is run again against the same machine, the
+// idempotent provisioners that already completed can be skipped.
+//
+// Each marker holds the checksum of the configuration the provisioner
+// completed with, usually its configdigest.Digest, so that a provisioner
+// whose configuration changed runs again.
+type CompletionMarkers struct {
+	Comm packersdk.Communicator
+	// Commands are the commands of the guest. The markers are uploaded as
+	// the guest user, so they should not use sudo unless Dir is writable
+	// by the user either way.
+	Commands *GuestCommands
+	// Dir is the remote directory of the markers. It defaults to
+	// DefaultUnixMarkersDir or DefaultWindowsMarkersDir.
+	Dir string
+	// Resume enables skipping the completed provisioners. When it is not
+	// set, Completed always returns false, but markers are still written
+	// for a later run to resume from.
+	Resume bool
+}
+
+// CompletionMarker is the content of a marker file.
+type CompletionMarker struct {
+	Name        string    `json:"name"`
+	Checksum    string    `json:"checksum"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+func (m *CompletionMarkers) dir() string {
+	if m.Dir != "" {
+		return strings.TrimRight(m.Dir, `/\`)
+	}
+	if m.Commands.Family() == WindowsOSType {
+		return DefaultWindowsMarkersDir
+	}
+	return DefaultUnixMarkersDir
+}
+
+var markerNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// path returns the remote path of the marker of the provisioner name. Names
+// are sanitized, and suffixed with their hash so that different names never
+// share a marker.
+func (m *CompletionMarkers) path(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	clean := strings.Trim(markerNameRe.ReplaceAllString(name, "_"), "_")
+	if len(clean) > 64 {
+		clean = clean[:64]
+	}
+	return fmt.Sprintf("%s/%s-%s.json", m.dir(), clean, hex.EncodeToString(hash[:6]))
+}
+
+// Completed tells whether the provisioner name completed with the
+// configuration of checksum, and can be skipped. It is always false when
+// Resume is not set. Markers that can't be read are logged and reported as
+// not completed, running a provisioner again being the safe choice.
+func (m *CompletionMarkers) Completed(ctx context.Context, name, checksum string) (bool, error) {
+	if !m.Resume {
+		return false, nil
+	}
+	path := m.path(name)
+	_, _, status, err := startCommand(ctx, m.Comm, m.Commands.StatPath(path))
+	if err != nil {
+		return false, err
+	}
+	if status != 0 {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := m.Comm.Download(path, &buf); err != nil {
+		log.Printf("[WARN] Could not download the completion marker %s: %s", path, err)
+		return false, nil
+	}
+	var marker CompletionMarker
+	if err := json.Unmarshal(buf.Bytes(), &marker); err != nil {
+		log.Printf("[WARN] Ignoring the invalid completion marker %s: %s", path, err)
+		return false, nil
+	}
+	if marker.Name != name || marker.Checksum != checksum {
+		log.Printf("[INFO] %s completed with another configuration, running it again", name)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Mark records that the provisioner name completed with the configuration of
+// checksum.
+func (m *CompletionMarkers) Mark(ctx context.Context, name, checksum string) error {
+	if _, err := runCommand(ctx, m.Comm, m.Commands.CreateDir(m.dir())); err != nil {
+		return fmt.Errorf("Error creating completion markers directory: %s", err)
+	}
+	data, err := json.Marshal(CompletionMarker{
+		Name:        name,
+		Checksum:    checksum,
+		CompletedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	path := m.path(name)
+	if err := m.Comm.Upload(path, bytes.NewReader(data), nil); err != nil {
+		return fmt.Errorf("Error uploading completion marker %s: %s", path, err)
+	}
+	return nil
+}
+
+// Clear removes all the markers, once the build succeeded or to start it
+// over.
+func (m *CompletionMarkers) Clear(ctx context.Context) error {
+	if _, err := runCommand(ctx, m.Comm, m.Commands.RemoveDir(m.dir())); err != nil {
+		return fmt.Errorf("Error removing completion markers: %s", err)
+	}
+	return nil
+}
+
+// RunOnce calls run unless the provisioner name already completed with the
+// configuration of checksum, and marks it completed once run succeeded. It
+// returns whether run was skipped.
+func (m *CompletionMarkers) RunOnce(ctx context.Context, ui packersdk.Ui, name, checksum string, run func() error) (bool, error) {
+	completed, err := m.Completed(ctx, name, checksum)
+	if err != nil {
+		return false, err
+	}
+	if completed {
+		ui.Say(fmt.Sprintf("Skipping %s, completed by a previous build", name))
+		return true, nil
+	}
+	if err := run(); err != nil {
+		return false, err
+	}
+	return false, m.Mark(ctx, name, checksum)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fileCommunicator keeps the files uploaded to it, and answers the stat
+// commands of the unix profile.
+type fileCommunicator struct {
+	packersdk.MockCommunicator
+
+	files map[string]string
+}
+
+func (c *fileCommunicator) Start(_ context.Context, rc *packersdk.RemoteCmd) error {
+	status := 0
+	if strings.HasPrefix(rc.Command, "stat ") {
+		if _, ok := c.files[strings.Trim(strings.TrimPrefix(rc.Command, "stat "), "'")]; !ok {
+			status = 1
+		}
+	}
+	if strings.HasPrefix(rc.Command, "rm -rf ") {
+		c.files = nil
+	}
+	rc.SetExited(status)
+	return nil
+}
+
+func (c *fileCommunicator) Upload(path string, r io.Reader, _ *os.FileInfo) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if c.files == nil {
+		c.files = map[string]string{}
+	}
+	c.files[path] = string(data)
+	return nil
+}
+
+func (c *fileCommunicator) Download(path string, w io.Writer) error {
+	data, ok := c.files[path]
+	if !ok {
+		return fmt.Errorf("%s not found", path)
+	}
+	_, err := io.WriteString(w, data)
+	return err
+}
+
+func TestCompletionMarkers(t *testing.T) {
+	ctx := context.Background()
+	comm := new(fileCommunicator)
+	commands, _ := NewGuestCommands(UnixOSType, false)
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: io.Discard, ErrorWriter: io.Discard}
+
+	runs := 0
+	run := func() error {
+		runs++
+		return nil
+	}
+
+	// Markers are written even when not resuming.
+	markers := &CompletionMarkers{Comm: comm, Commands: commands}
+	if skipped, err := markers.RunOnce(ctx, ui, `provisioner "shell" #1`, "abc", run); err != nil || skipped {
+		t.Fatalf("skipped: %t, err: %v", skipped, err)
+	}
+	if len(comm.files) != 1 {
+		t.Fatalf("expected a marker, got %v", comm.files)
+	}
+	for path := range comm.files {
+		if !strings.HasPrefix(path, DefaultUnixMarkersDir+"/provisioner_shell_1-") {
+			t.Fatalf("unexpected marker path %s", path)
+		}
+	}
+	if skipped, _ := markers.RunOnce(ctx, ui, `provisioner "shell" #1`, "abc", run); skipped {
+		t.Fatalf("skipped without resuming")
+	}
+
+	markers.Resume = true
+	if skipped, err := markers.RunOnce(ctx, ui, `provisioner "shell" #1`, "abc", run); err != nil || !skipped {
+		t.Fatalf("skipped: %t, err: %v", skipped, err)
+	}
+	// A changed configuration runs again.
+	if skipped, _ := markers.RunOnce(ctx, ui, `provisioner "shell" #1`, "def", run); skipped {
+		t.Fatalf("skipped with another configuration")
+	}
+	// So does another provisioner.
+	if skipped, _ := markers.RunOnce(ctx, ui, `provisioner "shell" #2`, "def", run); skipped {
+		t.Fatalf("skipped another provisioner")
+	}
+	if runs != 4 {
+		t.Fatalf("expected 4 runs, got %d", runs)
+	}
+
+	if err := markers.Clear(ctx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if completed, _ := markers.Completed(ctx, `provisioner "shell" #1`, "def"); completed {
+		t.Fatalf("completed after clearing the markers")
+	}
+}
+
+func TestCompletionMarkers_invalid(t *testing.T) {
+	ctx := context.Background()
+	comm := new(fileCommunicator)
+	commands, _ := NewGuestCommands(UnixOSType, false)
+	markers := &CompletionMarkers{Comm: comm, Commands: commands, Dir: "/opt/markers/", Resume: true}
+
+	if err := markers.Mark(ctx, "ansible", "abc"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for path := range comm.files {
+		if !strings.HasPrefix(path, "/opt/markers/ansible-") {
+			t.Fatalf("unexpected marker path %s", path)
+		}
+		comm.files[path] = `{"name":"ansi`
+	}
+	completed, err := markers.Completed(ctx, "ansible", "abc")
+	if err != nil || completed {
+		t.Fatalf("completed: %t, err: %v", completed, err)
+	}
+}
@@ -0,0 +1,478 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// The formats StaticNetwork renders the network configuration in.
+const (
+	// NetworkNetplan writes /etc/netplan/99-packer-static.yaml, for Ubuntu
+	// and the other distributions configured with netplan.
+	NetworkNetplan = "netplan"
+	// NetworkIfcfg writes /etc/sysconfig/network-scripts/ifcfg-*, for RHEL
+	// and its derivatives.
+	NetworkIfcfg = "ifcfg"
+	// NetworkWindows runs a PowerShell script.
+	NetworkWindows = "windows"
+)
+
+// netplanPath is the file NetworkNetplan writes. Netplan reads the files of
+// /etc/netplan in lexical order, the last one winning.
+const netplanPath = "/etc/netplan/99-packer-static.yaml"
+
+var (
+	interfaceNameRe = regexp.MustCompile(`^[A-Za-z0-9_.:@-]+$`)
+	searchDomainRe  = regexp.MustCompile(`^[A-Za-z0-9.-]+$`)
+)
+
+// StaticNetwork is the static network configuration of a guest, typically
+// given by the builder for networks without DHCP. Apply writes it in the
+// format of the guest and applies it through the communicator.
+type StaticNetwork struct {
+	Interfaces []NetworkInterface
+	// Format is NetworkNetplan, NetworkIfcfg or NetworkWindows. Apply
+	// detects it when empty.
+	Format string
+	// Detach applies the configuration in the background, a couple of
+	// seconds after Apply returns, for guests the communicator reaches
+	// through an address the configuration replaces: the connection drops
+	// and the builder reconnects to the new address.
+	Detach bool
+}
+
+// NetworkInterface is the configuration of a network interface.
+type NetworkInterface struct {
+	// Name is the name of the interface on the guest, like `ens192` or
+	// `Ethernet0`. Either Name or MACAddress must be set.
+	Name string
+	// MACAddress matches the interface by its MAC address, like
+	// `00:50:56:aa:bb:cc`, which is more reliable than names predicted
+	// from the hardware.
+	MACAddress string
+	// Addresses are the IPv4 and IPv6 addresses of the interface, in CIDR
+	// notation, like `10.0.0.10/24`.
+	Addresses []string
+	// Gateway and Gateway6 are the default IPv4 and IPv6 gateways.
+	Gateway  string
+	Gateway6 string
+	// DNSServers are the addresses of the DNS servers, and SearchDomains the
+	// domains unqualified names are looked up in.
+	DNSServers    []string
+	SearchDomains []string
+	// MTU is the MTU of the interface, left as is when 0.
+	MTU int
+}
+
+// NetworkFile is a file of a RenderedNetwork.
+type NetworkFile struct {
+	Path    string
+	Content string
+	// Mode is the mode of the file on Unix guests, like "0600".
+	Mode string
+}
+
+// RenderedNetwork is a StaticNetwork in the format of a guest: the files to
+// write, and the commands applying them, to run with elevated privileges.
+type RenderedNetwork struct {
+	Files    []NetworkFile
+	Commands []string
+}
+
+// Validate checks the addresses of the interfaces of n.
+func (n *StaticNetwork) Validate() error {
+	return n.validate(n.Format)
+}
+
+func (n *StaticNetwork) validate(format string) error {
+	if len(n.Interfaces) == 0 {
+		return errors.New("no network interface to configure")
+	}
+	var errs []error
+	for i, iface := range n.Interfaces {
+		if iface.Name == "" && iface.MACAddress == "" {
+			errs = append(errs, fmt.Errorf("interface %d: name or MAC address must be set", i))
+		}
+		if iface.Name != "" && format != NetworkWindows && !interfaceNameRe.MatchString(iface.Name) {
+			errs = append(errs, fmt.Errorf("interface %d: invalid name %q", i, iface.Name))
+		}
+		if iface.MACAddress != "" {
+			if _, err := net.ParseMAC(iface.MACAddress); err != nil {
+				errs = append(errs, fmt.Errorf("interface %d: %s", i, err))
+			}
+		}
+		if len(iface.Addresses) == 0 {
+			errs = append(errs, fmt.Errorf("interface %d: no address", i))
+		}
+		for _, addr := range iface.Addresses {
+			if _, _, err := net.ParseCIDR(addr); err != nil {
+				errs = append(errs, fmt.Errorf("interface %d: invalid address %q, expected CIDR notation like 10.0.0.10/24", i, addr))
+			}
+		}
+		if iface.Gateway != "" && !isIPv4(iface.Gateway) {
+			errs = append(errs, fmt.Errorf("interface %d: invalid IPv4 gateway %q", i, iface.Gateway))
+		}
+		if iface.Gateway6 != "" && (net.ParseIP(iface.Gateway6) == nil || isIPv4(iface.Gateway6)) {
+			errs = append(errs, fmt.Errorf("interface %d: invalid IPv6 gateway %q", i, iface.Gateway6))
+		}
+		for _, dns := range iface.DNSServers {
+			if net.ParseIP(dns) == nil {
+				errs = append(errs, fmt.Errorf("interface %d: invalid DNS server %q", i, dns))
+			}
+		}
+		for _, domain := range iface.SearchDomains {
+			if !searchDomainRe.MatchString(domain) {
+				errs = append(errs, fmt.Errorf("interface %d: invalid search domain %q", i, domain))
+			}
+		}
+		if iface.MTU != 0 && (iface.MTU < 68 || iface.MTU > 65535) {
+			errs = append(errs, fmt.Errorf("interface %d: invalid MTU %d", i, iface.MTU))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil
+}
+
+// Render renders n in format.
+func (n *StaticNetwork) Render(format string) (*RenderedNetwork, error) {
+	switch format {
+	case NetworkNetplan:
+		return n.renderNetplan(), nil
+	case NetworkIfcfg:
+		return n.renderIfcfg(), nil
+	case NetworkWindows:
+		return n.renderWindows(), nil
+	}
+	return nil, fmt.Errorf("unknown network configuration format %q, expected %s, %s or %s",
+		format, NetworkNetplan, NetworkIfcfg, NetworkWindows)
+}
+
+func (n *StaticNetwork) renderNetplan() *RenderedNetwork {
+	var b strings.Builder
+	b.WriteString("# Written by Packer\nnetwork:\n  version: 2\n  ethernets:\n")
+	for i, iface := range n.Interfaces {
+		id := iface.Name
+		if id == "" {
+			id = fmt.Sprintf("packer%d", i)
+		}
+		fmt.Fprintf(&b, "    %s:\n", id)
+		if iface.MACAddress != "" {
+			fmt.Fprintf(&b, "      match:\n        macaddress: %q\n", strings.ToLower(iface.MACAddress))
+			if iface.Name != "" {
+				fmt.Fprintf(&b, "      set-name: %s\n", iface.Name)
+			}
+		}
+		b.WriteString("      dhcp4: false\n      dhcp6: false\n      addresses:\n")
+		for _, addr := range iface.Addresses {
+			fmt.Fprintf(&b, "        - %q\n", addr)
+		}
+		if iface.Gateway != "" || iface.Gateway6 != "" {
+			b.WriteString("      routes:\n")
+			if iface.Gateway != "" {
+				fmt.Fprintf(&b, "        - to: default\n          via: %q\n", iface.Gateway)
+			}
+			if iface.Gateway6 != "" {
+				fmt.Fprintf(&b, "        - to: \"::/0\"\n          via: %q\n", iface.Gateway6)
+			}
+		}
+		if len(iface.DNSServers) > 0 || len(iface.SearchDomains) > 0 {
+			b.WriteString("      nameservers:\n")
+			if len(iface.DNSServers) > 0 {
+				fmt.Fprintf(&b, "        addresses: [%s]\n", yamlList(iface.DNSServers))
+			}
+			if len(iface.SearchDomains) > 0 {
+				fmt.Fprintf(&b, "        search: [%s]\n", yamlList(iface.SearchDomains))
+			}
+		}
+		if iface.MTU != 0 {
+			fmt.Fprintf(&b, "      mtu: %d\n", iface.MTU)
+		}
+	}
+	return &RenderedNetwork{
+		Files:    []NetworkFile{{Path: netplanPath, Content: b.String(), Mode: "0600"}},
+		Commands: []string{"netplan apply"},
+	}
+}
+
+func yamlList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (n *StaticNetwork) renderIfcfg() *RenderedNetwork {
+	r := &RenderedNetwork{}
+	for i, iface := range n.Interfaces {
+		name := iface.Name
+		if name == "" {
+			name = fmt.Sprintf("packer%d", i)
+		}
+		var b strings.Builder
+		b.WriteString("# Written by Packer\n")
+		fmt.Fprintf(&b, "NAME=%s\n", name)
+		if iface.Name != "" {
+			fmt.Fprintf(&b, "DEVICE=%s\n", iface.Name)
+		}
+		if iface.MACAddress != "" {
+			fmt.Fprintf(&b, "HWADDR=%s\n", strings.ToLower(iface.MACAddress))
+		}
+		b.WriteString("TYPE=Ethernet\nONBOOT=yes\nBOOTPROTO=none\n")
+
+		var v4, v6 []string
+		for _, addr := range iface.Addresses {
+			if ip, _, _ := net.ParseCIDR(addr); ip.To4() != nil {
+				v4 = append(v4, addr)
+			} else {
+				v6 = append(v6, addr)
+			}
+		}
+		for j, addr := range v4 {
+			ip, prefix, _ := strings.Cut(addr, "/")
+			suffix := ""
+			if j > 0 {
+				suffix = strconv.Itoa(j)
+			}
+			fmt.Fprintf(&b, "IPADDR%s=%s\nPREFIX%s=%s\n", suffix, ip, suffix, prefix)
+		}
+		if iface.Gateway != "" {
+			fmt.Fprintf(&b, "GATEWAY=%s\n", iface.Gateway)
+		}
+		if len(v6) > 0 {
+			fmt.Fprintf(&b, "IPV6INIT=yes\nIPV6_AUTOCONF=no\nIPV6ADDR=%s\n", v6[0])
+			if len(v6) > 1 {
+				fmt.Fprintf(&b, "IPV6ADDR_SECONDARIES=%q\n", strings.Join(v6[1:], " "))
+			}
+			if iface.Gateway6 != "" {
+				fmt.Fprintf(&b, "IPV6_DEFAULTGW=%s\n", iface.Gateway6)
+			}
+		}
+		for j, dns := range iface.DNSServers {
+			fmt.Fprintf(&b, "DNS%d=%s\n", j+1, dns)
+		}
+		if len(iface.SearchDomains) > 0 {
+			fmt.Fprintf(&b, "DOMAIN=%q\n", strings.Join(iface.SearchDomains, " "))
+		}
+		if iface.MTU != 0 {
+			fmt.Fprintf(&b, "MTU=%d\n", iface.MTU)
+		}
+
+		r.Files = append(r.Files, NetworkFile{
+			Path:    "/etc/sysconfig/network-scripts/ifcfg-" + name,
+			Content: b.String(),
+			Mode:    "0644",
+		})
+		// NetworkManager reads the ifcfg files on RHEL 7 and later, the
+		// network service on the older releases.
+		r.Commands = append(r.Commands, fmt.Sprintf(
+			"if command -v nmcli >/dev/null 2>&1; then nmcli connection reload && nmcli connection up %[1]s; else ifdown %[1]s 2>/dev/null; ifup %[1]s; fi",
+			shellQuote(name)))
+	}
+	return r
+}
+
+// windowsNetworkTask is the scheduled task applying the configuration in
+// the background on Windows, which outlives the WinRM shell.
+const windowsNetworkTask = "packer-static-network"
+
+func (n *StaticNetwork) renderWindows() *RenderedNetwork {
+	var b strings.Builder
+	b.WriteString("# Written by Packer\n$ErrorActionPreference = 'Stop'\n")
+	if n.Detach {
+		b.WriteString("Start-Sleep -Seconds 2\n")
+	}
+	for _, iface := range n.Interfaces {
+		if iface.MACAddress != "" {
+			mac := strings.ToUpper(strings.NewReplacer(":", "-").Replace(iface.MACAddress))
+			fmt.Fprintf(&b, "$adapter = Get-NetAdapter | Where-Object { $_.MacAddress -eq %s } | Select-Object -First 1\n", psQuote(mac))
+			b.WriteString("if (-not $adapter) { throw 'No network adapter with MAC address " + mac + "' }\n")
+			if iface.Name != "" {
+				fmt.Fprintf(&b, "if ($adapter.Name -ne %[1]s) { Rename-NetAdapter -Name $adapter.Name -NewName %[1]s; $adapter = Get-NetAdapter -Name %[1]s }\n", psQuote(iface.Name))
+			}
+		} else {
+			fmt.Fprintf(&b, "$adapter = Get-NetAdapter -Name %s\n", psQuote(iface.Name))
+		}
+		b.WriteString("$index = $adapter.ifIndex\n")
+		b.WriteString("Set-NetIPInterface -InterfaceIndex $index -Dhcp Disabled\n")
+		b.WriteString("Get-NetIPAddress -InterfaceIndex $index -ErrorAction SilentlyContinue | Where-Object { $_.PrefixOrigin -ne 'WellKnown' } | Remove-NetIPAddress -Confirm:$false\n")
+		b.WriteString("Get-NetRoute -InterfaceIndex $index -ErrorAction SilentlyContinue | Where-Object { $_.DestinationPrefix -in '0.0.0.0/0', '::/0' } | Remove-NetRoute -Confirm:$false\n")
+		for _, addr := range iface.Addresses {
+			ip, prefix, _ := strings.Cut(addr, "/")
+			fmt.Fprintf(&b, "New-NetIPAddress -InterfaceIndex $index -IPAddress %s -PrefixLength %s | Out-Null\n", psQuote(ip), prefix)
+		}
+		if iface.Gateway != "" {
+			fmt.Fprintf(&b, "New-NetRoute -InterfaceIndex $index -DestinationPrefix '0.0.0.0/0' -NextHop %s | Out-Null\n", psQuote(iface.Gateway))
+		}
+		if iface.Gateway6 != "" {
+			fmt.Fprintf(&b, "New-NetRoute -InterfaceIndex $index -DestinationPrefix '::/0' -NextHop %s | Out-Null\n", psQuote(iface.Gateway6))
+		}
+		if len(iface.DNSServers) > 0 {
+			fmt.Fprintf(&b, "Set-DnsClientServerAddress -InterfaceIndex $index -ServerAddresses @(%s)\n", psList(iface.DNSServers))
+		}
+		if len(iface.SearchDomains) > 0 {
+			fmt.Fprintf(&b, "Set-DnsClient -InterfaceIndex $index -ConnectionSpecificSuffix %s\n", psQuote(iface.SearchDomains[0]))
+			fmt.Fprintf(&b, "Set-DnsClientGlobalSetting -SuffixSearchList @(%s)\n", psList(iface.SearchDomains))
+		}
+		if iface.MTU != 0 {
+			fmt.Fprintf(&b, "Set-NetIPInterface -InterfaceIndex $index -NlMtuBytes %d\n", iface.MTU)
+		}
+	}
+	if n.Detach {
+		fmt.Fprintf(&b, "Unregister-ScheduledTask -TaskName %s -Confirm:$false -ErrorAction SilentlyContinue\n", psQuote(windowsNetworkTask))
+	}
+	b.WriteString("Remove-Item -Force $PSCommandPath\n")
+
+	path := "C:/Windows/Temp/packer-network-" + uuid.TimeOrderedUUID() + ".ps1"
+	return &RenderedNetwork{
+		Files:    []NetworkFile{{Path: path, Content: b.String()}},
+		Commands: []string{"powershell.exe -NoProfile -ExecutionPolicy Bypass -File " + path},
+	}
+}
+
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func psList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = psQuote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// DetectNetworkFormat returns the format the network configuration of the
+// guest is written in.
+func DetectNetworkFormat(ctx context.Context, comm packersdk.Communicator, commands *GuestCommands) (string, error) {
+	if commands.Family() == WindowsOSType {
+		return NetworkWindows, nil
+	}
+	for _, candidate := range []struct{ format, detect string }{
+		{NetworkNetplan, "test -d /etc/netplan && command -v netplan"},
+		{NetworkIfcfg, "test -d /etc/sysconfig/network-scripts"},
+	} {
+		_, _, status, err := startCommand(ctx, comm, candidate.detect)
+		if err != nil {
+			return "", err
+		}
+		if status == 0 {
+			return candidate.format, nil
+		}
+	}
+	return "", errors.New("the guest uses neither netplan nor ifcfg files to configure its network")
+}
+
+// Apply writes the configuration of n on the guest and applies it, with the
+// commands elevated as commands says.
+func (n *StaticNetwork) Apply(ctx context.Context, comm packersdk.Communicator, commands *GuestCommands) error {
+	format := n.Format
+	if format == "" {
+		var err error
+		if format, err = DetectNetworkFormat(ctx, comm, commands); err != nil {
+			return fmt.Errorf("Error detecting the network configuration of the guest: %s", err)
+		}
+	}
+	if err := n.validate(format); err != nil {
+		return err
+	}
+	rendered, err := n.Render(format)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Applying the static network configuration with %s", format)
+
+	if format == NetworkWindows {
+		return n.applyWindows(ctx, comm, rendered)
+	}
+	for _, f := range rendered.Files {
+		// The file is written by a shell running elevated, so that it
+		// belongs to root and is never readable by others on the way.
+		umask := fmt.Sprintf("%04o", 0777&^parseMode(f.Mode))
+		command := commands.sudo("sh -c " + shellQuote(fmt.Sprintf("umask %s && cat > %s", umask, shellQuote(f.Path))))
+		if err := runCommandStdin(ctx, comm, command, f.Content); err != nil {
+			return fmt.Errorf("Error writing %s: %s", f.Path, err)
+		}
+	}
+	for _, command := range rendered.Commands {
+		if n.Detach {
+			command = "nohup sh -c " + shellQuote("sleep 2; "+command) + " >/dev/null 2>&1 &"
+			command = commands.sudo("sh -c " + shellQuote(command))
+		} else {
+			command = commands.sudo("sh -c " + shellQuote(command))
+		}
+		if _, err := runCommand(ctx, comm, command); err != nil {
+			return fmt.Errorf("Error applying the network configuration: %s", err)
+		}
+	}
+	return nil
+}
+
+func (n *StaticNetwork) applyWindows(ctx context.Context, comm packersdk.Communicator, rendered *RenderedNetwork) error {
+	for _, f := range rendered.Files {
+		if err := comm.Upload(f.Path, strings.NewReader(f.Content), nil); err != nil {
+			return fmt.Errorf("Error uploading %s: %s", f.Path, err)
+		}
+	}
+	for _, command := range rendered.Commands {
+		if n.Detach {
+			// Processes started from a WinRM shell are killed with it: a
+			// scheduled task runs the script once the shell is gone.
+			command = fmt.Sprintf("powershell.exe -Command \"$a = New-ScheduledTaskAction -Execute powershell.exe -Argument %s; "+
+				"Register-ScheduledTask -TaskName %s -Action $a -User SYSTEM -RunLevel Highest -Force | Out-Null; "+
+				"Start-ScheduledTask -TaskName %s\"",
+				psQuote(strings.TrimPrefix(command, "powershell.exe ")), windowsNetworkTask, windowsNetworkTask)
+		}
+		if _, err := runCommand(ctx, comm, command); err != nil {
+			return fmt.Errorf("Error applying the network configuration: %s", err)
+		}
+	}
+	return nil
+}
+
+// parseMode parses the octal mode of a NetworkFile, 0644 when invalid.
+func parseMode(mode string) uint64 {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0644
+	}
+	return m
+}
+
+// runCommandStdin runs command with stdin as its input, and returns an error
+// if it exits with a non-zero status.
+func runCommandStdin(ctx context.Context, comm packersdk.Communicator, command, stdin string) error {
+	var stderr strings.Builder
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdin:   strings.NewReader(stdin),
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return err
+	}
+	if status := cmd.Wait(); status != 0 {
+		return fmt.Errorf("%q exited with status %d: %s", command, status, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
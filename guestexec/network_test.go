@@ -0,0 +1,243 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// networkCommunicator records the commands it runs with their input, and the
+// files uploaded. The commands containing failing exit with status 1.
+type networkCommunicator struct {
+	packersdk.MockCommunicator
+
+	failing  string
+	commands []string
+	stdins   []string
+	uploads  map[string]string
+}
+
+func (c *networkCommunicator) Start(_ context.Context, rc *packersdk.RemoteCmd) error {
+	c.commands = append(c.commands, rc.Command)
+	stdin := ""
+	if rc.Stdin != nil {
+		b, _ := io.ReadAll(rc.Stdin)
+		stdin = string(b)
+	}
+	c.stdins = append(c.stdins, stdin)
+	if c.failing != "" && strings.Contains(rc.Command, c.failing) {
+		rc.SetExited(1)
+		return nil
+	}
+	rc.SetExited(0)
+	return nil
+}
+
+func (c *networkCommunicator) Upload(path string, r io.Reader, _ *os.FileInfo) error {
+	b, _ := io.ReadAll(r)
+	if c.uploads == nil {
+		c.uploads = map[string]string{}
+	}
+	c.uploads[path] = string(b)
+	return nil
+}
+
+func testStaticNetwork() *StaticNetwork {
+	return &StaticNetwork{Interfaces: []NetworkInterface{{
+		Name:          "ens192",
+		MACAddress:    "00:50:56:AA:BB:CC",
+		Addresses:     []string{"10.0.0.10/24", "fd00::10/64"},
+		Gateway:       "10.0.0.1",
+		Gateway6:      "fd00::1",
+		DNSServers:    []string{"10.0.0.2", "10.0.0.3"},
+		SearchDomains: []string{"example.com"},
+		MTU:           9000,
+	}}}
+}
+
+func TestStaticNetwork_renderNetplan(t *testing.T) {
+	r, err := testStaticNetwork().Render(NetworkNetplan)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `# Written by Packer
+network:
+  version: 2
+  ethernets:
+    ens192:
+      match:
+        macaddress: "00:50:56:aa:bb:cc"
+      set-name: ens192
+      dhcp4: false
+      dhcp6: false
+      addresses:
+        - "10.0.0.10/24"
+        - "fd00::10/64"
+      routes:
+        - to: default
+          via: "10.0.0.1"
+        - to: "::/0"
+          via: "fd00::1"
+      nameservers:
+        addresses: ["10.0.0.2", "10.0.0.3"]
+        search: ["example.com"]
+      mtu: 9000
+`
+	if len(r.Files) != 1 || r.Files[0].Path != netplanPath || r.Files[0].Mode != "0600" {
+		t.Fatalf("bad files: %#v", r.Files)
+	}
+	if r.Files[0].Content != expected {
+		t.Fatalf("bad netplan configuration:\n%s", r.Files[0].Content)
+	}
+	if len(r.Commands) != 1 || r.Commands[0] != "netplan apply" {
+		t.Fatalf("bad commands: %#v", r.Commands)
+	}
+}
+
+func TestStaticNetwork_renderIfcfg(t *testing.T) {
+	r, err := testStaticNetwork().Render(NetworkIfcfg)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(r.Files) != 1 || r.Files[0].Path != "/etc/sysconfig/network-scripts/ifcfg-ens192" {
+		t.Fatalf("bad files: %#v", r.Files)
+	}
+	for _, line := range []string{
+		"NAME=ens192", "DEVICE=ens192", "HWADDR=00:50:56:aa:bb:cc", "BOOTPROTO=none",
+		"IPADDR=10.0.0.10", "PREFIX=24", "GATEWAY=10.0.0.1",
+		"IPV6ADDR=fd00::10/64", "IPV6_DEFAULTGW=fd00::1",
+		"DNS1=10.0.0.2", "DNS2=10.0.0.3", `DOMAIN="example.com"`, "MTU=9000",
+	} {
+		if !strings.Contains(r.Files[0].Content, line+"\n") {
+			t.Errorf("expected %s in:\n%s", line, r.Files[0].Content)
+		}
+	}
+	if len(r.Commands) != 1 || !strings.Contains(r.Commands[0], "nmcli connection up 'ens192'") {
+		t.Fatalf("bad commands: %#v", r.Commands)
+	}
+}
+
+func TestStaticNetwork_renderWindows(t *testing.T) {
+	n := testStaticNetwork()
+	n.Interfaces[0].Name = "Ethernet 0"
+	r, err := n.Render(NetworkWindows)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	script := r.Files[0].Content
+	for _, line := range []string{
+		"$adapter = Get-NetAdapter | Where-Object { $_.MacAddress -eq '00-50-56-AA-BB-CC' } | Select-Object -First 1",
+		"if ($adapter.Name -ne 'Ethernet 0') { Rename-NetAdapter -Name $adapter.Name -NewName 'Ethernet 0'; $adapter = Get-NetAdapter -Name 'Ethernet 0' }",
+		"New-NetIPAddress -InterfaceIndex $index -IPAddress '10.0.0.10' -PrefixLength 24 | Out-Null",
+		"New-NetRoute -InterfaceIndex $index -DestinationPrefix '0.0.0.0/0' -NextHop '10.0.0.1' | Out-Null",
+		"Set-DnsClientServerAddress -InterfaceIndex $index -ServerAddresses @('10.0.0.2', '10.0.0.3')",
+		"Set-NetIPInterface -InterfaceIndex $index -NlMtuBytes 9000",
+	} {
+		if !strings.Contains(script, line+"\n") {
+			t.Errorf("expected %s in:\n%s", line, script)
+		}
+	}
+	if !strings.HasSuffix(r.Commands[0], "-File "+r.Files[0].Path) {
+		t.Fatalf("bad commands: %#v", r.Commands)
+	}
+}
+
+func TestStaticNetwork_Validate(t *testing.T) {
+	n := &StaticNetwork{Interfaces: []NetworkInterface{{
+		Name:          "eth0; reboot",
+		MACAddress:    "not a mac",
+		Addresses:     []string{"10.0.0.10"},
+		Gateway:       "fd00::1",
+		DNSServers:    []string{"dns.example.com"},
+		SearchDomains: []string{"example.com'"},
+		MTU:           10,
+	}}}
+	err := n.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{
+		`invalid name "eth0; reboot"`, "invalid MAC address", `invalid address "10.0.0.10"`,
+		`invalid IPv4 gateway "fd00::1"`, `invalid DNS server`, "invalid search domain", "invalid MTU 10",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %s", expected, err)
+		}
+	}
+
+	if err := testStaticNetwork().Validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := (&StaticNetwork{}).Validate(); err == nil {
+		t.Fatal("expected an error without interfaces")
+	}
+}
+
+func TestStaticNetwork_Apply(t *testing.T) {
+	comm := &networkCommunicator{failing: "/etc/netplan"}
+	commands, _ := NewGuestCommands(UnixOSType, true)
+	n := testStaticNetwork()
+	if err := n.Apply(context.Background(), comm, commands); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// netplan is not detected, ifcfg is.
+	expected := []string{
+		"test -d /etc/netplan && command -v netplan",
+		"test -d /etc/sysconfig/network-scripts",
+		`sudo sh -c 'umask 0133 && cat > '\''/etc/sysconfig/network-scripts/ifcfg-ens192'\'''`,
+	}
+	for i, command := range expected {
+		if comm.commands[i] != command {
+			t.Fatalf("command %d: expected %s, got %s", i, command, comm.commands[i])
+		}
+	}
+	if !strings.Contains(comm.stdins[2], "IPADDR=10.0.0.10\n") {
+		t.Fatalf("bad configuration written: %s", comm.stdins[2])
+	}
+	if last := comm.commands[len(comm.commands)-1]; !strings.HasPrefix(last, "sudo sh -c 'if command -v nmcli") {
+		t.Fatalf("bad apply command: %s", last)
+	}
+
+	comm = &networkCommunicator{}
+	n.Format, n.Detach = NetworkNetplan, true
+	if err := n.Apply(context.Background(), comm, commands); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if last := comm.commands[len(comm.commands)-1]; !strings.Contains(last, "nohup sh -c") || !strings.HasSuffix(last, "&'") {
+		t.Fatalf("the configuration should be applied in the background, got %s", last)
+	}
+
+	comm = &networkCommunicator{failing: "netplan apply"}
+	n.Detach = false
+	if err := n.Apply(context.Background(), comm, commands); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestStaticNetwork_ApplyWindows(t *testing.T) {
+	comm := &networkCommunicator{}
+	commands, _ := NewGuestCommands(WindowsOSType, false)
+	n := testStaticNetwork()
+	n.Detach = true
+	if err := n.Apply(context.Background(), comm, commands); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(comm.uploads) != 1 {
+		t.Fatalf("expected the script to be uploaded, got %#v", comm.uploads)
+	}
+	for path, script := range comm.uploads {
+		if !strings.HasPrefix(path, "C:/Windows/Temp/packer-network-") || !strings.Contains(script, "Unregister-ScheduledTask") {
+			t.Fatalf("bad upload %s:\n%s", path, script)
+		}
+	}
+	if len(comm.commands) != 1 || !strings.Contains(comm.commands[0], "Register-ScheduledTask -TaskName packer-static-network") {
+		t.Fatalf("bad commands: %#v", comm.commands)
+	}
+}
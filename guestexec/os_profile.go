@@ -0,0 +1,189 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The OS profiles registered besides UnixOSType and WindowsOSType.
+const (
+	AlpineOSType      = "alpine"
+	FreeBSDOSType     = "freebsd"
+	MacOSType         = "macos"
+	WindowsCoreOSType = "windows-core"
+)
+
+// An OSProfile tells how to run common commands on a kind of guest OS. The
+// commands are fmt formats taking the arguments of the GuestCommands method
+// of the same name.
+type OSProfile struct {
+	// Name is the osType of the profile, as given to NewGuestCommands.
+	Name string
+	// Family is UnixOSType or WindowsOSType, for the code only knowing
+	// those.
+	Family string
+	// Detect is a command exiting with a zero status on guests of this
+	// profile, used by DetectOSProfile. Profiles without one are never
+	// detected.
+	Detect string
+	// Elevate is the command prefixing the commands to run with elevated
+	// privileges when Sudo is set, like "sudo"; they run as is when it is
+	// empty.
+	Elevate string
+	// TempDir is the system temporary directory.
+	TempDir string
+
+	Chmod         string
+	Mkdir         string
+	RemoveDir     string
+	StatPath      string
+	Move          string
+	FreeSpace     string
+	FreeMemory    string
+	HasBinary     string
+	CreateTempDir string
+	HasPackage    string
+	HasService    string
+}
+
+var (
+	osProfilesLock sync.RWMutex
+	osProfiles     = map[string]OSProfile{}
+	// osProfileOrder is the order profiles were registered in.
+	osProfileOrder []string
+)
+
+// RegisterOSProfile adds profile to the profiles NewGuestCommands and
+// DetectOSProfile know about, so that plugins can support guest OSes the SDK
+// doesn't. It panics if a profile of the same name is already registered.
+func RegisterOSProfile(profile OSProfile) {
+	osProfilesLock.Lock()
+	defer osProfilesLock.Unlock()
+	if _, ok := osProfiles[profile.Name]; ok {
+		panic(fmt.Sprintf("guestexec: OS profile %q registered twice", profile.Name))
+	}
+	osProfiles[profile.Name] = profile
+	osProfileOrder = append(osProfileOrder, profile.Name)
+}
+
+// LookupOSProfile returns the registered profile name.
+func LookupOSProfile(name string) (OSProfile, bool) {
+	osProfilesLock.RLock()
+	defer osProfilesLock.RUnlock()
+	p, ok := osProfiles[name]
+	return p, ok
+}
+
+// OSProfiles returns the names of the registered profiles, in the order they
+// were registered.
+func OSProfiles() []string {
+	osProfilesLock.RLock()
+	defer osProfilesLock.RUnlock()
+	return append([]string(nil), osProfileOrder...)
+}
+
+// DetectOSProfile runs the Detect command of the registered profiles on the
+// guest, the last registered first so that specific profiles win over the
+// generic unix and windows ones, and returns the first matching profile.
+func DetectOSProfile(ctx context.Context, comm packersdk.Communicator) (OSProfile, error) {
+	names := OSProfiles()
+	for i := len(names) - 1; i >= 0; i-- {
+		p, _ := LookupOSProfile(names[i])
+		if p.Detect == "" {
+			continue
+		}
+		_, _, status, err := startCommand(ctx, comm, p.Detect)
+		if err != nil {
+			return OSProfile{}, fmt.Errorf("Error detecting the OS of the guest: %s", err)
+		}
+		if status == 0 {
+			log.Printf("[INFO] Detected the %s OS profile", p.Name)
+			return p, nil
+		}
+	}
+	return OSProfile{}, fmt.Errorf("the OS of the guest matches none of the profiles %v", names)
+}
+
+func init() {
+	unix := OSProfile{
+		Name:          UnixOSType,
+		Family:        UnixOSType,
+		Detect:        "uname -s",
+		Elevate:       "sudo",
+		TempDir:       "/tmp",
+		Chmod:         "chmod %s '%s'",
+		Mkdir:         "mkdir -p '%s'",
+		RemoveDir:     "rm -rf '%s'",
+		StatPath:      "stat '%s'",
+		Move:          "mv '%s' '%s'",
+		FreeSpace:     "df -Pk '%s' | awk 'NR==2 {printf \"%%.0f\\n\", $4 * 1024}'",
+		FreeMemory:    "awk '/^MemAvailable:/ {printf \"%.0f\\n\", $2 * 1024}' /proc/meminfo",
+		HasBinary:     "command -v '%s'",
+		CreateTempDir: "mktemp -d \"${TMPDIR:-/tmp}/%sXXXXXXXX\"",
+		HasPackage:    "dpkg-query -W -f='${Status}' '%[1]s' 2>/dev/null | grep -q 'ok installed' || rpm -q '%[1]s' >/dev/null 2>&1 || apk info -e '%[1]s' >/dev/null 2>&1",
+		HasService:    "systemctl is-active --quiet '%[1]s' 2>/dev/null || service '%[1]s' status >/dev/null 2>&1",
+	}
+	windows := OSProfile{
+		Name:       WindowsOSType,
+		Family:     WindowsOSType,
+		Detect:     "cmd /c ver",
+		TempDir:    "C:/Windows/Temp",
+		Chmod:      "echo 'skipping chmod %s %s'", // no-op
+		Mkdir:      "powershell.exe -Command \"New-Item -ItemType directory -Force -ErrorAction SilentlyContinue -Path %s\"",
+		RemoveDir:  "powershell.exe -Command \"rm %s -recurse -force\"",
+		StatPath:   "powershell.exe -Command { if (test-path %s) { exit 0 } else { exit 1 } }",
+		Move:       "powershell.exe -Command \"mv %s %s -force\"",
+		FreeSpace:  "powershell.exe -Command \"(Get-Item %s).PSDrive.Free\"",
+		FreeMemory: "powershell.exe -Command \"(Get-CimInstance Win32_OperatingSystem).FreePhysicalMemory * 1024\"",
+		HasBinary:  "powershell.exe -Command \"if (Get-Command %s -ErrorAction SilentlyContinue) { exit 0 } else { exit 1 }\"",
+		CreateTempDir: "powershell.exe -Command \"$d = New-Item -ItemType Directory -Path (Join-Path ([IO.Path]::GetTempPath()) ('%s' + [guid]::NewGuid())); " +
+			"icacls $d.FullName /inheritance:r /grant:r ('*' + [Security.Principal.WindowsIdentity]::GetCurrent().User.Value + ':(OI)(CI)F') '*S-1-5-18:(OI)(CI)F' | Out-Null; " +
+			"if ($LASTEXITCODE -ne 0) { exit $LASTEXITCODE }; $d.FullName\"",
+		HasPackage: "powershell.exe -Command \"if (Get-Package -Name '%s' -ErrorAction SilentlyContinue) { exit 0 } else { exit 1 }\"",
+		HasService: "powershell.exe -Command \"if ((Get-Service -Name '%s' -ErrorAction SilentlyContinue).Status -eq 'Running') { exit 0 } else { exit 1 }\"",
+	}
+	RegisterOSProfile(unix)
+	RegisterOSProfile(windows)
+
+	// Alpine and other busybox systems use OpenRC, and doas in the cloud
+	// images.
+	alpine := unix
+	alpine.Name = AlpineOSType
+	alpine.Detect = "test -f /etc/alpine-release || readlink \"$(command -v sh)\" | grep -q busybox"
+	alpine.Elevate = "doas"
+	alpine.HasPackage = "apk info -e '%s' >/dev/null 2>&1"
+	alpine.HasService = "rc-service '%s' status >/dev/null 2>&1"
+	RegisterOSProfile(alpine)
+
+	freebsd := unix
+	freebsd.Name = FreeBSDOSType
+	freebsd.Detect = "test \"$(uname -s)\" = FreeBSD"
+	freebsd.FreeMemory = "echo $(( $(sysctl -n vm.stats.vm.v_free_count) * $(sysctl -n hw.pagesize) ))"
+	freebsd.HasPackage = "pkg info -e '%s'"
+	freebsd.HasService = "service '%s' status >/dev/null 2>&1"
+	RegisterOSProfile(freebsd)
+
+	macos := unix
+	macos.Name = MacOSType
+	macos.Detect = "test \"$(uname -s)\" = Darwin"
+	macos.TempDir = "/private/tmp"
+	macos.FreeMemory = "vm_stat | awk '/page size of/ {ps = $8} /^Pages free/ {gsub(/\\./, \"\", $3); printf \"%.0f\\n\", $3 * ps}'"
+	macos.HasPackage = "pkgutil --pkg-info '%[1]s' >/dev/null 2>&1 || brew list --versions '%[1]s' >/dev/null 2>&1"
+	macos.HasService = "launchctl list '%s' >/dev/null 2>&1"
+	RegisterOSProfile(macos)
+
+	// Server Core has no Explorer, and its optional components are
+	// features rather than packages.
+	core := windows
+	core.Name = WindowsCoreOSType
+	core.Detect = "powershell.exe -Command \"if (Test-Path (Join-Path $env:windir 'explorer.exe')) { exit 1 } else { exit 0 }\""
+	core.HasPackage = "powershell.exe -Command \"if ((Get-WindowsFeature -Name '%[1]s' -ErrorAction SilentlyContinue).Installed -or (Get-Package -Name '%[1]s' -ErrorAction SilentlyContinue)) { exit 0 } else { exit 1 }\""
+	RegisterOSProfile(core)
+}
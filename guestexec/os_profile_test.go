@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRegisterOSProfile(t *testing.T) {
+	haiku := OSProfile{
+		Name:    "haiku-test",
+		Family:  UnixOSType,
+		Elevate: "",
+		Mkdir:   "mkdir -p '%s'",
+	}
+	RegisterOSProfile(haiku)
+
+	names := OSProfiles()
+	if names[len(names)-1] != "haiku-test" {
+		t.Fatalf("the profile should be registered last: %v", names)
+	}
+	commands, err := NewGuestCommands("haiku-test", true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if commands.Family() != UnixOSType {
+		t.Fatalf("bad family: %s", commands.Family())
+	}
+	// Profiles without an elevation command run commands as is.
+	if cmd := commands.CreateDir("/boot/home/tmp"); cmd != "mkdir -p '/boot/home/tmp'" {
+		t.Fatalf("unexpected command: %s", cmd)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a profile twice should panic")
+		}
+	}()
+	RegisterOSProfile(haiku)
+}
+
+func TestOSProfile_Alpine(t *testing.T) {
+	commands, err := NewGuestCommands(AlpineOSType, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if cmd := commands.CreateDir("/tmp/dir"); cmd != "doas mkdir -p '/tmp/dir'" {
+		t.Fatalf("unexpected command: %s", cmd)
+	}
+	if cmd := commands.HasPackage("curl"); cmd != "apk info -e 'curl' >/dev/null 2>&1" {
+		t.Fatalf("unexpected command: %s", cmd)
+	}
+	if commands.Family() != UnixOSType {
+		t.Fatalf("bad family: %s", commands.Family())
+	}
+}
+
+func TestDetectOSProfile(t *testing.T) {
+	unix, _ := LookupOSProfile(UnixOSType)
+	alpine, _ := LookupOSProfile(AlpineOSType)
+	windows, _ := LookupOSProfile(WindowsOSType)
+
+	cases := []struct {
+		results  map[string]scriptedResult
+		expected string
+	}{
+		{map[string]scriptedResult{unix.Detect: {stdout: "Linux\n"}}, UnixOSType},
+		{map[string]scriptedResult{unix.Detect: {stdout: "Linux\n"}, alpine.Detect: {}}, AlpineOSType},
+		{map[string]scriptedResult{windows.Detect: {}}, WindowsOSType},
+	}
+	for _, tc := range cases {
+		p, err := DetectOSProfile(context.Background(), &scriptedCommunicator{results: tc.results})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if p.Name != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, p.Name)
+		}
+	}
+
+	if _, err := DetectOSProfile(context.Background(), &scriptedCommunicator{}); err == nil {
+		t.Fatal("guests matching no profile should fail")
+	}
+}
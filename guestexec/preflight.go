@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Preflight describes the resources a provisioner needs on the guest. Checking
+// them before provisioning starts turns a script failing halfway with a cryptic
+// message into an error telling what is missing.
+type Preflight struct {
+	// MinFreeDisk maps remote paths to the number of bytes that must be
+	// available on the filesystem holding them.
+	MinFreeDisk map[string]uint64
+	// MinFreeMemory is the number of bytes of memory that must be available.
+	MinFreeMemory uint64
+	// Binaries that must be found in the PATH of the guest.
+	Binaries []string
+}
+
+// Check runs every check of p on the guest and returns an error listing all
+// the ones that failed. A check whose result cannot be read from the guest,
+// for example because df or /proc/meminfo are not available, is logged and
+// skipped rather than reported.
+func (p *Preflight) Check(ctx context.Context, comm packersdk.Communicator, commands *GuestCommands) error {
+	var errs []error
+
+	paths := make([]string, 0, len(p.MinFreeDisk))
+	for path := range p.MinFreeDisk {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		free, err := FreeSpace(ctx, comm, commands, path)
+		if err != nil {
+			log.Printf("[WARN] Could not check free space in %s: %s", path, err)
+			continue
+		}
+		if required := p.MinFreeDisk[path]; free < required {
+			errs = append(errs, fmt.Errorf("needs %s free in %s, only %s available",
+				formatBytes(required), path, formatBytes(free)))
+		}
+	}
+
+	if p.MinFreeMemory > 0 {
+		free, err := freeMemory(ctx, comm, commands)
+		if err != nil {
+			log.Printf("[WARN] Could not check free memory: %s", err)
+		} else if free < p.MinFreeMemory {
+			errs = append(errs, fmt.Errorf("needs %s of free memory, only %s available",
+				formatBytes(p.MinFreeMemory), formatBytes(free)))
+		}
+	}
+
+	var missing []string
+	for _, name := range p.Binaries {
+		_, _, status, err := startCommand(ctx, comm, commands.HasBinary(name))
+		if err != nil {
+			return err
+		}
+		if status != 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("needs %s to be installed and in the PATH",
+			strings.Join(missing, ", ")))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Guest preflight checks failed:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func freeMemory(ctx context.Context, comm packersdk.Communicator, commands *GuestCommands) (uint64, error) {
+	out, err := runCommand(ctx, comm, commands.FreeMemory())
+	if err != nil {
+		return 0, err
+	}
+	free, err := strconv.ParseUint(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output %q", out)
+	}
+	return free, nil
+}
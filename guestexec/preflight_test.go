@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type scriptedResult struct {
+	stdout string
+	status int
+}
+
+// scriptedCommunicator answers each command with a predefined result, and
+// fails the commands it doesn't know about.
+type scriptedCommunicator struct {
+	packersdk.MockCommunicator
+
+	results map[string]scriptedResult
+}
+
+func (c *scriptedCommunicator) Start(_ context.Context, rc *packersdk.RemoteCmd) error {
+	res, ok := c.results[rc.Command]
+	if !ok {
+		rc.SetExited(127)
+		return nil
+	}
+	io.WriteString(rc.Stdout, res.stdout)
+	rc.SetExited(res.status)
+	return nil
+}
+
+func TestPreflight(t *testing.T) {
+	commands, _ := NewGuestCommands(UnixOSType, false)
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{
+		commands.FreeSpace("/tmp"):    {stdout: "1073741824\n"},
+		commands.FreeSpace("/var"):    {stdout: "4294967296\n"},
+		commands.FreeMemory():         {stdout: "536870912\n"},
+		commands.HasBinary("curl"):    {stdout: "/usr/bin/curl\n"},
+		commands.HasBinary("ansible"): {status: 1},
+		commands.HasBinary("python3"): {status: 1},
+		commands.HasBinary("unzip"):   {stdout: "/usr/bin/unzip\n"},
+	}}
+
+	ok := &Preflight{
+		MinFreeDisk:   map[string]uint64{"/tmp": 1 << 30, "/var": 1 << 30},
+		MinFreeMemory: 256 << 20,
+		Binaries:      []string{"curl", "unzip"},
+	}
+	if err := ok.Check(context.Background(), comm, commands); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	failing := &Preflight{
+		MinFreeDisk:   map[string]uint64{"/tmp": 2 << 30, "/var": 1 << 30},
+		MinFreeMemory: 1 << 30,
+		Binaries:      []string{"curl", "ansible", "python3"},
+	}
+	err := failing.Check(context.Background(), comm, commands)
+	expected := "Guest preflight checks failed:\n" +
+		"needs 2.0GiB free in /tmp, only 1.0GiB available\n" +
+		"needs 1.0GiB of free memory, only 512.0MiB available\n" +
+		"needs ansible, python3 to be installed and in the PATH"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPreflight_unreadable(t *testing.T) {
+	commands, _ := NewGuestCommands(UnixOSType, false)
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{
+		commands.FreeSpace("/tmp"): {stdout: "df: not found\n"},
+	}}
+
+	p := &Preflight{
+		MinFreeDisk:   map[string]uint64{"/tmp": 1 << 30},
+		MinFreeMemory: 1 << 30,
+	}
+	if err := p.Check(context.Background(), comm, commands); err != nil {
+		t.Fatalf("checks that cannot be run should be skipped, got: %s", err)
+	}
+}
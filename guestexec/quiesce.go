@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Quiescer prepares the filesystems of a guest for a snapshot or an export of
+// its disks, so that the image does not contain writes still in the guest
+// caches.
+type Quiescer interface {
+	// Quiesce flushes the filesystems, and blocks writes to them when the
+	// guest supports it. Thaw must be called once the snapshot is taken,
+	// even when Quiesce fails.
+	Quiesce(ctx context.Context, comm packersdk.Communicator) error
+
+	// Thaw resumes the writes blocked by Quiesce.
+	Thaw(ctx context.Context, comm packersdk.Communicator) error
+}
+
+// NewQuiescer returns the Quiescer of the guests of type osType.
+//
+// On Unix, the filesystems are synced, then the ones mounted at freezePaths
+// are frozen with fsfreeze. The root filesystem should not be frozen when
+// the communicator needs to write to it, to log the sessions for example.
+//
+// On Windows, the caches of the fixed volumes are written with
+// Write-VolumeCache. Windows can only keep volumes frozen for a few seconds,
+// within a VSS snapshot, which cannot span several commands, so freezePaths
+// must be empty.
+func NewQuiescer(osType string, sudo bool, freezePaths []string) (Quiescer, error) {
+	commands, err := NewGuestCommands(osType, sudo)
+	if err != nil {
+		return nil, err
+	}
+	switch commands.Family() {
+	case WindowsOSType:
+		if len(freezePaths) > 0 {
+			return nil, fmt.Errorf("filesystems can't be frozen on Windows guests")
+		}
+		return &windowsQuiescer{}, nil
+	default:
+		return &unixQuiescer{commands: commands, freezePaths: freezePaths}, nil
+	}
+}
+
+type unixQuiescer struct {
+	commands    *GuestCommands
+	freezePaths []string
+	frozen      []string
+}
+
+func (q *unixQuiescer) Quiesce(ctx context.Context, comm packersdk.Communicator) error {
+	if _, err := runCommand(ctx, comm, q.commands.sudo("sync")); err != nil {
+		return fmt.Errorf("Error syncing the guest filesystems: %s", err)
+	}
+	for _, path := range q.freezePaths {
+		log.Printf("[INFO] Freezing the filesystem mounted at %s", path)
+		if _, err := runCommand(ctx, comm, q.commands.sudo(fmt.Sprintf("fsfreeze --freeze '%s'", path))); err != nil {
+			return fmt.Errorf("Error freezing %s: %s", path, err)
+		}
+		q.frozen = append(q.frozen, path)
+	}
+	return nil
+}
+
+// Thaw unfreezes the filesystems in the reverse order they were frozen, and
+// returns the first error.
+func (q *unixQuiescer) Thaw(ctx context.Context, comm packersdk.Communicator) error {
+	var first error
+	for i := len(q.frozen) - 1; i >= 0; i-- {
+		path := q.frozen[i]
+		log.Printf("[INFO] Unfreezing the filesystem mounted at %s", path)
+		if _, err := runCommand(ctx, comm, q.commands.sudo(fmt.Sprintf("fsfreeze --unfreeze '%s'", path))); err != nil && first == nil {
+			first = fmt.Errorf("Error unfreezing %s: %s", path, err)
+		}
+	}
+	q.frozen = nil
+	return first
+}
+
+const windowsFlushVolumes = `powershell.exe -Command "Get-Volume | ` +
+	`Where-Object { $_.DriveLetter -and $_.DriveType -eq 'Fixed' } | ` +
+	`ForEach-Object { Write-VolumeCache -DriveLetter $_.DriveLetter }"`
+
+type windowsQuiescer struct{}
+
+func (q *windowsQuiescer) Quiesce(ctx context.Context, comm packersdk.Communicator) error {
+	if _, err := runCommand(ctx, comm, windowsFlushVolumes); err != nil {
+		return fmt.Errorf("Error flushing the guest volumes: %s", err)
+	}
+	return nil
+}
+
+func (q *windowsQuiescer) Thaw(context.Context, packersdk.Communicator) error {
+	return nil
+}
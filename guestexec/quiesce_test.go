@@ -0,0 +1,47 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUnixQuiescer(t *testing.T) {
+	q, err := NewQuiescer(UnixOSType, true, []string{"/data", "/var/lib/db"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{
+		"sudo sync":                            {},
+		"sudo fsfreeze --freeze '/data'":       {},
+		"sudo fsfreeze --freeze '/var/lib/db'": {status: 1},
+		"sudo fsfreeze --unfreeze '/data'":     {},
+	}}
+
+	if err := q.Quiesce(context.Background(), comm); err == nil {
+		t.Fatal("expected an error freezing /var/lib/db")
+	}
+	// Only what was frozen is thawed.
+	if err := q.Thaw(context.Background(), comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if frozen := q.(*unixQuiescer).frozen; len(frozen) != 0 {
+		t.Fatalf("filesystems still frozen: %#v", frozen)
+	}
+}
+
+func TestWindowsQuiescer(t *testing.T) {
+	if _, err := NewQuiescer(WindowsOSType, false, []string{"C:"}); err == nil {
+		t.Fatal("expected an error freezing on Windows")
+	}
+	q, err := NewQuiescer(WindowsOSType, false, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	comm := &scriptedCommunicator{results: map[string]scriptedResult{windowsFlushVolumes: {}}}
+	if err := q.Quiesce(context.Background(), comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"syscall"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+// ResetPolicy tells RunWithResetRetry how to handle a script losing its
+// connection to the guest, as happens with WinRM when a script restarts the
+// WinRM service, changes the network configuration or installs updates.
+type ResetPolicy struct {
+	// Tries is the maximum number of times the script is run. Defaults to 2,
+	// so that the script is retried once.
+	Tries int
+	// Delay to wait before retrying, so that the guest has time to settle.
+	// Defaults to 10 seconds.
+	Delay time.Duration
+	// IsReset reports whether the script failed because the connection was
+	// reset, from the error returned by the communicator and the exit status
+	// of the script. Defaults to IsConnectionReset.
+	IsReset func(err error, status int) bool
+	// Reconnect, if set, is called before retrying, for example to restart
+	// the communicator. Retrying stops if it fails.
+	Reconnect func(ctx context.Context) error
+}
+
+// ErrConnectionReset is wrapped by the errors of RunWithResetRetry when the
+// connection was still reset after the last try.
+var ErrConnectionReset = errors.New("connection to the guest was reset")
+
+// IsConnectionReset is the default ResetPolicy.IsReset. It recognizes the
+// CmdDisconnect exit status as well as the errors returned by the standard
+// library and the SSH and WinRM clients when the other side goes away.
+func IsConnectionReset(err error, status int) bool {
+	if status == packersdk.CmdDisconnect {
+		return true
+	}
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Errors coming from plugins lost their type on the way.
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"connection reset",
+		"broken pipe",
+		"forcibly closed by the remote host",
+		"unexpected eof",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// resetError marks a try that failed because the connection was reset.
+type resetError struct {
+	err    error
+	status int
+}
+
+func (e *resetError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %s", ErrConnectionReset, e.err)
+	}
+	return fmt.Sprintf("%s, exit status %d", ErrConnectionReset, e.status)
+}
+
+func (e *resetError) Unwrap() []error {
+	if e.err != nil {
+		return []error{ErrConnectionReset, e.err}
+	}
+	return []error{ErrConnectionReset}
+}
+
+// RunWithResetRetry runs the command returned by newCmd with RunWithUi, and
+// runs it again if the connection was reset while it ran, following policy.
+// A RemoteCmd cannot be started twice so newCmd is called for each try. The
+// script has to be safe to run more than once.
+//
+// RunWithResetRetry returns the exit status of the last try. Errors that are
+// not connection resets are returned right away.
+func RunWithResetRetry(ctx context.Context, comm packersdk.Communicator, ui packersdk.Ui, policy ResetPolicy, newCmd func() *packersdk.RemoteCmd) (int, error) {
+	tries := policy.Tries
+	if tries <= 0 {
+		tries = 2
+	}
+	delay := policy.Delay
+	if delay <= 0 {
+		delay = 10 * time.Second
+	}
+	isReset := policy.IsReset
+	if isReset == nil {
+		isReset = IsConnectionReset
+	}
+
+	status := 0
+	try := 0
+	err := retry.Config{
+		Tries:      tries,
+		RetryDelay: func() time.Duration { return delay },
+		ShouldRetry: func(err error) bool {
+			return errors.Is(err, ErrConnectionReset)
+		},
+	}.Run(ctx, func(ctx context.Context) error {
+		try++
+		if try > 1 {
+			ui.Say(fmt.Sprintf("Connection to the guest was reset, retrying (try %d of %d)...", try, tries))
+			if policy.Reconnect != nil {
+				if err := policy.Reconnect(ctx); err != nil {
+					return fmt.Errorf("Error reconnecting to the guest: %s", err)
+				}
+			}
+		}
+
+		cmd := newCmd()
+		err := cmd.RunWithUi(ctx, comm, ui)
+		status = 0
+		if err == nil {
+			status = cmd.ExitStatus()
+		}
+		if isReset(err, status) {
+			log.Printf("[INFO] Connection reset while running %q: err=%v, status=%d", cmd.Command, err, status)
+			return &resetError{err: err, status: status}
+		}
+		return err
+	})
+
+	var exhausted *retry.RetryExhaustedError
+	if errors.As(err, &exhausted) {
+		err = exhausted.Err
+	}
+	return status, err
+}
@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// resettingCommunicator disconnects the first resets commands it runs.
+type resettingCommunicator struct {
+	packersdk.MockCommunicator
+
+	resets int
+	starts int
+}
+
+func (c *resettingCommunicator) Start(_ context.Context, rc *packersdk.RemoteCmd) error {
+	c.starts++
+	if c.starts <= c.resets {
+		rc.SetExited(packersdk.CmdDisconnect)
+		return nil
+	}
+	rc.SetExited(3010)
+	return nil
+}
+
+func TestRunWithResetRetry(t *testing.T) {
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: io.Discard, ErrorWriter: io.Discard}
+	newCmd := func() *packersdk.RemoteCmd { return &packersdk.RemoteCmd{Command: "script.ps1"} }
+
+	comm := &resettingCommunicator{resets: 1}
+	reconnects := 0
+	policy := ResetPolicy{
+		Delay: time.Millisecond,
+		Reconnect: func(context.Context) error {
+			reconnects++
+			return nil
+		},
+	}
+	status, err := RunWithResetRetry(context.Background(), comm, ui, policy, newCmd)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if status != 3010 || comm.starts != 2 || reconnects != 1 {
+		t.Fatalf("unexpected status %d, %d starts and %d reconnects", status, comm.starts, reconnects)
+	}
+
+	// Still reset after the last try.
+	comm = &resettingCommunicator{resets: 5}
+	policy = ResetPolicy{Tries: 3, Delay: time.Millisecond}
+	_, err = RunWithResetRetry(context.Background(), comm, ui, policy, newCmd)
+	if !errors.Is(err, ErrConnectionReset) || comm.starts != 3 {
+		t.Fatalf("expected a connection reset error after 3 starts, got %v after %d", err, comm.starts)
+	}
+}
+
+func TestIsConnectionReset(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		want   bool
+	}{
+		{nil, 0, false},
+		{nil, 1, false},
+		{nil, packersdk.CmdDisconnect, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), 0, true},
+		{errors.New("http response error: 401 - invalid content type"), 0, false},
+		{errors.New("wsarecv: An existing connection was forcibly closed by the remote host."), 0, true},
+	}
+	for _, tt := range tests {
+		if got := IsConnectionReset(tt.err, tt.status); got != tt.want {
+			t.Errorf("IsConnectionReset(%v, %d) = %t, want %t", tt.err, tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// StagingDirs creates the remote directories provisioners upload files to
+// before using them, and removes them once provisioning is done. Each
+// directory gets a unique name so that concurrent provisioners, or builds
+// sharing a machine, don't step on each other.
+type StagingDirs struct {
+	Comm     packersdk.Communicator
+	Commands *GuestCommands
+	// Base is the remote directory the staging directories are created in,
+	// for example `/tmp` or `C:/Windows/Temp`. If empty, they are created
+	// with TempDir, only accessible to the guest user.
+	Base string
+	// Mode is the mode of the staging directories, for example "0700". It
+	// is ignored on Windows. Directories keep the default mode if unset.
+	Mode string
+	// MinFreeBytes, if set, makes Create fail when less space is available
+	// in Base. It is ignored when Base is empty.
+	MinFreeBytes uint64
+
+	mu      sync.Mutex
+	created []string
+}
+
+// Create creates a new staging directory and returns its path.
+func (s *StagingDirs) Create(ctx context.Context) (string, error) {
+	dir, err := s.create(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.created = append(s.created, dir)
+	s.mu.Unlock()
+
+	if s.Mode != "" && s.Commands.Family() != WindowsOSType {
+		if _, err := runCommand(ctx, s.Comm, s.Commands.Chmod(dir, s.Mode)); err != nil {
+			return "", fmt.Errorf("Error setting mode of staging directory %s: %s", dir, err)
+		}
+	}
+	return dir, nil
+}
+
+func (s *StagingDirs) create(ctx context.Context) (string, error) {
+	if s.Base == "" {
+		dir, err := TempDir(ctx, s.Comm, s.Commands, "packer-")
+		if err != nil {
+			return "", fmt.Errorf("Error creating staging directory: %s", err)
+		}
+		return dir, nil
+	}
+
+	if s.MinFreeBytes > 0 {
+		free, err := FreeSpace(ctx, s.Comm, s.Commands, s.Base)
+		if err != nil {
+			log.Printf("[WARN] Could not check free space in %s: %s", s.Base, err)
+		} else if free < s.MinFreeBytes {
+			return "", fmt.Errorf("staging needs %s free in %s, only %s available",
+				formatBytes(s.MinFreeBytes), s.Base, formatBytes(free))
+		}
+	}
+
+	dir := strings.TrimRight(s.Base, `/\`) + "/packer-" + uuid.TimeOrderedUUID()
+	if _, err := runCommand(ctx, s.Comm, s.Commands.CreateDir(dir)); err != nil {
+		return "", fmt.Errorf("Error creating staging directory %s: %s", dir, err)
+	}
+	return dir, nil
+}
+
+// Cleanup removes every staging directory created so far, most recent first.
+// It keeps going when a directory cannot be removed and returns all the
+// errors.
+func (s *StagingDirs) Cleanup(ctx context.Context) error {
+	s.mu.Lock()
+	created := s.created
+	s.created = nil
+	s.mu.Unlock()
+
+	var errs []error
+	for i := len(created) - 1; i >= 0; i-- {
+		if _, err := runCommand(ctx, s.Comm, s.Commands.RemoveDir(created[i])); err != nil {
+			errs = append(errs, fmt.Errorf("Error removing staging directory %s: %s", created[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TempDir creates a new directory in the temporary directory of the guest
+// user, with a name beginning with prefix, and returns its path.
+func TempDir(ctx context.Context, comm packersdk.Communicator, commands *GuestCommands, prefix string) (string, error) {
+	out, err := runCommand(ctx, comm, commands.CreateTempDir(prefix))
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(out)
+	if dir == "" {
+		return "", fmt.Errorf("%q did not print the path of the directory", commands.CreateTempDir(prefix))
+	}
+	return dir, nil
+}
+
+// FreeSpace returns the number of bytes available on the remote filesystem
+// holding path.
+func FreeSpace(ctx context.Context, comm packersdk.Communicator, commands *GuestCommands, path string) (uint64, error) {
+	out, err := runCommand(ctx, comm, commands.FreeSpace(path))
+	if err != nil {
+		return 0, err
+	}
+	free, err := strconv.ParseUint(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output %q", out)
+	}
+	return free, nil
+}
+
+// runCommand runs command and returns its output, or an error if it exits
+// with a non-zero status.
+func runCommand(ctx context.Context, comm packersdk.Communicator, command string) (string, error) {
+	stdout, stderr, status, err := startCommand(ctx, comm, command)
+	if err != nil {
+		return "", err
+	}
+	if status != 0 {
+		return "", fmt.Errorf("%q exited with status %d: %s", command, status, strings.TrimSpace(stderr))
+	}
+	return stdout, nil
+}
+
+func startCommand(ctx context.Context, comm packersdk.Communicator, command string) (string, string, int, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return "", "", 0, err
+	}
+	status := cmd.Wait()
+	return stdout.String(), stderr.String(), status, nil
+}
+
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
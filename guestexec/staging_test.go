@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// recordingCommunicator records the commands it runs and answers the free
+// space command with freeSpace.
+type recordingCommunicator struct {
+	packersdk.MockCommunicator
+
+	freeSpace string
+	tempDir   string
+	commands  []string
+}
+
+func (c *recordingCommunicator) Start(_ context.Context, rc *packersdk.RemoteCmd) error {
+	c.commands = append(c.commands, rc.Command)
+	if strings.HasPrefix(rc.Command, "df ") {
+		io.WriteString(rc.Stdout, c.freeSpace)
+	}
+	if strings.HasPrefix(rc.Command, "mktemp ") {
+		io.WriteString(rc.Stdout, c.tempDir)
+	}
+	rc.SetExited(0)
+	return nil
+}
+
+func TestStagingDirs(t *testing.T) {
+	comm := &recordingCommunicator{freeSpace: "2048\n"}
+	commands, _ := NewGuestCommands(UnixOSType, false)
+	staging := &StagingDirs{
+		Comm:         comm,
+		Commands:     commands,
+		Base:         "/tmp/",
+		Mode:         "0700",
+		MinFreeBytes: 1024,
+	}
+
+	first, err := staging.Create(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	second, err := staging.Create(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if first == second {
+		t.Fatalf("staging directories should be unique, got %s twice", first)
+	}
+	if !strings.HasPrefix(first, "/tmp/packer-") {
+		t.Fatalf("unexpected staging directory: %s", first)
+	}
+	if comm.commands[1] != "mkdir -p '"+first+"'" || comm.commands[2] != "chmod 0700 '"+first+"'" {
+		t.Fatalf("unexpected commands: %#v", comm.commands)
+	}
+
+	comm.commands = nil
+	if err := staging.Cleanup(context.Background()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{"rm -rf '" + second + "'", "rm -rf '" + first + "'"}
+	if strings.Join(comm.commands, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected cleanup commands: %#v", comm.commands)
+	}
+
+	// Everything was removed already.
+	comm.commands = nil
+	if err := staging.Cleanup(context.Background()); err != nil || len(comm.commands) != 0 {
+		t.Fatalf("second cleanup should be a no-op, got %v, %#v", err, comm.commands)
+	}
+}
+
+func TestStagingDirs_tempDir(t *testing.T) {
+	comm := &recordingCommunicator{tempDir: "/tmp/packer-a1b2c3d4\n"}
+	commands, _ := NewGuestCommands(UnixOSType, true)
+	staging := &StagingDirs{Comm: comm, Commands: commands, Mode: "0755"}
+
+	dir, err := staging.Create(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if dir != "/tmp/packer-a1b2c3d4" {
+		t.Fatalf("unexpected staging directory: %s", dir)
+	}
+	if len(comm.commands) != 2 || !strings.HasPrefix(comm.commands[0], "mktemp -d ") || comm.commands[1] != "sudo chmod 0755 '"+dir+"'" {
+		t.Fatalf("unexpected commands: %#v", comm.commands)
+	}
+
+	comm.commands = nil
+	if err := staging.Cleanup(context.Background()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(comm.commands) != 1 || comm.commands[0] != "sudo rm -rf '/tmp/packer-a1b2c3d4'" {
+		t.Fatalf("unexpected cleanup commands: %#v", comm.commands)
+	}
+}
+
+func TestStagingDirs_notEnoughSpace(t *testing.T) {
+	comm := &recordingCommunicator{freeSpace: "512\n"}
+	commands, _ := NewGuestCommands(UnixOSType, false)
+	staging := &StagingDirs{
+		Comm:         comm,
+		Commands:     commands,
+		Base:         "/tmp",
+		MinFreeBytes: 10 * 1024 * 1024,
+	}
+
+	_, err := staging.Create(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "needs 10.0MiB free in /tmp, only 512B available") {
+		t.Fatalf("expected a disk space error, got: %v", err)
+	}
+	if len(comm.commands) != 1 {
+		t.Fatalf("nothing should be created, got: %#v", comm.commands)
+	}
+}
@@ -0,0 +1,571 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// CtyUnmarshaler is implemented by types decoding themselves from a cty
+// value with Decode.
+type CtyUnmarshaler interface {
+	UnmarshalCty(cty.Value) error
+}
+
+// CtyMarshaler is implemented by types encoding themselves to a cty value
+// with Encode.
+type CtyMarshaler interface {
+	MarshalCty() (cty.Value, error)
+}
+
+var (
+	ctyValueType       = reflect.TypeOf(cty.Value{})
+	durationType       = reflect.TypeOf(time.Duration(0))
+	timeType           = reflect.TypeOf(time.Time{})
+	ipType             = reflect.TypeOf(net.IP{})
+	ipNetType          = reflect.TypeOf(net.IPNet{})
+	ctyUnmarshalerType = reflect.TypeOf((*CtyUnmarshaler)(nil)).Elem()
+	ctyMarshalerType   = reflect.TypeOf((*CtyMarshaler)(nil)).Elem()
+)
+
+// Decode decodes v into the value out points to, typically a struct, for
+// datasources and tests to read cty values without walking them by hand.
+//
+// Struct fields are decoded from the attribute named by their `cty` tag,
+// else their `mapstructure` tag, else their name in snake case; fields
+// tagged "-" are skipped and embedded structs tagged ",squash" are decoded
+// from the same object. Nested blocks decode into structs, pointers to
+// structs or slices of structs. Null values leave fields as they are, or set
+// pointers to nil.
+//
+// On top of the types gocty supports, time.Duration decodes from duration
+// strings like "1m30s", time.Time from RFC 3339 strings, net.IP from
+// addresses, net.IPNet from CIDR blocks, interface{} to the basic Go types,
+// cty.Value as is, and types implementing CtyUnmarshaler decode themselves.
+// Capsule values decode into their encapsulated type, or a pointer to it.
+func Decode(v cty.Value, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("can't decode into %T, a non-nil pointer is required", out)
+	}
+	v, pvm := v.UnmarkDeepWithPaths()
+	err := decode(nil, v, rv.Elem())
+	if de, ok := err.(*DecodeError); ok {
+		de.Provenance = provenanceAt(pvm, de.Path)
+	}
+	return err
+}
+
+func decode(path cty.Path, v cty.Value, out reflect.Value) error {
+	if out.Type() == ctyValueType {
+		out.Set(reflect.ValueOf(v))
+		return nil
+	}
+	if out.CanAddr() && out.Addr().Type().Implements(ctyUnmarshalerType) {
+		if err := out.Addr().Interface().(CtyUnmarshaler).UnmarshalCty(v); err != nil {
+			return pathError(path, err.Error())
+		}
+		return nil
+	}
+	if !v.IsKnown() {
+		return pathError(path, "value is unknown")
+	}
+	if v.IsNull() {
+		if out.Kind() == reflect.Ptr || out.Kind() == reflect.Interface {
+			out.Set(reflect.Zero(out.Type()))
+		}
+		return nil
+	}
+	if v.Type().IsCapsuleType() {
+		return decodeCapsule(path, v, out)
+	}
+
+	switch out.Type() {
+	case durationType:
+		s, err := decodeString(path, v)
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return pathError(path, err.Error())
+		}
+		out.SetInt(int64(d))
+		return nil
+	case timeType:
+		s, err := decodeString(path, v)
+		if err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return pathError(path, err.Error())
+		}
+		out.Set(reflect.ValueOf(t))
+		return nil
+	case ipType:
+		s, err := decodeString(path, v)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return pathError(path, fmt.Sprintf("invalid IP address %q", s))
+		}
+		out.Set(reflect.ValueOf(ip))
+		return nil
+	case ipNetType:
+		s, err := decodeString(path, v)
+		if err != nil {
+			return err
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return pathError(path, err.Error())
+		}
+		out.Set(reflect.ValueOf(*ipNet))
+		return nil
+	}
+
+	switch out.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(out.Type().Elem())
+		if err := decode(path, v, elem.Elem()); err != nil {
+			return err
+		}
+		out.Set(elem)
+		return nil
+	case reflect.Interface:
+		if out.NumMethod() != 0 {
+			return pathError(path, fmt.Sprintf("can't decode into %s", out.Type()))
+		}
+		out.Set(reflect.ValueOf(goValue(v)))
+		return nil
+	case reflect.String:
+		s, err := decodeString(path, v)
+		if err != nil {
+			return err
+		}
+		out.SetString(s)
+		return nil
+	case reflect.Bool:
+		if v.Type() != cty.Bool {
+			return pathError(path, fmt.Sprintf("bool required, got %s", v.Type().FriendlyName()))
+		}
+		out.SetBool(v.True())
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, err := decodeNumber(path, v)
+		if err != nil {
+			return err
+		}
+		i, acc := f.Int64()
+		if acc != big.Exact || out.OverflowInt(i) {
+			return pathError(path, fmt.Sprintf("%s doesn't fit in %s", f.Text('f', -1), out.Type()))
+		}
+		out.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, err := decodeNumber(path, v)
+		if err != nil {
+			return err
+		}
+		u, acc := f.Uint64()
+		if acc != big.Exact || out.OverflowUint(u) {
+			return pathError(path, fmt.Sprintf("%s doesn't fit in %s", f.Text('f', -1), out.Type()))
+		}
+		out.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := decodeNumber(path, v)
+		if err != nil {
+			return err
+		}
+		f64, _ := f.Float64()
+		out.SetFloat(f64)
+		return nil
+	case reflect.Slice:
+		ty := v.Type()
+		if !ty.IsListType() && !ty.IsTupleType() && !ty.IsSetType() {
+			return pathError(path, fmt.Sprintf("list required, got %s", ty.FriendlyName()))
+		}
+		s := reflect.MakeSlice(out.Type(), v.LengthInt(), v.LengthInt())
+		i := 0
+		for it := v.ElementIterator(); it.Next(); i++ {
+			_, elem := it.Element()
+			if err := decode(append(copyPath(path), cty.IndexStep{Key: cty.NumberIntVal(int64(i))}), elem, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.Set(s)
+		return nil
+	case reflect.Map:
+		ty := v.Type()
+		if !ty.IsMapType() && !ty.IsObjectType() {
+			return pathError(path, fmt.Sprintf("map required, got %s", ty.FriendlyName()))
+		}
+		if out.Type().Key().Kind() != reflect.String {
+			return pathError(path, fmt.Sprintf("can't decode into %s, keys must be strings", out.Type()))
+		}
+		m := reflect.MakeMap(out.Type())
+		for k, elem := range v.AsValueMap() {
+			e := reflect.New(out.Type().Elem()).Elem()
+			if err := decode(append(copyPath(path), cty.IndexStep{Key: cty.StringVal(k)}), elem, e); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(out.Type().Key()), e)
+		}
+		out.Set(m)
+		return nil
+	case reflect.Struct:
+		ty := v.Type()
+		if !ty.IsObjectType() && !ty.IsMapType() {
+			return pathError(path, fmt.Sprintf("object required, got %s", ty.FriendlyName()))
+		}
+		attrs := v.AsValueMap()
+		for _, f := range structFields(out.Type()) {
+			attr, ok := attrs[f.name]
+			if !ok {
+				continue
+			}
+			if err := decode(append(copyPath(path), cty.GetAttrStep{Name: f.name}), attr, out.FieldByIndex(f.index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return pathError(path, fmt.Sprintf("can't decode into %s", out.Type()))
+}
+
+func decodeCapsule(path cty.Path, v cty.Value, out reflect.Value) error {
+	ptr := reflect.ValueOf(v.EncapsulatedValue())
+	switch {
+	case ptr.Type().AssignableTo(out.Type()):
+		out.Set(ptr)
+	case ptr.Type().Elem().AssignableTo(out.Type()):
+		out.Set(ptr.Elem())
+	default:
+		return pathError(path, fmt.Sprintf("can't decode %s into %s", v.Type().FriendlyName(), out.Type()))
+	}
+	return nil
+}
+
+func decodeString(path cty.Path, v cty.Value) (string, error) {
+	if v.Type() != cty.String {
+		return "", pathError(path, fmt.Sprintf("string required, got %s", v.Type().FriendlyName()))
+	}
+	return v.AsString(), nil
+}
+
+func decodeNumber(path cty.Path, v cty.Value) (*big.Float, error) {
+	if v.Type() != cty.Number {
+		return nil, pathError(path, fmt.Sprintf("number required, got %s", v.Type().FriendlyName()))
+	}
+	return v.AsBigFloat(), nil
+}
+
+// goValue returns v as a bool, string, int64 or float64, []interface{} or
+// map[string]interface{}.
+func goValue(v cty.Value) interface{} {
+	if v.IsNull() || !v.IsKnown() {
+		return nil
+	}
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return v.AsString()
+	case ty == cty.Bool:
+		return v.True()
+	case ty == cty.Number:
+		f := v.AsBigFloat()
+		if i, acc := f.Int64(); acc == big.Exact {
+			return i
+		}
+		f64, _ := f.Float64()
+		return f64
+	case ty.IsListType() || ty.IsTupleType() || ty.IsSetType():
+		l := make([]interface{}, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			l = append(l, goValue(elem))
+		}
+		return l
+	case ty.IsMapType() || ty.IsObjectType():
+		m := map[string]interface{}{}
+		for k, elem := range v.AsValueMap() {
+			m[k] = goValue(elem)
+		}
+		return m
+	case ty.IsCapsuleType():
+		return v.EncapsulatedValue()
+	}
+	return nil
+}
+
+// Encode encodes in, typically a struct, to a cty value, the way Decode
+// decodes it: structs become objects, slices lists and maps maps, or
+// tuples and objects when their elements have different types.
+func Encode(in interface{}) (cty.Value, error) {
+	if in == nil {
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	}
+	return encode(nil, reflect.ValueOf(in))
+}
+
+func encode(path cty.Path, in reflect.Value) (cty.Value, error) {
+	if in.Type() == ctyValueType {
+		return in.Interface().(cty.Value), nil
+	}
+	if in.Type().Implements(ctyMarshalerType) {
+		if in.Kind() == reflect.Ptr && in.IsNil() {
+			return cty.NullVal(cty.DynamicPseudoType), nil
+		}
+		v, err := in.Interface().(CtyMarshaler).MarshalCty()
+		if err != nil {
+			return cty.NilVal, pathError(path, err.Error())
+		}
+		return v, nil
+	}
+
+	switch in.Type() {
+	case durationType:
+		return cty.StringVal(time.Duration(in.Int()).String()), nil
+	case timeType:
+		return cty.StringVal(in.Interface().(time.Time).Format(time.RFC3339)), nil
+	case ipType:
+		if in.IsNil() {
+			return cty.NullVal(cty.String), nil
+		}
+		return cty.StringVal(in.Interface().(net.IP).String()), nil
+	case ipNetType:
+		ipNet := in.Interface().(net.IPNet)
+		return cty.StringVal(ipNet.String()), nil
+	}
+
+	switch in.Kind() {
+	case reflect.Ptr:
+		if in.IsNil() {
+			return cty.NullVal(impliedType(in.Type().Elem())), nil
+		}
+		return encode(path, in.Elem())
+	case reflect.Interface:
+		if in.IsNil() {
+			return cty.NullVal(cty.DynamicPseudoType), nil
+		}
+		return encode(path, in.Elem())
+	case reflect.String:
+		return cty.StringVal(in.String()), nil
+	case reflect.Bool:
+		return cty.BoolVal(in.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cty.NumberIntVal(in.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cty.NumberUIntVal(in.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return cty.NumberFloatVal(in.Float()), nil
+	case reflect.Slice, reflect.Array:
+		if in.Kind() == reflect.Slice && in.IsNil() {
+			return cty.NullVal(impliedType(in.Type())), nil
+		}
+		elems := make([]cty.Value, in.Len())
+		for i := range elems {
+			elem, err := encode(append(copyPath(path), cty.IndexStep{Key: cty.NumberIntVal(int64(i))}), in.Index(i))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems[i] = elem
+		}
+		if len(elems) == 0 {
+			return cty.ListValEmpty(impliedType(in.Type().Elem())), nil
+		}
+		if !sameTypes(elems) {
+			return cty.TupleVal(elems), nil
+		}
+		return cty.ListVal(elems), nil
+	case reflect.Map:
+		if in.Type().Key().Kind() != reflect.String {
+			return cty.NilVal, pathError(path, fmt.Sprintf("can't encode %s, keys must be strings", in.Type()))
+		}
+		if in.IsNil() {
+			return cty.NullVal(impliedType(in.Type())), nil
+		}
+		elems := map[string]cty.Value{}
+		for it := in.MapRange(); it.Next(); {
+			k := it.Key().String()
+			elem, err := encode(append(copyPath(path), cty.IndexStep{Key: cty.StringVal(k)}), it.Value())
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems[k] = elem
+		}
+		if len(elems) == 0 {
+			return cty.MapValEmpty(impliedType(in.Type().Elem())), nil
+		}
+		values := make([]cty.Value, 0, len(elems))
+		for _, elem := range elems {
+			values = append(values, elem)
+		}
+		if !sameTypes(values) {
+			return cty.ObjectVal(elems), nil
+		}
+		return cty.MapVal(elems), nil
+	case reflect.Struct:
+		attrs := map[string]cty.Value{}
+		for _, f := range structFields(in.Type()) {
+			attr, err := encode(append(copyPath(path), cty.GetAttrStep{Name: f.name}), in.FieldByIndex(f.index))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[f.name] = attr
+		}
+		return cty.ObjectVal(attrs), nil
+	}
+	return cty.NilVal, pathError(path, fmt.Sprintf("can't encode %s", in.Type()))
+}
+
+// impliedType returns the type Encode gives to the values of t, used for
+// null and empty values.
+func impliedType(t reflect.Type) cty.Type {
+	switch t {
+	case ctyValueType:
+		return cty.DynamicPseudoType
+	case durationType, timeType, ipType, ipNetType:
+		return cty.String
+	}
+	if t.Implements(ctyMarshalerType) {
+		return cty.DynamicPseudoType
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return impliedType(t.Elem())
+	case reflect.String:
+		return cty.String
+	case reflect.Bool:
+		return cty.Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return cty.Number
+	case reflect.Slice, reflect.Array:
+		return cty.List(impliedType(t.Elem()))
+	case reflect.Map:
+		return cty.Map(impliedType(t.Elem()))
+	case reflect.Struct:
+		attrs := map[string]cty.Type{}
+		for _, f := range structFields(t) {
+			attrs[f.name] = impliedType(t.FieldByIndex(f.index).Type)
+		}
+		return cty.Object(attrs)
+	}
+	return cty.DynamicPseudoType
+}
+
+func sameTypes(values []cty.Value) bool {
+	for _, v := range values[1:] {
+		if !v.Type().Equals(values[0].Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields returns the fields of t decoded and encoded, sorted by name.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("cty")
+		if !ok {
+			tag = f.Tag.Get("mapstructure")
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && strings.Contains(opts, "squash") {
+			for _, embedded := range structFields(f.Type) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+		fields = append(fields, structField{name: name, index: []int{i}})
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+// snakeCase turns a field name like SSHPort into ssh_port.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// DecodeError is the error of Decode, on the value at Path.
+type DecodeError struct {
+	Path    cty.Path
+	Message string
+	// Provenance is where the value comes from, when it was decoded with
+	// DecodeBody.
+	Provenance *Provenance
+}
+
+func (e *DecodeError) Error() string {
+	msg := e.Message
+	if len(e.Path) > 0 {
+		msg = FormatPath(e.Path) + ": " + msg
+	}
+	if e.Provenance != nil {
+		msg += " (" + e.Provenance.String() + ")"
+	}
+	return msg
+}
+
+// Diagnostic returns e as an HCL diagnostic, whose subject is the
+// expression the value comes from when it is known.
+func (e *DecodeError) Diagnostic() *hcl.Diagnostic {
+	diag := &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid configuration value",
+		Detail:   e.Error(),
+	}
+	if e.Provenance != nil {
+		diag.Subject = e.Provenance.Range.Ptr()
+	}
+	return diag
+}
+
+func pathError(path cty.Path, msg string) error {
+	return &DecodeError{Path: copyPath(path), Message: msg}
+}
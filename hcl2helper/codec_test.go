@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+type codecDisk struct {
+	Size int    `cty:"size"`
+	Type string `mapstructure:"type"`
+}
+
+type codecCommon struct {
+	Region string `mapstructure:"region"`
+}
+
+type codecConfig struct {
+	codecCommon `mapstructure:",squash"`
+
+	SSHPort   int               `mapstructure:"ssh_port"`
+	Timeout   time.Duration     `mapstructure:"timeout"`
+	CreatedAt time.Time         `mapstructure:"created_at"`
+	Address   net.IP            `mapstructure:"address"`
+	Subnet    net.IPNet         `mapstructure:"subnet"`
+	Tags      map[string]string `mapstructure:"tags"`
+	Disks     []codecDisk       `mapstructure:"disk"`
+	Boot      *codecDisk        `mapstructure:"boot"`
+	Skipped   string            `mapstructure:"-"`
+	UseSSL    bool
+}
+
+func TestEncodeDecode(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	in := codecConfig{
+		codecCommon: codecCommon{Region: "eu-west-1"},
+		SSHPort:     22,
+		Timeout:     90 * time.Second,
+		CreatedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Address:     net.ParseIP("10.0.0.1"),
+		Subnet:      *subnet,
+		Tags:        map[string]string{"Name": "packer"},
+		Disks:       []codecDisk{{Size: 8, Type: "gp3"}},
+		Skipped:     "skipped",
+		UseSSL:      true,
+	}
+
+	v, err := Encode(in)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"region":     cty.StringVal("eu-west-1"),
+		"ssh_port":   cty.NumberIntVal(22),
+		"timeout":    cty.StringVal("1m30s"),
+		"created_at": cty.StringVal("2024-01-02T03:04:05Z"),
+		"address":    cty.StringVal("10.0.0.1"),
+		"subnet":     cty.StringVal("10.0.0.0/24"),
+		"tags":       cty.MapVal(map[string]cty.Value{"Name": cty.StringVal("packer")}),
+		"disk": cty.ListVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{
+			"size": cty.NumberIntVal(8),
+			"type": cty.StringVal("gp3"),
+		})}),
+		"boot":    cty.NullVal(cty.Object(map[string]cty.Type{"size": cty.Number, "type": cty.String})),
+		"use_ssl": cty.True,
+	})
+	if diff := DiffValues(expected, v); diff != nil {
+		t.Fatalf("bad encoding:\n%s", strings.Join(diff, "\n"))
+	}
+
+	var out codecConfig
+	if err := Decode(v, &out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	in.Skipped = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("bad decoding:\n%#v\n%#v", in, out)
+	}
+}
+
+type codecUpper string
+
+func (u *codecUpper) UnmarshalCty(v cty.Value) error {
+	*u = codecUpper(strings.ToUpper(v.AsString()))
+	return nil
+}
+
+func TestDecode(t *testing.T) {
+	type capsuled struct{ name string }
+	capsuleType := cty.Capsule("capsuled", reflect.TypeOf(capsuled{}))
+
+	var out struct {
+		Any     interface{}      `cty:"any"`
+		Raw     cty.Value        `cty:"raw"`
+		Upper   codecUpper       `cty:"upper"`
+		Capsule *capsuled        `cty:"capsule"`
+		Ports   []uint16         `cty:"ports"`
+		Nested  map[string][]int `cty:"nested"`
+	}
+	v := cty.ObjectVal(map[string]cty.Value{
+		"any": cty.ObjectVal(map[string]cty.Value{
+			"a": cty.NumberIntVal(1),
+			"b": cty.TupleVal([]cty.Value{cty.StringVal("x"), cty.NumberFloatVal(1.5)}),
+		}),
+		"raw":     cty.StringVal("raw").Mark(SensitiveMark),
+		"upper":   cty.StringVal("hello"),
+		"capsule": cty.CapsuleVal(capsuleType, &capsuled{name: "c"}),
+		"ports":   cty.SetVal([]cty.Value{cty.NumberIntVal(22)}),
+		"nested":  cty.MapVal(map[string]cty.Value{"a": cty.ListVal([]cty.Value{cty.NumberIntVal(1)})}),
+	})
+	if err := Decode(v, &out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(out.Any, map[string]interface{}{"a": int64(1), "b": []interface{}{"x", 1.5}}) {
+		t.Fatalf("bad any: %#v", out.Any)
+	}
+	if !out.Raw.RawEquals(cty.StringVal("raw")) || out.Upper != "HELLO" || out.Capsule.name != "c" {
+		t.Fatalf("bad decoding: %#v", out)
+	}
+	if !reflect.DeepEqual(out.Ports, []uint16{22}) || !reflect.DeepEqual(out.Nested, map[string][]int{"a": {1}}) {
+		t.Fatalf("bad decoding: %#v", out)
+	}
+}
+
+func TestDecode_errors(t *testing.T) {
+	tcs := map[string]struct {
+		value    cty.Value
+		expected string
+	}{
+		"wrong type": {
+			cty.ObjectVal(map[string]cty.Value{"ssh_port": cty.StringVal("22")}),
+			"ssh_port: number required, got string",
+		},
+		"overflow": {
+			cty.ObjectVal(map[string]cty.Value{"disk": cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"size": cty.NumberFloatVal(1.5)}),
+			})}),
+			"disk[0].size: 1.5 doesn't fit in int",
+		},
+		"bad duration": {
+			cty.ObjectVal(map[string]cty.Value{"timeout": cty.StringVal("soon")}),
+			`timeout: time: invalid duration "soon"`,
+		},
+		"unknown": {
+			cty.ObjectVal(map[string]cty.Value{"tags": cty.UnknownVal(cty.Map(cty.String))}),
+			"tags: value is unknown",
+		},
+	}
+	for name, tc := range tcs {
+		var out codecConfig
+		err := Decode(tc.value, &out)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("%s: expected %q, got %v", name, tc.expected, err)
+		}
+	}
+
+	if err := Decode(cty.True, codecConfig{}); err == nil {
+		t.Error("decoding into a non pointer should fail")
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// ExpressionFunctions are the functions expressions evaluated with
+// EvalExpression can call by default. They only compute values: none of them
+// reads files, the environment or the network, so that expressions given by
+// users are safe to evaluate.
+var ExpressionFunctions = map[string]function.Function{
+	"abs":        stdlib.AbsoluteFunc,
+	"coalesce":   stdlib.CoalesceFunc,
+	"concat":     stdlib.ConcatFunc,
+	"contains":   stdlib.ContainsFunc,
+	"format":     stdlib.FormatFunc,
+	"join":       stdlib.JoinFunc,
+	"keys":       stdlib.KeysFunc,
+	"length":     stdlib.LengthFunc,
+	"lower":      stdlib.LowerFunc,
+	"max":        stdlib.MaxFunc,
+	"min":        stdlib.MinFunc,
+	"regex":      stdlib.RegexFunc,
+	"regexall":   stdlib.RegexAllFunc,
+	"split":      stdlib.SplitFunc,
+	"startswith": startsWithFunc,
+	"strlen":     stdlib.StrlenFunc,
+	"substr":     stdlib.SubstrFunc,
+	"trimspace":  stdlib.TrimSpaceFunc,
+	"upper":      stdlib.UpperFunc,
+	"values":     stdlib.ValuesFunc,
+}
+
+var startsWithFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "str", Type: cty.String}, {Name: "prefix", Type: cty.String}},
+	Type:   function.StaticReturnType(cty.Bool),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		str, prefix := args[0].AsString(), args[1].AsString()
+		return cty.BoolVal(len(str) >= len(prefix) && str[:len(prefix)] == prefix), nil
+	},
+})
+
+// ParseExpression parses src, an HCL expression like
+// `var.os == "windows" && length(tags) > 0`, and returns the names of the
+// root variables it references, sorted, for plugins to validate them when
+// preparing their configuration.
+func ParseExpression(src string) ([]string, error) {
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "expression", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diagsError(src, diags)
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, traversal := range expr.Variables() {
+		if name := traversal.RootName(); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// EvalExpression evaluates src, an HCL expression, with vars as its
+// variables, and funcs as the functions it can call, ExpressionFunctions
+// when nil. Expressions referencing unknown variables or functions, or
+// evaluating to an unknown value, fail.
+func EvalExpression(src string, vars map[string]cty.Value, funcs map[string]function.Function) (cty.Value, error) {
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "expression", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilVal, diagsError(src, diags)
+	}
+	if funcs == nil {
+		funcs = ExpressionFunctions
+	}
+	ctx := &hcl.EvalContext{Variables: vars, Functions: funcs}
+	v, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return cty.NilVal, diagsError(src, diags)
+	}
+	if !v.IsWhollyKnown() {
+		return cty.NilVal, fmt.Errorf("%q: the result is not known", src)
+	}
+	return v, nil
+}
+
+// EvalBool evaluates src like EvalExpression, and returns its result as a
+// bool, for conditions like `skip_create_image_if`.
+func EvalBool(src string, vars map[string]cty.Value, funcs map[string]function.Function) (bool, error) {
+	v, err := EvalExpression(src, vars, funcs)
+	if err != nil {
+		return false, err
+	}
+	v, err = convert.Convert(v, cty.Bool)
+	if err != nil {
+		return false, fmt.Errorf("%q: the result must be a bool: %s", src, err)
+	}
+	if v.IsNull() {
+		return false, fmt.Errorf("%q: the result must not be null", src)
+	}
+	return v.True(), nil
+}
+
+// diagsError returns diags as an error mentioning the expression.
+func diagsError(src string, diags hcl.Diagnostics) error {
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+		if diag.Detail != "" {
+			return fmt.Errorf("%q: %s: %s", src, diag.Summary, diag.Detail)
+		}
+		return fmt.Errorf("%q: %s", src, diag.Summary)
+	}
+	return fmt.Errorf("%q: %s", src, diags.Error())
+}
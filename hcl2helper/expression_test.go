@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func testExpressionVars() map[string]cty.Value {
+	return map[string]cty.Value{
+		"build": cty.ObjectVal(map[string]cty.Value{
+			"os":   cty.StringVal("windows"),
+			"disk": cty.NumberIntVal(40),
+		}),
+		"tags": cty.MapVal(map[string]cty.Value{"env": cty.StringVal("prod")}),
+	}
+}
+
+func TestEvalExpression(t *testing.T) {
+	tests := []struct {
+		Src  string
+		Want cty.Value
+	}{
+		{`upper(build.os)`, cty.StringVal("WINDOWS")},
+		{`build.disk * 2`, cty.NumberIntVal(80)},
+		{`format("%s-%s", build.os, tags["env"])`, cty.StringVal("windows-prod")},
+		{`startswith(build.os, "win") ? "rdp" : "ssh"`, cty.StringVal("rdp")},
+	}
+	for _, tt := range tests {
+		got, err := EvalExpression(tt.Src, testExpressionVars(), nil)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.Src, err)
+		}
+		if !got.RawEquals(tt.Want) {
+			t.Errorf("%s: got %#v, expected %#v", tt.Src, got, tt.Want)
+		}
+	}
+}
+
+func TestEvalExpression_errors(t *testing.T) {
+	tests := map[string]string{
+		`build.os ==`:          "Missing expression",
+		`var.os == "linux"`:    "Unknown variable",
+		`file("/etc/passwd")`:  "Call to unknown function",
+		`build.unset`:          "Unsupported attribute",
+		`length(build.os, 1)`:  "Too many function arguments",
+		`tags["missing"] != 1`: "Invalid index",
+	}
+	for src, want := range tests {
+		_, err := EvalExpression(src, testExpressionVars(), nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", src, want, err)
+		}
+	}
+
+	_, err := EvalExpression(`build.os`, map[string]cty.Value{
+		"build": cty.ObjectVal(map[string]cty.Value{"os": cty.UnknownVal(cty.String)}),
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "not known") {
+		t.Errorf("expected an unknown result error, got %v", err)
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	tests := map[string]bool{
+		`build.os == "windows" && build.disk >= 40`: true,
+		`contains(keys(tags), "owner")`:             false,
+		`"true"`:                                    true,
+	}
+	for src, want := range tests {
+		got, err := EvalBool(src, testExpressionVars(), nil)
+		if err != nil {
+			t.Fatalf("%s: %s", src, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %t, expected %t", src, got, want)
+		}
+	}
+
+	for _, src := range []string{`build.os`, `null`} {
+		if _, err := EvalBool(src, testExpressionVars(), nil); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	names, err := ParseExpression(`build.os == "windows" && length(tags) > 0 && build.disk > 0`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff := cmp.Diff([]string{"build", "tags"}, names); diff != "" {
+		t.Fatalf("unexpected names: %s", diff)
+	}
+
+	if _, err := ParseExpression(`build.os ==`); err == nil {
+		t.Fatal("expected a syntax error")
+	}
+}
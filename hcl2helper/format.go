@@ -0,0 +1,195 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SensitiveMark is the mark of values that must not be displayed, like
+// passwords or tokens. It is the same mark Packer sets on sensitive
+// variables.
+const SensitiveMark = "sensitive"
+
+// Sensitive marks v so that FormatValue and DiffValues redact it.
+func Sensitive(v cty.Value) cty.Value {
+	return v.Mark(SensitiveMark)
+}
+
+// FormatValue renders v compactly the way it would be written in HCL, for
+// example `{ ssh_port = 22, tags = { "Name" = "packer" } }`, to be shown in
+// error messages. Sensitive values are rendered as `(sensitive value)` and
+// unknown values as `(unknown)`.
+func FormatValue(v cty.Value) string {
+	var b strings.Builder
+	formatValue(&b, v)
+	return b.String()
+}
+
+func formatValue(b *strings.Builder, v cty.Value) {
+	if v == cty.NilVal {
+		b.WriteString("(nil)")
+		return
+	}
+	if v.HasMark(SensitiveMark) {
+		b.WriteString("(sensitive value)")
+		return
+	}
+	v, _ = v.Unmark()
+	if !v.IsKnown() {
+		b.WriteString("(unknown)")
+		return
+	}
+	if v.IsNull() {
+		b.WriteString("null")
+		return
+	}
+
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		fmt.Fprintf(b, "%q", v.AsString())
+	case ty == cty.Number:
+		b.WriteString(v.AsBigFloat().Text('f', -1))
+	case ty == cty.Bool:
+		fmt.Fprint(b, v.True())
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		b.WriteString("[")
+		i := 0
+		for it := v.ElementIterator(); it.Next(); i++ {
+			_, elem := it.Element()
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			formatValue(b, elem)
+		}
+		b.WriteString("]")
+	case ty.IsObjectType() || ty.IsMapType():
+		attrs := v.AsValueMap()
+		if len(attrs) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteString("{ ")
+		for i, k := range sortedKeys(attrs) {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if ty.IsMapType() {
+				fmt.Fprintf(b, "%q", k)
+			} else {
+				b.WriteString(k)
+			}
+			b.WriteString(" = ")
+			formatValue(b, attrs[k])
+		}
+		b.WriteString(" }")
+	default:
+		b.WriteString(v.GoString())
+	}
+}
+
+// DiffValues describes the differences between two values, one line per
+// attribute or element that differs, for example:
+//
+//	ssh_port: 22 => 2222
+//	+ tags["Env"]: "dev"
+//	- winrm_use_ssl: true
+//
+// where `+` marks what is only set in got and `-` what is only set in want.
+// Objects and maps are compared attribute by attribute and lists and tuples
+// element by element; any other value, sets included, is compared as a
+// whole. DiffValues returns nil when the values are equal.
+func DiffValues(want, got cty.Value) []string {
+	var diffs []string
+	diffValues(&diffs, nil, want, got)
+	return diffs
+}
+
+func diffValues(diffs *[]string, path cty.Path, want, got cty.Value) {
+	wantSensitive, gotSensitive := want.HasMark(SensitiveMark), got.HasMark(SensitiveMark)
+	// Only the top level marks are removed, so that nested sensitive values
+	// are still redacted.
+	uwant, _ := want.Unmark()
+	ugot, _ := got.Unmark()
+	if !uwant.IsKnown() || !ugot.IsKnown() || uwant.IsNull() || ugot.IsNull() ||
+		wantSensitive || gotSensitive {
+		if !unmarkDeep(want).RawEquals(unmarkDeep(got)) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s => %s", formatDiffPath(path), FormatValue(want), FormatValue(got)))
+		}
+		return
+	}
+
+	wantTy, gotTy := uwant.Type(), ugot.Type()
+	switch {
+	case (wantTy.IsObjectType() || wantTy.IsMapType()) && (gotTy.IsObjectType() || gotTy.IsMapType()):
+		wantAttrs, gotAttrs := uwant.AsValueMap(), ugot.AsValueMap()
+		keys := map[string]cty.Value{}
+		for k, v := range wantAttrs {
+			keys[k] = v
+		}
+		for k, v := range gotAttrs {
+			keys[k] = v
+		}
+		for _, k := range sortedKeys(keys) {
+			var step cty.PathStep = cty.GetAttrStep{Name: k}
+			if wantTy.IsMapType() || gotTy.IsMapType() {
+				step = cty.IndexStep{Key: cty.StringVal(k)}
+			}
+			p := append(copyPath(path), step)
+			w, inWant := wantAttrs[k]
+			g, inGot := gotAttrs[k]
+			switch {
+			case !inGot:
+				*diffs = append(*diffs, fmt.Sprintf("- %s: %s", formatDiffPath(p), FormatValue(w)))
+			case !inWant:
+				*diffs = append(*diffs, fmt.Sprintf("+ %s: %s", formatDiffPath(p), FormatValue(g)))
+			default:
+				diffValues(diffs, p, w, g)
+			}
+		}
+	case (wantTy.IsListType() || wantTy.IsTupleType()) && (gotTy.IsListType() || gotTy.IsTupleType()):
+		wantElems, gotElems := uwant.AsValueSlice(), ugot.AsValueSlice()
+		for i := 0; i < len(wantElems) || i < len(gotElems); i++ {
+			p := append(copyPath(path), cty.IndexStep{Key: cty.NumberIntVal(int64(i))})
+			switch {
+			case i >= len(gotElems):
+				*diffs = append(*diffs, fmt.Sprintf("- %s: %s", formatDiffPath(p), FormatValue(wantElems[i])))
+			case i >= len(wantElems):
+				*diffs = append(*diffs, fmt.Sprintf("+ %s: %s", formatDiffPath(p), FormatValue(gotElems[i])))
+			default:
+				diffValues(diffs, p, wantElems[i], gotElems[i])
+			}
+		}
+	default:
+		if eq := unmarkDeep(want).Equals(unmarkDeep(got)); !eq.IsKnown() || eq.False() {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s => %s", formatDiffPath(path), FormatValue(want), FormatValue(got)))
+		}
+	}
+}
+
+func unmarkDeep(v cty.Value) cty.Value {
+	v, _ = v.UnmarkDeep()
+	return v
+}
+
+func formatDiffPath(path cty.Path) string {
+	if len(path) == 0 {
+		return "(root)"
+	}
+	return FormatPath(path)
+}
+
+func sortedKeys(m map[string]cty.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
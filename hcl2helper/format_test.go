@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value cty.Value
+		want  string
+	}{
+		{cty.StringVal("packer"), `"packer"`},
+		{cty.NumberFloatVal(1.5), "1.5"},
+		{cty.True, "true"},
+		{cty.NullVal(cty.String), "null"},
+		{cty.UnknownVal(cty.String), "(unknown)"},
+		{Sensitive(cty.StringVal("hunter2")), "(sensitive value)"},
+		{cty.ListValEmpty(cty.String), "[]"},
+		{cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.NumberIntVal(1)}), `["a", 1]`},
+		{cty.EmptyObjectVal, "{}"},
+		{
+			cty.ObjectVal(map[string]cty.Value{
+				"ssh_port":     cty.NumberIntVal(22),
+				"ssh_password": Sensitive(cty.StringVal("hunter2")),
+				"tags":         cty.MapVal(map[string]cty.Value{"Name": cty.StringVal("packer")}),
+			}),
+			`{ ssh_password = (sensitive value), ssh_port = 22, tags = { "Name" = "packer" } }`,
+		},
+	}
+	for _, tt := range tests {
+		if got := FormatValue(tt.value); got != tt.want {
+			t.Errorf("FormatValue(%#v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	want := cty.ObjectVal(map[string]cty.Value{
+		"ssh_port":      cty.NumberIntVal(22),
+		"ssh_password":  Sensitive(cty.StringVal("hunter2")),
+		"winrm_use_ssl": cty.True,
+		"tags":          cty.MapVal(map[string]cty.Value{"Name": cty.StringVal("packer")}),
+		"run_tags":      cty.ListVal([]cty.Value{cty.StringVal("a")}),
+	})
+	got := cty.ObjectVal(map[string]cty.Value{
+		"ssh_port":     cty.NumberIntVal(2222),
+		"ssh_password": Sensitive(cty.StringVal("hunter3")),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Name": cty.StringVal("packer"),
+			"Env":  cty.StringVal("dev"),
+		}),
+		"run_tags": cty.ListVal([]cty.Value{cty.StringVal("b"), cty.StringVal("c")}),
+	})
+
+	expected := []string{
+		`run_tags[0]: "a" => "b"`,
+		`+ run_tags[1]: "c"`,
+		`ssh_password: (sensitive value) => (sensitive value)`,
+		`ssh_port: 22 => 2222`,
+		`+ tags["Env"]: "dev"`,
+		`- winrm_use_ssl: true`,
+	}
+	if diffs := DiffValues(want, got); !reflect.DeepEqual(diffs, expected) {
+		t.Fatalf("unexpected diff:\n%#v\nexpected:\n%#v", diffs, expected)
+	}
+	if diffs := DiffValues(want, want); diffs != nil {
+		t.Fatalf("expected no diff between equal values, got %#v", diffs)
+	}
+	if diffs := DiffValues(cty.StringVal("a"), cty.NumberIntVal(1)); !reflect.DeepEqual(diffs, []string{`(root): "a" => 1`}) {
+		t.Fatalf("unexpected diff: %#v", diffs)
+	}
+}
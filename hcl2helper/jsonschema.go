@@ -0,0 +1,233 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// JSONSchemaDialect is the JSON Schema version of the schemas returned by
+// JSONSchema.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns the JSON Schema of the configurations decoded with spec,
+// for editors to complete them and for external tools to validate them.
+//
+// config is optional. It is the configuration struct spec was generated from,
+// like the Config of a builder, whose fields document the matching
+// attributes and blocks: the `doc:"..."` tag is their description, and
+// `required:"true"` makes them required even though the spec, which Packer
+// validates after decoding, does not.
+func JSONSchema(spec hcldec.ObjectSpec, config interface{}) map[string]interface{} {
+	var t reflect.Type
+	if config != nil {
+		t = reflect.TypeOf(config)
+	}
+	schema := objectSchema(spec, t)
+	schema["$schema"] = JSONSchemaDialect
+	return schema
+}
+
+// objectSchema returns the schema of the body decoded by spec. t is the type of
+// the struct documenting it, if any.
+func objectSchema(spec hcldec.ObjectSpec, t reflect.Type) map[string]interface{} {
+	t = structType(t)
+	properties := map[string]interface{}{}
+	var required []string
+	for _, s := range spec {
+		name, schema, req := specSchema(s, t)
+		if name == "" {
+			continue
+		}
+		if t != nil {
+			if f, ok := fieldByName(t, name); ok {
+				if doc := f.Tag.Get("doc"); doc != "" {
+					schema["description"] = doc
+				}
+				req = req || f.Tag.Get("required") == "true"
+			}
+		}
+		properties[name] = schema
+		if req {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// specSchema returns the name of the attribute or block decoded by s, its
+// schema, and whether it is required. The name is empty for specs not
+// reading the body, like literals.
+func specSchema(s hcldec.Spec, t reflect.Type) (string, map[string]interface{}, bool) {
+	switch s := s.(type) {
+	case *hcldec.AttrSpec:
+		return s.Name, TypeJSONSchema(s.Type), s.Required
+	case *hcldec.BlockSpec:
+		return s.TypeName, nestedSchema(s.Nested, fieldType(t, s.TypeName)), s.Required
+	case *hcldec.BlockListSpec:
+		return s.TypeName, blocksSchema(s.Nested, fieldType(t, s.TypeName), s.MinItems, s.MaxItems, false), s.MinItems > 0
+	case *hcldec.BlockTupleSpec:
+		return s.TypeName, blocksSchema(s.Nested, fieldType(t, s.TypeName), s.MinItems, s.MaxItems, false), s.MinItems > 0
+	case *hcldec.BlockSetSpec:
+		return s.TypeName, blocksSchema(s.Nested, fieldType(t, s.TypeName), s.MinItems, s.MaxItems, true), s.MinItems > 0
+	case *hcldec.BlockMapSpec:
+		return s.TypeName, labelledSchema(s.Nested, fieldType(t, s.TypeName), len(s.LabelNames)), false
+	case *hcldec.BlockObjectSpec:
+		return s.TypeName, labelledSchema(s.Nested, fieldType(t, s.TypeName), len(s.LabelNames)), false
+	case *hcldec.BlockAttrsSpec:
+		return s.TypeName, map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": TypeJSONSchema(s.ElementType),
+		}, s.Required
+	case *hcldec.DefaultSpec:
+		name, schema, _ := specSchema(s.Primary, t)
+		if literal, ok := s.Default.(*hcldec.LiteralSpec); ok && literal.Value.IsWhollyKnown() {
+			schema["default"] = goValue(literal.Value)
+		}
+		return name, schema, false
+	case *hcldec.TransformExprSpec:
+		return specSchema(s.Wrapped, t)
+	case *hcldec.TransformFuncSpec:
+		return specSchema(s.Wrapped, t)
+	case *hcldec.RefineValueSpec:
+		return specSchema(s.Wrapped, t)
+	case *hcldec.ValidateSpec:
+		return specSchema(s.Wrapped, t)
+	}
+	return "", nil, false
+}
+
+// nestedSchema returns the schema of the body of a block.
+func nestedSchema(nested hcldec.Spec, t reflect.Type) map[string]interface{} {
+	if spec, ok := nested.(hcldec.ObjectSpec); ok {
+		return objectSchema(spec, t)
+	}
+	if _, schema, _ := specSchema(nested, t); schema != nil {
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// blocksSchema returns the schema of repeated blocks, which are written as a
+// list of objects in JSON.
+func blocksSchema(nested hcldec.Spec, t reflect.Type, minItems, maxItems int, unique bool) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":  "array",
+		"items": nestedSchema(nested, t),
+	}
+	if minItems > 0 {
+		schema["minItems"] = minItems
+	}
+	if maxItems > 0 {
+		schema["maxItems"] = maxItems
+	}
+	if unique {
+		schema["uniqueItems"] = true
+	}
+	return schema
+}
+
+// labelledSchema returns the schema of blocks with labels, which are written
+// as an object for each label in JSON.
+func labelledSchema(nested hcldec.Spec, t reflect.Type, labels int) map[string]interface{} {
+	schema := nestedSchema(nested, t)
+	for i := 0; i < labels; i++ {
+		schema = map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schema,
+		}
+	}
+	return schema
+}
+
+// TypeJSONSchema returns the JSON Schema of the values of type t.
+func TypeJSONSchema(t cty.Type) map[string]interface{} {
+	switch {
+	case t == cty.String:
+		return map[string]interface{}{"type": "string"}
+	case t == cty.Number:
+		return map[string]interface{}{"type": "number"}
+	case t == cty.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.IsListType():
+		return map[string]interface{}{"type": "array", "items": TypeJSONSchema(t.ElementType())}
+	case t.IsSetType():
+		return map[string]interface{}{"type": "array", "items": TypeJSONSchema(t.ElementType()), "uniqueItems": true}
+	case t.IsMapType():
+		return map[string]interface{}{"type": "object", "additionalProperties": TypeJSONSchema(t.ElementType())}
+	case t.IsTupleType():
+		items := []interface{}{}
+		for _, elem := range t.TupleElementTypes() {
+			items = append(items, TypeJSONSchema(elem))
+		}
+		return map[string]interface{}{"type": "array", "prefixItems": items, "items": false}
+	case t.IsObjectType():
+		properties := map[string]interface{}{}
+		var required []string
+		for name, attr := range t.AttributeTypes() {
+			properties[name] = TypeJSONSchema(attr)
+			if !t.AttributeOptional(name) {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return schema
+	}
+	// Dynamic and capsule types accept anything.
+	return map[string]interface{}{}
+}
+
+// structType returns the struct type t points to or holds, or nil.
+func structType(t reflect.Type) reflect.Type {
+	for t != nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			return t
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// fieldByName returns the field of struct t decoded from name.
+func fieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, f := range structFields(t) {
+		if f.name == name {
+			return t.FieldByIndex(f.index), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// fieldType returns the type of the field of t decoded from name, or nil.
+func fieldType(t reflect.Type, name string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	f, ok := fieldByName(t, name)
+	if !ok {
+		return nil
+	}
+	return f.Type
+}
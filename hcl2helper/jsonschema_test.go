@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type schemaDisk struct {
+	Size int    `mapstructure:"size" doc:"The size of the disk, in MB." required:"true"`
+	Type string `mapstructure:"type"`
+}
+
+type schemaCommon struct {
+	Region string `mapstructure:"region" doc:"The region to build in."`
+}
+
+type schemaConfig struct {
+	schemaCommon `mapstructure:",squash"`
+	Disks        []schemaDisk `mapstructure:"disk"`
+	Port         int          `mapstructure:"port"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"region": &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: true},
+		"port": &hcldec.DefaultSpec{
+			Primary: &hcldec.AttrSpec{Name: "port", Type: cty.Number},
+			Default: &hcldec.LiteralSpec{Value: cty.NumberIntVal(22)},
+		},
+		"tags": &hcldec.AttrSpec{Name: "tags", Type: cty.Map(cty.String)},
+		"disk": &hcldec.BlockListSpec{TypeName: "disk", MaxItems: 4, Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+			"type": &hcldec.AttrSpec{Name: "type", Type: cty.String},
+		}},
+		"constant": &hcldec.LiteralSpec{Value: cty.True},
+	}
+
+	got := JSONSchema(spec, &schemaConfig{})
+	want := map[string]interface{}{
+		"$schema":              JSONSchemaDialect,
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []string{"region"},
+		"properties": map[string]interface{}{
+			"region": map[string]interface{}{"type": "string", "description": "The region to build in."},
+			"port":   map[string]interface{}{"type": "number", "default": int64(22)},
+			"tags": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"disk": map[string]interface{}{
+				"type":     "array",
+				"maxItems": 4,
+				"items": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"required":             []string{"size"},
+					"properties": map[string]interface{}{
+						"size": map[string]interface{}{"type": "number", "description": "The size of the disk, in MB."},
+						"type": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected schema: %s", diff)
+	}
+}
+
+func TestJSONSchema_generatedSpec(t *testing.T) {
+	spec := hcldec.ObjectSpec(new(MockConfig).FlatMapstructure().HCL2Spec())
+	schema := JSONSchema(spec, nil)
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	if len(properties) != len(spec) {
+		t.Fatalf("bad properties: %d, expected %d", len(properties), len(spec))
+	}
+	nested := properties["nested_slice"].(map[string]interface{})["items"].(map[string]interface{})
+	tag := nested["properties"].(map[string]interface{})["tag"].(map[string]interface{})
+	if tag["type"] != "array" {
+		t.Fatalf("bad tag schema: %#v", tag)
+	}
+}
+
+func TestTypeJSONSchema(t *testing.T) {
+	tests := []struct {
+		Type cty.Type
+		Want map[string]interface{}
+	}{
+		{cty.Bool, map[string]interface{}{"type": "boolean"}},
+		{cty.Set(cty.String), map[string]interface{}{
+			"type": "array", "items": map[string]interface{}{"type": "string"}, "uniqueItems": true,
+		}},
+		{cty.Tuple([]cty.Type{cty.String, cty.Number}), map[string]interface{}{
+			"type":        "array",
+			"prefixItems": []interface{}{map[string]interface{}{"type": "string"}, map[string]interface{}{"type": "number"}},
+			"items":       false,
+		}},
+		{cty.ObjectWithOptionalAttrs(map[string]cty.Type{"a": cty.String, "b": cty.Bool}, []string{"b"}), map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"a": map[string]interface{}{"type": "string"},
+				"b": map[string]interface{}{"type": "boolean"},
+			},
+			"required": []string{"a"},
+		}},
+		{cty.DynamicPseudoType, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.Want, TypeJSONSchema(tt.Type)); diff != "" {
+			t.Errorf("unexpected schema of %s: %s", tt.Type.FriendlyName(), diff)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type unsetMark struct{}
+
+// Unset marks a value that removes an attribute set by lower layers when
+// passed to MergeValues, as opposed to a null value which leaves it as is.
+//
+//	override := cty.ObjectVal(map[string]cty.Value{
+//		"ssh_password": hcl2helper.Unset(cty.String),
+//	})
+func Unset(ty cty.Type) cty.Value {
+	return cty.NullVal(ty).Mark(unsetMark{})
+}
+
+// MergeConflict records an attribute set to a value by a layer and to a
+// different one by a later layer.
+type MergeConflict struct {
+	Path cty.Path
+	// Layer is the index of the layer that overrode the value.
+	Layer    int
+	Previous cty.Value
+	Value    cty.Value
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf("%s: %s overridden by %s in layer %d",
+		FormatPath(c.Path), FormatValue(c.Previous), FormatValue(c.Value), c.Layer)
+}
+
+// FormatPath renders a cty.Path the way it would be written in HCL, for
+// example `communicator.ssh_port` or `tags["Name"]`.
+func FormatPath(path cty.Path) string {
+	s := ""
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			if s != "" {
+				s += "."
+			}
+			s += step.Name
+		case cty.IndexStep:
+			if step.Key.Type() == cty.String {
+				s += fmt.Sprintf("[%q]", step.Key.AsString())
+			} else {
+				s += fmt.Sprintf("[%s]", step.Key.AsBigFloat().Text('f', -1))
+			}
+		}
+	}
+	return s
+}
+
+// MergeValues deep merges layers of configuration, from the lowest
+// precedence to the highest, for example a defaults file, the template and
+// command line overrides.
+//
+// Objects and maps are merged attribute by attribute. Any other value of a
+// later layer replaces the previous one, lists and sets included. A null
+// value in a later layer means that the layer doesn't set the attribute and
+// the previous value is kept, while a value created with Unset removes it.
+//
+// MergeValues returns every value that was replaced by a different one, so
+// that callers can warn about them or refuse them. An error is returned when
+// layers disagree on the shape of an attribute, like an object in a layer
+// and a string in another.
+func MergeValues(layers ...cty.Value) (cty.Value, []MergeConflict, error) {
+	m := &merger{}
+	result := cty.NullVal(cty.DynamicPseudoType)
+	for i, layer := range layers {
+		m.layer = i
+		var err error
+		result, err = m.merge(nil, result, layer)
+		if err != nil {
+			return cty.NilVal, nil, err
+		}
+	}
+	return stripUnset(result), m.conflicts, nil
+}
+
+type merger struct {
+	layer     int
+	conflicts []MergeConflict
+}
+
+func (m *merger) merge(path cty.Path, previous, next cty.Value) (cty.Value, error) {
+	if next.HasMark(unsetMark{}) {
+		return next, nil
+	}
+	if next.IsKnown() && next.IsNull() {
+		return previous, nil
+	}
+	if previous.IsNull() || !previous.IsKnown() || !next.IsKnown() {
+		return next, nil
+	}
+
+	prevTy, nextTy := previous.Type(), next.Type()
+	prevMergeable := prevTy.IsObjectType() || prevTy.IsMapType()
+	nextMergeable := nextTy.IsObjectType() || nextTy.IsMapType()
+	if prevMergeable != nextMergeable {
+		return cty.NilVal, fmt.Errorf("%s: cannot merge %s with %s in layer %d",
+			FormatPath(path), prevTy.FriendlyName(), nextTy.FriendlyName(), m.layer)
+	}
+
+	if !prevMergeable {
+		if eq := previous.Equals(next); !eq.IsKnown() || eq.False() {
+			m.conflicts = append(m.conflicts, MergeConflict{
+				Path:     copyPath(path),
+				Layer:    m.layer,
+				Previous: previous,
+				Value:    next,
+			})
+		}
+		return next, nil
+	}
+
+	attrs := previous.AsValueMap()
+	if attrs == nil {
+		attrs = map[string]cty.Value{}
+	}
+	nextAttrs := next.AsValueMap()
+	keys := make([]string, 0, len(nextAttrs))
+	for k := range nextAttrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var step cty.PathStep = cty.GetAttrStep{Name: k}
+		if prevTy.IsMapType() || nextTy.IsMapType() {
+			step = cty.IndexStep{Key: cty.StringVal(k)}
+		}
+		prev, ok := attrs[k]
+		if !ok {
+			prev = cty.NullVal(cty.DynamicPseudoType)
+		}
+		merged, err := m.merge(append(path, step), prev, nextAttrs[k])
+		if err != nil {
+			return cty.NilVal, err
+		}
+		attrs[k] = merged
+	}
+
+	if prevTy.IsMapType() && nextTy.IsMapType() {
+		if v, ok := mapVal(attrs); ok {
+			return v, nil
+		}
+	}
+	return cty.ObjectVal(attrs), nil
+}
+
+// mapVal returns attrs as a map if all its elements have the same type.
+func mapVal(attrs map[string]cty.Value) (cty.Value, bool) {
+	var ty cty.Type
+	for _, v := range attrs {
+		if v.HasMark(unsetMark{}) {
+			continue
+		}
+		if ty == cty.NilType {
+			ty = v.Type()
+		} else if !ty.Equals(v.Type()) {
+			return cty.NilVal, false
+		}
+	}
+	if ty == cty.NilType {
+		return cty.MapValEmpty(cty.DynamicPseudoType), true
+	}
+	clean := map[string]cty.Value{}
+	for k, v := range attrs {
+		if !v.HasMark(unsetMark{}) {
+			clean[k] = v
+		}
+	}
+	if len(clean) == 0 {
+		return cty.MapValEmpty(ty), true
+	}
+	return cty.MapVal(clean), true
+}
+
+// stripUnset turns the values marked with Unset into nulls; in maps, the
+// element is removed.
+func stripUnset(v cty.Value) cty.Value {
+	if v.HasMark(unsetMark{}) {
+		v, _ = v.Unmark()
+		return v
+	}
+	if v.IsNull() || !v.IsKnown() {
+		return v
+	}
+	ty := v.Type()
+	switch {
+	case ty.IsObjectType():
+		attrs := v.AsValueMap()
+		for k, attr := range attrs {
+			attrs[k] = stripUnset(attr)
+		}
+		return cty.ObjectVal(attrs)
+	case ty.IsMapType():
+		attrs := v.AsValueMap()
+		for k, elem := range attrs {
+			if elem.HasMark(unsetMark{}) {
+				delete(attrs, k)
+				continue
+			}
+			attrs[k] = stripUnset(elem)
+		}
+		if len(attrs) == 0 {
+			return cty.MapValEmpty(ty.ElementType())
+		}
+		return cty.MapVal(attrs)
+	}
+	return v
+}
+
+func copyPath(path cty.Path) cty.Path {
+	return append(cty.Path{}, path...)
+}
+
+// MergeConfigs merges decoded configuration structs the way MergeValues
+// merges their cty values, spec being the HCL2Spec of their flat
+// representation. Since a struct cannot tell an unset attribute from a zero
+// value, zero values are treated as unset and never override a previous
+// layer. The result can be passed to config.Decode.
+func MergeConfigs(spec map[string]hcldec.Spec, configs ...interface{}) (cty.Value, []MergeConflict, error) {
+	layers := make([]cty.Value, 0, len(configs))
+	for _, conf := range configs {
+		layers = append(layers, nullZeroValues(HCL2ValueFromConfig(conf, spec)))
+	}
+	return MergeValues(layers...)
+}
+
+// nullZeroValues replaces the zero values of v by nulls.
+func nullZeroValues(v cty.Value) cty.Value {
+	if v.IsNull() || !v.IsKnown() {
+		return v
+	}
+	ty := v.Type()
+	switch {
+	case ty.IsObjectType():
+		attrs := v.AsValueMap()
+		for k, attr := range attrs {
+			attrs[k] = nullZeroValues(attr)
+		}
+		return cty.ObjectVal(attrs)
+	case ty == cty.String && v.AsString() == "",
+		ty == cty.Number && v.AsBigFloat().Sign() == 0,
+		ty == cty.Bool && v.False(),
+		(ty.IsListType() || ty.IsMapType() || ty.IsSetType() || ty.IsTupleType()) && v.LengthInt() == 0:
+		return cty.NullVal(ty)
+	}
+	return v
+}
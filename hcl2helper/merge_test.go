@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestMergeValues(t *testing.T) {
+	defaults := cty.ObjectVal(map[string]cty.Value{
+		"region":       cty.StringVal("us-east-1"),
+		"ssh_username": cty.StringVal("ubuntu"),
+		"ssh_password": cty.StringVal("packer"),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Team": cty.StringVal("infra"),
+			"Env":  cty.StringVal("dev"),
+		}),
+		"subnets": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+	})
+	template := cty.ObjectVal(map[string]cty.Value{
+		"region":       cty.StringVal("eu-west-1"),
+		"ssh_username": cty.NullVal(cty.String),
+		"ssh_password": Unset(cty.String),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Env": cty.StringVal("prod"),
+		}),
+		"subnets": cty.ListVal([]cty.Value{cty.StringVal("c")}),
+	})
+	overrides := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("eu-west-1"),
+	})
+
+	merged, conflicts, err := MergeValues(defaults, template, overrides)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"region":       cty.StringVal("eu-west-1"),
+		"ssh_username": cty.StringVal("ubuntu"),
+		"ssh_password": cty.NullVal(cty.String),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Team": cty.StringVal("infra"),
+			"Env":  cty.StringVal("prod"),
+		}),
+		"subnets": cty.ListVal([]cty.Value{cty.StringVal("c")}),
+	})
+	if !merged.RawEquals(expected) {
+		t.Fatalf("unexpected merge result:\n%#v", merged)
+	}
+
+	// Setting the same value again is not a conflict.
+	var paths []string
+	for _, c := range conflicts {
+		if c.Layer != 1 {
+			t.Fatalf("unexpected conflict: %s", c)
+		}
+		paths = append(paths, FormatPath(c.Path))
+	}
+	if len(paths) != 3 || paths[0] != "region" || paths[1] != "subnets" || paths[2] != `tags["Env"]` {
+		t.Fatalf("unexpected conflicts: %v", conflicts)
+	}
+}
+
+func TestMergeValues_typeMismatch(t *testing.T) {
+	a := cty.ObjectVal(map[string]cty.Value{
+		"communicator": cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(22)}),
+	})
+	b := cty.ObjectVal(map[string]cty.Value{
+		"communicator": cty.StringVal("ssh"),
+	})
+	_, _, err := MergeValues(a, b)
+	if err == nil || err.Error() != "communicator: cannot merge object with string in layer 1" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMergeConfigs(t *testing.T) {
+	spec := (&FlatMockConfig{}).HCL2Spec()
+	defaults := MockConfig{NestedMockConfig: NestedMockConfig{String: "default", Int: 4, Bool: true}}
+	override := MockConfig{NestedMockConfig: NestedMockConfig{String: "override"}}
+
+	merged, _, err := MergeConfigs(spec, defaults, override)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	attrs := merged.AsValueMap()
+	if attrs["string"].AsString() != "override" || !attrs["int"].RawEquals(cty.NumberIntVal(4)) || attrs["bool"].False() {
+		t.Fatalf("unexpected merge result: %#v", merged)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Provenance tells where a value of the configuration comes from: the
+// expression of the template it was evaluated from, and the variables this
+// expression references. Values decoded with DecodeBody are marked with
+// their *Provenance, so that the errors of Decode and config.Decode point
+// at the template line and variable to fix.
+type Provenance struct {
+	// Range is the range of the expression in the template.
+	Range hcl.Range
+	// Variables are the variables the expression references, like
+	// `var.disk_size` or `local.tags`, in order.
+	Variables []string
+}
+
+// ExpressionProvenance returns the provenance of the values of expr.
+func ExpressionProvenance(expr hcl.Expression) *Provenance {
+	p := &Provenance{Range: expr.Range()}
+	seen := map[string]bool{}
+	for _, traversal := range expr.Variables() {
+		name := traversal.RootName()
+		// Name the variable itself, not only its kind, as in var.disk_size.
+		if len(traversal) > 1 {
+			if attr, ok := traversal[1].(hcl.TraverseAttr); ok {
+				name += "." + attr.Name
+			}
+		}
+		if !seen[name] {
+			seen[name] = true
+			p.Variables = append(p.Variables, name)
+		}
+	}
+	return p
+}
+
+func (p *Provenance) String() string {
+	if len(p.Variables) == 0 {
+		return "at " + p.Range.String()
+	}
+	return fmt.Sprintf("from %s at %s", strings.Join(p.Variables, ", "), p.Range)
+}
+
+// ProvenanceString describes p, for config.Decode, which can't import this
+// package, to recognize provenance marks.
+func (p *Provenance) ProvenanceString() string {
+	return p.String()
+}
+
+// WithProvenance returns v marked with p.
+func WithProvenance(v cty.Value, p *Provenance) cty.Value {
+	return v.Mark(p)
+}
+
+// ProvenanceOf returns the provenance v is marked with, if any.
+func ProvenanceOf(v cty.Value) (*Provenance, bool) {
+	for mark := range v.Marks() {
+		if p, ok := mark.(*Provenance); ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// DecodeBody decodes body with spec like hcldec.Decode, and marks the value
+// of every attribute of the body, and of its nested blocks, with its
+// Provenance. Marked values must be unmarked before most cty operations;
+// Decode and config.Decode do it. Only native syntax bodies carry
+// provenance; the values of other bodies are returned unmarked.
+func DecodeBody(body hcl.Body, spec hcldec.Spec, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	v, diags := hcldec.Decode(body, spec, ctx)
+	if diags.HasErrors() {
+		return v, diags
+	}
+	var pvm []cty.PathValueMarks
+	bodyProvenance(&pvm, nil, body, v)
+	return v.MarkWithPaths(pvm), diags
+}
+
+// bodyProvenance appends the provenance of the attributes and blocks of
+// body, decoded into v at path, to pvm.
+func bodyProvenance(pvm *[]cty.PathValueMarks, path cty.Path, body hcl.Body, v cty.Value) {
+	sb, ok := body.(*hclsyntax.Body)
+	if !ok || v.IsNull() || !v.IsKnown() || !v.Type().IsObjectType() {
+		return
+	}
+	for name, attr := range sb.Attributes {
+		if !v.Type().HasAttribute(name) {
+			continue
+		}
+		*pvm = append(*pvm, cty.PathValueMarks{
+			Path:  path.GetAttr(name),
+			Marks: cty.NewValueMarks(ExpressionProvenance(attr.Expr)),
+		})
+	}
+
+	indexes := map[string]int{}
+	for _, block := range sb.Blocks {
+		if !v.Type().HasAttribute(block.Type) {
+			continue
+		}
+		i := indexes[block.Type]
+		indexes[block.Type]++
+		blocks := v.GetAttr(block.Type)
+		blockPath := path.GetAttr(block.Type)
+		switch {
+		case blocks.IsNull() || !blocks.IsKnown():
+		case blocks.Type().IsObjectType():
+			if i == 0 {
+				bodyProvenance(pvm, blockPath, block.Body, blocks)
+			}
+		case blocks.Type().IsListType() || blocks.Type().IsTupleType():
+			if i < blocks.LengthInt() {
+				key := cty.NumberIntVal(int64(i))
+				bodyProvenance(pvm, blockPath.Index(key), block.Body, blocks.Index(key))
+			}
+		}
+	}
+}
+
+// provenanceAt returns the provenance of the value at path, or of the
+// closest value containing it, according to the marks of pvm.
+func provenanceAt(pvm []cty.PathValueMarks, path cty.Path) *Provenance {
+	var found *Provenance
+	longest := -1
+	for _, m := range pvm {
+		if len(m.Path) <= longest || len(m.Path) > len(path) || !path[:len(m.Path)].Equals(m.Path) {
+			continue
+		}
+		for mark := range m.Marks {
+			if p, ok := mark.(*Provenance); ok {
+				found, longest = p, len(m.Path)
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type provenanceDisk struct {
+	Size int `mapstructure:"size"`
+}
+
+type provenanceConfig struct {
+	Timeout time.Duration    `mapstructure:"timeout"`
+	Disks   []provenanceDisk `mapstructure:"disk"`
+}
+
+var provenanceSpec = hcldec.ObjectSpec{
+	"timeout": &hcldec.AttrSpec{Name: "timeout", Type: cty.String},
+	"disk": &hcldec.BlockListSpec{
+		TypeName: "disk",
+		Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+		},
+	},
+}
+
+func decodeProvenance(t *testing.T, src string, vars map[string]cty.Value) error {
+	t.Helper()
+	f, diags := hclsyntax.ParseConfig([]byte(src), "build.pkr.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("parse: %s", diags)
+	}
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{"var": cty.ObjectVal(vars)}}
+	v, diags := DecodeBody(f.Body, provenanceSpec, ctx)
+	if diags.HasErrors() {
+		t.Fatalf("decode body: %s", diags)
+	}
+	var c provenanceConfig
+	return Decode(v, &c)
+}
+
+func TestDecodeBody_provenance(t *testing.T) {
+	src := `timeout = "5m"
+
+disk {
+  size = 8
+}
+
+disk {
+  size = var.disk_size
+}
+`
+	err := decodeProvenance(t, src, map[string]cty.Value{
+		"disk_size": cty.NumberFloatVal(1.5),
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	de, ok := err.(*DecodeError)
+	if !ok {
+		t.Fatalf("expected a *DecodeError, got %T: %s", err, err)
+	}
+	if !strings.Contains(err.Error(), "from var.disk_size at build.pkr.hcl:8") {
+		t.Fatalf("error doesn't point at the expression: %s", err)
+	}
+	diag := de.Diagnostic()
+	if diag.Subject == nil || diag.Subject.Filename != "build.pkr.hcl" || diag.Subject.Start.Line != 8 {
+		t.Fatalf("bad diagnostic subject: %#v", diag.Subject)
+	}
+}
+
+func TestDecodeBody_provenanceLiteral(t *testing.T) {
+	err := decodeProvenance(t, `timeout = "five minutes"`, map[string]cty.Value{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "at build.pkr.hcl:1") || strings.Contains(err.Error(), "from") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestDecodeBody_noError(t *testing.T) {
+	src := `timeout = var.timeout
+disk {
+  size = 8
+}
+`
+	f, diags := hclsyntax.ParseConfig([]byte(src), "build.pkr.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("parse: %s", diags)
+	}
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{
+		"var": cty.ObjectVal(map[string]cty.Value{"timeout": cty.StringVal("5m")}),
+	}}
+	v, diags := DecodeBody(f.Body, provenanceSpec, ctx)
+	if diags.HasErrors() {
+		t.Fatalf("decode body: %s", diags)
+	}
+	p, ok := ProvenanceOf(v.GetAttr("timeout"))
+	if !ok || len(p.Variables) != 1 || p.Variables[0] != "var.timeout" {
+		t.Fatalf("bad provenance: %#v", p)
+	}
+
+	var c provenanceConfig
+	if err := Decode(v, &c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.Timeout != 5*time.Minute || len(c.Disks) != 1 || c.Disks[0].Size != 8 {
+		t.Fatalf("bad config: %#v", c)
+	}
+}
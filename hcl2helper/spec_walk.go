@@ -0,0 +1,225 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SpecKind is the kind of a SpecNode, after the hcldec spec decoding it.
+type SpecKind string
+
+const (
+	// SpecAttribute is an attribute, decoded by an hcldec.AttrSpec.
+	SpecAttribute SpecKind = "attribute"
+	// SpecBlock is a single block, decoded by an hcldec.BlockSpec.
+	SpecBlock SpecKind = "block"
+	// SpecBlockList, SpecBlockSet and SpecBlockTuple are repeated blocks.
+	SpecBlockList  SpecKind = "block_list"
+	SpecBlockSet   SpecKind = "block_set"
+	SpecBlockTuple SpecKind = "block_tuple"
+	// SpecBlockMap and SpecBlockObject are blocks keyed by their labels.
+	SpecBlockMap    SpecKind = "block_map"
+	SpecBlockObject SpecKind = "block_object"
+	// SpecBlockAttrs is a block whose attributes are a map, decoded by an
+	// hcldec.BlockAttrsSpec.
+	SpecBlockAttrs SpecKind = "block_attrs"
+)
+
+// SpecNode is an attribute or a block of a spec, as visited by WalkSpec.
+// Fields may be added to it, but their meaning won't change.
+type SpecNode struct {
+	// Path is the names of the blocks containing the node, then the name
+	// of the node.
+	Path []string
+	// Name is the name of the attribute, or the type of the block.
+	Name string
+	Kind SpecKind
+	// Type is the type of the values of attributes, of the attributes of
+	// SpecBlockAttrs blocks, and the implied type of the body of the other
+	// blocks.
+	Type     cty.Type
+	Required bool
+	// MinItems and MaxItems bound the number of repeated blocks, MaxItems
+	// being 0 when unbounded.
+	MinItems int
+	MaxItems int
+	// LabelNames are the names of the labels of SpecBlockMap and
+	// SpecBlockObject blocks.
+	LabelNames []string
+	// Default is the default value of the node, cty.NilVal without one.
+	Default cty.Value
+	// Spec is the spec decoding the node, with its defaults and transforms.
+	Spec hcldec.Spec
+	// Nested is the spec of the body of blocks, nil for attributes and
+	// SpecBlockAttrs blocks.
+	Nested hcldec.Spec
+}
+
+// IsBlock reports whether n is a block.
+func (n *SpecNode) IsBlock() bool {
+	return n.Kind != SpecAttribute
+}
+
+// SkipNested is returned by a SpecVisitor not to visit the body of a block.
+var SkipNested = errors.New("skip the body of this block")
+
+// A SpecVisitor is called by WalkSpec for each node. When it returns an
+// error, the walk stops and WalkSpec returns the error, but for SkipNested.
+type SpecVisitor func(n *SpecNode) error
+
+// WalkSpec calls visit for each attribute and block decoded by spec, depth
+// first, the nodes of a body in the order of their names. Specs not reading
+// the body, like literals, are not visited.
+func WalkSpec(spec hcldec.Spec, visit SpecVisitor) error {
+	return walkSpec(spec, nil, visit)
+}
+
+func walkSpec(spec hcldec.Spec, path []string, visit SpecVisitor) error {
+	var specs []hcldec.Spec
+	if obj, ok := spec.(hcldec.ObjectSpec); ok {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			specs = append(specs, obj[k])
+		}
+	} else {
+		specs = []hcldec.Spec{spec}
+	}
+
+	var nodes []*SpecNode
+	for _, s := range specs {
+		if n := specNode(s); n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	for _, n := range nodes {
+		n.Path = append(append([]string(nil), path...), n.Name)
+		err := visit(n)
+		if errors.Is(err, SkipNested) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if n.Nested != nil {
+			if err := walkSpec(n.Nested, n.Path, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// specNode returns the node decoded by s, or nil when s doesn't read the
+// body.
+func specNode(s hcldec.Spec) *SpecNode {
+	n := &SpecNode{Spec: s}
+	hasDefault := false
+	inner := s
+	for {
+		switch w := inner.(type) {
+		case *hcldec.DefaultSpec:
+			if literal, ok := w.Default.(*hcldec.LiteralSpec); ok {
+				n.Default = literal.Value
+			}
+			hasDefault = true
+			inner = w.Primary
+			continue
+		case *hcldec.TransformExprSpec:
+			inner = w.Wrapped
+			continue
+		case *hcldec.TransformFuncSpec:
+			inner = w.Wrapped
+			continue
+		case *hcldec.RefineValueSpec:
+			inner = w.Wrapped
+			continue
+		case *hcldec.ValidateSpec:
+			inner = w.Wrapped
+			continue
+		}
+		break
+	}
+
+	switch inner := inner.(type) {
+	case *hcldec.AttrSpec:
+		n.Name, n.Kind, n.Type, n.Required = inner.Name, SpecAttribute, inner.Type, inner.Required
+	case *hcldec.BlockSpec:
+		n.Name, n.Kind, n.Nested, n.Required = inner.TypeName, SpecBlock, inner.Nested, inner.Required
+	case *hcldec.BlockListSpec:
+		n.Name, n.Kind, n.Nested = inner.TypeName, SpecBlockList, inner.Nested
+		n.MinItems, n.MaxItems, n.Required = inner.MinItems, inner.MaxItems, inner.MinItems > 0
+	case *hcldec.BlockSetSpec:
+		n.Name, n.Kind, n.Nested = inner.TypeName, SpecBlockSet, inner.Nested
+		n.MinItems, n.MaxItems, n.Required = inner.MinItems, inner.MaxItems, inner.MinItems > 0
+	case *hcldec.BlockTupleSpec:
+		n.Name, n.Kind, n.Nested = inner.TypeName, SpecBlockTuple, inner.Nested
+		n.MinItems, n.MaxItems, n.Required = inner.MinItems, inner.MaxItems, inner.MinItems > 0
+	case *hcldec.BlockMapSpec:
+		n.Name, n.Kind, n.Nested, n.LabelNames = inner.TypeName, SpecBlockMap, inner.Nested, inner.LabelNames
+	case *hcldec.BlockObjectSpec:
+		n.Name, n.Kind, n.Nested, n.LabelNames = inner.TypeName, SpecBlockObject, inner.Nested, inner.LabelNames
+	case *hcldec.BlockAttrsSpec:
+		n.Name, n.Kind, n.Type, n.Required = inner.TypeName, SpecBlockAttrs, inner.ElementType, inner.Required
+	default:
+		return nil
+	}
+	if n.Nested != nil {
+		n.Type = hcldec.ImpliedType(n.Nested)
+	}
+	if hasDefault {
+		n.Required = false
+	}
+	return n
+}
+
+// SpecNodes returns the nodes of spec, in the order WalkSpec visits them.
+func SpecNodes(spec hcldec.Spec) []*SpecNode {
+	var nodes []*SpecNode
+	WalkSpec(spec, func(n *SpecNode) error {
+		nodes = append(nodes, n)
+		return nil
+	})
+	return nodes
+}
+
+// SpecNodeAt returns the node of spec at path, like {"disk", "size"}, and
+// whether there is one.
+func SpecNodeAt(spec hcldec.Spec, path ...string) (*SpecNode, bool) {
+	var found *SpecNode
+	WalkSpec(spec, func(n *SpecNode) error {
+		if found != nil || !pathPrefix(n.Path, path) {
+			return SkipNested
+		}
+		if len(n.Path) == len(path) {
+			found = n
+			return SkipNested
+		}
+		return nil
+	})
+	return found, found != nil
+}
+
+// pathPrefix reports whether prefix is a prefix of path.
+func pathPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func testWalkSpec() hcldec.ObjectSpec {
+	return hcldec.ObjectSpec{
+		"region": &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: true},
+		"port": &hcldec.DefaultSpec{
+			Primary: &hcldec.AttrSpec{Name: "port", Type: cty.Number, Required: true},
+			Default: &hcldec.LiteralSpec{Value: cty.NumberIntVal(22)},
+		},
+		"disk": &hcldec.BlockListSpec{TypeName: "disk", MinItems: 1, MaxItems: 4, Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number, Required: true},
+			"tags": &hcldec.BlockAttrsSpec{TypeName: "tags", ElementType: cty.String},
+		}},
+		"network": &hcldec.BlockMapSpec{TypeName: "network", LabelNames: []string{"name"}, Nested: hcldec.ObjectSpec{
+			"cidr": &hcldec.AttrSpec{Name: "cidr", Type: cty.String},
+		}},
+		"constant": &hcldec.LiteralSpec{Value: cty.True},
+	}
+}
+
+func TestWalkSpec(t *testing.T) {
+	var visited []string
+	err := WalkSpec(testWalkSpec(), func(n *SpecNode) error {
+		visited = append(visited, strings.Join(n.Path, ".")+" "+string(n.Kind))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{
+		"disk block_list",
+		"disk.size attribute",
+		"disk.tags block_attrs",
+		"network block_map",
+		"network.cidr attribute",
+		"port attribute",
+		"region attribute",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("expected %q, got %q", expected, visited)
+	}
+
+	visited = nil
+	WalkSpec(testWalkSpec(), func(n *SpecNode) error {
+		visited = append(visited, n.Name)
+		if n.IsBlock() {
+			return SkipNested
+		}
+		return nil
+	})
+	if expected := []string{"disk", "network", "port", "region"}; !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("the blocks should not be walked: %q", visited)
+	}
+
+	stop := errors.New("stop")
+	if err := WalkSpec(testWalkSpec(), func(*SpecNode) error { return stop }); err != stop {
+		t.Fatalf("expected the error of the visitor, got %v", err)
+	}
+}
+
+func TestSpecNodeAt(t *testing.T) {
+	spec := testWalkSpec()
+
+	disk, ok := SpecNodeAt(spec, "disk")
+	if !ok {
+		t.Fatal("disk not found")
+	}
+	if disk.MinItems != 1 || disk.MaxItems != 4 || !disk.Required || !disk.Type.Equals(cty.Object(map[string]cty.Type{
+		"size": cty.Number,
+		"tags": cty.Map(cty.String),
+	})) {
+		t.Fatalf("bad disk: %#v", disk)
+	}
+
+	port, _ := SpecNodeAt(spec, "port")
+	if port.Required || !port.Default.RawEquals(cty.NumberIntVal(22)) || port.Type != cty.Number {
+		t.Fatalf("bad port: %#v", port)
+	}
+	if _, ok := port.Spec.(*hcldec.DefaultSpec); !ok {
+		t.Fatalf("the spec of the node should keep its default: %#v", port.Spec)
+	}
+
+	size, ok := SpecNodeAt(spec, "disk", "size")
+	if !ok || !size.Required || size.Type != cty.Number || !reflect.DeepEqual(size.Path, []string{"disk", "size"}) {
+		t.Fatalf("bad size: %#v", size)
+	}
+	network, _ := SpecNodeAt(spec, "network")
+	if !reflect.DeepEqual(network.LabelNames, []string{"name"}) {
+		t.Fatalf("bad network: %#v", network)
+	}
+
+	if _, ok := SpecNodeAt(spec, "size"); ok {
+		t.Fatal("size is only in disk blocks")
+	}
+	if _, ok := SpecNodeAt(spec, "constant"); ok {
+		t.Fatal("literals are not nodes")
+	}
+	if len(SpecNodes(spec)) != 7 {
+		t.Fatalf("bad nodes: %#v", SpecNodes(spec))
+	}
+}
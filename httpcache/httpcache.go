@@ -0,0 +1,417 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package httpcache caches the responses of HTTP GET requests on disk, for
+// template functions and datasources querying external APIs, so that
+// repeated runs of `packer validate` or `packer build`, in CI for example,
+// don't hit them, and their rate limits, every time:
+//
+//	client := httpcache.NewClient()
+//	resp, err := client.Get("https://api.example.com/images/latest")
+//
+// Responses are fresh for as long as their Cache-Control or Expires headers
+// allow, or MaxAge otherwise. Stale responses with an ETag or a
+// Last-Modified header are revalidated with a conditional request, and
+// served again when the server answers 304 Not Modified. The cache is
+// shared by the processes of the host, and its least recently used
+// responses are removed when it grows past MaxSize.
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+const (
+	// DefaultMaxSize is the size in bytes of a cache by default.
+	DefaultMaxSize = 64 * 1024 * 1024
+	// DefaultMaxAge is how long responses without caching headers are
+	// fresh by default.
+	DefaultMaxAge = 5 * time.Minute
+
+	// CacheStatusHeader is set in the responses returned by a Transport:
+	// `hit` for responses served from the cache, `revalidated` for
+	// responses served from the cache after a 304 Not Modified, `stale`
+	// for responses served from the cache because the server could not be
+	// reached, and `miss` for the others.
+	CacheStatusHeader = "X-Packer-Cache"
+
+	entrySuffix = ".entry"
+)
+
+// Transport is an http.RoundTripper caching the responses of GET requests
+// in a directory. Its fields must not be changed once it's in use.
+type Transport struct {
+	// Dir is the directory of the cache.
+	Dir string
+	// MaxSize is the size in bytes past which the least recently used
+	// responses are removed. Responses larger than a tenth of it are not
+	// cached. DefaultMaxSize when 0.
+	MaxSize int64
+	// MaxAge is how long responses without Cache-Control or Expires
+	// headers are fresh. They are revalidated every time when 0.
+	MaxAge time.Duration
+	// Base does the requests, http.DefaultTransport when nil.
+	Base http.RoundTripper
+
+	l sync.Mutex
+}
+
+// New returns a transport caching responses in dir, fresh for
+// DefaultMaxAge by default, and requesting through base.
+func New(dir string, base http.RoundTripper) *Transport {
+	return &Transport{Dir: dir, MaxAge: DefaultMaxAge, Base: base}
+}
+
+var (
+	defaultTransport     *Transport
+	defaultTransportErr  error
+	defaultTransportOnce sync.Once
+)
+
+// Default returns the transport caching responses in the http directory of
+// the Packer cache directory, see packersdk.CachePath, and retrying failed
+// requests through the proxy of the environment.
+func Default() (*Transport, error) {
+	defaultTransportOnce.Do(func() {
+		var dir string
+		dir, defaultTransportErr = packersdk.CachePath("http")
+		if defaultTransportErr == nil {
+			defaultTransport = New(dir, retry.NewHTTPClient().Transport)
+		}
+	})
+	return defaultTransport, defaultTransportErr
+}
+
+// NewClient returns a client using the default transport, or one not
+// caching responses when the cache directory can't be used.
+func NewClient() *http.Client {
+	t, err := Default()
+	if err != nil {
+		log.Printf("[WARN] HTTP responses won't be cached: %s", err)
+		return retry.NewHTTPClient()
+	}
+	return &http.Client{Transport: t}
+}
+
+// entry is a cached response.
+type entry struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	// StoredAt is when the response was received or last revalidated.
+	StoredAt time.Time `json:"stored_at"`
+
+	body []byte
+}
+
+// fresh tells whether e can be served without asking the server.
+func (e *entry) fresh(t *Transport, req *http.Request, now time.Time) bool {
+	reqCC := parseCacheControl(req.Header.Get("Cache-Control"))
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	if _, ok := parseCacheControl(e.Header.Get("Cache-Control"))["no-cache"]; ok {
+		return false
+	}
+	maxAge := e.maxAge(t)
+	if v, ok := reqCC["max-age"]; ok {
+		if secs, err := strconv.Atoi(v); err == nil && time.Duration(secs)*time.Second < maxAge {
+			maxAge = time.Duration(secs) * time.Second
+		}
+	}
+	return now.Sub(e.StoredAt) < maxAge
+}
+
+// maxAge returns how long e is fresh after it was stored.
+func (e *entry) maxAge(t *Transport) time.Duration {
+	cc := parseCacheControl(e.Header.Get("Cache-Control"))
+	if v, ok := cc["max-age"]; ok {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		exp, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(e.Header.Get("Date"))
+		if err != nil {
+			date = e.StoredAt
+		}
+		return exp.Sub(date)
+	}
+	return t.MaxAge
+}
+
+func (e *entry) response(req *http.Request, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set(CacheStatusHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// parseCacheControl returns the directives of a Cache-Control header, in
+// lower case, with their value.
+func parseCacheControl(header string) map[string]string {
+	cc := map[string]string{}
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(directive, "=")
+		cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	return cc
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *Transport) maxSize() int64 {
+	if t.MaxSize <= 0 {
+		return DefaultMaxSize
+	}
+	return t.MaxSize
+}
+
+// key returns the name of the entry of req. Requests with different
+// credentials or accepting different formats don't share entries.
+func key(req *http.Request) string {
+	h := sha256.New()
+	for _, s := range []string{req.URL.String(), req.Header.Get("Authorization"), req.Header.Get("Accept")} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)) + entrySuffix
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "" && req.Method != http.MethodGet {
+		return t.base().RoundTrip(req)
+	}
+	if _, ok := parseCacheControl(req.Header.Get("Cache-Control"))["no-store"]; ok {
+		return t.base().RoundTrip(req)
+	}
+
+	name := key(req)
+	cached, err := t.load(name)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] Ignoring the cached response of %s: %s", req.URL.Redacted(), err)
+	}
+	now := time.Now()
+	if cached != nil && cached.fresh(t, req, now) {
+		t.touch(name)
+		return cached.response(req, "hit"), nil
+	}
+
+	outReq := req
+	if cached != nil {
+		etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outReq = req.Clone(req.Context())
+			if etag != "" {
+				outReq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outReq.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	resp, err := t.base().RoundTrip(outReq)
+	if err != nil {
+		if cached != nil && req.Context().Err() == nil {
+			log.Printf("[WARN] Using the cached response of %s: %s", req.URL.Redacted(), err)
+			return cached.response(req, "stale"), nil
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+		cached.StoredAt = now
+		t.store(name, cached)
+		return cached.response(req, "revalidated"), nil
+	}
+
+	resp.Header.Set(CacheStatusHeader, "miss")
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	if _, ok := parseCacheControl(resp.Header.Get("Cache-Control"))["no-store"]; ok {
+		return resp, nil
+	}
+
+	// Read the body to cache it, unless it is too large.
+	limit := t.maxSize() / 10
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del(CacheStatusHeader)
+	t.store(name, &entry{
+		URL:        req.URL.Redacted(),
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		StoredAt:   now,
+		body:       body,
+	})
+	return resp, nil
+}
+
+// load reads the entry name: a line of JSON encoded entry, and the body.
+func (t *Transport) load(name string) (*entry, error) {
+	b, err := os.ReadFile(filepath.Join(t.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	meta, body, ok := bytes.Cut(b, []byte{'\n'})
+	if !ok {
+		return nil, fmt.Errorf("truncated entry %s", name)
+	}
+	e := new(entry)
+	if err := json.Unmarshal(meta, e); err != nil {
+		return nil, err
+	}
+	e.body = body
+	return e, nil
+}
+
+// store writes e atomically, for other processes to never read a partial
+// entry, then removes the least recently used entries if the cache grew
+// too large. Failures are only logged: caching is an optimization.
+func (t *Transport) store(name string, e *entry) {
+	if err := t.write(name, e); err != nil {
+		log.Printf("[WARN] Failed to cache the response of %s: %s", e.URL, err)
+		return
+	}
+	if err := t.GC(); err != nil {
+		log.Printf("[WARN] Failed to clean the HTTP cache up: %s", err)
+	}
+}
+
+func (t *Transport) write(name string, e *entry) error {
+	meta, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(t.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.Write(meta)
+	w.WriteByte('\n')
+	w.Write(e.body)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(t.Dir, name)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// touch marks the entry name as used now, for GC to keep it.
+func (t *Transport) touch(name string) {
+	now := time.Now()
+	_ = os.Chtimes(filepath.Join(t.Dir, name), now, now)
+}
+
+// GC removes the least recently used entries until the cache holds at most
+// MaxSize bytes.
+func (t *Transport) GC() error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	entries, err := os.ReadDir(t.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var files []os.FileInfo
+	total := int64(0)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), entrySuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	// Least recently used first.
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, f := range files {
+		if total <= t.maxSize() {
+			break
+		}
+		if err := os.Remove(filepath.Join(t.Dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= f.Size()
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package httpcache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, client *http.Client, url string, header ...string) (string, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return string(body), resp.Header.Get(CacheStatusHeader)
+}
+
+func TestTransport_maxAge(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		io.WriteString(w, "image-"+r.URL.Path)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: New(t.TempDir(), nil)}
+	for i, want := range []string{"miss", "hit"} {
+		body, status := get(t, client, srv.URL+"/latest")
+		if body != "image-/latest" || status != want {
+			t.Fatalf("request %d: got %q, %s", i, body, status)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 request, got %d", requests)
+	}
+
+	if _, status := get(t, client, srv.URL+"/latest", "Cache-Control", "no-cache"); status != "miss" {
+		t.Fatalf("no-cache requests should not be served from the cache, got %s", status)
+	}
+	if _, status := get(t, client, srv.URL+"/latest", "Authorization", "Bearer other"); status != "miss" {
+		t.Fatalf("other credentials should not share the entry, got %s", status)
+	}
+	get(t, client, srv.URL+"/private")
+	if _, status := get(t, client, srv.URL+"/private"); status != "miss" {
+		t.Fatalf("no-store responses should not be cached, got %s", status)
+	}
+}
+
+func TestTransport_revalidate(t *testing.T) {
+	var requests, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, `{"version": 1}`)
+	}))
+	defer srv.Close()
+
+	// Without MaxAge, responses are revalidated every time.
+	client := &http.Client{Transport: &Transport{Dir: t.TempDir()}}
+	for i, want := range []string{"miss", "revalidated", "revalidated"} {
+		body, status := get(t, client, srv.URL)
+		if body != `{"version": 1}` || status != want {
+			t.Fatalf("request %d: got %q, %s", i, body, status)
+		}
+	}
+	if requests != 3 || notModified != 2 {
+		t.Fatalf("expected 3 requests, 2 not modified, got %d, %d", requests, notModified)
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("network is unreachable")
+}
+
+func TestTransport_stale(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "v1")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	get(t, &http.Client{Transport: &Transport{Dir: dir}}, srv.URL)
+
+	client := &http.Client{Transport: &Transport{Dir: dir, Base: failingTransport{}}}
+	if body, status := get(t, client, srv.URL); body != "v1" || status != "stale" {
+		t.Fatalf("got %q, %s", body, status)
+	}
+	if _, err := client.Get(srv.URL + "/uncached"); err == nil {
+		t.Fatal("expected an error for a response not cached")
+	}
+}
+
+func TestTransport_GC(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=600")
+		size := 90
+		if r.URL.Path == "/large" {
+			size = 2000
+		}
+		io.WriteString(w, strings.Repeat("x", size))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	tr := &Transport{Dir: dir, MaxSize: 1000}
+	client := &http.Client{Transport: tr}
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+		get(t, client, srv.URL+path)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, status := get(t, client, srv.URL+"/large"); status != "miss" {
+		t.Fatalf("bad status %s", status)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	total := int64(0)
+	for _, e := range entries {
+		info, _ := e.Info()
+		total += info.Size()
+	}
+	if total > tr.MaxSize || len(entries) == 0 || len(entries) == 6 {
+		t.Fatalf("the cache should hold some entries within %d bytes, got %d entries of %d bytes", tr.MaxSize, len(entries), total)
+	}
+	if _, status := get(t, client, srv.URL+"/f"); status != "hit" {
+		t.Fatalf("the most recent entry should be kept, got %s", status)
+	}
+	if _, status := get(t, client, srv.URL+"/a"); status != "miss" {
+		t.Fatalf("the least recent entry should be removed, got %s", status)
+	}
+}
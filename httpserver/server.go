@@ -0,0 +1,187 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package httpserver serves files to guests during a build, like kickstart,
+// preseed or cloud-init files, from a directory or from memory. Files can be
+// rendered against the variables of the build, and served over HTTPS with a
+// certificate generated for the build.
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// TemplateSuffix is the suffix of the files rendered before being served,
+// under their name without it: "ks.cfg.tmpl" is served as "ks.cfg".
+const TemplateSuffix = ".tmpl"
+
+// Server serves the files of Dir, or the in-memory Content.
+type Server struct {
+	// Dir is the directory to serve.
+	Dir string
+	// Content are the files to serve when Dir is empty, by path.
+	Content map[string]string
+
+	// TemplateContext renders the files ending with TemplateSuffix, when
+	// set. Its Data can be set once the server is started, with the address
+	// of the server for example, as long as it is before the guest requests
+	// the files.
+	TemplateContext *interpolate.Context
+
+	// TLS serves the files over HTTPS. The certificate of CertFile and
+	// KeyFile is used, or a self-signed one generated for Hosts when they
+	// are empty.
+	TLS               bool
+	CertFile, KeyFile string
+	Hosts             []string
+
+	// Ui is told of the requests, when set.
+	Ui packersdk.Ui
+
+	// Certificate is the PEM encoded certificate served, set by Serve when
+	// serving over HTTPS, for the guest to trust.
+	Certificate []byte
+
+	lock   sync.Mutex
+	server *http.Server
+}
+
+// Serve serves the files on l in the background, until Shutdown is called.
+func (s *Server) Serve(l net.Listener) error {
+	server := &http.Server{Handler: s, ReadHeaderTimeout: 30 * time.Second}
+	if s.TLS {
+		cert, err := s.certificate()
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		l = tls.NewListener(l, server.TLSConfig)
+	}
+
+	s.lock.Lock()
+	s.server = server
+	s.lock.Unlock()
+	go func() {
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] HTTP server stopped: %s", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the server and closes its listener, letting the ongoing
+// requests finish until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	server := s.server
+	s.server = nil
+	s.lock.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	if s.Dir != "" {
+		s.serveDir(rw, r)
+	} else {
+		s.serveContent(rw, r)
+	}
+	log.Printf("HTTP server: %s %s from %s: %d", r.Method, r.URL.Path, r.RemoteAddr, rw.status)
+	if s.Ui != nil {
+		s.Ui.Message(i18n.Sprintf("HTTP server: %s %s from %s: %d", r.Method, r.URL.Path, r.RemoteAddr, rw.status))
+	}
+}
+
+func (s *Server) serveDir(w http.ResponseWriter, r *http.Request) {
+	if s.TemplateContext != nil {
+		dir := http.Dir(s.Dir)
+		f, err := dir.Open(path.Clean("/"+r.URL.Path) + TemplateSuffix)
+		if err == nil {
+			defer f.Close()
+			info, err := f.Stat()
+			if err == nil && !info.IsDir() {
+				data, err := io.ReadAll(f)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				s.serveTemplate(w, r, string(data), info.ModTime())
+				return
+			}
+		}
+	}
+	http.FileServer(http.Dir(s.Dir)).ServeHTTP(w, r)
+}
+
+func (s *Server) serveContent(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + r.URL.Path)
+	lookup := func(p string) (string, bool) {
+		if content, ok := s.Content[p]; ok {
+			return content, true
+		}
+		content, ok := s.Content[strings.TrimPrefix(p, "/")]
+		return content, ok
+	}
+	if s.TemplateContext != nil {
+		if content, ok := lookup(p + TemplateSuffix); ok {
+			s.serveTemplate(w, r, content, time.Time{})
+			return
+		}
+	}
+	content, ok := lookup(p)
+	if !ok {
+		paths := make([]string, 0, len(s.Content))
+		for k := range s.Content {
+			paths = append(paths, k)
+		}
+		sort.Strings(paths)
+		msg := fmt.Sprintf("%s not found.", p)
+		if sug := didyoumean.NameSuggestion(p, paths); sug != "" {
+			msg += fmt.Sprintf(" Did you mean %q?", sug)
+		}
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+	http.ServeContent(w, r, p, time.Time{}, strings.NewReader(content))
+}
+
+func (s *Server) serveTemplate(w http.ResponseWriter, r *http.Request, tpl string, modTime time.Time) {
+	rendered, err := interpolate.Render(tpl, s.TemplateContext)
+	if err != nil {
+		log.Printf("[ERROR] HTTP server: rendering %s: %s", r.URL.Path, err)
+		http.Error(w, fmt.Sprintf("Error rendering %s: %s", r.URL.Path, err), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, r.URL.Path, modTime, bytes.NewReader([]byte(rendered)))
+}
+
+// statusRecorder records the status of a response, for the logs.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
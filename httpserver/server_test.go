@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestServer_dir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "plain.cfg"), []byte("{{ .HTTPIP }}"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ks.cfg.tmpl"), []byte("url --url http://{{ .HTTPIP }}/repo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := &packersdk.BasicUi{Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	s := &Server{
+		Dir:             dir,
+		TemplateContext: &interpolate.Context{Data: map[string]string{"HTTPIP": "10.0.2.2"}},
+		Ui:              ui,
+	}
+
+	if code, body := get(t, s, "/ks.cfg"); code != 200 || body != "url --url http://10.0.2.2/repo" {
+		t.Fatalf("bad response %d: %q", code, body)
+	}
+	// Files without the template suffix are served as is.
+	if code, body := get(t, s, "/plain.cfg"); code != 200 || body != "{{ .HTTPIP }}" {
+		t.Fatalf("bad response %d: %q", code, body)
+	}
+	if code, _ := get(t, s, "/missing"); code != 404 {
+		t.Fatalf("bad code: %d", code)
+	}
+	if !strings.Contains(ui.Writer.(*bytes.Buffer).String(), "GET /ks.cfg from") {
+		t.Fatalf("request not logged: %q", ui.Writer.(*bytes.Buffer).String())
+	}
+}
+
+func TestServer_content(t *testing.T) {
+	s := &Server{
+		Content: map[string]string{
+			"/user-data":       "#cloud-config",
+			"meta-data.tmpl":   "instance-id: {{ .Name }}",
+			"/broken.cfg.tmpl": "{{ .Name",
+		},
+		TemplateContext: &interpolate.Context{Data: map[string]string{"Name": "packer"}},
+	}
+	if code, body := get(t, s, "/user-data"); code != 200 || body != "#cloud-config" {
+		t.Fatalf("bad response %d: %q", code, body)
+	}
+	if code, body := get(t, s, "/meta-data"); code != 200 || body != "instance-id: packer" {
+		t.Fatalf("bad response %d: %q", code, body)
+	}
+	if code, _ := get(t, s, "/broken.cfg"); code != 500 {
+		t.Fatalf("bad code: %d", code)
+	}
+	if code, body := get(t, s, "/user-dat"); code != 404 || !strings.Contains(body, `Did you mean "/user-data"?`) {
+		t.Fatalf("bad response %d: %q", code, body)
+	}
+}
+
+func TestStepServe(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packersdk.BasicUi{Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)})
+
+	step := &StepServe{
+		Server:  &Server{Content: map[string]string{"/user-data": "#cloud-config"}, TLS: true, Hosts: []string{"10.0.2.2"}},
+		PortMin: 8000,
+		PortMax: 9000,
+		Address: "127.0.0.1",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	defer step.Cleanup(state)
+	if state.Get("http_scheme") != "https" {
+		t.Fatalf("bad scheme: %v", state.Get("http_scheme"))
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(state.Get("http_certificate").([]byte)) {
+		t.Fatalf("bad certificate")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/user-data", state.Get("http_port")))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "#cloud-config" {
+		t.Fatalf("bad body: %q", body)
+	}
+
+	step.Cleanup(state)
+	if _, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/user-data", state.Get("http_port"))); err == nil {
+		t.Fatalf("expected the server to be shut down")
+	}
+}
+
+func TestStepServe_nothingToServe(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packersdk.BasicUi{Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)})
+	step := &StepServe{Server: &Server{}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("http_port") != 0 {
+		t.Fatalf("bad port: %v", state.Get("http_port"))
+	}
+	step.Cleanup(state)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/net"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// DefaultShutdownTimeout is how long StepServe lets the ongoing requests
+// finish when the build ends.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// StepServe starts Server on a free port of [PortMin, PortMax) for the rest
+// of the build, and shuts it down gracefully on cleanup. It does nothing when
+// there is nothing to serve. The Ui of the state is told of the requests
+// unless the Server has its own.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	http_port int - The port the server listens to, 0 when not started.
+//	http_scheme string - "http" or "https".
+//	http_certificate []byte - The PEM encoded certificate served over HTTPS.
+type StepServe struct {
+	Server *Server
+
+	PortMin, PortMax int
+	// Address is the address to listen to, all of them when empty.
+	Address string
+	// Network is like "tcp", "tcp4" or "tcp6". Defaults to "tcp".
+	Network string
+	// ShutdownTimeout defaults to DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	l *net.Listener
+}
+
+func (s *StepServe) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	if s.Server == nil || (s.Server.Dir == "" && len(s.Server.Content) == 0) {
+		state.Put("http_port", 0)
+		return multistep.ActionContinue
+	}
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if s.Server.Dir != "" {
+		if _, err := os.Stat(s.Server.Dir); err != nil {
+			return halt(i18n.Errorf("Error finding %q: %s", s.Server.Dir, err))
+		}
+	}
+	if s.Server.Ui == nil {
+		s.Server.Ui = ui
+	}
+
+	l, err := net.ListenRangeConfig{
+		Min:     s.PortMin,
+		Max:     s.PortMax,
+		Addr:    s.Address,
+		Network: s.Network,
+	}.Listen(ctx)
+	if err != nil {
+		return halt(i18n.Errorf("Error finding port: %s", err))
+	}
+	if err := s.Server.Serve(l); err != nil {
+		l.Close()
+		return halt(i18n.Errorf("Error starting HTTP server: %s", err))
+	}
+	s.l = l
+	scheme := "http"
+	if s.Server.TLS {
+		scheme = "https"
+		state.Put("http_certificate", s.Server.Certificate)
+	}
+	ui.Say(i18n.Sprintf("Starting HTTP server on port %d", l.Port))
+	state.Put("http_port", l.Port)
+	state.Put("http_scheme", scheme)
+	return multistep.ActionContinue
+}
+
+func (s *StepServe) Cleanup(state multistep.StateBag) {
+	if s.l == nil {
+		return
+	}
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Shutdown closes the listener, releasing the port.
+	if err := s.Server.Shutdown(ctx); err != nil {
+		ui := state.Get("ui").(packersdk.Ui)
+		ui.Error(fmt.Sprintf("Failed shutting down HTTP server on port %d: %s", s.l.Port, err))
+		s.l.Close()
+	}
+	s.l = nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package i18n lets distributions of Packer and its plugins translate the
+// messages the SDK writes to users.
+//
+// Messages are identified by their English format string, so that call
+// sites stay readable and messages without a translation are displayed as
+// they always were:
+//
+//	ui.Say(i18n.Sprintf("Creating floppy disk..."))
+//	return i18n.Errorf("Error creating floppy: %s", err)
+//
+// A translated format string must take the same arguments, in the same
+// order, as the original one. The Askf, Sayf and Errorf methods of the Uis
+// of the SDK translate their format string the same way.
+package i18n
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Catalog translates format strings.
+type Catalog interface {
+	// Translate returns the translation of format, false when it has none.
+	Translate(format string) (string, bool)
+}
+
+// MapCatalog is a Catalog mapping the English format strings to their
+// translation.
+type MapCatalog map[string]string
+
+func (c MapCatalog) Translate(format string) (string, bool) {
+	t, ok := c[format]
+	return t, ok
+}
+
+var (
+	l       sync.RWMutex
+	catalog Catalog
+)
+
+// SetCatalog makes c translate the messages of the process; nil disables
+// translation. It is meant to be called once, from main.
+func SetCatalog(c Catalog) {
+	l.Lock()
+	defer l.Unlock()
+	catalog = c
+}
+
+// T returns the translation of format, or format itself when it has none.
+func T(format string) string {
+	l.RLock()
+	c := catalog
+	l.RUnlock()
+	if c == nil {
+		return format
+	}
+	if t, ok := c.Translate(format); ok {
+		return t
+	}
+	return format
+}
+
+// Sprintf formats the translation of format like fmt.Sprintf.
+func Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Errorf formats the translation of format like fmt.Errorf, so %w still
+// wraps errors.
+func Errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(T(format), args...)
+}
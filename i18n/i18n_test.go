@@ -0,0 +1,39 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package i18n
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSprintf(t *testing.T) {
+	defer SetCatalog(nil)
+
+	if s := Sprintf("Copying file: %s", "a"); s != "Copying file: a" {
+		t.Fatalf("bad message: %q", s)
+	}
+
+	SetCatalog(MapCatalog{"Copying file: %s": "Copie du fichier : %s"})
+	if s := Sprintf("Copying file: %s", "a"); s != "Copie du fichier : a" {
+		t.Fatalf("bad translation: %q", s)
+	}
+	if s := Sprintf("Creating floppy disk..."); s != "Creating floppy disk..." {
+		t.Fatalf("untranslated messages should be kept: %q", s)
+	}
+}
+
+func TestErrorf(t *testing.T) {
+	defer SetCatalog(nil)
+	SetCatalog(MapCatalog{"Error creating floppy: %w": "Erreur de création de la disquette : %w"})
+
+	cause := errors.New("disk full")
+	err := Errorf("Error creating floppy: %w", cause)
+	if err.Error() != "Erreur de création de la disquette : disk full" {
+		t.Fatalf("bad error: %q", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("error should wrap its cause")
+	}
+}
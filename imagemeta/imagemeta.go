@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package imagemeta describes the images produced by builders in a way that
+// doesn't depend on the cloud. Builders return it from the State of their
+// artifact under StateKey, and post-processors and other tools read it with
+// FromArtifact instead of parsing the Id or the String of the artifact.
+package imagemeta
+
+import (
+	"fmt"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/mitchellh/mapstructure"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// StateKey is the name under which artifacts return their images from State.
+const StateKey = "image_metadata"
+
+// Architectures, as reported by Go, of Architecture.
+const (
+	ArchAMD64 = "amd64"
+	ArchARM64 = "arm64"
+)
+
+// Operating system families of OSFamily.
+const (
+	OSFamilyLinux   = "linux"
+	OSFamilyWindows = "windows"
+	OSFamilyMacOS   = "macos"
+	OSFamilyBSD     = "bsd"
+)
+
+// Virtualization types of VirtualizationType.
+const (
+	VirtualizationHVM         = "hvm"
+	VirtualizationParavirtual = "paravirtual"
+)
+
+// Metadata describes an image. Fields that don't apply to a cloud, like Zone
+// for regional images, are left empty.
+type Metadata struct {
+	// ID is the cloud specific identifier of the image, for example an AMI
+	// ID.
+	ID   string `cty:"id" json:"id" mapstructure:"id"`
+	Name string `cty:"name" json:"name" mapstructure:"name"`
+	// Region and Zone are where the image is available. An image copied to
+	// several regions is described once per region.
+	Region             string `cty:"region" json:"region" mapstructure:"region"`
+	Zone               string `cty:"zone" json:"zone" mapstructure:"zone"`
+	Architecture       string `cty:"architecture" json:"architecture" mapstructure:"architecture"`
+	OSFamily           string `cty:"os_family" json:"os_family" mapstructure:"os_family"`
+	VirtualizationType string `cty:"virtualization_type" json:"virtualization_type" mapstructure:"virtualization_type"`
+	Encrypted          bool   `cty:"encrypted" json:"encrypted" mapstructure:"encrypted"`
+	// EncryptionKey identifies the key the image is encrypted with, when it
+	// isn't the default key of the cloud.
+	EncryptionKey string `cty:"encryption_key" json:"encryption_key" mapstructure:"encryption_key"`
+}
+
+// CtyType is the type of the cty value of a Metadata, for example to expose
+// images to HCL2 templates.
+var CtyType = cty.Object(map[string]cty.Type{
+	"id":                  cty.String,
+	"name":                cty.String,
+	"region":              cty.String,
+	"zone":                cty.String,
+	"architecture":        cty.String,
+	"os_family":           cty.String,
+	"virtualization_type": cty.String,
+	"encrypted":           cty.Bool,
+	"encryption_key":      cty.String,
+})
+
+// CtyValue returns m as a value of type CtyType.
+func (m Metadata) CtyValue() cty.Value {
+	v, err := gocty.ToCtyValue(m, CtyType)
+	if err != nil {
+		// All the fields of Metadata have a type in CtyType.
+		panic(err)
+	}
+	return v
+}
+
+// FromCtyValue decodes a value of type CtyType.
+func FromCtyValue(v cty.Value) (Metadata, error) {
+	var m Metadata
+	err := gocty.FromCtyValue(v, &m)
+	return m, err
+}
+
+// Validate returns an error for each field of m that isn't set or has an
+// unknown value.
+func (m Metadata) Validate() []error {
+	var errs []error
+	if m.ID == "" {
+		errs = append(errs, fmt.Errorf("the image has no ID"))
+	}
+	if !oneOf(m.Architecture, "", ArchAMD64, ArchARM64) {
+		errs = append(errs, fmt.Errorf("unknown architecture %q", m.Architecture))
+	}
+	if !oneOf(m.OSFamily, "", OSFamilyLinux, OSFamilyWindows, OSFamilyMacOS, OSFamilyBSD) {
+		errs = append(errs, fmt.Errorf("unknown OS family %q", m.OSFamily))
+	}
+	if !oneOf(m.VirtualizationType, "", VirtualizationHVM, VirtualizationParavirtual) {
+		errs = append(errs, fmt.Errorf("unknown virtualization type %q", m.VirtualizationType))
+	}
+	if m.EncryptionKey != "" && !m.Encrypted {
+		errs = append(errs, fmt.Errorf("the image has an encryption key but is not encrypted"))
+	}
+	return errs
+}
+
+func oneOf(s string, values ...string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// FromArtifact returns the images of an artifact, nil when the builder that
+// created it doesn't describe them.
+func FromArtifact(a packersdk.Artifact) ([]Metadata, error) {
+	state := a.State(StateKey)
+	switch s := state.(type) {
+	case nil:
+		return nil, nil
+	case []Metadata:
+		return s, nil
+	}
+	// Artifacts of plugins are received over RPC as generic lists and maps.
+	var images []Metadata
+	if err := mapstructure.Decode(state, &images); err != nil {
+		return nil, fmt.Errorf("invalid image metadata of artifact %s: %s", a.Id(), err)
+	}
+	return images, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package imagemeta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+var testImage = Metadata{
+	ID:                 "ami-123",
+	Name:               "ubuntu",
+	Region:             "eu-west-1",
+	Architecture:       ArchARM64,
+	OSFamily:           OSFamilyLinux,
+	VirtualizationType: VirtualizationHVM,
+	Encrypted:          true,
+	EncryptionKey:      "alias/images",
+}
+
+func TestMetadata_CtyValue(t *testing.T) {
+	v := testImage.CtyValue()
+	if !v.Type().Equals(CtyType) {
+		t.Fatalf("unexpected type: %#v", v.Type())
+	}
+	m, err := FromCtyValue(v)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff := cmp.Diff(testImage, m); diff != "" {
+		t.Fatalf("unexpected metadata: %s", diff)
+	}
+}
+
+func TestMetadata_Validate(t *testing.T) {
+	if errs := testImage.Validate(); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	errs := Metadata{Architecture: "sparc", EncryptionKey: "k"}.Validate()
+	if len(errs) != 3 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestFromArtifact(t *testing.T) {
+	images, err := FromArtifact(&packersdk.MockArtifact{})
+	if err != nil || images != nil {
+		t.Fatalf("unexpected result: %#v, %v", images, err)
+	}
+
+	// What a plugin artifact returns once decoded by Packer.
+	remote := []interface{}{map[interface{}]interface{}{
+		"id":                  "ami-123",
+		"name":                "ubuntu",
+		"region":              "eu-west-1",
+		"architecture":        "arm64",
+		"os_family":           "linux",
+		"virtualization_type": "hvm",
+		"encrypted":           true,
+		"encryption_key":      "alias/images",
+	}}
+	images, err = FromArtifact(&packersdk.MockArtifact{StateValues: map[string]interface{}{StateKey: remote}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff := cmp.Diff([]Metadata{testImage}, images); diff != "" {
+		t.Fatalf("unexpected metadata: %s", diff)
+	}
+}
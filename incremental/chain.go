@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package incremental
+
+import (
+	"encoding/json"
+	"fmt"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/mitchellh/mapstructure"
+)
+
+// StateKey is the name under which artifacts return their Chain from State.
+const StateKey = "provenance_chain"
+
+// The keys of the metadata of the artifact store entries holding the
+// provenance of an artifact, see PublishMetadata.
+const (
+	ArtifactIDMetadataKey = "packer_artifact_id"
+	ChainMetadataKey      = "packer_provenance_chain"
+)
+
+// Link is an artifact of a Chain.
+type Link struct {
+	ID        string `json:"id" mapstructure:"id"`
+	BuilderID string `json:"builder_id,omitempty" mapstructure:"builder_id"`
+	// Ref is the reference the artifact was resolved from.
+	Ref string `json:"ref,omitempty" mapstructure:"ref"`
+}
+
+// Chain is the provenance of an artifact: the artifacts it derives from,
+// from the base artifact built from scratch to the source of the build.
+type Chain []Link
+
+// Depth is the number of artifacts the artifact of c derives from.
+func (c Chain) Depth() int {
+	return len(c)
+}
+
+// Contains tells whether an artifact of c has the ID id.
+func (c Chain) Contains(id string) bool {
+	for _, l := range c {
+		if l.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceArtifact is an artifact built from a Source.
+type sourceArtifact struct {
+	packersdk.Artifact
+	chain Chain
+}
+
+var _ packersdk.ArtifactV2 = new(sourceArtifact)
+
+func (a *sourceArtifact) State(name string) interface{} {
+	if name == StateKey {
+		return a.chain
+	}
+	return a.Artifact.State(name)
+}
+
+// Metadata returns the metadata of the artifact, with the ID of the source
+// in its provenance.
+func (a *sourceArtifact) Metadata() *packersdk.ArtifactMetadata {
+	md := *packersdk.ArtifactMetadataOf(a.Artifact)
+	source := a.chain[len(a.chain)-1].ID
+	for _, s := range md.Provenance.Sources {
+		if s == source {
+			return &md
+		}
+	}
+	md.Provenance.Sources = append(append([]string(nil), md.Provenance.Sources...), source)
+	return &md
+}
+
+// WithSource returns a, built from src, returning from State under StateKey
+// the chain of src followed by src, and with the ID of src in the sources
+// of its metadata.
+func WithSource(a packersdk.Artifact, src *Source) packersdk.Artifact {
+	if s, ok := a.(*sourceArtifact); ok {
+		a = s.Artifact
+	}
+	chain := append(append(Chain(nil), src.Chain...), Link{ID: src.ID, BuilderID: src.BuilderID, Ref: src.Ref})
+	return &sourceArtifact{Artifact: a, chain: chain}
+}
+
+// ChainOf returns the provenance of an artifact, nil when it was not built
+// from a Source.
+func ChainOf(a packersdk.Artifact) (Chain, error) {
+	state := a.State(StateKey)
+	switch s := state.(type) {
+	case nil:
+		return nil, nil
+	case Chain:
+		return append(Chain(nil), s...), nil
+	}
+	// Artifacts of plugins are received over RPC as generic lists and maps.
+	var chain Chain
+	if err := mapstructure.Decode(state, &chain); err != nil {
+		return nil, fmt.Errorf("invalid provenance of artifact %s: %s", a.Id(), err)
+	}
+	return chain, nil
+}
+
+// PublishMetadata returns metadata with the ID and the provenance of a, to
+// publish a to an artifact store with, for the builds resolving it to know
+// them.
+func PublishMetadata(a packersdk.Artifact, metadata map[string]string) (map[string]string, error) {
+	chain, err := ChainOf(a)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string, len(metadata)+2)
+	for k, v := range metadata {
+		res[k] = v
+	}
+	res[ArtifactIDMetadataKey] = a.Id()
+	if len(chain) > 0 {
+		b, err := json.Marshal(chain)
+		if err != nil {
+			return nil, err
+		}
+		res[ChainMetadataKey] = string(b)
+	}
+	return res, nil
+}
@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package incremental
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Config holds the source artifact of a builder building from a previous
+// artifact. Embed it in your builder config using the
+// `mapstructure:",squash"` struct tag, resolve the source with a Resolver
+// and check it fits the build with Requirements.
+type Config struct {
+	// The artifact the build starts from, rather than from scratch: the
+	// newest entry of the local artifact store matching the query, like
+	// `artifact://ubuntu-base?version=24.04`, the last artifact of a build
+	// in a manifest file, like `manifest:packer-manifest.json#qemu.base`, or
+	// the ID of the artifact, like an AMI ID.
+	SourceArtifact string `mapstructure:"source_artifact" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	if c.SourceArtifact == "" {
+		return nil
+	}
+	if _, err := ParseRef(c.SourceArtifact); err != nil {
+		return []error{fmt.Errorf("source_artifact: %s", err)}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package incremental
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/artifactstore"
+	"github.com/hashicorp/packer-plugin-sdk/manifest"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestParseRef(t *testing.T) {
+	cases := map[string]*Ref{
+		"artifact://ubuntu-base?version=24.04": {Kind: RefArtifactStore, Name: "ubuntu-base", Match: map[string]string{"version": "24.04"}},
+		"manifest:out/manifest.json#qemu.base": {Kind: RefManifest, Path: "out/manifest.json", Build: "qemu.base"},
+		"manifest:manifest.json":               {Kind: RefManifest, Path: "manifest.json"},
+		"ami-0123456789":                       {Kind: RefID, ID: "ami-0123456789"},
+	}
+	for ref, expected := range cases {
+		actual, err := ParseRef(ref)
+		if err != nil {
+			t.Fatalf("%s: %s", ref, err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("%s: expected %#v, got %#v", ref, expected, actual)
+		}
+	}
+	for _, ref := range []string{"", "artifact://ubuntu-base/disk.qcow2", "manifest:#qemu.base"} {
+		if _, err := ParseRef(ref); err == nil {
+			t.Fatalf("%q: expected an error", ref)
+		}
+	}
+}
+
+func TestResolver_store(t *testing.T) {
+	store := artifactstore.New(t.TempDir())
+	disk := filepath.Join(t.TempDir(), "disk.qcow2")
+	if err := os.WriteFile(disk, []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	base := &packersdk.MockArtifact{IdValue: "base-1", BuilderIdValue: "packer.qemu", FilesValue: []string{disk}}
+	built := WithSource(base, &Source{ID: "ubuntu-cloud-24.04", Ref: "ubuntu-cloud-24.04"})
+	metadata, err := PublishMetadata(built, map[string]string{"version": "24.04", "architecture": "arm64"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := store.PublishArtifact(context.Background(), "ubuntu-base", built, metadata); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r := &Resolver{Store: store}
+	src, err := r.Resolve("artifact://ubuntu-base?version=24.04")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if src.ID != "base-1" || src.BuilderID != "packer.qemu" {
+		t.Fatalf("bad source %#v", src)
+	}
+	if !reflect.DeepEqual(src.Metadata, map[string]string{"version": "24.04", "architecture": "arm64"}) {
+		t.Fatalf("bad metadata %#v", src.Metadata)
+	}
+	if b, err := os.ReadFile(src.Files["disk.qcow2"]); err != nil || string(b) != "disk" {
+		t.Fatalf("bad file %s: %v", b, err)
+	}
+	if !reflect.DeepEqual(src.Chain, Chain{{ID: "ubuntu-cloud-24.04", Ref: "ubuntu-cloud-24.04"}}) {
+		t.Fatalf("bad chain %#v", src.Chain)
+	}
+
+	if _, err := r.Resolve("artifact://ubuntu-base?version=22.04"); !errors.Is(err, artifactstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestResolver_manifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	base := manifest.NewBuild("qemu.base", "qemu")
+	base.AddArtifact(&packersdk.MockArtifact{IdValue: "old", FilesValue: []string{"out/old.qcow2"}})
+	base.AddArtifact(&packersdk.MockArtifact{IdValue: "base-1", BuilderIdValue: "packer.qemu", FilesValue: []string{"out/disk.qcow2"}})
+	other := manifest.NewBuild("qemu.other", "qemu")
+	other.AddArtifact(&packersdk.MockArtifact{IdValue: "other-1"})
+	if err := manifest.WriteFile(path, base, other); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r := new(Resolver)
+	src, err := r.Resolve("manifest:" + path + "#qemu.base")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if src.ID != "base-1" || src.BuilderID != "packer.qemu" || src.Files["disk.qcow2"] != "out/disk.qcow2" {
+		t.Fatalf("bad source %#v", src)
+	}
+	if src, err := r.Resolve("manifest:" + path); err != nil || src.ID != "other-1" {
+		t.Fatalf("the last build should be used: %#v, %v", src, err)
+	}
+	if _, err := r.Resolve("manifest:" + path + "#qemu.missing"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestRequirements_Check(t *testing.T) {
+	src := &Source{
+		Ref:       "artifact://ubuntu-base",
+		ID:        "base-2",
+		BuilderID: "packer.vmware",
+		Metadata:  map[string]string{"architecture": "amd64"},
+		Files:     map[string]string{"disk.vmdk": "/store/disk"},
+		Chain:     Chain{{ID: "base-0"}, {ID: "base-1"}},
+	}
+	r := Requirements{
+		BuilderIDs: []string{"packer.qemu"},
+		Metadata:   map[string]string{"architecture": "arm64", "version": "24.04"},
+		Files:      []string{"disk.qcow2"},
+		MaxDepth:   2,
+	}
+	err := r.Check(src)
+	var incompatible *IncompatibleError
+	if !errors.As(err, &incompatible) {
+		t.Fatalf("expected an *IncompatibleError, got %v", err)
+	}
+	expected := []string{
+		"it was built by packer.vmware, expected packer.qemu",
+		`its architecture is "amd64", expected "arm64"`,
+		`it has no version, expected "24.04"`,
+		"it has no file disk.qcow2",
+		"the artifact built would derive from 3 artifacts, at most 2 are allowed",
+	}
+	if !reflect.DeepEqual(incompatible.Problems, expected) {
+		t.Fatalf("bad problems:\n%s", strings.Join(incompatible.Problems, "\n"))
+	}
+
+	if err := (Requirements{BuilderIDs: []string{"packer.vmware"}, MaxDepth: 3}).Check(src); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	src.Chain = append(src.Chain, Link{ID: "base-2"})
+	if err := (Requirements{}).Check(src); err == nil || !strings.Contains(err.Error(), "derives from itself") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestWithSource(t *testing.T) {
+	a := &packersdk.MockArtifact{
+		IdValue:       "child-1",
+		StateValues:   map[string]interface{}{"region": "eu-west-1"},
+		MetadataValue: &packersdk.ArtifactMetadata{Provenance: packersdk.ArtifactProvenance{Sources: []string{"iso"}}},
+	}
+	src := &Source{ID: "base-1", BuilderID: "packer.qemu", Ref: "artifact://ubuntu-base", Chain: Chain{{ID: "base-0"}}}
+	built := WithSource(a, src)
+
+	chain, err := ChainOf(built)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := Chain{{ID: "base-0"}, {ID: "base-1", BuilderID: "packer.qemu", Ref: "artifact://ubuntu-base"}}
+	if !reflect.DeepEqual(chain, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, chain)
+	}
+	if md := packersdk.ArtifactMetadataOf(built); !reflect.DeepEqual(md.Provenance.Sources, []string{"iso", "base-1"}) {
+		t.Fatalf("bad sources %#v", md.Provenance.Sources)
+	}
+	if len(a.MetadataValue.Provenance.Sources) != 1 {
+		t.Fatal("the metadata of the artifact should not be modified")
+	}
+	if built.State("region") != "eu-west-1" {
+		t.Fatal("the state of the artifact should be kept")
+	}
+
+	// Chains received over RPC are generic lists.
+	a.StateValues[StateKey] = []interface{}{map[string]interface{}{"id": "base-0", "builder_id": "packer.qemu"}}
+	if chain, err := ChainOf(a); err != nil || !reflect.DeepEqual(chain, Chain{{ID: "base-0", BuilderID: "packer.qemu"}}) {
+		t.Fatalf("bad chain %#v: %v", chain, err)
+	}
+}
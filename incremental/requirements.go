@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package incremental
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Requirements are what a build needs of its source, like the builder that
+// built it or its architecture. Zero values mean no requirement.
+type Requirements struct {
+	// BuilderIDs are the builders the source must be built by, like
+	// "transcend.qemu".
+	BuilderIDs []string
+	// Metadata are the values the metadata of the source must have, like
+	// "architecture": "arm64".
+	Metadata map[string]string
+	// Files are the names of the files the source must have, like
+	// "disk.qcow2".
+	Files []string
+	// MaxDepth is the maximum length of the provenance chain of the
+	// artifact built, source included, to bound how many layers an image
+	// piles up.
+	MaxDepth int
+}
+
+// IncompatibleError lists why a source doesn't fit a build.
+type IncompatibleError struct {
+	Ref      string
+	Problems []string
+}
+
+func (e *IncompatibleError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "source artifact %s is not compatible with the build:", e.Ref)
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  - %s", p)
+	}
+	return b.String()
+}
+
+// Check returns an *IncompatibleError when src doesn't meet r.
+func (r Requirements) Check(src *Source) error {
+	var problems []string
+	if len(r.BuilderIDs) > 0 {
+		found := false
+		for _, id := range r.BuilderIDs {
+			if id == src.BuilderID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			builder := src.BuilderID
+			if builder == "" {
+				builder = "an unknown builder"
+			}
+			problems = append(problems, fmt.Sprintf("it was built by %s, expected %s", builder, strings.Join(r.BuilderIDs, " or ")))
+		}
+	}
+
+	keys := make([]string, 0, len(r.Metadata))
+	for k := range r.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := src.Metadata[k]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("it has no %s, expected %q", k, r.Metadata[k]))
+		case v != r.Metadata[k]:
+			problems = append(problems, fmt.Sprintf("its %s is %q, expected %q", k, v, r.Metadata[k]))
+		}
+	}
+
+	for _, name := range r.Files {
+		if _, ok := src.Files[name]; !ok {
+			problems = append(problems, fmt.Sprintf("it has no file %s", name))
+		}
+	}
+
+	if r.MaxDepth > 0 && src.Chain.Depth()+1 > r.MaxDepth {
+		problems = append(problems, fmt.Sprintf("the artifact built would derive from %d artifacts, at most %d are allowed", src.Chain.Depth()+1, r.MaxDepth))
+	}
+	if src.ID != "" && src.Chain.Contains(src.ID) {
+		problems = append(problems, "it derives from itself")
+	}
+
+	if len(problems) > 0 {
+		return &IncompatibleError{Ref: src.Ref, Problems: problems}
+	}
+	return nil
+}
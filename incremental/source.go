@@ -0,0 +1,203 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package incremental helps builders build from a previous artifact, for
+// layered image pipelines: a base image built once, then customized by
+// other builds. Builders resolve the reference to their source artifact with
+// a Resolver, check the source fits the build with Requirements, and attach
+// the provenance of what they built, the chain of artifacts it derives from,
+// to their artifact with WithSource.
+package incremental
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/artifactstore"
+	"github.com/hashicorp/packer-plugin-sdk/manifest"
+)
+
+// The kinds of references of Ref.
+const (
+	// RefArtifactStore references the newest entry of an artifact store
+	// matching the query: artifact://<name>?<key>=<value>, like
+	// artifact://ubuntu-base?version=24.04.
+	RefArtifactStore = "artifact"
+	// RefManifest references the last artifact of a build in a manifest
+	// file: manifest:<path>#<build name>, like
+	// manifest:packer-manifest.json#qemu.base. The last build of the
+	// manifest is used without a build name.
+	RefManifest = "manifest"
+	// RefID references an artifact by its ID, like an AMI ID, for the
+	// builders that can look it up.
+	RefID = "id"
+)
+
+// Ref is a parsed reference to an artifact.
+type Ref struct {
+	Kind string
+	// Name and Match are the name and the metadata of the entries of
+	// RefArtifactStore references.
+	Name  string
+	Match map[string]string
+	// Path and Build are the file and the build of RefManifest references.
+	Path  string
+	Build string
+	// ID is the ID of RefID references.
+	ID string
+}
+
+// ParseRef parses a reference to an artifact. References that are neither
+// artifact:// URLs nor manifest: references are IDs.
+func ParseRef(ref string) (*Ref, error) {
+	switch {
+	case ref == "":
+		return nil, fmt.Errorf("empty artifact reference")
+	case strings.HasPrefix(ref, artifactstore.Scheme+"://"):
+		u, err := url.Parse(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact reference %q: %s", ref, err)
+		}
+		if u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			return nil, fmt.Errorf("invalid artifact reference %q, expected %s://<name>?<key>=<value>", ref, artifactstore.Scheme)
+		}
+		match := map[string]string{}
+		for k, v := range u.Query() {
+			match[k] = v[0]
+		}
+		return &Ref{Kind: RefArtifactStore, Name: u.Host, Match: match}, nil
+	case strings.HasPrefix(ref, RefManifest+":"):
+		path, build, _ := strings.Cut(strings.TrimPrefix(ref, RefManifest+":"), "#")
+		if path == "" {
+			return nil, fmt.Errorf("invalid artifact reference %q, expected %s:<path>#<build name>", ref, RefManifest)
+		}
+		return &Ref{Kind: RefManifest, Path: path, Build: build}, nil
+	}
+	return &Ref{Kind: RefID, ID: ref}, nil
+}
+
+// Source is the artifact a build starts from.
+type Source struct {
+	// Ref is the reference the source was resolved from.
+	Ref string
+	// ID is the ID of the artifact, like an AMI ID.
+	ID string
+	// BuilderID is the ID of the builder of the artifact, when known.
+	BuilderID string
+	// Files are the local paths of the files of the artifact, by name,
+	// like "disk.qcow2".
+	Files map[string]string
+	// Metadata describes the artifact, like its version or architecture,
+	// when known.
+	Metadata map[string]string
+	// Chain is the provenance of the source itself, empty for artifacts
+	// built from scratch or whose provenance is unknown.
+	Chain Chain
+}
+
+// Resolver resolves the references to artifacts.
+type Resolver struct {
+	// Store is the store of the artifact:// references, the default store
+	// of the host when nil.
+	Store *artifactstore.Store
+}
+
+// Resolve returns the source ref references. Only the ID of RefID
+// references is known; the builder looks the artifact up.
+func (r *Resolver) Resolve(ref string) (*Source, error) {
+	parsed, err := ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Kind {
+	case RefArtifactStore:
+		return r.resolveStore(ref, parsed)
+	case RefManifest:
+		return resolveManifest(ref, parsed)
+	}
+	return &Source{Ref: ref, ID: parsed.ID}, nil
+}
+
+func (r *Resolver) resolveStore(ref string, parsed *Ref) (*Source, error) {
+	store := r.Store
+	if store == nil {
+		var err error
+		if store, err = artifactstore.Default(); err != nil {
+			return nil, err
+		}
+	}
+	e, err := store.Lookup(parsed.Name, parsed.Match)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", ref, err)
+	}
+
+	src := &Source{
+		Ref:       ref,
+		ID:        e.ID,
+		BuilderID: e.BuilderID,
+		Files:     make(map[string]string, len(e.Files)),
+		Metadata:  map[string]string{},
+	}
+	for k, v := range e.Metadata {
+		switch k {
+		case ArtifactIDMetadataKey:
+			src.ID = v
+		case ChainMetadataKey:
+			if err := json.Unmarshal([]byte(v), &src.Chain); err != nil {
+				return nil, fmt.Errorf("invalid provenance of %s: %s", ref, err)
+			}
+		default:
+			src.Metadata[k] = v
+		}
+	}
+	for _, f := range e.Files {
+		path, err := store.FilePath(e, f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %s: %w", ref, err)
+		}
+		src.Files[f.Name] = path
+	}
+	return src, nil
+}
+
+func resolveManifest(ref string, parsed *Ref) (*Source, error) {
+	b, err := os.ReadFile(parsed.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %s", ref, err)
+	}
+	var m manifest.Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("error resolving %s: invalid manifest: %s", ref, err)
+	}
+	for i := len(m.Builds) - 1; i >= 0; i-- {
+		build := m.Builds[i]
+		if parsed.Build != "" && build.Name != parsed.Build {
+			continue
+		}
+		if len(build.Artifacts) == 0 {
+			continue
+		}
+		a := build.Artifacts[len(build.Artifacts)-1]
+		src := &Source{Ref: ref, ID: a.Id, BuilderID: a.BuilderId, Files: make(map[string]string, len(a.Files))}
+		for _, f := range a.Files {
+			src.Files[baseName(f)] = f
+		}
+		return src, nil
+	}
+	if parsed.Build != "" {
+		return nil, fmt.Errorf("error resolving %s: no artifact of build %s in %s", ref, parsed.Build, parsed.Path)
+	}
+	return nil, fmt.Errorf("error resolving %s: no artifact in %s", ref, parsed.Path)
+}
+
+// baseName is filepath.Base for the paths of both Unix and Windows hosts, as
+// manifests are shared.
+func baseName(path string) string {
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
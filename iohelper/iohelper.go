@@ -0,0 +1,170 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package iohelper holds the io helpers shared by the communicators and the
+// steps moving files around: copies that stop when their context is done,
+// rate limited readers, readers reporting their progress to a Ui and
+// writers teeing what they are written to the logs.
+package iohelper
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// copyBufferSize is the size of the chunks CancellableCopy copies.
+const copyBufferSize = 32 * 1024
+
+// CancellableCopy copies src to dst like io.Copy, until ctx is done. It
+// returns the number of bytes copied, and ctx.Err() when it was cancelled.
+// A read or write in progress is not interrupted: cancelling a copy from a
+// stalled connection also takes closing it.
+func CancellableCopy(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	return io.CopyBuffer(dst, NewContextReader(ctx, src), make([]byte, copyBufferSize))
+}
+
+// NewContextReader returns a reader reading from r until ctx is done, then
+// failing with ctx.Err().
+func NewContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// NewRateLimitedReader returns a reader reading from r no faster than
+// bytesPerSecond on average, in bursts of a second at most, for example to
+// keep an upload from saturating the link of a build host. It waits until ctx
+// is done at most. r is read as is when bytesPerSecond isn't positive.
+func NewRateLimitedReader(ctx context.Context, r io.Reader, bytesPerSecond int64) io.Reader {
+	if bytesPerSecond <= 0 {
+		return NewContextReader(ctx, r)
+	}
+	return &rateLimitedReader{ctx: ctx, r: r, rate: bytesPerSecond}
+}
+
+type rateLimitedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	if int64(len(p)) > r.rate {
+		p = p[:r.rate]
+	}
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	// Wait until the bytes read so far fit in the rate.
+	due := r.start.Add(time.Duration(float64(r.read) / float64(r.rate) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-r.ctx.Done():
+			return n, r.ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return n, err
+}
+
+// NewProgressReader returns a reader reading from r and reporting its
+// progress to ui, with packersdk.NewProgressTracker, so that Uis only
+// implementing TrackProgress show it too. label describes the operation, like
+// "Uploading ubuntu.iso", and total is the number of bytes to read, 0 when it
+// isn't known. Closing the reader finishes the progress, and closes r when
+// it is an io.Closer.
+func NewProgressReader(ui packersdk.Ui, label string, total int64, r io.Reader) io.ReadCloser {
+	t := packersdk.NewProgressTracker(ui)
+	t.Start(label, total)
+	return &progressReadCloser{Reader: packersdk.ProgressReader(t, r), t: t, r: r}
+}
+
+type progressReadCloser struct {
+	io.Reader
+	t    packersdk.ProgressTracker
+	r    io.Reader
+	once sync.Once
+}
+
+func (r *progressReadCloser) Close() error {
+	r.once.Do(r.t.Finish)
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// TeeToLog returns a writer writing to w, and logging each line it is
+// written with the log package, prefixed with prefix, like "[DEBUG] stdout:
+// ". w can be nil to only log. Closing the writer logs the last line when it
+// doesn't end with a newline; it doesn't close w.
+func TeeToLog(w io.Writer, prefix string) io.WriteCloser {
+	return &logWriter{w: w, prefix: prefix}
+}
+
+type logWriter struct {
+	w      io.Writer
+	prefix string
+
+	l   sync.Mutex
+	buf []byte
+}
+
+func (w *logWriter) Write(p []byte) (n int, err error) {
+	n = len(p)
+	if w.w != nil {
+		n, err = w.w.Write(p)
+	}
+	w.log(p[:n])
+	return n, err
+}
+
+// log logs the complete lines of p, keeping the last one until it ends.
+func (w *logWriter) log(p []byte) {
+	w.l.Lock()
+	defer w.l.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		log.Printf("%s%s", w.prefix, bytes.TrimSuffix(w.buf[:i], []byte("\r")))
+		w.buf = w.buf[i+1:]
+	}
+}
+
+func (w *logWriter) Close() error {
+	w.l.Lock()
+	defer w.l.Unlock()
+	if len(w.buf) > 0 {
+		log.Printf("%s%s", w.prefix, w.buf)
+		w.buf = nil
+	}
+	return nil
+}
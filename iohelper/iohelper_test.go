@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iohelper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// cancellingReader cancels a context once read, and never ends.
+type cancellingReader struct {
+	cancel context.CancelFunc
+}
+
+func (r cancellingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return len(p), nil
+}
+
+func TestCancellableCopy(t *testing.T) {
+	var dst bytes.Buffer
+	n, err := CancellableCopy(context.Background(), &dst, strings.NewReader("content"))
+	if err != nil || n != 7 || dst.String() != "content" {
+		t.Fatalf("bad copy of %d bytes %q: %v", n, dst.String(), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n, err = CancellableCopy(ctx, io.Discard, cancellingReader{cancel})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("bad error: %v", err)
+	}
+	if n == 0 || n > copyBufferSize {
+		t.Fatalf("the copy should stop after the read in progress, copied %d bytes", n)
+	}
+}
+
+func TestNewRateLimitedReader(t *testing.T) {
+	start := time.Now()
+	r := NewRateLimitedReader(context.Background(), strings.NewReader(strings.Repeat("x", 300)), 1000)
+	b, err := io.ReadAll(r)
+	if err != nil || len(b) != 300 {
+		t.Fatalf("bad read of %d bytes: %v", len(b), err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Fatalf("300 bytes at 1000 bytes/s should take 300ms, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r = NewRateLimitedReader(ctx, strings.NewReader(strings.Repeat("x", 300)), 10)
+	if _, err := io.ReadAll(r); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+// trackingUi records the progress of the streams passed to TrackProgress.
+type trackingUi struct {
+	packersdk.Ui
+	label  string
+	total  int64
+	read   int
+	closed bool
+}
+
+func (u *trackingUi) TrackProgress(src string, _, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	u.label, u.total = src, totalSize
+	return &trackingStream{ui: u, ReadCloser: stream}
+}
+
+type trackingStream struct {
+	io.ReadCloser
+	ui *trackingUi
+}
+
+func (s *trackingStream) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	s.ui.read += n
+	return n, err
+}
+
+func (s *trackingStream) Close() error {
+	s.ui.closed = true
+	return s.ReadCloser.Close()
+}
+
+func TestNewProgressReader(t *testing.T) {
+	ui := &trackingUi{Ui: packersdk.TestUi(t)}
+	r := NewProgressReader(ui, "Uploading ubuntu.iso", 5, strings.NewReader("hello"))
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r.Close()
+	if ui.label != "Uploading ubuntu.iso" || ui.total != 5 || ui.read != 5 || !ui.closed {
+		t.Fatalf("bad progress: %#v", ui)
+	}
+}
+
+func TestTeeToLog(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	defer log.SetFlags(log.Flags())
+	log.SetOutput(&logs)
+	log.SetFlags(0)
+
+	var out bytes.Buffer
+	w := TeeToLog(&out, "[DEBUG] stderr: ")
+	io.WriteString(w, "first line\r\nsecond")
+	io.WriteString(w, " line\nlast")
+	if want := "[DEBUG] stderr: first line\n[DEBUG] stderr: second line\n"; logs.String() != want {
+		t.Fatalf("bad logs: %q", logs.String())
+	}
+	w.Close()
+	if want := "[DEBUG] stderr: first line\n[DEBUG] stderr: second line\n[DEBUG] stderr: last\n"; logs.String() != want {
+		t.Fatalf("bad logs: %q", logs.String())
+	}
+	if out.String() != "first line\r\nsecond line\nlast" {
+		t.Fatalf("bad output: %q", out.String())
+	}
+}
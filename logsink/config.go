@@ -0,0 +1,151 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type SinkConfig
+
+package logsink
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Config holds the log_sink blocks, streaming the output and the logs of
+// the build while it runs:
+//
+//	log_sink {
+//	  type     = "file"
+//	  path     = "/var/log/packer/build.log"
+//	  max_size = 10485760
+//	}
+//	log_sink {
+//	  type    = "http"
+//	  url     = "https://logs.example.com/ingest"
+//	  headers = { Authorization = "Bearer ${var.logs_token}" }
+//	  level   = "INFO"
+//	}
+//
+// Embed it in your builder config using the `mapstructure:",squash"` struct
+// tag.
+type Config struct {
+	// The sinks the output and the logs of the build are streamed to.
+	Sinks []SinkConfig `mapstructure:"log_sink" required:"false"`
+}
+
+// SinkConfig configures a log sink.
+type SinkConfig struct {
+	// The kind of sink: `file`, to append to a file, `syslog`, to send to a
+	// syslog daemon, or `http`, to POST newline delimited JSON to an HTTP
+	// endpoint.
+	Type string `mapstructure:"type" required:"true"`
+	// The lowest level of the entries sent: `TRACE`, `DEBUG`, `INFO`,
+	// `WARN` or `ERROR`. Defaults to sending them all.
+	Level string `mapstructure:"level" required:"false"`
+	// The path of the file of `file` sinks. The `~` can be used in path and
+	// will be expanded to the home directory of current user.
+	Path string `mapstructure:"path" required:"false"`
+	// The size in bytes past which the file is rotated. Defaults to `0`,
+	// the file is not rotated.
+	MaxSize int64 `mapstructure:"max_size" required:"false"`
+	// The number of rotated files kept. Defaults to `3`.
+	MaxBackups int `mapstructure:"max_backups" required:"false"`
+	// The network of the syslog daemon of `syslog` sinks, `udp` or `tcp`.
+	// Defaults to the local syslog daemon.
+	Network string `mapstructure:"network" required:"false"`
+	// The `host:port` address of the syslog daemon, required with
+	// `network`.
+	Address string `mapstructure:"address" required:"false"`
+	// The tag of the syslog messages. Defaults to `packer`.
+	Tag string `mapstructure:"tag" required:"false"`
+	// The URL the entries of `http` sinks are POSTed to.
+	URL string `mapstructure:"url" required:"false"`
+	// Headers set in the requests, for authentication for example.
+	Headers map[string]string `mapstructure:"headers" required:"false"`
+	// How often the entries are POSTed. Defaults to `2s`.
+	FlushInterval time.Duration `mapstructure:"flush_interval" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	for i := range c.Sinks {
+		s := &c.Sinks[i]
+		if s.Level != "" && levelIndex(s.Level) < 0 {
+			errs = append(errs, fmt.Errorf("log_sink[%d].level: unknown level %q, expected one of %v", i, s.Level, Levels))
+		}
+		switch s.Type {
+		case "file":
+			if s.Path == "" {
+				errs = append(errs, fmt.Errorf("log_sink[%d].path must be set for file sinks", i))
+			}
+			if s.MaxSize < 0 {
+				errs = append(errs, fmt.Errorf("log_sink[%d].max_size must be positive", i))
+			}
+			if s.MaxBackups == 0 {
+				s.MaxBackups = 3
+			}
+		case "syslog":
+			if (s.Network == "") != (s.Address == "") {
+				errs = append(errs, fmt.Errorf("log_sink[%d]: network and address must be set together", i))
+			}
+			if s.Tag == "" {
+				s.Tag = "packer"
+			}
+		case "http":
+			if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				errs = append(errs, fmt.Errorf("log_sink[%d].url must be an http or https URL, got %q", i, s.URL))
+			}
+			if s.FlushInterval < 0 {
+				errs = append(errs, fmt.Errorf("log_sink[%d].flush_interval must be positive", i))
+			}
+			for _, v := range s.Headers {
+				packersdk.LogSecretFilter.Set(v)
+			}
+		default:
+			errs = append(errs, fmt.Errorf("log_sink[%d].type: unknown type %q, expected one of file, syslog or http", i, s.Type))
+		}
+	}
+	return errs
+}
+
+// Open opens the sinks and returns the streamer sending entries to them, nil
+// when there are none. Call it once the config is prepared.
+func (c *Config) Open(buildName string) (*Streamer, error) {
+	if len(c.Sinks) == 0 {
+		return nil, nil
+	}
+	var sinks []Sink
+	for i, sc := range c.Sinks {
+		sink, err := sc.open()
+		if err != nil {
+			for _, sink := range sinks {
+				sink.Close()
+			}
+			return nil, fmt.Errorf("log_sink[%d]: %w", i, err)
+		}
+		sinks = append(sinks, WithMinLevel(sink, sc.Level))
+	}
+	return NewStreamer(buildName, sinks...), nil
+}
+
+func (sc *SinkConfig) open() (Sink, error) {
+	switch sc.Type {
+	case "file":
+		path, err := pathing.ExpandUser(sc.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileSink(path, sc.MaxSize, sc.MaxBackups)
+	case "syslog":
+		return NewSyslogSink(sc.Network, sc.Address, sc.Tag)
+	case "http":
+		return NewHTTPSink(sc.URL, sc.Headers, sc.FlushInterval), nil
+	}
+	return nil, errors.New("unknown sink type " + sc.Type)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package logsink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileSink appends entries, one line of text each, to a file, rotated when
+// it grows past MaxSize: the file is renamed with a `.1` suffix, the
+// previous `.1` file to `.2`, and so on, keeping MaxBackups of them.
+type FileSink struct {
+	Path string
+	// MaxSize is the size in bytes past which the file is rotated, 0 never
+	// to rotate it.
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups int
+
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens, or creates, the file at path to append entries to.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f, s.size = f, fi.Size()
+	return nil
+}
+
+func (s *FileSink) Send(e Entry) error {
+	line := []byte(e.String() + "\n")
+	if s.MaxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.MaxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotating %s: %w", s.Path, err)
+		}
+	}
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// rotate shifts the backups, moves the file to the first one, and starts
+// a new file.
+func (s *FileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if s.MaxBackups <= 0 {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return s.open()
+	}
+	os.Remove(s.backup(s.MaxBackups))
+	for i := s.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(s.backup(i), s.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(s.Path, s.backup(1)); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) backup(i int) string {
+	return fmt.Sprintf("%s.%d", s.Path, i)
+}
+
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
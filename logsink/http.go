@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFlushInterval is how often an HTTPSink POSTs the entries it
+	// received by default.
+	DefaultFlushInterval = 2 * time.Second
+	// httpBatchSize is the number of entries past which an HTTPSink POSTs
+	// them without waiting for the next flush.
+	httpBatchSize = 500
+)
+
+// HTTPSink POSTs entries to an HTTP endpoint in batches, as newline
+// delimited JSON, every FlushInterval or as soon as a batch is full.
+type HTTPSink struct {
+	URL string
+	// Headers are set in the requests, for authentication for example.
+	Headers map[string]string
+	// Client sends the requests, http.DefaultClient when nil.
+	Client *http.Client
+
+	l     sync.Mutex
+	buf   bytes.Buffer
+	n     int
+	err   error
+	stop  chan struct{}
+	flush sync.WaitGroup
+}
+
+// NewHTTPSink returns a sink POSTing entries to url every flushInterval,
+// DefaultFlushInterval when 0.
+func NewHTTPSink(url string, headers map[string]string, flushInterval time.Duration) *HTTPSink {
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	s := &HTTPSink{URL: url, Headers: headers, stop: make(chan struct{})}
+	s.flush.Add(1)
+	go s.flushEvery(flushInterval)
+	return s
+}
+
+func (s *HTTPSink) flushEvery(interval time.Duration) {
+	defer s.flush.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.l.Lock()
+			if err := s.post(); err != nil && s.err == nil {
+				s.err = err
+			}
+			s.l.Unlock()
+		}
+	}
+}
+
+// Send buffers e, POSTing the batch when it is full. It returns the error
+// of a previous POST, if any.
+func (s *HTTPSink) Send(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.buf.Write(b)
+	s.buf.WriteByte('\n')
+	s.n++
+	if s.n >= httpBatchSize {
+		if err := s.post(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+	err, s.err = s.err, nil
+	return err
+}
+
+// post sends the buffered entries, which are dropped even when it fails, as
+// sending them again would delay the next ones.
+func (s *HTTPSink) post() error {
+	if s.n == 0 {
+		return nil
+	}
+	defer func() {
+		s.buf.Reset()
+		s.n = 0
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(s.buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// Close stops the periodic flushes and POSTs the last entries.
+func (s *HTTPSink) Close() error {
+	close(s.stop)
+	s.flush.Wait()
+	s.l.Lock()
+	defer s.l.Unlock()
+	err := s.post()
+	if s.err != nil {
+		err = s.err
+	}
+	return err
+}
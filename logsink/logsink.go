@@ -0,0 +1,341 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package logsink streams the Ui messages and the logs of a build to
+// external sinks while it runs: a rotated file, syslog, or an HTTP endpoint
+// receiving newline delimited JSON, so that long builds on remote CI agents
+// can be watched live from elsewhere.
+//
+// Plugins embed Config in their configuration, and wrap their Ui and logs
+// with the Streamer it opens:
+//
+//	streamer, err := b.config.LogSinkConfig.Open(b.config.PackerBuildName)
+//	if err != nil {
+//		return nil, err
+//	}
+//	defer streamer.Close()
+//	defer streamer.TeeLog()()
+//	ui = streamer.Ui(ui)
+package logsink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Source tells where an Entry comes from.
+type Source string
+
+const (
+	SourceUi  Source = "ui"
+	SourceLog Source = "log"
+)
+
+// Levels of the entries, from the lowest to the highest. The levels of Ui
+// entries are the ones of the Ui methods called: say and message are INFO,
+// errors are ERROR and machine readable output is DEBUG.
+const (
+	LevelTrace = "TRACE"
+	LevelDebug = "DEBUG"
+	LevelInfo  = "INFO"
+	LevelWarn  = "WARN"
+	LevelError = "ERROR"
+)
+
+// Levels are the levels of the entries, from the lowest to the highest.
+var Levels = []string{LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError}
+
+func levelIndex(level string) int {
+	for i, l := range Levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// Entry is a Ui message or a log line sent to the sinks.
+type Entry struct {
+	Time      time.Time `json:"timestamp"`
+	Source    Source    `json:"source"`
+	Level     string    `json:"level"`
+	BuildName string    `json:"build_name,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// String formats e as a line of text, without the trailing new line.
+func (e Entry) String() string {
+	var b strings.Builder
+	b.WriteString(e.Time.UTC().Format(time.RFC3339Nano))
+	b.WriteString(" [" + e.Level + "] ")
+	if e.BuildName != "" {
+		b.WriteString(e.BuildName + ": ")
+	}
+	if e.Source == SourceUi {
+		b.WriteString("ui: ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// A Sink receives entries. Streamer calls Send from a single goroutine, so
+// sinks don't need to be safe for concurrent use, and can block.
+type Sink interface {
+	Send(Entry) error
+	// Close flushes the entries not sent yet and releases the sink.
+	Close() error
+}
+
+// WithMinLevel returns a Sink only sending the entries of sink whose level
+// is level or higher.
+func WithMinLevel(sink Sink, level string) Sink {
+	if levelIndex(level) <= 0 {
+		return sink
+	}
+	return &minLevelSink{Sink: sink, min: levelIndex(level)}
+}
+
+type minLevelSink struct {
+	Sink
+	min int
+}
+
+func (s *minLevelSink) Send(e Entry) error {
+	if levelIndex(e.Level) < s.min {
+		return nil
+	}
+	return s.Sink.Send(e)
+}
+
+// queueSize is the number of entries waiting to be sent to a sink, past
+// which entries are dropped rather than slowing the build down.
+const queueSize = 4096
+
+// Streamer sends entries to sinks, each from its own goroutine, never
+// blocking the build: entries are dropped when a sink can't keep up.
+// Secrets registered with packer.LogSecretFilter are filtered out of the
+// entries. A nil *Streamer streams nothing. It is safe to be used from
+// multiple goroutines.
+type Streamer struct {
+	// BuildName is set in the entries that don't have one.
+	BuildName string
+
+	l      sync.Mutex
+	closed bool
+	queues []*sinkQueue
+}
+
+type sinkQueue struct {
+	sink    Sink
+	entries chan Entry
+	done    chan struct{}
+
+	// dropped is written by Send, under the lock of the Streamer, and
+	// failed and err by run, before done is closed.
+	dropped int
+	failed  int
+	err     error
+}
+
+// NewStreamer returns a streamer sending entries to sinks.
+func NewStreamer(buildName string, sinks ...Sink) *Streamer {
+	s := &Streamer{BuildName: buildName}
+	for _, sink := range sinks {
+		q := &sinkQueue{sink: sink, entries: make(chan Entry, queueSize), done: make(chan struct{})}
+		s.queues = append(s.queues, q)
+		go q.run()
+	}
+	return s
+}
+
+func (q *sinkQueue) run() {
+	defer close(q.done)
+	for e := range q.entries {
+		if err := q.sink.Send(e); err != nil {
+			// Errors are returned by Close rather than logged here, as the
+			// logs could be streamed to this very sink, and fail again.
+			q.failed++
+			if q.err == nil {
+				q.err = err
+			}
+		}
+	}
+}
+
+// Send queues e for the sinks, timestamped now if it is not.
+func (s *Streamer) Send(e Entry) {
+	if s == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.BuildName == "" {
+		e.BuildName = s.BuildName
+	}
+	e.Message = packersdk.LogSecretFilter.FilterString(e.Message)
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.closed {
+		return
+	}
+	for _, q := range s.queues {
+		select {
+		case q.entries <- e:
+		default:
+			q.dropped++
+		}
+	}
+}
+
+// Close sends the queued entries and closes the sinks. It returns the
+// first error of every sink that failed, and tells how many entries were
+// dropped or failed to be sent.
+func (s *Streamer) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.l.Lock()
+	if s.closed {
+		s.l.Unlock()
+		return nil
+	}
+	s.closed = true
+	for _, q := range s.queues {
+		close(q.entries)
+	}
+	s.l.Unlock()
+
+	var errs []error
+	for i, q := range s.queues {
+		<-q.done
+		if q.err != nil {
+			errs = append(errs, fmt.Errorf("log sink %d: %d entries failed to be sent: %w", i, q.failed, q.err))
+		}
+		if err := q.sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("log sink %d: %w", i, err))
+		}
+		if q.dropped > 0 {
+			log.Printf("[WARN] log sink %d: %d entries dropped, the sink was too slow", i, q.dropped)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ui returns ui, also sending what it displays to the sinks. Questions and
+// their answers are not sent.
+func (s *Streamer) Ui(ui packersdk.Ui) packersdk.Ui {
+	if s == nil {
+		return ui
+	}
+	return &streamUi{Ui: ui, s: s}
+}
+
+type streamUi struct {
+	packersdk.Ui
+	s *Streamer
+}
+
+func (u *streamUi) send(level, message string) {
+	u.s.Send(Entry{Source: SourceUi, Level: level, Message: message})
+}
+
+func (u *streamUi) Sayf(message string, args ...any) {
+	u.Say(i18n.Sprintf(message, args...))
+}
+
+func (u *streamUi) Say(message string) {
+	u.send(LevelInfo, message)
+	u.Ui.Say(message)
+}
+
+func (u *streamUi) Message(message string) {
+	u.send(LevelInfo, message)
+	u.Ui.Message(message)
+}
+
+func (u *streamUi) Errorf(message string, args ...any) {
+	u.Error(i18n.Sprintf(message, args...))
+}
+
+func (u *streamUi) Error(message string) {
+	u.send(LevelError, message)
+	u.Ui.Error(message)
+}
+
+func (u *streamUi) Machine(t string, args ...string) {
+	u.send(LevelDebug, strings.Join(append([]string{t}, args...), ","))
+	u.Ui.Machine(t, args...)
+}
+
+// logLevelRe matches the level prefixes of log lines, like `[DEBUG]`.
+var logLevelRe = regexp.MustCompile(`\[(TRACE|DEBUG|INFO|WARN|WARNING|ERR|ERROR)\]\s*`)
+
+// LogWriter returns a writer sending each line written to it to the sinks,
+// with the level of its `[LEVEL]` prefix, INFO by default.
+func (s *Streamer) LogWriter() io.Writer {
+	return &logWriter{s: s}
+}
+
+type logWriter struct {
+	s   *Streamer
+	l   sync.Mutex
+	buf []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.l.Lock()
+	defer w.l.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.send(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *logWriter) send(line string) {
+	line = strings.TrimRight(line, "\r")
+	if line == "" {
+		return
+	}
+	level := LevelInfo
+	if m := logLevelRe.FindStringSubmatchIndex(line); m != nil {
+		switch prefix := line[m[2]:m[3]]; prefix {
+		case "WARNING":
+			level = LevelWarn
+		case "ERR":
+			level = LevelError
+		default:
+			level = prefix
+		}
+		line = line[:m[0]] + line[m[1]:]
+	}
+	w.s.Send(Entry{Source: SourceLog, Level: level, Message: line})
+}
+
+// TeeLog also sends what the standard logger writes to the sinks, until
+// the function it returns is called.
+func (s *Streamer) TeeLog() (restore func()) {
+	if s == nil {
+		return func() {}
+	}
+	out := log.Writer()
+	log.SetOutput(io.MultiWriter(out, s.LogWriter()))
+	return func() { log.SetOutput(out) }
+}
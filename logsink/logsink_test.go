@@ -0,0 +1,250 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package logsink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type testSink struct {
+	entries []Entry
+	err     error
+	closed  bool
+}
+
+func (s *testSink) Send(e Entry) error {
+	s.entries = append(s.entries, e)
+	return s.err
+}
+
+func (s *testSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestStreamer_Ui(t *testing.T) {
+	sink := new(testSink)
+	s := NewStreamer("qemu.ubuntu", sink)
+	packersdk.LogSecretFilter.Set("hunter2")
+
+	ui := s.Ui(packersdk.TestUi(t))
+	ui.Say("Starting VM...")
+	ui.Errorf("bad password %s", "hunter2")
+	if err := s.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !sink.closed {
+		t.Fatal("the sink should be closed")
+	}
+
+	if len(sink.entries) != 2 {
+		t.Fatalf("bad entries: %#v", sink.entries)
+	}
+	say, errEntry := sink.entries[0], sink.entries[1]
+	if say.Source != SourceUi || say.Level != LevelInfo || say.Message != "Starting VM..." || say.BuildName != "qemu.ubuntu" || say.Time.IsZero() {
+		t.Fatalf("bad say entry: %#v", say)
+	}
+	if errEntry.Level != LevelError || errEntry.Message != "bad password <sensitive>" {
+		t.Fatalf("bad error entry: %#v", errEntry)
+	}
+
+	// Entries sent once closed are ignored.
+	ui.Say("late")
+	if len(sink.entries) != 2 {
+		t.Fatalf("bad entries: %#v", sink.entries)
+	}
+}
+
+func TestStreamer_TeeLog(t *testing.T) {
+	sink := new(testSink)
+	s := NewStreamer("", WithMinLevel(sink, LevelInfo))
+	out := log.Writer()
+	restore := s.TeeLog()
+	log.Printf("[DEBUG] not streamed")
+	log.Printf("[WARN] disk almost full")
+	log.Print("no level")
+	restore()
+	log.Printf("[ERROR] after restore")
+	s.Close()
+
+	if log.Writer() != out {
+		t.Fatal("the log output should be restored")
+	}
+	if len(sink.entries) != 2 {
+		t.Fatalf("bad entries: %#v", sink.entries)
+	}
+	warn, info := sink.entries[0], sink.entries[1]
+	if warn.Source != SourceLog || warn.Level != LevelWarn || !strings.HasSuffix(warn.Message, " disk almost full") {
+		t.Fatalf("bad warn entry: %#v", warn)
+	}
+	if strings.Contains(warn.Message, "[WARN]") {
+		t.Fatalf("the level should be removed from the message: %q", warn.Message)
+	}
+	if info.Level != LevelInfo || !strings.HasSuffix(info.Message, " no level") {
+		t.Fatalf("bad info entry: %#v", info)
+	}
+}
+
+func TestStreamer_errors(t *testing.T) {
+	sink := &testSink{err: errors.New("unreachable")}
+	s := NewStreamer("", sink)
+	s.Send(Entry{Level: LevelInfo, Message: "one"})
+	s.Send(Entry{Level: LevelInfo, Message: "two"})
+	err := s.Close()
+	if err == nil || !strings.Contains(err.Error(), "2 entries failed to be sent: unreachable") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var nilStreamer *Streamer
+	nilStreamer.Send(Entry{})
+	nilStreamer.TeeLog()()
+	if err := nilStreamer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSink_rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "build.log")
+	sink, err := NewFileSink(path, 110, 2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 8; i++ {
+		if err := sink.Send(Entry{Time: at, Level: LevelInfo, Source: SourceUi, Message: strings.Repeat("x", 20)}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := "2024-01-02T03:04:05Z [INFO] ui: " + strings.Repeat("x", 20) + "\n"
+	if string(b) != strings.Repeat(want, 2) {
+		t.Fatalf("bad content: %q", b)
+	}
+	for _, backup := range []string{path + ".1", path + ".2"} {
+		if _, err := os.Stat(backup); err != nil {
+			t.Fatalf("missing backup: %s", err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("only 2 backups should be kept: %v", err)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var l sync.Mutex
+	var got []Entry
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Lock()
+		defer l.Unlock()
+		headers = r.Header
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var e Entry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				t.Errorf("bad line %q: %s", scanner.Text(), err)
+			}
+			got = append(got, e)
+		}
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(srv.URL, map[string]string{"Authorization": "Bearer token"}, time.Hour)
+	for _, msg := range []string{"one", "two"} {
+		if err := sink.Send(Entry{Level: LevelInfo, Message: msg}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if len(got) != 2 || got[0].Message != "one" || got[1].Message != "two" {
+		t.Fatalf("bad entries: %#v", got)
+	}
+	if headers.Get("Content-Type") != "application/x-ndjson" || headers.Get("Authorization") != "Bearer token" {
+		t.Fatalf("bad headers: %v", headers)
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	sink = NewHTTPSink(missing.URL, nil, time.Hour)
+	sink.Send(Entry{Level: LevelInfo, Message: "lost"})
+	if err := sink.Close(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected a 404 error, got %v", err)
+	}
+}
+
+func TestConfig_Prepare(t *testing.T) {
+	c := &Config{Sinks: []SinkConfig{
+		{Type: "file", Path: filepath.Join(t.TempDir(), "build.log")},
+		{Type: "http", URL: "https://logs.example.com", Level: LevelWarn},
+	}}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	if c.Sinks[0].MaxBackups != 3 {
+		t.Fatalf("bad defaults: %#v", c.Sinks[0])
+	}
+
+	c = &Config{Sinks: []SinkConfig{
+		{Type: "file"},
+		{Type: "syslog", Network: "udp"},
+		{Type: "http", URL: "ftp://logs.example.com"},
+		{Type: "kafka"},
+		{Type: "file", Path: "build.log", Level: "LOUD"},
+	}}
+	if errs := c.Prepare(nil); len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got %#v", errs)
+	}
+}
+
+func TestConfig_Open(t *testing.T) {
+	var c Config
+	if s, err := c.Open("build"); s != nil || err != nil {
+		t.Fatalf("no sinks should give a nil streamer, got %v, %v", s, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "build.log")
+	c = Config{Sinks: []SinkConfig{{Type: "file", Path: path, Level: LevelError}}}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	s, err := c.Open("build")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ui := s.Ui(packersdk.TestUi(t))
+	ui.Say("ignored")
+	ui.Error("failed")
+	if err := s.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(b), " [ERROR] build: ui: failed\n") || strings.Count(string(b), "\n") != 1 {
+		t.Fatalf("bad content: %q", b)
+	}
+}
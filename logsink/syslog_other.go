@@ -0,0 +1,16 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows || plan9
+
+package logsink
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// NewSyslogSink fails: there is no syslog on this OS.
+func NewSyslogSink(network, address, tag string) (Sink, error) {
+	return nil, fmt.Errorf("syslog is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,47 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows && !plan9
+
+package logsink
+
+import (
+	"log/syslog"
+)
+
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink returns a sink sending entries to the syslog daemon at
+// address, over network, `udp` or `tcp` for example, or to the local one
+// when network is empty. Entries are tagged with tag, and sent with the
+// user facility and the priority of their level.
+func NewSyslogSink(network, address, tag string) (Sink, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Send(e Entry) error {
+	msg := e.Message
+	if e.BuildName != "" {
+		msg = e.BuildName + ": " + msg
+	}
+	switch e.Level {
+	case LevelError:
+		return s.w.Err(msg)
+	case LevelWarn:
+		return s.w.Warning(msg)
+	case LevelInfo:
+		return s.w.Info(msg)
+	default:
+		return s.w.Debug(msg)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/version"
+)
+
+// EnvironmentStateKey is the key of the Environment of a build in its state,
+// and of its Map in the state of the artifacts recording it.
+const EnvironmentStateKey = "build_environment"
+
+// Environment is what a build ran with, to trace an image back to exactly
+// what produced it.
+type Environment struct {
+	// PackerVersion is the version of Packer running the build, when
+	// known.
+	PackerVersion string `json:"packer_version,omitempty"`
+	// PluginVersions maps the plugins involved in the build to their
+	// version, the SDK being "packer-sdk".
+	PluginVersions map[string]string `json:"plugin_versions"`
+	// HostOS and HostArch are the OS and architecture of the host, like
+	// "linux" and "amd64", and GoVersion the version of Go the plugin was
+	// compiled with.
+	HostOS    string `json:"host_os"`
+	HostArch  string `json:"host_arch"`
+	GoVersion string `json:"go_version"`
+	// ToolVersions maps the programs the build ran on the host to their
+	// version, as found by commonsteps.StepCheckHostTools.
+	ToolVersions map[string]string `json:"tool_versions,omitempty"`
+	// TemplateDigest is the digest of the template of the build, see
+	// TemplateDigest.
+	TemplateDigest string `json:"template_digest,omitempty"`
+}
+
+// NewEnvironment returns the environment of the build of config, on this
+// host.
+func NewEnvironment(config common.PackerConfig) *Environment {
+	return &Environment{
+		PackerVersion: config.PackerCoreVersion,
+		PluginVersions: map[string]string{
+			"packer-sdk": version.SDKVersion.String(),
+		},
+		HostOS:    runtime.GOOS,
+		HostArch:  runtime.GOARCH,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// AddPlugin records the version of a plugin used by the build.
+func (e *Environment) AddPlugin(name, version string) {
+	e.PluginVersions[name] = version
+}
+
+// AddTools records the versions of programs run on the host, by name.
+func (e *Environment) AddTools(versions map[string]string) {
+	if len(versions) == 0 {
+		return
+	}
+	if e.ToolVersions == nil {
+		e.ToolVersions = map[string]string{}
+	}
+	for name, v := range versions {
+		e.ToolVersions[name] = v
+	}
+}
+
+// SetTemplate records the digest of the template at path.
+func (e *Environment) SetTemplate(path string) error {
+	digest, err := TemplateDigest(path)
+	if err != nil {
+		return err
+	}
+	e.TemplateDigest = digest
+	return nil
+}
+
+// TemplateDigest returns the InputsDigest of the template at path: the file,
+// or the HCL2 and JSON files of the directory, as Packer reads them.
+func TemplateDigest(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.Type().IsRegular() && (strings.HasSuffix(name, ".pkr.hcl") || strings.HasSuffix(name, ".pkr.json") ||
+				strings.HasSuffix(name, ".pkrvars.hcl") || strings.HasSuffix(name, ".pkrvars.json")) {
+				files = append(files, filepath.Join(path, name))
+			}
+		}
+	} else {
+		files = []string{path}
+	}
+
+	inputs := map[string][]byte{}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		inputs[filepath.Base(file)] = b
+	}
+	return InputsDigest(inputs), nil
+}
+
+// Map returns e as plain values, for artifacts to return from their State
+// under EnvironmentStateKey: maps of strings survive RPC, unlike structs.
+func (e *Environment) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"host_os":         e.HostOS,
+		"host_arch":       e.HostArch,
+		"go_version":      e.GoVersion,
+		"plugin_versions": copyMap(e.PluginVersions),
+	}
+	if e.PackerVersion != "" {
+		m["packer_version"] = e.PackerVersion
+	}
+	if len(e.ToolVersions) > 0 {
+		m["tool_versions"] = copyMap(e.ToolVersions)
+	}
+	if e.TemplateDigest != "" {
+		m["template_digest"] = e.TemplateDigest
+	}
+	return m
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// SetEnvironment records the environment of the build, whose plugin versions
+// are added to the ones of b.
+func (b *Build) SetEnvironment(e *Environment) {
+	b.Environment = e
+	for name, v := range e.PluginVersions {
+		b.AddPlugin(name, v)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package manifest records what a build consumed and produced: a digest of
+// its inputs, the versions of the plugins involved, the environment it ran
+// in, the artifacts, how long it took and the data generated by the builder. The manifest is written as
+// canonical JSON so that two identical builds produce identical manifests,
+// timings aside.
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/configdigest"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer-plugin-sdk/version"
+)
+
+// now is replaced in tests.
+var now = time.Now
+
+// Manifest is the content of a manifest file.
+type Manifest struct {
+	Builds []*Build `json:"builds"`
+}
+
+// Artifact identifies an artifact produced by a build.
+type Artifact struct {
+	BuilderId string   `json:"builder_id"`
+	Id        string   `json:"id"`
+	Files     []string `json:"files,omitempty"`
+}
+
+// Build is the manifest of a single build. Create it with NewBuild when the
+// build starts and call Finish once it is done.
+type Build struct {
+	Name        string `json:"name"`
+	BuilderType string `json:"builder_type"`
+	// InputsDigest is the digest of the inputs set with SetInputs, written
+	// `sha256:<hex>`.
+	InputsDigest string `json:"inputs_digest,omitempty"`
+	// ConfigDigest is the digest of the prepared configuration set with
+	// SetConfig, see configdigest.Digest.
+	ConfigDigest string `json:"config_digest,omitempty"`
+	// PluginVersions maps the plugins involved in the build to their
+	// version. The version of the SDK is always recorded as "packer-sdk".
+	PluginVersions map[string]string      `json:"plugin_versions"`
+	Artifacts      []Artifact             `json:"artifacts"`
+	StartTime      time.Time              `json:"start_time"`
+	EndTime        *time.Time             `json:"end_time,omitempty"`
+	Duration       string                 `json:"duration,omitempty"`
+	GeneratedData  map[string]interface{} `json:"generated_data,omitempty"`
+	// Environment is what the build ran with, set with SetEnvironment.
+	Environment *Environment `json:"environment,omitempty"`
+}
+
+// NewBuild starts the manifest of a build.
+func NewBuild(name, builderType string) *Build {
+	return &Build{
+		Name:        name,
+		BuilderType: builderType,
+		PluginVersions: map[string]string{
+			"packer-sdk": version.SDKVersion.String(),
+		},
+		Artifacts: []Artifact{},
+		StartTime: now().UTC(),
+	}
+}
+
+// SetInputs records the digest of the inputs of the build, for example the
+// files of the template by path. The digest does not depend on the order of
+// the map.
+func (b *Build) SetInputs(inputs map[string][]byte) {
+	b.InputsDigest = InputsDigest(inputs)
+}
+
+// SetConfig records the digest of the prepared configuration of the
+// builder, without its secrets.
+func (b *Build) SetConfig(config interface{}) error {
+	digest, err := configdigest.Digest(config)
+	if err != nil {
+		return fmt.Errorf("error digesting configuration: %s", err)
+	}
+	b.ConfigDigest = digest
+	return nil
+}
+
+// AddPlugin records the version of a plugin used by the build.
+func (b *Build) AddPlugin(name, version string) {
+	b.PluginVersions[name] = version
+}
+
+// AddArtifact records an artifact produced by the build. Nil artifacts,
+// returned by builders that produced nothing, are ignored.
+func (b *Build) AddArtifact(a packersdk.Artifact) {
+	if a == nil {
+		return
+	}
+	files := append([]string(nil), a.Files()...)
+	sort.Strings(files)
+	b.Artifacts = append(b.Artifacts, Artifact{
+		BuilderId: a.BuilderId(),
+		Id:        a.Id(),
+		Files:     files,
+	})
+}
+
+// SetGeneratedData records the data generated by the builder, leaving out
+// the placeholders of values the builder did not set, and the typed copy of
+// the values of its packerbuilderdata.Store.
+func (b *Build) SetGeneratedData(data map[string]interface{}) {
+	b.GeneratedData = make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if s, ok := v.(string); ok && s == packerbuilderdata.PlaceholderMsg {
+			continue
+		}
+		if k == packerbuilderdata.TypedDataKey {
+			continue
+		}
+		b.GeneratedData[k] = v
+	}
+}
+
+// Finish records the end of the build.
+func (b *Build) Finish() {
+	end := now().UTC()
+	b.EndTime = &end
+	b.Duration = end.Sub(b.StartTime).String()
+}
+
+// InputsDigest returns the SHA-256 digest of inputs, written `sha256:<hex>`.
+// Each input is hashed with its name and length so that moving bytes from
+// one input to another changes the digest.
+func InputsDigest(inputs map[string][]byte) string {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%q %d\n", name, len(inputs[name]))
+		h.Write(inputs[name])
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Encode writes m to w as indented JSON.
+func (m *Manifest) Encode(w io.Writer) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// WriteFile writes the manifest of builds to path. The file is replaced
+// atomically so that readers never see a partial manifest.
+func WriteFile(path string, builds ...*Build) error {
+	var buf bytes.Buffer
+	if err := (&Manifest{Builds: builds}).Encode(&buf); err != nil {
+		return fmt.Errorf("error encoding manifest: %s", err)
+	}
+	tf, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("error creating manifest: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	if err := tf.Chmod(0644); err != nil {
+		tf.Close()
+		return fmt.Errorf("error writing manifest: %s", err)
+	}
+	if _, err := tf.Write(buf.Bytes()); err != nil {
+		tf.Close()
+		return fmt.Errorf("error writing manifest: %s", err)
+	}
+	if err := tf.Close(); err != nil {
+		return fmt.Errorf("error writing manifest: %s", err)
+	}
+	if err := os.Rename(tf.Name(), path); err != nil {
+		return fmt.Errorf("error writing manifest: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer-plugin-sdk/version"
+)
+
+func TestBuild(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	b := NewBuild("example", "null")
+	b.SetInputs(map[string][]byte{"build.pkr.hcl": []byte("source {}")})
+	if err := b.SetConfig(&struct {
+		Region string `mapstructure:"region"`
+	}{"eu-west-1"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b.AddPlugin("packer-plugin-example", "v1.0.0")
+	b.AddArtifact(&packersdk.MockArtifact{IdValue: "ami-1", FilesValue: []string{"b", "a"}})
+	b.AddArtifact(nil)
+	b.SetGeneratedData(map[string]interface{}{
+		"ID":       "i-1",
+		"SSHHost":  packerbuilderdata.PlaceholderMsg,
+		"Attempts": 2,
+	})
+	now = func() time.Time { return start.Add(90 * time.Second) }
+	b.Finish()
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := WriteFile(path, b); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]interface{}{
+		"builds": []interface{}{map[string]interface{}{
+			"name":          "example",
+			"builder_type":  "null",
+			"inputs_digest": InputsDigest(map[string][]byte{"build.pkr.hcl": []byte("source {}")}),
+			"config_digest": "sha256:" + fmt.Sprintf("%x", sha256.Sum256([]byte(`{"region":"eu-west-1"}`))),
+			"plugin_versions": map[string]interface{}{
+				"packer-sdk":            version.SDKVersion.String(),
+				"packer-plugin-example": "v1.0.0",
+			},
+			"artifacts": []interface{}{map[string]interface{}{
+				"builder_id": "bid",
+				"id":         "ami-1",
+				"files":      []interface{}{"a", "b"},
+			}},
+			"start_time":     "2024-01-02T03:04:05Z",
+			"end_time":       "2024-01-02T03:05:35Z",
+			"duration":       "1m30s",
+			"generated_data": map[string]interface{}{"ID": "i-1", "Attempts": float64(2)},
+		}},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected manifest: %s", diff)
+	}
+}
+
+func TestInputsDigest(t *testing.T) {
+	a := InputsDigest(map[string][]byte{"a": []byte("xy"), "b": []byte("z")})
+	if a != InputsDigest(map[string][]byte{"b": []byte("z"), "a": []byte("xy")}) {
+		t.Fatalf("the digest should not depend on the order of the inputs")
+	}
+	if a == InputsDigest(map[string][]byte{"a": []byte("x"), "b": []byte("yz")}) {
+		t.Fatalf("moving bytes between inputs should change the digest")
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "build.pkr.hcl"), []byte("build {}"), 0644)
+	os.WriteFile(filepath.Join(dir, "vars.auto.pkrvars.hcl"), []byte(`region = "eu-west-1"`), 0644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("not part of the template"), 0644)
+
+	e := NewEnvironment(common.PackerConfig{PackerCoreVersion: "1.11.0"})
+	e.AddPlugin("packer-plugin-qemu", "v1.1.0")
+	e.AddTools(map[string]string{"qemu-img": "8.2.2"})
+	if err := e.SetTemplate(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expectedDigest := InputsDigest(map[string][]byte{
+		"build.pkr.hcl":         []byte("build {}"),
+		"vars.auto.pkrvars.hcl": []byte(`region = "eu-west-1"`),
+	})
+	if e.TemplateDigest != expectedDigest {
+		t.Fatalf("bad template digest: %s", e.TemplateDigest)
+	}
+
+	expected := map[string]interface{}{
+		"packer_version":  "1.11.0",
+		"host_os":         runtime.GOOS,
+		"host_arch":       runtime.GOARCH,
+		"go_version":      runtime.Version(),
+		"plugin_versions": map[string]string{"packer-sdk": version.SDKVersion.String(), "packer-plugin-qemu": "v1.1.0"},
+		"tool_versions":   map[string]string{"qemu-img": "8.2.2"},
+		"template_digest": expectedDigest,
+	}
+	if diff := cmp.Diff(expected, e.Map()); diff != "" {
+		t.Fatalf("unexpected map: %s", diff)
+	}
+
+	b := NewBuild("example", "qemu")
+	b.SetEnvironment(e)
+	if b.PluginVersions["packer-plugin-qemu"] != "v1.1.0" || b.Environment != e {
+		t.Fatalf("the environment should be recorded: %#v", b)
+	}
+}
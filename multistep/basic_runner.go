@@ -5,6 +5,9 @@ package multistep
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
 )
@@ -23,6 +26,14 @@ type BasicRunner struct {
 	// modified.
 	Steps []Step
 
+	// Checkpoints, if set, stores a checkpoint after each step completes,
+	// so that a run interrupted by a crash can be resumed: the next run
+	// restores the state of the completed steps implementing
+	// StatePersister instead of running them, and runs the other steps
+	// again, so those should be safe to run twice. The checkpoint is
+	// removed when Run returns, since the steps are then cleaned up.
+	Checkpoints CheckpointStore
+
 	l     sync.Mutex
 	state runState
 }
@@ -55,6 +66,9 @@ func (b *BasicRunner) Run(ctx context.Context, state StateBag) {
 		}
 	}()
 
+	checkpoint := newCheckpointRun(b.Checkpoints)
+	defer checkpoint.remove()
+
 	for _, step := range b.Steps {
 		if step == nil {
 			continue
@@ -70,8 +84,13 @@ func (b *BasicRunner) Run(ctx context.Context, state StateBag) {
 			break
 		}
 
-		action := step.Run(ctx, state)
-		defer step.Cleanup(state)
+		if checkpoint.restore(step, state) {
+			defer cleanupStep(step, state)
+			continue
+		}
+
+		action := runStep(ctx, step, state)
+		defer cleanupStep(step, state)
 
 		if _, ok := state.GetOk(StateCancelled); ok {
 			break
@@ -81,5 +100,36 @@ func (b *BasicRunner) Run(ctx context.Context, state StateBag) {
 			state.Put(StateHalted, true)
 			break
 		}
+		checkpoint.completed(step, state)
 	}
 }
+
+// runStep runs a step, turning a panic into an error in the state bag and an
+// ActionHalt, so that the steps that ran before it are still cleaned up.
+func runStep(ctx context.Context, step Step, state StateBag) (action StepAction) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("panic in step %T: %v\n%s", step, r, debug.Stack())
+			log.Printf("[ERROR] %s", err)
+			state.Put("error", err)
+			action = ActionHalt
+		}
+	}()
+	return step.Run(ctx, state)
+}
+
+// cleanupStep cleans a step up, recovering from a panic so that the cleanup
+// of the remaining steps still happens. The panic is reported in the state
+// bag unless an error is already there.
+func cleanupStep(step Step, state StateBag) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("panic in cleanup of step %T: %v\n%s", step, r, debug.Stack())
+			log.Printf("[ERROR] %s", err)
+			if _, ok := state.GetOk("error"); !ok {
+				state.Put("error", err)
+			}
+		}
+	}()
+	step.Cleanup(state)
+}
@@ -6,6 +6,7 @@ package multistep
 import (
 	"context"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -175,3 +176,53 @@ func TestBasicRunner_Cancel_Special(t *testing.T) {
 		t.Errorf("cancelled should be in state bag")
 	}
 }
+
+func TestBasicRunner_Run_Panic(t *testing.T) {
+	data := new(BasicStateBag)
+	stepA := &TestStepAcc{Data: "a"}
+	stepC := &TestStepAcc{Data: "c"}
+
+	r := &BasicRunner{Steps: []Step{stepA, TestStepPanic{}, stepC}}
+	r.Run(context.Background(), data)
+
+	// Test run data
+	expected := []string{"a"}
+	results := data.Get("data").([]string)
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected result: %#v", results)
+	}
+
+	// Steps that ran before the panic are cleaned up
+	results = data.Get("cleanup").([]string)
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected result: %#v", results)
+	}
+
+	err, ok := data.Get("error").(error)
+	if !ok || !strings.Contains(err.Error(), "run panic") {
+		t.Errorf("panic should be reported as an error, got: %#v", data.Get("error"))
+	}
+	if _, ok := data.GetOk(StateHalted); !ok {
+		t.Errorf("halted should be in state bag")
+	}
+}
+
+func TestBasicRunner_Cleanup_Panic(t *testing.T) {
+	data := new(BasicStateBag)
+	stepA := &TestStepAcc{Data: "a"}
+	stepC := &TestStepAcc{Data: "c"}
+
+	r := &BasicRunner{Steps: []Step{stepA, TestStepPanic{InCleanup: true}, stepC}}
+	r.Run(context.Background(), data)
+
+	expected := []string{"c", "a"}
+	results := data.Get("cleanup").([]string)
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected result: %#v", results)
+	}
+
+	err, ok := data.Get("error").(error)
+	if !ok || !strings.Contains(err.Error(), "cleanup panic") {
+		t.Errorf("panic should be reported as an error, got: %#v", data.Get("error"))
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// StatePersister is implemented by the steps whose work can be kept when a
+// build is resumed from a checkpoint, see BasicRunner.Checkpoints.
+type StatePersister interface {
+	// SaveState is called after Run succeeded, and returns what the step
+	// needs to restore its state later, usually the values it Put in the
+	// state bag, serialized.
+	SaveState(StateBag) ([]byte, error)
+
+	// RestoreState is called instead of Run when resuming, with what
+	// SaveState returned. It puts back in the state bag what Run would
+	// have.
+	RestoreState(StateBag, []byte) error
+}
+
+// Checkpoint records the steps of a sequence that completed.
+type Checkpoint struct {
+	Steps []CheckpointStep `json:"steps"`
+}
+
+// CheckpointStep is a step that completed.
+type CheckpointStep struct {
+	// Name is the type of the step, to check that a checkpoint is resumed
+	// with the same sequence of steps.
+	Name string `json:"name"`
+	// State is what the step saved, nil for the steps that are not
+	// StatePersisters.
+	State []byte `json:"state,omitempty"`
+}
+
+// CheckpointStore stores the checkpoint of a sequence of steps.
+type CheckpointStore interface {
+	// Load returns the stored checkpoint, nil when there is none.
+	Load() (*Checkpoint, error)
+	Save(*Checkpoint) error
+	Remove() error
+}
+
+// FileCheckpointStore is a CheckpointStore keeping the checkpoint in a JSON
+// file.
+type FileCheckpointStore struct {
+	Path string
+}
+
+func (s *FileCheckpointStore) Load() (*Checkpoint, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := new(Checkpoint)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %s", s.Path, err)
+	}
+	return c, nil
+}
+
+// Save writes c to a temporary file first, so that a crash while saving
+// leaves the previous checkpoint intact.
+func (s *FileCheckpointStore) Save(c *Checkpoint) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+func (s *FileCheckpointStore) Remove() error {
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// checkpointRun checkpoints a run of a sequence, resuming the previous
+// checkpoint if any.
+type checkpointRun struct {
+	store CheckpointStore
+	// previous are the steps of the checkpoint being resumed, in the order
+	// they completed.
+	previous []CheckpointStep
+	current  Checkpoint
+}
+
+func newCheckpointRun(store CheckpointStore) *checkpointRun {
+	if store == nil {
+		return nil
+	}
+	c := &checkpointRun{store: store}
+	previous, err := store.Load()
+	if err != nil {
+		log.Printf("[WARN] Not resuming from the checkpoint: %s", err)
+	} else if previous != nil {
+		log.Printf("[INFO] Resuming from a checkpoint of %d completed steps", len(previous.Steps))
+		c.previous = previous.Steps
+	}
+	return c
+}
+
+// restore restores the state of step from the checkpoint being resumed, and
+// returns whether it was, in which case step must not be run. The steps
+// that are not StatePersisters are run again.
+func (c *checkpointRun) restore(step Step, state StateBag) bool {
+	if c == nil {
+		return false
+	}
+	i := len(c.current.Steps)
+	if i >= len(c.previous) {
+		return false
+	}
+	saved := c.previous[i]
+	name := fmt.Sprintf("%T", step)
+	if saved.Name != name {
+		log.Printf("[WARN] Step %d is a %s, it was a %s in the checkpoint: not resuming the next steps", i, name, saved.Name)
+		c.previous = nil
+		return false
+	}
+
+	p, ok := step.(StatePersister)
+	if !ok || saved.State == nil {
+		return false
+	}
+	if err := p.RestoreState(state, saved.State); err != nil {
+		log.Printf("[WARN] Error restoring the state of step %s, running it again: %s", name, err)
+		c.previous = nil
+		return false
+	}
+	log.Printf("[INFO] Restored the state of step %s from the checkpoint", name)
+	c.current.Steps = append(c.current.Steps, saved)
+	c.save()
+	return true
+}
+
+// completed records that step ran successfully.
+func (c *checkpointRun) completed(step Step, state StateBag) {
+	if c == nil {
+		return
+	}
+	saved := CheckpointStep{Name: fmt.Sprintf("%T", step)}
+	if p, ok := step.(StatePersister); ok {
+		data, err := p.SaveState(state)
+		if err != nil {
+			log.Printf("[WARN] Error saving the state of step %s, it will run again when resuming: %s", saved.Name, err)
+		}
+		saved.State = data
+	}
+	c.current.Steps = append(c.current.Steps, saved)
+	c.save()
+}
+
+func (c *checkpointRun) save() {
+	if err := c.store.Save(&c.current); err != nil {
+		log.Printf("[WARN] Error saving the checkpoint: %s", err)
+	}
+}
+
+// remove removes the checkpoint once the run is over, which includes the
+// cleanup of its steps: there is nothing left to resume.
+func (c *checkpointRun) remove() {
+	if c == nil {
+		return
+	}
+	if err := c.store.Remove(); err != nil {
+		log.Printf("[WARN] Error removing the checkpoint: %s", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testStepPersist puts its value under its key, and saves it.
+type testStepPersist struct {
+	key   string
+	value string
+	ran   bool
+}
+
+func (s *testStepPersist) Run(_ context.Context, state StateBag) StepAction {
+	s.ran = true
+	state.Put(s.key, s.value)
+	return ActionContinue
+}
+
+func (s *testStepPersist) Cleanup(StateBag) {}
+
+func (s *testStepPersist) SaveState(state StateBag) ([]byte, error) {
+	return []byte(state.Get(s.key).(string)), nil
+}
+
+func (s *testStepPersist) RestoreState(state StateBag, data []byte) error {
+	state.Put(s.key, string(data))
+	return nil
+}
+
+// memoryCheckpointStore records the checkpoints it saves.
+type memoryCheckpointStore struct {
+	saved   []Checkpoint
+	removed bool
+}
+
+func (s *memoryCheckpointStore) Load() (*Checkpoint, error) { return nil, nil }
+
+func (s *memoryCheckpointStore) Save(c *Checkpoint) error {
+	s.saved = append(s.saved, Checkpoint{Steps: append([]CheckpointStep{}, c.Steps...)})
+	return nil
+}
+
+func (s *memoryCheckpointStore) Remove() error {
+	s.removed = true
+	return nil
+}
+
+func TestBasicRunner_Checkpoints(t *testing.T) {
+	store := new(memoryCheckpointStore)
+	r := &BasicRunner{
+		Steps: []Step{
+			&testStepPersist{key: "vm", value: "vm-1"},
+			&TestStepAcc{Data: "a"},
+		},
+		Checkpoints: store,
+	}
+	r.Run(context.Background(), new(BasicStateBag))
+
+	expected := []Checkpoint{
+		{Steps: []CheckpointStep{{Name: "*multistep.testStepPersist", State: []byte("vm-1")}}},
+		{Steps: []CheckpointStep{
+			{Name: "*multistep.testStepPersist", State: []byte("vm-1")},
+			{Name: "*multistep.TestStepAcc"},
+		}},
+	}
+	if !reflect.DeepEqual(store.saved, expected) {
+		t.Fatalf("bad checkpoints: %#v", store.saved)
+	}
+	if !store.removed {
+		t.Fatal("the checkpoint should be removed at the end of the run")
+	}
+}
+
+func TestBasicRunner_Checkpoints_resume(t *testing.T) {
+	store := &FileCheckpointStore{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+	// A previous run crashed after the first two steps.
+	err := store.Save(&Checkpoint{Steps: []CheckpointStep{
+		{Name: "*multistep.testStepPersist", State: []byte("vm-1")},
+		{Name: "*multistep.TestStepAcc"},
+	}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	vm := &testStepPersist{key: "vm", value: "vm-2"}
+	disk := &testStepPersist{key: "disk", value: "disk-2"}
+	data := new(BasicStateBag)
+	r := &BasicRunner{
+		Steps:       []Step{vm, &TestStepAcc{Data: "a"}, disk},
+		Checkpoints: store,
+	}
+	r.Run(context.Background(), data)
+
+	if vm.ran || data.Get("vm") != "vm-1" {
+		t.Fatalf("the state of the completed step should be restored: %#v", data.Get("vm"))
+	}
+	if results := data.Get("data").([]string); !reflect.DeepEqual(results, []string{"a"}) {
+		t.Fatalf("steps that do not persist their state should run again: %#v", results)
+	}
+	if !disk.ran {
+		t.Fatal("the steps after the checkpoint should run")
+	}
+	if c, err := store.Load(); err != nil || c != nil {
+		t.Fatalf("the checkpoint should be removed: %#v, %v", c, err)
+	}
+}
+
+func TestBasicRunner_Checkpoints_changedSteps(t *testing.T) {
+	store := &FileCheckpointStore{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+	err := store.Save(&Checkpoint{Steps: []CheckpointStep{
+		{Name: "*multistep.TestStepAcc"},
+		{Name: "*multistep.testStepPersist", State: []byte("vm-1")},
+	}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The sequence is not the one of the checkpoint anymore.
+	vm := &testStepPersist{key: "vm", value: "vm-2"}
+	data := new(BasicStateBag)
+	r := &BasicRunner{Steps: []Step{vm, &TestStepAcc{Data: "a"}}, Checkpoints: store}
+	r.Run(context.Background(), data)
+
+	if !vm.ran || data.Get("vm") != "vm-2" {
+		t.Fatalf("the step should run: %#v", data.Get("vm"))
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package commonsteps
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Packer can check that the guest has what it was built for once it is
+// provisioned, and fail the build with a report of what is missing rather
+// than produce a broken image. By default, nothing is checked.
+//
+// Example usage from a builder:
+//
+// ```hcl
+// assert_files    = ["/etc/nginx/nginx.conf"]
+// assert_packages = ["nginx"]
+// assert_services = ["nginx"]
+// ```
+type AssertConfig struct {
+	// Paths of files or directories that must exist on the guest.
+	AssertFiles []string `mapstructure:"assert_files"`
+	// Packages that must be installed on the guest. They are looked up with
+	// dpkg, rpm or apk on Unix guests, and `Get-Package` on Windows guests.
+	AssertPackages []string `mapstructure:"assert_packages"`
+	// Services that must be running on the guest. They are looked up with
+	// `systemctl` or `service` on Unix guests, and `Get-Service` on Windows
+	// guests.
+	AssertServices []string `mapstructure:"assert_services"`
+}
+
+func (c *AssertConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	check := func(option string, names []string) {
+		for _, name := range names {
+			if strings.TrimSpace(name) == "" {
+				errs = append(errs, fmt.Errorf("%s must not contain empty names", option))
+				return
+			}
+			if strings.Contains(name, "'") {
+				errs = append(errs, fmt.Errorf("%s: %q must not contain quotes", option, name))
+			}
+		}
+	}
+	check("assert_files", c.AssertFiles)
+	check("assert_packages", c.AssertPackages)
+	check("assert_services", c.AssertServices)
+	return errs
+}
+
+// Assertions returns the assertions configured.
+func (c *AssertConfig) Assertions() *guestexec.Assertions {
+	return &guestexec.Assertions{
+		Files:    c.AssertFiles,
+		Packages: c.AssertPackages,
+		Services: c.AssertServices,
+	}
+}
+
+func (c *AssertConfig) empty() bool {
+	return len(c.AssertFiles)+len(c.AssertPackages)+len(c.AssertServices) == 0
+}
@@ -12,6 +12,10 @@ launching Packer's internal HTTP server for serving files to the instance.
 It also provides step_provision, which contains the hooks necessary for allowing
 provisioners to run inside your builder.
 
+The keys of the state bag that builders and steps share by convention, like
+"ui" and "communicator", are defined as constants such as StateUi, with typed
+getters and setters such as GetUi and PutUi.
+
 While it is possible to create a simple builder without using the multistep
 runner or step_provision, your builder will lack core Packer functionality.
 */
@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Converter converts disk images from a format to another, for
+// StepExportArtifact.
+type Converter interface {
+	// Converts tells whether the converter converts images in format from
+	// to format to.
+	Converts(from, to string) bool
+	// Convert writes the image src, in format from, to dst in format to.
+	Convert(ctx context.Context, src, from, dst, to string) error
+}
+
+// CommandConverter is a Converter running a command, like qemu-img or
+// vmware-vdiskmanager.
+type CommandConverter struct {
+	// From and To are the formats converted from and to, any when empty.
+	From, To []string
+	// Command returns the command and arguments converting src to dst.
+	Command func(src, from, dst, to string) []string
+}
+
+func (c *CommandConverter) Converts(from, to string) bool {
+	return (len(c.From) == 0 || containsString(c.From, from)) && (len(c.To) == 0 || containsString(c.To, to))
+}
+
+func (c *CommandConverter) Convert(ctx context.Context, src, from, dst, to string) error {
+	args := c.Command(src, from, dst, to)
+	if len(args) == 0 {
+		return fmt.Errorf("no command to convert %s to %s", from, to)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	convertersLock sync.RWMutex
+	converters     = map[string]Converter{}
+	// converterOrder is the order converters were registered in.
+	converterOrder []string
+)
+
+// RegisterConverter adds c to the converters StepExportArtifact picks from,
+// for builders to plug the tools of their hypervisor in. It panics if a
+// converter of the same name is already registered.
+func RegisterConverter(name string, c Converter) {
+	convertersLock.Lock()
+	defer convertersLock.Unlock()
+	if _, ok := converters[name]; ok {
+		panic(fmt.Sprintf("commonsteps: converter %q registered twice", name))
+	}
+	converters[name] = c
+	converterOrder = append(converterOrder, name)
+}
+
+// LookupConverter returns the first registered converter converting images in
+// format from to format to.
+func LookupConverter(from, to string) (Converter, bool) {
+	convertersLock.RLock()
+	defer convertersLock.RUnlock()
+	for _, name := range converterOrder {
+		if c := converters[name]; c.Converts(from, to) {
+			return c, true
+		}
+	}
+	return nil, false
+}
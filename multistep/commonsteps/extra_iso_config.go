@@ -7,10 +7,8 @@ package commonsteps
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
 
+	"github.com/hashicorp/packer-plugin-sdk/template/include"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
@@ -98,16 +96,9 @@ func (c *CDConfig) Prepare(ctx *interpolate.Context) []error {
 	// Create new file list based on globbing.
 	var files []string
 	for _, path := range c.CDFiles {
-		if strings.ContainsAny(path, "*?[") {
-			var globbedFiles []string
-			globbedFiles, err = filepath.Glob(path)
-			if len(globbedFiles) > 0 {
-				files = append(files, globbedFiles...)
-			}
-		} else {
-			_, err = os.Stat(path)
-			files = append(files, path)
-		}
+		var matches []string
+		matches, err = new(include.Resolver).Glob(path)
+		files = append(files, matches...)
 		if err != nil {
 			errs = append(errs, fmt.Errorf("Bad CD disk file '%s': %s", path, err))
 		}
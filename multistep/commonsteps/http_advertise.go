@@ -0,0 +1,73 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"fmt"
+	"net"
+)
+
+// HTTPIPGeneratedData is the name of the generated data holding the address
+// of the HTTP server chosen with http_advertise_strategy. Builders add it to
+// the generated data they declare to make it available as
+// `build.HTTPIP`.
+const HTTPIPGeneratedData = "HTTPIP"
+
+// advertisedIP returns the address of the HTTP server to give to the guest,
+// or "" when no strategy is set and the builder should guess it.
+func (s *StepHTTPServer) advertisedIP() (string, error) {
+	switch s.HTTPAdvertiseStrategy {
+	case "":
+		return "", nil
+	case HTTPAdvertiseAddress:
+		return s.HTTPAdvertiseAddress, nil
+	case HTTPAdvertiseInterface:
+		return interfaceIP(s.HTTPAdvertiseInterface)
+	case HTTPAdvertiseRoute:
+		return routeIP(s.HTTPAdvertiseRouteTo)
+	default:
+		return "", fmt.Errorf("unknown http_advertise_strategy %q", s.HTTPAdvertiseStrategy)
+	}
+}
+
+// interfaceIP returns the first IPv4 address of the interface name, or its
+// first IPv6 address when it has none.
+func interfaceIP(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("Error finding interface %q: %s", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("Error listing the addresses of interface %q: %s", name, err)
+	}
+	var v6 net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ip := ipNet.IP.To4(); ip != nil {
+			return ip.String(), nil
+		}
+		if v6 == nil {
+			v6 = ipNet.IP
+		}
+	}
+	if v6 == nil {
+		return "", fmt.Errorf("interface %q has no usable address", name)
+	}
+	return v6.String(), nil
+}
+
+// routeIP returns the local address of the route to the host dest. Dialing
+// UDP sends nothing: it only picks the route.
+func routeIP(dest string) (string, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(dest, "9"))
+	if err != nil {
+		return "", fmt.Errorf("Error finding the route to %s: %s", dest, err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
@@ -21,6 +21,14 @@ const (
 	NetworkProcotlUnixPacket        = "unixpacket"
 )
 
+// These are the valid values for "http_advertise_strategy", how the address of
+// the HTTP server given to the guest is found.
+const (
+	HTTPAdvertiseAddress   string = "address"
+	HTTPAdvertiseInterface        = "interface"
+	HTTPAdvertiseRoute            = "route"
+)
+
 // Packer will create an http server serving `http_directory` when it is set, a
 // random free port will be selected and the architecture of the directory
 // referenced will be available in your builder.
@@ -71,6 +79,24 @@ type HTTPConfig struct {
 	// Defines the HTTP Network protocol. Valid options are `tcp`, `tcp4`, `tcp6`,
 	// `unix`, and `unixpacket`. This value defaults to `tcp`.
 	HTTPNetworkProtocol string `mapstructure:"http_network_protocol"`
+	// How the address of the HTTP server given to the guest, `{{ .HTTPIP }}`,
+	// is found, for hosts where the builder guesses it wrong, like
+	// multi-homed hosts or WSL. `address` uses `http_advertise_address`,
+	// `interface` the first address of `http_advertise_interface`, and
+	// `route` the local address of the route to `http_advertise_route_to`.
+	// Defaults to the strategy of the option that is set; when none is, the
+	// builder guesses the address.
+	HTTPAdvertiseStrategy string `mapstructure:"http_advertise_strategy"`
+	// The address given to the guest, for example the address of the host
+	// on the network of the guest when Packer runs in a container. It can
+	// be a host name.
+	HTTPAdvertiseAddress string `mapstructure:"http_advertise_address"`
+	// The interface whose address is given to the guest, like `virbr0`.
+	// IPv4 addresses are preferred.
+	HTTPAdvertiseInterface string `mapstructure:"http_advertise_interface"`
+	// An address of the network of the guest, like its gateway. The address
+	// of the interface the host routes it through is given to the guest.
+	HTTPAdvertiseRouteTo string `mapstructure:"http_advertise_route_to"`
 }
 
 func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
@@ -129,5 +155,45 @@ func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
 			fmt.Errorf("http_network_protocol is invalid. Must be one of: %v", validProtocols))
 	}
 
+	errs = append(errs, c.prepareAdvertise()...)
+
+	return errs
+}
+
+func (c *HTTPConfig) prepareAdvertise() []error {
+	var errs []error
+	set := map[string]string{
+		HTTPAdvertiseAddress:   c.HTTPAdvertiseAddress,
+		HTTPAdvertiseInterface: c.HTTPAdvertiseInterface,
+		HTTPAdvertiseRoute:     c.HTTPAdvertiseRouteTo,
+	}
+	options := map[string]string{
+		HTTPAdvertiseAddress:   "http_advertise_address",
+		HTTPAdvertiseInterface: "http_advertise_interface",
+		HTTPAdvertiseRoute:     "http_advertise_route_to",
+	}
+
+	if c.HTTPAdvertiseStrategy == "" {
+		for _, strategy := range []string{HTTPAdvertiseAddress, HTTPAdvertiseInterface, HTTPAdvertiseRoute} {
+			if set[strategy] == "" {
+				continue
+			}
+			if c.HTTPAdvertiseStrategy != "" {
+				errs = append(errs, fmt.Errorf("only one of http_advertise_address, http_advertise_interface and http_advertise_route_to can be specified"))
+				break
+			}
+			c.HTTPAdvertiseStrategy = strategy
+		}
+		return errs
+	}
+
+	option, ok := options[c.HTTPAdvertiseStrategy]
+	if !ok {
+		return append(errs, fmt.Errorf("http_advertise_strategy is invalid. Must be one of: %v",
+			[]string{HTTPAdvertiseAddress, HTTPAdvertiseInterface, HTTPAdvertiseRoute}))
+	}
+	if set[c.HTTPAdvertiseStrategy] == "" {
+		errs = append(errs, fmt.Errorf("%s must be specified with http_advertise_strategy %q", option, c.HTTPAdvertiseStrategy))
+	}
 	return errs
 }
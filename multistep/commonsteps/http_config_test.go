@@ -46,3 +46,24 @@ func TestHTTPConfigPrepare_Bounds(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 }
+
+func TestHTTPConfigPrepare_Advertise(t *testing.T) {
+	h := HTTPConfig{HTTPAdvertiseInterface: "virbr0"}
+	if errs := h.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if h.HTTPAdvertiseStrategy != HTTPAdvertiseInterface {
+		t.Fatalf("the strategy should default to interface, got %q", h.HTTPAdvertiseStrategy)
+	}
+
+	bad := []HTTPConfig{
+		{HTTPAdvertiseAddress: "10.0.0.1", HTTPAdvertiseRouteTo: "192.168.122.1"},
+		{HTTPAdvertiseStrategy: "guess"},
+		{HTTPAdvertiseStrategy: HTTPAdvertiseRoute},
+	}
+	for _, h := range bad {
+		if errs := h.Prepare(nil); len(errs) == 0 {
+			t.Fatalf("%#v should have error", h)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -116,7 +117,7 @@ func (s askStep) Run(ctx context.Context, state multistep.StateBag) (action mult
 			return
 		}
 
-		err, ok := state.GetOk("error")
+		err, ok := state.GetOk(StateError)
 		if ok {
 			s.ui.Error(fmt.Sprintf("%s", err))
 		}
@@ -152,7 +153,7 @@ const (
 )
 
 func ask(ui packersdk.Ui, name string, state multistep.StateBag) askResponse {
-	ui.Say(fmt.Sprintf("Step %q failed", name))
+	ui.Say(i18n.Sprintf("Step %q failed", name))
 
 	result := make(chan askResponse)
 	go func() {
@@ -187,7 +188,7 @@ func askPrompt(ui packersdk.Ui) askResponse {
 		case 'r':
 			return askRetry
 		}
-		ui.Say(fmt.Sprintf("Incorrect input: %#v", line))
+		ui.Say(i18n.Sprintf("Incorrect input: %#v", line))
 	}
 }
 
@@ -195,26 +196,26 @@ func handleAbortsAndInterupts(state multistep.StateBag, ui packersdk.Ui, stepNam
 	// if returns false, don't run cleanup. If true, do run cleanup.
 	_, alreadyLogged := state.GetOk("abort_step_logged")
 
-	err, ok := state.GetOk("error")
+	err, ok := state.GetOk(StateError)
 	if ok && !alreadyLogged {
 		ui.Error(fmt.Sprintf("%s", err))
 		state.Put("abort_step_logged", true)
 	}
 	if _, ok := state.GetOk(multistep.StateCancelled); ok {
 		if !alreadyLogged {
-			ui.Error("Interrupted, aborting...")
+			ui.Error(i18n.T("Interrupted, aborting..."))
 			state.Put("abort_step_logged", true)
 		} else {
-			ui.Error(fmt.Sprintf("aborted: skipping cleanup of step %q", stepName))
+			ui.Error(i18n.Sprintf("aborted: skipping cleanup of step %q", stepName))
 		}
 		return false
 	}
 	if _, ok := state.GetOk(multistep.StateHalted); ok {
 		if !alreadyLogged {
-			ui.Error(fmt.Sprintf("Step %q failed, aborting...", stepName))
+			ui.Error(i18n.Sprintf("Step %q failed, aborting...", stepName))
 			state.Put("abort_step_logged", true)
 		} else {
-			ui.Error(fmt.Sprintf("aborted: skipping cleanup of step %q", stepName))
+			ui.Error(i18n.Sprintf("aborted: skipping cleanup of step %q", stepName))
 		}
 		return false
 	}
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package commonsteps
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// A virtual TPM and UEFI secure boot can be enabled for the guest, as
+// Windows 11 and secure boot Linux images require. The keys to enroll are
+// given as certificates, PEM or DER encoded, and converted to the EFI
+// signature lists the firmware expects. Without keys, the hypervisor keeps
+// its default ones.
+type SecureBootConfig struct {
+	// Attach a TPM 2.0 to the guest. Defaults to `false`.
+	TPM bool `mapstructure:"vtpm"`
+	// The directory keeping the state of the TPM, so that it can be reused
+	// by the machines created from the image. A temporary directory,
+	// removed at the end of the build, is used when unset.
+	TPMStateDirectory string `mapstructure:"vtpm_state_directory"`
+	// Enable UEFI secure boot. Defaults to `false`.
+	SecureBoot bool `mapstructure:"secure_boot"`
+	// The certificate of the platform key (PK) to enroll.
+	PlatformKey string `mapstructure:"secure_boot_pk"`
+	// The certificates of the key exchange keys (KEK) to enroll.
+	KeyExchangeKeys []string `mapstructure:"secure_boot_kek"`
+	// The certificates of the signature database (db) to enroll, the
+	// certificates signing the bootloaders allowed to run.
+	Signatures []string `mapstructure:"secure_boot_db"`
+	// The forbidden signature database (dbx) to enroll: certificates, or the
+	// SHA-256 hashes of forbidden binaries, in hex.
+	ForbiddenSignatures []string `mapstructure:"secure_boot_dbx"`
+	// The GUID of the owner of the enrolled keys. A random one is used when
+	// unset.
+	OwnerGUID string `mapstructure:"secure_boot_owner_guid"`
+}
+
+func (c *SecureBootConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+
+	if c.TPMStateDirectory != "" && !c.TPM {
+		errs = append(errs, fmt.Errorf("vtpm_state_directory requires vtpm"))
+	}
+
+	keys := len(c.KeyExchangeKeys) + len(c.Signatures) + len(c.ForbiddenSignatures)
+	if c.PlatformKey != "" {
+		keys++
+	}
+	if keys > 0 && !c.SecureBoot {
+		errs = append(errs, fmt.Errorf("secure_boot_pk, secure_boot_kek, secure_boot_db and secure_boot_dbx require secure_boot"))
+	}
+	if keys > 0 && c.PlatformKey == "" {
+		errs = append(errs, fmt.Errorf("secure_boot_pk must be set to enroll secure boot keys"))
+	}
+
+	var certs []string
+	if c.PlatformKey != "" {
+		certs = append(certs, c.PlatformKey)
+	}
+	certs = append(certs, c.KeyExchangeKeys...)
+	certs = append(certs, c.Signatures...)
+	for _, path := range certs {
+		if _, err := readCertificates(path); err != nil {
+			errs = append(errs, fmt.Errorf("Bad secure boot certificate %s: %s", path, err))
+		}
+	}
+	for _, entry := range c.ForbiddenSignatures {
+		if isSHA256Hex(entry) {
+			continue
+		}
+		if _, err := readCertificates(entry); err != nil {
+			errs = append(errs, fmt.Errorf("Bad secure_boot_dbx entry %s: not a SHA-256 hash, and %s", entry, err))
+		}
+	}
+
+	if c.OwnerGUID != "" {
+		if _, err := parseGUID(c.OwnerGUID); err != nil {
+			errs = append(errs, fmt.Errorf("Bad secure_boot_owner_guid: %s", err))
+		}
+	}
+
+	if c.TPMStateDirectory != "" {
+		if info, err := os.Stat(c.TPMStateDirectory); err == nil && !info.IsDir() {
+			errs = append(errs, fmt.Errorf("vtpm_state_directory %s is not a directory", c.TPMStateDirectory))
+		}
+	}
+
+	return errs
+}
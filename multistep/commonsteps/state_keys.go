@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The keys of the state bag that Packer, the builders and the common steps
+// share by convention. Reading them with the typed getters below, rather than
+// asserting the type of state.Get, doesn't panic when a value is missing.
+const (
+	// StateUi holds the packersdk.Ui of the build.
+	StateUi = "ui"
+	// StateHook holds the packersdk.Hook running the provisioners of the
+	// build.
+	StateHook = "hook"
+	// StateCommunicator holds the packersdk.Communicator connected to the
+	// machine being built, once the communicator step ran.
+	StateCommunicator = "communicator"
+	// StateInstanceID holds the ID of the machine being built, exposed to
+	// provisioners as the ID build variable.
+	StateInstanceID = "instance_id"
+	// StateError holds the error that halted the build. The runner and the
+	// cleanup of the steps tell a failed build with it.
+	StateError = "error"
+)
+
+// GetUi returns the Ui of state. ok is false if there is none.
+func GetUi(state multistep.StateBag) (ui packersdk.Ui, ok bool) {
+	ui, ok = state.Get(StateUi).(packersdk.Ui)
+	return ui, ok
+}
+
+// PutUi sets the Ui of state.
+func PutUi(state multistep.StateBag, ui packersdk.Ui) {
+	state.Put(StateUi, ui)
+}
+
+// GetHook returns the Hook of state. ok is false if there is none.
+func GetHook(state multistep.StateBag) (hook packersdk.Hook, ok bool) {
+	hook, ok = state.Get(StateHook).(packersdk.Hook)
+	return hook, ok
+}
+
+// PutHook sets the Hook of state.
+func PutHook(state multistep.StateBag, hook packersdk.Hook) {
+	state.Put(StateHook, hook)
+}
+
+// GetCommunicator returns the Communicator of state. ok is false if there is
+// none, for example before the communicator step ran or when the
+// communicator type is "none".
+func GetCommunicator(state multistep.StateBag) (comm packersdk.Communicator, ok bool) {
+	comm, ok = state.Get(StateCommunicator).(packersdk.Communicator)
+	return comm, ok
+}
+
+// PutCommunicator sets the Communicator of state.
+func PutCommunicator(state multistep.StateBag, comm packersdk.Communicator) {
+	state.Put(StateCommunicator, comm)
+}
+
+// GetInstanceID returns the ID of the machine being built. IDs that builders
+// put as another type than string, like a number, are formatted with
+// fmt.Sprint. ok is false if there is none.
+func GetInstanceID(state multistep.StateBag) (id string, ok bool) {
+	raw, ok := state.GetOk(StateInstanceID)
+	if !ok || raw == nil {
+		return "", false
+	}
+	if id, ok := raw.(string); ok {
+		return id, true
+	}
+	return fmt.Sprint(raw), true
+}
+
+// PutInstanceID sets the ID of the machine being built.
+func PutInstanceID(state multistep.StateBag, id string) {
+	state.Put(StateInstanceID, id)
+}
+
+// GetError returns the error that halted the build, or nil.
+func GetError(state multistep.StateBag) error {
+	err, _ := state.Get(StateError).(error)
+	return err
+}
+
+// PutError sets the error that halts the build. A step putting it returns
+// multistep.ActionHalt.
+func PutError(state multistep.StateBag, err error) {
+	state.Put(StateError, err)
+}
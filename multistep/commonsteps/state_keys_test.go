@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStateKeys(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	if _, ok := GetUi(state); ok {
+		t.Fatal("there should be no ui")
+	}
+	if _, ok := GetHook(state); ok {
+		t.Fatal("there should be no hook")
+	}
+	if _, ok := GetCommunicator(state); ok {
+		t.Fatal("there should be no communicator")
+	}
+	if _, ok := GetInstanceID(state); ok {
+		t.Fatal("there should be no instance id")
+	}
+	if err := GetError(state); err != nil {
+		t.Fatalf("there should be no error, got %s", err)
+	}
+
+	ui := new(packersdk.MockUi)
+	PutUi(state, ui)
+	hook := new(packersdk.MockHook)
+	PutHook(state, hook)
+	comm := new(packersdk.MockCommunicator)
+	PutCommunicator(state, comm)
+	PutInstanceID(state, "i-1234")
+	PutError(state, errors.New("boom"))
+
+	if got, ok := GetUi(state); !ok || got != ui || state.Get("ui") != ui {
+		t.Fatalf("bad ui: %#v", got)
+	}
+	if got, ok := GetHook(state); !ok || got != hook || state.Get("hook") != hook {
+		t.Fatalf("bad hook: %#v", got)
+	}
+	if got, ok := GetCommunicator(state); !ok || got != comm || state.Get("communicator") != comm {
+		t.Fatalf("bad communicator: %#v", got)
+	}
+	if id, ok := GetInstanceID(state); !ok || id != "i-1234" || state.Get("instance_id") != "i-1234" {
+		t.Fatalf("bad instance id: %q", id)
+	}
+	if err := GetError(state); err == nil || err.Error() != "boom" || state.Get("error") != err {
+		t.Fatalf("bad error: %v", err)
+	}
+
+	// Values of the wrong type are reported missing rather than panic.
+	state.Put(StateCommunicator, "ssh")
+	if _, ok := GetCommunicator(state); ok {
+		t.Fatal("a string is not a communicator")
+	}
+	state.Put(StateInstanceID, 42)
+	if id, ok := GetInstanceID(state); !ok || id != "42" {
+		t.Fatalf("bad instance id: %q", id)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepAssertGuest checks the assertions of an AssertConfig on the guest, and
+// halts the build with the list of the failed ones. It is meant to run at
+// the end of provisioning, after StepProvision.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui packersdk.Ui
+type StepAssertGuest struct {
+	Config *AssertConfig
+	// GuestOSType is the guestexec OS type of the guest, guestexec.UnixOSType
+	// when empty.
+	GuestOSType string
+}
+
+func (s *StepAssertGuest) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config == nil || s.Config.empty() {
+		return multistep.ActionContinue
+	}
+	ui := state.Get(StateUi).(packersdk.Ui)
+	comm, ok := state.Get(StateCommunicator).(packersdk.Communicator)
+	if !ok {
+		log.Printf("[WARN] No communicator to check the guest assertions with")
+		return multistep.ActionContinue
+	}
+
+	osType := s.GuestOSType
+	if osType == "" {
+		osType = guestexec.DefaultOSType
+	}
+	commands, err := guestexec.NewGuestCommands(osType, false)
+	if err != nil {
+		state.Put(StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(i18n.T("Checking the guest assertions..."))
+	if err := s.Config.Assertions().Check(ctx, comm, commands); err != nil {
+		state.Put(StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepAssertGuest) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,50 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestAssertConfigPrepare(t *testing.T) {
+	c := &AssertConfig{AssertFiles: []string{"/etc/hosts"}, AssertServices: []string{"sshd"}}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	c = &AssertConfig{AssertFiles: []string{""}, AssertPackages: []string{"it's"}}
+	if errs := c.Prepare(nil); len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %#v", errs)
+	}
+}
+
+func TestStepAssertGuest(t *testing.T) {
+	commands, _ := guestexec.NewGuestCommands(guestexec.UnixOSType, false)
+	state := testState(t)
+	state.Put("communicator", &guestComm{outputs: map[string]string{
+		commands.StatPath("/etc/hosts"): "",
+	}})
+
+	step := &StepAssertGuest{Config: &AssertConfig{AssertFiles: []string{"/etc/hosts"}}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	step = &StepAssertGuest{Config: &AssertConfig{
+		AssertFiles:    []string{"/etc/hosts"},
+		AssertServices: []string{"nginx"},
+	}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "service not running: nginx") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/manifest"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepCaptureEnvironment records what the build runs with: the versions of
+// Packer, of the plugin and of the programs checked by StepCheckHostTools,
+// the host OS and architecture, and the digest of the template. Builders
+// return it from the State of their artifact under
+// manifest.EnvironmentStateKey, with manifest.Environment.Map, and record it
+// in the manifest of the build with manifest.Build.SetEnvironment, so that
+// an image can be traced back to what produced it. It must come after
+// StepCheckHostTools for the versions of the programs to be recorded.
+//
+// Uses:
+//
+//	host_tool_versions map[string]string
+//
+// Produces:
+//
+//	build_environment *manifest.Environment - The environment of the build.
+type StepCaptureEnvironment struct {
+	PackerConfig common.PackerConfig
+	// PluginName and PluginVersion are the name and version of the plugin
+	// of the builder, like "packer-plugin-qemu" and version.PluginVersion's
+	// String.
+	PluginName    string
+	PluginVersion string
+	// TemplatePath is the template of the build, a file or a directory, as
+	// given to the builder in `packer_template_path`. Its digest is not
+	// recorded when it is empty.
+	TemplatePath string
+}
+
+func (s *StepCaptureEnvironment) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	env := manifest.NewEnvironment(s.PackerConfig)
+	if s.PluginName != "" {
+		env.AddPlugin(s.PluginName, s.PluginVersion)
+	}
+	if versions, ok := state.GetOk("host_tool_versions"); ok {
+		env.AddTools(versions.(map[string]string))
+	}
+	if s.TemplatePath != "" {
+		// The template is only traced; not finding it doesn't fail the
+		// build.
+		if err := env.SetTemplate(s.TemplatePath); err != nil {
+			log.Printf("[WARN] Error digesting the template %s: %s", s.TemplatePath, err)
+		}
+	}
+	state.Put(manifest.EnvironmentStateKey, env)
+	return multistep.ActionContinue
+}
+
+func (s *StepCaptureEnvironment) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/manifest"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCaptureEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test programs are shell scripts")
+	}
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	testHostTool(t, dir, "qemu-img", "qemu-img version 8.2.2 (Debian 1:8.2.2+ds-0ubuntu1)")
+	testHostTool(t, dir, "xorriso", "xorriso 1.5.6 : RockRidge filesystem manipulator")
+	template := filepath.Join(t.TempDir(), "build.pkr.hcl")
+	os.WriteFile(template, []byte("build {}"), 0644)
+
+	state := testState(t)
+	steps := []multistep.Step{
+		&StepCheckHostTools{
+			Tools:          []HostTool{{Name: "qemu-img", Constraint: ">= 6.0"}, {Name: "xorriso"}},
+			RecordVersions: true,
+		},
+		&StepCaptureEnvironment{
+			PackerConfig:  common.PackerConfig{PackerCoreVersion: "1.11.0"},
+			PluginName:    "packer-plugin-qemu",
+			PluginVersion: "1.1.0",
+			TemplatePath:  template,
+		},
+	}
+	for _, step := range steps {
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action %#v: %s", action, state.Get("error"))
+		}
+	}
+
+	env := state.Get(manifest.EnvironmentStateKey).(*manifest.Environment)
+	if env.ToolVersions["qemu-img"] != "8.2.2" || env.ToolVersions["xorriso"] != "1.5.6" {
+		t.Fatalf("bad tool versions: %#v", env.ToolVersions)
+	}
+	if env.PackerVersion != "1.11.0" || env.PluginVersions["packer-plugin-qemu"] != "1.1.0" || env.HostOS != runtime.GOOS {
+		t.Fatalf("bad environment: %#v", env)
+	}
+	if expected, _ := manifest.TemplateDigest(template); env.TemplateDigest != expected {
+		t.Fatalf("bad template digest: %s", env.TemplateDigest)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// hostToolVersionTimeout is how long the command printing the version of a
+// host tool can take.
+const hostToolVersionTimeout = 10 * time.Second
+
+// defaultVersionRe matches the first version number in the output of a
+// tool, like `8.2.2` in `qemu-img version 8.2.2 (Debian 1:8.2.2+ds-0)`.
+var defaultVersionRe = regexp.MustCompile(`(\d+\.\d+(?:\.\d+)*)`)
+
+// HostTool is a program a build runs on the host, like `qemu-img`.
+type HostTool struct {
+	// Name is the name of the program, looked up in PATH.
+	Name string
+	// Alternatives are programs that can be used instead of Name, in order
+	// of preference, like `mkisofs` for `xorriso`.
+	Alternatives []string
+	// Constraint, if set, is the versions of the program supported, as a
+	// constraint like `>= 4.2` or `>= 4.2, < 5`.
+	Constraint string
+	// VersionArgs are the arguments making the program print its version,
+	// `--version` when empty.
+	VersionArgs []string
+	// VersionPattern is a regular expression whose first group matches the
+	// version in what the program prints, the first number like `1.2.3`
+	// when empty.
+	VersionPattern string
+	// InstallHints tell how to install the program on the OS they are keyed
+	// by, like `linux` or `darwin`. The hint keyed by "" is for the others.
+	InstallHints map[string]string
+}
+
+// names returns the programs that can be used, in order of preference.
+func (t *HostTool) names() []string {
+	return append([]string{t.Name}, t.Alternatives...)
+}
+
+// installHint returns the install hint for the OS of the host, or "".
+func (t *HostTool) installHint() string {
+	if hint, ok := t.InstallHints[runtime.GOOS]; ok {
+		return hint
+	}
+	return t.InstallHints[""]
+}
+
+// Check returns the path of the first program of t found in PATH, checking
+// its version when t has a constraint.
+func (t *HostTool) Check(ctx context.Context) (string, error) {
+	path, _, err := t.check(ctx)
+	return path, err
+}
+
+// check is Check, also returning the version of the program when it was
+// checked.
+func (t *HostTool) check(ctx context.Context) (string, *version.Version, error) {
+	var constraint version.Constraints
+	if t.Constraint != "" {
+		var err error
+		if constraint, err = version.NewConstraint(t.Constraint); err != nil {
+			return "", nil, fmt.Errorf("%s: invalid version constraint %q: %s", t.Name, t.Constraint, err)
+		}
+	}
+
+	names := t.names()
+	var path, name string
+	for _, name = range names {
+		if p, err := exec.LookPath(name); err == nil {
+			path = p
+			break
+		}
+	}
+	if path == "" {
+		msg := i18n.Sprintf("%s was not found in PATH", strings.Join(names, " or "))
+		return "", nil, &HostToolError{Tool: t, Message: msg}
+	}
+	if constraint == nil {
+		return path, nil, nil
+	}
+
+	v, err := t.version(ctx, path)
+	if err != nil {
+		return "", nil, &HostToolError{Tool: t, Message: i18n.Sprintf("%s: the version can't be checked: %s", name, err)}
+	}
+	if !constraint.Check(v) {
+		msg := i18n.Sprintf("%s %s is installed, but version %s is required", name, v, t.Constraint)
+		return "", nil, &HostToolError{Tool: t, Message: msg}
+	}
+	return path, v, nil
+}
+
+func (t *HostTool) version(ctx context.Context, path string) (*version.Version, error) {
+	re := defaultVersionRe
+	if t.VersionPattern != "" {
+		var err error
+		if re, err = regexp.Compile(t.VersionPattern); err != nil {
+			return nil, fmt.Errorf("invalid version pattern: %s", err)
+		}
+	}
+	args := t.VersionArgs
+	if len(args) == 0 {
+		args = []string{"--version"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hostToolVersionTimeout)
+	defer cancel()
+	// Some programs print their version on stderr, or exit with an error
+	// after printing it.
+	out, runErr := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	m := re.FindSubmatch(out)
+	if len(m) < 2 {
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, fmt.Errorf("no version found in %q", strings.TrimSpace(string(out)))
+	}
+	return version.NewVersion(string(m[1]))
+}
+
+// HostToolError tells why a HostTool can't be used.
+type HostToolError struct {
+	Tool    *HostTool
+	Message string
+}
+
+func (e *HostToolError) Error() string {
+	if hint := e.Tool.installHint(); hint != "" {
+		return i18n.Sprintf("%s. To install it: %s", e.Message, hint)
+	}
+	return e.Message
+}
+
+// CheckHostTools checks tools, and returns the path of each by name, or an
+// error listing all the missing ones with how to install them.
+func CheckHostTools(ctx context.Context, tools []HostTool) (map[string]string, error) {
+	paths, _, err := checkHostTools(ctx, tools, false)
+	return paths, err
+}
+
+// checkHostTools is CheckHostTools, also returning the version of each tool
+// by name. With allVersions, the versions of the tools without a constraint
+// are looked up too, and left out when they can't be found.
+func checkHostTools(ctx context.Context, tools []HostTool, allVersions bool) (map[string]string, map[string]string, error) {
+	paths := map[string]string{}
+	versions := map[string]string{}
+	var missing []string
+	for i := range tools {
+		path, v, err := tools[i].check(ctx)
+		if err != nil {
+			missing = append(missing, "* "+err.Error())
+			continue
+		}
+		paths[tools[i].Name] = path
+		if v == nil && allVersions {
+			v, _ = tools[i].version(ctx, path)
+		}
+		if v != nil {
+			versions[tools[i].Name] = v.String()
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, i18n.Errorf("Some programs required on this host are missing:\n\n%s", strings.Join(missing, "\n"))
+	}
+	return paths, versions, nil
+}
+
+// StepCheckHostTools checks that the programs the build runs on the host are
+// installed, in supported versions, before anything is created. It halts
+// with the list of the missing ones, and how to install them.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	host_tools map[string]string - The path of each tool by name, which is
+//	the path of the alternative found when the tool itself is not.
+//	host_tool_versions map[string]string - The version of each tool by
+//	name, for the tools whose version was checked, or all of them with
+//	RecordVersions.
+type StepCheckHostTools struct {
+	Tools []HostTool
+	// RecordVersions looks up the versions of the tools without a
+	// constraint too, for StepCaptureEnvironment to record them.
+	RecordVersions bool
+}
+
+func (s *StepCheckHostTools) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Tools) == 0 {
+		return multistep.ActionContinue
+	}
+	ui := state.Get(StateUi).(packersdk.Ui)
+	ui.Say(i18n.T("Checking the programs required on this host..."))
+
+	paths, versions, err := checkHostTools(ctx, s.Tools, s.RecordVersions)
+	if err != nil {
+		state.Put(StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("host_tools", paths)
+	state.Put("host_tool_versions", versions)
+	return multistep.ActionContinue
+}
+
+func (s *StepCheckHostTools) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// testHostTool writes a program printing output to dir.
+func testHostTool(t *testing.T, dir, name, output string) {
+	script := "#!/bin/sh\necho '" + output + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStepCheckHostTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test programs are shell scripts")
+	}
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	testHostTool(t, dir, "qemu-img", "qemu-img version 8.2.2 (Debian 1:8.2.2+ds-0ubuntu1)")
+	testHostTool(t, dir, "mkisofs", "mkisofs 2.01 is not what you think it is")
+	testHostTool(t, dir, "ovftool", "VMware ovftool 4.4.3 (build-18663434)")
+
+	step := &StepCheckHostTools{Tools: []HostTool{
+		{Name: "qemu-img", Constraint: ">= 6.0"},
+		{Name: "xorriso", Alternatives: []string{"mkisofs"}},
+		{Name: "ovftool", Constraint: "~> 4.4", VersionPattern: `ovftool ([\d.]+)`},
+	}}
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action %#v: %s", action, state.Get("error"))
+	}
+	paths := state.Get("host_tools").(map[string]string)
+	if paths["xorriso"] != filepath.Join(dir, "mkisofs") || paths["qemu-img"] != filepath.Join(dir, "qemu-img") {
+		t.Fatalf("bad paths: %#v", paths)
+	}
+
+	step = &StepCheckHostTools{Tools: []HostTool{
+		{Name: "qemu-img", Constraint: ">= 9.0", InstallHints: map[string]string{runtime.GOOS: "upgrade qemu"}},
+		{Name: "virt-customize", InstallHints: map[string]string{"": "install libguestfs-tools"}},
+		{Name: "ovftool"},
+	}}
+	state = testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error).Error()
+	for _, want := range []string{
+		"* qemu-img 8.2.2 is installed, but version >= 9.0 is required. To install it: upgrade qemu",
+		"* virt-customize was not found in PATH. To install it: install libguestfs-tools",
+	} {
+		if !strings.Contains(err, want) {
+			t.Errorf("expected %q in error:\n%s", want, err)
+		}
+	}
+	if strings.Contains(err, "ovftool") {
+		t.Errorf("ovftool is installed:\n%s", err)
+	}
+}
+
+func TestHostTool_Check_noVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test programs are shell scripts")
+	}
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	testHostTool(t, dir, "tool", "no version here")
+
+	tool := &HostTool{Name: "tool", Constraint: ">= 1"}
+	if _, err := tool.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "no version found") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tool = &HostTool{Name: "tool", Constraint: "latest"}
+	if _, err := tool.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid version constraint") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -34,12 +35,12 @@ func (s *StepCleanupTempKeys) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionContinue
 	}
 
-	comm := state.Get("communicator").(packersdk.Communicator)
-	ui := state.Get("ui").(packersdk.Ui)
+	comm := state.Get(StateCommunicator).(packersdk.Communicator)
+	ui := state.Get(StateUi).(packersdk.Ui)
 
 	cmd := new(packersdk.RemoteCmd)
 
-	ui.Say("Trying to remove ephemeral keys from authorized_keys files")
+	ui.Say(i18n.T("Trying to remove ephemeral keys from authorized_keys files"))
 
 	// Per the OpenSSH manual (https://man.openbsd.org/sshd.8), a typical
 	// line in the 'authorized_keys' file contains several fields that
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepCollectLogs collects the logs of the guest into a gzipped tarball when
+// the build fails, so that the failure can be triaged once the guest is
+// gone. It does nothing when the build succeeds. The logs are collected
+// during cleanup, so the step must come after the step connecting to the
+// guest, and before the guest is provisioned to catch its failures.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	log_bundle string - The path of the bundle, when one was written.
+type StepCollectLogs struct {
+	// Bundle describes the logs to collect, guestexec.DefaultLogBundle when
+	// nil.
+	Bundle *guestexec.LogBundle
+	// GuestOSType is the guestexec OS type of the guest, guestexec.UnixOSType
+	// when empty.
+	GuestOSType string
+	// Path is where the bundle is written. It must not be in an output
+	// directory deleted on failure, like the one of StepOutputDir. It
+	// defaults to `packer-logs-<timestamp>.tar.gz` in the current directory.
+	Path string
+	// Timeout bounds the collection, 5 minutes when unset.
+	Timeout time.Duration
+}
+
+func (s *StepCollectLogs) Run(context.Context, multistep.StateBag) multistep.StepAction {
+	return multistep.ActionContinue
+}
+
+func (s *StepCollectLogs) Cleanup(state multistep.StateBag) {
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+	comm, ok := state.Get(StateCommunicator).(packersdk.Communicator)
+	if !ok {
+		return
+	}
+	ui := state.Get(StateUi).(packersdk.Ui)
+
+	bundle := s.Bundle
+	if bundle == nil {
+		osType := s.GuestOSType
+		if osType == "" {
+			osType = guestexec.DefaultOSType
+		}
+		bundle = guestexec.DefaultLogBundle(osType)
+	}
+	path := s.Path
+	if path == "" {
+		path = fmt.Sprintf("packer-logs-%d.tar.gz", time.Now().Unix())
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+
+	ui.Say(i18n.T("Collecting the guest logs..."))
+	// The build context may be cancelled already.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := writeLogBundle(ctx, bundle, comm, path); err != nil {
+		ui.Error(i18n.Sprintf("Error collecting the guest logs: %s", err))
+		return
+	}
+	ui.Say(i18n.Sprintf("Guest logs written to %s", path))
+	state.Put("log_bundle", path)
+}
+
+// writeLogBundle writes the bundle to a temporary file renamed to path once
+// complete, not to leave a truncated tarball behind.
+func writeLogBundle(ctx context.Context, bundle *guestexec.LogBundle, comm packersdk.Communicator, path string) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := bundle.Collect(ctx, comm, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
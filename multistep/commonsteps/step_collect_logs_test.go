@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepCollectLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "bundle.tar.gz")
+	state := testState(t)
+	state.Put("communicator", &packersdk.MockCommunicator{DownloadData: "log"})
+	step := &StepCollectLogs{
+		Bundle: &guestexec.LogBundle{Files: []string{"/var/log/cloud-init.log"}},
+		Path:   path,
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("logs should not be collected when the build succeeds: %v", err)
+	}
+
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+	if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+		t.Fatalf("the bundle should be written: %v", err)
+	}
+	if state.Get("log_bundle") != path {
+		t.Fatalf("bad log_bundle: %#v", state.Get("log_bundle"))
+	}
+	if tmp, _ := filepath.Glob(path + ".*.tmp"); len(tmp) > 0 {
+		t.Fatalf("temporary files left: %#v", tmp)
+	}
+}
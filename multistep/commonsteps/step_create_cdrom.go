@@ -14,6 +14,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/shell-local/localexec"
@@ -40,8 +41,8 @@ func (s *StepCreateCD) Run(ctx context.Context, state multistep.StateBag) multis
 		return multistep.ActionContinue
 	}
 
-	ui := state.Get("ui").(packersdk.Ui)
-	ui.Say("Creating CD disk...")
+	ui := state.Get(StateUi).(packersdk.Ui)
+	ui.Say(i18n.T("Creating CD disk..."))
 
 	if s.Label == "" {
 		s.Label = "packer"
@@ -56,8 +57,8 @@ func (s *StepCreateCD) Run(ctx context.Context, state multistep.StateBag) multis
 	CDF.Close()
 	os.Remove(CDPath)
 	if err != nil {
-		state.Put("error",
-			fmt.Errorf("Error creating temporary file for CD: %s", err))
+		state.Put(StateError,
+			i18n.Errorf("Error creating temporary file for CD: %s", err))
 		return multistep.ActionHalt
 	}
 
@@ -68,8 +69,8 @@ func (s *StepCreateCD) Run(ctx context.Context, state multistep.StateBag) multis
 	// "root" directory.
 	rootFolder, err := tmp.Dir("packer_to_cdrom")
 	if err != nil {
-		state.Put("error",
-			fmt.Errorf("Error creating temporary file for CD: %s", err))
+		state.Put(StateError,
+			i18n.Errorf("Error creating temporary file for CD: %s", err))
 		return multistep.ActionHalt
 	}
 	s.rootFolder = rootFolder
@@ -77,8 +78,8 @@ func (s *StepCreateCD) Run(ctx context.Context, state multistep.StateBag) multis
 	for _, toAdd := range s.Files {
 		err = s.AddFile(rootFolder, toAdd)
 		if err != nil {
-			state.Put("error",
-				fmt.Errorf("Error creating temporary file for CD: %s", err))
+			state.Put(StateError,
+				i18n.Errorf("Error creating temporary file for CD: %s", err))
 			return multistep.ActionHalt
 		}
 	}
@@ -86,31 +87,31 @@ func (s *StepCreateCD) Run(ctx context.Context, state multistep.StateBag) multis
 	for path, content := range s.Content {
 		err = s.AddContent(rootFolder, path, content)
 		if err != nil {
-			state.Put("error",
-				fmt.Errorf("Error creating temporary file for CD: %s", err))
+			state.Put(StateError,
+				i18n.Errorf("Error creating temporary file for CD: %s", err))
 			return multistep.ActionHalt
 		}
 	}
 
 	cmd, err := retrieveCDISOCreationCommand(s.Label, rootFolder, CDPath)
 	if err != nil {
-		state.Put("error", err)
+		state.Put(StateError, err)
 		return multistep.ActionHalt
 	}
 
 	err = localexec.RunAndStream(cmd, ui, []string{})
 	if err != nil {
-		state.Put("error", err)
+		state.Put(StateError, err)
 		return multistep.ActionHalt
 	}
 
-	ui.Message("Done copying paths from CD_dirs")
+	ui.Message(i18n.T("Done copying paths from CD_dirs"))
 
 	// Set the path to the CD so it can be used later
 	state.Put("cd_path", CDPath)
 
 	if err != nil {
-		state.Put("error", err)
+		state.Put(StateError, err)
 		return multistep.ActionHalt
 	}
 
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
@@ -46,14 +47,14 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 
 	s.FilesAdded = make(map[string]bool)
 
-	ui := state.Get("ui").(packersdk.Ui)
-	ui.Say("Creating floppy disk...")
+	ui := state.Get(StateUi).(packersdk.Ui)
+	ui.Say(i18n.T("Creating floppy disk..."))
 
 	// Create a temporary file to be our floppy drive
 	floppyF, err := tmp.File("packer")
 	if err != nil {
-		state.Put("error",
-			fmt.Errorf("Error creating temporary file for floppy: %s", err))
+		state.Put(StateError,
+			i18n.Errorf("Error creating temporary file for floppy: %s", err))
 		return multistep.ActionHalt
 	}
 	defer floppyF.Close()
@@ -65,7 +66,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 
 	// Set the size of the file to be a floppy sized
 	if err := floppyF.Truncate(1440 * 1024); err != nil {
-		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
+		state.Put(StateError, i18n.Errorf("Error creating floppy: %s", err))
 		return multistep.ActionHalt
 	}
 
@@ -73,7 +74,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	log.Println("Initializing block device backed by temporary file")
 	device, err := fs.NewFileDisk(floppyF)
 	if err != nil {
-		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
+		state.Put(StateError, i18n.Errorf("Error creating floppy: %s", err))
 		return multistep.ActionHalt
 	}
 
@@ -85,7 +86,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 		OEMName: s.Label,
 	}
 	if err := fat.FormatSuperFloppy(device, formatConfig); err != nil {
-		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
+		state.Put(StateError, i18n.Errorf("Error creating floppy: %s", err))
 		return multistep.ActionHalt
 	}
 
@@ -93,7 +94,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	log.Println("Initializing FAT filesystem on block device")
 	fatFs, err := fat.New(device)
 	if err != nil {
-		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
+		state.Put(StateError, i18n.Errorf("Error creating floppy: %s", err))
 		return multistep.ActionHalt
 	}
 
@@ -101,7 +102,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	log.Println("Reading the root directory from the filesystem")
 	rootDir, err := fatFs.RootDir()
 	if err != nil {
-		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
+		state.Put(StateError, i18n.Errorf("Error creating floppy: %s", err))
 		return multistep.ActionHalt
 	}
 	cache := fsDirectoryCache(rootDir)
@@ -132,7 +133,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 		}
 		if !info.IsDir() {
 			crawlDirectoryFiles = append(crawlDirectoryFiles, path)
-			ui.Message(fmt.Sprintf("Adding file: %s", path))
+			ui.Message(i18n.Sprintf("Adding file: %s", path))
 		}
 		return nil
 	}
@@ -142,7 +143,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	var filelist = make(chan string)
 	go globFiles(s.Files, filelist)
 
-	ui.Message("Copying files flatly from floppy_files")
+	ui.Message(i18n.T("Copying files flatly from floppy_files"))
 	for {
 		filename, ok := <-filelist
 		if !ok {
@@ -151,23 +152,23 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 
 		finfo, err := os.Stat(filename)
 		if err != nil {
-			state.Put("error", fmt.Errorf("Error trying to stat : %s : %s", filename, err))
+			state.Put(StateError, i18n.Errorf("Error trying to stat : %s : %s", filename, err))
 			return multistep.ActionHalt
 		}
 
 		// walk through directory adding files to the root of the fs
 		if finfo.IsDir() {
-			ui.Message(fmt.Sprintf("Copying directory: %s", filename))
+			ui.Message(i18n.Sprintf("Copying directory: %s", filename))
 
 			err := filepath.Walk(filename, crawlDirectory)
 			if err != nil {
-				state.Put("error", fmt.Errorf("Error adding file from floppy_files : %s : %s", filename, err))
+				state.Put(StateError, i18n.Errorf("Error adding file from floppy_files : %s : %s", filename, err))
 				return multistep.ActionHalt
 			}
 
 			for _, crawlfilename := range crawlDirectoryFiles {
 				if err = s.Add(cache, crawlfilename); err != nil {
-					state.Put("error", fmt.Errorf("Error adding file from floppy_files : %s : %s", filename, err))
+					state.Put(StateError, i18n.Errorf("Error adding file from floppy_files : %s : %s", filename, err))
 					return multistep.ActionHalt
 				}
 				s.FilesAdded[crawlfilename] = true
@@ -178,23 +179,23 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 		}
 
 		// add just a single file
-		ui.Message(fmt.Sprintf("Copying file: %s", filename))
+		ui.Message(i18n.Sprintf("Copying file: %s", filename))
 		if err = s.Add(cache, filename); err != nil {
-			state.Put("error", fmt.Errorf("Error adding file from floppy_files : %s : %s", filename, err))
+			state.Put(StateError, i18n.Errorf("Error adding file from floppy_files : %s : %s", filename, err))
 			return multistep.ActionHalt
 		}
 		s.FilesAdded[filename] = true
 	}
-	ui.Message("Done copying files from floppy_files")
+	ui.Message(i18n.T("Done copying files from floppy_files"))
 
 	// Collect all paths (expanding wildcards) into pathqueue
-	ui.Message("Collecting paths from floppy_dirs")
+	ui.Message(i18n.T("Collecting paths from floppy_dirs"))
 	var pathqueue []string
 	for _, filename := range s.Directories {
 		if strings.ContainsAny(filename, "*?[") {
 			matches, err := filepath.Glob(filename)
 			if err != nil {
-				state.Put("error", fmt.Errorf("Error adding path %s to floppy: %s", filename, err))
+				state.Put(StateError, i18n.Errorf("Error adding path %s to floppy: %s", filename, err))
 				return multistep.ActionHalt
 			}
 
@@ -203,30 +204,30 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 		}
 		pathqueue = append(pathqueue, filename)
 	}
-	ui.Message(fmt.Sprintf("Resulting paths from floppy_dirs : %v", pathqueue))
+	ui.Message(i18n.Sprintf("Resulting paths from floppy_dirs : %v", pathqueue))
 
 	// Go over each path in pathqueue and copy it.
 	for _, src := range pathqueue {
-		ui.Message(fmt.Sprintf("Recursively copying : %s", src))
+		ui.Message(i18n.Sprintf("Recursively copying : %s", src))
 		err = s.Add(cache, src)
 		if err != nil {
-			state.Put("error", fmt.Errorf("Error adding path %s to floppy: %s", src, err))
+			state.Put(StateError, i18n.Errorf("Error adding path %s to floppy: %s", src, err))
 			return multistep.ActionHalt
 		}
 	}
-	ui.Message("Done copying paths from floppy_dirs")
+	ui.Message(i18n.T("Done copying paths from floppy_dirs"))
 
 	// Collect files from floppy_content
-	ui.Message("Copying files from floppy_content")
+	ui.Message(i18n.T("Copying files from floppy_content"))
 	for path, content := range s.Content {
 		err = s.AddContent(cache, path, content)
 		if err != nil {
-			state.Put("error",
-				fmt.Errorf("Error creating file for floppy: %s", err))
+			state.Put(StateError,
+				i18n.Errorf("Error creating file for floppy: %s", err))
 			return multistep.ActionHalt
 		}
 	}
-	ui.Message("Done copying files from floppy_content")
+	ui.Message(i18n.T("Done copying files from floppy_content"))
 
 	// Set the path to the floppy so it can be used later
 	state.Put("floppy_path", s.floppyPath)
@@ -0,0 +1,243 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+// The families of the guest operating systems.
+const (
+	GuestOSFamilyLinux   = "linux"
+	GuestOSFamilyDarwin  = "darwin"
+	GuestOSFamilyFreeBSD = "freebsd"
+	GuestOSFamilyWindows = "windows"
+)
+
+// GuestOSGeneratedData are the names of the generated data StepDetectGuestOS
+// sets. Builders using the step should add them to the generated data they
+// return from Prepare.
+var GuestOSGeneratedData = []string{
+	"GuestOSFamily",
+	"GuestOSDistribution",
+	"GuestOSVersion",
+	"GuestOSArch",
+}
+
+// GuestOS describes the operating system of a guest.
+type GuestOS struct {
+	// Family is the kernel of the OS, one of the GuestOSFamily constants,
+	// or the lower case name printed by uname for the other Unix systems.
+	Family string
+	// Distribution is the ID of the distribution set in /etc/os-release,
+	// like `ubuntu` or `rhel`, and the family for the systems without one.
+	Distribution string
+	// Version is the version of the distribution, like `22.04`, or the one
+	// of the kernel for the systems without one, like `10.0.17763` for
+	// Windows.
+	Version string
+	// Name is the human readable name of the OS, like `Ubuntu 22.04.3 LTS`.
+	Name string
+	// Arch is the architecture of the guest, with the names of GOARCH, like
+	// `amd64` or `arm64`.
+	Arch string
+}
+
+// StepDetectGuestOS finds the operating system of the connected guest, so
+// that later steps and provisioners can depend on it without probing the
+// guest again.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	guest_os *GuestOS - The detected operating system.
+//	generated_data - The fields of the GuestOS, see GuestOSGeneratedData.
+type StepDetectGuestOS struct {
+	// Required halts the build when the OS of the guest cannot be detected.
+	// Otherwise the step only logs the error and continues.
+	Required bool
+}
+
+func (s *StepDetectGuestOS) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get(StateUi).(packersdk.Ui)
+	comm, ok := state.Get(StateCommunicator).(packersdk.Communicator)
+	if !ok {
+		log.Printf("[DEBUG] No communicator to detect the guest OS with")
+		return multistep.ActionContinue
+	}
+
+	ui.Say(i18n.T("Detecting the guest operating system..."))
+	guest, err := DetectGuestOS(ctx, comm)
+	if err != nil {
+		if s.Required {
+			err := i18n.Errorf("Error detecting the guest operating system: %s", err)
+			state.Put(StateError, err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		log.Printf("[WARN] Could not detect the guest operating system: %s", err)
+		return multistep.ActionContinue
+	}
+
+	ui.Message(i18n.Sprintf("Guest operating system: %s (%s/%s)", guest.Name, guest.Family, guest.Arch))
+	state.Put("guest_os", guest)
+	gd := &packerbuilderdata.GeneratedData{State: state}
+	gd.Put("GuestOSFamily", guest.Family)
+	gd.Put("GuestOSDistribution", guest.Distribution)
+	gd.Put("GuestOSVersion", guest.Version)
+	gd.Put("GuestOSArch", guest.Arch)
+	return multistep.ActionContinue
+}
+
+func (s *StepDetectGuestOS) Cleanup(multistep.StateBag) {}
+
+// DetectGuestOS probes the guest comm is connected to with uname and
+// /etc/os-release, or systeminfo when uname is not found.
+func DetectGuestOS(ctx context.Context, comm packersdk.Communicator) (*GuestOS, error) {
+	out, status, err := guestOutput(ctx, comm, "uname -s -m")
+	if err != nil {
+		return nil, err
+	}
+	if status == 0 {
+		guest, err := parseUname(out)
+		if err != nil {
+			return nil, err
+		}
+		// Systems without os-release, like macOS, keep the kernel version.
+		if release, status, err := guestOutput(ctx, comm, "cat /etc/os-release"); err == nil && status == 0 {
+			parseOSRelease(guest, release)
+		} else if version, status, err := guestOutput(ctx, comm, "uname -r"); err == nil && status == 0 {
+			guest.Version = strings.TrimSpace(version)
+		}
+		return guest, nil
+	}
+
+	out, status, err = guestOutput(ctx, comm, "systeminfo /fo csv /nh")
+	if err != nil {
+		return nil, err
+	}
+	if status != 0 {
+		return nil, fmt.Errorf("neither uname nor systeminfo could be run on the guest")
+	}
+	return parseSystemInfo(out)
+}
+
+func guestOutput(ctx context.Context, comm packersdk.Communicator, command string) (string, int, error) {
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{Command: command, Stdout: &stdout}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return "", 0, err
+	}
+	status := cmd.Wait()
+	log.Printf("[DEBUG] %q exited with status %d", command, status)
+	return stdout.String(), status, nil
+}
+
+// parseUname parses the output of uname -s -m, like "Linux x86_64".
+func parseUname(out string) (*GuestOS, error) {
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("unexpected output of uname: %q", out)
+	}
+	family := strings.ToLower(fields[0])
+	return &GuestOS{
+		Family:       family,
+		Distribution: family,
+		Name:         fields[0],
+		Arch:         normalizeArch(fields[1]),
+	}, nil
+}
+
+// parseOSRelease sets the distribution of guest from the content of
+// /etc/os-release.
+func parseOSRelease(guest *GuestOS, release string) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(release))
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(k, "#") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(v); err == nil {
+			v = unquoted
+		} else {
+			v = strings.Trim(v, `'"`)
+		}
+		values[k] = v
+	}
+
+	if id := values["ID"]; id != "" {
+		guest.Distribution = id
+	}
+	guest.Version = values["VERSION_ID"]
+	if name := values["PRETTY_NAME"]; name != "" {
+		guest.Name = name
+	} else if name := values["NAME"]; name != "" {
+		guest.Name = name
+	}
+}
+
+// parseSystemInfo parses the output of systeminfo /fo csv /nh. Its columns
+// are always in the same order, but their values are localized, hence the
+// arch is found from its prefix.
+func parseSystemInfo(out string) (*GuestOS, error) {
+	r := csv.NewReader(strings.NewReader(out))
+	r.FieldsPerRecord = -1
+	record, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unexpected output of systeminfo: %s", err)
+	}
+	if len(record) < 14 {
+		return nil, fmt.Errorf("unexpected output of systeminfo: %d columns", len(record))
+	}
+
+	// Like "10.0.17763 N/A Build 17763".
+	version := strings.Fields(record[2])
+	if len(version) == 0 {
+		return nil, fmt.Errorf("unexpected OS version in systeminfo: %q", record[2])
+	}
+	// Like "x64-based PC".
+	arch, _, _ := strings.Cut(record[13], "-")
+	return &GuestOS{
+		Family:       GuestOSFamilyWindows,
+		Distribution: GuestOSFamilyWindows,
+		Version:      version[0],
+		Name:         strings.TrimSpace(record[1]),
+		Arch:         normalizeArch(arch),
+	}, nil
+}
+
+// normalizeArch returns the GOARCH name of the architecture printed by
+// uname or systeminfo.
+func normalizeArch(arch string) string {
+	switch a := strings.ToLower(strings.TrimSpace(arch)); a {
+	case "x86_64", "amd64", "x64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "i386", "i486", "i586", "i686", "x86":
+		return "386"
+	default:
+		if strings.HasPrefix(a, "armv") {
+			return "arm"
+		}
+		return a
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// guestComm is a communicator printing the output of the commands it knows,
+// and failing the others.
+type guestComm struct {
+	packersdk.MockCommunicator
+	outputs map[string]string
+}
+
+func (c *guestComm) Start(ctx context.Context, rc *packersdk.RemoteCmd) error {
+	out, ok := c.outputs[rc.Command]
+	go func() {
+		if !ok {
+			rc.SetExited(127)
+			return
+		}
+		io.Copy(rc.Stdout, strings.NewReader(out))
+		rc.SetExited(0)
+	}()
+	return nil
+}
+
+const ubuntuOSRelease = `PRETTY_NAME="Ubuntu 22.04.3 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+VERSION="22.04.3 LTS (Jammy Jellyfish)"
+ID=ubuntu
+ID_LIKE=debian
+`
+
+func TestDetectGuestOS(t *testing.T) {
+	cases := map[string]struct {
+		outputs  map[string]string
+		expected GuestOS
+	}{
+		"linux": {
+			outputs: map[string]string{
+				"uname -s -m":         "Linux aarch64\n",
+				"cat /etc/os-release": ubuntuOSRelease,
+			},
+			expected: GuestOS{Family: "linux", Distribution: "ubuntu", Version: "22.04", Name: "Ubuntu 22.04.3 LTS", Arch: "arm64"},
+		},
+		"darwin": {
+			outputs: map[string]string{
+				"uname -s -m": "Darwin x86_64\n",
+				"uname -r":    "23.1.0\n",
+			},
+			expected: GuestOS{Family: "darwin", Distribution: "darwin", Version: "23.1.0", Name: "Darwin", Arch: "amd64"},
+		},
+		"windows": {
+			outputs: map[string]string{
+				"systeminfo /fo csv /nh": `"WIN-1","Microsoft Windows Server 2019 Datacenter","10.0.17763 N/A Build 17763","Microsoft Corporation","Standalone Server","Multiprocessor Free","Windows User","","00430-00000-00000-AA000","1/1/2024, 1:00:00 AM","1/1/2024, 1:00:00 AM","Amazon EC2","t3.medium","x64-based PC","1 Processor(s) Installed."` + "\r\n",
+			},
+			expected: GuestOS{Family: "windows", Distribution: "windows", Version: "10.0.17763", Name: "Microsoft Windows Server 2019 Datacenter", Arch: "amd64"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			guest, err := DetectGuestOS(context.Background(), &guestComm{outputs: tc.outputs})
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(*guest, tc.expected) {
+				t.Fatalf("bad guest OS: %#v", guest)
+			}
+		})
+	}
+}
+
+func TestStepDetectGuestOS(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &guestComm{outputs: map[string]string{
+		"uname -s -m":         "Linux x86_64\n",
+		"cat /etc/os-release": ubuntuOSRelease,
+	}})
+
+	step := new(StepDetectGuestOS)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if guest := state.Get("guest_os").(*GuestOS); guest.Distribution != "ubuntu" {
+		t.Fatalf("bad guest OS: %#v", guest)
+	}
+	generated := state.Get("generated_data").(map[string]interface{})
+	for _, k := range GuestOSGeneratedData {
+		if _, ok := generated[k]; !ok {
+			t.Errorf("%s should be in the generated data", k)
+		}
+	}
+	if generated["GuestOSArch"] != "amd64" {
+		t.Errorf("bad arch: %#v", generated["GuestOSArch"])
+	}
+}
+
+func TestStepDetectGuestOS_Required(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &guestComm{})
+
+	if action := new(StepDetectGuestOS).Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("detection failures should be ignored by default: %#v", action)
+	}
+
+	step := &StepDetectGuestOS{Required: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("error should be in the state bag")
+	}
+}
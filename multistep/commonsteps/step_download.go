@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -22,7 +23,9 @@ import (
 	urlhelper "github.com/hashicorp/go-getter/v2/helper/url"
 
 	"github.com/hashicorp/packer-plugin-sdk/filelock"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -58,6 +61,10 @@ type StepDownload struct {
 	// extension on the URL is used. Otherwise, this will be forced
 	// on the downloaded file for every URL.
 	Extension string
+
+	// Proxy, if set, is the proxy configuration of HTTP and HTTPS downloads,
+	// instead of the proxy environment variables.
+	Proxy *net.ProxyConfig
 }
 
 // defaultGetterReadTimeout is the read timeout for downloading operations via go-getter.
@@ -100,6 +107,24 @@ var defaultGetterClient = getter.Client{
 	},
 }
 
+// getterClient returns the client downloading with the proxies of the step.
+func (s *StepDownload) getterClient() *getter.Client {
+	if s.Proxy == nil {
+		return &defaultGetterClient
+	}
+	client := defaultGetterClient
+	client.Getters = make([]getter.Getter, len(defaultGetterClient.Getters))
+	for i, g := range defaultGetterClient.Getters {
+		if httpGetter, ok := g.(*getter.HttpGetter); ok {
+			proxied := *httpGetter
+			proxied.Client = &http.Client{Transport: s.Proxy.Transport()}
+			g = &proxied
+		}
+		client.Getters[i] = g
+	}
+	return &client
+}
+
 func (s *StepDownload) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	if len(s.Url) == 0 {
 		log.Printf("No URLs were provided to Step Download. Continuing...")
@@ -108,24 +133,24 @@ func (s *StepDownload) Run(ctx context.Context, state multistep.StateBag) multis
 
 	defer log.Printf("Leaving retrieve loop for %s", s.Description)
 
-	ui := state.Get("ui").(packersdk.Ui)
-	ui.Say(fmt.Sprintf("Retrieving %s", s.Description))
+	ui := state.Get(StateUi).(packersdk.Ui)
+	ui.Say(i18n.Sprintf("Retrieving %s", s.Description))
 
 	var errs []error
 
 	for _, source := range s.Url {
 		if ctx.Err() != nil {
-			state.Put("error", fmt.Errorf("Download cancelled: %v", errs))
+			state.Put(StateError, i18n.Errorf("Download cancelled: %v", errs))
 			return multistep.ActionHalt
 		}
-		ui.Say(fmt.Sprintf("Trying %s", source))
+		ui.Say(i18n.Sprintf("Trying %s", source))
 		var err error
 		var dst string
 		if s.Description == "OVF/OVA" && strings.HasSuffix(source, ".ovf") {
 			// TODO(adrien): make go-getter allow using files in place.
 			// ovf files usually point to a file in the same directory, so
 			// using them in place is the only way.
-			ui.Say("Using ovf inplace")
+			ui.Say(i18n.T("Using ovf inplace"))
 			dst = source
 		} else {
 			dst, err = s.download(ctx, ui, source)
@@ -140,8 +165,8 @@ func (s *StepDownload) Run(ctx context.Context, state multistep.StateBag) multis
 		errs = append(errs, err)
 	}
 
-	err := fmt.Errorf("error downloading %s: %v", s.Description, errs)
-	state.Put("error", err)
+	err := i18n.Errorf("error downloading %s: %v", s.Description, errs)
+	state.Put(StateError, err)
 	ui.Error(err.Error())
 	return multistep.ActionHalt
 }
@@ -239,28 +264,28 @@ func (s *StepDownload) download(ctx context.Context, ui packersdk.Ui, source str
 		}
 	}
 
-	ui.Say(fmt.Sprintf("Trying %s", u.String()))
+	ui.Say(i18n.Sprintf("Trying %s", u.String()))
 	req := &getter.Request{
 		Dst:              targetPath,
 		Src:              src,
-		ProgressListener: ui,
+		ProgressListener: packersdk.ProgressListener(ui),
 		Pwd:              wd,
 		GetMode:          getter.ModeFile,
 		Inplace:          true,
 	}
 
-	switch op, err := defaultGetterClient.Get(ctx, req); err.(type) {
+	switch op, err := s.getterClient().Get(ctx, req); err.(type) {
 	case nil: // success !
-		ui.Say(fmt.Sprintf("%s => %s", u.String(), op.Dst))
+		ui.Say(i18n.Sprintf("%s => %s", u.String(), op.Dst))
 		return op.Dst, nil
 	case *getter.ChecksumError:
-		ui.Say(fmt.Sprintf("Checksum did not match, removing %s", targetPath))
+		ui.Say(i18n.Sprintf("Checksum did not match, removing %s", targetPath))
 		if err := os.Remove(targetPath); err != nil {
-			ui.Error(fmt.Sprintf("Failed to remove cache file. Please remove manually: %s", targetPath))
+			ui.Error(i18n.Sprintf("Failed to remove cache file. Please remove manually: %s", targetPath))
 		}
 		return "", err
 	default:
-		ui.Say(fmt.Sprintf("Download failed %s", err))
+		ui.Say(i18n.Sprintf("Download failed %s", err))
 		return "", err
 	}
 }
@@ -0,0 +1,290 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/compression"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/iohelper"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// checksumTypes are the checksums StepExportArtifact can write next to the
+// files it exports.
+var checksumTypes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// StepExportArtifact writes the disk image of a build to the output
+// directory, converted to Format with the registered converters, compressed,
+// split in parts and with checksum files, as asked, showing the progress of
+// the copy.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	export_files []string - The files written, checksum files included.
+type StepExportArtifact struct {
+	// Source returns the path of the disk image and its format, like
+	// "qcow2" or "vmdk".
+	Source func(multistep.StateBag) (path, format string, err error)
+
+	// OutputDir is the directory to write to, and Name the name of the
+	// image, without extension.
+	OutputDir string
+	Name      string
+	// Format is the format to export to, the one of the source when empty.
+	Format string
+	// Compression is the name of a compression backend, like "gzip", "zstd"
+	// or "xz", "auto" to pick the fastest on this host, or empty not to
+	// compress.
+	Compression string
+	// CompressionLevel is the level of the backend, the default one when 0.
+	CompressionLevel int
+	// CompressionThreads is the number of threads compressing, the number
+	// of CPUs when 0.
+	CompressionThreads int
+	// SplitSize splits the image in parts of at most this many bytes,
+	// named with a ".000"-like suffix, when set.
+	SplitSize int64
+	// Checksum is the type of the checksum files written next to each file,
+	// "md5", "sha1", "sha256" or "sha512", in the format of sha256sum.
+	Checksum string
+
+	files []string
+}
+
+func (s *StepExportArtifact) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get(StateUi).(packersdk.Ui)
+	halt := func(err error) multistep.StepAction {
+		err = i18n.Errorf("Error exporting the image: %s", err)
+		state.Put(StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	var backend compression.Backend
+	if s.Compression != "" {
+		var ok bool
+		if backend, ok = compression.Lookup(s.Compression); !ok {
+			return halt(fmt.Errorf("unsupported compression %q", s.Compression))
+		}
+	}
+	if s.Checksum != "" && checksumTypes[s.Checksum] == nil {
+		return halt(fmt.Errorf("unsupported checksum type %q", s.Checksum))
+	}
+
+	src, from, err := s.Source(state)
+	if err != nil {
+		return halt(err)
+	}
+	to := s.Format
+	if to == "" {
+		to = from
+	}
+	if err := os.MkdirAll(s.OutputDir, 0755); err != nil {
+		return halt(err)
+	}
+
+	if to != from {
+		converter, ok := LookupConverter(from, to)
+		if !ok {
+			return halt(fmt.Errorf("no converter from %s to %s", from, to))
+		}
+		converted := filepath.Join(s.OutputDir, fmt.Sprintf(".%s-converted.%s", s.Name, to))
+		ui.Say(i18n.Sprintf("Converting the image from %s to %s...", from, to))
+		if err := converter.Convert(ctx, src, from, converted, to); err != nil {
+			os.Remove(converted)
+			return halt(err)
+		}
+		defer os.Remove(converted)
+		src = converted
+	}
+
+	name := s.Name + "." + to
+	if backend != nil {
+		name += backend.Extension()
+	}
+	ui.Say(i18n.Sprintf("Exporting the image to %s...", filepath.Join(s.OutputDir, name)))
+	if err := s.export(ctx, ui, src, filepath.Join(s.OutputDir, name), backend); err != nil {
+		return halt(err)
+	}
+
+	state.Put("export_files", append([]string(nil), s.files...))
+	return multistep.ActionContinue
+}
+
+// export copies src to dst, compressing it with backend when set, splitting
+// and checksumming it on the way, until ctx is done.
+func (s *StepExportArtifact) export(ctx context.Context, ui packersdk.Ui, src, dst string, backend compression.Backend) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r := ui.TrackProgress(filepath.Base(src), 0, info.Size(), f)
+	defer r.Close()
+
+	parts := &partWriter{base: dst, size: s.SplitSize, checksum: s.Checksum, files: &s.files}
+	var w io.WriteCloser = parts
+	if backend != nil {
+		opts := compression.Options{Level: s.CompressionLevel, Threads: s.CompressionThreads}
+		cw, err := backend.NewWriter(ctx, parts, opts)
+		if err != nil {
+			return err
+		}
+		w = &chainedCloser{WriteCloser: cw, next: parts}
+	}
+	if _, err := iohelper.CancellableCopy(ctx, w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *StepExportArtifact) Cleanup(state multistep.StateBag) {
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+	for _, file := range s.files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Printf("[WARN] Error removing %s: %s", file, err)
+		}
+	}
+	s.files = nil
+}
+
+// chainedCloser closes next after its WriteCloser, like a compressor and
+// the file it writes to.
+type chainedCloser struct {
+	io.WriteCloser
+	next io.Closer
+}
+
+func (c *chainedCloser) Close() error {
+	err := c.WriteCloser.Close()
+	if nextErr := c.next.Close(); err == nil {
+		err = nextErr
+	}
+	return err
+}
+
+// partWriter writes to base, or to parts of base of at most size bytes when
+// size is set, and writes the checksum of each file next to it. The files
+// written are appended to files, for them to be removed on failure.
+type partWriter struct {
+	base     string
+	size     int64
+	checksum string
+	files    *[]string
+
+	part    int
+	current *os.File
+	written int64
+	hash    hash.Hash
+}
+
+func (w *partWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if w.current == nil {
+			if err := w.next(); err != nil {
+				return n, err
+			}
+		}
+		chunk := p
+		if w.size > 0 && int64(len(chunk)) > w.size-w.written {
+			chunk = chunk[:w.size-w.written]
+		}
+		c, err := w.current.Write(chunk)
+		if w.hash != nil {
+			w.hash.Write(chunk[:c])
+		}
+		n += c
+		w.written += int64(c)
+		p = p[c:]
+		if err != nil {
+			return n, err
+		}
+		if w.size > 0 && w.written == w.size {
+			if err := w.finish(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// next opens the next file.
+func (w *partWriter) next() error {
+	path := w.base
+	if w.size > 0 {
+		path = fmt.Sprintf("%s.%03d", w.base, w.part)
+		w.part++
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	*w.files = append(*w.files, path)
+	w.current, w.written = f, 0
+	if w.checksum != "" {
+		w.hash = checksumTypes[w.checksum]()
+	}
+	return nil
+}
+
+// finish closes the current file and writes its checksum.
+func (w *partWriter) finish() error {
+	f := w.current
+	w.current = nil
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if w.hash == nil {
+		return nil
+	}
+	sumPath := f.Name() + "." + w.checksum
+	line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(w.hash.Sum(nil)), filepath.Base(f.Name()))
+	*w.files = append(*w.files, sumPath)
+	return os.WriteFile(sumPath, []byte(line), 0644)
+}
+
+func (w *partWriter) Close() error {
+	if w.current == nil && w.part == 0 && w.size == 0 {
+		// Nothing was written, create the empty file.
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+	if w.current == nil {
+		return nil
+	}
+	return w.finish()
+}
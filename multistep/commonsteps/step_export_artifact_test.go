@@ -0,0 +1,206 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/compression"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// upperConverter converts "lower" images to "upper" ones, uppercasing them.
+type upperConverter struct{}
+
+func (upperConverter) Converts(from, to string) bool { return from == "lower" && to == "upper" }
+
+func (upperConverter) Convert(_ context.Context, src, _, dst, _ string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, bytes.ToUpper(b), 0644)
+}
+
+func init() {
+	RegisterConverter("test-upper", upperConverter{})
+}
+
+func testExportSource(t *testing.T, content, format string) func(multistep.StateBag) (string, string, error) {
+	path := filepath.Join(t.TempDir(), "disk")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return func(multistep.StateBag) (string, string, error) { return path, format, nil }
+}
+
+func TestStepExportArtifact_impl(t *testing.T) {
+	var _ multistep.Step = new(StepExportArtifact)
+}
+
+func TestStepExportArtifact_convertSplitChecksum(t *testing.T) {
+	state := testState(t)
+	dir := t.TempDir()
+	step := &StepExportArtifact{
+		Source:    testExportSource(t, "abcdefghij", "lower"),
+		OutputDir: dir,
+		Name:      "image",
+		Format:    "upper",
+		SplitSize: 4,
+		Checksum:  "sha256",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %v", action, state.Get("error"))
+	}
+	defer step.Cleanup(state)
+
+	parts := []string{"ABCD", "EFGH", "IJ"}
+	var want []string
+	for i, content := range parts {
+		name := fmt.Sprintf("image.upper.%03d", i)
+		path := filepath.Join(dir, name)
+		want = append(want, path, path+".sha256")
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(b) != content {
+			t.Fatalf("bad content of %s: %q", name, b)
+		}
+		sum := sha256.Sum256([]byte(content))
+		b, err = os.ReadFile(path + ".sha256")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if expected := hex.EncodeToString(sum[:]) + "  " + name + "\n"; string(b) != expected {
+			t.Fatalf("bad checksum of %s: %q", name, b)
+		}
+	}
+	files := state.Get("export_files").([]string)
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Fatalf("bad files: %v", files)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("the converted image should be removed, found %d files", len(entries))
+	}
+}
+
+func TestStepExportArtifact_gzip(t *testing.T) {
+	state := testState(t)
+	dir := t.TempDir()
+	step := &StepExportArtifact{
+		Source:      testExportSource(t, "hello", "raw"),
+		OutputDir:   dir,
+		Name:        "image",
+		Compression: "gzip",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %v", action, state.Get("error"))
+	}
+
+	f, err := os.Open(filepath.Join(dir, "image.raw.gz"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("bad content: %q", b)
+	}
+}
+
+func TestStepExportArtifact_zstd(t *testing.T) {
+	state := testState(t)
+	dir := t.TempDir()
+	step := &StepExportArtifact{
+		Source:             testExportSource(t, "hello", "raw"),
+		OutputDir:          dir,
+		Name:               "image",
+		Compression:        "zstd",
+		CompressionThreads: 2,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %v", action, state.Get("error"))
+	}
+
+	f, err := os.Open(filepath.Join(dir, "image.raw.zst"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+	backend, _ := compression.Lookup("zstd")
+	r, err := backend.NewReader(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("bad content: %q", b)
+	}
+}
+
+func TestStepExportArtifact_noConverter(t *testing.T) {
+	state := testState(t)
+	step := &StepExportArtifact{
+		Source:    testExportSource(t, "hello", "raw"),
+		OutputDir: t.TempDir(),
+		Name:      "image",
+		Format:    "unknown",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have an error")
+	}
+}
+
+func TestStepExportArtifact_cleanupHalted(t *testing.T) {
+	state := testState(t)
+	dir := t.TempDir()
+	step := &StepExportArtifact{
+		Source:    testExportSource(t, "hello", "raw"),
+		OutputDir: dir,
+		Name:      "image",
+		Checksum:  "md5",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %v", action, state.Get("error"))
+	}
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+
+	for _, file := range state.Get("export_files").([]string) {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed", file)
+		}
+	}
+}
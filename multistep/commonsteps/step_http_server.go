@@ -13,9 +13,11 @@ import (
 	"sort"
 
 	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 func HTTPServerFromHTTPConfig(cfg *HTTPConfig) *StepHTTPServer {
@@ -26,6 +28,11 @@ func HTTPServerFromHTTPConfig(cfg *HTTPConfig) *StepHTTPServer {
 		HTTPPortMax:         cfg.HTTPPortMax,
 		HTTPAddress:         cfg.HTTPAddress,
 		HTTPNetworkProcotol: cfg.HTTPNetworkProtocol,
+
+		HTTPAdvertiseStrategy:  cfg.HTTPAdvertiseStrategy,
+		HTTPAdvertiseAddress:   cfg.HTTPAdvertiseAddress,
+		HTTPAdvertiseInterface: cfg.HTTPAdvertiseInterface,
+		HTTPAdvertiseRouteTo:   cfg.HTTPAdvertiseRouteTo,
 	}
 }
 
@@ -40,6 +47,9 @@ func HTTPServerFromHTTPConfig(cfg *HTTPConfig) *StepHTTPServer {
 // Produces:
 //
 //	http_port int - The port the HTTP server started on.
+//	http_ip string - The address of the HTTP server given to the guest,
+//	  when HTTPAdvertiseStrategy is set. Builders guessing the address
+//	  should keep this one.
 type StepHTTPServer struct {
 	HTTPDir             string
 	HTTPContent         map[string]string
@@ -48,6 +58,12 @@ type StepHTTPServer struct {
 	HTTPAddress         string
 	HTTPNetworkProcotol string
 
+	// See the http_advertise options of HTTPConfig.
+	HTTPAdvertiseStrategy  string
+	HTTPAdvertiseAddress   string
+	HTTPAdvertiseInterface string
+	HTTPAdvertiseRouteTo   string
+
 	l *net.Listener
 }
 
@@ -86,7 +102,7 @@ func (s MapServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	ui := state.Get("ui").(packersdk.Ui)
+	ui := state.Get(StateUi).(packersdk.Ui)
 
 	if s.HTTPDir == "" && len(s.HTTPContent) == 0 {
 		state.Put("http_port", 0)
@@ -95,8 +111,8 @@ func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) mult
 
 	if s.HTTPDir != "" {
 		if _, err := os.Stat(s.HTTPDir); err != nil {
-			err := fmt.Errorf("Error finding %q: %s", s.HTTPDir, err)
-			state.Put("error", err)
+			err := i18n.Errorf("Error finding %q: %s", s.HTTPDir, err)
+			state.Put(StateError, err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
@@ -112,13 +128,13 @@ func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) mult
 	}.Listen(ctx)
 
 	if err != nil {
-		err := fmt.Errorf("Error finding port: %s", err)
-		state.Put("error", err)
+		err := i18n.Errorf("Error finding port: %s", err)
+		state.Put(StateError, err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 
-	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", s.l.Port))
+	ui.Say(i18n.Sprintf("Starting HTTP server on port %d", s.l.Port))
 
 	// Start the HTTP server and run it in the background
 	server := &http.Server{Addr: "", Handler: s.Handler()}
@@ -127,12 +143,25 @@ func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) mult
 	// Save the address into the state so it can be accessed in the future
 	state.Put("http_port", s.l.Port)
 
+	ip, err := s.advertisedIP()
+	if err != nil {
+		err := i18n.Errorf("Error finding the address of the HTTP server: %s", err)
+		state.Put(StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if ip != "" {
+		log.Printf("[INFO] Advertising the HTTP server at %s (%s)", ip, s.HTTPAdvertiseStrategy)
+		state.Put("http_ip", ip)
+		(&packerbuilderdata.GeneratedData{State: state}).Put(HTTPIPGeneratedData, ip)
+	}
+
 	return multistep.ActionContinue
 }
 
 func (s *StepHTTPServer) Cleanup(state multistep.StateBag) {
 	if s.l != nil {
-		ui := state.Get("ui").(packersdk.Ui)
+		ui := state.Get(StateUi).(packersdk.Ui)
 
 		// Close the listener so that the HTTP server stops
 		if err := s.l.Close(); err != nil {
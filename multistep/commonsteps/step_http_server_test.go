@@ -85,3 +85,32 @@ func TestStepHTTPServer_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestStepHTTPServer_Advertise(t *testing.T) {
+	cfg := &HTTPConfig{HTTPContent: map[string]string{"/foo.txt": "biz"}, HTTPAdvertiseAddress: "10.0.2.2"}
+	if errs := cfg.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	s := HTTPServerFromHTTPConfig(cfg)
+	state := testState(t)
+	if action := s.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %s", action)
+	}
+	defer s.Cleanup(state)
+
+	if ip := state.Get("http_ip"); ip != "10.0.2.2" {
+		t.Fatalf("unexpected http_ip: %v", ip)
+	}
+	generated := state.Get("generated_data").(map[string]interface{})
+	if generated[HTTPIPGeneratedData] != "10.0.2.2" {
+		t.Fatalf("unexpected generated data: %#v", generated)
+	}
+
+	ip, err := (&StepHTTPServer{HTTPAdvertiseStrategy: HTTPAdvertiseRoute, HTTPAdvertiseRouteTo: "127.0.0.1"}).advertisedIP()
+	if err != nil || ip != "127.0.0.1" {
+		t.Fatalf("unexpected route address: %q %v", ip, err)
+	}
+	if _, err := (&StepHTTPServer{HTTPAdvertiseStrategy: HTTPAdvertiseInterface, HTTPAdvertiseInterface: "does-not-exist0"}).advertisedIP(); err == nil {
+		t.Fatal("unknown interfaces should fail")
+	}
+}
@@ -5,12 +5,12 @@ package commonsteps
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -26,19 +26,19 @@ type StepOutputDir struct {
 }
 
 func (s *StepOutputDir) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	ui := state.Get("ui").(packersdk.Ui)
+	ui := state.Get(StateUi).(packersdk.Ui)
 
 	if _, err := os.Stat(s.Path); err == nil {
 		if !s.Force {
-			err := fmt.Errorf(
+			err := i18n.Errorf(
 				"Output directory exists: %s\n\n"+
 					"Use the force flag to delete it prior to building.",
 				s.Path)
-			state.Put("error", err)
+			state.Put(StateError, err)
 			return multistep.ActionHalt
 		}
 
-		ui.Say("Deleting previous output directory...")
+		ui.Say(i18n.T("Deleting previous output directory..."))
 		os.RemoveAll(s.Path)
 	}
 
@@ -47,15 +47,15 @@ func (s *StepOutputDir) Run(ctx context.Context, state multistep.StateBag) multi
 
 	// Create the directory
 	if err := os.MkdirAll(s.Path, 0755); err != nil {
-		state.Put("error", err)
+		state.Put(StateError, err)
 		return multistep.ActionHalt
 	}
 
 	// Make sure we can write in the directory
 	f, err := os.Create(filepath.Join(s.Path, "_packer_perm_check"))
 	if err != nil {
-		err = fmt.Errorf("Couldn't write to output directory: %s", err)
-		state.Put("error", err)
+		err = i18n.Errorf("Couldn't write to output directory: %s", err)
+		state.Put(StateError, err)
 		return multistep.ActionHalt
 	}
 	f.Close()
@@ -73,9 +73,9 @@ func (s *StepOutputDir) Cleanup(state multistep.StateBag) {
 	_, halted := state.GetOk(multistep.StateHalted)
 
 	if cancelled || halted {
-		ui := state.Get("ui").(packersdk.Ui)
+		ui := state.Get(StateUi).(packersdk.Ui)
 
-		ui.Say("Deleting output directory...")
+		ui.Say(i18n.T("Deleting output directory..."))
 		for i := 0; i < 5; i++ {
 			err := os.RemoveAll(s.Path)
 			if err == nil {
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -47,7 +48,7 @@ func PopulateProvisionHookData(state multistep.StateBag) map[string]interface{}
 	// Implemented in most others including digitalOcean (droplet id),
 	// docker (container_id), and clouds which use "server" internally instead
 	// of instance.
-	id, ok := state.GetOk("instance_id")
+	id, ok := state.GetOk(StateInstanceID)
 	if ok {
 		hookData["ID"] = id
 	}
@@ -105,14 +106,14 @@ func (s *StepProvision) runWithHook(ctx context.Context, state multistep.StateBa
 	// hooktype will be either packersdk.HookProvision or packersdk.HookCleanupProvision
 	comm := s.Comm
 	if comm == nil {
-		raw, ok := state.Get("communicator").(packersdk.Communicator)
+		raw, ok := state.Get(StateCommunicator).(packersdk.Communicator)
 		if ok {
 			comm = raw
 		}
 	}
 
-	hook := state.Get("hook").(packersdk.Hook)
-	ui := state.Get("ui").(packersdk.Ui)
+	hook := state.Get(StateHook).(packersdk.Hook)
+	ui := state.Get(StateUi).(packersdk.Ui)
 
 	hookData := PopulateProvisionHookData(state)
 
@@ -125,7 +126,7 @@ func (s *StepProvision) runWithHook(ctx context.Context, state multistep.StateBa
 	if hooktype == packersdk.HookProvision {
 		log.Println("Running the provision hook")
 	} else if hooktype == packersdk.HookCleanupProvision {
-		ui.Say("Provisioning step had errors: Running the cleanup provisioner, if present...")
+		ui.Say(i18n.T("Provisioning step had errors: Running the cleanup provisioner, if present..."))
 	}
 	errCh := make(chan error, 1)
 	go func() {
@@ -139,10 +140,10 @@ func (s *StepProvision) runWithHook(ctx context.Context, state multistep.StateBa
 				if hooktype == packersdk.HookProvision {
 					// We don't overwrite the error if it's a cleanup
 					// provisioner being run.
-					state.Put("error", err)
+					state.Put(StateError, err)
 				} else if hooktype == packersdk.HookCleanupProvision {
-					origErr := state.Get("error").(error)
-					state.Put("error", fmt.Errorf("Cleanup failed: %s. "+
+					origErr := state.Get(StateError).(error)
+					state.Put(StateError, i18n.Errorf("Cleanup failed: %s. "+
 						"Original Provisioning error: %s", err, origErr))
 				}
 				return multistep.ActionHalt
@@ -169,7 +170,7 @@ func (s *StepProvision) Cleanup(state multistep.StateBag) {
 	// We have a "final" provisioner that gets defined by "error-cleanup-provisioner"
 	// which we only call if there's an error during the provision run and
 	// the "error-cleanup-provisioner" is defined.
-	if _, ok := state.GetOk("error"); ok {
+	if _, ok := state.GetOk(StateError); ok {
 		s.runWithHook(context.Background(), state, packersdk.HookCleanupProvision)
 	}
 }
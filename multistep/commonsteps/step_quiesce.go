@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepQuiesce runs the step of a builder taking a snapshot of the guest or
+// exporting its disks with the guest filesystems quiesced, so that the image
+// is consistent. The filesystems are thawed as soon as Step returns.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui packersdk.Ui
+type StepQuiesce struct {
+	// Quiescer quiesces the guest, see guestexec.NewQuiescer.
+	Quiescer guestexec.Quiescer
+	// Step takes the snapshot.
+	Step multistep.Step
+}
+
+// InnerStepName makes the DebugRunner name the wrapped step.
+func (s *StepQuiesce) InnerStepName() string {
+	if wrapped, ok := s.Step.(multistep.StepWrapper); ok {
+		return wrapped.InnerStepName()
+	}
+	return typeName(s.Step)
+}
+
+func (s *StepQuiesce) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get(StateUi).(packersdk.Ui)
+	comm, ok := state.Get(StateCommunicator).(packersdk.Communicator)
+	if !ok || s.Quiescer == nil {
+		log.Printf("[DEBUG] Not quiescing the guest: no communicator or quiescer")
+		return s.Step.Run(ctx, state)
+	}
+
+	ui.Say(i18n.T("Quiescing the guest filesystems..."))
+	defer func() {
+		// The context may be cancelled already, thawing must still happen.
+		if err := s.Quiescer.Thaw(context.Background(), comm); err != nil {
+			ui.Error(i18n.Sprintf("Error thawing the guest filesystems: %s", err))
+		}
+	}()
+	if err := s.Quiescer.Quiesce(ctx, comm); err != nil {
+		err := i18n.Errorf("Error quiescing the guest: %s", err)
+		state.Put(StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return s.Step.Run(ctx, state)
+}
+
+func (s *StepQuiesce) Cleanup(state multistep.StateBag) {
+	s.Step.Cleanup(state)
+}
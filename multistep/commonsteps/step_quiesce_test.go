@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type testQuiescer struct {
+	err    error
+	events *[]string
+}
+
+func (q *testQuiescer) Quiesce(context.Context, packersdk.Communicator) error {
+	*q.events = append(*q.events, "quiesce")
+	return q.err
+}
+
+func (q *testQuiescer) Thaw(context.Context, packersdk.Communicator) error {
+	*q.events = append(*q.events, "thaw")
+	return nil
+}
+
+type testStepSnapshot struct {
+	events *[]string
+}
+
+func (s *testStepSnapshot) Run(context.Context, multistep.StateBag) multistep.StepAction {
+	*s.events = append(*s.events, "snapshot")
+	return multistep.ActionContinue
+}
+
+func (s *testStepSnapshot) Cleanup(multistep.StateBag) {}
+
+func TestStepQuiesce(t *testing.T) {
+	var events []string
+	state := testState(t)
+	state.Put("communicator", new(packersdk.MockCommunicator))
+
+	step := &StepQuiesce{
+		Quiescer: &testQuiescer{events: &events},
+		Step:     &testStepSnapshot{events: &events},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(events) != 3 || events[0] != "quiesce" || events[1] != "snapshot" || events[2] != "thaw" {
+		t.Fatalf("bad events: %#v", events)
+	}
+	if name := step.InnerStepName(); name != "testStepSnapshot" {
+		t.Fatalf("bad name: %s", name)
+	}
+
+	events = nil
+	step.Quiescer = &testQuiescer{events: &events, err: errors.New("frozen")}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(events) != 2 || events[1] != "thaw" {
+		t.Fatalf("the snapshot should not be taken, and the guest thawed: %#v", events)
+	}
+}
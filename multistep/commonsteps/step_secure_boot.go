@@ -0,0 +1,285 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// SecureBootArtifacts are what StepSecureBoot generated for the hypervisor to
+// attach to the machine.
+type SecureBootArtifacts struct {
+	// TPMStateDir is the directory of the state of the TPM, empty when no
+	// TPM is attached. It is empty at first, for the TPM emulator to
+	// initialize it.
+	TPMStateDir string
+	// SecureBoot tells whether secure boot is enabled.
+	SecureBoot bool
+	// PK, KEK, DB and DBX are the EFI signature lists of the keys to
+	// enroll, nil when the hypervisor keeps its default keys.
+	PK, KEK, DB, DBX []byte
+	// PKPath, KEKPath, DBPath and DBXPath are the files of the signature
+	// lists, for the hypervisors taking files, empty when not enrolled.
+	PKPath, KEKPath, DBPath, DBXPath string
+}
+
+// StepSecureBoot generates the state of a virtual TPM and the secure boot keys
+// to enroll from a SecureBootConfig, and calls Attach for the builder to
+// attach them to the machine. It does nothing when neither a TPM nor secure
+// boot are enabled. The step must run after the machine is created, and
+// before it boots.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	secure_boot_artifacts *SecureBootArtifacts - What was attached.
+type StepSecureBoot struct {
+	Config *SecureBootConfig
+	// Attach attaches the artifacts to the machine, in the way of the
+	// hypervisor of the builder.
+	Attach func(context.Context, multistep.StateBag, *SecureBootArtifacts) error
+
+	tempDir string
+}
+
+func (s *StepSecureBoot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config == nil || (!s.Config.TPM && !s.Config.SecureBoot) {
+		log.Println("[DEBUG] No vTPM or secure boot to attach")
+		return multistep.ActionContinue
+	}
+	ui := state.Get(StateUi).(packersdk.Ui)
+
+	artifacts, err := s.generate()
+	if err != nil {
+		err := i18n.Errorf("Error generating the secure boot artifacts: %s", err)
+		state.Put(StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(i18n.T("Attaching vTPM and secure boot keys..."))
+	if err := s.Attach(ctx, state, artifacts); err != nil {
+		err := i18n.Errorf("Error attaching vTPM and secure boot keys: %s", err)
+		state.Put(StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("secure_boot_artifacts", artifacts)
+	return multistep.ActionContinue
+}
+
+func (s *StepSecureBoot) generate() (*SecureBootArtifacts, error) {
+	c := s.Config
+	artifacts := &SecureBootArtifacts{SecureBoot: c.SecureBoot}
+	needsTemp := (c.TPM && c.TPMStateDirectory == "") || (c.SecureBoot && c.PlatformKey != "")
+	if needsTemp {
+		dir, err := tmp.Dir("packer-secure-boot")
+		if err != nil {
+			return nil, err
+		}
+		s.tempDir = dir
+	}
+
+	if c.TPM {
+		artifacts.TPMStateDir = c.TPMStateDirectory
+		if artifacts.TPMStateDir == "" {
+			artifacts.TPMStateDir = filepath.Join(s.tempDir, "tpm")
+		}
+		if err := os.MkdirAll(artifacts.TPMStateDir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	if !c.SecureBoot || c.PlatformKey == "" {
+		return artifacts, nil
+	}
+	owner, err := ownerGUID(c.OwnerGUID)
+	if err != nil {
+		return nil, err
+	}
+	lists := []struct {
+		entries []string
+		list    *[]byte
+		path    *string
+		name    string
+	}{
+		{[]string{c.PlatformKey}, &artifacts.PK, &artifacts.PKPath, "PK.esl"},
+		{c.KeyExchangeKeys, &artifacts.KEK, &artifacts.KEKPath, "KEK.esl"},
+		{c.Signatures, &artifacts.DB, &artifacts.DBPath, "db.esl"},
+		{c.ForbiddenSignatures, &artifacts.DBX, &artifacts.DBXPath, "dbx.esl"},
+	}
+	for _, l := range lists {
+		if len(l.entries) == 0 {
+			continue
+		}
+		esl, err := SignatureList(owner, l.entries)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(s.tempDir, l.name)
+		if err := os.WriteFile(path, esl, 0600); err != nil {
+			return nil, err
+		}
+		*l.list, *l.path = esl, path
+	}
+	return artifacts, nil
+}
+
+func (s *StepSecureBoot) Cleanup(multistep.StateBag) {
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+		s.tempDir = ""
+	}
+}
+
+var (
+	// efiCertX509GUID is the type of the signatures of X.509 certificates.
+	efiCertX509GUID = mustParseGUID("a5c059a1-94e4-4aa7-87b5-ab155c2bf072")
+	// efiCertSHA256GUID is the type of the signatures of SHA-256 hashes.
+	efiCertSHA256GUID = mustParseGUID("c1c41626-504c-4092-aca9-41f936934328")
+)
+
+// SignatureList returns the EFI signature lists of entries, the files of
+// certificates, PEM or DER encoded, or SHA-256 hashes in hex, owned by owner.
+// Hashes share one list, and each certificate gets its own, certificates
+// having different sizes.
+func SignatureList(owner [16]byte, entries []string) ([]byte, error) {
+	var out bytes.Buffer
+	var hashes [][]byte
+	for _, entry := range entries {
+		if isSHA256Hex(entry) {
+			hash, _ := hex.DecodeString(entry)
+			hashes = append(hashes, hash)
+			continue
+		}
+		certs, err := readCertificates(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", entry, err)
+		}
+		for _, cert := range certs {
+			writeSignatureList(&out, efiCertX509GUID, owner, [][]byte{cert.Raw})
+		}
+	}
+	if len(hashes) > 0 {
+		writeSignatureList(&out, efiCertSHA256GUID, owner, hashes)
+	}
+	return out.Bytes(), nil
+}
+
+// writeSignatureList writes an EFI_SIGNATURE_LIST of signatures of the same
+// size.
+func writeSignatureList(w *bytes.Buffer, signatureType, owner [16]byte, signatures [][]byte) {
+	signatureSize := 16 + len(signatures[0])
+	w.Write(signatureType[:])
+	binary.Write(w, binary.LittleEndian, uint32(28+signatureSize*len(signatures)))
+	binary.Write(w, binary.LittleEndian, uint32(0))
+	binary.Write(w, binary.LittleEndian, uint32(signatureSize))
+	for _, signature := range signatures {
+		w.Write(owner[:])
+		w.Write(signature)
+	}
+}
+
+// readCertificates reads the certificates of the file path, PEM or DER
+// encoded.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		return x509.ParseCertificates(data)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
+
+func isSHA256Hex(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == 32
+}
+
+// ownerGUID returns the GUID s, or a random one when s is empty.
+func ownerGUID(s string) ([16]byte, error) {
+	if s != "" {
+		return parseGUID(s)
+	}
+	var guid [16]byte
+	if _, err := rand.Read(guid[:]); err != nil {
+		return guid, err
+	}
+	// Version 4, variant 1, with the first three fields little-endian.
+	guid[7] = guid[7]&0x0f | 0x40
+	guid[8] = guid[8]&0x3f | 0x80
+	return guid, nil
+}
+
+// parseGUID parses a GUID like "a5c059a1-94e4-4aa7-87b5-ab155c2bf072" to its
+// binary form, where the first three fields are little-endian.
+func parseGUID(s string) ([16]byte, error) {
+	var guid [16]byte
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return guid, fmt.Errorf("invalid GUID %q", s)
+	}
+	b, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		return guid, fmt.Errorf("invalid GUID %q", s)
+	}
+	copy(guid[:], b)
+	reverse := func(b []byte) {
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+	reverse(guid[0:4])
+	reverse(guid[4:6])
+	reverse(guid[6:8])
+	return guid, nil
+}
+
+func mustParseGUID(s string) [16]byte {
+	guid, err := parseGUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return guid
+}
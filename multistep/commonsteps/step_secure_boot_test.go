@@ -0,0 +1,161 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// testCertificate writes a self-signed certificate to dir, and returns its
+// path and DER encoding.
+func testCertificate(t *testing.T, dir, name string) (string, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return path, der
+}
+
+func TestParseGUID(t *testing.T) {
+	guid, err := parseGUID("a5c059a1-94e4-4aa7-87b5-ab155c2bf072")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []byte{0xa1, 0x59, 0xc0, 0xa5, 0xe4, 0x94, 0xa7, 0x4a, 0x87, 0xb5, 0xab, 0x15, 0x5c, 0x2b, 0xf0, 0x72}
+	if !bytes.Equal(guid[:], expected) {
+		t.Fatalf("bad GUID: %x", guid)
+	}
+	if _, err := parseGUID("a5c059a1-94e4-4aa7-87b5"); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestSecureBootConfigPrepare(t *testing.T) {
+	dir := t.TempDir()
+	pk, _ := testCertificate(t, dir, "pk")
+
+	c := &SecureBootConfig{SecureBoot: true, PlatformKey: pk, ForbiddenSignatures: []string{strings.Repeat("ab", 32)}}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+
+	c = &SecureBootConfig{
+		TPMStateDirectory: dir,
+		Signatures:        []string{filepath.Join(dir, "missing.pem")},
+		OwnerGUID:         "bogus",
+	}
+	// vtpm_state_directory without vtpm, keys without secure_boot nor
+	// secure_boot_pk, a missing certificate and a bad GUID.
+	if errs := c.Prepare(nil); len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got %v", errs)
+	}
+}
+
+func TestStepSecureBoot(t *testing.T) {
+	dir := t.TempDir()
+	pk, pkDER := testCertificate(t, dir, "pk")
+	kek, _ := testCertificate(t, dir, "kek")
+	hash := strings.Repeat("ab", 32)
+	owner := "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+	var attached *SecureBootArtifacts
+	step := &StepSecureBoot{
+		Config: &SecureBootConfig{
+			TPM:                 true,
+			SecureBoot:          true,
+			PlatformKey:         pk,
+			KeyExchangeKeys:     []string{kek},
+			ForbiddenSignatures: []string{hash},
+			OwnerGUID:           owner,
+		},
+		Attach: func(_ context.Context, _ multistep.StateBag, a *SecureBootArtifacts) error {
+			attached = a
+			return nil
+		},
+	}
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, %v", action, state.Get("error"))
+	}
+	if attached == nil || state.Get("secure_boot_artifacts") != attached {
+		t.Fatalf("artifacts not attached")
+	}
+	if info, err := os.Stat(attached.TPMStateDir); err != nil || !info.IsDir() {
+		t.Fatalf("bad TPM state directory %s: %v", attached.TPMStateDir, err)
+	}
+	if attached.DB != nil || attached.DBPath != "" {
+		t.Fatalf("unexpected db")
+	}
+
+	// PK is one X.509 signature list.
+	pkList := attached.PK
+	if !bytes.Equal(pkList[:16], efiCertX509GUID[:]) {
+		t.Fatalf("bad signature type: %x", pkList[:16])
+	}
+	if size := binary.LittleEndian.Uint32(pkList[16:20]); int(size) != len(pkList) || int(size) != 28+16+len(pkDER) {
+		t.Fatalf("bad list size %d for %d bytes", size, len(pkList))
+	}
+	ownerGUID := mustParseGUID(owner)
+	if !bytes.Equal(pkList[28:44], ownerGUID[:]) || !bytes.Equal(pkList[44:], pkDER) {
+		t.Fatalf("bad signature")
+	}
+	if data, err := os.ReadFile(attached.PKPath); err != nil || !bytes.Equal(data, pkList) {
+		t.Fatalf("bad PK file %s: %v", attached.PKPath, err)
+	}
+
+	// dbx holds the hash.
+	if !bytes.Equal(attached.DBX[:16], efiCertSHA256GUID[:]) || len(attached.DBX) != 28+16+32 {
+		t.Fatalf("bad dbx: %x", attached.DBX)
+	}
+
+	step.Cleanup(state)
+	if _, err := os.Stat(attached.PKPath); !os.IsNotExist(err) {
+		t.Fatalf("temporary files not removed: %v", err)
+	}
+}
+
+func TestStepSecureBoot_disabled(t *testing.T) {
+	step := &StepSecureBoot{
+		Config: new(SecureBootConfig),
+		Attach: func(context.Context, multistep.StateBag, *SecureBootArtifacts) error {
+			t.Fatalf("attach should not be called")
+			return nil
+		},
+	}
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+}
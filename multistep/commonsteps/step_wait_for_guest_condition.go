@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+const (
+	// DefaultGuestConditionTimeout is how long StepWaitForGuestCondition
+	// waits by default.
+	DefaultGuestConditionTimeout     = 10 * time.Minute
+	defaultGuestConditionInterval    = 2 * time.Second
+	defaultGuestConditionMaxInterval = 30 * time.Second
+)
+
+// StepWaitForGuestCondition runs a command on the guest until it succeeds,
+// waiting longer and longer between the tries, for example to wait for
+// cloud-init to finish with `cloud-init status --wait` or
+// `test -f /var/lib/cloud/instance/boot-finished`. The build halts when the
+// command still fails after Timeout, with its last output.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui packersdk.Ui
+type StepWaitForGuestCondition struct {
+	// Command is the command run on the guest; the condition is met once it
+	// exits with status 0.
+	Command string
+	// Description of the condition, like "cloud-init to finish", shown in
+	// the Ui. Command is shown when empty.
+	Description string
+	// Timeout is how long to wait for the condition, including the tries
+	// still running; DefaultGuestConditionTimeout when zero.
+	Timeout time.Duration
+	// Interval is the wait after the first failed try, 2s when zero. It
+	// doubles after each try, up to MaxInterval, 30s when zero.
+	Interval    time.Duration
+	MaxInterval time.Duration
+}
+
+func (s *StepWaitForGuestCondition) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Command == "" {
+		return multistep.ActionContinue
+	}
+	ui := state.Get(StateUi).(packersdk.Ui)
+	comm, ok := state.Get(StateCommunicator).(packersdk.Communicator)
+	if !ok {
+		log.Printf("[WARN] No communicator to wait for the guest condition with")
+		return multistep.ActionContinue
+	}
+
+	description := s.Description
+	if description == "" {
+		description = s.Command
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultGuestConditionTimeout
+	}
+	backoff := retry.Backoff{
+		InitialBackoff: s.Interval,
+		MaxBackoff:     s.MaxInterval,
+		Multiplier:     2,
+	}
+	if backoff.InitialBackoff <= 0 {
+		backoff.InitialBackoff = defaultGuestConditionInterval
+	}
+	if backoff.MaxBackoff <= 0 {
+		backoff.MaxBackoff = defaultGuestConditionMaxInterval
+	}
+
+	ui.Say(i18n.Sprintf("Waiting for %s...", description))
+	start := time.Now()
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var last string
+	for try := 1; ; try++ {
+		status, output, err := s.try(waitCtx, comm)
+		switch {
+		case err == nil && status == 0:
+			ui.Message(i18n.Sprintf("%s: done after %s", description, time.Since(start).Round(time.Second)))
+			return multistep.ActionContinue
+		case err != nil:
+			log.Printf("[DEBUG] Try %d of the guest condition failed: %s", try, err)
+			last = err.Error()
+		default:
+			log.Printf("[DEBUG] Try %d of the guest condition exited with status %d", try, status)
+			last = i18n.Sprintf("exit status %d", status)
+			if output != "" {
+				last += ": " + output
+			}
+		}
+
+		if waitCtx.Err() == nil {
+			wait := backoff.Linear()
+			ui.Message(i18n.Sprintf("Still waiting for %s (%s elapsed), trying again in %s", description, time.Since(start).Round(time.Second), wait))
+			select {
+			case <-time.After(wait):
+			case <-waitCtx.Done():
+			}
+		}
+		if waitCtx.Err() != nil {
+			if ctx.Err() != nil {
+				state.Put(StateError, ctx.Err())
+				return multistep.ActionHalt
+			}
+			err := i18n.Errorf("Timeout waiting for %s after %s, last try: %s", description, timeout, last)
+			state.Put(StateError, err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+}
+
+// try runs the command once, returning its exit status and its output.
+func (s *StepWaitForGuestCondition) try(ctx context.Context, comm packersdk.Communicator) (int, string, error) {
+	var out bytes.Buffer
+	cmd := &packersdk.RemoteCmd{Command: s.Command, Stdout: &out, Stderr: &out}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return 0, "", err
+	}
+	status := cmd.Wait()
+	return status, strings.TrimSpace(out.String()), nil
+}
+
+func (s *StepWaitForGuestCondition) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// flakyComm fails the commands it runs until its budget of failures is spent.
+type flakyComm struct {
+	packersdk.MockCommunicator
+	failures int32
+	tries    int32
+}
+
+func (c *flakyComm) Start(ctx context.Context, rc *packersdk.RemoteCmd) error {
+	atomic.AddInt32(&c.tries, 1)
+	go func() {
+		if atomic.AddInt32(&c.failures, -1) >= 0 {
+			rc.Stdout.Write([]byte("status: running\n"))
+			rc.SetExited(2)
+			return
+		}
+		rc.SetExited(0)
+	}()
+	return nil
+}
+
+func TestStepWaitForGuestCondition(t *testing.T) {
+	state := testState(t)
+	comm := &flakyComm{failures: 2}
+	state.Put("communicator", comm)
+
+	step := &StepWaitForGuestCondition{
+		Command:  "cloud-init status --wait",
+		Interval: time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %v", action, state.Get("error"))
+	}
+	if comm.tries != 3 {
+		t.Fatalf("expected 3 tries, got %d", comm.tries)
+	}
+}
+
+func TestStepWaitForGuestCondition_timeout(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &flakyComm{failures: 1 << 30})
+
+	step := &StepWaitForGuestCondition{
+		Command:     "test -f /var/lib/cloud/instance/boot-finished",
+		Description: "cloud-init to finish",
+		Timeout:     50 * time.Millisecond,
+		Interval:    5 * time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "Timeout waiting for cloud-init to finish") || !strings.Contains(err.Error(), "exit status 2: status: running") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
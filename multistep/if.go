@@ -3,6 +3,11 @@
 
 package multistep
 
+import (
+	"context"
+	"log"
+)
+
 // if returns step only if on is true.
 func If(on bool, step Step) Step {
 	if !on {
@@ -10,3 +15,42 @@ func If(on bool, step Step) Step {
 	}
 	return step
 }
+
+// SkipWhen returns a Step that runs step unless skip returns true. skip is
+// evaluated when the step is about to run, so it can look at values put in
+// the state bag by previous steps, or at the configuration it closes over.
+// When the step is skipped, the reason returned by skip is logged and the
+// Cleanup of step is not called, since it never ran.
+func SkipWhen(skip func(StateBag) (bool, string), step Step) Step {
+	return &conditionalStep{step: step, skip: skip}
+}
+
+// IfStep returns a Step that only runs step when cond returns true. reason
+// is logged when the step is skipped. See SkipWhen.
+func IfStep(cond func(StateBag) bool, reason string, step Step) Step {
+	return SkipWhen(func(state StateBag) (bool, string) {
+		return !cond(state), reason
+	}, step)
+}
+
+type conditionalStep struct {
+	step Step
+	skip func(StateBag) (bool, string)
+	ran  bool
+}
+
+func (s *conditionalStep) Run(ctx context.Context, state StateBag) StepAction {
+	if skip, reason := s.skip(state); skip {
+		log.Printf("[INFO] Skipping step %T: %s", s.step, reason)
+		return ActionContinue
+	}
+	s.ran = true
+	return s.step.Run(ctx, state)
+}
+
+func (s *conditionalStep) Cleanup(state StateBag) {
+	if !s.ran {
+		return
+	}
+	s.step.Cleanup(state)
+}
@@ -0,0 +1,38 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSkipWhen(t *testing.T) {
+	data := new(BasicStateBag)
+	data.Put("skip_b", true)
+
+	r := &BasicRunner{Steps: []Step{
+		&TestStepAcc{Data: "a"},
+		SkipWhen(func(state StateBag) (bool, string) {
+			_, skip := state.GetOk("skip_b")
+			return skip, "skip_b is set"
+		}, &TestStepAcc{Data: "b"}),
+		IfStep(func(state StateBag) bool {
+			return len(state.Get("data").([]string)) == 1
+		}, "a single step ran", &TestStepAcc{Data: "c"}),
+	}}
+	r.Run(context.Background(), data)
+
+	expected := []string{"a", "c"}
+	if results := data.Get("data").([]string); !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected result: %#v", results)
+	}
+
+	// Skipped steps must not be cleaned up.
+	expected = []string{"c", "a"}
+	if results := data.Get("cleanup").([]string); !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected cleanup: %#v", results)
+	}
+}
@@ -76,3 +76,21 @@ func (s TestStepInjectCancel) Run(ctx context.Context, state StateBag) StepActio
 }
 
 func (s TestStepInjectCancel) Cleanup(StateBag) {}
+
+// A step that panics in Run or Cleanup
+type TestStepPanic struct {
+	InCleanup bool
+}
+
+func (s TestStepPanic) Run(context.Context, StateBag) StepAction {
+	if !s.InCleanup {
+		panic("run panic")
+	}
+	return ActionContinue
+}
+
+func (s TestStepPanic) Cleanup(StateBag) {
+	if s.InCleanup {
+		panic("cleanup panic")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ParallelStep is a Step that runs independent steps concurrently, for
+// example downloading an image while a network is created, and only
+// continues once all of them are done.
+//
+// Each step runs with a fork of the state bag: it can read everything in the
+// parent state bag, but not what the other steps Put while they run. Once
+// all the steps are done, the values they Put are merged into the parent
+// state bag in the order of Steps, so that when two steps Put the same key,
+// the value of the last one wins whatever the order they finished in.
+//
+// The ParallelStep halts when any of its steps halts. The errors the steps
+// Put under the "error" key are then joined with errors.Join into a single
+// "error" in the parent state bag; when only one step failed, its error is
+// stored as is.
+type ParallelStep struct {
+	// Steps are the steps to run concurrently. nil steps are skipped.
+	Steps []Step
+
+	// FailFast cancels the context of the steps still running as soon as
+	// one of them halts, instead of waiting for them to finish.
+	FailFast bool
+
+	branches []*parallelBranch
+}
+
+// parallelBranch is a step run by a ParallelStep, with its fork of the state
+// bag.
+type parallelBranch struct {
+	step   Step
+	state  *namespacedStateBag
+	action StepAction
+}
+
+func (s *ParallelStep) Run(ctx context.Context, state StateBag) StepAction {
+	s.branches = nil
+	for _, step := range s.Steps {
+		if step == nil {
+			continue
+		}
+		s.branches = append(s.branches, &parallelBranch{
+			step:  step,
+			state: &namespacedStateBag{parent: state},
+		})
+	}
+	if ctx.Err() != nil {
+		s.branches = nil
+		return ActionHalt
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, b := range s.branches {
+		wg.Add(1)
+		go func(b *parallelBranch) {
+			defer wg.Done()
+			b.action = runStep(ctx, b.step, b.state)
+			if b.action == ActionHalt && s.FailFast {
+				log.Printf("[INFO] Step %T halted, cancelling the steps running in parallel", b.step)
+				cancel()
+			}
+		}(b)
+	}
+	wg.Wait()
+
+	return s.merge(state)
+}
+
+// merge copies the values the branches Put into state, and returns whether
+// the sequence should continue.
+func (s *ParallelStep) merge(state StateBag) StepAction {
+	action := ActionContinue
+	var errs []error
+	origins := make(map[string]Step)
+	for _, b := range s.branches {
+		if b.action == ActionHalt {
+			action = ActionHalt
+		}
+		for _, k := range b.state.keys() {
+			v := b.state.BasicStateBag.Get(k)
+			switch k {
+			case "error":
+				if err, ok := v.(error); ok {
+					errs = append(errs, err)
+				}
+				continue
+			case StateHalted, StateCancelled:
+				continue
+			}
+			if origin, ok := origins[k]; ok {
+				log.Printf("[WARN] Steps %T and %T both set %q, keeping the value of %T", origin, b.step, k, b.step)
+			}
+			origins[k] = b.step
+			state.Put(k, v)
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+	case 1:
+		state.Put("error", errs[0])
+	default:
+		state.Put("error", errors.Join(errs...))
+	}
+	return action
+}
+
+// Cleanup cleans up the steps that ran, in the reverse order of Steps. An
+// "error" a step Puts during its cleanup is copied to the parent state bag
+// unless there already is one.
+func (s *ParallelStep) Cleanup(state StateBag) {
+	for i := len(s.branches) - 1; i >= 0; i-- {
+		b := s.branches[i]
+		b.state.BasicStateBag.Remove("error")
+		cleanupStep(b.step, b.state)
+		if err, ok := b.state.BasicStateBag.GetOk("error"); ok {
+			if _, ok := state.GetOk("error"); !ok {
+				state.Put("error", err)
+			}
+		}
+	}
+	s.branches = nil
+}
+
+// keys returns the keys of the values Put in b itself, sorted.
+func (b *namespacedStateBag) keys() []string {
+	b.l.RLock()
+	defer b.l.RUnlock()
+	keys := make([]string, 0, len(b.data))
+	for k := range b.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParallelStep_impl(t *testing.T) {
+	var _ Step = new(ParallelStep)
+}
+
+// testStepBarrier waits for all the steps sharing its channel to be
+// running.
+type testStepBarrier struct {
+	key     string
+	arrived chan struct{}
+	n       int
+}
+
+func (s *testStepBarrier) Run(ctx context.Context, state StateBag) StepAction {
+	s.arrived <- struct{}{}
+	for len(s.arrived) < s.n {
+		select {
+		case <-ctx.Done():
+			return ActionHalt
+		default:
+		}
+	}
+	state.Put(s.key, true)
+	return ActionContinue
+}
+
+func (s *testStepBarrier) Cleanup(StateBag) {}
+
+func TestParallelStep_Run(t *testing.T) {
+	arrived := make(chan struct{}, 3)
+	data := new(BasicStateBag)
+	data.Put("region", "eu-west-1")
+	get := &testStepGet{key: "region"}
+
+	// The steps only finish once all of them are running.
+	step := &ParallelStep{Steps: []Step{
+		&testStepBarrier{key: "image", arrived: arrived, n: 3},
+		nil,
+		&testStepBarrier{key: "network", arrived: arrived, n: 3},
+		&testStepBarrier{key: "disk", arrived: arrived, n: 3},
+		get,
+	}}
+	if action := step.Run(context.Background(), data); action != ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	for _, k := range []string{"image", "network", "disk"} {
+		if _, ok := data.GetOk(k); !ok {
+			t.Errorf("%s should be merged in the state bag", k)
+		}
+	}
+	if get.value != "eu-west-1" {
+		t.Errorf("steps should see the parent state, got %#v", get.value)
+	}
+}
+
+func TestParallelStep_merge(t *testing.T) {
+	data := new(BasicStateBag)
+	step := &ParallelStep{Steps: []Step{
+		&testStepPut{key: "id", value: "first"},
+		&testStepPut{key: "id", value: "second"},
+	}}
+	for i := 0; i < 10; i++ {
+		step.Run(context.Background(), data)
+		if data.Get("id") != "second" {
+			t.Fatalf("the value of the last step should win, got %#v", data.Get("id"))
+		}
+	}
+}
+
+func TestParallelStep_errors(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+
+	data := new(BasicStateBag)
+	step := &ParallelStep{Steps: []Step{
+		&testStepPut{key: "error", value: errA},
+		&TestStepAcc{Data: "ok"},
+		&testStepPut{key: "error", value: errB},
+	}}
+	if action := step.Run(context.Background(), data); action != ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := data.Get("error").(error)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("both errors should be reported: %s", err)
+	}
+	if results := data.Get("data").([]string); !reflect.DeepEqual(results, []string{"ok"}) {
+		t.Errorf("the other steps should still run: %#v", results)
+	}
+
+	data = new(BasicStateBag)
+	step = &ParallelStep{Steps: []Step{&testStepPut{key: "error", value: errA}, &TestStepAcc{Data: "ok"}}}
+	step.Run(context.Background(), data)
+	if data.Get("error") != errA {
+		t.Fatalf("a single error should be kept as is: %#v", data.Get("error"))
+	}
+}
+
+func TestParallelStep_FailFast(t *testing.T) {
+	data := new(BasicStateBag)
+	step := &ParallelStep{
+		Steps: []Step{
+			// Would wait forever for a second step to arrive.
+			&testStepBarrier{key: "image", arrived: make(chan struct{}, 2), n: 2},
+			&TestStepAcc{Data: "a", Halt: true},
+		},
+		FailFast: true,
+	}
+	if action := step.Run(context.Background(), data); action != ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := data.GetOk("image"); ok {
+		t.Errorf("the running step should have been cancelled")
+	}
+}
+
+func TestParallelStep_Cleanup(t *testing.T) {
+	data := new(BasicStateBag)
+	step := &ParallelStep{Steps: []Step{
+		&TestStepAcc{Data: "a"},
+		TestStepPanic{InCleanup: true},
+	}}
+
+	r := &BasicRunner{Steps: []Step{step}}
+	r.Run(context.Background(), data)
+
+	if _, ok := data.GetOk("error"); !ok {
+		t.Errorf("the cleanup panic should be reported in the state bag")
+	}
+	if step.branches != nil {
+		t.Errorf("the steps should be forgotten once cleaned up")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+)
+
+// Policy tells how to run a step wrapped with WithPolicy.
+type Policy struct {
+	// Timeout, if set, cancels the context of each attempt of the step
+	// after this long. Steps must return when their context is done for
+	// the timeout to have an effect.
+	Timeout time.Duration
+
+	// Retries is the number of times the step is run again after it halts.
+	Retries int
+
+	// Backoff, if set, returns how long to wait before each retry, for
+	// example the Linear method of a retry.Backoff. Retries happen right
+	// away otherwise.
+	Backoff func() time.Duration
+
+	// ShouldRetry, if set, tells from the state bag of a halted attempt,
+	// with the "error" it Put, whether it should be retried.
+	ShouldRetry func(StateBag) bool
+
+	// BeforeRetry, if set, is called before each retry, after the failed
+	// attempt was cleaned up, to reset the state bag for example.
+	BeforeRetry func(StateBag)
+}
+
+// WithPolicy returns a Step running step according to policy: each attempt
+// is cancelled after the timeout, and an attempt that halts is cleaned up
+// and run again until the retries are exhausted. The "error" of the last
+// attempt is the one left in the state bag.
+func WithPolicy(step Step, policy Policy) Step {
+	return &policyStep{step: step, policy: policy}
+}
+
+type policyStep struct {
+	step   Step
+	policy Policy
+}
+
+// InnerStepName makes the DebugRunner name the wrapped step.
+func (s *policyStep) InnerStepName() string {
+	if wrapped, ok := s.step.(StepWrapper); ok {
+		return wrapped.InnerStepName()
+	}
+	return reflect.Indirect(reflect.ValueOf(s.step)).Type().Name()
+}
+
+func (s *policyStep) Run(ctx context.Context, state StateBag) StepAction {
+	for attempt := 1; ; attempt++ {
+		action := s.attempt(ctx, state)
+		if action == ActionContinue {
+			return action
+		}
+		if attempt > s.policy.Retries || ctx.Err() != nil {
+			return action
+		}
+		if _, ok := state.GetOk(StateCancelled); ok {
+			return action
+		}
+		if s.policy.ShouldRetry != nil && !s.policy.ShouldRetry(state) {
+			return action
+		}
+
+		err, _ := state.GetOk("error")
+		log.Printf("[INFO] Step %s halted (%v), retrying: attempt %d of %d", s.InnerStepName(), err, attempt+1, s.policy.Retries+1)
+		cleanupStep(s.step, state)
+		state.Remove("error")
+		if s.policy.BeforeRetry != nil {
+			s.policy.BeforeRetry(state)
+		}
+
+		if s.policy.Backoff != nil {
+			select {
+			case <-time.After(s.policy.Backoff()):
+			case <-ctx.Done():
+				return ActionHalt
+			}
+		}
+	}
+}
+
+// attempt runs the step once, within the timeout.
+func (s *policyStep) attempt(ctx context.Context, state StateBag) StepAction {
+	if s.policy.Timeout <= 0 {
+		return runStep(ctx, s.step, state)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, s.policy.Timeout)
+	defer cancel()
+	action := runStep(attemptCtx, s.step, state)
+	if action == ActionHalt && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		err := fmt.Errorf("step %s timed out after %s", s.InnerStepName(), s.policy.Timeout)
+		if stepErr, ok := state.Get("error").(error); ok {
+			err = fmt.Errorf("%s: %w", err, stepErr)
+		}
+		log.Printf("[ERROR] %s", err)
+		state.Put("error", err)
+	}
+	return action
+}
+
+func (s *policyStep) Cleanup(state StateBag) {
+	cleanupStep(s.step, state)
+}
@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStepFlaky halts with an error until it ran failures times.
+type testStepFlaky struct {
+	failures int
+	runs     int
+	cleanups int
+}
+
+func (s *testStepFlaky) Run(_ context.Context, state StateBag) StepAction {
+	s.runs++
+	if s.runs <= s.failures {
+		state.Put("error", errors.New("flaky"))
+		return ActionHalt
+	}
+	return ActionContinue
+}
+
+func (s *testStepFlaky) Cleanup(StateBag) { s.cleanups++ }
+
+func TestWithPolicy_Retries(t *testing.T) {
+	step := &testStepFlaky{failures: 2}
+	var resets int
+	waits := 0
+	data := new(BasicStateBag)
+	wrapped := WithPolicy(step, Policy{
+		Retries:     2,
+		Backoff:     func() time.Duration { waits++; return time.Millisecond },
+		BeforeRetry: func(StateBag) { resets++ },
+	})
+	if action := wrapped.Run(context.Background(), data); action != ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if step.runs != 3 || step.cleanups != 2 || resets != 2 || waits != 2 {
+		t.Fatalf("bad attempts: %#v, %d resets, %d waits", step, resets, waits)
+	}
+	if _, ok := data.GetOk("error"); ok {
+		t.Fatal("the error of the failed attempts should be removed")
+	}
+
+	step = &testStepFlaky{failures: 5}
+	data = new(BasicStateBag)
+	if action := WithPolicy(step, Policy{Retries: 1}).Run(context.Background(), data); action != ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if step.runs != 2 || data.Get("error").(error).Error() != "flaky" {
+		t.Fatalf("bad attempts: %#v, %v", step, data.Get("error"))
+	}
+}
+
+func TestWithPolicy_ShouldRetry(t *testing.T) {
+	step := &testStepFlaky{failures: 1}
+	wrapped := WithPolicy(step, Policy{
+		Retries:     3,
+		ShouldRetry: func(state StateBag) bool { return state.Get("error").(error).Error() != "flaky" },
+	})
+	if action := wrapped.Run(context.Background(), new(BasicStateBag)); action != ActionHalt || step.runs != 1 {
+		t.Fatalf("the error should not be retried: %#v, %d runs", action, step.runs)
+	}
+}
+
+// testStepWaitCtx waits for its context to be done.
+type testStepWaitCtx struct{}
+
+func (testStepWaitCtx) Run(ctx context.Context, state StateBag) StepAction {
+	<-ctx.Done()
+	state.Put("error", ctx.Err())
+	return ActionHalt
+}
+
+func (testStepWaitCtx) Cleanup(StateBag) {}
+
+func TestWithPolicy_Timeout(t *testing.T) {
+	data := new(BasicStateBag)
+	wrapped := WithPolicy(testStepWaitCtx{}, Policy{Timeout: 10 * time.Millisecond})
+	if action := wrapped.Run(context.Background(), data); action != ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := data.Get("error").(error)
+	if !strings.Contains(err.Error(), "timed out after 10ms") || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestWithPolicy_DebugName(t *testing.T) {
+	var names []string
+	r := &DebugRunner{
+		Steps: []Step{WithPolicy(&TestStepAcc{Data: "a"}, Policy{})},
+		PauseFn: func(loc DebugLocation, name string, state StateBag) {
+			names = append(names, name)
+		},
+	}
+	r.Run(context.Background(), new(BasicStateBag))
+	if !reflect.DeepEqual(names, []string{"TestStepAcc", "TestStepAcc"}) {
+		t.Fatalf("bad names: %#v", names)
+	}
+}
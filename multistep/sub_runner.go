@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import "context"
+
+// SubRunnerStep is a Step that runs a nested sequence of steps, so that a
+// group of steps, for example "attach a volume and wait for it", can be
+// packaged and reused as a single step.
+//
+// The nested steps run in order and stop at the first one that halts, just
+// like with a BasicRunner. By default they are cleaned up, in reverse order,
+// as soon as the sequence is over; set DeferCleanup to clean them up when the
+// SubRunnerStep itself is cleaned up instead.
+type SubRunnerStep struct {
+	// Steps is the sequence of steps to run. nil steps are skipped.
+	Steps []Step
+
+	// Namespace, if set, gives the nested steps a state bag of their own.
+	// They can still read everything in the parent state bag, but the
+	// values they Put are kept in the nested bag, which is stored in the
+	// parent bag under the Namespace key. An "error" set by a nested step
+	// is always copied to the parent bag.
+	Namespace string
+
+	// DeferCleanup delays the cleanup of the nested steps until Cleanup is
+	// called on the SubRunnerStep.
+	DeferCleanup bool
+
+	ran   []Step
+	state StateBag
+}
+
+func (s *SubRunnerStep) Run(ctx context.Context, state StateBag) StepAction {
+	s.state = state
+	if s.Namespace != "" {
+		s.state = &namespacedStateBag{parent: state}
+		state.Put(s.Namespace, s.state)
+	}
+	s.ran = nil
+
+	action := ActionContinue
+	for _, step := range s.Steps {
+		if step == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			action = ActionHalt
+			break
+		}
+
+		a := runStep(ctx, step, s.state)
+		s.ran = append(s.ran, step)
+
+		if _, ok := s.state.GetOk(StateCancelled); ok {
+			action = ActionHalt
+			break
+		}
+		if a == ActionHalt {
+			action = ActionHalt
+			break
+		}
+	}
+
+	if err, ok := s.state.GetOk("error"); ok && s.Namespace != "" {
+		state.Put("error", err)
+	}
+
+	if !s.DeferCleanup {
+		if action == ActionHalt {
+			s.state.Put(StateHalted, true)
+		}
+		s.cleanup()
+	}
+	return action
+}
+
+func (s *SubRunnerStep) Cleanup(StateBag) {
+	s.cleanup()
+}
+
+func (s *SubRunnerStep) cleanup() {
+	for i := len(s.ran) - 1; i >= 0; i-- {
+		cleanupStep(s.ran[i], s.state)
+	}
+	s.ran = nil
+}
+
+// namespacedStateBag is a StateBag that keeps its own values and falls back
+// to its parent for the keys it doesn't have.
+type namespacedStateBag struct {
+	BasicStateBag
+	parent StateBag
+}
+
+func (b *namespacedStateBag) Get(k string) interface{} {
+	result, _ := b.GetOk(k)
+	return result
+}
+
+func (b *namespacedStateBag) GetOk(k string) (interface{}, bool) {
+	if result, ok := b.BasicStateBag.GetOk(k); ok {
+		return result, ok
+	}
+	return b.parent.GetOk(k)
+}
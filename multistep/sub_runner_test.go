@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSubRunnerStep_impl(t *testing.T) {
+	var _ Step = new(SubRunnerStep)
+}
+
+func TestSubRunnerStep_Run(t *testing.T) {
+	data := new(BasicStateBag)
+	sub := &SubRunnerStep{Steps: []Step{
+		&TestStepAcc{Data: "b"},
+		nil,
+		&TestStepAcc{Data: "c"},
+	}}
+
+	r := &BasicRunner{Steps: []Step{&TestStepAcc{Data: "a"}, sub, &TestStepAcc{Data: "d"}}}
+	r.Run(context.Background(), data)
+
+	expected := []string{"a", "b", "c", "d"}
+	if results := data.Get("data").([]string); !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected result: %#v", results)
+	}
+
+	// The nested steps are cleaned up as soon as they are done.
+	expected = []string{"c", "b", "d", "a"}
+	if results := data.Get("cleanup").([]string); !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected cleanup: %#v", results)
+	}
+}
+
+func TestSubRunnerStep_DeferCleanup(t *testing.T) {
+	data := new(BasicStateBag)
+	sub := &SubRunnerStep{
+		Steps:        []Step{&TestStepAcc{Data: "b"}, &TestStepAcc{Data: "c"}},
+		DeferCleanup: true,
+	}
+
+	r := &BasicRunner{Steps: []Step{&TestStepAcc{Data: "a"}, sub, &TestStepAcc{Data: "d"}}}
+	r.Run(context.Background(), data)
+
+	expected := []string{"d", "c", "b", "a"}
+	if results := data.Get("cleanup").([]string); !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected cleanup: %#v", results)
+	}
+}
+
+func TestSubRunnerStep_Halt(t *testing.T) {
+	data := new(BasicStateBag)
+	sub := &SubRunnerStep{Steps: []Step{
+		&TestStepAcc{Data: "b", Halt: true},
+		&TestStepAcc{Data: "c"},
+	}}
+
+	r := &BasicRunner{Steps: []Step{&TestStepAcc{Data: "a"}, sub, &TestStepAcc{Data: "d"}}}
+	r.Run(context.Background(), data)
+
+	expected := []string{"a", "b"}
+	if results := data.Get("data").([]string); !reflect.DeepEqual(results, expected) {
+		t.Errorf("unexpected result: %#v", results)
+	}
+	if _, ok := data.GetOk(StateHalted); !ok {
+		t.Errorf("halted should be in state bag")
+	}
+}
+
+type testStepPut struct {
+	key   string
+	value interface{}
+}
+
+func (s *testStepPut) Run(_ context.Context, state StateBag) StepAction {
+	state.Put(s.key, s.value)
+	if s.key == "error" {
+		return ActionHalt
+	}
+	return ActionContinue
+}
+
+func (s *testStepPut) Cleanup(StateBag) {}
+
+type testStepGet struct {
+	key   string
+	value interface{}
+}
+
+func (s *testStepGet) Run(_ context.Context, state StateBag) StepAction {
+	s.value = state.Get(s.key)
+	return ActionContinue
+}
+
+func (s *testStepGet) Cleanup(StateBag) {}
+
+func TestSubRunnerStep_Namespace(t *testing.T) {
+	data := new(BasicStateBag)
+	data.Put("region", "eu-west-1")
+
+	get := &testStepGet{key: "region"}
+	sub := &SubRunnerStep{
+		Namespace: "volume",
+		Steps: []Step{
+			&testStepPut{key: "device", value: "/dev/sdf"},
+			&testStepPut{key: "region", value: "overridden"},
+			get,
+		},
+	}
+	if action := sub.Run(context.Background(), data); action != ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if get.value != "overridden" {
+		t.Errorf("nested steps should see their own values first, got %#v", get.value)
+	}
+	if data.Get("region") != "eu-west-1" {
+		t.Errorf("parent state should not be modified, got %#v", data.Get("region"))
+	}
+	if _, ok := data.GetOk("device"); ok {
+		t.Errorf("device should only be in the nested state")
+	}
+	if device := data.Get("volume").(StateBag).Get("device"); device != "/dev/sdf" {
+		t.Errorf("unexpected nested device: %#v", device)
+	}
+
+	err := errors.New("boom")
+	sub = &SubRunnerStep{
+		Namespace: "volume",
+		Steps:     []Step{&testStepPut{key: "error", value: err}},
+	}
+	if action := sub.Run(context.Background(), data); action != ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if data.Get("error") != err {
+		t.Errorf("error should be copied to the parent state")
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package naming
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config holds the naming options of a builder. Embed it in your builder
+// config using the `mapstructure:",squash"` struct tag, call Prepare with the
+// rules of your cloud from the Prepare of the builder and name resources with
+// the Generator.
+type Config struct {
+	// The pattern of the names of the resources the build creates, like
+	// temporary instances, security groups or key pairs. `{prefix}`,
+	// `{build_name}`, `{resource}`, `{timestamp}` and `{uuid}` are replaced
+	// by the prefix, the name of the build, the kind of resource, the time
+	// of the build and random characters. Names are adjusted to the rules
+	// of the cloud: forbidden characters are replaced with hyphens and long
+	// names are shortened, keeping their timestamp and random characters.
+	// Defaults to `{prefix}-{resource}-{uuid}`.
+	ResourceNamePattern string `mapstructure:"resource_name_pattern" required:"false"`
+	// The prefix of the names of the resources the build creates. Defaults
+	// to `packer`.
+	ResourceNamePrefix string `mapstructure:"resource_name_prefix" required:"false"`
+}
+
+// Prepare validates the pattern against rules, with a name generated the
+// way the names of the build will be.
+func (c *Config) Prepare(rules Rules) []error {
+	g := c.Generator("", rules)
+	// The timestamp and random characters are digits in the sample, for
+	// the patterns starting with them to fail whatever the time and luck.
+	if _, err := g.name("resource", "00000000"); err != nil {
+		return []error{fmt.Errorf("resource_name_pattern: %s", err)}
+	}
+	return nil
+}
+
+// Generator returns the generator of the names of the resources of the build
+// named buildName, started now.
+func (c *Config) Generator(buildName string, rules Rules) *Generator {
+	return &Generator{
+		Pattern:   c.ResourceNamePattern,
+		Prefix:    c.ResourceNamePrefix,
+		BuildName: buildName,
+		Time:      time.Now(),
+		Rules:     rules,
+	}
+}
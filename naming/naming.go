@@ -0,0 +1,299 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package naming generates the names of the resources builders create, like
+// temporary instances, security groups, key pairs or images, from patterns
+// users configure, and adjusts them to the naming rules of the cloud, so that
+// every builder names its resources the same way.
+package naming
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashicorp/packer-plugin-sdk/random"
+)
+
+// Rules are the constraints a cloud puts on the names of resources. Zero
+// values mean no constraint.
+type Rules struct {
+	// MinLength and MaxLength are counted in characters.
+	MinLength int
+	MaxLength int
+	// ValidChar tells whether a character is allowed in names; all
+	// characters are allowed when it is nil.
+	ValidChar func(r rune) bool
+	// ValidFirst and ValidLast tell whether a character can start and end
+	// a name; all valid characters can when they are nil.
+	ValidFirst func(r rune) bool
+	ValidLast  func(r rune) bool
+	// LowerCase is set when names must be lower case.
+	LowerCase bool
+}
+
+func isAlphaNum(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+var (
+	// AWSImage are the rules of the names of AWS AMIs.
+	AWSImage = Rules{
+		MinLength: 3,
+		MaxLength: 128,
+		ValidChar: func(r rune) bool {
+			return isAlphaNum(r) || strings.ContainsRune("()[] ./-'@_", r)
+		},
+	}
+
+	// Azure are the rules of the names of Azure images and most other
+	// resources of a resource group.
+	Azure = Rules{
+		MinLength: 1,
+		MaxLength: 80,
+		ValidChar: func(r rune) bool {
+			return isAlphaNum(r) || strings.ContainsRune("._-", r)
+		},
+		ValidFirst: isAlphaNum,
+		ValidLast: func(r rune) bool {
+			return isAlphaNum(r) || r == '_'
+		},
+	}
+
+	// GCP are the rules of the names of Google Cloud resources, from
+	// RFC 1035.
+	GCP = Rules{
+		MinLength: 1,
+		MaxLength: 63,
+		ValidChar: func(r rune) bool {
+			return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-'
+		},
+		ValidFirst: func(r rune) bool { return r >= 'a' && r <= 'z' },
+		ValidLast: func(r rune) bool {
+			return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+		},
+		LowerCase: true,
+	}
+)
+
+// replacement replaces the characters forbidden by the rules.
+const replacement = '-'
+
+// Normalize returns name adjusted to rules: lower cased if needed, with
+// forbidden characters replaced by hyphens, truncated to the maximum length
+// and without the trailing characters that can't end a name. What can't be
+// fixed, like a name too short, is left for Validate to report.
+func Normalize(name string, rules Rules) string {
+	name = rules.normalizeChars(name)
+	if rules.MaxLength > 0 && utf8.RuneCountInString(name) > rules.MaxLength {
+		name = string([]rune(name)[:rules.MaxLength])
+	}
+	return rules.trimEnd(name)
+}
+
+func (r Rules) normalizeChars(s string) string {
+	if r.LowerCase {
+		s = strings.ToLower(s)
+	}
+	if r.ValidChar != nil {
+		s = strings.Map(func(c rune) rune {
+			if r.ValidChar(c) {
+				return c
+			}
+			return replacement
+		}, s)
+	}
+	return s
+}
+
+func (r Rules) trimEnd(s string) string {
+	if r.ValidLast == nil {
+		return s
+	}
+	return strings.TrimRightFunc(s, func(c rune) bool { return !r.ValidLast(c) })
+}
+
+// Validate returns an error when name doesn't follow rules.
+func Validate(name string, rules Rules) error {
+	n := utf8.RuneCountInString(name)
+	switch {
+	case n == 0 || n < rules.MinLength:
+		return fmt.Errorf("name %q is shorter than %d characters", name, max(rules.MinLength, 1))
+	case rules.MaxLength > 0 && n > rules.MaxLength:
+		return fmt.Errorf("name %q is longer than %d characters", name, rules.MaxLength)
+	case rules.LowerCase && strings.ToLower(name) != name:
+		return fmt.Errorf("name %q must be lower case", name)
+	}
+	if rules.ValidChar != nil {
+		for _, c := range name {
+			if !rules.ValidChar(c) {
+				return fmt.Errorf("name %q contains the forbidden character %q", name, c)
+			}
+		}
+	}
+	first, _ := utf8.DecodeRuneInString(name)
+	if rules.ValidFirst != nil && !rules.ValidFirst(first) {
+		return fmt.Errorf("name %q must not start with %q", name, first)
+	}
+	last, _ := utf8.DecodeLastRuneInString(name)
+	if rules.ValidLast != nil && !rules.ValidLast(last) {
+		return fmt.Errorf("name %q must not end with %q", name, last)
+	}
+	return nil
+}
+
+// DefaultPattern is the pattern of the names when none is configured.
+const DefaultPattern = "{prefix}-{resource}-{uuid}"
+
+// DefaultPrefix is the {prefix} of the names when none is configured.
+const DefaultPrefix = "packer"
+
+// The placeholders of the patterns. {timestamp} and {uuid} make the names
+// unique, and are never truncated.
+var placeholders = map[string]bool{
+	"prefix":     false,
+	"build_name": false,
+	"resource":   false,
+	"timestamp":  true,
+	"uuid":       true,
+}
+
+// Generator generates the names of the resources of a build.
+type Generator struct {
+	// Pattern is the pattern of the names, DefaultPattern when empty. Its
+	// placeholders are replaced by:
+	//
+	//	{prefix}     - Prefix.
+	//	{build_name} - BuildName.
+	//	{resource}   - The kind of resource named, like "sg".
+	//	{timestamp}  - The time of the build, in seconds since the Unix epoch.
+	//	{uuid}       - 8 random hexadecimal characters, new for every name.
+	Pattern string
+	// Prefix is DefaultPrefix when empty.
+	Prefix    string
+	BuildName string
+	// Time is the time of the build, the time the name is generated when
+	// zero.
+	Time  time.Time
+	Rules Rules
+}
+
+// segment is a part of an expanded pattern.
+type segment struct {
+	value string
+	// unique segments are never truncated.
+	unique bool
+}
+
+func parsePattern(pattern string) ([]segment, error) {
+	var segments []segment
+	for pattern != "" {
+		i := strings.IndexByte(pattern, '{')
+		if i < 0 {
+			segments = append(segments, segment{value: pattern})
+			break
+		}
+		if i > 0 {
+			segments = append(segments, segment{value: pattern[:i]})
+		}
+		j := strings.IndexByte(pattern[i:], '}')
+		if j < 0 {
+			return nil, fmt.Errorf("unterminated placeholder in %q", pattern)
+		}
+		name := pattern[i+1 : i+j]
+		unique, ok := placeholders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s}, expected one of {prefix}, {build_name}, {resource}, {timestamp} or {uuid}", name)
+		}
+		// The placeholder is kept as is, for expand to replace.
+		segments = append(segments, segment{value: "{" + name + "}", unique: unique})
+		pattern = pattern[i+j+1:]
+	}
+	return segments, nil
+}
+
+// Name returns a new name for a resource of kind resource, like "instance",
+// adjusted to the rules like Normalize does. When the name is too long, the
+// parts of the pattern other than {timestamp} and {uuid} are shortened,
+// for names to stay unique. The error tells when the name doesn't follow
+// the rules nonetheless, like when the pattern starts with a character
+// that can't start a name.
+func (g *Generator) Name(resource string) (string, error) {
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(random.Reader, b); err != nil {
+		return "", err
+	}
+	return g.name(resource, hex.EncodeToString(b))
+}
+
+func (g *Generator) name(resource, id string) (string, error) {
+	pattern := g.Pattern
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		return "", err
+	}
+	prefix := g.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	t := g.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	values := map[string]string{
+		"{prefix}":     prefix,
+		"{build_name}": g.BuildName,
+		"{resource}":   resource,
+		"{timestamp}":  strconv.FormatInt(t.Unix(), 10),
+		"{uuid}":       id,
+	}
+
+	length := 0
+	for i := range segments {
+		if v, ok := values[segments[i].value]; ok {
+			segments[i].value = v
+		}
+		segments[i].value = g.Rules.normalizeChars(segments[i].value)
+		length += utf8.RuneCountInString(segments[i].value)
+	}
+	if g.Rules.MaxLength > 0 && length > g.Rules.MaxLength {
+		shorten(segments, length-g.Rules.MaxLength)
+	}
+
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteString(s.value)
+	}
+	name := Normalize(b.String(), g.Rules)
+	if err := Validate(name, g.Rules); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// shorten removes excess characters from the end of the segments that are
+// not unique, the longest ones first.
+func shorten(segments []segment, excess int) {
+	for excess > 0 {
+		longest, longestLen := -1, 0
+		for i, s := range segments {
+			if n := utf8.RuneCountInString(s.value); !s.unique && n > longestLen {
+				longest, longestLen = i, n
+			}
+		}
+		if longest < 0 {
+			return
+		}
+		cut := min(excess, longestLen)
+		segments[longest].value = string([]rune(segments[longest].value)[:longestLen-cut])
+		excess -= cut
+	}
+}
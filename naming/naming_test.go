@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package naming
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/random"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name     string
+		rules    Rules
+		expected string
+	}{
+		{"My Image_1", GCP, "my-image-1"},
+		{"image-" + strings.Repeat("x", 70), GCP, "image-" + strings.Repeat("x", 57)},
+		{"image.", GCP, "image"},
+		{"My Image (v1)", AWSImage, "My Image (v1)"},
+		{"my image!", Azure, "my-image"},
+	}
+	for _, tc := range cases {
+		if actual := Normalize(tc.name, tc.rules); actual != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]string{
+		"":                      "shorter than 1 characters",
+		"1image":                `must not start with '1'`,
+		"image-":                `must not end with '-'`,
+		"Image":                 "must be lower case",
+		"my_image":              `forbidden character '_'`,
+		strings.Repeat("x", 64): "longer than 63 characters",
+	}
+	for name, expected := range cases {
+		err := Validate(name, GCP)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", name, expected, err)
+		}
+	}
+	if err := Validate("packer-instance-0a1b", GCP); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := Validate("ab", AWSImage); err == nil {
+		t.Fatal("AMI names are at least 3 characters")
+	}
+}
+
+func TestGenerator_Name(t *testing.T) {
+	defer random.SetSource(random.SetSource(random.NewSeededSource(1)))
+
+	g := &Generator{Rules: GCP}
+	name, err := g.Name("instance")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !regexp.MustCompile(`^packer-instance-[0-9a-f]{8}$`).MatchString(name) {
+		t.Fatalf("bad name %q", name)
+	}
+	if other, _ := g.Name("instance"); other == name {
+		t.Fatal("names should be unique")
+	}
+
+	g = &Generator{
+		Pattern:   "{prefix}-{build_name}-{resource}-{timestamp}-{uuid}",
+		Prefix:    "CI",
+		BuildName: "googlecompute.Ubuntu_" + strings.Repeat("x", 60),
+		Time:      time.Unix(1700000000, 0),
+		Rules:     GCP,
+	}
+	name, err = g.name("disk", "0a1b2c3d")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// The build name is shortened, the timestamp and uuid are kept.
+	expected := "ci-googlecompute-ubuntu-xxxxxxxxxxxxxx-disk-1700000000-0a1b2c3d"
+	if name != expected || len(name) != GCP.MaxLength {
+		t.Fatalf("expected %q, got %q", expected, name)
+	}
+
+	if _, err := (&Generator{Pattern: "{uuid}-{resource}", Rules: GCP}).name("disk", "0a1b2c3d"); err == nil {
+		t.Fatal("GCP names must start with a letter")
+	}
+	if _, err := (&Generator{Pattern: "{prefix}-{id}"}).Name("disk"); err == nil || !strings.Contains(err.Error(), "unknown placeholder {id}") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestConfig_Prepare(t *testing.T) {
+	c := &Config{ResourceNamePattern: "{timestamp}-{resource}"}
+	if errs := c.Prepare(GCP); len(errs) != 1 {
+		t.Fatalf("patterns starting with digits should be rejected on GCP, got %v", errs)
+	}
+	if errs := c.Prepare(AWSImage); len(errs) != 0 {
+		t.Fatalf("errs: %v", errs)
+	}
+	if errs := (&Config{ResourceNamePattern: "{prefix"}).Prepare(AWSImage); len(errs) != 1 {
+		t.Fatalf("expected an error, got %v", errs)
+	}
+	c = &Config{ResourceNamePrefix: "team-a"}
+	if errs := c.Prepare(GCP); len(errs) != 0 {
+		t.Fatalf("errs: %v", errs)
+	}
+	name, err := c.Generator("build", GCP).Name("sg")
+	if err != nil || !strings.HasPrefix(name, "team-a-sg-") {
+		t.Fatalf("bad name %q: %v", name, err)
+	}
+}
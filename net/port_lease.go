@@ -0,0 +1,249 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+// DefaultPortLeaseTTL is how long a port lease lasts when it is not released,
+// for the ports of a build that crashed to be allocated again.
+const DefaultPortLeaseTTL = 2 * time.Hour
+
+// PortRange is the [Min, Max) range of ports.
+type PortRange struct {
+	Min, Max int
+}
+
+// ParsePortRanges parses comma separated ports and inclusive ranges of ports,
+// like "5900-5999,8080".
+func ParsePortRanges(s string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		min, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		max := min
+		if isRange {
+			max, err = strconv.Atoi(strings.TrimSpace(last))
+			if err != nil {
+				return nil, fmt.Errorf("invalid port range %q", part)
+			}
+		}
+		if min < 1 || max > 65535 || min > max {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, PortRange{Min: min, Max: max + 1})
+	}
+	return ranges, nil
+}
+
+// PortAllocator allocates ports to the builds running on a host, with lease
+// files in a directory shared by their processes. Unlike with
+// ListenRangeConfig, a port stays allocated once the listener checking it is
+// closed, until the lease is released or expires, so that the port can be
+// handed to a VNC server or a guest without another build picking it in the
+// meantime.
+type PortAllocator struct {
+	// Dir is the directory of the lease files. Defaults to "port-leases" in
+	// the Packer cache directory, shared by all the builds of the user.
+	Dir string
+	// Ranges are the ranges to allocate the ports from.
+	Ranges []PortRange
+	// TTL is how long the leases last. Defaults to DefaultPortLeaseTTL.
+	TTL time.Duration
+	// Network is the network used to check that a port is free, like "tcp",
+	// "tcp6" or "udp". Defaults to "tcp".
+	Network string
+	// Addr is the address used to check that a port is free, like
+	// "127.0.0.1" or "::1". Defaults to all the addresses.
+	Addr string
+}
+
+// PortLease is a port allocated by a PortAllocator.
+type PortLease struct {
+	Port    int
+	Expires time.Time
+
+	path  string
+	lock  string
+	owner string
+}
+
+// leaseFile is the content of a lease file.
+type leaseFile struct {
+	Owner   string    `json:"owner"`
+	PID     int       `json:"pid"`
+	Expires time.Time `json:"expires"`
+}
+
+// ErrNoFreePort is returned by PortAllocator.Allocate when every port of the
+// ranges is leased or in use.
+var ErrNoFreePort = errors.New("no free port left in range")
+
+// Allocate leases a free port of the ranges, waiting for one to be released
+// or to expire until ctx is cancelled.
+func (a *PortAllocator) Allocate(ctx context.Context) (*PortLease, error) {
+	if len(a.Ranges) == 0 {
+		return nil, fmt.Errorf("no port range to allocate from")
+	}
+	dir := a.Dir
+	if dir == "" {
+		var err error
+		if dir, err = packersdk.CachePath("port-leases"); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var ports []int
+	for _, r := range a.Ranges {
+		for port := r.Min; port < r.Max; port++ {
+			ports = append(ports, port)
+		}
+	}
+
+	var lease *PortLease
+	err := retry.Config{
+		RetryDelay: func() time.Duration { return 100 * time.Millisecond },
+		ShouldRetry: func(err error) bool {
+			return errors.Is(err, ErrNoFreePort) || errors.Is(err, errLeasesLocked)
+		},
+	}.Run(ctx, func(context.Context) error {
+		var err error
+		lease, err = a.allocate(dir, ports)
+		return err
+	})
+	return lease, err
+}
+
+var errLeasesLocked = errors.New("lease directory is locked")
+
+func (a *PortAllocator) allocate(dir string, ports []int) (*PortLease, error) {
+	lockPath := filepath.Join(dir, ".lock")
+	lock := filelock.New(lockPath)
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, errLeasesLocked
+	}
+	defer lock.Unlock()
+
+	now := time.Now()
+	for _, i := range rand.Perm(len(ports)) {
+		port := ports[i]
+		path := filepath.Join(dir, fmt.Sprintf("%d.lease", port))
+		if current, err := readLease(path); err == nil && now.Before(current.Expires) {
+			continue
+		}
+		if !a.free(port) {
+			continue
+		}
+
+		ttl := a.TTL
+		if ttl <= 0 {
+			ttl = DefaultPortLeaseTTL
+		}
+		lf := leaseFile{
+			Owner:   fmt.Sprintf("%d-%d", os.Getpid(), now.UnixNano()),
+			PID:     os.Getpid(),
+			Expires: now.Add(ttl),
+		}
+		data, err := json.Marshal(lf)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return nil, err
+		}
+		log.Printf("Leased port %d until %s", port, lf.Expires.Format(time.RFC3339))
+		return &PortLease{Port: port, Expires: lf.Expires, path: path, lock: lockPath, owner: lf.Owner}, nil
+	}
+	return nil, ErrNoFreePort
+}
+
+// free tells whether port can be listened to.
+func (a *PortAllocator) free(port int) bool {
+	network := a.Network
+	if network == "" {
+		network = "tcp"
+	}
+	address := net.JoinHostPort(a.Addr, strconv.Itoa(port))
+	if strings.HasPrefix(network, "udp") {
+		c, err := net.ListenPacket(network, address)
+		if err != nil {
+			return false
+		}
+		return c.Close() == nil
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return false
+	}
+	return l.Close() == nil
+}
+
+func readLease(path string) (*leaseFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lf := new(leaseFile)
+	if err := json.Unmarshal(data, lf); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+// Release releases the lease, for the port to be allocated again. The lease
+// file is left alone when the lease expired and the port was allocated since.
+func (l *PortLease) Release() error {
+	lock := filelock.New(l.lock)
+	for {
+		locked, err := lock.TryLock()
+		if err != nil {
+			return err
+		}
+		if locked {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer lock.Unlock()
+
+	current, err := readLease(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil && current.Owner != l.owner {
+		log.Printf("Port %d was leased again after its lease expired", l.Port)
+		return nil
+	}
+	return os.Remove(l.path)
+}
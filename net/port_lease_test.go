@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePortRanges(t *testing.T) {
+	ranges, err := ParsePortRanges("5900-5999, 8080")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []PortRange{{Min: 5900, Max: 6000}, {Min: 8080, Max: 8081}}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("bad ranges: %#v", ranges)
+	}
+	for _, s := range []string{"abc", "10-5", "0-10", "65000-70000"} {
+		if _, err := ParsePortRanges(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+// freePort returns a port the system considers free.
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestPortAllocator(t *testing.T) {
+	port := freePort(t)
+	a := &PortAllocator{
+		Dir:    t.TempDir(),
+		Ranges: []PortRange{{Min: port, Max: port + 1}},
+		Addr:   "127.0.0.1",
+	}
+
+	lease, err := a.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if lease.Port != port {
+		t.Fatalf("bad port: %d", lease.Port)
+	}
+
+	// The port stays leased, even though nothing listens to it.
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := a.Allocate(ctx); err == nil {
+		t.Fatalf("expected the leased port not to be allocated again")
+	}
+
+	if err := lease.Release(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	lease, err = a.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := lease.Release(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestPortAllocator_expired(t *testing.T) {
+	port := freePort(t)
+	a := &PortAllocator{
+		Dir:    t.TempDir(),
+		Ranges: []PortRange{{Min: port, Max: port + 1}},
+		TTL:    time.Millisecond,
+		Addr:   "127.0.0.1",
+	}
+	first, err := a.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	a.TTL = time.Hour
+	second, err := a.Allocate(context.Background())
+	if err != nil {
+		t.Fatalf("expected the expired lease to be allocated again: %s", err)
+	}
+	// Releasing the expired lease leaves the new one alone.
+	if err := first.Release(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(second.path); err != nil {
+		t.Fatalf("lease of the second allocation removed: %s", err)
+	}
+	if err := second.Release(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestPortAllocator_inUse(t *testing.T) {
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is not available: %s", err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	a := &PortAllocator{
+		Dir:     t.TempDir(),
+		Ranges:  []PortRange{{Min: port, Max: port + 1}},
+		Network: "tcp6",
+		Addr:    "::1",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if lease, err := a.Allocate(ctx); err == nil {
+		t.Fatalf("expected port %d in use not to be allocated", lease.Port)
+	}
+}
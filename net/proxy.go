@@ -0,0 +1,204 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ProxyConfig
+
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyConfig is the proxy configuration of the networked parts of a plugin:
+// downloads, communicators, template functions and HTTP clients. Embedding it
+// in the configuration of a builder with `mapstructure:",squash"` lets users
+// set a proxy once for all of them. Unset fields are read from the
+// environment by Prepare, once, so that every consumer honors the same
+// variables.
+type ProxyConfig struct {
+	// The URL of the proxy for HTTP requests, like
+	// `http://proxy.example.com:3128`. Defaults to the `HTTP_PROXY`
+	// environment variable.
+	HTTPProxy string `mapstructure:"http_proxy"`
+	// The URL of the proxy for HTTPS requests. Defaults to the `HTTPS_PROXY`
+	// environment variable.
+	HTTPSProxy string `mapstructure:"https_proxy"`
+	// The hosts to connect to directly, as in the `NO_PROXY` environment
+	// variable: domain names, which match their subdomains, IP addresses and
+	// CIDR ranges, optionally with a port. Defaults to the `NO_PROXY`
+	// environment variable.
+	NoProxy []string `mapstructure:"no_proxy"`
+	// The URL of a SOCKS5 proxy, like `socks5://proxy.example.com:1080`,
+	// used for HTTP and HTTPS requests without a proxy of their own, and for
+	// SSH connections. Defaults to the `ALL_PROXY` environment variable when
+	// it is a SOCKS5 URL.
+	SOCKS5Proxy string `mapstructure:"socks5_proxy"`
+	// The username to authenticate with the proxies whose URL has no
+	// credentials.
+	ProxyUsername string `mapstructure:"proxy_username"`
+	// The password to authenticate with the proxies whose URL has no
+	// credentials.
+	ProxyPassword string `mapstructure:"proxy_password" sensitive:"true"`
+}
+
+// Prepare reads the unset fields from the environment and validates the
+// proxy URLs.
+func (c *ProxyConfig) Prepare(ctx *interpolate.Context) []error {
+	if c.HTTPProxy == "" {
+		c.HTTPProxy = getenv("HTTP_PROXY")
+	}
+	if c.HTTPSProxy == "" {
+		c.HTTPSProxy = getenv("HTTPS_PROXY")
+	}
+	if len(c.NoProxy) == 0 {
+		for _, host := range strings.Split(getenv("NO_PROXY"), ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				c.NoProxy = append(c.NoProxy, host)
+			}
+		}
+	}
+	if c.SOCKS5Proxy == "" {
+		if all := getenv("ALL_PROXY"); strings.HasPrefix(all, "socks5://") || strings.HasPrefix(all, "socks5h://") {
+			c.SOCKS5Proxy = all
+		}
+	}
+
+	var errs []error
+	for name, raw := range map[string]string{"http_proxy": c.HTTPProxy, "https_proxy": c.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		if u, err := url.Parse(raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s is invalid: %s", name, err))
+		} else if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" {
+			errs = append(errs, fmt.Errorf("%s is invalid: unsupported proxy scheme %q, use http, https or socks5", name, u.Scheme))
+		}
+	}
+	if c.SOCKS5Proxy != "" {
+		if u, err := url.Parse(c.SOCKS5Proxy); err != nil {
+			errs = append(errs, fmt.Errorf("socks5_proxy is invalid: %s", err))
+		} else if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+			errs = append(errs, fmt.Errorf("socks5_proxy is invalid: unsupported proxy scheme %q, use socks5", u.Scheme))
+		}
+	}
+	if c.ProxyPassword != "" && c.ProxyUsername == "" {
+		errs = append(errs, fmt.Errorf("proxy_password is set but not proxy_username"))
+	}
+	return errs
+}
+
+// getenv returns the environment variable key, or its lowercase version.
+func getenv(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return os.Getenv(strings.ToLower(key))
+}
+
+// withCredentials returns raw with the proxy credentials, unless it has its
+// own.
+func (c *ProxyConfig) withCredentials(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil && c.ProxyUsername != "" {
+		u.User = url.UserPassword(c.ProxyUsername, c.ProxyPassword)
+	}
+	return u, nil
+}
+
+// httpProxy returns the configuration of the HTTP proxies.
+func (c *ProxyConfig) httpProxy() *httpproxy.Config {
+	cfg := &httpproxy.Config{
+		HTTPProxy:  c.HTTPProxy,
+		HTTPSProxy: c.HTTPSProxy,
+		NoProxy:    strings.Join(c.NoProxy, ","),
+	}
+	if cfg.HTTPProxy == "" {
+		cfg.HTTPProxy = c.SOCKS5Proxy
+	}
+	if cfg.HTTPSProxy == "" {
+		cfg.HTTPSProxy = c.SOCKS5Proxy
+	}
+	for _, p := range []*string{&cfg.HTTPProxy, &cfg.HTTPSProxy} {
+		if *p == "" {
+			continue
+		}
+		if u, err := c.withCredentials(*p); err == nil {
+			*p = u.String()
+		}
+	}
+	return cfg
+}
+
+// ProxyFunc returns the proxy of requests, for http.Transport.Proxy. It
+// returns nil when requests are sent directly.
+func (c *ProxyConfig) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	proxyFunc := c.httpProxy().ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// Transport returns an http.Transport configured like
+// http.DefaultTransport, but for its proxies.
+func (c *ProxyConfig) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = c.ProxyFunc()
+	return t
+}
+
+// HTTPClient returns an http.Client using the proxies and retrying failed
+// requests, like retry.NewHTTPClient does with the proxies of the
+// environment.
+func (c *ProxyConfig) HTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &retry.HTTPTransport{Base: c.Transport()},
+	}
+}
+
+// DialContext connects to address through the SOCKS5 proxy, or directly
+// when there is none or address is excluded by NoProxy. It can be used to
+// reach SSH servers.
+func (c *ProxyConfig) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	direct := &net.Dialer{}
+	if c.SOCKS5Proxy == "" || c.bypasses(address) {
+		return direct.DialContext(ctx, network, address)
+	}
+	u, err := c.withCredentials(c.SOCKS5Proxy)
+	if err != nil {
+		return nil, err
+	}
+	var auth *proxy.Auth
+	if u.User != nil {
+		auth = &proxy.Auth{User: u.User.Username()}
+		auth.Password, _ = u.User.Password()
+	}
+	dialer, err := proxy.SOCKS5("tcp", u.Host, auth, direct)
+	if err != nil {
+		return nil, err
+	}
+	return dialer.(proxy.ContextDialer).DialContext(ctx, network, address)
+}
+
+// bypasses reports whether address is excluded from proxying by NoProxy.
+func (c *ProxyConfig) bypasses(address string) bool {
+	// httpproxy implements the NO_PROXY matching rules, and only proxies
+	// the hosts not excluded.
+	cfg := httpproxy.Config{HTTPSProxy: "http://proxy", NoProxy: strings.Join(c.NoProxy, ",")}
+	u, err := cfg.ProxyFunc()(&url.URL{Scheme: "https", Host: address})
+	return err == nil && u == nil
+}
@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package orphans
+
+import (
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Config tells what to do with the resources a build leaves behind. Embed
+// it in your builder config using the `mapstructure:",squash"` struct tag,
+// and pass it to StepReportOrphans.
+type Config struct {
+	// Destroy the resources a failed build left behind, when the builder
+	// knows how to, rather than only report them. Defaults to `false`: a
+	// failed build may be investigated from what it left.
+	DestroyOrphans bool `mapstructure:"destroy_orphans" required:"false"`
+	// The file the orphans report is written to, as JSON, when the build
+	// leaves resources behind, for a script to clean them up. By default
+	// the report is only part of the output of the build.
+	OrphansReportPath string `mapstructure:"orphans_report_path" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	return nil
+}
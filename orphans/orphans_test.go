@@ -0,0 +1,162 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package orphans
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Record(Resource{Type: "instance", ID: "i-1"})
+	r.Record(Resource{Type: "volume", ID: "vol-1"})
+	r.Record(Resource{Type: "instance", ID: "i-1", Region: "eu-west-1"})
+	r.Record(Resource{Type: "security_group", ID: "sg-1"})
+	r.Forget("volume", "vol-1")
+	r.Forget("volume", "vol-2")
+
+	expected := []Resource{
+		{Type: "instance", ID: "i-1", Region: "eu-west-1"},
+		{Type: "security_group", ID: "sg-1"},
+	}
+	if got := r.Resources(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, got)
+	}
+}
+
+func TestRegistry_Orphans(t *testing.T) {
+	r := NewRegistry()
+	var destroyed []string
+	r.RegisterDestructor("instance", func(_ context.Context, res Resource) error {
+		destroyed = append(destroyed, res.ID)
+		return nil
+	})
+	r.RegisterDestructor("volume", func(context.Context, Resource) error {
+		return errors.New("volume in use")
+	})
+	r.Record(Resource{Type: "instance", ID: "i-1"})
+	r.Record(Resource{Type: "volume", ID: "vol-1"})
+	r.Record(Resource{Type: "instance", ID: "i-2"})
+	r.Record(Resource{Type: "key_pair", ID: "packer-1"})
+
+	orphans := r.Orphans(context.Background(), false)
+	if len(orphans) != 4 || destroyed != nil {
+		t.Fatalf("nothing should be destroyed: %#v", orphans)
+	}
+
+	orphans = r.Orphans(context.Background(), true)
+	expected := []Orphan{
+		{Resource: Resource{Type: "instance", ID: "i-1"}, Status: Destroyed},
+		{Resource: Resource{Type: "volume", ID: "vol-1"}, Status: DestroyFailed, Error: "volume in use"},
+		{Resource: Resource{Type: "instance", ID: "i-2"}, Status: Destroyed},
+		{Resource: Resource{Type: "key_pair", ID: "packer-1"}, Status: Orphaned},
+	}
+	if !reflect.DeepEqual(orphans, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, orphans)
+	}
+	if !reflect.DeepEqual(destroyed, []string{"i-2", "i-1"}) {
+		t.Fatalf("the newest resources should be destroyed first: %v", destroyed)
+	}
+	if len(r.Resources()) != 2 {
+		t.Fatalf("the destroyed resources should be forgotten: %#v", r.Resources())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a destructor twice should panic")
+		}
+	}()
+	r.RegisterDestructor("instance", nil)
+}
+
+func TestStepReportOrphans(t *testing.T) {
+	var out bytes.Buffer
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packersdk.MachineReadableUi{Writer: &out})
+	reportPath := filepath.Join(t.TempDir(), "orphans.json")
+	step := &StepReportOrphans{Config: &Config{DestroyOrphans: true, OrphansReportPath: reportPath}, BuildName: "amazon-ebs.ubuntu"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	registry := RegistryOf(state)
+	registry.RegisterDestructor("instance", func(context.Context, Resource) error { return nil })
+	registry.Record(Resource{Type: "instance", ID: "i-1"})
+	registry.Record(Resource{Type: "snapshot", ID: "snap-1", DeleteHint: "aws ec2 delete-snapshot --snapshot-id snap-1"})
+	state.Put("error", errors.New("timeout waiting for SSH"))
+	step.Cleanup(state)
+
+	expected := &Report{
+		BuildName: "amazon-ebs.ubuntu",
+		Error:     "timeout waiting for SSH",
+		Orphans: []Orphan{
+			{Resource: Resource{Type: "instance", ID: "i-1"}, Status: Destroyed},
+			{Resource: Resource{Type: "snapshot", ID: "snap-1", DeleteHint: "aws ec2 delete-snapshot --snapshot-id snap-1"}, Status: Orphaned},
+		},
+	}
+	if report := state.Get("orphans_report"); !reflect.DeepEqual(report, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, report)
+	}
+
+	var fromFile Report
+	b, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &fromFile); err != nil || !reflect.DeepEqual(&fromFile, expected) {
+		t.Fatalf("bad report file (%v):\n%s", err, b)
+	}
+
+	dec := packersdk.NewUiEventDecoder(&out)
+	var machine *packersdk.UiEvent
+	var errs []string
+	for {
+		e, err := dec.Decode()
+		if err != nil {
+			break
+		}
+		switch {
+		case e.Type == packersdk.UiEventMachine:
+			machine = &e
+		case e.Type == packersdk.UiEventError:
+			errs = append(errs, e.Payload.Message)
+		}
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0], "aws ec2 delete-snapshot --snapshot-id snap-1") {
+		t.Fatalf("bad errors: %q", errs)
+	}
+	var fromUi Report
+	if machine == nil || machine.Payload.Category != ReportMachineCategory {
+		t.Fatalf("expected a machine readable report, got %#v", machine)
+	}
+	if err := machine.Payload.DecodeData(&fromUi); err != nil || !reflect.DeepEqual(&fromUi, expected) {
+		t.Fatalf("bad machine readable report (%v): %#v", err, fromUi)
+	}
+}
+
+func TestStepReportOrphans_nothingLeft(t *testing.T) {
+	ui := &packersdk.MockUi{}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", ui)
+	step := &StepReportOrphans{}
+	step.Run(context.Background(), state)
+	RegistryOf(state).Record(Resource{Type: "instance", ID: "i-1"})
+	RegistryOf(state).Forget("instance", "i-1")
+	step.Cleanup(state)
+
+	if _, ok := state.GetOk("orphans_report"); ok || ui.ErrorCalled || ui.MachineCalled {
+		t.Fatal("nothing should be reported")
+	}
+}
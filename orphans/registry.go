@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package orphans keeps track of the remote resources a build creates, like
+// instances, volumes or security groups, so that the ones a failed build
+// leaves behind are reported, with what it takes to delete them, and can be
+// destroyed.
+//
+// Builders record a resource in the Registry of the build as soon as it is
+// created, and forget it once it is deleted or becomes part of the
+// artifact. StepReportOrphans reports what is left when the build ends.
+package orphans
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// registryStateKey is the key of the Registry of a build in its state.
+const registryStateKey = "orphans_registry"
+
+// Resource is a remote resource created by a build.
+type Resource struct {
+	// Type is the type of the resource, like "instance" or "volume".
+	Type string `json:"type"`
+	// ID identifies the resource.
+	ID string `json:"id"`
+	// Region is where the resource is, when it matters to find it.
+	Region string `json:"region,omitempty"`
+	// DeleteHint tells users how to delete the resource by hand, like
+	// "aws ec2 terminate-instances --instance-ids i-0123".
+	DeleteHint string `json:"delete_hint,omitempty"`
+}
+
+func (r Resource) String() string {
+	if r.Region != "" {
+		return fmt.Sprintf("%s %s (%s)", r.Type, r.ID, r.Region)
+	}
+	return fmt.Sprintf("%s %s", r.Type, r.ID)
+}
+
+// A Destructor deletes a resource. It returns nil when the resource is
+// already gone.
+type Destructor func(ctx context.Context, r Resource) error
+
+// Registry holds the resources of a build that still exist. A Registry is
+// safe to use from multiple goroutines.
+type Registry struct {
+	mu          sync.Mutex
+	resources   []Resource
+	destructors map[string]Destructor
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{destructors: map[string]Destructor{}}
+}
+
+// RegistryOf returns the Registry of the build of state, creating it the
+// first time.
+func RegistryOf(state multistep.StateBag) *Registry {
+	if r, ok := state.GetOk(registryStateKey); ok {
+		return r.(*Registry)
+	}
+	r := NewRegistry()
+	state.Put(registryStateKey, r)
+	return r
+}
+
+// RegisterDestructor registers destroy as the way to delete the resources of
+// type resourceType. It panics if the type already has one.
+func (r *Registry) RegisterDestructor(resourceType string, destroy Destructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.destructors[resourceType]; ok {
+		panic(fmt.Sprintf("orphans: destructor of %q registered twice", resourceType))
+	}
+	r.destructors[resourceType] = destroy
+}
+
+// Record records that res was created. Recording a resource of the same type
+// and ID again replaces it.
+func (r *Registry) Record(res Resource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.index(res.Type, res.ID); i >= 0 {
+		r.resources[i] = res
+		return
+	}
+	r.resources = append(r.resources, res)
+}
+
+// Forget forgets the resource of type resourceType identified by id, once
+// it is deleted or becomes part of the artifact. Forgetting a resource that
+// isn't recorded does nothing.
+func (r *Registry) Forget(resourceType, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.index(resourceType, id); i >= 0 {
+		r.resources = append(r.resources[:i:i], r.resources[i+1:]...)
+	}
+}
+
+// Resources returns the resources recorded, in the order they were created.
+func (r *Registry) Resources() []Resource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Resource(nil), r.resources...)
+}
+
+func (r *Registry) index(resourceType, id string) int {
+	for i, res := range r.resources {
+		if res.Type == resourceType && res.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Status is what became of an orphan.
+type Status string
+
+const (
+	// Orphaned resources are left behind.
+	Orphaned Status = "orphaned"
+	// Destroyed resources were deleted by their Destructor.
+	Destroyed Status = "destroyed"
+	// DestroyFailed resources couldn't be deleted by their Destructor, and
+	// are left behind.
+	DestroyFailed Status = "destroy_failed"
+)
+
+// Orphan is a resource left behind by a build, and what became of it.
+type Orphan struct {
+	Resource
+	Status Status `json:"status"`
+	// Error tells why the resource couldn't be destroyed.
+	Error string `json:"error,omitempty"`
+}
+
+// Orphans returns the resources recorded as orphans. With destroy, the ones
+// whose type has a Destructor are destroyed first, the newest first as they
+// may depend on the older ones, and forgotten when that works.
+func (r *Registry) Orphans(ctx context.Context, destroy bool) []Orphan {
+	resources := r.Resources()
+	orphans := make([]Orphan, len(resources))
+	for i := len(resources) - 1; i >= 0; i-- {
+		res := resources[i]
+		orphans[i] = Orphan{Resource: res, Status: Orphaned}
+		if !destroy {
+			continue
+		}
+		r.mu.Lock()
+		destructor := r.destructors[res.Type]
+		r.mu.Unlock()
+		if destructor == nil {
+			continue
+		}
+		if err := destructor(ctx, res); err != nil {
+			orphans[i].Status = DestroyFailed
+			orphans[i].Error = err.Error()
+			continue
+		}
+		orphans[i].Status = Destroyed
+		r.Forget(res.Type, res.ID)
+	}
+	return orphans
+}
@@ -0,0 +1,121 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package orphans
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ReportMachineCategory is the category of the machine readable event the
+// orphans report is written as.
+const ReportMachineCategory = "orphans-report"
+
+// Report lists the resources a build left behind.
+type Report struct {
+	BuildName string `json:"build_name,omitempty"`
+	// Error is the error the build failed with, if it did.
+	Error   string   `json:"error,omitempty"`
+	Orphans []Orphan `json:"orphans"`
+}
+
+// machineDataUi is implemented by the Uis writing typed machine events, like
+// packersdk.MachineReadableUi.
+type machineDataUi interface {
+	MachineData(t string, data interface{}) error
+}
+
+// StepReportOrphans reports the resources recorded in the Registry of the
+// build that are still there once every other step cleaned up, destroying
+// them first with Config.DestroyOrphans. The report is written to the Ui,
+// both for people and as a machine readable event of category
+// ReportMachineCategory, and to Config.OrphansReportPath. It must be the
+// first step, for its cleanup to run last.
+//
+// Produces:
+//
+//	orphans_report *Report - The report, when resources were left behind.
+type StepReportOrphans struct {
+	Config    *Config
+	BuildName string
+	// Timeout bounds the destruction of the orphans, 10 minutes when unset.
+	Timeout time.Duration
+}
+
+func (s *StepReportOrphans) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	RegistryOf(state)
+	return multistep.ActionContinue
+}
+
+func (s *StepReportOrphans) Cleanup(state multistep.StateBag) {
+	registry := RegistryOf(state)
+	if len(registry.Resources()) == 0 {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+	config := s.Config
+	if config == nil {
+		config = &Config{}
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if config.DestroyOrphans {
+		ui.Say(i18n.T("Destroying the resources left behind by the build..."))
+	}
+	report := &Report{BuildName: s.BuildName, Orphans: registry.Orphans(ctx, config.DestroyOrphans)}
+	if err, ok := state.Get("error").(error); ok {
+		report.Error = err.Error()
+	}
+	state.Put("orphans_report", report)
+
+	for _, o := range report.Orphans {
+		switch o.Status {
+		case Destroyed:
+			ui.Message(i18n.Sprintf("Destroyed %s", o.Resource))
+		case DestroyFailed:
+			ui.Error(i18n.Sprintf("Error destroying %s: %s", o.Resource, o.Error))
+			fallthrough
+		default:
+			if o.DeleteHint != "" {
+				ui.Error(i18n.Sprintf("The build left %s behind, delete it with: %s", o.Resource, o.DeleteHint))
+			} else {
+				ui.Error(i18n.Sprintf("The build left %s behind", o.Resource))
+			}
+		}
+	}
+
+	if mui, ok := ui.(machineDataUi); ok {
+		if err := mui.MachineData(ReportMachineCategory, report); err != nil {
+			ui.Error(i18n.Sprintf("Error writing the orphans report: %s", err))
+		}
+	} else if b, err := json.Marshal(report); err == nil {
+		ui.Machine(ReportMachineCategory, string(b))
+	}
+
+	if config.OrphansReportPath != "" {
+		if err := report.WriteFile(config.OrphansReportPath); err != nil {
+			ui.Error(i18n.Sprintf("Error writing the orphans report: %s", err))
+		}
+	}
+}
+
+// WriteFile writes r to path, as indented JSON.
+func (r *Report) WriteFile(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
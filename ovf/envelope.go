@@ -0,0 +1,216 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package ovf reads and writes the Open Virtualization Format files exported
+// and imported by VMware, VirtualBox and the builders and post-processors
+// around them: the OVF descriptor, its manifest of checksums, and the OVA
+// tarball packing them with the disks. The descriptor is only parsed to be
+// validated and inspected; SetProperties edits it in place, leaving the
+// parts it doesn't change byte for byte as they are.
+package ovf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Namespaces of the OVF envelope, by version.
+const (
+	Namespace1 = "http://schemas.dmtf.org/ovf/envelope/1"
+	Namespace2 = "http://schemas.dmtf.org/ovf/envelope/2"
+)
+
+// Envelope is the part of an OVF descriptor builders and post-processors
+// look at.
+type Envelope struct {
+	XMLName xml.Name
+	// References are the files of the package, like its disks.
+	References []File    `xml:"References>File"`
+	Disks      []Disk    `xml:"DiskSection>Disk"`
+	Networks   []Network `xml:"NetworkSection>Network"`
+	// VirtualSystems are the virtual machines of the package, the ones of
+	// its VirtualSystemCollection included.
+	VirtualSystems []VirtualSystem `xml:"VirtualSystem"`
+	Collections    []struct {
+		ID             string          `xml:"id,attr"`
+		VirtualSystems []VirtualSystem `xml:"VirtualSystem"`
+	} `xml:"VirtualSystemCollection"`
+}
+
+// File is a file of the package.
+type File struct {
+	ID   string `xml:"id,attr"`
+	Href string `xml:"href,attr"`
+	// Size is the size of the file in bytes, 0 when not set.
+	Size int64 `xml:"size,attr"`
+}
+
+// Disk is a virtual disk of the package.
+type Disk struct {
+	DiskID string `xml:"diskId,attr"`
+	// FileRef is the ID of the File holding the disk, empty for disks
+	// created empty on import.
+	FileRef                 string `xml:"fileRef,attr"`
+	Capacity                string `xml:"capacity,attr"`
+	CapacityAllocationUnits string `xml:"capacityAllocationUnits,attr"`
+	Format                  string `xml:"format,attr"`
+}
+
+// Network is a logical network the virtual systems connect to.
+type Network struct {
+	Name        string `xml:"name,attr"`
+	Description string `xml:"Description"`
+}
+
+// VirtualSystem is a virtual machine.
+type VirtualSystem struct {
+	ID       string           `xml:"id,attr"`
+	Name     string           `xml:"Name"`
+	Products []ProductSection `xml:"ProductSection"`
+}
+
+// ProductSection describes the product installed in a virtual system and
+// the properties configuring it on deployment.
+type ProductSection struct {
+	Class      string     `xml:"class,attr"`
+	Product    string     `xml:"Product"`
+	Version    string     `xml:"Version"`
+	Properties []Property `xml:"Property"`
+}
+
+// Property is a setting of a product, like its hostname, which can be set
+// when the package is deployed.
+type Property struct {
+	Key              string `xml:"key,attr"`
+	Type             string `xml:"type,attr"`
+	Value            string `xml:"value,attr"`
+	UserConfigurable bool   `xml:"userConfigurable,attr"`
+	Label            string `xml:"Label"`
+	Description      string `xml:"Description"`
+}
+
+// Parse parses the OVF descriptor r.
+func Parse(r io.Reader) (*Envelope, error) {
+	var e Envelope
+	if err := xml.NewDecoder(r).Decode(&e); err != nil {
+		return nil, fmt.Errorf("invalid OVF descriptor: %w", err)
+	}
+	return &e, nil
+}
+
+// ParseFile parses the OVF descriptor at path.
+func ParseFile(path string) (*Envelope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	e, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+// AllVirtualSystems returns the virtual systems of e, the ones of its
+// collections included.
+func (e *Envelope) AllVirtualSystems() []VirtualSystem {
+	vss := append([]VirtualSystem(nil), e.VirtualSystems...)
+	for _, c := range e.Collections {
+		vss = append(vss, c.VirtualSystems...)
+	}
+	return vss
+}
+
+// File returns the file of e with the given ID.
+func (e *Envelope) File(id string) (File, bool) {
+	for _, f := range e.References {
+		if f.ID == id {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// Validate checks that e is an OVF envelope whose references are consistent
+// and whose files can be packed in, and extracted from, an OVA safely.
+func (e *Envelope) Validate() error {
+	if e.XMLName.Local != "Envelope" || (e.XMLName.Space != Namespace1 && e.XMLName.Space != Namespace2) {
+		return fmt.Errorf("the root element is %s %s, expected an OVF Envelope", e.XMLName.Space, e.XMLName.Local)
+	}
+
+	var errs []error
+	files := map[string]bool{}
+	for i, f := range e.References {
+		if f.ID == "" {
+			errs = append(errs, fmt.Errorf("References.File[%d]: id is not set", i))
+		} else if files[f.ID] {
+			errs = append(errs, fmt.Errorf("References.File[%d]: duplicate id %q", i, f.ID))
+		}
+		files[f.ID] = true
+		if err := checkHref(f.Href); err != nil {
+			errs = append(errs, fmt.Errorf("References.File[%d]: %w", i, err))
+		}
+		if f.Size < 0 {
+			errs = append(errs, fmt.Errorf("References.File[%d]: negative size %d", i, f.Size))
+		}
+	}
+
+	disks := map[string]bool{}
+	for i, d := range e.Disks {
+		if d.DiskID == "" {
+			errs = append(errs, fmt.Errorf("DiskSection.Disk[%d]: diskId is not set", i))
+		} else if disks[d.DiskID] {
+			errs = append(errs, fmt.Errorf("DiskSection.Disk[%d]: duplicate diskId %q", i, d.DiskID))
+		}
+		disks[d.DiskID] = true
+		if d.FileRef != "" && !files[d.FileRef] {
+			errs = append(errs, fmt.Errorf("DiskSection.Disk[%d]: fileRef %q is not a file of References", i, d.FileRef))
+		}
+		if d.Capacity == "" {
+			errs = append(errs, fmt.Errorf("DiskSection.Disk[%d]: capacity is not set", i))
+		}
+	}
+
+	vss := e.AllVirtualSystems()
+	if len(vss) == 0 {
+		errs = append(errs, errors.New("the envelope has no VirtualSystem"))
+	}
+	for i, vs := range vss {
+		if vs.ID == "" {
+			errs = append(errs, fmt.Errorf("VirtualSystem[%d]: id is not set", i))
+		}
+		for _, p := range vs.Products {
+			keys := map[string]bool{}
+			for _, prop := range p.Properties {
+				if prop.Key == "" {
+					errs = append(errs, fmt.Errorf("VirtualSystem[%d]: a property has no key", i))
+				} else if keys[prop.Key] {
+					errs = append(errs, fmt.Errorf("VirtualSystem[%d]: duplicate property %q", i, prop.Key))
+				}
+				keys[prop.Key] = true
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkHref checks that href, the path of a file relative to the
+// descriptor, or a URL, doesn't point outside of the package.
+func checkHref(href string) error {
+	if href == "" {
+		return errors.New("href is not set")
+	}
+	if strings.Contains(href, "://") {
+		return nil
+	}
+	if path.IsAbs(href) || strings.Contains(href, `\`) || path.Clean(href) != href || strings.HasPrefix(href, "../") || href == ".." {
+		return fmt.Errorf("href %q must be a file name relative to the descriptor", href)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ovf
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// manifestAlgorithms are the checksums manifests are written with, SHA1 for
+// OVF 1.0 and SHA256 for the later versions and VMware.
+var manifestAlgorithms = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// manifestLineRe matches the lines of a manifest, like
+// `SHA256(disk1.vmdk)= 9f86d0...`.
+var manifestLineRe = regexp.MustCompile(`^(\w+)\((.+)\)\s*=\s*([0-9a-fA-F]+)$`)
+
+// ManifestEntry is the checksum of a file of the package.
+type ManifestEntry struct {
+	Algorithm string
+	File      string
+	Sum       string
+}
+
+// Manifest is the content of the .mf file listing the checksums of the
+// files of a package, the descriptor included.
+type Manifest struct {
+	Entries []ManifestEntry
+}
+
+// NewManifest computes the checksums of files, relative to dir, with
+// algorithm, "SHA1", "SHA256" or "SHA512".
+func NewManifest(algorithm, dir string, files []string) (*Manifest, error) {
+	algorithm = strings.ToUpper(algorithm)
+	if manifestAlgorithms[algorithm] == nil {
+		return nil, fmt.Errorf("unsupported manifest algorithm %q, expected SHA1, SHA256 or SHA512", algorithm)
+	}
+	m := &Manifest{}
+	for _, file := range files {
+		if err := m.Set(algorithm, dir, file); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Set computes the checksum of file, relative to dir, with algorithm and
+// sets it in m, replacing the entry of file if there is one.
+func (m *Manifest) Set(algorithm, dir, file string) error {
+	algorithm = strings.ToUpper(algorithm)
+	sum, err := fileSum(algorithm, filepath.Join(dir, file))
+	if err != nil {
+		return err
+	}
+	entry := ManifestEntry{Algorithm: algorithm, File: file, Sum: sum}
+	for i := range m.Entries {
+		if m.Entries[i].File == file {
+			m.Entries[i] = entry
+			return nil
+		}
+	}
+	m.Entries = append(m.Entries, entry)
+	return nil
+}
+
+func fileSum(algorithm, path string) (string, error) {
+	newHash := manifestAlgorithms[algorithm]
+	if newHash == nil {
+		return "", fmt.Errorf("unsupported manifest algorithm %q", algorithm)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ParseManifest parses the manifest r.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	m := &Manifest{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+		match := manifestLineRe.FindStringSubmatch(text)
+		if match == nil {
+			return nil, fmt.Errorf("manifest line %d: invalid entry %q", line, text)
+		}
+		m.Entries = append(m.Entries, ManifestEntry{
+			Algorithm: strings.ToUpper(match[1]),
+			File:      match[2],
+			Sum:       strings.ToLower(match[3]),
+		})
+	}
+	return m, s.Err()
+}
+
+// ParseManifestFile parses the manifest at path.
+func ParseManifestFile(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseManifest(f)
+}
+
+// WriteTo writes m to w, in the format of the .mf files.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, e := range m.Entries {
+		fmt.Fprintf(&b, "%s(%s)= %s\n", e.Algorithm, e.File, e.Sum)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// WriteFile writes m to path.
+func (m *Manifest) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := m.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Verify checks the checksums of the files of m, relative to dir.
+func (m *Manifest) Verify(dir string) error {
+	var errs []error
+	for _, e := range m.Entries {
+		if err := checkHref(e.File); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sum, err := fileSum(e.Algorithm, filepath.Join(dir, e.File))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if sum != e.Sum {
+			errs = append(errs, fmt.Errorf("%s: %s checksum mismatch: expected %s, got %s", e.File, e.Algorithm, e.Sum, sum))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ManifestPath returns the path of the manifest of the descriptor at
+// ovfPath, which has the same name with the .mf extension.
+func ManifestPath(ovfPath string) string {
+	return strings.TrimSuffix(ovfPath, filepath.Ext(ovfPath)) + ".mf"
+}
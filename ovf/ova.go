@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ovf
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/iohelper"
+)
+
+// CreateOVA packs the descriptor at ovfPath, its manifest and the files it
+// references into the OVA at ovaPath, in the order the OVF specification
+// requires: descriptor first, then manifest, then files in the order of
+// References. When manifestAlgorithm is set, like "SHA256", the manifest is
+// generated with it; otherwise the manifest next to the descriptor, if any,
+// is packed as is.
+func CreateOVA(ctx context.Context, ovfPath, ovaPath, manifestAlgorithm string) error {
+	e, err := ParseFile(ovfPath)
+	if err != nil {
+		return err
+	}
+	if err := e.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", ovfPath, err)
+	}
+	dir := filepath.Dir(ovfPath)
+	files := []string{filepath.Base(ovfPath)}
+	for _, f := range e.References {
+		if strings.Contains(f.Href, "/") {
+			return fmt.Errorf("%s: %s can't be packed in an OVA, only files next to the descriptor can", ovfPath, f.Href)
+		}
+		files = append(files, f.Href)
+	}
+
+	var manifest []byte
+	mfName := filepath.Base(ManifestPath(ovfPath))
+	if manifestAlgorithm != "" {
+		m, err := NewManifest(manifestAlgorithm, dir, files)
+		if err != nil {
+			return err
+		}
+		var b bytes.Buffer
+		if _, err := m.WriteTo(&b); err != nil {
+			return err
+		}
+		manifest = b.Bytes()
+	} else if manifest, err = os.ReadFile(filepath.Join(dir, mfName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	out, err := os.Create(ovaPath)
+	if err != nil {
+		return err
+	}
+	if err := writeOVA(ctx, out, dir, files, mfName, manifest); err != nil {
+		out.Close()
+		os.Remove(ovaPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(ovaPath)
+		return err
+	}
+	return nil
+}
+
+func writeOVA(ctx context.Context, w io.Writer, dir string, files []string, mfName string, manifest []byte) error {
+	tw := tar.NewWriter(w)
+	for i, name := range files {
+		if err := addFile(ctx, tw, dir, name); err != nil {
+			return err
+		}
+		if i == 0 && manifest != nil {
+			hdr := &tar.Header{Name: mfName, Mode: 0644, Size: int64(len(manifest)), Format: tar.FormatUSTAR}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := tw.Write(manifest); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func addFile(ctx context.Context, tw *tar.Writer, dir, name string) error {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Format:  tar.FormatUSTAR,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if _, err := iohelper.CancellableCopy(ctx, tw, f); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// ExtractOVA extracts the OVA at ovaPath to dir, verifies the checksums of
+// its manifest if it has one, and returns the path of its descriptor.
+func ExtractOVA(ctx context.Context, ovaPath, dir string) (string, error) {
+	f, err := os.Open(ovaPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	var ovfPath, mfPath string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ovaPath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return "", fmt.Errorf("%s: %s is not a regular file", ovaPath, hdr.Name)
+		}
+		if err := checkHref(hdr.Name); err != nil || strings.Contains(hdr.Name, "/") {
+			return "", fmt.Errorf("%s: invalid file name %q", ovaPath, hdr.Name)
+		}
+		path := filepath.Join(dir, hdr.Name)
+		switch {
+		case ovfPath == "" && strings.EqualFold(filepath.Ext(hdr.Name), ".ovf"):
+			ovfPath = path
+		case ovfPath == "":
+			return "", fmt.Errorf("%s: the first file is %s, expected the OVF descriptor", ovaPath, hdr.Name)
+		case strings.EqualFold(filepath.Ext(hdr.Name), ".mf"):
+			mfPath = path
+		}
+		if err := extractFile(ctx, tr, path); err != nil {
+			return "", err
+		}
+	}
+	if ovfPath == "" {
+		return "", fmt.Errorf("%s: no OVF descriptor found", ovaPath)
+	}
+
+	if mfPath != "" {
+		m, err := ParseManifestFile(mfPath)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", mfPath, err)
+		}
+		if err := m.Verify(dir); err != nil {
+			return "", fmt.Errorf("%s: %w", ovaPath, err)
+		}
+	}
+	return ovfPath, nil
+}
+
+func extractFile(ctx context.Context, r io.Reader, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := iohelper.CancellableCopy(ctx, out, r); err != nil {
+		out.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return out.Close()
+}
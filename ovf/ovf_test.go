@@ -0,0 +1,293 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ovf
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDescriptor = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:vmw="http://www.vmware.com/schema/ovf">
+  <References>
+    <File ovf:id="file1" ovf:href="disk1.vmdk" ovf:size="4"/>
+  </References>
+  <DiskSection>
+    <Info>Virtual disk information</Info>
+    <Disk ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:capacity="20" ovf:capacityAllocationUnits="byte * 2^30" ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"/>
+  </DiskSection>
+  <NetworkSection>
+    <Info>The list of logical networks</Info>
+    <Network ovf:name="VM Network">
+      <Description>The VM Network network</Description>
+    </Network>
+  </NetworkSection>
+  <VirtualSystem ovf:id="packer">
+    <Info>A virtual machine</Info>
+    <Name>packer</Name>
+    <ProductSection>
+      <Info>Information about the installed software</Info>
+      <Product>Ubuntu</Product>
+      <Version>24.04</Version>
+      <Property ovf:key="hostname" ovf:type="string" ovf:userConfigurable="true" ovf:value="">
+        <Label>Hostname</Label>
+      </Property>
+      <Property ovf:key="password" ovf:type="password" ovf:userConfigurable="true"/>
+    </ProductSection>
+    <vmw:BootOrderSection vmw:type="disk"/>
+  </VirtualSystem>
+</Envelope>
+`
+
+func writeTestPackage(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "packer.ovf"), []byte(testDescriptor), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "disk1.vmdk"), []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "packer.ovf")
+}
+
+func TestParse(t *testing.T) {
+	e, err := Parse(strings.NewReader(testDescriptor))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := e.Validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected := []File{{ID: "file1", Href: "disk1.vmdk", Size: 4}}; !reflect.DeepEqual(e.References, expected) {
+		t.Fatalf("bad references: %#v", e.References)
+	}
+	if len(e.Disks) != 1 || e.Disks[0].FileRef != "file1" || e.Disks[0].Capacity != "20" {
+		t.Fatalf("bad disks: %#v", e.Disks)
+	}
+	if len(e.Networks) != 1 || e.Networks[0].Name != "VM Network" {
+		t.Fatalf("bad networks: %#v", e.Networks)
+	}
+	vss := e.AllVirtualSystems()
+	if len(vss) != 1 || vss[0].Name != "packer" || len(vss[0].Products) != 1 {
+		t.Fatalf("bad virtual systems: %#v", vss)
+	}
+	props := vss[0].Products[0].Properties
+	if len(props) != 2 || props[0].Key != "hostname" || props[0].Label != "Hostname" || !props[0].UserConfigurable {
+		t.Fatalf("bad properties: %#v", props)
+	}
+}
+
+func TestEnvelope_Validate(t *testing.T) {
+	e := &Envelope{}
+	e.XMLName.Space, e.XMLName.Local = Namespace1, "Envelope"
+	e.References = []File{{ID: "f", Href: "../disk.vmdk"}, {ID: "f", Href: "/etc/passwd"}}
+	e.Disks = []Disk{{DiskID: "d", FileRef: "missing"}}
+	err := e.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{
+		`href "../disk.vmdk"`,
+		`href "/etc/passwd"`,
+		`duplicate id "f"`,
+		`fileRef "missing"`,
+		"capacity is not set",
+		"no VirtualSystem",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %s", expected, err)
+		}
+	}
+
+	e = &Envelope{}
+	e.XMLName.Local = "Project"
+	if err := e.Validate(); err == nil {
+		t.Fatal("expected an error for a non OVF document")
+	}
+}
+
+func TestSetProperties(t *testing.T) {
+	out, err := SetProperties([]byte(testDescriptor), map[string]string{
+		"hostname": "web-1",
+		"password": `p&"ss`,
+		"role":     "web",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s := string(out)
+	for _, expected := range []string{
+		`<Property ovf:key="hostname" ovf:type="string" ovf:userConfigurable="true" ovf:value="web-1">`,
+		`<Property ovf:key="password" ovf:type="password" ovf:userConfigurable="true" ovf:value="p&amp;&#34;ss"/>`,
+		"\n      <Property ovf:key=\"role\" ovf:type=\"string\" ovf:userConfigurable=\"true\" ovf:value=\"web\"/>\n    </ProductSection>",
+		`<vmw:BootOrderSection vmw:type="disk"/>`,
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("expected %q in:\n%s", expected, s)
+		}
+	}
+
+	e, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	values := map[string]string{}
+	for _, p := range e.VirtualSystems[0].Products[0].Properties {
+		values[p.Key] = p.Value
+	}
+	if expected := map[string]string{"hostname": "web-1", "password": `p&"ss`, "role": "web"}; !reflect.DeepEqual(values, expected) {
+		t.Fatalf("bad values: %#v", values)
+	}
+}
+
+func TestSetProperties_newProductSection(t *testing.T) {
+	descriptor := `<ovf:Envelope xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <ovf:VirtualSystem ovf:id="vm">
+    <ovf:Info>A virtual machine</ovf:Info>
+  </ovf:VirtualSystem>
+</ovf:Envelope>
+`
+	out, err := SetProperties([]byte(descriptor), map[string]string{"role": "db"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `<ovf:Envelope xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <ovf:VirtualSystem ovf:id="vm">
+    <ovf:Info>A virtual machine</ovf:Info>
+    <ovf:ProductSection>
+      <ovf:Info>Properties of the virtual system</ovf:Info>
+      <ovf:Property ovf:key="role" ovf:type="string" ovf:userConfigurable="true" ovf:value="db"/>
+    </ovf:ProductSection>
+  </ovf:VirtualSystem>
+</ovf:Envelope>
+`
+	if string(out) != expected {
+		t.Fatalf("bad descriptor:\n%s", out)
+	}
+
+	if _, err := SetProperties([]byte("<Project/>"), map[string]string{"a": "b"}); err == nil {
+		t.Fatal("expected an error for a non OVF document")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	ovfPath := writeTestPackage(t)
+	dir := filepath.Dir(ovfPath)
+	m, err := NewManifest("sha256", dir, []string{"packer.ovf", "disk1.vmdk"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := m.WriteFile(ManifestPath(ovfPath)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, "packer.mf"))
+	diskSum := sha256.Sum256([]byte("disk"))
+	if !strings.HasPrefix(string(b), "SHA256(packer.ovf)= ") ||
+		!strings.HasSuffix(string(b), "\nSHA256(disk1.vmdk)= "+hex.EncodeToString(diskSum[:])+"\n") {
+		t.Fatalf("bad manifest:\n%s", b)
+	}
+
+	parsed, err := ParseManifestFile(filepath.Join(dir, "packer.mf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(parsed, m) {
+		t.Fatalf("expected %#v, got %#v", m, parsed)
+	}
+	if err := parsed.Verify(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Properties set afterwards update the checksum of the descriptor.
+	if err := SetPropertiesFile(ovfPath, map[string]string{"hostname": "web-1"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	parsed, _ = ParseManifestFile(filepath.Join(dir, "packer.mf"))
+	if err := parsed.Verify(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "disk1.vmdk"), []byte("corrupted"), 0644)
+	if err := parsed.Verify(dir); err == nil || !strings.Contains(err.Error(), "disk1.vmdk: SHA256 checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+}
+
+func TestCreateExtractOVA(t *testing.T) {
+	ovfPath := writeTestPackage(t)
+	ovaPath := filepath.Join(t.TempDir(), "packer.ova")
+	if err := CreateOVA(context.Background(), ovfPath, ovaPath, "SHA256"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	f, err := os.Open(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	f.Close()
+	if expected := []string{"packer.ovf", "packer.mf", "disk1.vmdk"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+
+	dir := t.TempDir()
+	extracted, err := ExtractOVA(context.Background(), ovaPath, dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if extracted != filepath.Join(dir, "packer.ovf") {
+		t.Fatalf("bad descriptor path: %s", extracted)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, "disk1.vmdk"))
+	if string(b) != "disk" {
+		t.Fatalf("bad disk: %q", b)
+	}
+}
+
+func TestExtractOVA_invalid(t *testing.T) {
+	write := func(t *testing.T, files ...string) string {
+		path := filepath.Join(t.TempDir(), "test.ova")
+		f, _ := os.Create(path)
+		tw := tar.NewWriter(f)
+		for _, name := range files {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1})
+			tw.Write([]byte("x"))
+		}
+		tw.Close()
+		f.Close()
+		return path
+	}
+
+	for name, files := range map[string][]string{
+		"traversal":        {"test.ovf", "../evil"},
+		"descriptor first": {"disk1.vmdk", "test.ovf"},
+		"bad checksum":     {"test.ovf", "test.mf"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ExtractOVA(context.Background(), write(t, files...), t.TempDir()); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
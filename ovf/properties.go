@@ -0,0 +1,255 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ovf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// edit replaces the bytes from start to end of a descriptor with text.
+type edit struct {
+	start, end int64
+	text       string
+}
+
+// SetProperties sets the values of the properties of the first virtual
+// system of the descriptor, adding the ones it doesn't have to its first
+// ProductSection, or to a new one, as user configurable strings. The rest of
+// the descriptor is left as is.
+func SetProperties(descriptor []byte, props map[string]string) ([]byte, error) {
+	if len(props) == 0 {
+		return descriptor, nil
+	}
+	d := xml.NewDecoder(bytes.NewReader(descriptor))
+	var (
+		edits []edit
+		// stack holds the local names of the elements the decoder is in.
+		stack []string
+		// attrPrefix is the prefix of the OVF attributes, like "ovf:".
+		attrPrefix string
+		// elemPrefix is the prefix of the elements of the section edited.
+		elemPrefix string
+		// productDepth is the depth of the ProductSection edited, 0 when
+		// the decoder is not in it.
+		productDepth int
+		vsDepth      int
+		done         bool
+		set          = map[string]bool{}
+		// space is the whitespace before the current token, to indent what
+		// is added like the rest of the descriptor.
+		space string
+	)
+	for {
+		start := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid OVF descriptor: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			raw := string(descriptor[start:d.InputOffset()])
+			if len(stack) == 0 {
+				if t.Name.Local != "Envelope" || (t.Name.Space != Namespace1 && t.Name.Space != Namespace2) {
+					return nil, errors.New("the root element is not an OVF Envelope")
+				}
+				attrPrefix = namespacePrefix(t, t.Name.Space)
+			}
+			stack = append(stack, t.Name.Local)
+			switch {
+			case done:
+			case t.Name.Local == "VirtualSystem" && vsDepth == 0:
+				vsDepth = len(stack)
+				elemPrefix = rawPrefix(raw)
+			case t.Name.Local == "ProductSection" && vsDepth > 0 && len(stack) == vsDepth+1 && productDepth == 0:
+				productDepth = len(stack)
+				elemPrefix = rawPrefix(raw)
+			case t.Name.Local == "Property" && productDepth > 0 && len(stack) == productDepth+1:
+				key := attrValue(t, "key")
+				if v, ok := props[key]; ok && !set[key] {
+					set[key] = true
+					edits = append(edits, edit{start, d.InputOffset(), setAttr(raw, attrPrefix+"value", v)})
+				}
+			}
+		case xml.EndElement:
+			depth := len(stack)
+			stack = stack[:depth-1]
+			switch {
+			case done:
+			case productDepth > 0 && depth == productDepth:
+				var b strings.Builder
+				for _, key := range missingKeys(props, set) {
+					// The whitespace before the closing tag indents the
+					// property, and what follows it the closing tag.
+					b.WriteString(pad(space))
+					b.WriteString(newProperty(elemPrefix, attrPrefix, key, props[key]))
+					b.WriteString(space)
+				}
+				edits = append(edits, edit{start, start, b.String()})
+				done = true
+			case vsDepth > 0 && depth == vsDepth:
+				var b strings.Builder
+				b.WriteString(pad(space))
+				fmt.Fprintf(&b, "<%sProductSection>", elemPrefix)
+				fmt.Fprintf(&b, "%s<%sInfo>Properties of the virtual system</%sInfo>", indent(space, 2), elemPrefix, elemPrefix)
+				for _, key := range missingKeys(props, set) {
+					b.WriteString(indent(space, 2))
+					b.WriteString(newProperty(elemPrefix, attrPrefix, key, props[key]))
+				}
+				fmt.Fprintf(&b, "%s</%sProductSection>%s", indent(space, 1), elemPrefix, space)
+				edits = append(edits, edit{start, start, b.String()})
+				done = true
+			}
+		}
+		space = ""
+		if cd, ok := tok.(xml.CharData); ok && len(bytes.TrimSpace(cd)) == 0 {
+			space = string(cd)
+		}
+	}
+	if !done {
+		return nil, errors.New("the envelope has no VirtualSystem")
+	}
+
+	var out bytes.Buffer
+	last := int64(0)
+	for _, e := range edits {
+		out.Write(descriptor[last:e.start])
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.Write(descriptor[last:])
+	return out.Bytes(), nil
+}
+
+// SetPropertiesFile sets props in the descriptor at ovfPath with
+// SetProperties, and updates its checksum in its manifest if it has one.
+func SetPropertiesFile(ovfPath string, props map[string]string) error {
+	descriptor, err := os.ReadFile(ovfPath)
+	if err != nil {
+		return err
+	}
+	descriptor, err = SetProperties(descriptor, props)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ovfPath, err)
+	}
+	if err := os.WriteFile(ovfPath, descriptor, 0644); err != nil {
+		return err
+	}
+
+	mfPath := ManifestPath(ovfPath)
+	m, err := ParseManifestFile(mfPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", mfPath, err)
+	}
+	name := filepath.Base(ovfPath)
+	algorithm := "SHA256"
+	for _, e := range m.Entries {
+		if e.File == name {
+			algorithm = e.Algorithm
+		}
+	}
+	if err := m.Set(algorithm, filepath.Dir(ovfPath), name); err != nil {
+		return err
+	}
+	return m.WriteFile(mfPath)
+}
+
+// missingKeys returns the keys of props not in set, sorted.
+func missingKeys(props map[string]string, set map[string]bool) []string {
+	var keys []string
+	for key := range props {
+		if !set[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newProperty(elemPrefix, attrPrefix, key, value string) string {
+	return fmt.Sprintf(`<%sProperty %skey="%s" %stype="string" %suserConfigurable="true" %svalue="%s"/>`,
+		elemPrefix, attrPrefix, escape(key), attrPrefix, attrPrefix, attrPrefix, escape(value))
+}
+
+// indent returns space, the whitespace before a closing tag, indented by
+// level more steps, or "" when the descriptor is not indented.
+func indent(space string, level int) string {
+	if space == "" {
+		return ""
+	}
+	return space + strings.Repeat("  ", level)
+}
+
+// pad returns the indentation of a child of the element closed after space,
+// relative to the closing tag.
+func pad(space string) string {
+	if space == "" {
+		return ""
+	}
+	return "  "
+}
+
+// namespacePrefix returns the prefix declared for ns by the start element,
+// with its colon, or "" when ns is the default namespace.
+func namespacePrefix(t xml.StartElement, ns string) string {
+	for _, a := range t.Attr {
+		if a.Name.Space == "xmlns" && a.Value == ns {
+			return a.Name.Local + ":"
+		}
+	}
+	return ""
+}
+
+// rawPrefix returns the prefix of the raw start tag, like "ovf:" for
+// `<ovf:ProductSection>`.
+func rawPrefix(raw string) string {
+	name := strings.TrimPrefix(raw, "<")
+	if i := strings.IndexAny(name, " \t\r\n/>"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i+1]
+	}
+	return ""
+}
+
+func attrValue(t xml.StartElement, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// setAttr sets the attribute name of the raw start tag to value.
+func setAttr(raw, name, value string) string {
+	re := regexp.MustCompile(`(\s` + regexp.QuoteMeta(name) + `\s*=\s*)("[^"]*"|'[^']*')`)
+	if loc := re.FindStringSubmatchIndex(raw); loc != nil {
+		return raw[:loc[4]] + `"` + escape(value) + `"` + raw[loc[5]:]
+	}
+	body := strings.TrimSuffix(strings.TrimSuffix(raw, ">"), "/")
+	body = strings.TrimRight(body, " \t\r\n")
+	return body + fmt.Sprintf(` %s="%s"`, name, escape(value)) + raw[len(body):]
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DestroyOptions tell DestroyArtifact how to destroy an artifact.
+type DestroyOptions struct {
+	// DryRun reports what would be destroyed, without destroying anything.
+	DryRun bool
+	// Force destroys the resources that other resources depend on, like a
+	// snapshot backing an image, or an image instances were launched from.
+	// Without it, they are skipped.
+	Force bool
+}
+
+// DestroyStatus is what happened to a resource of an artifact.
+type DestroyStatus string
+
+const (
+	// Destroyed resources are deleted.
+	Destroyed DestroyStatus = "destroyed"
+	// WouldDestroy resources would be deleted but for DryRun.
+	WouldDestroy DestroyStatus = "would_destroy"
+	// Skipped resources are kept because other resources depend on them and
+	// Force isn't set.
+	Skipped DestroyStatus = "skipped"
+	// NotFound resources were already deleted.
+	NotFound DestroyStatus = "not_found"
+	// DestroyFailed resources couldn't be deleted.
+	DestroyFailed DestroyStatus = "failed"
+)
+
+// DestroyResult is the result of destroying one of the resources of an
+// artifact.
+type DestroyResult struct {
+	// Type is the type of the resource, like "image", "snapshot" or "file".
+	Type string
+	// ID identifies the resource.
+	ID     string
+	Status DestroyStatus
+	// Dependents are the resources depending on this one, which is skipped
+	// without Force.
+	Dependents []string
+	// Error tells why the resource was skipped or couldn't be deleted.
+	Error string
+}
+
+// DestroyableArtifact is implemented by artifacts made of several resources,
+// which can tell what they would destroy and how it went for each resource.
+type DestroyableArtifact interface {
+	Artifact
+
+	// DestroyWithOptions destroys the resources of the artifact, or only
+	// reports them with opts.DryRun, and returns a result per resource. It
+	// fails when a resource couldn't be destroyed, still returning the
+	// results of all of them.
+	DestroyWithOptions(ctx context.Context, opts DestroyOptions) ([]DestroyResult, error)
+}
+
+// DestroyArtifact destroys a with opts. Artifacts that are not
+// DestroyableArtifacts are reported as a single resource; with DryRun they
+// are not destroyed, and Force makes no difference to them.
+func DestroyArtifact(ctx context.Context, a Artifact, opts DestroyOptions) ([]DestroyResult, error) {
+	if d, ok := a.(DestroyableArtifact); ok {
+		return d.DestroyWithOptions(ctx, opts)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := DestroyResult{Type: "artifact", ID: a.Id(), Status: WouldDestroy}
+	if opts.DryRun {
+		return []DestroyResult{result}, nil
+	}
+	result.Status = Destroyed
+	err := a.Destroy()
+	if err != nil {
+		result.Status = DestroyFailed
+		result.Error = err.Error()
+	}
+	return []DestroyResult{result}, err
+}
+
+// DestroyError is returned by DestroyableArtifacts when some of their
+// resources couldn't be destroyed.
+type DestroyError struct {
+	Results []DestroyResult
+}
+
+func (e *DestroyError) Error() string {
+	var failed []string
+	for _, r := range e.Results {
+		if r.Status == DestroyFailed {
+			failed = append(failed, fmt.Sprintf("%s %s: %s", r.Type, r.ID, r.Error))
+		}
+	}
+	return fmt.Sprintf("failed to destroy %d resources: %s", len(failed), strings.Join(failed, "; "))
+}
+
+// DestroyResultsError returns a *DestroyError when some of results failed,
+// nil otherwise: DestroyableArtifacts return it from DestroyWithOptions.
+func DestroyResultsError(results []DestroyResult) error {
+	for _, r := range results {
+		if r.Status == DestroyFailed {
+			return &DestroyError{Results: results}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDestroyArtifact(t *testing.T) {
+	a := new(MockArtifact)
+	results, err := DestroyArtifact(context.Background(), a, DestroyOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []DestroyResult{{Type: "artifact", ID: "id", Status: WouldDestroy}}
+	if !reflect.DeepEqual(results, expected) || a.DestroyCalled {
+		t.Fatalf("a dry run should not destroy the artifact: %#v", results)
+	}
+
+	results, err = DestroyArtifact(context.Background(), a, DestroyOptions{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected[0].Status = Destroyed
+	if !reflect.DeepEqual(results, expected) || !a.DestroyCalled {
+		t.Fatalf("the artifact should be destroyed: %#v", results)
+	}
+}
+
+func TestDestroyResultsError(t *testing.T) {
+	results := []DestroyResult{
+		{Type: "image", ID: "ami-1", Status: Destroyed},
+		{Type: "snapshot", ID: "snap-1", Status: Skipped, Dependents: []string{"ami-2"}},
+	}
+	if err := DestroyResultsError(results); err != nil {
+		t.Fatalf("skipped resources are not failures: %s", err)
+	}
+
+	results = append(results, DestroyResult{Type: "snapshot", ID: "snap-2", Status: DestroyFailed, Error: "denied"})
+	err := DestroyResultsError(results)
+	var derr *DestroyError
+	if !errors.As(err, &derr) || !reflect.DeepEqual(derr.Results, results) {
+		t.Fatalf("bad error: %#v", err)
+	}
+	if err.Error() != "failed to destroy 1 resources: snapshot snap-2: denied" {
+		t.Fatalf("bad message: %s", err)
+	}
+}
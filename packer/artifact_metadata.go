@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"os"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ArtifactV2 is an Artifact describing itself with structured metadata on
+// top of its free form State, for registries and post-processors to consume.
+// Use ArtifactMetadataOf to read the metadata of any artifact.
+type ArtifactV2 interface {
+	Artifact
+
+	// Metadata returns the metadata of the artifact, nil when it has none.
+	Metadata() *ArtifactMetadata
+}
+
+// ArtifactMetadata describes an artifact.
+type ArtifactMetadata struct {
+	// CreatedAt is when the artifact was created, zero when unknown.
+	CreatedAt time.Time
+
+	// Size is the size of the artifact in bytes, 0 when unknown.
+	Size int64
+
+	// Checksums of the artifact, in hexadecimal, by algorithm, like
+	// "sha256".
+	Checksums map[string]string
+
+	// Provenance tells how the artifact was built.
+	Provenance ArtifactProvenance
+
+	// Values are the metadata specific to the builder, like the region of
+	// a cloud image. They keep their type across RPC, unlike State.
+	Values map[string]cty.Value
+}
+
+// ArtifactProvenance tells how an artifact was built.
+type ArtifactProvenance struct {
+	// BuildName is the name of the build that created the artifact.
+	BuildName string
+
+	// Sources are what the artifact was built from, like the ID of a source
+	// image or the URL of an ISO.
+	Sources []string
+
+	// PluginVersion is the version of the plugin that built the artifact.
+	PluginVersion string
+}
+
+// ArtifactMetadataOf returns the metadata of a. For artifacts that are not
+// ArtifactV2s, or have no metadata, it is what can be inferred from the
+// artifact: the size of its files, when they are local.
+func ArtifactMetadataOf(a Artifact) *ArtifactMetadata {
+	if v2, ok := a.(ArtifactV2); ok {
+		if md := v2.Metadata(); md != nil {
+			return md
+		}
+	}
+
+	md := new(ArtifactMetadata)
+	for _, f := range a.Files() {
+		fi, err := os.Stat(f)
+		if err != nil || !fi.Mode().IsRegular() {
+			md.Size = 0
+			break
+		}
+		md.Size += fi.Size()
+	}
+	return md
+}
+
+// UpgradeArtifact returns a as an ArtifactV2. Artifacts that are not already
+// ArtifactV2s are adapted, with the metadata of ArtifactMetadataOf.
+func UpgradeArtifact(a Artifact) ArtifactV2 {
+	if v2, ok := a.(ArtifactV2); ok {
+		return v2
+	}
+	return &artifactV1{Artifact: a}
+}
+
+type artifactV1 struct {
+	Artifact
+}
+
+func (a *artifactV1) Metadata() *ArtifactMetadata {
+	return ArtifactMetadataOf(a.Artifact)
+}
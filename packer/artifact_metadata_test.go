@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArtifactMetadataOf(t *testing.T) {
+	md := &ArtifactMetadata{Size: 42}
+	if got := ArtifactMetadataOf(&MockArtifact{MetadataValue: md}); got != md {
+		t.Fatalf("the metadata of the artifact should be returned: %#v", got)
+	}
+
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "disk.qcow2"), filepath.Join(dir, "disk.ovf")}
+	for i, f := range files {
+		if err := os.WriteFile(f, make([]byte, 10*(i+1)), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if got := ArtifactMetadataOf(&MockArtifact{FilesValue: files}); got.Size != 30 {
+		t.Fatalf("the size should be the one of the files: %d", got.Size)
+	}
+
+	files = append(files, filepath.Join(dir, "missing"))
+	if got := ArtifactMetadataOf(&MockArtifact{FilesValue: files}); got.Size != 0 {
+		t.Fatalf("the size should be unknown when a file is not local: %d", got.Size)
+	}
+}
+
+func TestUpgradeArtifact(t *testing.T) {
+	a := new(MockArtifact)
+	if UpgradeArtifact(a) != ArtifactV2(a) {
+		t.Fatal("ArtifactV2s should be returned as is")
+	}
+
+	v2 := UpgradeArtifact(new(TestArtifact))
+	if v2.Id() != "id" || v2.Metadata() == nil {
+		t.Fatalf("bad adapted artifact: %#v", v2)
+	}
+}
@@ -11,6 +11,7 @@ type MockArtifact struct {
 	StateValues    map[string]interface{}
 	DestroyCalled  bool
 	StringValue    string
+	MetadataValue  *ArtifactMetadata
 }
 
 func (a *MockArtifact) BuilderId() string {
@@ -55,3 +56,7 @@ func (a *MockArtifact) Destroy() error {
 	a.DestroyCalled = true
 	return nil
 }
+
+func (a *MockArtifact) Metadata() *ArtifactMetadata {
+	return a.MetadataValue
+}
@@ -34,7 +34,10 @@ type Builder interface {
 	//
 	// Prepare should return a list of variables that will be made accessible to
 	// users during the provision methods, a list of warnings along with any
-	// errors that occurred while preparing.
+	// errors that occurred while preparing. A *DiagnosticsError can be
+	// returned instead of the warnings and a plain error to report them with
+	// a detail and the attribute they are about, or the builder can
+	// implement DiagnosticsBuilder.
 	Prepare(...interface{}) ([]string, []string, error)
 
 	// Run is where the actual build should take place. It takes a Build and a Ui.
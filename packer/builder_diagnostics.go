@@ -0,0 +1,44 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"github.com/hashicorp/hcl/v2"
+)
+
+// DiagnosticsBuilder is implemented by builders reporting the problems of
+// their configuration as diagnostics, with a severity, a detail and the
+// attribute or range they are about, rather than as warning strings and an
+// error.
+//
+// They still implement Prepare, for the versions of Packer only calling it,
+// usually with LegacyPrepare.
+type DiagnosticsBuilder interface {
+	Builder
+
+	// PrepareWithDiagnostics is like Prepare, the preparation having
+	// failed when the diagnostics contain an error.
+	PrepareWithDiagnostics(...interface{}) ([]string, hcl.Diagnostics)
+}
+
+// PrepareBuilder prepares b and returns the variables it generates and its
+// diagnostics, calling PrepareWithDiagnostics when b implements it, and
+// turning what Prepare returned into diagnostics otherwise.
+func PrepareBuilder(b Builder, raws ...interface{}) ([]string, hcl.Diagnostics) {
+	if db, ok := b.(DiagnosticsBuilder); ok {
+		return db.PrepareWithDiagnostics(raws...)
+	}
+	generated, warnings, err := b.Prepare(raws...)
+	return generated, PrepareDiagnostics(warnings, err)
+}
+
+// LegacyPrepare implements Prepare for a DiagnosticsBuilder, returning its
+// diagnostics in a *DiagnosticsError.
+func LegacyPrepare(b DiagnosticsBuilder, raws ...interface{}) ([]string, []string, error) {
+	generated, diags := b.PrepareWithDiagnostics(raws...)
+	if len(diags) == 0 {
+		return generated, nil, nil
+	}
+	return generated, nil, &DiagnosticsError{Diagnostics: diags}
+}
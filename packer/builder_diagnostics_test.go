@@ -0,0 +1,32 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+type legacyErrorBuilder struct {
+	MockBuilder
+}
+
+func (b *legacyErrorBuilder) Prepare(...interface{}) ([]string, []string, error) {
+	return []string{"Id"}, []string{"deprecated option"}, &MultiError{Errors: []error{errors.New("a"), errors.New("b")}}
+}
+
+func TestPrepareBuilder_legacy(t *testing.T) {
+	generated, diags := PrepareBuilder(new(legacyErrorBuilder))
+	if len(generated) != 1 || generated[0] != "Id" {
+		t.Fatalf("bad generated vars: %v", generated)
+	}
+	if len(diags) != 3 || diags[0].Severity != hcl.DiagWarning || diags[0].Summary != "deprecated option" {
+		t.Fatalf("bad diagnostics: %#v", diags)
+	}
+	if diags[1].Severity != hcl.DiagError || diags[2].Summary != "b" {
+		t.Fatalf("bad diagnostics: %#v", diags)
+	}
+}
@@ -5,10 +5,13 @@ package packer
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 
 	"github.com/mitchellh/iochan"
@@ -18,6 +21,10 @@ import (
 // exited because the remote side disconnected us.
 const CmdDisconnect int = 2300218
 
+// ErrInactivityTimeout is returned by RunWithUi when a command did not
+// produce any output for longer than its InactivityTimeout.
+var ErrInactivityTimeout = errors.New("remote command inactivity timeout")
+
 // RemoteCmd represents a remote command being prepared or run.
 type RemoteCmd struct {
 	// Command is the command to run remotely. This is executed as if
@@ -36,6 +43,11 @@ type RemoteCmd struct {
 	Stdout io.Writer
 	Stderr io.Writer
 
+	// InactivityTimeout, if set, makes RunWithUi cancel the command and
+	// fail when neither stdout nor stderr received anything for that long,
+	// for example when a package mirror hangs. It is ignored by Start.
+	InactivityTimeout time.Duration
+
 	// Once Exited is true, this will contain the exit code of the process.
 	exitStatus int
 
@@ -83,6 +95,219 @@ type Communicator interface {
 	DownloadDir(src string, dst string, exclude []string) error
 }
 
+// CommunicatorCapabilities describes the optional features of a
+// Communicator.
+type CommunicatorCapabilities struct {
+	// DownloadDir is true if DownloadDir is implemented.
+	DownloadDir bool
+	// PTY is true if commands can be run in a pseudo terminal.
+	PTY bool
+	// Env is true if commands inherit environment variables set on the
+	// Packer side.
+	Env bool
+	// UnixSockets is true if unix domain sockets can be forwarded to the
+	// remote machine, as required for SSH agent forwarding.
+	UnixSockets bool
+	// Tunnels is true if ports can be forwarded with Tunnel and
+	// ReverseTunnel.
+	Tunnels bool
+	// Reboot is true if the communicator reconnects to the machine after
+	// it reboots, so that provisioners can restart it.
+	Reboot bool
+}
+
+// Missing returns the names of the capabilities set in required that c does
+// not have, in a form suitable for error messages.
+func (c CommunicatorCapabilities) Missing(required CommunicatorCapabilities) []string {
+	var missing []string
+	if required.DownloadDir && !c.DownloadDir {
+		missing = append(missing, "directory download")
+	}
+	if required.PTY && !c.PTY {
+		missing = append(missing, "pseudo terminals")
+	}
+	if required.Env && !c.Env {
+		missing = append(missing, "environment variables")
+	}
+	if required.UnixSockets && !c.UnixSockets {
+		missing = append(missing, "unix socket forwarding")
+	}
+	if required.Tunnels && !c.Tunnels {
+		missing = append(missing, "port forwarding")
+	}
+	if required.Reboot && !c.Reboot {
+		missing = append(missing, "reconnecting after reboots")
+	}
+	return missing
+}
+
+// CapableCommunicator is implemented by communicators that can report their
+// capabilities, so that provisioners can skip or refuse an operation with a
+// clear message instead of failing in the middle of a run.
+type CapableCommunicator interface {
+	// Capabilities returns the features supported by the communicator. ok
+	// is false when they cannot be determined, for example when the
+	// communicator lives in a plugin built with an older SDK.
+	Capabilities() (caps CommunicatorCapabilities, ok bool)
+}
+
+// CapabilitiesOf returns the capabilities of c. ok is false if c does not
+// report them, in which case callers should assume that everything is
+// supported and handle errors as they used to.
+func CapabilitiesOf(c Communicator) (caps CommunicatorCapabilities, ok bool) {
+	if cc, isCapable := c.(CapableCommunicator); isCapable {
+		return cc.Capabilities()
+	}
+	return CommunicatorCapabilities{}, false
+}
+
+// ErrTunnelsNotSupported is returned by OpenTunnel and OpenReverseTunnel when
+// the communicator cannot forward ports.
+var ErrTunnelsNotSupported = errors.New("the communicator does not support port forwarding")
+
+// A Tunnel is a port forward opened by a TunnelCommunicator. It forwards
+// connections until it is closed or the communicator disconnects.
+type Tunnel interface {
+	// Addr is the address the tunnel listens on, with the port that was
+	// picked if 0 was asked for.
+	Addr() string
+	// Close stops listening. Connections already forwarded are left to
+	// finish.
+	Close() error
+}
+
+// TunnelCommunicator is implemented by communicators that can forward ports,
+// so that steps can reach services of the remote machine, or the other way
+// around, without setting up their own SSH tunnels. Addresses are host:port
+// strings; the context only bounds opening the tunnel.
+type TunnelCommunicator interface {
+	// Tunnel listens on local, on the Packer side, and forwards the
+	// connections it accepts to remote, dialed from the remote machine.
+	Tunnel(ctx context.Context, local, remote string) (Tunnel, error)
+
+	// ReverseTunnel listens on remote, on the remote machine, and forwards
+	// the connections it accepts to local, dialed from the Packer side.
+	ReverseTunnel(ctx context.Context, remote, local string) (Tunnel, error)
+}
+
+// OpenTunnel opens a Tunnel from local to remote through c, or returns
+// ErrTunnelsNotSupported when c cannot forward ports.
+func OpenTunnel(ctx context.Context, c Communicator, local, remote string) (Tunnel, error) {
+	tc, ok := c.(TunnelCommunicator)
+	if !ok {
+		return nil, ErrTunnelsNotSupported
+	}
+	return tc.Tunnel(ctx, local, remote)
+}
+
+// OpenReverseTunnel opens a ReverseTunnel from remote to local through c, or
+// returns ErrTunnelsNotSupported when c cannot forward ports.
+func OpenReverseTunnel(ctx context.Context, c Communicator, remote, local string) (Tunnel, error) {
+	tc, ok := c.(TunnelCommunicator)
+	if !ok {
+		return nil, ErrTunnelsNotSupported
+	}
+	return tc.ReverseTunnel(ctx, remote, local)
+}
+
+// CommunicatorWithContext is implemented by communicators whose transfers
+// can be cancelled. Once ctx is done, a transfer stops and returns ctx.Err();
+// what was already transferred is left as is. Their Upload, UploadDir,
+// Download and DownloadDir methods are the same as these with
+// context.Background().
+type CommunicatorWithContext interface {
+	UploadContext(ctx context.Context, dst string, r io.Reader, fi *os.FileInfo) error
+	UploadDirContext(ctx context.Context, dst string, src string, exclude []string) error
+	DownloadContext(ctx context.Context, src string, w io.Writer) error
+	DownloadDirContext(ctx context.Context, src string, dst string, exclude []string) error
+}
+
+// UploadContext uploads r to dst with c until ctx is done. When c doesn't
+// implement CommunicatorWithContext, reading r fails once ctx is done, which
+// stops the upload at the next read.
+func UploadContext(ctx context.Context, c Communicator, dst string, r io.Reader, fi *os.FileInfo) error {
+	if cc, ok := c.(CommunicatorWithContext); ok {
+		return cc.UploadContext(ctx, dst, r, fi)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := c.Upload(dst, &contextReader{ctx: ctx, r: r}, fi)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// UploadDirContext uploads src to dst with c until ctx is done. When c
+// doesn't implement CommunicatorWithContext, ctx is only checked before the
+// upload starts.
+func UploadDirContext(ctx context.Context, c Communicator, dst string, src string, exclude []string) error {
+	if cc, ok := c.(CommunicatorWithContext); ok {
+		return cc.UploadDirContext(ctx, dst, src, exclude)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.UploadDir(dst, src, exclude)
+}
+
+// DownloadContext downloads src to w with c until ctx is done. When c doesn't
+// implement CommunicatorWithContext, writing to w fails once ctx is done,
+// which stops the download at the next write.
+func DownloadContext(ctx context.Context, c Communicator, src string, w io.Writer) error {
+	if cc, ok := c.(CommunicatorWithContext); ok {
+		return cc.DownloadContext(ctx, src, w)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := c.Download(src, &contextWriter{ctx: ctx, w: w})
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// DownloadDirContext downloads src to dst with c until ctx is done. When c
+// doesn't implement CommunicatorWithContext, ctx is only checked before the
+// download starts.
+func DownloadDirContext(ctx context.Context, c Communicator, src string, dst string, exclude []string) error {
+	if cc, ok := c.(CommunicatorWithContext); ok {
+		return cc.DownloadDirContext(ctx, src, dst, exclude)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.DownloadDir(src, dst, exclude)
+}
+
+// contextReader reads from r until ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// contextWriter writes to w until ctx is done.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
 type ConfigurableCommunicator interface {
 	HCL2Speccer
 	Configure(...interface{}) ([]string, error)
@@ -90,10 +315,15 @@ type ConfigurableCommunicator interface {
 
 // RunWithUi runs the remote command and streams the output to any configured
 // Writers for stdout/stderr, while also writing each line as it comes to a Ui.
-// RunWithUi will not return until the command finishes or is cancelled.
+// RunWithUi will not return until the command finishes or is cancelled, or
+// until InactivityTimeout is reached in which case an error wrapping
+// ErrInactivityTimeout is returned.
 func (r *RemoteCmd) RunWithUi(ctx context.Context, c Communicator, ui Ui) error {
 	r.initchan()
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	stdout_r, stdout_w := io.Pipe()
 	stderr_r, stderr_w := io.Pipe()
 	defer stdout_w.Close()
@@ -110,17 +340,26 @@ func (r *RemoteCmd) RunWithUi(ctx context.Context, c Communicator, ui Ui) error
 		r.Stderr = originalStderr
 	}()
 
+	// Any write counts as activity, even when it doesn't end a line, so that
+	// progress bars keep a command alive.
+	activity := make(chan struct{}, 1)
+	var stdout, stderr io.Writer = stdout_w, stderr_w
+	if r.InactivityTimeout > 0 {
+		stdout = &activityWriter{w: stdout_w, activity: activity}
+		stderr = &activityWriter{w: stderr_w, activity: activity}
+	}
+
 	// Set the writers for the output so that we get it streamed to us
 	if r.Stdout == nil {
-		r.Stdout = stdout_w
+		r.Stdout = stdout
 	} else {
-		r.Stdout = io.MultiWriter(r.Stdout, stdout_w)
+		r.Stdout = io.MultiWriter(r.Stdout, stdout)
 	}
 
 	if r.Stderr == nil {
-		r.Stderr = stderr_w
+		r.Stderr = stderr
 	} else {
-		r.Stderr = io.MultiWriter(r.Stderr, stderr_w)
+		r.Stderr = io.MultiWriter(r.Stderr, stderr)
 	}
 
 	// Start the command
@@ -139,6 +378,16 @@ func (r *RemoteCmd) RunWithUi(ctx context.Context, c Communicator, ui Ui) error
 		r.Wait()
 	}()
 
+	// A nil channel never fires, so there is no inactivity timeout unless
+	// one is set.
+	var inactive <-chan time.Time
+	var inactivity *time.Timer
+	if r.InactivityTimeout > 0 {
+		inactivity = time.NewTimer(r.InactivityTimeout)
+		defer inactivity.Stop()
+		inactive = inactivity.C
+	}
+
 	// Loop and get all our output
 OutputLoop:
 	for {
@@ -153,6 +402,20 @@ OutputLoop:
 			}
 		case <-r.exitCh:
 			break OutputLoop
+		case <-activity:
+			if !inactivity.Stop() {
+				select {
+				case <-inactivity.C:
+				default:
+				}
+			}
+			inactivity.Reset(r.InactivityTimeout)
+		case <-inactive:
+			// Cancelling the context tells the communicator to stop the
+			// command.
+			cancel()
+			return fmt.Errorf("%w: no output from %q for %s",
+				ErrInactivityTimeout, r.Command, r.InactivityTimeout)
 		case <-ctx.Done():
 			return ctx.Err()
 		}
@@ -171,6 +434,20 @@ OutputLoop:
 	return nil
 }
 
+// activityWriter signals every write on activity, without blocking.
+type activityWriter struct {
+	w        io.Writer
+	activity chan struct{}
+}
+
+func (w *activityWriter) Write(p []byte) (int, error) {
+	select {
+	case w.activity <- struct{}{}:
+	default:
+	}
+	return w.w.Write(p)
+}
+
 // SetExited is a helper for setting that this process is exited. This
 // should be called by communicators who are running a remote command in
 // order to set that the command is done.
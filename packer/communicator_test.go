@@ -6,7 +6,9 @@ package packer
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -71,6 +73,56 @@ func TestRemoteCmd_StartWithUi(t *testing.T) {
 	wg.Wait()
 }
 
+// slowCommunicator writes each line of lines to stdout after waiting for
+// delay, and then exits.
+type slowCommunicator struct {
+	MockCommunicator
+
+	lines []string
+	delay time.Duration
+}
+
+func (c *slowCommunicator) Start(ctx context.Context, rc *RemoteCmd) error {
+	go func() {
+		for _, line := range c.lines {
+			select {
+			case <-ctx.Done():
+				rc.SetExited(CmdDisconnect)
+				return
+			case <-time.After(c.delay):
+			}
+			io.WriteString(rc.Stdout, line)
+		}
+		rc.SetExited(0)
+	}()
+	return nil
+}
+
+func TestRemoteCmd_RunWithUi_InactivityTimeout(t *testing.T) {
+	ui := &BasicUi{Reader: new(bytes.Buffer), Writer: io.Discard, ErrorWriter: io.Discard}
+
+	// Partial lines count as activity.
+	comm := &slowCommunicator{lines: []string{"10%", "\r50%", "\r100%\n"}, delay: 20 * time.Millisecond}
+	rc := &RemoteCmd{Command: "progress", InactivityTimeout: 200 * time.Millisecond}
+	if err := rc.RunWithUi(context.Background(), comm, ui); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm = &slowCommunicator{lines: []string{"downloading\n", "done\n"}, delay: time.Hour}
+	rc = &RemoteCmd{Command: "apt-get update", InactivityTimeout: 20 * time.Millisecond}
+	err := rc.RunWithUi(context.Background(), comm, ui)
+	if !errors.Is(err, ErrInactivityTimeout) {
+		t.Fatalf("expected an inactivity timeout, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), `"apt-get update"`) {
+		t.Fatalf("the error should name the command: %s", err)
+	}
+	// The communicator is told to stop the command.
+	if status := rc.Wait(); status != CmdDisconnect {
+		t.Fatalf("the command should have been cancelled, got status %d", status)
+	}
+}
+
 func TestRemoteCmd_Wait(t *testing.T) {
 	var cmd RemoteCmd
 
@@ -89,3 +141,87 @@ func TestRemoteCmd_Wait(t *testing.T) {
 		t.Fatal("never got exit notification")
 	}
 }
+
+func TestOpenTunnel_unsupported(t *testing.T) {
+	c := new(MockCommunicator)
+	if _, err := OpenTunnel(context.Background(), c, "127.0.0.1:0", "localhost:80"); !errors.Is(err, ErrTunnelsNotSupported) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := OpenReverseTunnel(context.Background(), c, "0.0.0.0:80", "127.0.0.1:0"); !errors.Is(err, ErrTunnelsNotSupported) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	missing := CommunicatorCapabilities{}.Missing(CommunicatorCapabilities{Tunnels: true})
+	if len(missing) != 1 || missing[0] != "port forwarding" {
+		t.Fatalf("unexpected missing capabilities: %#v", missing)
+	}
+}
+
+// cancellingReader cancels the upload reading it after the first read.
+type cancellingReader struct {
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	r.reads++
+	r.cancel()
+	return copy(p, "x"), nil
+}
+
+// copyingCommunicator returns the errors of reading what it uploads.
+type copyingCommunicator struct {
+	MockCommunicator
+}
+
+func (c *copyingCommunicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+func TestUploadContext_fallback(t *testing.T) {
+	c := new(MockCommunicator)
+	if err := UploadContext(context.Background(), c, "/tmp/a", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.UploadPath != "/tmp/a" || c.UploadData != "data" {
+		t.Fatalf("bad upload: %s %q", c.UploadPath, c.UploadData)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &cancellingReader{cancel: cancel}
+	if err := UploadContext(ctx, new(copyingCommunicator), "/tmp/a", r, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the upload to be cancelled, got %v", err)
+	}
+	if r.reads != 1 {
+		t.Fatalf("expected the upload to stop reading, got %d reads", r.reads)
+	}
+
+	c = new(MockCommunicator)
+	if err := UploadDirContext(ctx, c, "/tmp", "dir", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the upload to be cancelled, got %v", err)
+	}
+	if c.UploadDirSrc != "" {
+		t.Fatal("the directory should not have been uploaded")
+	}
+}
+
+func TestDownloadContext_fallback(t *testing.T) {
+	c := &MockCommunicator{DownloadData: "data"}
+	var b bytes.Buffer
+	if err := DownloadContext(context.Background(), c, "/tmp/a", &b); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b.String() != "data" {
+		t.Fatalf("bad download: %q", b.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Reset()
+	if err := DownloadContext(ctx, c, "/tmp/a", &b); !errors.Is(err, context.Canceled) || b.Len() != 0 {
+		t.Fatalf("expected the download to be cancelled, got %v, %q", err, b.String())
+	}
+	if err := DownloadDirContext(ctx, c, "/tmp", "dir", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the download to be cancelled, got %v", err)
+	}
+}
@@ -4,6 +4,8 @@
 package packer
 
 import (
+	"context"
+
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -21,6 +23,72 @@ type Datasource interface {
 	// source correctly without having to execute the data source call.
 	OutputSpec() hcldec.ObjectSpec
 
-	// Execute the func call and return the values
+	// Execute the func call and return the values. Datasources that can be
+	// cancelled should also implement ContextDatasource.
 	Execute() (cty.Value, error)
 }
+
+// ContextDatasource is implemented by datasources that stop executing when
+// Packer cancels the build or when their timeout expires.
+type ContextDatasource interface {
+	Datasource
+
+	// ExecuteContext is Execute with a context, which is done when the
+	// execution must be abandoned; ExecuteContext then returns ctx.Err()
+	// as soon as it can.
+	ExecuteContext(ctx context.Context) (cty.Value, error)
+}
+
+// ExecuteDatasource executes d with ctx if it is a ContextDatasource, or
+// collects its output if it is a StreamingDatasource. Otherwise d.Execute
+// keeps running in the background when ctx is done first, but
+// ExecuteDatasource returns ctx.Err() right away.
+func ExecuteDatasource(ctx context.Context, d Datasource) (cty.Value, error) {
+	if s, ok := d.(StreamingDatasource); ok {
+		return CollectDatasourceStream(ctx, s)
+	}
+	if c, ok := d.(ContextDatasource); ok {
+		return c.ExecuteContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return cty.NilVal, err
+	}
+
+	type result struct {
+		v   cty.Value
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := d.Execute()
+		done <- result{v, err}
+	}()
+	select {
+	case res := <-done:
+		return res.v, res.err
+	case <-ctx.Done():
+		return cty.NilVal, ctx.Err()
+	}
+}
+
+// ReleasableDatasource is implemented by datasources that create ephemeral
+// resources when executed, for example a short lived token, and need to
+// delete them once the build is over.
+type ReleasableDatasource interface {
+	Datasource
+
+	// Release frees the resources created by Execute. Packer calls it
+	// once every build using the datasource is done, whether they
+	// succeeded or not, and again when the plugin is shut down, so Release
+	// must be safe to call more than once and when Execute was never
+	// called.
+	Release() error
+}
+
+// ReleaseDatasource calls Release on d if it is a ReleasableDatasource.
+func ReleaseDatasource(d Datasource) error {
+	if r, ok := d.(ReleasableDatasource); ok {
+		return r.Release()
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DatasourceRef references a datasource block of a configuration, written
+// `data.<type>.<name>` in HCL2.
+type DatasourceRef struct {
+	Type string
+	Name string
+}
+
+func (r DatasourceRef) String() string {
+	return "data." + r.Type + "." + r.Name
+}
+
+// ParseDatasourceRef parses a `data.<type>.<name>` reference. The `data.`
+// prefix is optional.
+func ParseDatasourceRef(s string) (DatasourceRef, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "data."), ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return DatasourceRef{}, fmt.Errorf("invalid datasource reference %q, expected data.<type>.<name>", s)
+	}
+	return DatasourceRef{Type: parts[0], Name: parts[1]}, nil
+}
+
+// DependentDatasource is implemented by datasources whose configuration
+// uses the output of other datasources. Packer executes the datasources it
+// depends on first.
+type DependentDatasource interface {
+	Datasource
+
+	// Dependencies returns the datasources that must be executed before
+	// this one. It is called after Configure.
+	Dependencies() []DatasourceRef
+}
+
+// DatasourceDependencies returns the dependencies of d, or nil when it
+// doesn't declare any.
+func DatasourceDependencies(d Datasource) []DatasourceRef {
+	if dd, ok := d.(DependentDatasource); ok {
+		return dd.Dependencies()
+	}
+	return nil
+}
+
+// SortDatasources returns the datasources of graph in an order where every
+// datasource comes after the ones it depends on, so that their Execute
+// methods can be called in sequence. graph maps each datasource to its
+// dependencies. Datasources that don't depend on each other are sorted by
+// name to keep the order stable.
+//
+// An error is returned if a dependency is not part of graph or if
+// dependencies form a cycle.
+func SortDatasources(graph map[DatasourceRef][]DatasourceRef) ([]DatasourceRef, error) {
+	refs := make([]DatasourceRef, 0, len(graph))
+	for ref, deps := range graph {
+		for _, dep := range deps {
+			if _, ok := graph[dep]; !ok {
+				return nil, fmt.Errorf("%s depends on %s, which is not defined", ref, dep)
+			}
+		}
+		refs = append(refs, ref)
+	}
+	sortRefs(refs)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[DatasourceRef]int, len(graph))
+	sorted := make([]DatasourceRef, 0, len(graph))
+	var path []DatasourceRef
+
+	var visit func(ref DatasourceRef) error
+	visit = func(ref DatasourceRef) error {
+		switch marks[ref] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := []string{ref.String()}
+			for i := len(path) - 1; i >= 0; i-- {
+				cycle = append([]string{path[i].String()}, cycle...)
+				if path[i] == ref {
+					break
+				}
+			}
+			return fmt.Errorf("datasource dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		marks[ref] = visiting
+		path = append(path, ref)
+		deps := append([]DatasourceRef{}, graph[ref]...)
+		sortRefs(deps)
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[ref] = visited
+		sorted = append(sorted, ref)
+		return nil
+	}
+
+	for _, ref := range refs {
+		if err := visit(ref); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+func sortRefs(refs []DatasourceRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].String() < refs[j].String()
+	})
+}
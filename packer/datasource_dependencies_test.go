@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDatasourceRef(t *testing.T) {
+	ref, err := ParseDatasourceRef("data.amazon-ami.base")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ref != (DatasourceRef{Type: "amazon-ami", Name: "base"}) || ref.String() != "data.amazon-ami.base" {
+		t.Fatalf("unexpected ref: %#v", ref)
+	}
+
+	for _, invalid := range []string{"", "data.amazon-ami", "data..base", "data.a.b.c"} {
+		if _, err := ParseDatasourceRef(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestSortDatasources(t *testing.T) {
+	base := DatasourceRef{Type: "amazon-ami", Name: "base"}
+	secret := DatasourceRef{Type: "vault", Name: "secret"}
+	image := DatasourceRef{Type: "image", Name: "final"}
+	other := DatasourceRef{Type: "http", Name: "other"}
+
+	sorted, err := SortDatasources(map[DatasourceRef][]DatasourceRef{
+		image:  {secret, base},
+		secret: {base},
+		base:   nil,
+		other:  nil,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []DatasourceRef{base, other, secret, image}
+	if !reflect.DeepEqual(sorted, expected) {
+		t.Fatalf("unexpected order: %v", sorted)
+	}
+
+	_, err = SortDatasources(map[DatasourceRef][]DatasourceRef{
+		image: {secret},
+	})
+	if err == nil || !strings.Contains(err.Error(), "not defined") {
+		t.Fatalf("expected an undefined dependency error, got: %v", err)
+	}
+
+	_, err = SortDatasources(map[DatasourceRef][]DatasourceRef{
+		base:   {image},
+		image:  {secret},
+		secret: {image},
+	})
+	expectedErr := "datasource dependency cycle: data.image.final -> data.vault.secret -> data.image.final"
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("unexpected cycle error: %v", err)
+	}
+}
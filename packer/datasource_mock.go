@@ -17,6 +17,7 @@ type MockDatasource struct {
 	ConfigureCalled  bool          `mapstructure-to-hcl2:",skip"`
 	ConfigureConfigs []interface{} `mapstructure-to-hcl2:",skip"`
 	ExecuteCalled    bool          `mapstructure-to-hcl2:",skip"`
+	ReleaseCalled    bool          `mapstructure-to-hcl2:",skip"`
 }
 
 type MockDatasourceResponse struct {
@@ -48,3 +49,8 @@ func (d *MockDatasource) Execute() (cty.Value, error) {
 		"foo": cty.StringVal(d.Foo),
 	}), nil
 }
+
+func (d *MockDatasource) Release() error {
+	d.ReleaseCalled = true
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// StreamingDatasource is implemented by datasources whose output holds a
+// large collection, like thousands of images or subnets. The elements of the
+// collection are sent one at a time, and transferred from the plugin to
+// Packer in chunks, instead of being held, and encoded, all at once.
+type StreamingDatasource interface {
+	Datasource
+
+	// StreamedAttribute is the name of the attribute of the output whose
+	// elements ExecuteStream sends: a list, set or tuple in OutputSpec.
+	StreamedAttribute() string
+
+	// ExecuteStream executes the datasource, calling send with each element
+	// of the streamed attribute in order, and returns the rest of the
+	// output, in which the streamed attribute is ignored. It stops when send
+	// fails, returning its error, and when ctx is done, like
+	// ContextDatasource.ExecuteContext.
+	ExecuteStream(ctx context.Context, send func(element cty.Value) error) (cty.Value, error)
+}
+
+// CollectDatasourceStream executes d, and returns its whole output, with the
+// elements sent set as the streamed attribute.
+func CollectDatasourceStream(ctx context.Context, d StreamingDatasource) (cty.Value, error) {
+	if err := ctx.Err(); err != nil {
+		return cty.NilVal, err
+	}
+	var elements []cty.Value
+	rest, err := d.ExecuteStream(ctx, func(v cty.Value) error {
+		elements = append(elements, v)
+		return nil
+	})
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return SetStreamedAttribute(d.OutputSpec(), rest, d.StreamedAttribute(), elements)
+}
+
+// SetStreamedAttribute returns rest, an object, with its attribute name set
+// to elements, converted to the type of the attribute in spec.
+func SetStreamedAttribute(spec hcldec.ObjectSpec, rest cty.Value, name string, elements []cty.Value) (cty.Value, error) {
+	ty := cty.DynamicPseudoType
+	if objTy := hcldec.ImpliedType(spec); objTy.IsObjectType() && objTy.HasAttribute(name) {
+		ty = objTy.AttributeType(name)
+	}
+
+	collection := cty.EmptyTupleVal
+	if len(elements) > 0 {
+		collection = cty.TupleVal(elements)
+	}
+	collection, err := convert.Convert(collection, ty)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("invalid %s: %s", name, err)
+	}
+
+	attrs := map[string]cty.Value{}
+	if !rest.IsNull() && rest.IsKnown() && rest.Type().IsObjectType() {
+		attrs = rest.AsValueMap()
+		if attrs == nil {
+			attrs = map[string]cty.Value{}
+		}
+	}
+	attrs[name] = collection
+	return cty.ObjectVal(attrs), nil
+}
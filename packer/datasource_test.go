@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type blockingDatasource struct {
+	MockDatasource
+
+	release chan struct{}
+}
+
+func (d *blockingDatasource) Execute() (cty.Value, error) {
+	<-d.release
+	return cty.True, nil
+}
+
+func TestExecuteDatasource(t *testing.T) {
+	d := new(MockDatasource)
+	v, err := ExecuteDatasource(context.Background(), d)
+	if err != nil || !d.ExecuteCalled || v.GetAttr("foo").AsString() != "bar" {
+		t.Fatalf("unexpected result: %#v, %v", v, err)
+	}
+
+	// Datasources ignoring contexts are abandoned when they are done.
+	blocking := &blockingDatasource{release: make(chan struct{})}
+	defer close(blocking.release)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ExecuteDatasource(ctx, blocking); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+type imagesDatasource struct {
+	MockDatasource
+
+	count int
+}
+
+func (d *imagesDatasource) OutputSpec() hcldec.ObjectSpec {
+	return hcldec.ObjectSpec{
+		"region": &hcldec.AttrSpec{Name: "region", Type: cty.String},
+		"ids":    &hcldec.AttrSpec{Name: "ids", Type: cty.List(cty.String)},
+	}
+}
+
+func (d *imagesDatasource) StreamedAttribute() string { return "ids" }
+
+func (d *imagesDatasource) ExecuteStream(ctx context.Context, send func(cty.Value) error) (cty.Value, error) {
+	for i := 0; i < d.count; i++ {
+		if err := send(cty.StringVal(fmt.Sprintf("ami-%d", i))); err != nil {
+			return cty.NilVal, err
+		}
+	}
+	return cty.ObjectVal(map[string]cty.Value{"region": cty.StringVal("eu-west-1")}), nil
+}
+
+func TestExecuteDatasource_stream(t *testing.T) {
+	v, err := ExecuteDatasource(context.Background(), &imagesDatasource{count: 3})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("eu-west-1"),
+		"ids":    cty.ListVal([]cty.Value{cty.StringVal("ami-0"), cty.StringVal("ami-1"), cty.StringVal("ami-2")}),
+	})
+	if !v.RawEquals(expected) {
+		t.Fatalf("bad output: %#v", v)
+	}
+
+	// An empty collection still has the type of the attribute.
+	v, err = ExecuteDatasource(context.Background(), &imagesDatasource{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ids := v.GetAttr("ids"); !ids.RawEquals(cty.ListValEmpty(cty.String)) {
+		t.Fatalf("bad ids: %#v", ids)
+	}
+}
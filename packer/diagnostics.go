@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"errors"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// DiagnosticAttribute is set as the Extra of a diagnostic about a single
+// option of the configuration. Plugins don't know where their configuration
+// was written, so Packer uses it to find the range of the option in the
+// template.
+type DiagnosticAttribute struct {
+	// Name of the attribute, as written in the template; nested attributes
+	// are separated by dots, like `communicator.ssh_port`.
+	Name string
+}
+
+// AttributeDiagnostic returns a diagnostic about the attribute called name.
+func AttributeDiagnostic(severity hcl.DiagnosticSeverity, name, summary, detail string) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: severity,
+		Summary:  summary,
+		Detail:   detail,
+		Extra:    DiagnosticAttribute{Name: name},
+	}
+}
+
+// DiagnosticAttributeName returns the name of the attribute diag is about, if
+// it was created with AttributeDiagnostic.
+func DiagnosticAttributeName(diag *hcl.Diagnostic) (string, bool) {
+	attr, ok := diag.Extra.(DiagnosticAttribute)
+	return attr.Name, ok
+}
+
+// ErrorDiagnostics turns the error returned when decoding or checking a
+// configuration into error diagnostics, one per error of a MultiError, which
+// eases implementing ValidateConfig on top of existing validation code.
+func ErrorDiagnostics(err error) hcl.Diagnostics {
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	if merr, ok := err.(*MultiError); ok {
+		errs = merr.Errors
+	}
+	diags := make(hcl.Diagnostics, 0, len(errs))
+	for _, err := range errs {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  err.Error(),
+		})
+	}
+	return diags
+}
+
+// WarningDiagnostics turns the warnings returned by Prepare into warning
+// diagnostics.
+func WarningDiagnostics(warnings []string) hcl.Diagnostics {
+	diags := make(hcl.Diagnostics, 0, len(warnings))
+	for _, w := range warnings {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  w,
+		})
+	}
+	return diags
+}
+
+// DiagnosticsError can be returned by Prepare and Configure to report
+// errors and warnings with a detail and, when known, the attribute or the
+// range they are about, instead of plain strings.
+//
+// A DiagnosticsError holding warnings only does not fail the preparation:
+// callers must use PrepareDiagnostics to interpret what Prepare or Configure
+// returned.
+type DiagnosticsError struct {
+	Diagnostics hcl.Diagnostics
+}
+
+func (e *DiagnosticsError) Error() string {
+	return e.Diagnostics.Error()
+}
+
+// PrepareDiagnostics turns the warnings and the error returned by Prepare or
+// Configure into diagnostics, the preparation having failed if they contain
+// an error.
+func PrepareDiagnostics(warnings []string, err error) hcl.Diagnostics {
+	var derr *DiagnosticsError
+	if errors.As(err, &derr) {
+		return append(WarningDiagnostics(warnings), derr.Diagnostics...)
+	}
+	return append(WarningDiagnostics(warnings), ErrorDiagnostics(err)...)
+}
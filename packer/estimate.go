@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+// Estimate describes the resources a build is expected to use, and what
+// they are expected to cost.
+type Estimate struct {
+	Resources []ResourceEstimate
+	// Currency is the currency of the costs of the resources, for example
+	// "USD".
+	Currency string
+}
+
+// ResourceEstimate is the expected usage of a single resource, for example
+// "2 hours of a t3.large instance".
+type ResourceEstimate struct {
+	// Kind is the kind of resource, for example "instance" or "volume".
+	Kind string
+	// Name identifies the resource, for example "t3.large in us-east-1".
+	Name string
+	// Quantity is the expected usage of the resource, in Unit.
+	Quantity float64
+	// Unit is the unit of Quantity, for example "hours" or "GiB-month".
+	Unit string
+	// Cost is the expected cost of the resource, 0 when it isn't known.
+	Cost float64
+}
+
+// TotalCost returns the sum of the costs of the resources of e.
+func (e *Estimate) TotalCost() float64 {
+	var total float64
+	for _, r := range e.Resources {
+		total += r.Cost
+	}
+	return total
+}
+
+// BuilderWithEstimate is implemented by builders that can estimate what a
+// build will use from their prepared configuration, so that Packer can show
+// it before running the build.
+type BuilderWithEstimate interface {
+	Builder
+
+	// Estimate is called after Prepare and before Run. Like Prepare, it must
+	// have no side effects: it may query the prices of the cloud, but must
+	// not create anything.
+	Estimate() (*Estimate, error)
+}
+
+// OptionalBuilderWithEstimate is implemented by builders that only know at
+// run time whether they can estimate their builds, like the RPC clients of
+// plugins built with an older SDK.
+type OptionalBuilderWithEstimate interface {
+	BuilderWithEstimate
+
+	SupportsEstimate() bool
+}
+
+// EstimateBuild returns the estimate of the build of b, nil when b is not a
+// BuilderWithEstimate.
+func EstimateBuild(b Builder) (*Estimate, error) {
+	if o, ok := b.(OptionalBuilderWithEstimate); ok && !o.SupportsEstimate() {
+		return nil, nil
+	}
+	e, ok := b.(BuilderWithEstimate)
+	if !ok {
+		return nil, nil
+	}
+	return e.Estimate()
+}
@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// A Function is a pure function that can be called from HCL2 expressions,
+// like the built-in `cidrsubnet` or `base64encode` functions. It is the
+// lightweight alternative to a Datasource for plugins that only need to
+// compute a value from their arguments.
+//
+// Functions must not have side effects: Packer may call them any number of
+// times, including while validating a template.
+type Function interface {
+	// Spec describes the parameters and the return type of the function.
+	Spec() FunctionSpec
+
+	// Call runs the function. args have the types declared in Spec, and
+	// if VarParam is set, the extra arguments follow the declared ones.
+	Call(args []cty.Value) (cty.Value, error)
+}
+
+// FunctionParam describes a parameter of a Function.
+type FunctionParam struct {
+	Name        string
+	Description string
+	Type        cty.Type
+	// AllowNull lets callers pass null for this parameter.
+	AllowNull bool
+}
+
+// FunctionSpec is the signature of a Function.
+type FunctionSpec struct {
+	Description string
+	Params      []FunctionParam
+	// VarParam, if set, is the type of the trailing variadic arguments.
+	VarParam *FunctionParam
+	// Return is the type of the value returned by Call.
+	Return cty.Type
+}
+
+// HCL2Function wraps f into a cty function that can be put in an HCL2
+// evaluation context.
+func HCL2Function(f Function) function.Function {
+	spec := f.Spec()
+
+	fs := &function.Spec{
+		Description: spec.Description,
+		Type:        function.StaticReturnType(spec.Return),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return f.Call(args)
+		},
+	}
+	for _, p := range spec.Params {
+		fs.Params = append(fs.Params, functionParameter(p))
+	}
+	if spec.VarParam != nil {
+		p := functionParameter(*spec.VarParam)
+		fs.VarParam = &p
+	}
+	return function.New(fs)
+}
+
+func functionParameter(p FunctionParam) function.Parameter {
+	return function.Parameter{
+		Name:        p.Name,
+		Description: p.Description,
+		Type:        p.Type,
+		AllowNull:   p.AllowNull,
+	}
+}
+
+// NewFunction returns a Function of signature spec, running call, for
+// plugins to register functions without declaring a type for each.
+func NewFunction(spec FunctionSpec, call func(args []cty.Value) (cty.Value, error)) Function {
+	return &funcFunction{spec: spec, call: call}
+}
+
+type funcFunction struct {
+	spec FunctionSpec
+	call func(args []cty.Value) (cty.Value, error)
+}
+
+func (f *funcFunction) Spec() FunctionSpec { return f.spec }
+
+func (f *funcFunction) Call(args []cty.Value) (cty.Value, error) { return f.call(args) }
+
+// InterpolateFunc adapts f to the interpolations of JSON templates, where
+// arguments and results are strings: the arguments are converted to the
+// types of the parameters of f, and its result to a string.
+func InterpolateFunc(f Function) interpolate.Func {
+	spec := f.Spec()
+	arity := len(spec.Params)
+	if spec.VarParam != nil {
+		arity = -1
+	}
+	return interpolate.Func{
+		Arity: arity,
+		Impl: func(_ *interpolate.Context, args []string) (string, error) {
+			if len(args) < len(spec.Params) {
+				return "", fmt.Errorf("expected at least %d arguments, got %d", len(spec.Params), len(args))
+			}
+			values := make([]cty.Value, len(args))
+			for i, arg := range args {
+				param := spec.VarParam
+				if i < len(spec.Params) {
+					param = &spec.Params[i]
+				}
+				v, err := convert.Convert(cty.StringVal(arg), param.Type)
+				if err != nil {
+					return "", fmt.Errorf("invalid %s argument: %s", param.Name, err)
+				}
+				values[i] = v
+			}
+
+			result, err := f.Call(values)
+			if err != nil {
+				return "", err
+			}
+			s, err := convert.Convert(result, cty.String)
+			if err != nil {
+				return "", fmt.Errorf("the result can't be used in an interpolation: %s", err)
+			}
+			if s.IsNull() || !s.IsKnown() {
+				return "", nil
+			}
+			return s.AsString(), nil
+		},
+	}
+}
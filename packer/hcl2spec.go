@@ -13,3 +13,11 @@ type HCL2Speccer interface {
 	// validate/configure a configuration.
 	ConfigSpec() hcldec.ObjectSpec
 }
+
+// ConfigDocumenter can be implemented by components to return the struct
+// their configuration is decoded to, for its field tags to document the
+// schema of their configuration, see hcl2helper.JSONSchema.
+type ConfigDocumenter interface {
+	// ConfigStruct returns the configuration struct, or a pointer to it.
+	ConfigStruct() interface{}
+}
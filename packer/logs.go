@@ -8,50 +8,78 @@ import (
 	"io"
 	"strings"
 	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
 )
 
-type secretFilter struct {
+// SecretFilter is a registry of secrets, like passwords and tokens, replaced
+// with `<sensitive>` in what is written through it. Plugins register their
+// secrets with LogSecretFilter, which scrubs the logs, the Ui messages, and
+// the messages the rpc package forwards to Packer. The configuration fields
+// tagged `sensitive:"true"` are registered when decoded by config.Decode.
+type SecretFilter struct {
 	s map[string]struct{}
-	m sync.Mutex
+	m sync.RWMutex
 	w io.Writer
 }
 
-func (l *secretFilter) Set(secrets ...string) {
+// Set registers secrets; empty strings are ignored.
+func (l *SecretFilter) Set(secrets ...string) {
 	l.m.Lock()
 	defer l.m.Unlock()
+	if l.s == nil {
+		l.s = make(map[string]struct{})
+	}
 	for _, s := range secrets {
-		l.s[s] = struct{}{}
+		if s != "" {
+			l.s[s] = struct{}{}
+		}
+	}
+}
+
+// SetKey registers a PEM encoded private key: the whole key, and each of its
+// lines but the armor, since log lines are filtered one write at a time and a
+// key spread over several lines of output is only caught line by line.
+func (l *SecretFilter) SetKey(key []byte) {
+	secrets := []string{string(bytes.TrimSpace(key))}
+	for _, line := range bytes.Split(key, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && !bytes.HasPrefix(line, []byte("-----")) {
+			secrets = append(secrets, string(line))
+		}
 	}
+	l.Set(secrets...)
 }
 
-func (l *secretFilter) SetOutput(output io.Writer) {
+func (l *SecretFilter) SetOutput(output io.Writer) {
 	l.m.Lock()
 	defer l.m.Unlock()
 	l.w = output
 }
 
-func (l *secretFilter) Write(p []byte) (n int, err error) {
+func (l *SecretFilter) Write(p []byte) (n int, err error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
 	for s := range l.s {
-		if s != "" {
-			p = bytes.Replace(p, []byte(s), []byte("<sensitive>"), -1)
-		}
+		p = bytes.Replace(p, []byte(s), []byte("<sensitive>"), -1)
 	}
 	return l.w.Write(p)
 }
 
 // FilterString will overwrite any senstitive variables in a string, returning
 // the filtered string.
-func (l *secretFilter) FilterString(message string) string {
+func (l *SecretFilter) FilterString(message string) string {
+	l.m.RLock()
+	defer l.m.RUnlock()
 	for s := range l.s {
-		if s != "" {
-			message = strings.Replace(message, s, "<sensitive>", -1)
-		}
+		message = strings.Replace(message, s, "<sensitive>", -1)
 	}
 	return message
 }
 
-var LogSecretFilter secretFilter
+var LogSecretFilter SecretFilter
 
 func init() {
 	LogSecretFilter.s = make(map[string]struct{})
+	config.RegisterSecretFilter(LogSecretFilter.Set)
 }
@@ -17,7 +17,8 @@ type PostProcessor interface {
 
 	// Configure is responsible for setting up configuration, storing
 	// the state for later, and returning and errors, such as validation
-	// errors.
+	// errors. A *DiagnosticsError can be returned to report detailed errors
+	// and warnings.
 	Configure(...interface{}) error
 
 	// PostProcess takes a previously created Artifact and produces another
@@ -26,6 +27,7 @@ type PostProcessor interface {
 	// given a value to keep_input_artifact. If forceOverride is true, then any
 	// user input for keep_input_artifact is ignored and the artifact is either
 	// kept or discarded according to the value set in `keep`.
-	// PostProcess is cancellable using context
+	// PostProcess is cancellable using context. Post-processors producing
+	// several artifacts implement MultiArtifactPostProcessor.
 	PostProcess(context.Context, Ui, Artifact) (a Artifact, keep bool, forceOverride bool, err error)
 }
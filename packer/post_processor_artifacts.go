@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// PostProcessedArtifact is an artifact produced by a post-processor, with
+// whether the artifact it was produced from should be kept, with the meaning
+// keep and forceOverride have for PostProcessor.PostProcess.
+type PostProcessedArtifact struct {
+	Artifact      Artifact
+	Keep          bool
+	ForceOverride bool
+}
+
+// MultiArtifactPostProcessor is implemented by post-processors producing
+// several artifacts from one, like copies of an image in several regions.
+//
+// They still implement PostProcess, for the versions of Packer only calling
+// it, usually with CombinedPostProcess.
+type MultiArtifactPostProcessor interface {
+	PostProcessor
+
+	// PostProcessArtifacts is like PostProcess, but returns any number of
+	// artifacts, each deciding whether the input artifact is kept.
+	PostProcessArtifacts(context.Context, Ui, Artifact) ([]PostProcessedArtifact, error)
+}
+
+// PostProcessArtifacts runs p on a, and returns the artifacts it produced,
+// calling PostProcessArtifacts when p implements it and PostProcess
+// otherwise.
+func PostProcessArtifacts(ctx context.Context, p PostProcessor, ui Ui, a Artifact) ([]PostProcessedArtifact, error) {
+	if mp, ok := p.(MultiArtifactPostProcessor); ok {
+		return mp.PostProcessArtifacts(ctx, ui, a)
+	}
+	artifact, keep, forceOverride, err := p.PostProcess(ctx, ui, a)
+	if err != nil {
+		return nil, err
+	}
+	if artifact == nil {
+		return nil, nil
+	}
+	return []PostProcessedArtifact{{Artifact: artifact, Keep: keep, ForceOverride: forceOverride}}, nil
+}
+
+// KeepInputArtifact combines the keep and forceOverride flags of artifacts
+// produced from the same input artifact. The input artifact is kept when any
+// of them keeps it, and the choice is forced when any of them forces it, in
+// which case only the artifacts forcing it decide.
+func KeepInputArtifact(artifacts []PostProcessedArtifact) (keep bool, forceOverride bool) {
+	for _, a := range artifacts {
+		if a.ForceOverride && !forceOverride {
+			forceOverride, keep = true, false
+		}
+		if a.ForceOverride == forceOverride {
+			keep = keep || a.Keep
+		}
+	}
+	return keep, forceOverride
+}
+
+// CombinedPostProcess implements PostProcess for a
+// MultiArtifactPostProcessor: a single artifact is returned as is, and
+// several artifacts as a CombinedArtifact.
+func CombinedPostProcess(ctx context.Context, p MultiArtifactPostProcessor, ui Ui, a Artifact) (Artifact, bool, bool, error) {
+	artifacts, err := p.PostProcessArtifacts(ctx, ui, a)
+	if err != nil {
+		return nil, false, false, err
+	}
+	keep, forceOverride := KeepInputArtifact(artifacts)
+	switch len(artifacts) {
+	case 0:
+		return nil, keep, forceOverride, nil
+	case 1:
+		return artifacts[0].Artifact, keep, forceOverride, nil
+	}
+	combined := make(CombinedArtifact, len(artifacts))
+	for i, a := range artifacts {
+		combined[i] = a.Artifact
+	}
+	return combined, keep, forceOverride, nil
+}
+
+// CombinedArtifact is several artifacts seen as one, for the versions of
+// Packer that only support one artifact per post-processor.
+type CombinedArtifact []Artifact
+
+var _ Artifact = CombinedArtifact(nil)
+
+// BuilderId returns the builder ID of the first artifact.
+func (c CombinedArtifact) BuilderId() string {
+	if len(c) == 0 {
+		return ""
+	}
+	return c[0].BuilderId()
+}
+
+func (c CombinedArtifact) Files() []string {
+	var files []string
+	for _, a := range c {
+		files = append(files, a.Files()...)
+	}
+	return files
+}
+
+// Id returns the IDs of the artifacts, separated by commas.
+func (c CombinedArtifact) Id() string {
+	ids := make([]string, len(c))
+	for i, a := range c {
+		ids[i] = a.Id()
+	}
+	return strings.Join(ids, ",")
+}
+
+func (c CombinedArtifact) String() string {
+	descriptions := make([]string, len(c))
+	for i, a := range c {
+		descriptions[i] = a.String()
+	}
+	return strings.Join(descriptions, "\n")
+}
+
+// State returns the state of the first artifact having a value for name.
+func (c CombinedArtifact) State(name string) interface{} {
+	for _, a := range c {
+		if v := a.State(name); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// Destroy destroys all the artifacts, returning their errors joined.
+func (c CombinedArtifact) Destroy() error {
+	var errs []error
+	for _, a := range c {
+		if err := a.Destroy(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
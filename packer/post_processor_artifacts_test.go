@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+)
+
+type regionCopiesPostProcessor struct {
+	regions []string
+}
+
+func (*regionCopiesPostProcessor) ConfigSpec() hcldec.ObjectSpec { return nil }
+
+func (*regionCopiesPostProcessor) Configure(...interface{}) error { return nil }
+
+func (p *regionCopiesPostProcessor) PostProcess(ctx context.Context, ui Ui, a Artifact) (Artifact, bool, bool, error) {
+	return CombinedPostProcess(ctx, p, ui, a)
+}
+
+func (p *regionCopiesPostProcessor) PostProcessArtifacts(_ context.Context, _ Ui, a Artifact) ([]PostProcessedArtifact, error) {
+	var artifacts []PostProcessedArtifact
+	for _, region := range p.regions {
+		artifacts = append(artifacts, PostProcessedArtifact{
+			Artifact: &MockArtifact{IdValue: region + ":" + a.Id(), FilesValue: []string{region}},
+			Keep:     true,
+		})
+	}
+	return artifacts, nil
+}
+
+func TestPostProcessArtifacts(t *testing.T) {
+	p := &regionCopiesPostProcessor{regions: []string{"us-east-1", "eu-west-1"}}
+	input := &MockArtifact{IdValue: "ami-1"}
+
+	artifacts, err := PostProcessArtifacts(context.Background(), p, TestUi(t), input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(artifacts) != 2 || artifacts[1].Artifact.Id() != "eu-west-1:ami-1" {
+		t.Fatalf("bad artifacts: %#v", artifacts)
+	}
+
+	// Older Packer versions get the artifacts combined.
+	a, keep, forceOverride, err := p.PostProcess(context.Background(), TestUi(t), input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !keep || forceOverride {
+		t.Fatalf("bad keep: %t, forceOverride: %t", keep, forceOverride)
+	}
+	if a.Id() != "us-east-1:ami-1,eu-west-1:ami-1" || len(a.Files()) != 2 {
+		t.Fatalf("bad combined artifact: %s %v", a.Id(), a.Files())
+	}
+	if err := a.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, a := range a.(CombinedArtifact) {
+		if !a.(*MockArtifact).DestroyCalled {
+			t.Fatalf("%s not destroyed", a.Id())
+		}
+	}
+
+	// A single artifact is returned as is.
+	p.regions = p.regions[:1]
+	if a, _, _, _ := p.PostProcess(context.Background(), TestUi(t), input); a.Id() != "us-east-1:ami-1" {
+		t.Fatalf("bad artifact: %s", a.Id())
+	}
+}
+
+func TestKeepInputArtifact(t *testing.T) {
+	cases := []struct {
+		name                string
+		artifacts           []PostProcessedArtifact
+		keep, forceOverride bool
+	}{
+		{"none", nil, false, false},
+		{"any keeps", []PostProcessedArtifact{{}, {Keep: true}}, true, false},
+		{"forced discard", []PostProcessedArtifact{{Keep: true}, {ForceOverride: true}}, false, true},
+		{"forced keep", []PostProcessedArtifact{{ForceOverride: true}, {Keep: true, ForceOverride: true}, {}}, true, true},
+	}
+	for _, tc := range cases {
+		keep, forceOverride := KeepInputArtifact(tc.artifacts)
+		if keep != tc.keep || forceOverride != tc.forceOverride {
+			t.Errorf("%s: expected %t, %t, got %t, %t", tc.name, tc.keep, tc.forceOverride, keep, forceOverride)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"io"
+
+	getter "github.com/hashicorp/go-getter/v2"
+)
+
+// ProgressTracker reports the progress of a long running operation, like the
+// upload of an ISO, so that Packer can display it or expose it to machine
+// readable output.
+//
+// A ProgressTracker tracks a single operation: Start is called once, then
+// Add as the operation goes, and Finish when it's done, whether it succeeded
+// or not.
+type ProgressTracker interface {
+	// Start starts tracking an operation described by label, for example
+	// "Uploading ubuntu.iso". total is the number of bytes the operation
+	// will process, 0 when it isn't known.
+	Start(label string, total int64)
+	// Add reports that n more bytes were processed.
+	Add(n int64)
+	// Finish reports the end of the operation.
+	Finish()
+}
+
+// ProgressUi is implemented by the Uis that create their own progress
+// trackers, like the Ui passed to plugins.
+type ProgressUi interface {
+	NewProgressTracker() ProgressTracker
+}
+
+// NewProgressTracker returns a tracker reporting progress to ui. When ui is
+// not a ProgressUi, the tracker reports it with ui.TrackProgress.
+func NewProgressTracker(ui Ui) ProgressTracker {
+	if pui, ok := ui.(ProgressUi); ok {
+		return pui.NewProgressTracker()
+	}
+	return NewUiProgressTracker(ui)
+}
+
+// NewUiProgressTracker returns a tracker reporting progress with
+// ui.TrackProgress, which every Ui implements.
+func NewUiProgressTracker(ui Ui) ProgressTracker {
+	return &uiProgressTracker{ui: ui}
+}
+
+type uiProgressTracker struct {
+	ui     Ui
+	stream io.ReadCloser
+}
+
+func (t *uiProgressTracker) Start(label string, total int64) {
+	t.stream = t.ui.TrackProgress(label, 0, total, nopReadCloser{})
+}
+
+func (t *uiProgressTracker) Add(n int64) {
+	if t.stream == nil {
+		return
+	}
+	// The stream counts the bytes read from nopReadCloser.
+	buf := make([]byte, 32*1024)
+	for n > 0 {
+		chunk := buf
+		if n < int64(len(chunk)) {
+			chunk = chunk[:n]
+		}
+		read, _ := t.stream.Read(chunk)
+		if read <= 0 {
+			return
+		}
+		n -= int64(read)
+	}
+}
+
+func (t *uiProgressTracker) Finish() {
+	if t.stream != nil {
+		t.stream.Close()
+		t.stream = nil
+	}
+}
+
+type nopReadCloser struct{}
+
+func (nopReadCloser) Close() error               { return nil }
+func (nopReadCloser) Read(b []byte) (int, error) { return len(b), nil }
+
+// ProgressReader returns a reader reading from r and reporting the bytes
+// read to t.
+func ProgressReader(t ProgressTracker, r io.Reader) io.Reader {
+	return &progressReader{t: t, r: r}
+}
+
+type progressReader struct {
+	t ProgressTracker
+	r io.Reader
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.t.Add(int64(n))
+	}
+	return n, err
+}
+
+// ProgressWriter returns a writer writing to w and reporting the bytes
+// written to t.
+func ProgressWriter(t ProgressTracker, w io.Writer) io.Writer {
+	return &progressWriter{t: t, w: w}
+}
+
+type progressWriter struct {
+	t ProgressTracker
+	w io.Writer
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.t.Add(int64(n))
+	}
+	return n, err
+}
+
+// ProgressListener returns a go-getter progress listener reporting the
+// progress of downloads with trackers created by NewProgressTracker.
+func ProgressListener(ui Ui) getter.ProgressTracker {
+	return &progressListener{ui: ui}
+}
+
+type progressListener struct {
+	ui Ui
+}
+
+func (l *progressListener) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	t := NewProgressTracker(l.ui)
+	t.Start(src, totalSize)
+	if currentSize > 0 {
+		t.Add(currentSize)
+	}
+	return &progressReadCloser{Reader: ProgressReader(t, stream), t: t, c: stream}
+}
+
+type progressReadCloser struct {
+	io.Reader
+	t ProgressTracker
+	c io.Closer
+}
+
+func (r *progressReadCloser) Close() error {
+	r.t.Finish()
+	return r.c.Close()
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// countingUi counts the bytes read from the streams passed to TrackProgress.
+type countingUi struct {
+	MockUi
+
+	src    string
+	total  int64
+	read   int64
+	closed bool
+}
+
+func (u *countingUi) TrackProgress(src string, _, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	u.src = src
+	u.total = totalSize
+	return &readCloser{
+		read: func(p []byte) (int, error) {
+			n, err := stream.Read(p)
+			u.read += int64(n)
+			return n, err
+		},
+		close: func() error {
+			u.closed = true
+			return stream.Close()
+		},
+	}
+}
+
+func TestNewProgressTracker_TrackProgress(t *testing.T) {
+	ui := new(countingUi)
+	tracker := NewProgressTracker(ui)
+
+	tracker.Start("Uploading iso", 100*1024)
+	tracker.Add(10)
+	tracker.Add(70 * 1024)
+	tracker.Finish()
+
+	if ui.src != "Uploading iso" || ui.total != 100*1024 {
+		t.Fatalf("bad start: %q %d", ui.src, ui.total)
+	}
+	if ui.read != 70*1024+10 {
+		t.Fatalf("bad progress: %d", ui.read)
+	}
+	if !ui.closed {
+		t.Fatal("tracking should be finished")
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	ui := new(countingUi)
+	tracker := NewProgressTracker(ui)
+	tracker.Start("Uploading file", 5)
+
+	b, err := io.ReadAll(ProgressReader(tracker, strings.NewReader("hello")))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("bad content: %q", b)
+	}
+	if ui.read != 5 {
+		t.Fatalf("bad progress: %d", ui.read)
+	}
+}
+
+func TestProgressListener(t *testing.T) {
+	ui := new(countingUi)
+	stream := ProgressListener(ui).TrackProgress("http://example.com/iso", 2, 7, io.NopCloser(strings.NewReader("hello")))
+	if _, err := io.ReadAll(stream); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	stream.Close()
+
+	if ui.src != "http://example.com/iso" || ui.read != 7 || !ui.closed {
+		t.Fatalf("bad progress: %#v", ui)
+	}
+}
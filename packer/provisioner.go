@@ -12,7 +12,8 @@ type Provisioner interface {
 
 	// Prepare is called with a set of configurations to setup the
 	// internal state of the provisioner. The multiple configurations
-	// should be merged in some sane way.
+	// should be merged in some sane way. A *DiagnosticsError can be
+	// returned to report detailed errors and warnings.
 	Prepare(...interface{}) error
 
 	// Provision is called to actually provision the machine. A context is
@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+)
+
+// Pause waits for d, for the guest to settle, telling ui how long is left
+// every minute, then every ten seconds during the last minute. It returns
+// ctx.Err() when ctx is done first. what describes the pause, like "after
+// the shell provisioner".
+func Pause(ctx context.Context, ui Ui, d time.Duration, what string) error {
+	if d <= 0 {
+		return nil
+	}
+	ui.Say(i18n.Sprintf("Pausing %s %s...", d, what))
+	end := time.Now().Add(d)
+	for {
+		left := time.Until(end)
+		if left <= 0 {
+			return nil
+		}
+		step := 10 * time.Second
+		if left > time.Minute {
+			step = time.Minute
+		}
+		// Wake up on round values of the time left, so that the countdown
+		// reads 2m0s, 1m0s, 50s...
+		wait := left % step
+		if wait == 0 {
+			wait = step
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			ui.Say(i18n.Sprintf("Pause %s cancelled", what))
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if left := time.Until(end).Round(time.Second); left > 0 {
+			ui.Message(i18n.Sprintf("%s left", left))
+		}
+	}
+}
+
+// PausedProvisioner is a Provisioner pausing before and after it provisions,
+// as set with the `pause_before` and `pause_after` options of provisioners,
+// for example to let services restart, instead of running sleep scripts.
+// There is no pause after a failed provisioning.
+type PausedProvisioner struct {
+	PauseBefore time.Duration
+	PauseAfter  time.Duration
+	Provisioner
+}
+
+func (p *PausedProvisioner) Provision(ctx context.Context, ui Ui, comm Communicator, generatedData map[string]interface{}) error {
+	if err := Pause(ctx, ui, p.PauseBefore, "before the next provisioner"); err != nil {
+		return err
+	}
+	if err := p.Provisioner.Provision(ctx, ui, comm, generatedData); err != nil {
+		return err
+	}
+	return Pause(ctx, ui, p.PauseAfter, "after the provisioner")
+}
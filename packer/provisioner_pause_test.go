@@ -0,0 +1,57 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	start := time.Now()
+	if err := Pause(context.Background(), TestUi(t), 50*time.Millisecond, "after the test"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("the pause was too short: %s", elapsed)
+	}
+}
+
+func TestPause_cancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := Pause(ctx, TestUi(t), time.Hour, "after the test")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestPausedProvisioner(t *testing.T) {
+	mock := new(MockProvisioner)
+	p := &PausedProvisioner{
+		PauseBefore: 10 * time.Millisecond,
+		PauseAfter:  10 * time.Millisecond,
+		Provisioner: mock,
+	}
+	start := time.Now()
+	if err := p.Provision(context.Background(), TestUi(t), new(MockCommunicator), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !mock.ProvCalled {
+		t.Fatal("the provisioner should run")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("the pauses were too short: %s", elapsed)
+	}
+}
+
+func TestPausedProvisioner_noPauseAfterFailure(t *testing.T) {
+	mock := &MockProvisioner{ProvFunc: func(context.Context) error { return errors.New("failed") }}
+	p := &PausedProvisioner{PauseAfter: time.Hour, Provisioner: mock}
+	if err := p.Provision(context.Background(), TestUi(t), new(MockCommunicator), nil); err == nil {
+		t.Fatal("should fail")
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProvisionerRequirements are the constraints a provisioner puts on where it
+// runs in a build. Phases are free-form names, like "package-install", that
+// provisioners provide and others order themselves against.
+type ProvisionerRequirements struct {
+	// Provides are the phases the provisioner completes.
+	Provides []string
+	// Requires are the phases that must be provided by a provisioner
+	// running before this one.
+	Requires []string
+	// After are the phases that, when provided, must be provided by a
+	// provisioner running before this one.
+	After []string
+	// Before are the phases that, when provided, must be provided by a
+	// provisioner running after this one.
+	Before []string
+	// Communicator are the capabilities the communicator must have, like
+	// Reboot for provisioners restarting the machine.
+	Communicator CommunicatorCapabilities
+}
+
+// RequirementsProvisioner is implemented by provisioners declaring
+// requirements, so that Packer can report a template running them in the
+// wrong order, or with the wrong communicator, when it is validated rather
+// than halfway through a build.
+type RequirementsProvisioner interface {
+	Provisioner
+
+	// Requirements returns the requirements of the provisioner. It is
+	// called after Prepare.
+	Requirements() ProvisionerRequirements
+}
+
+// ProvisionerRequirementsOf returns the requirements of p, which are empty
+// when it doesn't declare any.
+func ProvisionerRequirementsOf(p Provisioner) ProvisionerRequirements {
+	if rp, ok := p.(RequirementsProvisioner); ok {
+		return rp.Requirements()
+	}
+	return ProvisionerRequirements{}
+}
+
+// NamedProvisionerRequirements are the requirements of a provisioner of a
+// template, with the name used for it in error messages, like
+// `provisioner "shell" #2`.
+type NamedProvisionerRequirements struct {
+	Name string
+	ProvisionerRequirements
+}
+
+// ValidateProvisionerRequirements checks the requirements of the provisioners
+// of a build, given in the order they run, against each other and against the
+// capabilities of the communicator of the build. caps are only checked when
+// capsKnown is true, see CapabilitiesOf. All the unmet requirements are
+// returned in a *MultiError.
+func ValidateProvisionerRequirements(provisioners []NamedProvisionerRequirements, caps CommunicatorCapabilities, capsKnown bool) error {
+	// providers maps each phase to the indices of its providers.
+	providers := map[string][]int{}
+	for i, p := range provisioners {
+		for _, phase := range p.Provides {
+			providers[phase] = append(providers[phase], i)
+		}
+	}
+	names := func(indices []int) string {
+		var l []string
+		for _, i := range indices {
+			l = append(l, provisioners[i].Name)
+		}
+		return strings.Join(l, ", ")
+	}
+	// split returns the providers of phase running before and after i.
+	split := func(phase string, i int) (before, after []int) {
+		for _, j := range providers[phase] {
+			if j < i {
+				before = append(before, j)
+			} else if j > i {
+				after = append(after, j)
+			}
+		}
+		return before, after
+	}
+
+	var errs []error
+	for i, p := range provisioners {
+		for _, phase := range p.Requires {
+			before, after := split(phase, i)
+			switch {
+			case len(before) > 0:
+			case len(after) > 0:
+				errs = append(errs, fmt.Errorf("%s must run after %s, which provides the %q phase", p.Name, names(after), phase))
+			default:
+				errs = append(errs, fmt.Errorf("%s requires the %q phase, which no provisioner provides", p.Name, phase))
+			}
+		}
+		for _, phase := range p.After {
+			if _, after := split(phase, i); len(after) > 0 {
+				errs = append(errs, fmt.Errorf("%s must run after %s, which provides the %q phase", p.Name, names(after), phase))
+			}
+		}
+		for _, phase := range p.Before {
+			if before, _ := split(phase, i); len(before) > 0 {
+				errs = append(errs, fmt.Errorf("%s must run before %s, which provides the %q phase", p.Name, names(before), phase))
+			}
+		}
+		if capsKnown {
+			if missing := caps.Missing(p.Communicator); len(missing) > 0 {
+				errs = append(errs, fmt.Errorf("%s requires a communicator supporting %s", p.Name, strings.Join(missing, ", ")))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
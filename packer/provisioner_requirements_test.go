@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateProvisionerRequirements(t *testing.T) {
+	packages := NamedProvisionerRequirements{Name: "packages", ProvisionerRequirements: ProvisionerRequirements{
+		Provides: []string{"package-install"},
+	}}
+	configure := NamedProvisionerRequirements{Name: "configure", ProvisionerRequirements: ProvisionerRequirements{
+		Requires:     []string{"package-install"},
+		Before:       []string{"cleanup"},
+		Communicator: CommunicatorCapabilities{Reboot: true},
+	}}
+	cleanup := NamedProvisionerRequirements{Name: "cleanup", ProvisionerRequirements: ProvisionerRequirements{
+		Provides: []string{"cleanup"},
+		After:    []string{"package-install"},
+	}}
+	rebooting := CommunicatorCapabilities{Reboot: true}
+
+	ok := []NamedProvisionerRequirements{packages, configure, cleanup}
+	if err := ValidateProvisionerRequirements(ok, rebooting, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := ValidateProvisionerRequirements([]NamedProvisionerRequirements{cleanup, configure, packages}, CommunicatorCapabilities{}, true)
+	var got []string
+	for _, err := range err.(*MultiError).Errors {
+		got = append(got, err.Error())
+	}
+	expected := []string{
+		`cleanup must run after packages, which provides the "package-install" phase`,
+		`configure must run after packages, which provides the "package-install" phase`,
+		`configure must run before cleanup, which provides the "cleanup" phase`,
+		`configure requires a communicator supporting reconnecting after reboots`,
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected errors: %s", diff)
+	}
+
+	// Unknown capabilities are not checked, missing phases are.
+	err = ValidateProvisionerRequirements([]NamedProvisionerRequirements{configure}, CommunicatorCapabilities{}, false)
+	if err == nil || len(err.(*MultiError).Errors) != 1 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// RemoteTail streams the lines appended to a file on the remote machine to a
+// Ui, for example /var/log/cloud-init-output.log while a provisioner waits
+// for cloud-init to finish.
+//
+// Instead of leaving a `tail -f` running in the background, RemoteTail
+// periodically runs a short lived command that prints the file from the last
+// offset it read, so nothing is left running on the remote machine when it
+// stops.
+type RemoteTail struct {
+	// Path of the remote file.
+	Path string
+	// Interval between two reads of the file. Defaults to 2 seconds.
+	Interval time.Duration
+	// Prefix is prepended to every line written to the Ui.
+	Prefix string
+	// Command returns the command that prints the content of path starting
+	// at byte offset. Defaults to PosixTailCommand.
+	Command func(path string, offset int64) string
+
+	offset  int64
+	pending []byte
+}
+
+// PosixTailCommand prints a file from a byte offset with tail(1).
+func PosixTailCommand(path string, offset int64) string {
+	return fmt.Sprintf("tail -c +%d '%s'", offset+1, strings.ReplaceAll(path, "'", `'\''`))
+}
+
+// Run streams the file to ui until ctx is done, and then reads the file one
+// last time so that the lines written right before are not lost. A file that
+// doesn't exist yet is waited for. Run returns nil when ctx is done, or the
+// error of the communicator if it fails to start a command.
+func (t *RemoteTail) Run(ctx context.Context, comm Communicator, ui Ui) error {
+	interval := t.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	for {
+		// The last read uses a fresh context since ctx is already done.
+		readCtx := ctx
+		if ctx.Err() != nil {
+			readCtx = context.Background()
+		}
+		if err := t.read(readCtx, comm, ui); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			t.flush(ui)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (t *RemoteTail) read(ctx context.Context, comm Communicator, ui Ui) error {
+	command := t.Command
+	if command == nil {
+		command = PosixTailCommand
+	}
+
+	var stdout bytes.Buffer
+	cmd := &RemoteCmd{
+		Command: command(t.Path, t.offset),
+		Stdout:  &stdout,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return err
+	}
+	if status := cmd.Wait(); status != 0 {
+		log.Printf("[DEBUG] Could not read remote file %q yet, exit status %d", t.Path, status)
+		return nil
+	}
+
+	t.offset += int64(stdout.Len())
+	t.pending = append(t.pending, stdout.Bytes()...)
+	for {
+		i := bytes.IndexByte(t.pending, '\n')
+		if i < 0 {
+			break
+		}
+		ui.Message(t.Prefix + strings.TrimRight(string(t.pending[:i]), "\r"))
+		t.pending = t.pending[i+1:]
+	}
+	return nil
+}
+
+func (t *RemoteTail) flush(ui Ui) {
+	if len(t.pending) > 0 {
+		ui.Message(t.Prefix + strings.TrimRight(string(t.pending), "\r"))
+		t.pending = nil
+	}
+}
+
+// TailWhile streams the remote file of tail to ui while fn runs, and returns
+// the error of fn.
+func TailWhile(ctx context.Context, comm Communicator, ui Ui, tail *RemoteTail, fn func() error) error {
+	tailCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := tail.Run(tailCtx, comm, ui); err != nil {
+			log.Printf("[WARN] Stopped tailing %q: %s", tail.Path, err)
+		}
+	}()
+
+	err := fn()
+	cancel()
+	<-done
+	return err
+}
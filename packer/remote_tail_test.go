@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tailCommunicator serves a file that grows over time; the command of the
+// RemoteCmd is the offset to read from.
+type tailCommunicator struct {
+	MockCommunicator
+
+	mu      sync.Mutex
+	content string
+	exists  bool
+}
+
+func (c *tailCommunicator) append(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exists = true
+	c.content += s
+}
+
+func (c *tailCommunicator) Start(_ context.Context, rc *RemoteCmd) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.exists {
+		rc.SetExited(1)
+		return nil
+	}
+	offset, _ := strconv.Atoi(rc.Command)
+	io.WriteString(rc.Stdout, c.content[offset:])
+	rc.SetExited(0)
+	return nil
+}
+
+func (c *tailCommunicator) Upload(string, io.Reader, *os.FileInfo) error { return nil }
+
+func TestPosixTailCommand(t *testing.T) {
+	if cmd := PosixTailCommand("/var/log/it's.log", 10); cmd != `tail -c +11 '/var/log/it'\''s.log'` {
+		t.Fatalf("unexpected command: %s", cmd)
+	}
+}
+
+func TestTailWhile(t *testing.T) {
+	comm := new(tailCommunicator)
+	var out bytes.Buffer
+	ui := &BasicUi{Reader: new(bytes.Buffer), Writer: &out, ErrorWriter: io.Discard}
+
+	tail := &RemoteTail{
+		Path:     "/var/log/cloud-init-output.log",
+		Interval: time.Millisecond,
+		Prefix:   "cloud-init: ",
+		Command:  func(_ string, offset int64) string { return strconv.FormatInt(offset, 10) },
+	}
+
+	err := TailWhile(context.Background(), comm, ui, tail, func() error {
+		time.Sleep(5 * time.Millisecond)
+		comm.append("first line\nsecond ")
+		time.Sleep(5 * time.Millisecond)
+		comm.append("line\r\nno newline")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "cloud-init: first line\ncloud-init: second line\ncloud-init: no newline\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
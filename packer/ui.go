@@ -13,8 +13,10 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	getter "github.com/hashicorp/go-getter/v2"
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 )
 
 type TTY interface {
@@ -51,15 +53,33 @@ type BasicUi struct {
 	interrupted bool
 	TTY         TTY
 	PB          getter.ProgressTracker
+
+	// lines are the lines read from linesTTY by a single reader, running
+	// as long as the TTY, so that a line typed after a question timed out
+	// answers the next one rather than being lost.
+	lines    chan ttyLine
+	linesTTY TTY
+}
+
+type ttyLine struct {
+	line string
+	err  error
 }
 
 var _ Ui = new(BasicUi)
+var _ AskOptionsUi = new(BasicUi)
 
 func (rw *BasicUi) Askf(query string, args ...any) (string, error) {
-	return rw.Ask(fmt.Sprintf(query, args...))
+	return rw.Ask(i18n.Sprintf(query, args...))
 }
 
 func (rw *BasicUi) Ask(query string) (string, error) {
+	return rw.AskWithOptions(query, AskOptions{})
+}
+
+// AskWithOptions asks query on the TTY, and returns the default answer of
+// opts when there is no TTY or the timeout expires.
+func (rw *BasicUi) AskWithOptions(query string, opts AskOptions) (string, error) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
 
@@ -68,7 +88,7 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 	}
 
 	if rw.TTY == nil {
-		return "", errors.New("no available tty")
+		return opts.fallback(query, errors.New("no available tty"))
 	}
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
@@ -81,19 +101,27 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 		}
 	}
 
-	result := make(chan string, 1)
-	go func() {
-		line, err := rw.TTY.ReadString()
-		if err != nil {
-			log.Printf("ui: scan err: %s", err)
-			return
-		}
-		result <- strings.TrimSpace(line)
-	}()
+	lines := rw.ttyLines()
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
 
 	select {
-	case line := <-result:
-		return line, nil
+	case l := <-lines:
+		if l.err != nil {
+			// The reader stopped; the next question starts another.
+			rw.lines = nil
+			log.Printf("ui: scan err: %s", l.err)
+			return "", l.err
+		}
+		return strings.TrimSpace(l.line), nil
+	case <-timeout:
+		fmt.Fprintln(rw.Writer)
+		return opts.fallback(query, fmt.Errorf("%w after %s", ErrAskTimeout, opts.Timeout))
 	case <-sigCh:
 		// Print a newline so that any further output starts properly
 		// on a new line.
@@ -106,8 +134,28 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 	}
 }
 
+// ttyLines returns the lines read from the TTY, starting the reader if
+// needed. rw.l must be held.
+func (rw *BasicUi) ttyLines() <-chan ttyLine {
+	if rw.lines != nil && rw.linesTTY == rw.TTY {
+		return rw.lines
+	}
+	tty, lines := rw.TTY, make(chan ttyLine, 1)
+	rw.lines, rw.linesTTY = lines, tty
+	go func() {
+		for {
+			line, err := tty.ReadString()
+			lines <- ttyLine{line: line, err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return lines
+}
+
 func (rw *BasicUi) Sayf(message string, args ...any) {
-	rw.Say(fmt.Sprintf(message, args...))
+	rw.Say(i18n.Sprintf(message, args...))
 }
 
 func (rw *BasicUi) Say(message string) {
@@ -139,7 +187,7 @@ func (rw *BasicUi) Message(message string) {
 }
 
 func (rw *BasicUi) Errorf(message string, args ...any) {
-	rw.Error(fmt.Sprintf(message, args...))
+	rw.Error(i18n.Sprintf(message, args...))
 }
 
 func (rw *BasicUi) Error(message string) {
@@ -178,6 +226,8 @@ type SafeUi struct {
 }
 
 var _ Ui = new(SafeUi)
+var _ AskOptionsUi = new(SafeUi)
+var _ BatchUi = new(SafeUi)
 
 func (u *SafeUi) Askf(s string, args ...any) (string, error) {
 	u.Sem <- 1
@@ -194,6 +244,14 @@ func (u *SafeUi) Ask(s string) (string, error) {
 	return ret, err
 }
 
+func (u *SafeUi) AskWithOptions(s string, opts AskOptions) (string, error) {
+	u.Sem <- 1
+	ret, err := AskWithOptions(u.Ui, s, opts)
+	<-u.Sem
+
+	return ret, err
+}
+
 func (u *SafeUi) Sayf(s string, args ...any) {
 	u.Sem <- 1
 	u.Ui.Sayf(s, args...)
@@ -205,6 +263,18 @@ func (u *SafeUi) Say(s string) {
 	<-u.Sem
 }
 
+func (u *SafeUi) SayBatch(messages []string) {
+	u.Sem <- 1
+	SayBatch(u.Ui, messages)
+	<-u.Sem
+}
+
+func (u *SafeUi) MessageBatch(messages []string) {
+	u.Sem <- 1
+	MessageBatch(u.Ui, messages)
+	<-u.Sem
+}
+
 func (u *SafeUi) Message(s string) {
 	u.Sem <- 1
 	u.Ui.Message(s)
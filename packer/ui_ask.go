@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrAskTimeout is returned by AskWithOptions when no answer came in time and
+// there is no default answer.
+var ErrAskTimeout = errors.New("timed out waiting for an answer")
+
+// AskOptions keep a question from blocking a build forever, in CI for
+// example, where no one is there to answer.
+type AskOptions struct {
+	// Timeout is how long to wait for an answer; forever when zero.
+	Timeout time.Duration
+	// Default is the answer used when the timeout expires, or when the Ui
+	// can't ask questions, like when there is no terminal. When it is
+	// empty, ErrAskTimeout or the error of the Ui is returned instead.
+	Default string
+}
+
+// fallback returns the default answer to query, or err when there is none.
+func (o AskOptions) fallback(query string, err error) (string, error) {
+	if o.Default == "" || errors.Is(err, ErrInterrupted) {
+		return "", err
+	}
+	log.Printf("ui: using the default answer %q to %q: %s", o.Default, query, err)
+	return o.Default, nil
+}
+
+// AskOptionsUi is a Ui supporting AskOptions.
+type AskOptionsUi interface {
+	Ui
+	AskWithOptions(string, AskOptions) (string, error)
+}
+
+// AskWithOptions asks query to ui with opts. Uis that are not AskOptionsUis
+// are asked with Ask, and the default answer used when they return an error
+// or the timeout expires; their Ask call then keeps running in the
+// background.
+func AskWithOptions(ui Ui, query string, opts AskOptions) (string, error) {
+	if aui, ok := ui.(AskOptionsUi); ok {
+		return aui.AskWithOptions(query, opts)
+	}
+
+	type answer struct {
+		line string
+		err  error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		line, err := ui.Ask(query)
+		answers <- answer{line, err}
+	}()
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case a := <-answers:
+		if a.err != nil {
+			return opts.fallback(query, a.err)
+		}
+		return a.line, nil
+	case <-timeout:
+		return opts.fallback(query, fmt.Errorf("%w after %s", ErrAskTimeout, opts.Timeout))
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingTTY never answers, like a forgotten prompt.
+type blockingTTY struct {
+	done chan struct{}
+}
+
+func (t *blockingTTY) ReadString() (string, error) {
+	<-t.done
+	return "", io.EOF
+}
+
+func (t *blockingTTY) Close() error { return nil }
+
+func TestBasicUi_AskWithOptions(t *testing.T) {
+	tty := &blockingTTY{done: make(chan struct{})}
+	defer close(tty.done)
+	ui := &BasicUi{Reader: new(bytes.Buffer), Writer: io.Discard, ErrorWriter: io.Discard, TTY: tty}
+
+	answer, err := ui.AskWithOptions("Continue?", AskOptions{Timeout: 10 * time.Millisecond, Default: "yes"})
+	if err != nil || answer != "yes" {
+		t.Fatalf("answer: %q, err: %v", answer, err)
+	}
+	_, err = AskWithOptions(ui, "Continue?", AskOptions{Timeout: 10 * time.Millisecond})
+	if !errors.Is(err, ErrAskTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	// Without a terminal, the default answer is used right away.
+	ui.TTY = nil
+	answer, err = ui.AskWithOptions("Continue?", AskOptions{Default: "no"})
+	if err != nil || answer != "no" {
+		t.Fatalf("answer: %q, err: %v", answer, err)
+	}
+	if _, err := ui.AskWithOptions("Continue?", AskOptions{}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// typingTTY answers with the lines sent to it, like a user typing late.
+type typingTTY struct {
+	lines chan string
+	reads int32
+}
+
+func (t *typingTTY) ReadString() (string, error) {
+	atomic.AddInt32(&t.reads, 1)
+	line, ok := <-t.lines
+	if !ok {
+		return "", io.EOF
+	}
+	return line + "\n", nil
+}
+
+func (t *typingTTY) Close() error { return nil }
+
+func TestBasicUi_AskWithOptions_lateAnswer(t *testing.T) {
+	tty := &typingTTY{lines: make(chan string)}
+	defer close(tty.lines)
+	ui := &BasicUi{Reader: new(bytes.Buffer), Writer: io.Discard, ErrorWriter: io.Discard, TTY: tty}
+
+	answer, err := ui.AskWithOptions("Continue?", AskOptions{Timeout: 10 * time.Millisecond, Default: "yes"})
+	if err != nil || answer != "yes" {
+		t.Fatalf("answer: %q, err: %v", answer, err)
+	}
+
+	// The line typed after the timeout answers the next question.
+	tty.lines <- "abort"
+	answer, err = ui.Ask("Retry?")
+	if err != nil || answer != "abort" {
+		t.Fatalf("answer: %q, err: %v", answer, err)
+	}
+	go func() { tty.lines <- "continue" }()
+	answer, err = ui.Ask("Retry?")
+	if err != nil || answer != "continue" {
+		t.Fatalf("answer: %q, err: %v", answer, err)
+	}
+	// A single reader reads the TTY: the two answers and the pending read.
+	if reads := atomic.LoadInt32(&tty.reads); reads > 3 {
+		t.Fatalf("expected a single reader, got %d reads", reads)
+	}
+}
+
+// blockingUi only implements Ask, and never answers.
+type blockingUi struct {
+	MockUi
+	done chan struct{}
+}
+
+func (u *blockingUi) Ask(string) (string, error) {
+	<-u.done
+	return "", nil
+}
+
+func TestAskWithOptions_fallback(t *testing.T) {
+	ui := &blockingUi{done: make(chan struct{})}
+	defer close(ui.done)
+	answer, err := AskWithOptions(ui, "Continue?", AskOptions{Timeout: 10 * time.Millisecond, Default: "yes"})
+	if err != nil || answer != "yes" {
+		t.Fatalf("answer: %q, err: %v", answer, err)
+	}
+
+	// Uis that can't ask questions get the default answer.
+	answer, err = AskWithOptions(&MachineReadableUi{Writer: io.Discard}, "Continue?", AskOptions{Default: "no"})
+	if err != nil || answer != "no" {
+		t.Fatalf("answer: %q, err: %v", answer, err)
+	}
+}
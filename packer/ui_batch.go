@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"sync"
+	"time"
+)
+
+// BatchUi is a Ui writing several messages in one call, which a plugin sends
+// to Packer at once rather than one RPC call per message.
+type BatchUi interface {
+	Ui
+	SayBatch([]string)
+	MessageBatch([]string)
+}
+
+// SayBatch says messages on ui, at once when it is a BatchUi.
+func SayBatch(ui Ui, messages []string) {
+	if bui, ok := ui.(BatchUi); ok {
+		bui.SayBatch(messages)
+		return
+	}
+	for _, m := range messages {
+		ui.Say(m)
+	}
+}
+
+// MessageBatch writes messages to ui, at once when it is a BatchUi.
+func MessageBatch(ui Ui, messages []string) {
+	if bui, ok := ui.(BatchUi); ok {
+		bui.MessageBatch(messages)
+		return
+	}
+	for _, m := range messages {
+		ui.Message(m)
+	}
+}
+
+// DefaultUiBatchSize is the number of messages of a UiBatcher without Size.
+const DefaultUiBatchSize = 64
+
+// UiBatcher buffers the messages of code writing many of them in a tight
+// loop, like a line per uploaded file, and writes them to Ui in batches, see
+// SayBatch. Messages are written in order. Flush must be called once done,
+// and before other output, like errors, is written to Ui.
+type UiBatcher struct {
+	Ui Ui
+	// Size is the number of buffered messages that triggers a flush,
+	// DefaultUiBatchSize when zero.
+	Size int
+	// Interval, when set, is the longest a message is buffered.
+	Interval time.Duration
+
+	l     sync.Mutex
+	say   bool
+	batch []string
+	timer *time.Timer
+}
+
+// Say buffers a message to say.
+func (b *UiBatcher) Say(message string) {
+	b.add(true, message)
+}
+
+// Message buffers a message to write.
+func (b *UiBatcher) Message(message string) {
+	b.add(false, message)
+}
+
+func (b *UiBatcher) add(say bool, message string) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if len(b.batch) > 0 && b.say != say {
+		b.flushLocked()
+	}
+	b.say = say
+	b.batch = append(b.batch, message)
+	size := b.Size
+	if size <= 0 {
+		size = DefaultUiBatchSize
+	}
+	if len(b.batch) >= size {
+		b.flushLocked()
+		return
+	}
+	if b.Interval > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(b.Interval, b.Flush)
+	}
+}
+
+// Flush writes the buffered messages.
+func (b *UiBatcher) Flush() {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.flushLocked()
+}
+
+func (b *UiBatcher) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.batch) == 0 {
+		return
+	}
+	if b.say {
+		SayBatch(b.Ui, b.batch)
+	} else {
+		MessageBatch(b.Ui, b.batch)
+	}
+	b.batch = nil
+}
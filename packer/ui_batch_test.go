@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecordingUi records the batches it receives.
+type batchRecordingUi struct {
+	MockUi
+
+	l       sync.Mutex
+	batches []string
+}
+
+func (u *batchRecordingUi) record(batch string) {
+	u.l.Lock()
+	defer u.l.Unlock()
+	u.batches = append(u.batches, batch)
+}
+
+func (u *batchRecordingUi) recorded() []string {
+	u.l.Lock()
+	defer u.l.Unlock()
+	return append([]string(nil), u.batches...)
+}
+
+func (u *batchRecordingUi) SayBatch(messages []string) {
+	u.record("say " + joinMessages(messages))
+}
+
+func (u *batchRecordingUi) MessageBatch(messages []string) {
+	u.record("message " + joinMessages(messages))
+}
+
+func joinMessages(messages []string) string {
+	s := ""
+	for _, m := range messages {
+		s += m
+	}
+	return s
+}
+
+func TestUiBatcher(t *testing.T) {
+	ui := new(batchRecordingUi)
+	b := &UiBatcher{Ui: ui, Size: 3}
+
+	b.Say("1")
+	b.Say("2")
+	if batches := ui.recorded(); len(batches) != 0 {
+		t.Fatalf("messages should be buffered: %v", batches)
+	}
+	b.Say("3")
+	b.Say("4")
+	// Switching to Message keeps the order.
+	b.Message("5")
+	b.Flush()
+	expected := []string{"say 123", "say 4", "message 5"}
+	if batches := ui.recorded(); !reflect.DeepEqual(batches, expected) {
+		t.Fatalf("bad batches: %v", batches)
+	}
+}
+
+func TestUiBatcher_interval(t *testing.T) {
+	ui := new(batchRecordingUi)
+	b := &UiBatcher{Ui: ui, Interval: time.Millisecond}
+	b.Say("1")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ui.recorded()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("messages were not flushed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSayBatch_fallback(t *testing.T) {
+	ui := new(MockUi)
+	SayBatch(ui, []string{"1", "2"})
+	if len(ui.SayMessages) != 2 || ui.SayMessages[1].Message != "2" {
+		t.Fatalf("bad messages: %#v", ui.SayMessages)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+)
+
+// UiEventType is the kind of a UiEvent, after the Ui method emitting it.
+type UiEventType string
+
+const (
+	UiEventSay      UiEventType = "say"
+	UiEventMessage  UiEventType = "message"
+	UiEventError    UiEventType = "error"
+	UiEventMachine  UiEventType = "machine"
+	UiEventAsk      UiEventType = "ask"
+	UiEventProgress UiEventType = "progress"
+	// UiEventHeader is the first event written by a MachineReadableUi,
+	// telling the schema version of the events.
+	UiEventHeader UiEventType = "header"
+)
+
+// UiEventSchemaVersion is the version of the schema of the events written by
+// MachineReadableUi. It changes when fields are removed or change meaning.
+const UiEventSchemaVersion = 1
+
+// UiEvent is one call to a Ui, as written by MachineReadableUi.
+type UiEvent struct {
+	Type UiEventType `json:"type"`
+	// Sequence numbers the events written by a MachineReadableUi, from 1,
+	// for parsers to detect dropped and duplicated lines. The header is
+	// number 0.
+	Sequence uint64 `json:"sequence"`
+	// SchemaVersion is the UiEventSchemaVersion of header events.
+	SchemaVersion int            `json:"schema_version,omitempty"`
+	Timestamp     time.Time      `json:"timestamp"`
+	BuildName     string         `json:"build_name,omitempty"`
+	Payload       UiEventPayload `json:"payload"`
+}
+
+// UiEventPayload holds the arguments of the call of a UiEvent.
+type UiEventPayload struct {
+	// Message is the message of say, message, error and ask events.
+	Message string `json:"message,omitempty"`
+	// Category and Args are the arguments of machine events.
+	Category string   `json:"category,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	// Source and Size are the file tracked, and its size, of progress
+	// events.
+	Source string `json:"source,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	// Data is the JSON encoded value of typed machine events, see
+	// MachineReadableUi.MachineData.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// DecodeData decodes the data of a typed machine event into v.
+func (p UiEventPayload) DecodeData(v interface{}) error {
+	if len(p.Data) == 0 {
+		return errors.New("the event has no data")
+	}
+	return json.Unmarshal(p.Data, v)
+}
+
+// EventUi is a Ui receiving the events as is, keeping their timestamp for
+// example, for the events sent in batches by plugins.
+type EventUi interface {
+	Ui
+	Event(UiEvent)
+}
+
+// SendEvent sends e to ui, calling the method of the event when ui is not an
+// EventUi.
+func SendEvent(ui Ui, e UiEvent) {
+	if eui, ok := ui.(EventUi); ok {
+		eui.Event(e)
+		return
+	}
+	switch e.Type {
+	case UiEventSay:
+		ui.Say(e.Payload.Message)
+	case UiEventMessage:
+		ui.Message(e.Payload.Message)
+	case UiEventError:
+		ui.Error(e.Payload.Message)
+	case UiEventMachine:
+		ui.Machine(e.Payload.Category, e.Payload.Args...)
+	default:
+		log.Printf("[DEBUG] Dropping %s Ui event", e.Type)
+	}
+}
+
+// MachineReadableUi is a Ui writing every call as a JSON encoded UiEvent, one
+// per line, for CI systems to parse the output of a build. The first line is
+// a header event, and every event is numbered, see UiEventDecoder. It can't
+// ask questions. It is safe to be called from multiple goroutines.
+type MachineReadableUi struct {
+	Writer io.Writer
+	// BuildName is set in the events that don't have one.
+	BuildName string
+
+	l        sync.Mutex
+	sequence uint64
+}
+
+var _ EventUi = new(MachineReadableUi)
+
+// Event writes e, timestamped now if it is not, with the next sequence
+// number.
+func (u *MachineReadableUi) Event(e UiEvent) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	e.Timestamp = e.Timestamp.UTC()
+	if e.BuildName == "" {
+		e.BuildName = u.BuildName
+	}
+	e.Payload.Message = LogSecretFilter.FilterString(e.Payload.Message)
+	if len(e.Payload.Args) > 0 {
+		args := make([]string, len(e.Payload.Args))
+		for i, arg := range e.Payload.Args {
+			args[i] = LogSecretFilter.FilterString(arg)
+		}
+		e.Payload.Args = args
+	}
+
+	u.l.Lock()
+	defer u.l.Unlock()
+	if u.sequence == 0 {
+		u.write(UiEvent{
+			Type:          UiEventHeader,
+			Timestamp:     e.Timestamp,
+			SchemaVersion: UiEventSchemaVersion,
+		})
+	}
+	u.sequence++
+	e.Sequence = u.sequence
+	e.SchemaVersion = 0
+	u.write(e)
+}
+
+func (u *MachineReadableUi) write(e UiEvent) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("[ERR] Failed to encode Ui event: %s", err)
+		return
+	}
+	if _, err := u.Writer.Write(append(b, '\n')); err != nil {
+		log.Printf("[ERR] Failed to write to UI: %s", err)
+	}
+}
+
+func (u *MachineReadableUi) Askf(query string, args ...any) (string, error) {
+	return u.Ask(i18n.Sprintf(query, args...))
+}
+
+// Ask writes an ask event and fails, there is no one to answer.
+func (u *MachineReadableUi) Ask(query string) (string, error) {
+	u.Event(UiEvent{Type: UiEventAsk, Payload: UiEventPayload{Message: query}})
+	return "", errors.New("machine readable output can't ask questions")
+}
+
+func (u *MachineReadableUi) Sayf(message string, args ...any) {
+	u.Say(i18n.Sprintf(message, args...))
+}
+
+func (u *MachineReadableUi) Say(message string) {
+	u.Event(UiEvent{Type: UiEventSay, Payload: UiEventPayload{Message: message}})
+}
+
+func (u *MachineReadableUi) Message(message string) {
+	u.Event(UiEvent{Type: UiEventMessage, Payload: UiEventPayload{Message: message}})
+}
+
+func (u *MachineReadableUi) Errorf(message string, args ...any) {
+	u.Error(i18n.Sprintf(message, args...))
+}
+
+func (u *MachineReadableUi) Error(message string) {
+	u.Event(UiEvent{Type: UiEventError, Payload: UiEventPayload{Message: message}})
+}
+
+func (u *MachineReadableUi) Machine(t string, args ...string) {
+	u.Event(UiEvent{Type: UiEventMachine, Payload: UiEventPayload{Category: t, Args: args}})
+}
+
+// MachineData writes a machine event of category t with data, JSON encoded,
+// for parsers to decode with UiEventPayload.DecodeData rather than parse
+// string arguments.
+func (u *MachineReadableUi) MachineData(t string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	u.Event(UiEvent{Type: UiEventMachine, Payload: UiEventPayload{Category: t, Data: b}})
+	return nil
+}
+
+// TrackProgress writes a progress event when the transfer starts, and
+// returns stream as is.
+func (u *MachineReadableUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	u.Event(UiEvent{Type: UiEventProgress, Payload: UiEventPayload{Source: src, Size: totalSize}})
+	return stream
+}
+
+// UiSequenceError is returned by UiEventDecoder when events are missing or
+// duplicated, as when lines are lost by the CI system.
+type UiSequenceError struct {
+	Expected uint64
+	Got      uint64
+}
+
+func (e *UiSequenceError) Error() string {
+	if e.Got < e.Expected {
+		return fmt.Sprintf("duplicated Ui event %d, expected event %d", e.Got, e.Expected)
+	}
+	return fmt.Sprintf("%d Ui events dropped before event %d", e.Got-e.Expected, e.Got)
+}
+
+// UiEventDecoder reads the events written by a MachineReadableUi, checking
+// their sequence numbers.
+type UiEventDecoder struct {
+	// SchemaVersion is the version of the header read, 0 until then or when
+	// there is none, as in the output of older versions of Packer.
+	SchemaVersion int
+
+	scanner *bufio.Scanner
+	next    uint64
+}
+
+// NewUiEventDecoder returns a decoder of the events read from r.
+func NewUiEventDecoder(r io.Reader) *UiEventDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	return &UiEventDecoder{scanner: scanner}
+}
+
+// Decode returns the next event, skipping headers, and io.EOF at the end.
+// When events were dropped or duplicated before it, the event is returned
+// along with a *UiSequenceError, and decoding can go on.
+func (d *UiEventDecoder) Decode() (UiEvent, error) {
+	for d.scanner.Scan() {
+		var e UiEvent
+		if err := json.Unmarshal(d.scanner.Bytes(), &e); err != nil {
+			return UiEvent{}, err
+		}
+		if e.Type == UiEventHeader {
+			if e.SchemaVersion > UiEventSchemaVersion {
+				return UiEvent{}, fmt.Errorf("unsupported Ui event schema version %d", e.SchemaVersion)
+			}
+			d.SchemaVersion = e.SchemaVersion
+			d.next = 1
+			continue
+		}
+		if d.SchemaVersion == 0 {
+			return e, nil
+		}
+
+		expected := d.next
+		if e.Sequence >= d.next {
+			d.next = e.Sequence + 1
+		}
+		if e.Sequence != expected {
+			return e, &UiSequenceError{Expected: expected, Got: e.Sequence}
+		}
+		return e, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return UiEvent{}, err
+	}
+	return UiEvent{}, io.EOF
+}
@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMachineReadableUi(t *testing.T) {
+	var out bytes.Buffer
+	ui := &MachineReadableUi{Writer: &out, BuildName: "null.test"}
+	LogSecretFilter.Set("s3cr3t")
+
+	ui.Say("Starting")
+	ui.Errorf("bad password %s", "s3cr3t")
+	ui.Machine("artifact", "0", "id")
+	if _, err := ui.Ask("Continue?"); err == nil {
+		t.Fatal("ask should fail")
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	ui.Event(UiEvent{Type: UiEventMessage, Timestamp: at, BuildName: "other", Payload: UiEventPayload{Message: "plugin"}})
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 lines, got:\n%s", out.String())
+	}
+	var events []UiEvent
+	for i, line := range lines {
+		var e UiEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("bad line %q: %s", line, err)
+		}
+		if e.Timestamp.IsZero() {
+			t.Fatalf("event not timestamped: %s", line)
+		}
+		if e.Sequence != uint64(i) {
+			t.Fatalf("bad sequence number: %s", line)
+		}
+		events = append(events, e)
+	}
+	if e := events[0]; e.Type != UiEventHeader || e.SchemaVersion != UiEventSchemaVersion {
+		t.Fatalf("bad header: %#v", e)
+	}
+	events = events[1:]
+
+	if e := events[0]; e.Type != UiEventSay || e.BuildName != "null.test" || e.Payload.Message != "Starting" {
+		t.Fatalf("bad event: %#v", e)
+	}
+	if e := events[1]; e.Type != UiEventError || e.Payload.Message != "bad password <sensitive>" {
+		t.Fatalf("bad event: %#v", e)
+	}
+	if e := events[2]; e.Type != UiEventMachine || e.Payload.Category != "artifact" || strings.Join(e.Payload.Args, ",") != "0,id" {
+		t.Fatalf("bad event: %#v", e)
+	}
+	if e := events[3]; e.Type != UiEventAsk || e.Payload.Message != "Continue?" {
+		t.Fatalf("bad event: %#v", e)
+	}
+	if e := events[4]; !e.Timestamp.Equal(at) || e.BuildName != "other" {
+		t.Fatalf("bad event: %#v", e)
+	}
+}
+
+func TestSendEvent(t *testing.T) {
+	ui := new(MockUi)
+	SendEvent(ui, UiEvent{Type: UiEventMessage, Payload: UiEventPayload{Message: "hello"}})
+	if ui.MessageMessage != "hello" {
+		t.Fatalf("bad: %#v", ui)
+	}
+}
+
+func TestMachineReadableUi_MachineData(t *testing.T) {
+	var out bytes.Buffer
+	ui := &MachineReadableUi{Writer: &out}
+	type artifact struct {
+		ID    string `json:"id"`
+		Files int    `json:"files"`
+	}
+	if err := ui.MachineData("artifact", artifact{ID: "ami-1", Files: 2}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	e, err := NewUiEventDecoder(&out).Decode()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var a artifact
+	if err := e.Payload.DecodeData(&a); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e.Payload.Category != "artifact" || a.ID != "ami-1" || a.Files != 2 {
+		t.Fatalf("bad event: %#v", e)
+	}
+}
+
+func TestUiEventDecoder(t *testing.T) {
+	var out bytes.Buffer
+	ui := &MachineReadableUi{Writer: &out}
+	for i := 0; i < 4; i++ {
+		ui.Say("hello")
+	}
+	lines := strings.SplitAfter(out.String(), "\n")
+	// Drop event 2 and duplicate event 3.
+	in := lines[0] + lines[1] + lines[3] + lines[3] + lines[4]
+
+	dec := NewUiEventDecoder(strings.NewReader(in))
+	var seqErr *UiSequenceError
+	expected := []struct {
+		sequence uint64
+		err      *UiSequenceError
+	}{
+		{1, nil},
+		{3, &UiSequenceError{Expected: 2, Got: 3}},
+		{3, &UiSequenceError{Expected: 4, Got: 3}},
+		{4, nil},
+	}
+	for _, x := range expected {
+		e, err := dec.Decode()
+		if e.Sequence != x.sequence {
+			t.Fatalf("expected event %d, got %#v", x.sequence, e)
+		}
+		if x.err == nil && err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if x.err != nil && (!errors.As(err, &seqErr) || *seqErr != *x.err) {
+			t.Fatalf("expected %s, got %v", x.err, err)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if dec.SchemaVersion != UiEventSchemaVersion {
+		t.Fatalf("bad schema version %d", dec.SchemaVersion)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ConfigValidator is implemented by builders, provisioners, post-processors
+// and datasources that can check their configuration without preparing
+// themselves, so that `packer validate` does not have to call Prepare or
+// Configure.
+type ConfigValidator interface {
+	// ValidateConfig decodes and checks the configuration the same way
+	// Prepare does, but must have no side effects: it must not create API
+	// clients, contact remote services, create files or keep any state on
+	// the receiver. Diagnostics about a single option should be created with
+	// AttributeDiagnostic so that Packer can point at it in the template.
+	ValidateConfig(raws ...interface{}) hcl.Diagnostics
+}
+
+// OptionalConfigValidator is implemented by components that only know at
+// run time whether they can validate their configuration, like the RPC
+// clients of plugins built with an older SDK.
+type OptionalConfigValidator interface {
+	ConfigValidator
+
+	SupportsValidateConfig() bool
+}
+
+// ValidateConfig validates raws with component if it is a ConfigValidator.
+// The returned boolean is false when it isn't, in which case callers should
+// fall back to preparing the component.
+func ValidateConfig(component interface{}, raws ...interface{}) (hcl.Diagnostics, bool) {
+	if o, ok := component.(OptionalConfigValidator); ok && !o.SupportsValidateConfig() {
+		return nil, false
+	}
+	v, ok := component.(ConfigValidator)
+	if !ok {
+		return nil, false
+	}
+	return v.ValidateConfig(raws...), true
+}
@@ -0,0 +1,299 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packerbuilderdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// TypedDataKey is the key of the generated data holding the typed values of a
+// Store, for the provisioners and post-processors receiving the generated
+// data to read them back with Decode.
+const TypedDataKey = "PackerTypedGeneratedData"
+
+// storeStateKey is the key of the Store of a build in its state.
+const storeStateKey = "generated_data_store"
+
+// Schema are the keys of the generated data of a builder, and their types.
+type Schema map[string]cty.Type
+
+// Reader reads typed generated data. It is implemented by Store, and by the
+// client of a Store served over RPC.
+type Reader interface {
+	// Schema returns the declared keys and their types.
+	Schema() Schema
+	// Get returns the value of key, and whether it is set.
+	Get(key string) (cty.Value, bool)
+}
+
+// Store holds generated data typed by a Schema. Values are converted to the
+// type declared for their key when set, so that the provisioners and
+// post-processors reading them get what the builder declared, and watchers
+// are told of every change. A Store is safe to use from multiple goroutines.
+type Store struct {
+	lock     sync.Mutex
+	schema   Schema
+	values   map[string]cty.Value
+	watchers map[int]func(key string, value cty.Value)
+	nextID   int
+	state    multistep.StateBag
+}
+
+// NewStore returns a Store of the keys of schema.
+func NewStore(schema Schema) *Store {
+	s := &Store{schema: Schema{}, values: map[string]cty.Value{}, watchers: map[int]func(string, cty.Value){}}
+	for key, t := range schema {
+		s.schema[key] = t
+	}
+	return s
+}
+
+// StoreOf returns the Store of the build of state, creating it the first
+// time. What is set in it is also put in the generated data of the state,
+// see GeneratedData, as plain Go values for the existing consumers, and
+// typed under TypedDataKey.
+func StoreOf(state multistep.StateBag) *Store {
+	if s, ok := state.GetOk(storeStateKey); ok {
+		return s.(*Store)
+	}
+	s := NewStore(nil)
+	s.state = state
+	state.Put(storeStateKey, s)
+	return s
+}
+
+// Declare adds key to the schema with type t. Declaring a key again with the
+// same type does nothing.
+func (s *Store) Declare(key string, t cty.Type) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if current, ok := s.schema[key]; ok && !current.Equals(t) {
+		return fmt.Errorf("%s is already declared as %s", key, current.FriendlyName())
+	}
+	s.schema[key] = t
+	return nil
+}
+
+// Schema returns a copy of the schema of the store.
+func (s *Store) Schema() Schema {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	schema := make(Schema, len(s.schema))
+	for key, t := range s.schema {
+		schema[key] = t
+	}
+	return schema
+}
+
+// Set sets key to v, converted to the declared type of key. It fails when key
+// is not declared, or v can't be converted.
+func (s *Store) Set(key string, v cty.Value) error {
+	s.lock.Lock()
+	t, ok := s.schema[key]
+	if !ok {
+		s.lock.Unlock()
+		return fmt.Errorf("%s is not declared", key)
+	}
+	if !v.IsWhollyKnown() {
+		s.lock.Unlock()
+		return fmt.Errorf("%s: the value must be known", key)
+	}
+	converted, err := convert.Convert(v, t)
+	if err != nil {
+		s.lock.Unlock()
+		return fmt.Errorf("%s: %s", key, err)
+	}
+	s.values[key] = converted
+	watchers := make([]func(string, cty.Value), 0, len(s.watchers))
+	for _, watcher := range s.watchers {
+		watchers = append(watchers, watcher)
+	}
+	if s.state != nil {
+		if err := s.mirror(key, converted); err != nil {
+			s.lock.Unlock()
+			return err
+		}
+	}
+	s.lock.Unlock()
+
+	for _, watcher := range watchers {
+		watcher(key, converted)
+	}
+	return nil
+}
+
+// mirror puts value in the generated data of the state.
+func (s *Store) mirror(key string, value cty.Value) error {
+	typed, err := encodeTyped(s.schema, s.values)
+	if err != nil {
+		return err
+	}
+	gd := &GeneratedData{State: s.state}
+	gd.Put(key, GoValue(value))
+	gd.Put(TypedDataKey, typed)
+	return nil
+}
+
+// Get returns the value of key, and whether it is set.
+func (s *Store) Get(key string) (cty.Value, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Keys returns the keys set, sorted.
+func (s *Store) Keys() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Watch calls fn with every value set from now on, until the returned
+// function is called. fn is called from the goroutine setting the value, and
+// must not block.
+func (s *Store) Watch(fn func(key string, value cty.Value)) (stop func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	id := s.nextID
+	s.nextID++
+	s.watchers[id] = fn
+	return func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.watchers, id)
+	}
+}
+
+// typedData is the content of TypedDataKey: the values of a store as a cty
+// object, in JSON, along with its type.
+type typedData struct {
+	Type  json.RawMessage `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// encodeTyped returns values as the JSON content of TypedDataKey. The keys
+// declared but not set are null.
+func encodeTyped(schema Schema, values map[string]cty.Value) (string, error) {
+	attrs := map[string]cty.Type{}
+	vals := map[string]cty.Value{}
+	for key, t := range schema {
+		attrs[key] = t
+		vals[key] = cty.NullVal(t)
+		if v, ok := values[key]; ok {
+			vals[key] = v
+		}
+	}
+	t := cty.Object(attrs)
+	rawType, err := ctyjson.MarshalType(t)
+	if err != nil {
+		return "", err
+	}
+	rawValue, err := ctyjson.Marshal(cty.ObjectVal(vals), t)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(typedData{Type: rawType, Value: rawValue})
+	return string(b), err
+}
+
+// Decode returns the typed values of the generated data a provisioner or a
+// post-processor received, as a Store. It is empty when the builder did not
+// use a Store.
+func Decode(generatedData map[string]interface{}) (*Store, error) {
+	s := NewStore(nil)
+	raw, ok := generatedData[TypedDataKey].(string)
+	if !ok {
+		return s, nil
+	}
+	var data typedData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, fmt.Errorf("decoding %s: %s", TypedDataKey, err)
+	}
+	t, err := ctyjson.UnmarshalType(data.Type)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %s", TypedDataKey, err)
+	}
+	v, err := ctyjson.Unmarshal(data.Value, t)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %s", TypedDataKey, err)
+	}
+	for key, t := range t.AttributeTypes() {
+		s.schema[key] = t
+		if attr := v.GetAttr(key); !attr.IsNull() {
+			s.values[key] = attr
+		}
+	}
+	return s, nil
+}
+
+// GoValue returns v as the Go types of generated data: strings, bools, ints
+// for whole numbers and float64 for the others, []string and
+// map[string]string for collections of strings, and []interface{} and
+// map[string]interface{} for the other collections.
+func GoValue(v cty.Value) interface{} {
+	if v.IsNull() || !v.IsKnown() {
+		return nil
+	}
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return v.AsString()
+	case ty == cty.Bool:
+		return v.True()
+	case ty == cty.Number:
+		f := v.AsBigFloat()
+		if i, acc := f.Int64(); acc == big.Exact && i >= math.MinInt && i <= math.MaxInt {
+			return int(i)
+		}
+		f64, _ := f.Float64()
+		return f64
+	case (ty.IsListType() || ty.IsSetType()) && ty.ElementType() == cty.String:
+		l := make([]string, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			if elem.IsKnown() && !elem.IsNull() {
+				l = append(l, elem.AsString())
+			}
+		}
+		return l
+	case ty.IsMapType() && ty.ElementType() == cty.String:
+		m := map[string]string{}
+		for k, elem := range v.AsValueMap() {
+			if elem.IsKnown() && !elem.IsNull() {
+				m[k] = elem.AsString()
+			}
+		}
+		return m
+	case ty.IsListType() || ty.IsTupleType() || ty.IsSetType():
+		l := make([]interface{}, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			l = append(l, GoValue(elem))
+		}
+		return l
+	case ty.IsMapType() || ty.IsObjectType():
+		m := map[string]interface{}{}
+		for k, elem := range v.AsValueMap() {
+			m[k] = GoValue(elem)
+		}
+		return m
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packerbuilderdata
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestStore(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	store := StoreOf(state)
+	if StoreOf(state) != store {
+		t.Fatalf("the store of a state should be created once")
+	}
+	if err := store.Declare("InstanceId", cty.String); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := store.Declare("Ports", cty.List(cty.Number)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := store.Declare("InstanceId", cty.Number); err == nil {
+		t.Fatalf("expected an error redeclaring InstanceId with another type")
+	}
+
+	var changes []string
+	stop := store.Watch(func(key string, _ cty.Value) { changes = append(changes, key) })
+
+	if err := store.Set("InstanceId", cty.StringVal("i-1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Values are converted to the declared type.
+	if err := store.Set("Ports", cty.TupleVal([]cty.Value{cty.NumberIntVal(22), cty.StringVal("5985")})); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := store.Set("Ports", cty.StringVal("ssh")); err == nil {
+		t.Fatalf("expected a conversion error")
+	}
+	if err := store.Set("Unknown", cty.StringVal("x")); err == nil {
+		t.Fatalf("expected an error setting an undeclared key")
+	}
+	stop()
+	if err := store.Set("InstanceId", cty.StringVal("i-2")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(changes, []string{"InstanceId", "Ports"}) {
+		t.Fatalf("bad changes: %v", changes)
+	}
+
+	ports, ok := store.Get("Ports")
+	if !ok || !ports.RawEquals(cty.ListVal([]cty.Value{cty.NumberIntVal(22), cty.NumberIntVal(5985)})) {
+		t.Fatalf("bad ports: %#v", ports)
+	}
+	if !reflect.DeepEqual(store.Keys(), []string{"InstanceId", "Ports"}) {
+		t.Fatalf("bad keys: %v", store.Keys())
+	}
+
+	// The generated data has the plain values, and the typed ones.
+	generated := state.Get("generated_data").(map[string]interface{})
+	if generated["InstanceId"] != "i-2" || !reflect.DeepEqual(generated["Ports"], []interface{}{22, 5985}) {
+		t.Fatalf("bad generated data: %#v", generated)
+	}
+	decoded, err := Decode(generated)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(decoded.Keys(), []string{"InstanceId", "Ports"}) {
+		t.Fatalf("bad decoded keys: %v", decoded.Keys())
+	}
+	if v, _ := decoded.Get("Ports"); !v.RawEquals(ports) {
+		t.Fatalf("bad decoded ports: %#v", v)
+	}
+	if !decoded.Schema()["Ports"].Equals(cty.List(cty.Number)) {
+		t.Fatalf("bad decoded schema: %#v", decoded.Schema())
+	}
+}
+
+func TestDecode_untyped(t *testing.T) {
+	store, err := Decode(map[string]interface{}{"ID": "i-1"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(store.Keys()) != 0 {
+		t.Fatalf("expected an empty store, got %v", store.Keys())
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package pathutil handles paths in the style of a given OS rather than the
+// style of the host running the plugin, which path/filepath is limited to.
+// Provisioners use it to compute the paths of a Windows guest from a Linux
+// host, or the other way round, without corrupting them.
+package pathutil
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+)
+
+// A Style is a set of path rules: separators, volume names and what an
+// absolute path is.
+type Style int
+
+const (
+	// Posix paths are separated by slashes, and absolute when they begin
+	// with one.
+	Posix Style = iota
+	// Windows paths are separated by backslashes, or slashes, and may begin
+	// with a drive letter like `C:` or a UNC share like `\\server\share`.
+	// They are absolute when they begin with a drive letter and a
+	// separator, or a UNC share.
+	Windows
+)
+
+// Host is the style of the paths of the host running the plugin.
+var Host = func() Style {
+	if runtime.GOOS == "windows" {
+		return Windows
+	}
+	return Posix
+}()
+
+// ForOSType returns the style of the paths of a guest of the OS profile
+// osType, like guestexec.UnixOSType or guestexec.WindowsOSType. Unknown
+// profiles use Posix paths.
+func ForOSType(osType string) Style {
+	if p, ok := guestexec.LookupOSProfile(osType); ok && p.Family == guestexec.WindowsOSType {
+		return Windows
+	}
+	return Posix
+}
+
+func (s Style) String() string {
+	if s == Windows {
+		return "windows"
+	}
+	return "posix"
+}
+
+// Separator returns the preferred separator of the paths.
+func (s Style) Separator() string {
+	if s == Windows {
+		return `\`
+	}
+	return "/"
+}
+
+// IsSeparator reports whether c separates the elements of the paths.
+func (s Style) IsSeparator(c byte) bool {
+	return c == '/' || (s == Windows && c == '\\')
+}
+
+// VolumeName returns the leading volume name of p: `C:` or
+// `\\server\share` for Windows paths, and always "" for Posix paths.
+func (s Style) VolumeName(p string) string {
+	if s != Windows {
+		return ""
+	}
+	if len(p) >= 2 && p[1] == ':' && isLetter(p[0]) {
+		return p[:2]
+	}
+	// A UNC share: two separators, a server name, a separator and a share
+	// name.
+	if len(p) < 5 || !s.IsSeparator(p[0]) || !s.IsSeparator(p[1]) || s.IsSeparator(p[2]) {
+		return ""
+	}
+	i := 3
+	for i < len(p) && !s.IsSeparator(p[i]) {
+		i++
+	}
+	if i+1 >= len(p) || s.IsSeparator(p[i+1]) {
+		return ""
+	}
+	i++
+	for i < len(p) && !s.IsSeparator(p[i]) {
+		i++
+	}
+	return p[:i]
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// IsAbs reports whether p is absolute.
+func (s Style) IsAbs(p string) bool {
+	if s != Windows {
+		return strings.HasPrefix(p, "/")
+	}
+	vol := s.VolumeName(p)
+	if vol == "" {
+		return false
+	}
+	if len(vol) > 2 {
+		return true
+	}
+	return len(p) > 2 && s.IsSeparator(p[2])
+}
+
+// ToSlash returns p with its separators replaced by slashes.
+func (s Style) ToSlash(p string) string {
+	if s != Windows {
+		return p
+	}
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// FromSlash returns p with its slashes replaced by the preferred separator.
+func (s Style) FromSlash(p string) string {
+	if s != Windows {
+		return p
+	}
+	return strings.ReplaceAll(p, "/", `\`)
+}
+
+// Clean returns the shortest path equivalent to p, like filepath.Clean
+// would on an OS of the style, with the preferred separators.
+func (s Style) Clean(p string) string {
+	vol := s.VolumeName(p)
+	rest := s.ToSlash(p[len(vol):])
+	if rest == "" {
+		if vol == "" {
+			return "."
+		}
+		return s.FromSlash(vol)
+	}
+	return s.FromSlash(vol) + s.FromSlash(path.Clean(rest))
+}
+
+// Join joins the elements of a path, ignoring the empty ones, and cleans
+// the result. Unlike filepath.Join, joining a Windows drive letter and a
+// relative path, like `C:` and `Temp`, gives an absolute path, `C:\Temp`,
+// as the drive letters of guest paths seldom mean their current directory.
+func (s Style) Join(elem ...string) string {
+	var parts []string
+	for _, e := range elem {
+		if e != "" {
+			parts = append(parts, e)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	if s == Windows && len(parts) > 1 && len(parts[0]) == 2 && s.VolumeName(parts[0]) == parts[0] {
+		parts[0] += `\`
+	}
+	return s.Clean(strings.Join(parts, s.Separator()))
+}
+
+// Split splits p after its last separator, in a directory and a file name.
+func (s Style) Split(p string) (dir, file string) {
+	vol := s.VolumeName(p)
+	i := len(p) - 1
+	for i >= len(vol) && !s.IsSeparator(p[i]) {
+		i--
+	}
+	return p[:i+1], p[i+1:]
+}
+
+// Base returns the last element of p, ignoring trailing separators, like
+// filepath.Base would on an OS of the style.
+func (s Style) Base(p string) string {
+	if p == "" {
+		return "."
+	}
+	p = p[len(s.VolumeName(p)):]
+	for len(p) > 0 && s.IsSeparator(p[len(p)-1]) {
+		p = p[:len(p)-1]
+	}
+	if p == "" {
+		return s.Separator()
+	}
+	_, file := s.Split(p)
+	return file
+}
+
+// Dir returns all but the last element of p, cleaned, like filepath.Dir
+// would on an OS of the style.
+func (s Style) Dir(p string) string {
+	vol := s.VolumeName(p)
+	dir, _ := s.Split(p)
+	if len(dir) < len(vol) {
+		dir = vol
+	}
+	return s.Clean(dir)
+}
+
+// HasTrailingSeparator reports whether p ends with a separator, which
+// provisioners take as a destination directory.
+func (s Style) HasTrailingSeparator(p string) bool {
+	return p != "" && s.IsSeparator(p[len(p)-1])
+}
+
+// Convert returns p, a path of the style from, as a path of the style to.
+// Only relative paths can be converted, as the roots of the styles don't
+// match: `C:\` has no Posix equivalent.
+func Convert(p string, from, to Style) (string, error) {
+	if from.IsAbs(p) || from.VolumeName(p) != "" {
+		return "", fmt.Errorf("%q: only relative paths can be converted from %s to %s paths", p, from, to)
+	}
+	if from == to {
+		return p, nil
+	}
+	if from == Posix && to == Windows && strings.Contains(p, `\`) {
+		return "", fmt.Errorf("%q: a posix path with backslashes can't be converted to a windows path", p)
+	}
+	if from == Windows && strings.HasPrefix(from.ToSlash(p), "/") {
+		return "", fmt.Errorf("%q: only relative paths can be converted from %s to %s paths", p, from, to)
+	}
+	return to.FromSlash(from.ToSlash(p)), nil
+}
+
+// UploadDestination returns the guest path a host file src is uploaded to
+// when the destination is dst: dst itself, or the base name of src in dst
+// when dst ends with a separator, as with the `destination` of the file
+// provisioner. guest is the style of the guest paths.
+func UploadDestination(guest Style, dst, src string) string {
+	if !guest.HasTrailingSeparator(dst) {
+		return dst
+	}
+	return guest.Join(dst, Host.Base(src))
+}
+
+// RelativeUploadDestination returns the guest path the host file src, in
+// the host directory srcRoot, is uploaded to when the directory is uploaded
+// to the guest directory dstRoot, for provisioners uploading a tree file by
+// file.
+func RelativeUploadDestination(guest Style, dstRoot, srcRoot, src string) (string, error) {
+	rootSlash := strings.TrimSuffix(Host.ToSlash(Host.Clean(srcRoot)), "/")
+	srcSlash := Host.ToSlash(Host.Clean(src))
+	rel := strings.TrimPrefix(srcSlash, rootSlash+"/")
+	if rel == srcSlash {
+		return "", fmt.Errorf("%q is not in %q", src, srcRoot)
+	}
+	return guest.Join(dstRoot, guest.FromSlash(rel)), nil
+}
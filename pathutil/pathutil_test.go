@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package pathutil
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+)
+
+func TestStyle_Clean(t *testing.T) {
+	tests := []struct {
+		Style Style
+		Path  string
+		Want  string
+	}{
+		{Posix, "", "."},
+		{Posix, "/tmp//packer/../scripts/", "/tmp/scripts"},
+		{Posix, `dir\file`, `dir\file`},
+		{Windows, `C:/Windows\Temp/../System32\`, `C:\Windows\System32`},
+		{Windows, `C:`, `C:`},
+		{Windows, `C:\..`, `C:\`},
+		{Windows, `\\server\share/dir/..`, `\\server\share\`},
+		{Windows, `scripts/./install.ps1`, `scripts\install.ps1`},
+	}
+	for _, tt := range tests {
+		if got := tt.Style.Clean(tt.Path); got != tt.Want {
+			t.Errorf("%s Clean(%q) = %q, expected %q", tt.Style, tt.Path, got, tt.Want)
+		}
+	}
+}
+
+func TestStyle_Join(t *testing.T) {
+	tests := []struct {
+		Style Style
+		Elem  []string
+		Want  string
+	}{
+		{Posix, []string{"/tmp", "", "scripts", "run.sh"}, "/tmp/scripts/run.sh"},
+		{Posix, []string{"", ""}, ""},
+		{Windows, []string{"C:", "Windows", "Temp"}, `C:\Windows\Temp`},
+		{Windows, []string{`C:\Users\`, "packer/.ssh"}, `C:\Users\packer\.ssh`},
+		{Windows, []string{`\\server\share`, "setup.exe"}, `\\server\share\setup.exe`},
+	}
+	for _, tt := range tests {
+		if got := tt.Style.Join(tt.Elem...); got != tt.Want {
+			t.Errorf("%s Join(%q) = %q, expected %q", tt.Style, tt.Elem, got, tt.Want)
+		}
+	}
+}
+
+func TestStyle_IsAbs(t *testing.T) {
+	tests := []struct {
+		Style Style
+		Path  string
+		Want  bool
+	}{
+		{Posix, "/etc", true},
+		{Posix, "etc", false},
+		{Posix, `C:\Windows`, false},
+		{Windows, `C:\Windows`, true},
+		{Windows, `c:/Windows`, true},
+		{Windows, `C:Windows`, false},
+		{Windows, `\Windows`, false},
+		{Windows, `\\server\share`, true},
+		{Windows, `\\server`, false},
+		{Windows, "/etc", false},
+	}
+	for _, tt := range tests {
+		if got := tt.Style.IsAbs(tt.Path); got != tt.Want {
+			t.Errorf("%s IsAbs(%q) = %t", tt.Style, tt.Path, got)
+		}
+	}
+}
+
+func TestStyle_BaseDir(t *testing.T) {
+	tests := []struct {
+		Style Style
+		Path  string
+		Base  string
+		Dir   string
+	}{
+		{Posix, "/tmp/scripts/run.sh", "run.sh", "/tmp/scripts"},
+		{Posix, "/tmp/scripts/", "scripts", "/tmp/scripts"},
+		{Posix, "run.sh", "run.sh", "."},
+		{Posix, `C:\Temp\run.ps1`, `C:\Temp\run.ps1`, "."},
+		{Windows, `C:\Temp\run.ps1`, "run.ps1", `C:\Temp`},
+		{Windows, `C:/Temp/run.ps1`, "run.ps1", `C:\Temp`},
+		{Windows, `C:\run.ps1`, "run.ps1", `C:\`},
+		{Windows, `C:\`, `\`, `C:\`},
+		{Windows, `\\server\share\setup.exe`, "setup.exe", `\\server\share\`},
+	}
+	for _, tt := range tests {
+		if got := tt.Style.Base(tt.Path); got != tt.Base {
+			t.Errorf("%s Base(%q) = %q, expected %q", tt.Style, tt.Path, got, tt.Base)
+		}
+		if got := tt.Style.Dir(tt.Path); got != tt.Dir {
+			t.Errorf("%s Dir(%q) = %q, expected %q", tt.Style, tt.Path, got, tt.Dir)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	got, err := Convert("scripts/windows/setup.ps1", Posix, Windows)
+	if err != nil || got != `scripts\windows\setup.ps1` {
+		t.Fatalf("bad conversion %q: %v", got, err)
+	}
+	got, err = Convert(`scripts\linux/setup.sh`, Windows, Posix)
+	if err != nil || got != "scripts/linux/setup.sh" {
+		t.Fatalf("bad conversion %q: %v", got, err)
+	}
+
+	for _, tt := range []struct {
+		Path     string
+		From, To Style
+	}{
+		{"/tmp/file", Posix, Windows},
+		{`C:\Temp`, Windows, Posix},
+		{`C:Temp`, Windows, Posix},
+		{`\Temp`, Windows, Posix},
+		{`odd\name`, Posix, Windows},
+	} {
+		if _, err := Convert(tt.Path, tt.From, tt.To); err == nil {
+			t.Errorf("converting %q from %s to %s should fail", tt.Path, tt.From, tt.To)
+		}
+	}
+}
+
+func TestUploadDestination(t *testing.T) {
+	src := Host.Join("files", "app.conf")
+	tests := []struct {
+		Guest Style
+		Dst   string
+		Want  string
+	}{
+		{Posix, "/etc/app/", "/etc/app/app.conf"},
+		{Posix, "/etc/app/custom.conf", "/etc/app/custom.conf"},
+		{Windows, `C:\ProgramData\App\`, `C:\ProgramData\App\app.conf`},
+		{Windows, `C:/ProgramData/App/`, `C:\ProgramData\App\app.conf`},
+	}
+	for _, tt := range tests {
+		if got := UploadDestination(tt.Guest, tt.Dst, src); got != tt.Want {
+			t.Errorf("%s UploadDestination(%q) = %q, expected %q", tt.Guest, tt.Dst, got, tt.Want)
+		}
+	}
+}
+
+func TestRelativeUploadDestination(t *testing.T) {
+	root := Host.Join("files", "app")
+	got, err := RelativeUploadDestination(Windows, `C:\App`, root, Host.Join(root, "conf", "app.conf"))
+	if err != nil || got != `C:\App\conf\app.conf` {
+		t.Fatalf("bad destination %q: %v", got, err)
+	}
+	if _, err := RelativeUploadDestination(Posix, "/app", root, Host.Join("files", "other")); err == nil {
+		t.Fatal("a file outside of the root should fail")
+	}
+}
+
+func TestForOSType(t *testing.T) {
+	if ForOSType(guestexec.WindowsOSType) != Windows || ForOSType(guestexec.WindowsCoreOSType) != Windows {
+		t.Fatal("windows guests should use windows paths")
+	}
+	if ForOSType(guestexec.UnixOSType) != Posix || ForOSType("plan9") != Posix {
+		t.Fatal("other guests should use posix paths")
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+// HandshakeKey is the environment variable in which Packer sends its
+// Handshake, encoded in JSON, to the plugins it starts. Versions of Packer
+// that don't set it only tell their protocols, in ProtocolsKey.
+const HandshakeKey = "PACKER_PLUGIN_HANDSHAKE"
+
+// HandshakeVersion is the version of the Handshake messages of this SDK.
+const HandshakeVersion = 1
+
+// EncodingMsgpack is the msgpack encoding of the net/rpc messages, the only
+// one before handshakes were introduced.
+const EncodingMsgpack = "msgpack"
+
+const (
+	// CapabilityArtifactV2 is the support of packersdk.ArtifactV2 and its
+	// metadata.
+	CapabilityArtifactV2 = "artifact_v2"
+	// CapabilityUiBuffering is the support of Ui output sent in batches, see
+	// Set.EnableUiBuffering.
+	CapabilityUiBuffering = "ui_buffering"
+	// CapabilityLogForwarding is the support of log records forwarded over
+	// RPC with their levels, see Set.Logger.
+	CapabilityLogForwarding = "log_forwarding"
+	// CapabilityFunctions is the support of the template functions a plugin
+	// serves, see Set.RegisterFunction. It is advertised rather than told by
+	// a new API version, which the versions of Packer that don't know about
+	// functions would refuse.
+	CapabilityFunctions = "functions"
+)
+
+// Handshake tells what one end of a plugin connection supports. Packer sends
+// its handshake in HandshakeKey, and the plugin answers with the negotiated
+// one, the features both ends support, along with its address.
+type Handshake struct {
+	Version int `json:"version"`
+	// Protocols, like ProtocolGRPC, by order of preference.
+	Protocols []string `json:"protocols"`
+	// Encodings of the net/rpc messages, like EncodingMsgpack.
+	Encodings []string `json:"encodings"`
+	// Capabilities are the optional features, like CapabilityArtifactV2.
+	Capabilities []string `json:"capabilities,omitempty"`
+	// MaxProcs is the number of CPUs Packer lets the plugin use, which is
+	// its GOMAXPROCS unless set in its environment. Only Packer sets it,
+	// and it is kept in the negotiated handshake.
+	MaxProcs int `json:"max_procs,omitempty"`
+}
+
+// CoreHandshake returns the handshake Packer sent. For versions of Packer
+// predating handshakes, it is a version 0 handshake with the protocols listed
+// in ProtocolsKey, and no capabilities.
+func CoreHandshake() *Handshake {
+	if raw := os.Getenv(HandshakeKey); raw != "" {
+		h := new(Handshake)
+		err := json.Unmarshal([]byte(raw), h)
+		if err == nil {
+			return h
+		}
+		log.Printf("[WARN] Ignoring invalid %s: %s", HandshakeKey, err)
+	}
+
+	h := &Handshake{Encodings: []string{EncodingMsgpack}}
+	for _, protocol := range strings.Split(os.Getenv(ProtocolsKey), ",") {
+		if protocol = strings.TrimSpace(protocol); protocol != "" {
+			h.Protocols = append(h.Protocols, protocol)
+		}
+	}
+	if len(h.Protocols) == 0 {
+		h.Protocols = []string{ProtocolNetRPC}
+	}
+	return h
+}
+
+// Negotiate returns what both core and plugin support, in the order of
+// preference of plugin, at the lowest of their versions.
+func Negotiate(core, plugin *Handshake) *Handshake {
+	version := plugin.Version
+	if core.Version < version {
+		version = core.Version
+	}
+	return &Handshake{
+		Version:      version,
+		Protocols:    intersect(plugin.Protocols, core.Protocols),
+		Encodings:    intersect(plugin.Encodings, core.Encodings),
+		Capabilities: intersect(plugin.Capabilities, core.Capabilities),
+		MaxProcs:     core.MaxProcs,
+	}
+}
+
+// Has tells whether capability was negotiated.
+func (h *Handshake) Has(capability string) bool {
+	return contains(h.Capabilities, capability)
+}
+
+// Speaks tells whether protocol was negotiated.
+func (h *Handshake) Speaks(protocol string) bool {
+	return contains(h.Protocols, protocol)
+}
+
+// encode returns the handshake as it is added to the address of the plugin:
+// URL-safe base64 JSON, which can't contain the `|` separating the fields of
+// the address.
+func (h *Handshake) encode() string {
+	b, err := json.Marshal(h)
+	if err != nil {
+		// A Handshake only holds strings.
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func intersect(preferred, other []string) []string {
+	var res []string
+	for _, s := range preferred {
+		if contains(other, s) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	packrpc "github.com/hashicorp/packer-plugin-sdk/rpc"
+)
+
+func TestCoreHandshake(t *testing.T) {
+	t.Setenv(HandshakeKey, "")
+	t.Setenv(ProtocolsKey, "")
+	legacy := &Handshake{Protocols: []string{ProtocolNetRPC}, Encodings: []string{EncodingMsgpack}}
+	if diff := cmp.Diff(legacy, CoreHandshake()); diff != "" {
+		t.Fatalf("Unexpected handshake: %s", diff)
+	}
+
+	t.Setenv(ProtocolsKey, "netrpc, grpc")
+	legacy.Protocols = []string{ProtocolNetRPC, ProtocolGRPC}
+	if diff := cmp.Diff(legacy, CoreHandshake()); diff != "" {
+		t.Fatalf("Unexpected handshake: %s", diff)
+	}
+
+	t.Setenv(HandshakeKey, `{"version":2,"protocols":["netrpc"],"encodings":["msgpack"],"capabilities":["artifact_v2","future"]}`)
+	expected := &Handshake{
+		Version:      2,
+		Protocols:    []string{ProtocolNetRPC},
+		Encodings:    []string{EncodingMsgpack},
+		Capabilities: []string{CapabilityArtifactV2, "future"},
+	}
+	if diff := cmp.Diff(expected, CoreHandshake()); diff != "" {
+		t.Fatalf("Unexpected handshake: %s", diff)
+	}
+	if GRPCAccepted() {
+		t.Fatalf("the handshake should take precedence over %s", ProtocolsKey)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	set := NewSet()
+	set.EnableGRPC()
+	set.EnableUiBuffering(packrpc.DefaultUiBufferConfig)
+
+	core := &Handshake{
+		Version:      2,
+		Protocols:    []string{ProtocolNetRPC, ProtocolGRPC},
+		Encodings:    []string{EncodingMsgpack, "future"},
+		Capabilities: []string{CapabilityUiBuffering, "future"},
+		MaxProcs:     2,
+	}
+	expected := &Handshake{
+		Version:      HandshakeVersion,
+		Protocols:    []string{ProtocolGRPC, ProtocolNetRPC},
+		Encodings:    []string{EncodingMsgpack},
+		Capabilities: []string{CapabilityUiBuffering},
+		MaxProcs:     2,
+	}
+	negotiated := Negotiate(core, set.handshake("builder"))
+	if diff := cmp.Diff(expected, negotiated); diff != "" {
+		t.Fatalf("Unexpected handshake: %s", diff)
+	}
+	if !negotiated.Has(CapabilityUiBuffering) || negotiated.Has(CapabilityArtifactV2) {
+		t.Fatalf("bad capabilities: %v", negotiated.Capabilities)
+	}
+
+	if Negotiate(core, set.handshake("function")).Speaks(ProtocolGRPC) {
+		t.Fatal("functions should only be served over net/rpc")
+	}
+}
+
+func TestSet_functionsCapability(t *testing.T) {
+	set := NewSet()
+	if v := set.description().APIVersion; v != "x5.0" {
+		t.Fatalf("a set without functions should speak the API version of every Packer, got %s", v)
+	}
+	if set.handshake("builder").Has(CapabilityFunctions) {
+		t.Fatal("a set without functions should not advertise them")
+	}
+
+	set.RegisterFunction("example", new(MockFunction))
+	if v := set.description().APIVersion; v != "x5.0" {
+		t.Fatalf("functions should not change the API version, got %s", v)
+	}
+	core := &Handshake{Version: HandshakeVersion, Protocols: []string{ProtocolNetRPC}, Capabilities: []string{CapabilityFunctions}}
+	if !Negotiate(core, set.handshake("function")).Has(CapabilityFunctions) {
+		t.Fatal("functions should be advertised")
+	}
+}
+
+func TestHandshake_encode(t *testing.T) {
+	h := &Handshake{Version: 1, Protocols: []string{ProtocolNetRPC}, Encodings: []string{EncodingMsgpack}}
+	b, err := base64.RawURLEncoding.DecodeString(h.encode())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	decoded := new(Handshake)
+	if err := json.Unmarshal(b, decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff := cmp.Diff(h, decoded); diff != "" {
+		t.Fatalf("Unexpected handshake: %s", diff)
+	}
+}
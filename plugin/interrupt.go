@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// InterruptGracePeriod is how long a plugin served by Packer waits after an
+// interrupt before cancelling its builds by itself. Packer receives the
+// interrupts of the terminal too, and cancels the builds through RPC, so the
+// plugin only cancels them when Packer doesn't.
+var InterruptGracePeriod = 10 * time.Second
+
+// InterruptExitCode is the status a plugin not served by Packer exits with
+// when interrupted twice.
+const InterruptExitCode = 130
+
+var interrupts struct {
+	once    sync.Once
+	ctx     context.Context
+	handler *interruptHandler
+}
+
+// InterruptContext returns a context cancelled when the plugin is
+// interrupted with SIGINT or SIGTERM, for the main function of a plugin to
+// pass to what it runs. Plugins served by Set, or Server, have the contexts
+// of their builds cancelled the same way.
+//
+// A plugin run by Packer is cancelled InterruptGracePeriod after the first
+// interrupt, if Packer didn't cancel it first, and right away otherwise. The
+// interrupts that follow are ignored by a plugin run by Packer, which waits
+// for the builds to clean up through RPC, and make any other plugin exit with
+// InterruptExitCode. Every interrupt is counted in Interrupts.
+func InterruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(interrupted(), cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// interrupted returns the context cancelled by interrupts, handling them from
+// the first call on.
+func interrupted() context.Context {
+	interrupts.once.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		interrupts.ctx = ctx
+		interrupts.handler = &interruptHandler{
+			count:          &Interrupts,
+			cancel:         cancel,
+			servedByPacker: os.Getenv(MagicCookieKey) == MagicCookieValue,
+			gracePeriod:    InterruptGracePeriod,
+			exit:           os.Exit,
+		}
+
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			for range ch {
+				interrupts.handler.interrupt()
+			}
+		}()
+	})
+	return interrupts.ctx
+}
+
+type interruptHandler struct {
+	count          *int32
+	cancel         context.CancelFunc
+	servedByPacker bool
+	gracePeriod    time.Duration
+	exit           func(int)
+}
+
+func (h *interruptHandler) interrupt() {
+	count := atomic.AddInt32(h.count, 1)
+	switch {
+	case count > 1 && h.servedByPacker:
+		// Exiting would leave the resources of the builds behind while
+		// Packer waits for their cleanup.
+		log.Printf("[INFO] Received interrupt signal (count: %d). Leaving the cleanup to Packer.", count)
+	case count > 1:
+		log.Printf("[ERR] Received interrupt signal (count: %d). Exiting.", count)
+		h.exit(InterruptExitCode)
+	case h.servedByPacker:
+		log.Printf("[INFO] Received interrupt signal. Cancelling in %s unless Packer does first.", h.gracePeriod)
+		time.AfterFunc(h.gracePeriod, h.cancel)
+	default:
+		log.Printf("[INFO] Received interrupt signal. Cancelling.")
+		h.cancel()
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func testInterruptHandler(servedByPacker bool) (*interruptHandler, context.Context, *int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	exitCode := -1
+	h := &interruptHandler{
+		count:          new(int32),
+		cancel:         cancel,
+		servedByPacker: servedByPacker,
+		gracePeriod:    10 * time.Millisecond,
+		exit:           func(code int) { exitCode = code },
+	}
+	return h, ctx, &exitCode
+}
+
+func TestInterruptHandler(t *testing.T) {
+	h, ctx, exitCode := testInterruptHandler(false)
+	h.interrupt()
+	if ctx.Err() == nil {
+		t.Fatal("the first interrupt should cancel the context")
+	}
+	if *exitCode != -1 {
+		t.Fatalf("the first interrupt should not exit: %d", *exitCode)
+	}
+	h.interrupt()
+	if *exitCode != InterruptExitCode {
+		t.Fatalf("the second interrupt should exit, got %d", *exitCode)
+	}
+}
+
+func TestInterruptHandler_servedByPacker(t *testing.T) {
+	h, ctx, exitCode := testInterruptHandler(true)
+	h.interrupt()
+	if ctx.Err() != nil {
+		t.Fatal("Packer should be given time to cancel")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the context should be cancelled after the grace period")
+	}
+	h.interrupt()
+	if *exitCode != -1 {
+		t.Fatalf("a plugin served by Packer should not exit while Packer cleans up, got %d", *exitCode)
+	}
+}
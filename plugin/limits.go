@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// defaultRSSCheckInterval is how often the resident memory of a plugin is
+// checked against ResourceLimits.MaxRSS by default.
+const defaultRSSCheckInterval = 5 * time.Second
+
+// IOPriority is the I/O scheduling priority of a plugin.
+type IOPriority int
+
+const (
+	// IOPriorityDefault leaves the I/O priority of the plugin unchanged.
+	IOPriorityDefault IOPriority = iota
+	// IOPriorityLow is the lowest priority of the best-effort class.
+	IOPriorityLow
+	// IOPriorityIdle only lets the plugin do I/O when no other process
+	// does.
+	IOPriorityIdle
+)
+
+// ResourceLimits keep a misbehaving plugin from taking down the host of the
+// build. The zero value sets no limit.
+type ResourceLimits struct {
+	// MaxRSS is the resident memory, in bytes, above which the plugin exits.
+	// It is also the soft memory limit of the Go runtime, for the garbage
+	// collector to try to stay below it.
+	MaxRSS uint64
+	// RSSCheckInterval is how often the resident memory is checked, every
+	// five seconds by default.
+	RSSCheckInterval time.Duration
+
+	// Nice is added to the niceness of the plugin, for it to get less CPU
+	// time than the other processes of the host. On Windows, a positive
+	// value sets the priority class of the plugin to below normal, and
+	// idle from 15.
+	Nice int
+	// IOPriority is the I/O priority of the plugin. It is only supported
+	// on Linux.
+	IOPriority IOPriority
+
+	// Sandbox is called once the plugin is connected to Packer, and before
+	// it serves any call, to install a seccomp profile on Linux for example.
+	// The plugin stops when it returns an error.
+	Sandbox func() error
+}
+
+// SetResourceLimits makes the plugin limit itself to limits once started.
+func (i *Set) SetResourceLimits(limits ResourceLimits) {
+	i.limits = &limits
+}
+
+// applyLimits applies the limits of the set, if any, and returns the function
+// stopping the memory watchdog.
+func (i *Set) applyLimits() func() {
+	if i.limits == nil {
+		return func() {}
+	}
+	l := i.limits
+	if l.Nice != 0 {
+		if err := setNice(l.Nice); err != nil {
+			log.Printf("[WARN] Could not change the niceness of the plugin: %s", err)
+		}
+	}
+	if l.IOPriority != IOPriorityDefault {
+		if err := setIOPriority(l.IOPriority); err != nil {
+			log.Printf("[WARN] Could not change the I/O priority of the plugin: %s", err)
+		}
+	}
+	if l.MaxRSS == 0 {
+		return func() {}
+	}
+	debug.SetMemoryLimit(int64(l.MaxRSS))
+	interval := l.RSSCheckInterval
+	if interval <= 0 {
+		interval = defaultRSSCheckInterval
+	}
+	return watchRSS(l.MaxRSS, interval, func(rss uint64) {
+		log.Printf("[ERROR] The plugin uses %d bytes of memory, more than its limit of %d, exiting", rss, l.MaxRSS)
+		os.Exit(1)
+	})
+}
+
+// sandbox calls the Sandbox hook of the limits of the set, if any.
+func (i *Set) sandbox() error {
+	if i.limits == nil || i.limits.Sandbox == nil {
+		return nil
+	}
+	if err := i.limits.Sandbox(); err != nil {
+		return fmt.Errorf("sandboxing the plugin: %w", err)
+	}
+	return nil
+}
+
+// watchRSS calls exceeded when the resident memory goes above max, checking it
+// every interval, until the returned function is called.
+func watchRSS(max uint64, interval time.Duration, exceeded func(rss uint64)) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			rss, err := residentMemory()
+			if err != nil {
+				log.Printf("[WARN] Stopping the memory watchdog: %s", err)
+				return
+			}
+			if rss <= max {
+				continue
+			}
+			// Give the memory the runtime holds on to back before giving
+			// up.
+			debug.FreeOSMemory()
+			if rss, err = residentMemory(); err == nil && rss > max {
+				exceeded(rss)
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// goMemory returns the memory mapped by the Go runtime, which is what the
+// resident memory is approximated with where it can't be read.
+func goMemory() (uint64, error) {
+	sample := []metrics.Sample{{Name: "/memory/classes/total:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0, fmt.Errorf("the memory of the runtime is not available")
+	}
+	return sample[0].Value.Uint64(), nil
+}
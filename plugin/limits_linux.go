@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// residentMemory returns the resident memory of the process, from
+// /proc/self/statm.
+func residentMemory() (uint64, error) {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected /proc/self/statm: %q", data)
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
+
+// threads returns the IDs of the threads of the process. On Linux, the
+// niceness and I/O priority are per thread, and new threads inherit the ones
+// of the thread creating them.
+func threads() ([]int, error) {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return nil, err
+	}
+	var tids []int
+	for _, entry := range entries {
+		if tid, err := strconv.Atoi(entry.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+func setNice(nice int) error {
+	tids, err := threads()
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		current, err := unix.Getpriority(unix.PRIO_PROCESS, tid)
+		if err != nil {
+			continue
+		}
+		// The raw syscall returns 20 - nice.
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, 20-current+nice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	ioprioWhoProcess    = 1
+	ioprioClassBE       = 2
+	ioprioClassIdle     = 3
+	ioprioClassShift    = 13
+	ioprioLowestBELevel = 7
+)
+
+func setIOPriority(priority IOPriority) error {
+	ioprio := ioprioClassBE<<ioprioClassShift | ioprioLowestBELevel
+	if priority == IOPriorityIdle {
+		ioprio = ioprioClassIdle << ioprioClassShift
+	}
+	tids, err := threads()
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResidentMemory(t *testing.T) {
+	rss, err := residentMemory()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if rss == 0 {
+		t.Fatalf("the resident memory should not be 0")
+	}
+}
+
+func TestWatchRSS(t *testing.T) {
+	exceeded := make(chan uint64, 1)
+	stop := watchRSS(1, time.Millisecond, func(rss uint64) { exceeded <- rss })
+	defer stop()
+	select {
+	case rss := <-exceeded:
+		if rss <= 1 {
+			t.Fatalf("bad rss: %d", rss)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the limit should be exceeded")
+	}
+
+	stop = watchRSS(1<<62, time.Millisecond, func(uint64) { t.Errorf("the limit should not be exceeded") })
+	time.Sleep(20 * time.Millisecond)
+	stop()
+}
+
+func TestSet_sandbox(t *testing.T) {
+	set := NewSet()
+	if err := set.sandbox(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	set.SetResourceLimits(ResourceLimits{Sandbox: func() error { return errors.New("no seccomp") }})
+	if err := set.sandbox(); err == nil {
+		t.Fatalf("expected an error")
+	}
+	// No limit to apply.
+	set.applyLimits()()
+}
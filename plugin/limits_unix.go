@@ -0,0 +1,30 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows && !linux
+
+package plugin
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// residentMemory approximates the resident memory of the process with the
+// memory of the Go runtime.
+func residentMemory() (uint64, error) {
+	return goMemory()
+}
+
+func setNice(nice int) error {
+	current, err := unix.Getpriority(unix.PRIO_PROCESS, 0)
+	if err != nil {
+		return err
+	}
+	return unix.Setpriority(unix.PRIO_PROCESS, 0, current+nice)
+}
+
+func setIOPriority(IOPriority) error {
+	return fmt.Errorf("I/O priorities are only supported on Linux")
+}
@@ -0,0 +1,33 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package plugin
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// residentMemory approximates the resident memory of the process with the
+// memory of the Go runtime.
+func residentMemory() (uint64, error) {
+	return goMemory()
+}
+
+func setNice(nice int) error {
+	if nice <= 0 {
+		return nil
+	}
+	class := uint32(windows.BELOW_NORMAL_PRIORITY_CLASS)
+	if nice >= 15 {
+		class = windows.IDLE_PRIORITY_CLASS
+	}
+	return windows.SetPriorityClass(windows.CurrentProcess(), class)
+}
+
+func setIOPriority(IOPriority) error {
+	return fmt.Errorf("I/O priorities are only supported on Linux")
+}
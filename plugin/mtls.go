@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/fips"
+)
+
+// ClientCertKey is the environment variable in which Packer passes the PEM
+// encoded certificate it connects to the plugin with. When it is set, the
+// plugin only accepts TLS connections authenticated with this certificate,
+// and outputs its own certificate along with its address, for Packer to
+// authenticate the plugin in turn. Both certificates are ephemeral, and
+// self-signed, like with the AutoMTLS of go-plugin.
+const ClientCertKey = "PACKER_PLUGIN_CLIENT_CERT"
+
+// GenerateCertificate generates an ephemeral self-signed certificate, and
+// its key, PEM encoded, to authenticate one end of a plugin connection.
+func GenerateCertificate() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating key: %s", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating serial number: %s", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost", Organization: []string{"Packer"}},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		// Be lenient with clocks slightly out of sync.
+		NotBefore: now.Add(-30 * time.Second),
+		// Plugins don't outlive the builds they serve.
+		NotAfter: now.Add(30 * 24 * time.Hour),
+
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error encoding key: %s", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// ClientTLSConfig returns the configuration Packer connects to a plugin with:
+// it authenticates with its certificate and key, and only trusts serverCert,
+// as output by the plugin along with its address.
+func ClientTLSConfig(certPEM, keyPEM []byte, serverCert string) (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := certPool(serverCert)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   "localhost",
+		MinVersion:   tls.VersionTLS12,
+	}
+	fips.ApplyTLS(c)
+	return c, nil
+}
+
+// serverTLS wraps l to only accept the connections authenticated with the
+// certificate in ClientCertKey. It returns the certificate of the plugin, as
+// output with its address: unpadded base64 DER, which can't contain the `|`
+// separating the fields of the address. l is returned as is when Packer
+// didn't pass a certificate.
+func serverTLS(l net.Listener) (net.Listener, string, error) {
+	clientCert := os.Getenv(ClientCertKey)
+	if clientCert == "" {
+		return l, "", nil
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM([]byte(clientCert)) {
+		return nil, "", fmt.Errorf("invalid certificate in %s", ClientCertKey)
+	}
+
+	certPEM, keyPEM, err := GenerateCertificate()
+	if err != nil {
+		return nil, "", err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, "", err
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+	fips.ApplyTLS(c)
+	return tls.NewListener(l, c), base64.RawStdEncoding.EncodeToString(cert.Certificate[0]), nil
+}
+
+// certPool returns a pool of the certificate output by a plugin.
+func certPool(serverCert string) (*x509.CertPool, error) {
+	der, err := base64.RawStdEncoding.DecodeString(serverCert)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin certificate: %s", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return pool, nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+)
+
+// testTLSServer serves a listener wrapped by serverTLS, echoing what the
+// accepted connections send.
+func testTLSServer(t *testing.T) (net.Listener, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	l, serverCert, err := serverTLS(l)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l, serverCert
+}
+
+func TestServerTLS(t *testing.T) {
+	certPEM, keyPEM, err := GenerateCertificate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Setenv(ClientCertKey, string(certPEM))
+	l, serverCert := testTLSServer(t)
+	if serverCert == "" {
+		t.Fatal("the plugin should output its certificate")
+	}
+
+	config, err := ClientTLSConfig(certPEM, keyPEM, serverCert)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn, err := tls.Dial("tcp", l.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("bad echo %q: %v", buf, err)
+	}
+
+	// Another client is not accepted.
+	otherCert, otherKey, _ := GenerateCertificate()
+	config, _ = ClientTLSConfig(otherCert, otherKey, serverCert)
+	conn, err = tls.Dial("tcp", l.Addr().String(), config)
+	if err == nil {
+		_, err = conn.Read(buf)
+		conn.Close()
+	}
+	if err == nil {
+		t.Fatal("a client with another certificate should be rejected")
+	}
+}
+
+func TestServerTLS_disabled(t *testing.T) {
+	t.Setenv(ClientCertKey, "")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+	wrapped, serverCert, err := serverTLS(l)
+	if err != nil || wrapped != l || serverCert != "" {
+		t.Fatalf("the listener should be left as is: %v", err)
+	}
+
+	t.Setenv(ClientCertKey, "not a certificate")
+	if _, _, err := serverTLS(l); err == nil {
+		t.Fatal("expected an error with an invalid certificate")
+	}
+}
@@ -17,11 +17,9 @@ import (
 	"math/rand"
 	"net"
 	"os"
-	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strconv"
-	"sync/atomic"
-	"syscall"
 	"time"
 
 	packrpc "github.com/hashicorp/packer-plugin-sdk/rpc"
@@ -29,8 +27,9 @@ import (
 )
 
 // This is a count of the number of interrupts the process has received.
-// This is updated with sync/atomic whenever a SIGINT is received and can
-// be checked by the plugin safely to take action.
+// This is updated with sync/atomic whenever a SIGINT or a SIGTERM is
+// received, once the interrupts are handled, and can be checked by the
+// plugin safely to take action.
 var Interrupts int32 = 0
 
 const MagicCookieKey = "PACKER_PLUGIN_MAGIC_COOKIE"
@@ -57,35 +56,120 @@ const (
 	APIVersionMajor, APIVersionMinor = "5", "0"
 )
 
+// ProtocolsKey is the environment variable in which Packer lists, separated
+// by commas, the protocols it can speak with plugins. Versions of Packer that
+// don't set it only speak ProtocolNetRPC.
+const ProtocolsKey = "PACKER_PLUGIN_PROTOCOLS"
+
+const (
+	// ProtocolNetRPC is the net/rpc protocol of the rpc package, spoken by
+	// every version of Packer.
+	ProtocolNetRPC = "netrpc"
+	// ProtocolGRPC is the gRPC protocol described in rpc/pluginproto.
+	ProtocolGRPC = "grpc"
+)
+
 var ErrManuallyStartedPlugin = errors.New(
 	"Please do not execute plugins directly. Packer will execute these for you.")
 
 // Server waits for a connection to this plugin and returns a Packer
 // RPC server that you can use to register components and serve them.
 func Server() (*packrpc.PluginServer, error) {
+	return netRPCServer(nil)
+}
+
+func netRPCServer(handshake *Handshake) (*packrpc.PluginServer, error) {
+	conn, err := serverConn(ProtocolNetRPC, handshake)
+	if err != nil {
+		return nil, err
+	}
+	server, err := packrpc.NewServer(conn)
+	if err != nil {
+		return nil, err
+	}
+	server.SetContext(interrupted())
+	return server, nil
+}
+
+// GRPCServer is like Server but returns a gRPC server. It must only be used
+// when Packer accepts ProtocolGRPC, as told by GRPCAccepted.
+func GRPCServer() (*packrpc.GRPCServer, error) {
+	return grpcServer(nil)
+}
+
+func grpcServer(handshake *Handshake) (*packrpc.GRPCServer, error) {
+	conn, err := serverConn(ProtocolGRPC, handshake)
+	if err != nil {
+		return nil, err
+	}
+	server, err := packrpc.NewGRPCServer(conn)
+	if err != nil {
+		return nil, err
+	}
+	server.SetContext(interrupted())
+	return server, nil
+}
+
+// GRPCAccepted tells whether Packer speaks ProtocolGRPC, as told by its
+// handshake.
+func GRPCAccepted() bool {
+	return CoreHandshake().Speaks(ProtocolGRPC)
+}
+
+// serverConn outputs the address of the plugin to Packer and waits for it to
+// connect. The protocol is added to the address when it isn't the original
+// net/rpc one, which is all older versions of Packer can parse. Packer
+// versions sending a handshake get the protocol and the negotiated handshake,
+// when there is one, in any case, followed by the certificate of the plugin
+// when they passed theirs in ClientCertKey.
+func serverConn(protocol string, handshake *Handshake) (net.Conn, error) {
 	if os.Getenv(MagicCookieKey) != MagicCookieValue {
 		return nil, ErrManuallyStartedPlugin
 	}
 
-	// If there is no explicit number of Go threads to use, then set it
+	// If there is no explicit number of Go threads to use, then set it,
+	// within the CPUs Packer lets the plugin use.
 	if os.Getenv("GOMAXPROCS") == "" {
-		runtime.GOMAXPROCS(runtime.NumCPU())
+		procs := runtime.NumCPU()
+		if handshake != nil && handshake.MaxProcs > 0 && handshake.MaxProcs < procs {
+			procs = handshake.MaxProcs
+		}
+		runtime.GOMAXPROCS(procs)
 	}
 
-	listener, err := serverListener()
+	listener, err := serverListener(handshake)
 	if err != nil {
 		return nil, err
 	}
 	defer listener.Close()
+	listener, serverCert, err := serverTLS(listener)
+	if err != nil {
+		return nil, err
+	}
 
 	// Output the address to stdout
 	log.Printf("Plugin address: %s %s\n",
 		listener.Addr().Network(), listener.Addr().String())
-	fmt.Printf("%s|%s|%s|%s\n",
+	address := fmt.Sprintf("%s|%s|%s|%s",
 		APIVersionMajor,
 		APIVersionMinor,
 		listener.Addr().Network(),
 		listener.Addr().String())
+	switch {
+	case serverCert != "":
+		// Only Packer versions passing their certificate expect ours, the
+		// handshake is empty when they did not send theirs.
+		encoded := ""
+		if handshake != nil && handshake.Version >= 1 {
+			encoded = handshake.encode()
+		}
+		address += "|" + protocol + "|" + encoded + "|" + serverCert
+	case handshake != nil && handshake.Version >= 1:
+		address += "|" + protocol + "|" + handshake.encode()
+	case protocol != ProtocolNetRPC:
+		address += "|" + protocol
+	}
+	fmt.Println(address)
 	os.Stdout.Sync()
 
 	// Accept a connection
@@ -96,29 +180,34 @@ func Server() (*packrpc.PluginServer, error) {
 		return nil, err
 	}
 
-	// Eat the interrupts
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		var count int32 = 0
-		for {
-			<-ch
-			newCount := atomic.AddInt32(&count, 1)
-			log.Printf("Received interrupt signal (count: %d). Ignoring.", newCount)
-		}
-	}()
+	// Packer forwards interrupts through RPC, see InterruptContext.
+	interrupted()
 
 	// Serve a single connection
-	log.Println("Serving a plugin connection...")
-	return packrpc.NewServer(conn)
+	log.Printf("Serving a plugin connection over %s...", protocol)
+	return conn, nil
 }
 
-func serverListener() (net.Listener, error) {
-	if runtime.GOOS == "windows" {
+// serverListener listens on a Unix domain socket, and falls back to a TCP
+// port in the range set by Packer when sockets are not available, like on
+// older versions of Windows or when the temporary directory has a path too
+// long for a socket. Packer versions predating handshakes only connect to
+// Windows plugins over TCP.
+func serverListener(handshake *Handshake) (net.Listener, error) {
+	if runtime.GOOS == "windows" && (handshake == nil || handshake.Version < 1) {
 		return serverListener_tcp()
 	}
 
-	return serverListener_unix()
+	listener, err := serverListener_unix()
+	if err == nil {
+		return listener, nil
+	}
+	log.Printf("[WARN] Could not listen on a Unix domain socket, falling back to TCP: %s", err)
+	listener, tcpErr := serverListener_tcp()
+	if tcpErr != nil {
+		return nil, fmt.Errorf("%s; %s", err, tcpErr)
+	}
+	return listener, nil
 }
 
 func serverListener_tcp() (net.Listener, error) {
@@ -146,11 +235,12 @@ func serverListener_tcp() (net.Listener, error) {
 }
 
 func serverListener_unix() (net.Listener, error) {
-	tf, err := tmp.File("packer-plugin")
+	tf, err := tmp.File(fmt.Sprintf("%s%d-", socketPrefix, os.Getpid()))
 	if err != nil {
 		return nil, err
 	}
 	path := tf.Name()
+	removeStaleSockets(filepath.Dir(path))
 
 	// Close the file and remove it because it has to not exist for
 	// the domain socket.
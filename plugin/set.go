@@ -10,8 +10,14 @@ import (
 	"log"
 	"os"
 	"sort"
+	"sync"
 
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/packer-plugin-sdk/featureflag"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	packrpc "github.com/hashicorp/packer-plugin-sdk/rpc"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	pluginVersion "github.com/hashicorp/packer-plugin-sdk/version"
 )
 
@@ -37,6 +43,15 @@ type Set struct {
 	PostProcessors map[string]packersdk.PostProcessor
 	Provisioners   map[string]packersdk.Provisioner
 	Datasources    map[string]packersdk.Datasource
+	Functions      map[string]packersdk.Function
+
+	grpc     bool
+	uiBuffer *packrpc.UiBufferConfig
+	tracing  TracerProvider
+	limits   *ResourceLimits
+
+	loggerOnce sync.Once
+	logger     hclog.InterceptLogger
 }
 
 // SetDescription describes a Set.
@@ -48,6 +63,16 @@ type SetDescription struct {
 	PostProcessors []string `json:"post_processors"`
 	Provisioners   []string `json:"provisioners"`
 	Datasources    []string `json:"datasources"`
+	Functions      []string `json:"functions"`
+	// Protocols are the protocols the components can be served with, unset
+	// when only ProtocolNetRPC is supported.
+	Protocols []string `json:"protocols,omitempty"`
+	// Schemas are the JSON Schemas of the configurations of the components,
+	// by kind, like "builder", and name. They are only set by
+	// "describe -schema".
+	Schemas map[string]map[string]interface{} `json:"schemas,omitempty"`
+	// Features are the feature flags registered by the plugin.
+	Features []featureflag.Description `json:"features,omitempty"`
 }
 
 ////
@@ -62,6 +87,7 @@ func NewSet() *Set {
 		PostProcessors: map[string]packersdk.PostProcessor{},
 		Provisioners:   map[string]packersdk.Provisioner{},
 		Datasources:    map[string]packersdk.Datasource{},
+		Functions:      map[string]packersdk.Function{},
 	}
 }
 
@@ -69,6 +95,54 @@ func (i *Set) SetVersion(version *pluginVersion.PluginVersion) {
 	i.version = version.String()
 }
 
+// EnableGRPC makes the builders, provisioners, post-processors and
+// datasources of the set served over gRPC when Packer supports it. Functions
+// are always served over net/rpc.
+func (i *Set) EnableGRPC() {
+	i.grpc = true
+}
+
+// EnableUiBuffering makes the components of the set send what they write to
+// their Ui in batches, which is faster for components writing a lot of
+// output. Messages are still received in order, and the calls writing them
+// block when Packer falls behind. It only applies to components served over
+// net/rpc.
+func (i *Set) EnableUiBuffering(config packrpc.UiBufferConfig) {
+	i.uiBuffer = &config
+}
+
+// SetLogger sets the logger of the set, see Logger.
+func (i *Set) SetLogger(logger hclog.InterceptLogger) {
+	i.loggerOnce.Do(func() {})
+	i.logger = logger
+}
+
+// Logger returns the logger of the set, writing to stderr at every level by
+// default. Once a component is started, it is the default hclog logger, and
+// what is written with the log package goes through it too, at the level of
+// its "[DEBUG]"-like prefix. Packer asks plugins to send it the records of at
+// least the level set with PACKER_LOG, or PACKER_LOG_<PLUGIN>, over RPC, named
+// sub-loggers included, in which case they are no longer written to stderr.
+func (i *Set) Logger() hclog.InterceptLogger {
+	i.loggerOnce.Do(func() {
+		i.logger = hclog.NewInterceptLogger(&hclog.LoggerOptions{
+			Output: os.Stderr,
+			Level:  hclog.Trace,
+		})
+	})
+	return i.logger
+}
+
+// startLogging routes the logs of the component through the logger of the
+// set.
+func (i *Set) startLogging() hclog.InterceptLogger {
+	logger := i.Logger()
+	hclog.SetDefault(logger)
+	log.SetOutput(logger.StandardWriterIntercept(&hclog.StandardLoggerOptions{InferLevels: true}))
+	log.SetFlags(0)
+	return logger
+}
+
 func (i *Set) RegisterBuilder(name string, builder packersdk.Builder) {
 	if _, found := i.Builders[name]; found {
 		panic(fmt.Errorf("registering duplicate %s builder", name))
@@ -97,10 +171,29 @@ func (i *Set) RegisterDatasource(name string, datasource packersdk.Datasource) {
 	i.Datasources[name] = datasource
 }
 
+func (i *Set) RegisterFunction(name string, function packersdk.Function) {
+	if _, found := i.Functions[name]; found {
+		panic(fmt.Errorf("registering duplicate %s function", name))
+	}
+	i.Functions[name] = function
+}
+
+// RegisterTemplateFunction registers function like RegisterFunction, for
+// Packer to call it from HCL2 templates, and also adds it to the
+// interpolations of the JSON configurations the plugin renders, see
+// packersdk.InterpolateFunc.
+func (i *Set) RegisterTemplateFunction(name string, function packersdk.Function) {
+	i.RegisterFunction(name, function)
+	interpolate.RegisterFunc(name, packersdk.InterpolateFunc(function))
+}
+
 // Run takes the os Args and runs a packer plugin command from it.
-//   - "describe" command makes the plugin set describe itself.
+//   - "describe" command makes the plugin set describe itself, and
+//     "describe -schema" also outputs the JSON Schema of the configuration
+//     of every component
 //   - "start builder builder-name" starts the builder "builder-name"
 //   - "start post-processor example" starts the post-processor "example"
+//   - "start function example" starts the function "example"
 func (i *Set) Run() error {
 	args := os.Args[1:]
 	return i.RunCommand(args...)
@@ -113,7 +206,13 @@ func (i *Set) RunCommand(args ...string) error {
 
 	switch args[0] {
 	case "describe":
-		return i.jsonDescribe(os.Stdout)
+		switch {
+		case len(args) == 1:
+			return i.jsonDescribe(os.Stdout)
+		case len(args) == 2 && (args[1] == "-schema" || args[1] == "--schema"):
+			return i.jsonDescribeSchemas(os.Stdout)
+		}
+		return fmt.Errorf("describe takes no argument but -schema. Found: %v", args[1:])
 	case "start":
 		args = args[1:]
 		if len(args) != 2 {
@@ -126,10 +225,33 @@ func (i *Set) RunCommand(args ...string) error {
 }
 
 func (i *Set) start(kind, name string) error {
-	server, err := Server()
+	stopTracing := i.startTracing()
+	defer stopTracing()
+	logger := i.startLogging()
+	stopLimits := i.applyLimits()
+	defer stopLimits()
+
+	core := CoreHandshake()
+	negotiated := Negotiate(core, i.handshake(kind))
+	if negotiated.Speaks(ProtocolGRPC) {
+		return i.startGRPC(kind, name, negotiated)
+	}
+
+	server, err := netRPCServer(negotiated)
 	if err != nil {
 		return err
 	}
+	if err := i.sandbox(); err != nil {
+		return err
+	}
+	// Packer versions predating handshakes may still batch Ui calls, which
+	// the buffer finds out by itself.
+	if i.uiBuffer != nil && (core.Version == 0 || negotiated.Has(CapabilityUiBuffering)) {
+		server.BufferUi(*i.uiBuffer)
+	}
+	if err := server.RegisterLogging(logger); err != nil {
+		return err
+	}
 
 	log.Printf("[TRACE] starting %s %s", kind, name)
 
@@ -142,6 +264,8 @@ func (i *Set) start(kind, name string) error {
 		err = server.RegisterProvisioner(i.Provisioners[name])
 	case "datasource":
 		err = server.RegisterDatasource(i.Datasources[name])
+	case "function":
+		err = server.RegisterFunction(i.Functions[name])
 	default:
 		err = fmt.Errorf("Unknown plugin type: %s", kind)
 	}
@@ -149,9 +273,53 @@ func (i *Set) start(kind, name string) error {
 		return err
 	}
 	server.Serve()
+
+	i.stopped(kind, name)
 	return nil
 }
 
+func (i *Set) startGRPC(kind, name string, negotiated *Handshake) error {
+	server, err := grpcServer(negotiated)
+	if err != nil {
+		return err
+	}
+	if err := i.sandbox(); err != nil {
+		server.Close()
+		return err
+	}
+
+	log.Printf("[TRACE] starting %s %s over gRPC", kind, name)
+
+	switch kind {
+	case "builder":
+		server.RegisterBuilder(i.Builders[name])
+	case "post-processor":
+		server.RegisterPostProcessor(i.PostProcessors[name])
+	case "provisioner":
+		server.RegisterProvisioner(i.Provisioners[name])
+	case "datasource":
+		server.RegisterDatasource(i.Datasources[name])
+	default:
+		server.Close()
+		return fmt.Errorf("Unknown plugin type: %s", kind)
+	}
+	server.Serve()
+
+	i.stopped(kind, name)
+	return nil
+}
+
+// stopped is called once Packer disconnected from the component.
+func (i *Set) stopped(kind, name string) {
+	if kind == "datasource" {
+		// Packer is gone, so make sure ephemeral resources don't outlive
+		// it, even if it didn't get to release them.
+		if err := packersdk.ReleaseDatasource(i.Datasources[name]); err != nil {
+			log.Printf("[ERR] releasing datasource %s: %s", name, err)
+		}
+	}
+}
+
 ////
 // Describe
 ////
@@ -165,13 +333,79 @@ func (i *Set) description() SetDescription {
 		PostProcessors: i.postProcessorsDescription(),
 		Provisioners:   i.provisionersDescription(),
 		Datasources:    i.datasourceDescription(),
+		Functions:      i.functionsDescription(),
+		Protocols:      i.protocolsDescription(),
+		Features:       featureflag.Describe(),
+	}
+}
+
+// handshake returns what the set supports to serve a component of kind.
+func (i *Set) handshake(kind string) *Handshake {
+	h := &Handshake{
+		Version:      HandshakeVersion,
+		Protocols:    []string{ProtocolNetRPC},
+		Encodings:    []string{EncodingMsgpack},
+		Capabilities: []string{CapabilityArtifactV2, CapabilityLogForwarding},
+	}
+	// gRPC is preferred, and functions are always served over net/rpc.
+	if i.grpc && kind != "function" {
+		h.Protocols = []string{ProtocolGRPC, ProtocolNetRPC}
+	}
+	if i.uiBuffer != nil {
+		h.Capabilities = append(h.Capabilities, CapabilityUiBuffering)
+	}
+	if len(i.Functions) > 0 {
+		h.Capabilities = append(h.Capabilities, CapabilityFunctions)
+	}
+	return h
+}
+
+func (i *Set) protocolsDescription() []string {
+	if !i.grpc {
+		return nil
 	}
+	return []string{ProtocolNetRPC, ProtocolGRPC}
 }
 
 func (i *Set) jsonDescribe(out io.Writer) error {
 	return json.NewEncoder(out).Encode(i.description())
 }
 
+func (i *Set) jsonDescribeSchemas(out io.Writer) error {
+	desc := i.description()
+	desc.Schemas = i.schemasDescription()
+	return json.NewEncoder(out).Encode(desc)
+}
+
+// schemasDescription returns the JSON Schemas of the configurations of the
+// components of the set, by kind and name. Functions have no configuration.
+func (i *Set) schemasDescription() map[string]map[string]interface{} {
+	schemas := map[string]map[string]interface{}{}
+	add := func(kind, name string, component packersdk.HCL2Speccer) {
+		if schemas[kind] == nil {
+			schemas[kind] = map[string]interface{}{}
+		}
+		var config interface{}
+		if documenter, ok := component.(packersdk.ConfigDocumenter); ok {
+			config = documenter.ConfigStruct()
+		}
+		schemas[kind][name] = hcl2helper.JSONSchema(component.ConfigSpec(), config)
+	}
+	for name, builder := range i.Builders {
+		add("builder", name, builder)
+	}
+	for name, postProcessor := range i.PostProcessors {
+		add("post-processor", name, postProcessor)
+	}
+	for name, provisioner := range i.Provisioners {
+		add("provisioner", name, provisioner)
+	}
+	for name, datasource := range i.Datasources {
+		add("datasource", name, datasource)
+	}
+	return schemas
+}
+
 func (i *Set) buildersDescription() []string {
 	out := []string{}
 	for key := range i.Builders {
@@ -207,3 +441,12 @@ func (i *Set) datasourceDescription() []string {
 	sort.Strings(out)
 	return out
 }
+
+func (i *Set) functionsDescription() []string {
+	out := []string{}
+	for key := range i.Functions {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -4,11 +4,16 @@
 package plugin
 
 import (
+	"bytes"
+	"encoding/json"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	pluginVersion "github.com/hashicorp/packer-plugin-sdk/version"
+	"github.com/zclconf/go-cty/cty"
 )
 
 type MockBuilder struct {
@@ -35,6 +40,12 @@ type MockDatasource struct {
 
 var _ packersdk.Datasource = new(MockDatasource)
 
+type MockFunction struct {
+	packersdk.Function
+}
+
+var _ packersdk.Function = new(MockFunction)
+
 func TestSet(t *testing.T) {
 	set := NewSet()
 	set.RegisterBuilder("example-2", new(MockBuilder))
@@ -45,6 +56,7 @@ func TestSet(t *testing.T) {
 	set.RegisterProvisioner("example-2", new(MockProvisioner))
 	set.RegisterDatasource("example", new(MockDatasource))
 	set.RegisterDatasource("example-2", new(MockDatasource))
+	set.RegisterFunction("example", new(MockFunction))
 	set.SetVersion(pluginVersion.NewPluginVersion(
 		"1.1.1", "", ""))
 
@@ -59,6 +71,7 @@ func TestSet(t *testing.T) {
 		PostProcessors: []string{"example", "example-2"},
 		Provisioners:   []string{"example", "example-2"},
 		Datasources:    []string{"example", "example-2"},
+		Functions:      []string{"example"},
 	}, outputDesc); diff != "" {
 		t.Fatalf("Unexpected description: %s", diff)
 	}
@@ -68,3 +81,85 @@ func TestSet(t *testing.T) {
 		t.Fatalf("Unexpected error: %s", diff)
 	}
 }
+
+func TestSet_RegisterTemplateFunction(t *testing.T) {
+	double := packersdk.NewFunction(packersdk.FunctionSpec{
+		Params: []packersdk.FunctionParam{{Name: "n", Type: cty.Number}},
+		Return: cty.Number,
+	}, func(args []cty.Value) (cty.Value, error) {
+		return args[0].Multiply(cty.NumberIntVal(2)), nil
+	})
+	set := NewSet()
+	set.RegisterTemplateFunction("test_double", double)
+	defer delete(interpolate.FuncGens, "test_double")
+
+	if set.Functions["test_double"] != double {
+		t.Fatal("the function should be served to Packer")
+	}
+	result, err := interpolate.Render(`{{test_double "21"}}`, interpolate.NewContext())
+	if err != nil || result != "42" {
+		t.Fatalf("bad interpolation: %q, %v", result, err)
+	}
+	if _, err := interpolate.Render(`{{test_double "x"}}`, interpolate.NewContext()); err == nil {
+		t.Fatal("invalid arguments should fail")
+	}
+}
+
+func TestSet_EnableGRPC(t *testing.T) {
+	set := NewSet()
+	if protocols := set.description().Protocols; protocols != nil {
+		t.Fatalf("Unexpected protocols: %v", protocols)
+	}
+	set.EnableGRPC()
+	if diff := cmp.Diff([]string{ProtocolNetRPC, ProtocolGRPC}, set.description().Protocols); diff != "" {
+		t.Fatalf("Unexpected protocols: %s", diff)
+	}
+
+	t.Setenv(ProtocolsKey, "netrpc, grpc")
+	if !GRPCAccepted() {
+		t.Fatalf("gRPC should be accepted")
+	}
+	t.Setenv(ProtocolsKey, "netrpc")
+	if GRPCAccepted() {
+		t.Fatalf("gRPC should not be accepted")
+	}
+}
+
+func TestSet_describeSchemas(t *testing.T) {
+	set := NewSet()
+	set.RegisterBuilder("example", new(packersdk.MockBuilder))
+	set.RegisterDatasource("example", new(packersdk.MockDatasource))
+	set.RegisterFunction("example", new(MockFunction))
+
+	var out bytes.Buffer
+	if err := set.jsonDescribeSchemas(&out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var desc SetDescription
+	if err := json.Unmarshal(out.Bytes(), &desc); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(desc.Schemas) != 2 {
+		t.Fatalf("bad schemas: %v", desc.Schemas)
+	}
+	builder, ok := desc.Schemas["builder"]["example"].(map[string]interface{})
+	if !ok || builder["$schema"] != hcl2helper.JSONSchemaDialect {
+		t.Fatalf("bad builder schema: %#v", desc.Schemas["builder"])
+	}
+	properties := builder["properties"].(map[string]interface{})
+	if _, ok := properties["artifact_id"]; !ok {
+		t.Fatalf("bad builder properties: %v", properties)
+	}
+
+	if err := set.RunCommand("describe", "-unknown"); err == nil {
+		t.Fatal("unknown describe flags should fail")
+	}
+
+	out.Reset()
+	if err := set.jsonDescribe(&out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if bytes.Contains(out.Bytes(), []byte(`"schemas"`)) {
+		t.Fatalf("schemas should only be described when asked: %s", out.String())
+	}
+}
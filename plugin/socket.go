@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// socketPrefix begins the names of the sockets of the plugins, followed by
+// the PID of the plugin, so that the sockets left behind by plugins killed
+// before Packer connected can be told apart from the ones still in use.
+const socketPrefix = "packer-plugin-"
+
+// removeStaleSockets removes the sockets of dir left behind by plugins that
+// are not running anymore.
+func removeStaleSockets(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("[DEBUG] Could not list plugin sockets: %s", err)
+		return
+	}
+	for _, entry := range entries {
+		pid, ok := socketPID(entry.Name())
+		if !ok || pid == os.Getpid() || processExists(pid) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			log.Printf("[DEBUG] Could not remove stale plugin socket %s: %s", path, err)
+			continue
+		}
+		log.Printf("[DEBUG] Removed stale plugin socket %s", path)
+	}
+}
+
+// socketPID returns the PID of the plugin listening on the socket name.
+func socketPID(name string) (int, bool) {
+	rest, ok := strings.CutPrefix(name, socketPrefix)
+	if !ok {
+		return 0, false
+	}
+	pid, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(pid)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,57 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSocketPID(t *testing.T) {
+	cases := map[string]int{
+		"packer-plugin-1234-5678": 1234,
+		"packer-plugin-1234":      0,
+		"packer-plugin5678":       0,
+		"packer-plugin-abc-5678":  0,
+		"other-1234-5678":         0,
+	}
+	for name, expected := range cases {
+		pid, _ := socketPID(name)
+		if pid != expected {
+			t.Errorf("%s: got %d, expected %d", name, pid, expected)
+		}
+	}
+}
+
+func TestRemoveStaleSockets(t *testing.T) {
+	// A process that is not running anymore.
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dead := cmd.Process.Pid
+
+	dir := t.TempDir()
+	stale := filepath.Join(dir, fmt.Sprintf("%s%d-1", socketPrefix, dead))
+	live := filepath.Join(dir, fmt.Sprintf("%s%d-1", socketPrefix, os.Getpid()))
+	other := filepath.Join(dir, "packer-plugin123")
+	for _, path := range []string{stale, live, other} {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	removeStaleSockets(dir)
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("the stale socket should be removed: %v", err)
+	}
+	for _, path := range []string{live, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s should be kept: %s", path, err)
+		}
+	}
+}
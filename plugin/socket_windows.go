@@ -0,0 +1,21 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package plugin
+
+import (
+	"os"
+)
+
+// processExists tells whether a process with this PID runs. FindProcess
+// opens the process on Windows, which fails when it does not exist.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
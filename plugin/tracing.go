@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	packrpc "github.com/hashicorp/packer-plugin-sdk/rpc"
+)
+
+// TracesExporterKey is the OpenTelemetry environment variable naming the
+// exporter of the spans. Plugins inherit the environment of Packer, so the
+// OpenTelemetry variables configuring Packer configure its plugins too, and
+// plugins only trace their calls when it is set to something else than
+// "none".
+const TracesExporterKey = "OTEL_TRACES_EXPORTER"
+
+// tracingShutdownTimeout is how long a plugin waits for its last spans to be
+// exported before exiting.
+const tracingShutdownTimeout = 5 * time.Second
+
+// TracerProvider sets up the exporter configured by the OpenTelemetry
+// environment variables, and returns the Tracer of the RPC calls of the
+// plugin, along with the function flushing the spans and stopping the
+// exporter once the plugin is done.
+type TracerProvider func(ctx context.Context) (tracer packrpc.Tracer, shutdown func(context.Context) error, err error)
+
+// EnableTracing makes the components of the set trace the calls they serve
+// with the Tracer of provider when Packer has tracing enabled. The spans
+// are the children of the spans of Packer, which sends its trace context
+// along the calls.
+func (i *Set) EnableTracing(provider TracerProvider) {
+	i.tracing = provider
+}
+
+// startTracing sets the Tracer of the plugin up, and returns the function
+// stopping it.
+func (i *Set) startTracing() func() {
+	exporter := os.Getenv(TracesExporterKey)
+	if i.tracing == nil || exporter == "" || exporter == "none" {
+		return func() {}
+	}
+	tracer, shutdown, err := i.tracing(context.Background())
+	if err != nil {
+		log.Printf("[WARN] Tracing disabled, setting up the %s exporter failed: %s", exporter, err)
+		return func() {}
+	}
+	packrpc.SetTracer(tracer)
+	return func() {
+		packrpc.SetTracer(nil)
+		if shutdown == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Printf("[WARN] Error exporting the last spans: %s", err)
+		}
+	}
+}
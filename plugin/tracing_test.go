@@ -0,0 +1,37 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	packrpc "github.com/hashicorp/packer-plugin-sdk/rpc"
+)
+
+func TestSet_startTracing(t *testing.T) {
+	var started, stopped int
+	set := NewSet()
+	set.EnableTracing(func(context.Context) (packrpc.Tracer, func(context.Context) error, error) {
+		started++
+		return nil, func(context.Context) error {
+			stopped++
+			return nil
+		}, nil
+	})
+
+	for _, exporter := range []string{"", "none"} {
+		t.Setenv(TracesExporterKey, exporter)
+		set.startTracing()()
+	}
+	if started != 0 {
+		t.Fatalf("tracing should be disabled")
+	}
+
+	t.Setenv(TracesExporterKey, "otlp")
+	set.startTracing()()
+	if started != 1 || stopped != 1 {
+		t.Fatalf("tracing should be started and stopped, got %d starts and %d stops", started, stopped)
+	}
+}
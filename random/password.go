@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package random
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// DefaultSymbols are the symbols of passwords, leaving out the space,
+	// quotes, backslash and the characters that need escaping in most
+	// shells and configuration files.
+	DefaultSymbols = "!#%*+-=?@^_"
+	// AmbiguousCharacters are the characters easily mistaken for one
+	// another when read.
+	AmbiguousCharacters = "0O1lI|`'\""
+)
+
+// PasswordPolicy are the constraints of a password generated with Password.
+// Policies are plain values, so a builder can start from one and tighten it
+// for the requirements of its platform.
+type PasswordPolicy struct {
+	// Length is the number of random characters, not counting Prefix and
+	// Suffix.
+	Length int
+	// Charset are the characters to pick from, PossibleAlphaNum when
+	// empty.
+	Charset string
+	// Symbols are added to Charset. They default to DefaultSymbols when
+	// MinSymbols is set.
+	Symbols string
+
+	// MinLower, MinUpper, MinDigits and MinSymbols are the minimum number
+	// of lowercase letters, uppercase letters, digits and symbols.
+	MinLower   int
+	MinUpper   int
+	MinDigits  int
+	MinSymbols int
+
+	// ExcludeAmbiguous leaves out AmbiguousCharacters.
+	ExcludeAmbiguous bool
+	// Exclude are characters to leave out, like the ones a platform
+	// rejects.
+	Exclude string
+
+	// Prefix and Suffix are added as is around the random characters.
+	Prefix string
+	Suffix string
+}
+
+// Password returns a password satisfying policy, picked using the source of
+// the package, see SetSource. It fails when the policy can't be satisfied.
+func Password(policy PasswordPolicy) (string, error) {
+	symbols := policy.Symbols
+	if symbols == "" && policy.MinSymbols > 0 {
+		symbols = DefaultSymbols
+	}
+	charset := policy.Charset
+	if charset == "" {
+		charset = PossibleAlphaNum
+	}
+	exclude := policy.Exclude
+	if policy.ExcludeAmbiguous {
+		exclude += AmbiguousCharacters
+	}
+	keep := func(chars string) string {
+		return strings.Map(func(r rune) rune {
+			if strings.ContainsRune(exclude, r) {
+				return -1
+			}
+			return r
+		}, chars)
+	}
+	charset = keep(charset + symbols)
+	if charset == "" {
+		return "", fmt.Errorf("no characters left to pick from")
+	}
+
+	classes := []struct {
+		name  string
+		chars string
+		min   int
+	}{
+		{"lowercase letters", PossibleLowerCase, policy.MinLower},
+		{"uppercase letters", PossibleUpperCase, policy.MinUpper},
+		{"digits", PossibleNumbers, policy.MinDigits},
+		{"symbols", symbols, policy.MinSymbols},
+	}
+	var required []byte
+	for _, class := range classes {
+		if class.min <= 0 {
+			continue
+		}
+		chars := intersect(class.chars, charset)
+		if chars == "" {
+			return "", fmt.Errorf("the policy requires %s, but none are allowed", class.name)
+		}
+		for i := 0; i < class.min; i++ {
+			required = append(required, chars[intn(len(chars))])
+		}
+	}
+	if len(required) > policy.Length {
+		return "", fmt.Errorf("the policy requires %d characters, more than its length of %d", len(required), policy.Length)
+	}
+
+	password := append(required, String(charset, policy.Length-len(required))...)
+	// Shuffle, so that the required characters are not always first.
+	for i := len(password) - 1; i > 0; i-- {
+		j := intn(i + 1)
+		password[i], password[j] = password[j], password[i]
+	}
+	return policy.Prefix + string(password) + policy.Suffix, nil
+}
+
+// intersect returns the characters of a that are in b.
+func intersect(a, b string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(b, r) {
+			return r
+		}
+		return -1
+	}, a)
+}
+
+// Token returns n random bytes, read from the source of the package, encoded
+// in unpadded URL-safe base64, for API tokens and secrets that are never
+// typed.
+func Token(n int) string {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(Reader, b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+func count(s, chars string) int {
+	n := 0
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPassword(t *testing.T) {
+	policy := PasswordPolicy{
+		Length:           16,
+		MinLower:         2,
+		MinUpper:         2,
+		MinDigits:        3,
+		MinSymbols:       2,
+		ExcludeAmbiguous: true,
+		Exclude:          "@",
+		Prefix:           "Pk-",
+		Suffix:           "!",
+	}
+	for i := 0; i < 100; i++ {
+		password, err := Password(policy)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !strings.HasPrefix(password, "Pk-") || !strings.HasSuffix(password, "!") {
+			t.Fatalf("bad prefix or suffix: %s", password)
+		}
+		random := strings.TrimSuffix(strings.TrimPrefix(password, "Pk-"), "!")
+		if len(random) != 16 {
+			t.Fatalf("bad length: %s", password)
+		}
+		if count(random, PossibleLowerCase) < 2 || count(random, PossibleUpperCase) < 2 ||
+			count(random, PossibleNumbers) < 3 || count(random, DefaultSymbols) < 2 {
+			t.Fatalf("policy not satisfied: %s", password)
+		}
+		if count(random, AmbiguousCharacters+"@") > 0 {
+			t.Fatalf("excluded characters in %s", password)
+		}
+	}
+}
+
+func TestPassword_impossible(t *testing.T) {
+	policies := map[string]PasswordPolicy{
+		"too short":         {Length: 3, MinDigits: 2, MinUpper: 2},
+		"no digits allowed": {Length: 8, MinDigits: 1, Exclude: PossibleNumbers},
+		"nothing allowed":   {Length: 8, Charset: "01", ExcludeAmbiguous: true},
+	}
+	for name, policy := range policies {
+		if _, err := Password(policy); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNewSeededSource(t *testing.T) {
+	generate := func(seed int64) (string, string) {
+		previous := SetSource(NewSeededSource(seed))
+		defer SetSource(previous)
+		password, err := Password(PasswordPolicy{Length: 20, MinSymbols: 1})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return password, Token(16)
+	}
+	password, token := generate(42)
+	samePassword, sameToken := generate(42)
+	if password != samePassword || token != sameToken {
+		t.Fatalf("seeded source is not deterministic: %s %s, %s %s", password, token, samePassword, sameToken)
+	}
+	if otherPassword, _ := generate(43); otherPassword == password {
+		t.Fatalf("different seeds generated the same password %s", password)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package random
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+var (
+	sourceLock sync.Mutex
+	source     io.Reader = cryptorand.Reader
+)
+
+// Reader reads from the source of randomness of the package, see SetSource.
+// It is safe to be used from multiple goroutines.
+var Reader io.Reader = sourceReader{}
+
+type sourceReader struct{}
+
+func (sourceReader) Read(b []byte) (int, error) {
+	sourceLock.Lock()
+	defer sourceLock.Unlock()
+	return io.ReadFull(source, b)
+}
+
+// SetSource sets the source of randomness of the strings of the package and
+// of the UUIDs of the uuid package to r, like a hardware RNG required on the
+// host or a deterministic reader in tests, or back to crypto/rand when r is
+// nil. Reads from r are serialized. SetSource returns the previous source,
+// for tests to restore it.
+func SetSource(r io.Reader) io.Reader {
+	if r == nil {
+		r = cryptorand.Reader
+	}
+	sourceLock.Lock()
+	defer sourceLock.Unlock()
+	previous := source
+	source = r
+	return previous
+}
+
+// NewSeededSource returns a deterministic source of randomness, always
+// producing the same bytes for the same seed, for acceptance tests to set with
+// SetSource and get reproducible names and passwords. It must never be used
+// to generate real credentials.
+func NewSeededSource(seed int64) io.Reader {
+	return &seededSource{seed: seed}
+}
+
+// seededSource returns the SHA-256 hashes of its seed and a counter.
+type seededSource struct {
+	seed    int64
+	counter uint64
+	buf     []byte
+}
+
+func (s *seededSource) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		if len(s.buf) == 0 {
+			var block [16]byte
+			binary.BigEndian.PutUint64(block[:8], uint64(s.seed))
+			binary.BigEndian.PutUint64(block[8:], s.counter)
+			s.counter++
+			sum := sha256.Sum256(block[:])
+			s.buf = sum[:]
+		}
+		c := copy(b[n:], s.buf)
+		s.buf = s.buf[c:]
+		n += c
+	}
+	return n, nil
+}
@@ -1,13 +1,13 @@
 // Copyright (c) HashiCorp, Inc.
 // SPDX-License-Identifier: MPL-2.0
 
-// Package random is a helper for generating random alphanumeric strings.
+// Package random is a helper for generating random alphanumeric strings,
+// passwords and tokens.
 package random
 
 import (
-	"math/rand"
-	"os"
-	"time"
+	cryptorand "crypto/rand"
+	"math/big"
 )
 
 var (
@@ -21,8 +21,6 @@ var (
 	PossibleAlphaNumUpper = PossibleNumbers + PossibleUpperCase
 )
 
-var rnd = rand.New(rand.NewSource(time.Now().UnixNano() + int64(os.Getpid())))
-
 // Numbers returns a random numeric string of the given length
 func Numbers(length int) string { return String(PossibleNumbers, length) }
 
@@ -40,11 +38,22 @@ func AlphaNumUpper(length int) string { return String(PossibleAlphaNumUpper, len
 
 // String returns a random string of the given length, using only the component
 // characters provided in the "chooseFrom" string.
+//
+// The characters are picked using the source of the package, "crypto/rand"
+// unless replaced with SetSource.
 func String(chooseFrom string, length int) (randomString string) {
 	cflen := len(chooseFrom)
 	bytes := make([]byte, length)
 	for i := range bytes {
-		bytes[i] = chooseFrom[rnd.Intn(cflen)]
+		bytes[i] = chooseFrom[intn(cflen)]
 	}
 	return string(bytes)
 }
+
+func intn(n int) int {
+	i, err := cryptorand.Int(Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(i.Int64())
+}
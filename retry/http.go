@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPTransport is an http.RoundTripper that retries idempotent requests
+// failing with a network error, a 429 or a 5xx status. Once the tries are
+// exhausted the last response is returned as is, so that callers can
+// report the status.
+type HTTPTransport struct {
+	// Base is the transport doing the requests. Defaults to
+	// http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Config tells how to retry. Tries defaults to 4 and RetryDelay to a
+	// linear backoff starting at 1s and doubling up to 30s. ShouldRetry is
+	// ignored.
+	Config
+}
+
+// NewHTTPClient returns an http.Client using the proxy settings of the
+// environment and retrying failed requests.
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &HTTPTransport{
+			Base: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+			},
+		},
+	}
+}
+
+type retryableStatusError int
+
+func (e retryableStatusError) Error() string {
+	return fmt.Sprintf("retryable HTTP status %d", int(e))
+}
+
+func (t *HTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return base.RoundTrip(req)
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body could not be sent again.
+		return base.RoundTrip(req)
+	}
+
+	cfg := t.Config
+	if cfg.Tries == 0 {
+		cfg.Tries = 4
+	}
+	if cfg.RetryDelay == nil {
+		backoff := &Backoff{InitialBackoff: time.Second, MaxBackoff: 30 * time.Second, Multiplier: 2}
+		cfg.RetryDelay = backoff.Linear
+	}
+	cfg.ShouldRetry = func(err error) bool {
+		return req.Context().Err() == nil
+	}
+
+	var resp *http.Response
+	try := 0
+	err := cfg.Run(req.Context(), func(ctx context.Context) error {
+		if resp != nil {
+			// Drain the previous response so the connection can be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			resp = nil
+		}
+		r := req
+		if try > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+		try++
+
+		res, err := base.RoundTrip(r)
+		if err != nil {
+			return err
+		}
+		resp = res
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+			return retryableStatusError(res.StatusCode)
+		}
+		return nil
+	})
+	var exhausted *RetryExhaustedError
+	if errors.As(err, &exhausted) {
+		err = exhausted.Err
+	}
+	var statusErr retryableStatusError
+	if resp != nil && (err == nil || errors.As(err, &statusErr)) {
+		return resp, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,62 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPTransport(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if strings.HasSuffix(r.URL.Path, "/down") || requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &HTTPTransport{
+		Config: Config{Tries: 3, RetryDelay: func() time.Duration { return time.Millisecond }},
+	}}
+
+	resp, err := client.Get(server.URL + "/flaky")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" || requests != 3 {
+		t.Fatalf("unexpected response %d %q after %d requests", resp.StatusCode, body, requests)
+	}
+
+	// The last response is returned once the tries are exhausted.
+	requests = 0
+	resp, err = client.Get(server.URL + "/down")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || requests != 3 {
+		t.Fatalf("unexpected response %d after %d requests", resp.StatusCode, requests)
+	}
+
+	// Non idempotent requests are not retried.
+	requests = 0
+	resp, err = client.Post(server.URL+"/down", "text/plain", strings.NewReader("data"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if requests != 1 {
+		t.Fatalf("POST should not be retried, got %d requests", requests)
+	}
+}
@@ -4,7 +4,14 @@
 package rpc
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 // An implementation of packersdk.Artifact where the artifact is actually
@@ -20,27 +27,27 @@ type ArtifactServer struct {
 }
 
 func (a *artifact) BuilderId() (result string) {
-	a.client.Call(a.endpoint+".BuilderId", new(interface{}), &result)
+	a.idempotentCall(a.endpoint+".BuilderId", new(interface{}), &result)
 	return
 }
 
 func (a *artifact) Files() (result []string) {
-	a.client.Call(a.endpoint+".Files", new(interface{}), &result)
+	a.idempotentCall(a.endpoint+".Files", new(interface{}), &result)
 	return
 }
 
 func (a *artifact) Id() (result string) {
-	a.client.Call(a.endpoint+".Id", new(interface{}), &result)
+	a.idempotentCall(a.endpoint+".Id", new(interface{}), &result)
 	return
 }
 
 func (a *artifact) String() (result string) {
-	a.client.Call(a.endpoint+".String", new(interface{}), &result)
+	a.idempotentCall(a.endpoint+".String", new(interface{}), &result)
 	return
 }
 
 func (a *artifact) State(name string) (result interface{}) {
-	a.client.Call(a.endpoint+".State", name, &result)
+	a.idempotentCall(a.endpoint+".State", name, &result)
 	return
 }
 
@@ -87,3 +94,133 @@ func (s *ArtifactServer) Destroy(args *interface{}, reply *error) error {
 	*reply = err
 	return nil
 }
+
+// ArtifactMetadataResponse is the metadata of an artifact, as sent over RPC.
+// The cty values do not go through gob, they are sent as a JSON encoded
+// object.
+type ArtifactMetadataResponse struct {
+	// Supported is false when the artifact has no metadata.
+	Supported  bool
+	CreatedAt  time.Time
+	Size       int64
+	Checksums  map[string]string
+	Provenance packersdk.ArtifactProvenance
+	Values     []byte
+}
+
+var _ packersdk.ArtifactV2 = new(artifact)
+
+// Metadata returns the metadata of the remote artifact, nil when it has none
+// or the plugin was built with an SDK predating packersdk.ArtifactV2.
+func (a *artifact) Metadata() *packersdk.ArtifactMetadata {
+	resp := new(ArtifactMetadataResponse)
+	if err := a.client.Call(a.endpoint+".Metadata", new(interface{}), resp); err != nil {
+		log.Printf("[DEBUG] %s does not support Metadata: %s", a.endpoint, err)
+		return nil
+	}
+	if !resp.Supported {
+		return nil
+	}
+	values, err := decodeArtifactValues(resp.Values)
+	if err != nil {
+		log.Printf("[ERR] Invalid metadata values of artifact: %s", err)
+	}
+	return &packersdk.ArtifactMetadata{
+		CreatedAt:  resp.CreatedAt,
+		Size:       resp.Size,
+		Checksums:  resp.Checksums,
+		Provenance: resp.Provenance,
+		Values:     values,
+	}
+}
+
+func (s *ArtifactServer) Metadata(args *interface{}, reply *ArtifactMetadataResponse) error {
+	v2, ok := s.artifact.(packersdk.ArtifactV2)
+	if !ok {
+		return nil
+	}
+	md := v2.Metadata()
+	if md == nil {
+		return nil
+	}
+	values, err := encodeArtifactValues(md.Values)
+	if err != nil {
+		return err
+	}
+	*reply = ArtifactMetadataResponse{
+		Supported:  true,
+		CreatedAt:  md.CreatedAt,
+		Size:       md.Size,
+		Checksums:  md.Checksums,
+		Provenance: md.Provenance,
+		Values:     values,
+	}
+	return nil
+}
+
+// encodeArtifactValues encodes the metadata values of an artifact as a JSON
+// cty object, along with its type.
+func encodeArtifactValues(values map[string]cty.Value) ([]byte, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return ctyjson.Marshal(cty.ObjectVal(values), cty.DynamicPseudoType)
+}
+
+func decodeArtifactValues(b []byte) (map[string]cty.Value, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	v, err := ctyjson.Unmarshal(b, cty.DynamicPseudoType)
+	if err != nil {
+		return nil, err
+	}
+	if !v.Type().IsObjectType() {
+		return nil, fmt.Errorf("expected an object, got %s", v.Type().FriendlyName())
+	}
+	return v.AsValueMap(), nil
+}
+
+// ArtifactDestroyResponse is the response of DestroyWithOptions. Error is
+// part of the response rather than returned so that the results of a
+// partially failed destroy are still sent.
+type ArtifactDestroyResponse struct {
+	Results []packersdk.DestroyResult
+	Error   *BasicError
+}
+
+var _ packersdk.DestroyableArtifact = new(artifact)
+
+// DestroyWithOptions destroys the remote artifact with opts. Plugins built
+// with an SDK predating packersdk.DestroyableArtifact report their artifact
+// as a single resource, as packersdk.DestroyArtifact does.
+func (a *artifact) DestroyWithOptions(ctx context.Context, opts packersdk.DestroyOptions) ([]packersdk.DestroyResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp := new(ArtifactDestroyResponse)
+	if err := a.client.Call(a.endpoint+".DestroyWithOptions", opts, resp); err != nil {
+		if isMethodNotFound(err) {
+			// Hide DestroyWithOptions so that the artifact is destroyed
+			// with Destroy.
+			return packersdk.DestroyArtifact(ctx, struct{ packersdk.Artifact }{a}, opts)
+		}
+		return nil, err
+	}
+	if resp.Error == nil {
+		return resp.Results, nil
+	}
+	if err := packersdk.DestroyResultsError(resp.Results); err != nil {
+		return resp.Results, err
+	}
+	return resp.Results, resp.Error
+}
+
+func (s *ArtifactServer) DestroyWithOptions(opts packersdk.DestroyOptions, reply *ArtifactDestroyResponse) error {
+	results, err := packersdk.DestroyArtifact(context.Background(), s.artifact, opts)
+	*reply = ArtifactDestroyResponse{
+		Results: results,
+		Error:   NewBasicError(err),
+	}
+	return nil
+}
@@ -4,10 +4,14 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestArtifactRPC(t *testing.T) {
@@ -40,6 +44,124 @@ func TestArtifactRPC(t *testing.T) {
 	}
 }
 
+var testArtifactMetadata = &packersdk.ArtifactMetadata{
+	CreatedAt: time.Date(2023, 4, 5, 6, 7, 8, 9, time.UTC),
+	Size:      1024,
+	Checksums: map[string]string{"sha256": "abcd", "md5": "ef01"},
+	Provenance: packersdk.ArtifactProvenance{
+		BuildName:     "ubuntu",
+		Sources:       []string{"ami-1"},
+		PluginVersion: "1.2.3",
+	},
+	Values: map[string]cty.Value{
+		"region":  cty.StringVal("eu-west-1"),
+		"volumes": cty.ListVal([]cty.Value{cty.NumberIntVal(8)}),
+	},
+}
+
+func checkArtifactMetadata(t *testing.T, md *packersdk.ArtifactMetadata) {
+	t.Helper()
+	expected := testArtifactMetadata
+	if md == nil {
+		t.Fatal("the metadata should be sent")
+	}
+	if !md.CreatedAt.Equal(expected.CreatedAt) {
+		t.Fatalf("bad creation time: %s", md.CreatedAt)
+	}
+	if md.Size != expected.Size || !reflect.DeepEqual(md.Checksums, expected.Checksums) || !reflect.DeepEqual(md.Provenance, expected.Provenance) {
+		t.Fatalf("bad metadata: %#v", md)
+	}
+	if len(md.Values) != len(expected.Values) {
+		t.Fatalf("bad values: %#v", md.Values)
+	}
+	for k, v := range expected.Values {
+		if !md.Values[k].RawEquals(v) {
+			t.Fatalf("bad value %q: %#v", k, md.Values[k])
+		}
+	}
+}
+
+func TestArtifactRPC_Metadata(t *testing.T) {
+	a := &packersdk.MockArtifact{MetadataValue: testArtifactMetadata}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+
+	checkArtifactMetadata(t, client.Artifact().(packersdk.ArtifactV2).Metadata())
+
+	a.MetadataValue = nil
+	if md := client.Artifact().(packersdk.ArtifactV2).Metadata(); md != nil {
+		t.Fatalf("artifacts without metadata should have none: %#v", md)
+	}
+}
+
+// destroyableArtifact reports an image it destroys, and the snapshot backing
+// it, which fails to be destroyed when forced.
+type destroyableArtifact struct {
+	packersdk.MockArtifact
+	opts packersdk.DestroyOptions
+}
+
+func (a *destroyableArtifact) DestroyWithOptions(_ context.Context, opts packersdk.DestroyOptions) ([]packersdk.DestroyResult, error) {
+	a.opts = opts
+	results := []packersdk.DestroyResult{
+		{Type: "image", ID: "ami-1", Status: packersdk.Destroyed},
+		{Type: "snapshot", ID: "snap-1", Status: packersdk.Skipped, Dependents: []string{"ami-2"}},
+	}
+	if opts.Force {
+		results[1].Status = packersdk.DestroyFailed
+		results[1].Error = "denied"
+	}
+	return results, packersdk.DestroyResultsError(results)
+}
+
+func testDestroyableArtifact(t *testing.T, a *destroyableArtifact, client packersdk.Artifact) {
+	t.Helper()
+	results, err := packersdk.DestroyArtifact(context.Background(), client, packersdk.DestroyOptions{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(results) != 2 || results[1].Status != packersdk.Skipped || !reflect.DeepEqual(results[1].Dependents, []string{"ami-2"}) {
+		t.Fatalf("bad results: %#v", results)
+	}
+
+	results, err = packersdk.DestroyArtifact(context.Background(), client, packersdk.DestroyOptions{Force: true})
+	var derr *packersdk.DestroyError
+	if !errors.As(err, &derr) || !a.opts.Force {
+		t.Fatalf("bad error: %#v", err)
+	}
+	if len(results) != 2 || results[1].Status != packersdk.DestroyFailed || results[1].Error != "denied" {
+		t.Fatalf("bad results: %#v", results)
+	}
+}
+
+func TestArtifactRPC_DestroyWithOptions(t *testing.T) {
+	a := new(destroyableArtifact)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+
+	testDestroyableArtifact(t, a, client.Artifact())
+}
+
+func TestArtifactRPC_DestroyDryRun(t *testing.T) {
+	mock := new(packersdk.MockArtifact)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(mock)
+
+	results, err := packersdk.DestroyArtifact(context.Background(), client.Artifact(), packersdk.DestroyOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(results) != 1 || results[0].Status != packersdk.WouldDestroy || mock.DestroyCalled {
+		t.Fatalf("bad dry run: %#v", results)
+	}
+}
+
 func TestArtifact_Implements(t *testing.T) {
 	var _ packersdk.Artifact = new(artifact)
 }
@@ -5,9 +5,14 @@ package rpc
 
 import (
 	"context"
+	"fmt"
 	"log"
 
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 // An implementation of packersdk.Build where the build is actually executed
@@ -126,7 +131,7 @@ func (b *BuildServer) Prepare(args *interface{}, resp *BuildPrepareResponse) err
 
 func (b *BuildServer) Run(streamId uint32, reply *[]uint32) error {
 	if b.context == nil {
-		b.context, b.contextCancel = context.WithCancel(context.Background())
+		b.context, b.contextCancel = b.mux.newContext()
 	}
 
 	client, err := newClientWithMux(b.mux, streamId)
@@ -174,3 +179,43 @@ func (b *BuildServer) Cancel(args *interface{}, reply *interface{}) error {
 	}
 	return nil
 }
+
+// encodeGeneratedData encodes the generated data of a build, given to hooks
+// and provisioners, as a JSON cty object along with its type. Through gob,
+// interface{} values only keep the types registered with it, so the typed
+// encoding is sent alongside the gob one, which plugins built with older
+// SDKs read.
+func encodeGeneratedData(data map[string]interface{}) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	values := make(map[string]cty.Value, len(data))
+	for k, v := range data {
+		value, err := hcl2helper.Encode(v)
+		if err != nil {
+			return nil, fmt.Errorf("generated data %q: %s", k, err)
+		}
+		values[k] = value
+	}
+	return ctyjson.Marshal(cty.ObjectVal(values), cty.DynamicPseudoType)
+}
+
+// decodeGeneratedData decodes the generated data encoded by
+// encodeGeneratedData back to the Go types builders use: strings, bools,
+// ints for whole numbers and float64 for the others, []string and
+// map[string]string for collections of strings, and []interface{} and
+// map[string]interface{} for the other collections.
+func decodeGeneratedData(b []byte) (map[string]interface{}, error) {
+	v, err := ctyjson.Unmarshal(b, cty.DynamicPseudoType)
+	if err != nil {
+		return nil, err
+	}
+	if !v.Type().IsObjectType() {
+		return nil, fmt.Errorf("expected an object, got %s", v.Type().FriendlyName())
+	}
+	data := map[string]interface{}{}
+	for k, value := range v.AsValueMap() {
+		data[k] = packerbuilderdata.GoValue(value)
+	}
+	return data, nil
+}
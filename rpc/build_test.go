@@ -182,3 +182,29 @@ func TestBuildPrepare_Warnings(t *testing.T) {
 func TestBuild_ImplementsBuild(t *testing.T) {
 	var _ packersdk.Build = new(build)
 }
+
+func TestGeneratedDataCodec(t *testing.T) {
+	data := map[string]interface{}{
+		"Count":  3,
+		"Float":  1.5,
+		"Huge":   1e300,
+		"Mixed":  []interface{}{"a", 1, false},
+		"Nested": map[string]interface{}{"port": 22, "names": []string{"x"}},
+		"Empty":  []string{},
+	}
+	b, err := encodeGeneratedData(data)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	decoded, err := decodeGeneratedData(b)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(data, decoded) {
+		t.Fatalf("bad: %#v", decoded)
+	}
+
+	if _, err := encodeGeneratedData(map[string]interface{}{"Fn": func() {}}); err == nil {
+		t.Fatal("functions can't be encoded")
+	}
+}
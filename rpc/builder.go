@@ -6,7 +6,9 @@ package rpc
 import (
 	"context"
 	"log"
+	"sync"
 
+	"github.com/hashicorp/hcl/v2"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -16,11 +18,14 @@ type builder struct {
 	commonClient
 }
 
+var _ packersdk.DiagnosticsBuilder = new(builder)
+
 // BuilderServer wraps a packersdk.Builder implementation and makes it exportable
 // as part of a Golang RPC server.
 type BuilderServer struct {
 	context       context.Context
 	contextCancel func()
+	contextLock   sync.Mutex
 
 	commonServer
 	builder packersdk.Builder
@@ -28,12 +33,16 @@ type BuilderServer struct {
 
 type BuilderPrepareArgs struct {
 	Configs []interface{}
+	TraceContext
 }
 
 type BuilderPrepareResponse struct {
 	GeneratedVars []string
 	Warnings      []string
 	Error         *BasicError
+	// Diagnostics are set when the builder returned a
+	// packersdk.DiagnosticsError.
+	Diagnostics []Diagnostic
 }
 
 func (b *builder) Prepare(config ...interface{}) ([]string, []string, error) {
@@ -42,16 +51,20 @@ func (b *builder) Prepare(config ...interface{}) ([]string, []string, error) {
 		return nil, nil, err
 	}
 	var resp BuilderPrepareResponse
-	cerr := b.client.Call(b.endpoint+".Prepare", &BuilderPrepareArgs{config}, &resp)
+	cerr := b.tracedCall(context.Background(), b.endpoint+".Prepare", &BuilderPrepareArgs{Configs: config}, &resp)
 	if cerr != nil {
 		return nil, nil, cerr
 	}
 
-	if resp.Error != nil {
-		err = resp.Error
-	}
+	warnings, err := decodePrepareResult(resp.Warnings, resp.Error, resp.Diagnostics)
+	return resp.GeneratedVars, warnings, err
+}
 
-	return resp.GeneratedVars, resp.Warnings, err
+// PrepareWithDiagnostics calls Prepare, which carries the diagnostics of the
+// builders implementing packersdk.DiagnosticsBuilder.
+func (b *builder) PrepareWithDiagnostics(config ...interface{}) ([]string, hcl.Diagnostics) {
+	generated, warnings, err := b.Prepare(config...)
+	return generated, packersdk.PrepareDiagnostics(warnings, err)
 }
 
 func (b *builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
@@ -76,7 +89,7 @@ func (b *builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 	var responseId uint32
 
-	if err := b.client.Call(b.endpoint+".Run", nextId, &responseId); err != nil {
+	if err := b.tracedCall(ctx, b.endpoint+".Run", nextId, &responseId); err != nil {
 		return nil, err
 	}
 
@@ -97,11 +110,15 @@ func (b *BuilderServer) Prepare(args *BuilderPrepareArgs, reply *BuilderPrepareR
 	if err != nil {
 		return err
 	}
+	_, span := b.startSpan(context.Background(), "Builder.Prepare", args.TraceContext.TraceContext)
 	generated, warnings, err := b.builder.Prepare(config...)
+	span.End(err)
+	warnings, berr, diags := encodePrepareResult(warnings, err)
 	*reply = BuilderPrepareResponse{
 		GeneratedVars: generated,
 		Warnings:      warnings,
-		Error:         NewBasicError(err),
+		Error:         berr,
+		Diagnostics:   diags,
 	}
 	return nil
 }
@@ -113,11 +130,9 @@ func (b *BuilderServer) Run(streamId uint32, reply *uint32) error {
 	}
 	defer client.Close()
 
-	if b.context == nil {
-		b.context, b.contextCancel = context.WithCancel(context.Background())
-	}
-
-	artifact, err := b.builder.Run(b.context, client.Ui(), client.Hook())
+	ctx, span := b.startSpan(b.runContext(), "Builder.Run", nil)
+	artifact, err := b.builder.Run(ctx, client.Ui(), client.Hook())
+	span.End(err)
 	if err != nil {
 		return NewBasicError(err)
 	}
@@ -137,7 +152,22 @@ func (b *BuilderServer) Run(streamId uint32, reply *uint32) error {
 	return nil
 }
 
+// runContext returns the context of the build, created on first use.
+func (b *BuilderServer) runContext() context.Context {
+	b.contextLock.Lock()
+	defer b.contextLock.Unlock()
+	if b.context == nil {
+		b.context, b.contextCancel = b.newContext()
+	}
+	return b.context
+}
+
+// Cancel cancels the build. The cancellation can be received before the call
+// to Run it races with, which then runs cancelled.
 func (b *BuilderServer) Cancel(args *interface{}, reply *interface{}) error {
+	b.runContext()
+	b.contextLock.Lock()
+	defer b.contextLock.Unlock()
 	b.contextCancel()
 	return nil
 }
@@ -156,6 +156,99 @@ func TestBuilderCancel(t *testing.T) {
 	}
 }
 
+func TestBuilderServer_SetContext(t *testing.T) {
+	base, cancelBase := context.WithCancel(context.Background())
+	b := new(packersdk.MockBuilder)
+	cancelled := false
+	b.RunFn = func(ctx context.Context) {
+		cancelBase()
+		<-ctx.Done()
+		cancelled = true
+	}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.SetContext(base)
+	server.RegisterBuilder(b)
+
+	// Packer does not cancel the build, the plugin does.
+	if _, err := client.Builder().Run(context.Background(), new(testUi), new(packersdk.MockHook)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !cancelled {
+		t.Fatal("context should have been cancelled")
+	}
+}
+
 func TestBuilder_ImplementsBuilder(t *testing.T) {
 	var _ packersdk.Builder = new(builder)
 }
+
+type estimatingBuilder struct {
+	packersdk.MockBuilder
+}
+
+var testEstimate = &packersdk.Estimate{
+	Currency: "USD",
+	Resources: []packersdk.ResourceEstimate{
+		{Kind: "instance", Name: "t3.large", Quantity: 2, Unit: "hours", Cost: 0.17},
+		{Kind: "volume", Name: "gp3", Quantity: 8, Unit: "GiB-month", Cost: 0.64},
+	},
+}
+
+func (b *estimatingBuilder) Estimate() (*packersdk.Estimate, error) {
+	return testEstimate, nil
+}
+
+func TestBuilderEstimate(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(new(estimatingBuilder))
+
+	estimate, err := packersdk.EstimateBuild(client.Builder())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(estimate, testEstimate) {
+		t.Fatalf("bad estimate: %#v", estimate)
+	}
+}
+
+func TestBuilderEstimate_Unsupported(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(new(packersdk.MockBuilder))
+
+	estimate, err := packersdk.EstimateBuild(client.Builder())
+	if err != nil || estimate != nil {
+		t.Fatalf("expected no estimate, got %#v, %v", estimate, err)
+	}
+}
+
+func TestBuilderCancel_beforeRun(t *testing.T) {
+	b := new(packersdk.MockBuilder)
+	cancelled := false
+	b.RunFn = func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled = true
+	}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder().(*builder)
+
+	// Packer may cancel a build before the plugin received the call to run
+	// it.
+	if err := bClient.client.Call(bClient.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := bClient.Run(context.Background(), new(testUi), new(packersdk.MockHook)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !cancelled {
+		t.Fatal("the build should run cancelled")
+	}
+}
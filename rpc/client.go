@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"net/rpc"
+	"sync"
 
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/ugorji/go/codec"
@@ -21,6 +22,10 @@ type Client struct {
 	mux      *muxBroker
 	client   *rpc.Client
 	closeMux bool
+
+	// uis are the buffered Uis created by Ui, flushed on Close.
+	l   sync.Mutex
+	uis []*Ui
 }
 
 func NewClient(rwc io.ReadWriteCloser) (*Client, error) {
@@ -59,6 +64,7 @@ func newClientWithMux(mux *muxBroker, streamId uint32) (*Client, error) {
 }
 
 func (c *Client) Close() error {
+	c.flushUi()
 	if err := c.client.Close(); err != nil {
 		return err
 	}
@@ -76,6 +82,7 @@ func (c *Client) Artifact() packer.Artifact {
 		commonClient: commonClient{
 			endpoint: DefaultArtifactEndpoint,
 			client:   c.client,
+			mux:      c.mux,
 		},
 	}
 }
@@ -150,12 +157,39 @@ func (c *Client) Datasource() packer.Datasource {
 	}
 }
 
+func (c *Client) Function() packer.Function {
+	return &function{
+		commonClient: commonClient{
+			endpoint: DefaultFunctionEndpoint,
+			client:   c.client,
+			mux:      c.mux,
+		},
+	}
+}
+
 func (c *Client) Ui() packer.Ui {
-	return &Ui{
+	ui := &Ui{
 		commonClient: commonClient{
 			endpoint: DefaultUiEndpoint,
 			client:   c.client,
 		},
 		endpoint: DefaultUiEndpoint,
 	}
+	if config := c.mux.uiBufferConfig(); config != nil {
+		ui.buffer = newUiBuffer(c.client, *config)
+		c.l.Lock()
+		c.uis = append(c.uis, ui)
+		c.l.Unlock()
+	}
+	return ui
+}
+
+// flushUi sends the messages still buffered by the Uis of c.
+func (c *Client) flushUi() {
+	c.l.Lock()
+	uis := c.uis
+	c.l.Unlock()
+	for _, ui := range uis {
+		ui.Flush()
+	}
 }
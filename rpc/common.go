@@ -8,6 +8,7 @@ import (
 	"encoding/gob"
 	"fmt"
 	"net/rpc"
+	"sync"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/zclconf/go-cty/cty"
@@ -30,6 +31,10 @@ type commonServer struct {
 	selfConfigurable interface {
 		ConfigSpec() hcldec.ObjectSpec
 	}
+	trace serverTrace
+	// ns is the namespace of the calls, set by SetNamespace.
+	nsLock sync.Mutex
+	ns     *Namespace
 }
 
 type ConfigSpecResponse struct {
@@ -42,7 +47,7 @@ func (p *commonClient) ConfigSpec() hcldec.ObjectSpec {
 	// decide later. The correct approach would probably be to return an error
 	// in ConfigSpec but that will break a lot of things.
 	resp := &ConfigSpecResponse{}
-	cerr := p.client.Call(p.endpoint+".ConfigSpec", new(interface{}), resp)
+	cerr := p.idempotentCall(p.endpoint+".ConfigSpec", new(interface{}), resp)
 	if cerr != nil {
 		err := fmt.Errorf("ConfigSpec failed: %v", cerr)
 		panic(err.Error())
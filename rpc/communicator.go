@@ -6,6 +6,8 @@ package rpc
 import (
 	"context"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/rpc"
@@ -26,6 +28,16 @@ type communicator struct {
 type CommunicatorServer struct {
 	commonServer
 	c packersdk.Communicator
+
+	tunnelsLock sync.Mutex
+	tunnels     map[uint32]packersdk.Tunnel
+	nextTunnel  uint32
+
+	// transfers holds the cancel functions of the transfers running, by
+	// TransferId. A nil function marks a transfer cancelled before it
+	// started.
+	transfersLock sync.Mutex
+	transfers     map[uint32]context.CancelFunc
 }
 
 type CommandFinished struct {
@@ -40,27 +52,71 @@ type CommunicatorStartArgs struct {
 	ResponseStreamId uint32
 }
 
+// The TransferId of the arguments of transfers identifies them for
+// CancelTransfer. It is 0 when sent by clients built with an SDK that predates
+// it.
+
 type CommunicatorDownloadArgs struct {
 	Path           string
 	WriterStreamId uint32
+	TransferId     uint32
 }
 
 type CommunicatorUploadArgs struct {
 	Path           string
 	ReaderStreamId uint32
 	FileInfo       *fileInfo
+	TransferId     uint32
 }
 
 type CommunicatorUploadDirArgs struct {
-	Dst     string
-	Src     string
-	Exclude []string
+	Dst        string
+	Src        string
+	Exclude    []string
+	TransferId uint32
 }
 
 type CommunicatorDownloadDirArgs struct {
-	Dst     string
-	Src     string
-	Exclude []string
+	Dst        string
+	Src        string
+	Exclude    []string
+	TransferId uint32
+}
+
+type CommunicatorCapabilitiesResponse struct {
+	Capabilities packersdk.CommunicatorCapabilities
+	Known        bool
+}
+
+type CommunicatorTunnelArgs struct {
+	Local   string
+	Remote  string
+	Reverse bool
+}
+
+type CommunicatorTunnelResponse struct {
+	ID          uint32
+	Addr        string
+	Unsupported bool
+	Error       *BasicError
+}
+
+// tunnel is a packersdk.Tunnel opened by a CommunicatorServer. Packer and its
+// plugins run on the same host, so the local address of the tunnel is
+// reachable from both sides.
+type tunnel struct {
+	client   *rpc.Client
+	endpoint string
+	id       uint32
+	addr     string
+}
+
+func (t *tunnel) Addr() string {
+	return t.addr
+}
+
+func (t *tunnel) Close() error {
+	return t.client.Call(t.endpoint+".CloseTunnel", t.id, new(interface{}))
 }
 
 func Communicator(client *rpc.Client) *communicator {
@@ -135,6 +191,14 @@ func (c *communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err
 }
 
 func (c *communicator) Upload(path string, r io.Reader, fi *os.FileInfo) (err error) {
+	return c.UploadContext(context.Background(), path, r, fi)
+}
+
+func (c *communicator) UploadContext(ctx context.Context, path string, r io.Reader, fi *os.FileInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Pipe the reader through to the connection
 	streamId := c.mux.NextId()
 	go serveSingleCopy("uploadData", c.mux, streamId, nil, r)
@@ -142,25 +206,34 @@ func (c *communicator) Upload(path string, r io.Reader, fi *os.FileInfo) (err er
 	args := CommunicatorUploadArgs{
 		Path:           path,
 		ReaderStreamId: streamId,
+		TransferId:     c.mux.NextId(),
 	}
 
 	if fi != nil {
 		args.FileInfo = NewFileInfo(*fi)
 	}
 
-	err = c.client.Call(c.endpoint+".Upload", &args, new(interface{}))
-	return
+	return c.transfer(ctx, ".Upload", args.TransferId, &args, new(interface{}))
 }
 
 func (c *communicator) UploadDir(dst string, src string, exclude []string) error {
+	return c.UploadDirContext(context.Background(), dst, src, exclude)
+}
+
+func (c *communicator) UploadDirContext(ctx context.Context, dst string, src string, exclude []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	args := &CommunicatorUploadDirArgs{
-		Dst:     dst,
-		Src:     src,
-		Exclude: exclude,
+		Dst:        dst,
+		Src:        src,
+		Exclude:    exclude,
+		TransferId: c.mux.NextId(),
 	}
 
 	var reply error
-	err := c.client.Call(c.endpoint+".UploadDir", args, &reply)
+	err := c.transfer(ctx, ".UploadDir", args.TransferId, args, &reply)
 	if err == nil {
 		err = reply
 	}
@@ -169,14 +242,23 @@ func (c *communicator) UploadDir(dst string, src string, exclude []string) error
 }
 
 func (c *communicator) DownloadDir(src string, dst string, exclude []string) error {
+	return c.DownloadDirContext(context.Background(), src, dst, exclude)
+}
+
+func (c *communicator) DownloadDirContext(ctx context.Context, src string, dst string, exclude []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	args := &CommunicatorDownloadDirArgs{
-		Dst:     dst,
-		Src:     src,
-		Exclude: exclude,
+		Dst:        dst,
+		Src:        src,
+		Exclude:    exclude,
+		TransferId: c.mux.NextId(),
 	}
 
 	var reply error
-	err := c.client.Call(c.endpoint+".DownloadDir", args, &reply)
+	err := c.transfer(ctx, ".DownloadDir", args.TransferId, args, &reply)
 	if err == nil {
 		err = reply
 	}
@@ -184,7 +266,74 @@ func (c *communicator) DownloadDir(src string, dst string, exclude []string) err
 	return err
 }
 
+// transfer calls method with args, and asks the server to cancel the
+// transfer once ctx is done. The transfer is waited for either way: servers
+// built with an SDK that predates CancelTransfer run it to the end.
+func (c *communicator) transfer(ctx context.Context, method string, id uint32, args interface{}, reply interface{}) error {
+	call := c.client.Go(c.endpoint+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+	}
+	if err := c.client.Call(c.endpoint+".CancelTransfer", id, new(interface{})); err != nil {
+		log.Printf("[DEBUG] Could not cancel transfer %d: %s", id, err)
+	}
+	<-call.Done
+	return ctx.Err()
+}
+
+// Capabilities asks the remote communicator for its capabilities. They are
+// reported as unknown if the other side was built with an SDK that predates
+// them.
+func (c *communicator) Capabilities() (packersdk.CommunicatorCapabilities, bool) {
+	var reply CommunicatorCapabilitiesResponse
+	if err := c.client.Call(c.endpoint+".Capabilities", new(interface{}), &reply); err != nil {
+		log.Printf("[DEBUG] Could not get communicator capabilities: %s", err)
+		return packersdk.CommunicatorCapabilities{}, false
+	}
+	return reply.Capabilities, reply.Known
+}
+
+func (c *communicator) Tunnel(ctx context.Context, local, remote string) (packersdk.Tunnel, error) {
+	return c.openTunnel(&CommunicatorTunnelArgs{Local: local, Remote: remote})
+}
+
+func (c *communicator) ReverseTunnel(ctx context.Context, remote, local string) (packersdk.Tunnel, error) {
+	return c.openTunnel(&CommunicatorTunnelArgs{Local: local, Remote: remote, Reverse: true})
+}
+
+func (c *communicator) openTunnel(args *CommunicatorTunnelArgs) (packersdk.Tunnel, error) {
+	var reply CommunicatorTunnelResponse
+	if err := c.client.Call(c.endpoint+".Tunnel", args, &reply); err != nil {
+		if isMethodNotFound(err) {
+			return nil, packersdk.ErrTunnelsNotSupported
+		}
+		return nil, err
+	}
+	if reply.Unsupported {
+		return nil, packersdk.ErrTunnelsNotSupported
+	}
+	if reply.Error != nil {
+		return nil, reply.Error
+	}
+	return &tunnel{
+		client:   c.client,
+		endpoint: c.endpoint,
+		id:       reply.ID,
+		addr:     reply.Addr,
+	}, nil
+}
+
 func (c *communicator) Download(path string, w io.Writer) (err error) {
+	return c.DownloadContext(context.Background(), path, w)
+}
+
+func (c *communicator) DownloadContext(ctx context.Context, path string, w io.Writer) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Serve a single connection and a single copy
 	streamId := c.mux.NextId()
 
@@ -197,10 +346,11 @@ func (c *communicator) Download(path string, w io.Writer) (err error) {
 	args := CommunicatorDownloadArgs{
 		Path:           path,
 		WriterStreamId: streamId,
+		TransferId:     c.mux.NextId(),
 	}
 
 	// Start sending data to the RPC server
-	err = c.client.Call(c.endpoint+".Download", &args, new(interface{}))
+	err = c.transfer(ctx, ".Download", args.TransferId, &args, new(interface{}))
 
 	// Wait for the RPC server to finish receiving the data before we return
 	<-waitServer
@@ -301,16 +451,107 @@ func (c *CommunicatorServer) Upload(args *CommunicatorUploadArgs, reply *interfa
 		fi = new(os.FileInfo)
 		*fi = *args.FileInfo
 	}
-	err = c.c.Upload(args.Path, readerC, fi)
+	ctx, done := c.startTransfer(args.TransferId)
+	defer done()
+	err = packersdk.UploadContext(ctx, c.c, args.Path, readerC, fi)
 	return
 }
 
 func (c *CommunicatorServer) UploadDir(args *CommunicatorUploadDirArgs, reply *error) error {
-	return c.c.UploadDir(args.Dst, args.Src, args.Exclude)
+	ctx, done := c.startTransfer(args.TransferId)
+	defer done()
+	return packersdk.UploadDirContext(ctx, c.c, args.Dst, args.Src, args.Exclude)
 }
 
 func (c *CommunicatorServer) DownloadDir(args *CommunicatorUploadDirArgs, reply *error) error {
-	return c.c.DownloadDir(args.Src, args.Dst, args.Exclude)
+	ctx, done := c.startTransfer(args.TransferId)
+	defer done()
+	return packersdk.DownloadDirContext(ctx, c.c, args.Src, args.Dst, args.Exclude)
+}
+
+// startTransfer returns the context of the transfer id, cancelled by
+// CancelTransfer, and the function to call once it is done.
+func (c *CommunicatorServer) startTransfer(id uint32) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if id == 0 {
+		return ctx, cancel
+	}
+
+	c.transfersLock.Lock()
+	defer c.transfersLock.Unlock()
+	if c.transfers == nil {
+		c.transfers = make(map[uint32]context.CancelFunc)
+	}
+	if cancelled, ok := c.transfers[id]; ok && cancelled == nil {
+		cancel()
+	}
+	c.transfers[id] = cancel
+	return ctx, func() {
+		c.transfersLock.Lock()
+		delete(c.transfers, id)
+		c.transfersLock.Unlock()
+		cancel()
+	}
+}
+
+// CancelTransfer cancels the transfer id, which may not have started yet.
+func (c *CommunicatorServer) CancelTransfer(id uint32, reply *interface{}) error {
+	c.transfersLock.Lock()
+	defer c.transfersLock.Unlock()
+	if c.transfers == nil {
+		c.transfers = make(map[uint32]context.CancelFunc)
+	}
+	if cancel := c.transfers[id]; cancel != nil {
+		cancel()
+		return nil
+	}
+	c.transfers[id] = nil
+	return nil
+}
+
+func (c *CommunicatorServer) Capabilities(args *interface{}, reply *CommunicatorCapabilitiesResponse) error {
+	reply.Capabilities, reply.Known = packersdk.CapabilitiesOf(c.c)
+	return nil
+}
+
+func (c *CommunicatorServer) Tunnel(args *CommunicatorTunnelArgs, reply *CommunicatorTunnelResponse) error {
+	var t packersdk.Tunnel
+	var err error
+	if args.Reverse {
+		t, err = packersdk.OpenReverseTunnel(context.TODO(), c.c, args.Remote, args.Local)
+	} else {
+		t, err = packersdk.OpenTunnel(context.TODO(), c.c, args.Local, args.Remote)
+	}
+	if errors.Is(err, packersdk.ErrTunnelsNotSupported) {
+		reply.Unsupported = true
+		return nil
+	}
+	if err != nil {
+		reply.Error = NewBasicError(err)
+		return nil
+	}
+
+	c.tunnelsLock.Lock()
+	defer c.tunnelsLock.Unlock()
+	if c.tunnels == nil {
+		c.tunnels = make(map[uint32]packersdk.Tunnel)
+	}
+	c.nextTunnel++
+	c.tunnels[c.nextTunnel] = t
+	reply.ID = c.nextTunnel
+	reply.Addr = t.Addr()
+	return nil
+}
+
+func (c *CommunicatorServer) CloseTunnel(id uint32, reply *interface{}) error {
+	c.tunnelsLock.Lock()
+	t, ok := c.tunnels[id]
+	delete(c.tunnels, id)
+	c.tunnelsLock.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown tunnel %d", id)
+	}
+	return t.Close()
 }
 
 func (c *CommunicatorServer) Download(args *CommunicatorDownloadArgs, reply *interface{}) (err error) {
@@ -320,7 +561,9 @@ func (c *CommunicatorServer) Download(args *CommunicatorDownloadArgs, reply *int
 	}
 	defer writerC.Close()
 
-	err = c.c.Download(args.Path, writerC)
+	ctx, done := c.startTransfer(args.TransferId)
+	defer done()
+	err = packersdk.DownloadContext(ctx, c.c, args.Path, writerC)
 	return
 }
 
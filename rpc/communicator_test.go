@@ -5,10 +5,16 @@ package rpc
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"io"
+	"os"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -169,3 +175,203 @@ func TestCommunicator_ImplementsCommunicator(t *testing.T) {
 		t.Fatal("should be a Communicator")
 	}
 }
+
+type capableMockCommunicator struct {
+	packersdk.MockCommunicator
+}
+
+func (c *capableMockCommunicator) Capabilities() (packersdk.CommunicatorCapabilities, bool) {
+	return packersdk.CommunicatorCapabilities{DownloadDir: true, PTY: true}, true
+}
+
+func TestCommunicatorRPC_Capabilities(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(new(packersdk.MockCommunicator))
+
+	if _, ok := packersdk.CapabilitiesOf(client.Communicator()); ok {
+		t.Fatal("capabilities of a communicator that doesn't report them should be unknown")
+	}
+
+	client, server = testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(new(capableMockCommunicator))
+
+	caps, ok := packersdk.CapabilitiesOf(client.Communicator())
+	if !ok {
+		t.Fatal("capabilities should be known")
+	}
+	if !caps.DownloadDir || !caps.PTY || caps.Env {
+		t.Fatalf("unexpected capabilities: %#v", caps)
+	}
+	if missing := caps.Missing(packersdk.CommunicatorCapabilities{DownloadDir: true, Env: true}); len(missing) != 1 || missing[0] != "environment variables" {
+		t.Fatalf("unexpected missing capabilities: %#v", missing)
+	}
+}
+
+type mockTunnel struct {
+	addr   string
+	closed bool
+}
+
+func (t *mockTunnel) Addr() string { return t.addr }
+
+func (t *mockTunnel) Close() error {
+	t.closed = true
+	return nil
+}
+
+type tunnelMockCommunicator struct {
+	packersdk.MockCommunicator
+	args    []string
+	tunnels []*mockTunnel
+}
+
+func (c *tunnelMockCommunicator) Tunnel(_ context.Context, local, remote string) (packersdk.Tunnel, error) {
+	c.args = append(c.args, "tunnel", local, remote)
+	t := &mockTunnel{addr: "127.0.0.1:4242"}
+	c.tunnels = append(c.tunnels, t)
+	return t, nil
+}
+
+func (c *tunnelMockCommunicator) ReverseTunnel(_ context.Context, remote, local string) (packersdk.Tunnel, error) {
+	return nil, errors.New("remote port in use")
+}
+
+func TestCommunicatorRPC_Tunnel(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	c := new(tunnelMockCommunicator)
+	server.RegisterCommunicator(c)
+
+	tun, err := packersdk.OpenTunnel(context.Background(), client.Communicator(), "127.0.0.1:0", "localhost:8080")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if tun.Addr() != "127.0.0.1:4242" {
+		t.Fatalf("bad addr: %s", tun.Addr())
+	}
+	if !reflect.DeepEqual(c.args, []string{"tunnel", "127.0.0.1:0", "localhost:8080"}) {
+		t.Fatalf("bad args: %#v", c.args)
+	}
+	if err := tun.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !c.tunnels[0].closed {
+		t.Fatal("tunnel should be closed")
+	}
+	if err := tun.Close(); err == nil {
+		t.Fatal("closing an unknown tunnel should fail")
+	}
+
+	_, err = packersdk.OpenReverseTunnel(context.Background(), client.Communicator(), "0.0.0.0:8080", "127.0.0.1:80")
+	if err == nil || err.Error() != "remote port in use" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCommunicatorRPC_TunnelUnsupported(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(new(packersdk.MockCommunicator))
+
+	_, err := packersdk.OpenTunnel(context.Background(), client.Communicator(), "127.0.0.1:0", "localhost:8080")
+	if !errors.Is(err, packersdk.ErrTunnelsNotSupported) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// blockingMockCommunicator transfers until it is cancelled.
+type blockingMockCommunicator struct {
+	packersdk.MockCommunicator
+	cancelled chan string
+}
+
+func (c *blockingMockCommunicator) UploadContext(ctx context.Context, dst string, r io.Reader, fi *os.FileInfo) error {
+	<-ctx.Done()
+	c.cancelled <- "upload " + dst
+	return ctx.Err()
+}
+
+func (c *blockingMockCommunicator) UploadDirContext(ctx context.Context, dst string, src string, exclude []string) error {
+	<-ctx.Done()
+	c.cancelled <- "upload dir " + dst
+	return ctx.Err()
+}
+
+func (c *blockingMockCommunicator) DownloadContext(ctx context.Context, src string, w io.Writer) error {
+	w.Write([]byte("partial"))
+	<-ctx.Done()
+	c.cancelled <- "download " + src
+	return ctx.Err()
+}
+
+func (c *blockingMockCommunicator) DownloadDirContext(ctx context.Context, src string, dst string, exclude []string) error {
+	<-ctx.Done()
+	c.cancelled <- "download dir " + src
+	return ctx.Err()
+}
+
+// cancellingWriter cancels the download writing to it.
+type cancellingWriter struct {
+	buf    bytes.Buffer
+	cancel context.CancelFunc
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	defer w.cancel()
+	return w.buf.Write(p)
+}
+
+func TestCommunicatorRPC_cancelTransfers(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	c := &blockingMockCommunicator{cancelled: make(chan string, 4)}
+	server.RegisterCommunicator(c)
+	remote, ok := client.Communicator().(packersdk.CommunicatorWithContext)
+	if !ok {
+		t.Fatal("should be a CommunicatorWithContext")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancellingWriter{cancel: cancel}
+	if err := remote.DownloadContext(ctx, "/src", w); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the download to be cancelled, got %v", err)
+	}
+	if w.buf.String() != "partial" {
+		t.Fatalf("bad download: %q", w.buf.String())
+	}
+
+	for name, transfer := range map[string]func(context.Context) error{
+		"upload /dst": func(ctx context.Context) error {
+			return remote.UploadContext(ctx, "/dst", strings.NewReader("data"), nil)
+		},
+		"upload dir /dst": func(ctx context.Context) error {
+			return remote.UploadDirContext(ctx, "/dst", "src", nil)
+		},
+		"download dir /src": func(ctx context.Context) error {
+			return remote.DownloadDirContext(ctx, "/src", "dst", nil)
+		},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		if err := transfer(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%s: expected the transfer to be cancelled, got %v", name, err)
+		}
+		cancel()
+	}
+
+	var cancelled []string
+	for i := 0; i < 4; i++ {
+		cancelled = append(cancelled, <-c.cancelled)
+	}
+	sort.Strings(cancelled)
+	expected := []string{"download /src", "download dir /src", "upload /dst", "upload dir /dst"}
+	if !reflect.DeepEqual(cancelled, expected) {
+		t.Fatalf("expected %v to be cancelled, got %v", expected, cancelled)
+	}
+}
@@ -5,8 +5,13 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
@@ -17,10 +22,13 @@ import (
 // executed over an RPC connection.
 type datasource struct {
 	commonClient
+	// noStream is set once the plugin failed to find ExecuteStream.
+	noStream int32
 }
 
 type DatasourceConfigureArgs struct {
 	Configs []interface{}
+	TraceContext
 }
 
 type DatasourceConfigureResponse struct {
@@ -33,7 +41,7 @@ func (d *datasource) Configure(configs ...interface{}) error {
 		return err
 	}
 	var resp DatasourceConfigureResponse
-	if err := d.client.Call(d.endpoint+".Configure", &DatasourceConfigureArgs{Configs: configs}, &resp); err != nil {
+	if err := d.tracedCall(context.Background(), d.endpoint+".Configure", &DatasourceConfigureArgs{Configs: configs}, &resp); err != nil {
 		return err
 	}
 	if resp.Error != nil {
@@ -48,7 +56,7 @@ type OutputSpecResponse struct {
 
 func (d *datasource) OutputSpec() hcldec.ObjectSpec {
 	resp := new(OutputSpecResponse)
-	if err := d.client.Call(d.endpoint+".OutputSpec", new(interface{}), resp); err != nil {
+	if err := d.idempotentCall(d.endpoint+".OutputSpec", new(interface{}), resp); err != nil {
 		err := fmt.Errorf("Datasource.OutputSpec failed: %v", err)
 		panic(err.Error())
 	}
@@ -60,15 +68,61 @@ func (d *datasource) OutputSpec() hcldec.ObjectSpec {
 	return res
 }
 
+// DatasourceExecuteArgs are sent by clients with a context. Plugins built
+// with an SDK predating them ignore them.
+type DatasourceExecuteArgs struct {
+	// ExecutionID identifies the execution to abandon with Cancel.
+	ExecutionID uint32
+	// Deadline is the deadline of the context of the execution, zero when
+	// it has none.
+	Deadline time.Time
+	TraceContext
+}
+
 type ExecuteResponse struct {
 	Value []byte
 	Error *BasicError
 }
 
+// datasourceExecutions numbers the executions of all datasource clients, as
+// they can share the same server.
+var datasourceExecutions uint32
+
 func (d *datasource) Execute() (cty.Value, error) {
+	return d.ExecuteContext(context.Background())
+}
+
+func (d *datasource) ExecuteContext(ctx context.Context) (cty.Value, error) {
+	args := &DatasourceExecuteArgs{ExecutionID: atomic.AddUint32(&datasourceExecutions, 1)}
+	args.Deadline, _ = ctx.Deadline()
+
+	done := make(chan interface{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Printf("Cancelling datasource after context cancellation %v", ctx.Err())
+			if err := d.client.Call(d.endpoint+".Cancel", args.ExecutionID, new(interface{})); err != nil {
+				log.Printf("Error cancelling datasource: %s", err)
+			}
+		case <-done:
+		}
+	}()
+
+	if v, ok, err := d.executeStream(ctx, args); ok {
+		if err != nil && ctx.Err() != nil {
+			return cty.NilVal, ctx.Err()
+		}
+		return v, err
+	}
+
 	res := new(cty.Value)
 	resp := new(ExecuteResponse)
-	if err := d.client.Call(d.endpoint+".Execute", new(interface{}), resp); err != nil {
+	if err := d.tracedCall(ctx, d.endpoint+".Execute", args, resp); err != nil {
+		// Let callers tell a cancelled execution from a failed one.
+		if ctx.Err() != nil {
+			return *res, ctx.Err()
+		}
 		err := fmt.Errorf("Datasource.Execute failed: %v", err)
 		return *res, err
 	}
@@ -82,13 +136,59 @@ func (d *datasource) Execute() (cty.Value, error) {
 	return *res, err
 }
 
+type DatasourceReleaseResponse struct {
+	Error *BasicError
+}
+
+// Release releases the resources of the remote datasource. It does nothing
+// for plugins built with an SDK predating ReleasableDatasource.
+func (d *datasource) Release() error {
+	resp := new(DatasourceReleaseResponse)
+	if err := d.client.Call(d.endpoint+".Release", new(interface{}), resp); err != nil {
+		log.Printf("[DEBUG] Could not release datasource: %s", err)
+		return nil
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
+
+type DatasourceDependenciesResponse struct {
+	Dependencies []string
+}
+
+// Dependencies returns the datasources the remote datasource depends on.
+// Plugins built with an SDK that doesn't support dependencies have none.
+func (d *datasource) Dependencies() []packer.DatasourceRef {
+	resp := new(DatasourceDependenciesResponse)
+	if err := d.idempotentCall(d.endpoint+".Dependencies", new(interface{}), resp); err != nil {
+		log.Printf("[DEBUG] Could not get datasource dependencies: %s", err)
+		return nil
+	}
+	var refs []packer.DatasourceRef
+	for _, dep := range resp.Dependencies {
+		ref, err := packer.ParseDatasourceRef(dep)
+		if err != nil {
+			log.Printf("[ERR] %s", err)
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
 // DatasourceServer wraps a packer.Datasource implementation and makes it
 // exportable as part of a Golang RPC server.
 type DatasourceServer struct {
-	contextCancel func()
-
 	commonServer
 	d packer.Datasource
+
+	l sync.Mutex
+	// executions holds the cancel functions of the running executions.
+	executions map[uint32]context.CancelFunc
+	// cancelled holds the executions cancelled before they started.
+	cancelled map[uint32]bool
 }
 
 func (d *DatasourceServer) Configure(args *DatasourceConfigureArgs, reply *DatasourceConfigureResponse) error {
@@ -96,7 +196,9 @@ func (d *DatasourceServer) Configure(args *DatasourceConfigureArgs, reply *Datas
 	if err != nil {
 		return err
 	}
+	_, span := d.startSpan(context.Background(), "Datasource.Configure", args.TraceContext.TraceContext)
 	err = d.d.Configure(config...)
+	span.End(err)
 	reply.Error = NewBasicError(err)
 	return err
 }
@@ -109,8 +211,12 @@ func (d *DatasourceServer) OutputSpec(args *DatasourceConfigureArgs, reply *Outp
 	return err
 }
 
-func (d *DatasourceServer) Execute(args *interface{}, reply *ExecuteResponse) error {
-	spec, err := d.d.Execute()
+func (d *DatasourceServer) Execute(args *DatasourceExecuteArgs, reply *ExecuteResponse) error {
+	ctx, cancel := d.executionContext(args)
+	defer cancel()
+	ctx, span := d.startSpan(ctx, "Datasource.Execute", args.TraceContext.TraceContext)
+	spec, err := packer.ExecuteDatasource(ctx, d.d)
+	span.End(err)
 	reply.Error = NewBasicError(err)
 	b := bytes.NewBuffer(nil)
 	err = gob.NewEncoder(b).Encode(spec)
@@ -121,10 +227,55 @@ func (d *DatasourceServer) Execute(args *interface{}, reply *ExecuteResponse) er
 	return err
 }
 
-func (d *DatasourceServer) Cancel(args *interface{}, reply *interface{}) error {
-	if d.contextCancel != nil {
-		d.contextCancel()
+func (d *DatasourceServer) Dependencies(args *interface{}, reply *DatasourceDependenciesResponse) error {
+	for _, ref := range packer.DatasourceDependencies(d.d) {
+		reply.Dependencies = append(reply.Dependencies, ref.String())
+	}
+	return nil
+}
+
+func (d *DatasourceServer) Release(args *interface{}, reply *DatasourceReleaseResponse) error {
+	reply.Error = NewBasicError(packer.ReleaseDatasource(d.d))
+	return nil
+}
+
+// executionContext returns the context of an execution, which is done once
+// Cancel is called with its ID or when its deadline expires.
+func (d *DatasourceServer) executionContext(args *DatasourceExecuteArgs) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if !args.Deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(context.Background(), args.Deadline)
+	}
+
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.cancelled[args.ExecutionID] {
+		delete(d.cancelled, args.ExecutionID)
+		cancel()
+	}
+	if d.executions == nil {
+		d.executions = make(map[uint32]context.CancelFunc)
+	}
+	d.executions[args.ExecutionID] = cancel
+	return ctx, func() {
+		d.l.Lock()
+		defer d.l.Unlock()
+		delete(d.executions, args.ExecutionID)
+		cancel()
+	}
+}
+
+func (d *DatasourceServer) Cancel(executionID uint32, reply *interface{}) error {
+	d.l.Lock()
+	defer d.l.Unlock()
+	if cancel, ok := d.executions[executionID]; ok {
+		cancel()
+		return nil
+	}
+	if d.cancelled == nil {
+		d.cancelled = make(map[uint32]bool)
 	}
+	d.cancelled[executionID] = true
 	return nil
 }
 
@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DefaultDatasourceChunkSize is the number of elements of the output of a
+// packer.StreamingDatasource sent at once.
+const DefaultDatasourceChunkSize = 100
+
+const datasourceStreamEndpoint = "DatasourceStream"
+
+// DatasourceExecuteStreamArgs are the arguments of ExecuteStream, which
+// sends the elements of the output of a packer.StreamingDatasource to the
+// DatasourceStream served on StreamID, ChunkSize at a time.
+type DatasourceExecuteStreamArgs struct {
+	DatasourceExecuteArgs
+	StreamID  uint32
+	ChunkSize int
+}
+
+// DatasourceExecuteStreamResponse is the response of ExecuteStream. When
+// the datasource streamed its output, Value is the rest of it, and the
+// elements were sent as Attribute; otherwise Value is the whole output.
+type DatasourceExecuteStreamResponse struct {
+	Value     []byte
+	Streamed  bool
+	Attribute string
+	Error     *BasicError
+}
+
+// DatasourceChunk holds elements of a streamed output, gob encoded.
+type DatasourceChunk struct {
+	Elements []byte
+}
+
+// executeStream executes the remote datasource with ExecuteStream, which
+// transfers large outputs in chunks. It returns false when the plugin
+// predates it.
+func (d *datasource) executeStream(ctx context.Context, args *DatasourceExecuteArgs) (cty.Value, bool, error) {
+	if atomic.LoadInt32(&d.noStream) == 1 {
+		return cty.NilVal, false, nil
+	}
+
+	receiver := &datasourceStreamServer{}
+	nextId := d.mux.NextId()
+	server := newServerWithMux(d.mux, nextId)
+	if err := server.server.RegisterName(datasourceStreamEndpoint, receiver); err != nil {
+		return cty.NilVal, true, err
+	}
+	go server.Serve()
+
+	streamArgs := &DatasourceExecuteStreamArgs{
+		DatasourceExecuteArgs: *args,
+		StreamID:              nextId,
+		ChunkSize:             DefaultDatasourceChunkSize,
+	}
+	resp := new(DatasourceExecuteStreamResponse)
+	if err := d.tracedCall(ctx, d.endpoint+".ExecuteStream", streamArgs, resp); err != nil {
+		if isMethodNotFound(err) {
+			log.Printf("[DEBUG] The plugin does not stream datasource outputs")
+			atomic.StoreInt32(&d.noStream, 1)
+			return cty.NilVal, false, nil
+		}
+		return cty.NilVal, true, err
+	}
+	if resp.Error != nil {
+		return cty.NilVal, true, resp.Error
+	}
+
+	var v cty.Value
+	if err := gob.NewDecoder(bytes.NewReader(resp.Value)).Decode(&v); err != nil {
+		return cty.NilVal, true, err
+	}
+	if !resp.Streamed {
+		return v, true, nil
+	}
+	v, err := packer.SetStreamedAttribute(d.OutputSpec(), v, resp.Attribute, receiver.elements)
+	return v, true, err
+}
+
+// datasourceStreamServer receives the elements of a streamed output.
+type datasourceStreamServer struct {
+	elements []cty.Value
+}
+
+// Send receives a chunk of elements. The plugin sends the chunks one after
+// the other, waiting for each to be received.
+func (s *datasourceStreamServer) Send(chunk *DatasourceChunk, reply *interface{}) error {
+	var elements []cty.Value
+	if err := gob.NewDecoder(bytes.NewReader(chunk.Elements)).Decode(&elements); err != nil {
+		return err
+	}
+	s.elements = append(s.elements, elements...)
+	*reply = nil
+	return nil
+}
+
+// ExecuteStream executes the datasource, sending the elements of its output
+// in chunks when it is a packer.StreamingDatasource. Only one chunk is held
+// at a time.
+func (d *DatasourceServer) ExecuteStream(args *DatasourceExecuteStreamArgs, reply *DatasourceExecuteStreamResponse) error {
+	client, err := newClientWithMux(d.mux, args.StreamID)
+	if err != nil {
+		return NewBasicError(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := d.executionContext(&args.DatasourceExecuteArgs)
+	defer cancel()
+	ctx, span := d.startSpan(ctx, "Datasource.ExecuteStream", args.TraceContext.TraceContext)
+
+	var v cty.Value
+	streaming, ok := d.d.(packer.StreamingDatasource)
+	if ok {
+		v, err = streamDatasource(ctx, streaming, client, args.ChunkSize)
+		reply.Streamed = true
+		reply.Attribute = streaming.StreamedAttribute()
+	} else {
+		v, err = packer.ExecuteDatasource(ctx, d.d)
+	}
+	span.End(err)
+	if err != nil {
+		reply.Error = NewBasicError(err)
+		return nil
+	}
+
+	b := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(b).Encode(v); err != nil {
+		return err
+	}
+	reply.Value = b.Bytes()
+	return nil
+}
+
+// streamDatasource executes d, sending its elements to client chunkSize at a
+// time, and returns the rest of its output.
+func streamDatasource(ctx context.Context, d packer.StreamingDatasource, client *Client, chunkSize int) (cty.Value, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultDatasourceChunkSize
+	}
+	chunk := make([]cty.Value, 0, chunkSize)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		b := bytes.NewBuffer(nil)
+		if err := gob.NewEncoder(b).Encode(chunk); err != nil {
+			return err
+		}
+		chunk = chunk[:0]
+		if err := client.client.Call(datasourceStreamEndpoint+".Send", &DatasourceChunk{Elements: b.Bytes()}, new(interface{})); err != nil {
+			return fmt.Errorf("error sending datasource output: %s", err)
+		}
+		return nil
+	}
+
+	rest, err := d.ExecuteStream(ctx, func(element cty.Value) error {
+		chunk = append(chunk, element)
+		if len(chunk) < chunkSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return rest, flush()
+}
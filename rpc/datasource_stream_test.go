@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type streamingDatasource struct {
+	testDatasource
+
+	count int
+}
+
+func (d *streamingDatasource) OutputSpec() hcldec.ObjectSpec {
+	return hcldec.ObjectSpec{
+		"region": &hcldec.AttrSpec{Name: "region", Type: cty.String},
+		"images": &hcldec.AttrSpec{Name: "images", Type: cty.List(cty.Object(map[string]cty.Type{"id": cty.String}))},
+	}
+}
+
+func (d *streamingDatasource) StreamedAttribute() string { return "images" }
+
+func (d *streamingDatasource) ExecuteStream(ctx context.Context, send func(cty.Value) error) (cty.Value, error) {
+	for i := 0; i < d.count; i++ {
+		image := cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal(fmt.Sprintf("ami-%d", i))})
+		if err := send(image); err != nil {
+			return cty.NilVal, err
+		}
+	}
+	return cty.ObjectVal(map[string]cty.Value{"region": cty.StringVal("eu-west-1")}), nil
+}
+
+func checkStreamedOutput(t *testing.T, v cty.Value, count int) {
+	t.Helper()
+	if region := v.GetAttr("region"); !region.RawEquals(cty.StringVal("eu-west-1")) {
+		t.Fatalf("bad region: %#v", region)
+	}
+	images := v.GetAttr("images")
+	if !images.Type().IsListType() || images.LengthInt() != count {
+		t.Fatalf("bad images: %#v", images)
+	}
+	for i, image := range images.AsValueSlice() {
+		if id := image.GetAttr("id").AsString(); id != fmt.Sprintf("ami-%d", i) {
+			t.Fatalf("bad image %d: %s", i, id)
+		}
+	}
+}
+
+func TestDatasource_ExecuteStream(t *testing.T) {
+	d := &streamingDatasource{count: 2*DefaultDatasourceChunkSize + 50}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterDatasource(d)
+
+	v, err := client.Datasource().Execute()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	checkStreamedOutput(t, v, d.count)
+
+	// Datasources that don't stream their output are executed at once.
+	plain := &testDatasource{executeValue: cty.StringVal("foo")}
+	client, server = testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterDatasource(plain)
+	v, err = client.Datasource().Execute()
+	if err != nil || !plain.executeCalled || !v.RawEquals(cty.StringVal("foo")) {
+		t.Fatalf("unexpected result: %#v, %v", v, err)
+	}
+}
+
+func TestGRPCDatasource_ExecuteStream(t *testing.T) {
+	d := &streamingDatasource{count: 2*DefaultDatasourceChunkSize + 50}
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterDatasource(d) })
+
+	v, err := client.Datasource().Execute()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	checkStreamedOutput(t, v, d.count)
+
+	plain := &testDatasource{executeValue: cty.StringVal("foo")}
+	client = testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterDatasource(plain) })
+	v, err = client.Datasource().Execute()
+	if err != nil || !plain.executeCalled || !v.RawEquals(cty.StringVal("foo")) {
+		t.Fatalf("unexpected result: %#v, %v", v, err)
+	}
+}
@@ -4,8 +4,11 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
@@ -94,3 +97,106 @@ func TestDatasource_Implements(t *testing.T) {
 		t.Fatal("not a datasource")
 	}
 }
+
+type testDependentDatasource struct {
+	testDatasource
+}
+
+func (*testDependentDatasource) Dependencies() []packer.DatasourceRef {
+	return []packer.DatasourceRef{{Type: "amazon-ami", Name: "base"}}
+}
+
+func TestDatasource_Dependencies(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterDatasource(new(testDatasource))
+
+	if deps := packer.DatasourceDependencies(client.Datasource()); len(deps) != 0 {
+		t.Fatalf("unexpected dependencies: %#v", deps)
+	}
+
+	client, server = testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterDatasource(new(testDependentDatasource))
+
+	deps := packer.DatasourceDependencies(client.Datasource())
+	expected := []packer.DatasourceRef{{Type: "amazon-ami", Name: "base"}}
+	if !reflect.DeepEqual(deps, expected) {
+		t.Fatalf("unexpected dependencies: %#v", deps)
+	}
+}
+
+func TestDatasource_Release(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterDatasource(new(testDatasource))
+
+	// Datasources without ephemeral resources have nothing to release.
+	if err := packer.ReleaseDatasource(client.Datasource()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	d := new(packer.MockDatasource)
+	client, server = testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterDatasource(d)
+
+	if err := packer.ReleaseDatasource(client.Datasource()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !d.ReleaseCalled {
+		t.Fatal("release should be called")
+	}
+}
+
+type contextDatasource struct {
+	testDatasource
+
+	deadline time.Time
+}
+
+func (d *contextDatasource) ExecuteContext(ctx context.Context) (cty.Value, error) {
+	d.deadline, _ = ctx.Deadline()
+	<-ctx.Done()
+	return cty.NilVal, ctx.Err()
+}
+
+func TestDatasource_ExecuteContext(t *testing.T) {
+	d := new(contextDatasource)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterDatasource(d)
+	dsClient := client.Datasource()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := packer.ExecuteDatasource(ctx, dsClient); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+
+	deadline := time.Now().Add(50 * time.Millisecond)
+	ctx, cancel = context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if _, err := packer.ExecuteDatasource(ctx, dsClient); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if !d.deadline.Equal(deadline) {
+		t.Fatalf("the deadline was not propagated: %s", d.deadline)
+	}
+}
+
+func TestDatasourceServer_CancelBeforeExecute(t *testing.T) {
+	d := &DatasourceServer{d: new(contextDatasource)}
+	if err := d.Cancel(7, new(interface{})); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := d.Execute(&DatasourceExecuteArgs{ExecutionID: 7}, new(ExecuteResponse))
+	if err == nil || err.Error() != context.Canceled.Error() {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
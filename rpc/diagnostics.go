@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Diagnostic is an hcl.Diagnostic that can be sent over RPC: its expression,
+// evaluation context and extra information are not encodable, only the
+// attribute set with packersdk.AttributeDiagnostic is kept.
+type Diagnostic struct {
+	Severity  hcl.DiagnosticSeverity
+	Summary   string
+	Detail    string
+	Subject   *hcl.Range
+	Context   *hcl.Range
+	Attribute string
+}
+
+func newDiagnostics(diags hcl.Diagnostics) []Diagnostic {
+	if len(diags) == 0 {
+		return nil
+	}
+	res := make([]Diagnostic, 0, len(diags))
+	for _, diag := range diags {
+		d := Diagnostic{
+			Severity: diag.Severity,
+			Summary:  diag.Summary,
+			Detail:   diag.Detail,
+			Subject:  diag.Subject,
+			Context:  diag.Context,
+		}
+		d.Attribute, _ = packersdk.DiagnosticAttributeName(diag)
+		res = append(res, d)
+	}
+	return res
+}
+
+func hclDiagnostics(diags []Diagnostic) hcl.Diagnostics {
+	if len(diags) == 0 {
+		return nil
+	}
+	res := make(hcl.Diagnostics, 0, len(diags))
+	for _, d := range diags {
+		diag := &hcl.Diagnostic{
+			Severity: d.Severity,
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+			Subject:  d.Subject,
+			Context:  d.Context,
+		}
+		if d.Attribute != "" {
+			diag.Extra = packersdk.DiagnosticAttribute{Name: d.Attribute}
+		}
+		res = append(res, diag)
+	}
+	return res
+}
+
+// encodePrepareResult splits what Prepare or Configure returned into the
+// warnings and the error understood by every version of Packer, and the
+// diagnostics for the versions understanding them, which are only set when
+// the component returned a packersdk.DiagnosticsError.
+func encodePrepareResult(warnings []string, err error) ([]string, *BasicError, []Diagnostic) {
+	var derr *packersdk.DiagnosticsError
+	if !errors.As(err, &derr) {
+		return warnings, NewBasicError(err), nil
+	}
+	legacyWarnings := append([]string(nil), warnings...)
+	for _, diag := range derr.Diagnostics {
+		if diag.Severity == hcl.DiagWarning {
+			legacyWarnings = append(legacyWarnings, strings.TrimSuffix(diag.Summary+": "+diag.Detail, ": "))
+		}
+	}
+	var berr *BasicError
+	if derr.Diagnostics.HasErrors() {
+		berr = NewBasicError(err)
+	}
+	return legacyWarnings, berr, newDiagnostics(packersdk.PrepareDiagnostics(warnings, err))
+}
+
+// decodePrepareResult is the counterpart of encodePrepareResult. When the
+// plugin sent diagnostics, they are returned as a packersdk.DiagnosticsError
+// holding the warnings too.
+func decodePrepareResult(warnings []string, berr *BasicError, diags []Diagnostic) ([]string, error) {
+	if len(diags) > 0 {
+		return nil, &packersdk.DiagnosticsError{Diagnostics: hclDiagnostics(diags)}
+	}
+	if berr != nil {
+		return warnings, berr
+	}
+	return warnings, nil
+}
+
+// isUnknownMethod tells whether err was returned because the plugin does not
+// implement the called method, typically because it was built with an older
+// SDK.
+func isUnknownMethod(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "rpc: can't find method")
+}
+
+// PrepareDiagnosticsResponse is the response of the PrepareWithDiagnostics
+// and ConfigureWithDiagnostics calls of provisioners and post-processors,
+// whose legacy Prepare and Configure calls can only return an error.
+type PrepareDiagnosticsResponse struct {
+	Error       *BasicError
+	Diagnostics []Diagnostic
+}
+
+// legacyPrepareError returns the error to send to the versions of Packer
+// that don't understand diagnostics, nil when err only holds warnings.
+func legacyPrepareError(err error) error {
+	if _, berr, _ := encodePrepareResult(nil, err); berr != nil {
+		return berr
+	}
+	return nil
+}
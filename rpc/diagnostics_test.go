@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type diagnosticsBuilder struct {
+	packersdk.MockBuilder
+
+	diags hcl.Diagnostics
+}
+
+func (b *diagnosticsBuilder) Prepare(config ...interface{}) ([]string, []string, error) {
+	b.MockBuilder.Prepare(config...)
+	return nil, []string{"plain warning"}, &packersdk.DiagnosticsError{Diagnostics: b.diags}
+}
+
+type diagnosticsProvisioner struct {
+	packersdk.MockProvisioner
+
+	diags hcl.Diagnostics
+}
+
+func (p *diagnosticsProvisioner) Prepare(configs ...interface{}) error {
+	p.MockProvisioner.Prepare(configs...)
+	return &packersdk.DiagnosticsError{Diagnostics: p.diags}
+}
+
+func TestBuilderPrepare_Diagnostics(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	b := &diagnosticsBuilder{diags: hcl.Diagnostics{
+		packersdk.AttributeDiagnostic(hcl.DiagWarning, "iso_checksum", "Checksum not verified", "Set iso_checksum to verify the ISO."),
+	}}
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	_, warnings, err := bClient.Prepare(map[string]interface{}{})
+	diags := packersdk.PrepareDiagnostics(warnings, err)
+	if diags.HasErrors() {
+		t.Fatalf("warnings only should not fail: %s", diags)
+	}
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %#v", diags)
+	}
+	if diags[0].Summary != "plain warning" {
+		t.Fatalf("bad diagnostic: %#v", diags[0])
+	}
+	if name, _ := packersdk.DiagnosticAttributeName(diags[1]); name != "iso_checksum" || diags[1].Detail != "Set iso_checksum to verify the ISO." {
+		t.Fatalf("bad diagnostic: %#v", diags[1])
+	}
+
+	// Versions of Packer that don't know about diagnostics get strings.
+	var resp BuilderPrepareResponse
+	bs := &BuilderServer{builder: b}
+	if err := bs.Prepare(&BuilderPrepareArgs{}, &resp); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{"plain warning", "Checksum not verified: Set iso_checksum to verify the ISO."}
+	if resp.Error != nil || !reflect.DeepEqual(resp.Warnings, expected) {
+		t.Fatalf("bad legacy result: %#v, %#v", resp.Warnings, resp.Error)
+	}
+}
+
+func TestProvisionerPrepare_Diagnostics(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	p := &diagnosticsProvisioner{diags: hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Missing script",
+			Subject:  &hcl.Range{Filename: "build.pkr.hcl", Start: hcl.Pos{Line: 12, Column: 5}},
+		},
+	}}
+	server.RegisterProvisioner(p)
+
+	err := client.Provisioner().Prepare(map[string]interface{}{})
+	var derr *packersdk.DiagnosticsError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected diagnostics, got %#v", err)
+	}
+	diags := packersdk.PrepareDiagnostics(nil, err)
+	if !diags.HasErrors() || diags[0].Subject == nil || diags[0].Subject.Start.Line != 12 {
+		t.Fatalf("bad diagnostics: %#v", diags)
+	}
+
+	// Versions of Packer that don't know about diagnostics get an error.
+	ps := &ProvisionerServer{p: p}
+	if err := ps.Prepare(&ProvisionerPrepareArgs{}, new(interface{})); err == nil {
+		t.Fatal("expected an error")
+	}
+	p.diags[0].Severity = hcl.DiagWarning
+	if err := ps.Prepare(&ProvisionerPrepareArgs{}, new(interface{})); err != nil {
+		t.Fatalf("warnings only should not fail: %s", err)
+	}
+}
+
+type nativeDiagnosticsBuilder struct {
+	packersdk.MockBuilder
+
+	diags hcl.Diagnostics
+}
+
+func (b *nativeDiagnosticsBuilder) Prepare(config ...interface{}) ([]string, []string, error) {
+	return packersdk.LegacyPrepare(b, config...)
+}
+
+func (b *nativeDiagnosticsBuilder) PrepareWithDiagnostics(...interface{}) ([]string, hcl.Diagnostics) {
+	return []string{"SourceImage"}, b.diags
+}
+
+func TestBuilderPrepareWithDiagnostics(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	b := &nativeDiagnosticsBuilder{diags: hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported region",
+			Detail:   "mars-1 is not a region.",
+			Subject:  &hcl.Range{Filename: "build.pkr.hcl", Start: hcl.Pos{Line: 3, Column: 12}},
+		},
+	}}
+	server.RegisterBuilder(b)
+
+	generated, diags := packersdk.PrepareBuilder(client.Builder(), map[string]interface{}{})
+	if !reflect.DeepEqual(generated, []string{"SourceImage"}) {
+		t.Fatalf("bad generated vars: %v", generated)
+	}
+	if !diags.HasErrors() || len(diags) != 1 || diags[0].Detail != "mars-1 is not a region." || diags[0].Subject.Start.Line != 3 {
+		t.Fatalf("bad diagnostics: %#v", diags)
+	}
+
+	// Warnings only don't fail.
+	b.diags[0].Severity = hcl.DiagWarning
+	if _, diags := packersdk.PrepareBuilder(client.Builder(), map[string]interface{}{}); diags.HasErrors() || len(diags) != 1 {
+		t.Fatalf("bad diagnostics: %#v", diags)
+	}
+}
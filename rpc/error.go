@@ -3,6 +3,8 @@
 
 package rpc
 
+import "strings"
+
 // This is a type that wraps error types so that they can be messaged
 // across RPC channels. Since "error" is an interface, we can't always
 // gob-encode the underlying structure. This is a valid error interface
@@ -22,3 +24,9 @@ func NewBasicError(err error) *BasicError {
 func (e *BasicError) Error() string {
 	return e.Message
 }
+
+// isMethodNotFound returns whether err is the error of a call to a method
+// the server doesn't have, as when a newer plugin talks to an older Packer.
+func isMethodNotFound(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "rpc: can't find method")
+}
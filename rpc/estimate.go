@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"log"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type BuilderEstimateResponse struct {
+	Estimate *packersdk.Estimate
+	Error    *BasicError
+}
+
+var _ packersdk.OptionalBuilderWithEstimate = new(builder)
+
+// SupportsEstimate tells whether the remote builder can estimate its builds.
+// It is false for plugins built with an SDK predating
+// packersdk.BuilderWithEstimate.
+func (b *builder) SupportsEstimate() bool {
+	var supported bool
+	if err := b.client.Call(b.endpoint+".SupportsEstimate", new(interface{}), &supported); err != nil {
+		log.Printf("[DEBUG] %s does not support Estimate: %s", b.endpoint, err)
+		return false
+	}
+	return supported
+}
+
+func (b *builder) Estimate() (*packersdk.Estimate, error) {
+	resp := new(BuilderEstimateResponse)
+	if err := b.client.Call(b.endpoint+".Estimate", new(interface{}), resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Estimate, nil
+}
+
+func (b *BuilderServer) SupportsEstimate(_ interface{}, reply *bool) error {
+	_, *reply = b.builder.(packersdk.BuilderWithEstimate)
+	return nil
+}
+
+func (b *BuilderServer) Estimate(_ interface{}, reply *BuilderEstimateResponse) error {
+	e, ok := b.builder.(packersdk.BuilderWithEstimate)
+	if !ok {
+		return nil
+	}
+	estimate, err := e.Estimate()
+	*reply = BuilderEstimateResponse{
+		Estimate: estimate,
+		Error:    NewBasicError(err),
+	}
+	return nil
+}
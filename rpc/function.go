@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// An implementation of packer.Function where the function is actually
+// executed over an RPC connection.
+type function struct {
+	commonClient
+}
+
+type FunctionSpecResponse struct {
+	Spec []byte
+}
+
+func (f *function) Spec() packer.FunctionSpec {
+	resp := new(FunctionSpecResponse)
+	if err := f.idempotentCall(f.endpoint+".Spec", new(interface{}), resp); err != nil {
+		err := fmt.Errorf("Function.Spec failed: %v", err)
+		panic(err.Error())
+	}
+	res := packer.FunctionSpec{}
+	if err := gob.NewDecoder(bytes.NewReader(resp.Spec)).Decode(&res); err != nil {
+		panic(fmt.Sprintf("Function.Spec decoding failed: %s", err))
+	}
+	return res
+}
+
+type FunctionCallArgs struct {
+	Args []byte
+}
+
+type FunctionCallResponse struct {
+	Value []byte
+	Error *BasicError
+}
+
+func (f *function) Call(args []cty.Value) (cty.Value, error) {
+	b := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(b).Encode(args); err != nil {
+		return cty.NilVal, err
+	}
+
+	resp := new(FunctionCallResponse)
+	if err := f.client.Call(f.endpoint+".Call", &FunctionCallArgs{Args: b.Bytes()}, resp); err != nil {
+		return cty.NilVal, fmt.Errorf("Function.Call failed: %v", err)
+	}
+	if resp.Error != nil {
+		return cty.NilVal, resp.Error
+	}
+	res := cty.NilVal
+	if err := gob.NewDecoder(bytes.NewReader(resp.Value)).Decode(&res); err != nil {
+		return cty.NilVal, err
+	}
+	return res, nil
+}
+
+// FunctionServer wraps a packer.Function implementation and makes it
+// exportable as part of a Golang RPC server.
+type FunctionServer struct {
+	f packer.Function
+}
+
+func (f *FunctionServer) Spec(args *interface{}, reply *FunctionSpecResponse) error {
+	b := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(b).Encode(f.f.Spec())
+	reply.Spec = b.Bytes()
+	return err
+}
+
+func (f *FunctionServer) Call(args *FunctionCallArgs, reply *FunctionCallResponse) error {
+	var values []cty.Value
+	if err := gob.NewDecoder(bytes.NewReader(args.Args)).Decode(&values); err != nil {
+		return err
+	}
+
+	res, err := f.f.Call(values)
+	if err != nil {
+		reply.Error = NewBasicError(err)
+		return nil
+	}
+	b := bytes.NewBuffer(nil)
+	err = gob.NewEncoder(b).Encode(res)
+	reply.Value = b.Bytes()
+	return err
+}
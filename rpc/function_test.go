@@ -0,0 +1,73 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testFunction joins its arguments with a separator.
+type testFunction struct{}
+
+func (*testFunction) Spec() packer.FunctionSpec {
+	return packer.FunctionSpec{
+		Description: "joins strings",
+		Params: []packer.FunctionParam{
+			{Name: "separator", Type: cty.String},
+		},
+		VarParam: &packer.FunctionParam{Name: "elems", Type: cty.String},
+		Return:   cty.String,
+	}
+}
+
+func (*testFunction) Call(args []cty.Value) (cty.Value, error) {
+	if len(args) < 2 {
+		return cty.NilVal, fmt.Errorf("nothing to join")
+	}
+	var elems []string
+	for _, arg := range args[1:] {
+		elems = append(elems, arg.AsString())
+	}
+	return cty.StringVal(strings.Join(elems, args[0].AsString())), nil
+}
+
+func TestFunction(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterFunction(new(testFunction))
+	fnClient := client.Function()
+
+	spec := fnClient.Spec()
+	if spec.Description != "joins strings" || len(spec.Params) != 1 || spec.VarParam == nil ||
+		!spec.Return.Equals(cty.String) || !spec.Params[0].Type.Equals(cty.String) {
+		t.Fatalf("unexpected spec: %#v", spec)
+	}
+
+	// Call it through HCL2, as Packer would.
+	fn := packer.HCL2Function(fnClient)
+	res, err := fn.Call([]cty.Value{cty.StringVal("-"), cty.StringVal("a"), cty.StringVal("b")})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !res.RawEquals(cty.StringVal("a-b")) {
+		t.Fatalf("unexpected result: %#v", res)
+	}
+
+	if _, err := fnClient.Call([]cty.Value{cty.StringVal("-")}); err == nil || err.Error() != "nothing to join" {
+		t.Fatalf("expected the function error to be returned, got: %v", err)
+	}
+}
+
+func TestFunction_Implements(t *testing.T) {
+	var raw interface{} = new(function)
+	if _, ok := raw.(packer.Function); !ok {
+		t.Fatal("not a function")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// DefaultGeneratedDataEndpoint is the endpoint of the typed generated data of
+// a build, see RegisterGeneratedData.
+const DefaultGeneratedDataEndpoint string = "GeneratedData"
+
+type GeneratedDataSchemaResponse struct {
+	// Types are the JSON encoded types of the keys.
+	Types map[string][]byte
+}
+
+type GeneratedDataGetArgs struct {
+	Key string
+}
+
+type GeneratedDataGetResponse struct {
+	Set   bool
+	Type  []byte
+	Value []byte
+}
+
+// An implementation of packerbuilderdata.Reader reading the generated data
+// over an RPC connection.
+type generatedData struct {
+	commonClient
+}
+
+// GeneratedDataServer wraps a packerbuilderdata.Reader, like the Store of a
+// build, and makes it exportable as part of a Golang RPC server.
+type GeneratedDataServer struct {
+	reader packerbuilderdata.Reader
+}
+
+// RegisterGeneratedData serves r, for provisioners and post-processors to
+// read the typed generated data of a build with Client.GeneratedData.
+func (s *PluginServer) RegisterGeneratedData(r packerbuilderdata.Reader) error {
+	return s.server.RegisterName(DefaultGeneratedDataEndpoint, &GeneratedDataServer{reader: r})
+}
+
+// GeneratedData returns the generated data served with
+// RegisterGeneratedData. Errors are logged, and read as an empty schema or
+// unset values.
+func (c *Client) GeneratedData() packerbuilderdata.Reader {
+	return &generatedData{
+		commonClient: commonClient{
+			endpoint: DefaultGeneratedDataEndpoint,
+			client:   c.client,
+			mux:      c.mux,
+		},
+	}
+}
+
+func (g *generatedData) Schema() packerbuilderdata.Schema {
+	resp := new(GeneratedDataSchemaResponse)
+	if err := g.client.Call(g.endpoint+".Schema", new(interface{}), resp); err != nil {
+		log.Printf("[ERR] Error getting the schema of the generated data: %s", err)
+		return nil
+	}
+	schema := packerbuilderdata.Schema{}
+	for key, raw := range resp.Types {
+		t, err := ctyjson.UnmarshalType(raw)
+		if err != nil {
+			log.Printf("[ERR] Error decoding the type of the generated data %q: %s", key, err)
+			continue
+		}
+		schema[key] = t
+	}
+	return schema
+}
+
+func (g *generatedData) Get(key string) (cty.Value, bool) {
+	resp := new(GeneratedDataGetResponse)
+	if err := g.client.Call(g.endpoint+".Get", &GeneratedDataGetArgs{Key: key}, resp); err != nil {
+		log.Printf("[ERR] Error getting the generated data %q: %s", key, err)
+		return cty.NilVal, false
+	}
+	if !resp.Set {
+		return cty.NilVal, false
+	}
+	t, err := ctyjson.UnmarshalType(resp.Type)
+	if err == nil {
+		var v cty.Value
+		if v, err = ctyjson.Unmarshal(resp.Value, t); err == nil {
+			return v, true
+		}
+	}
+	log.Printf("[ERR] Error decoding the generated data %q: %s", key, err)
+	return cty.NilVal, false
+}
+
+func (s *GeneratedDataServer) Schema(_ interface{}, reply *GeneratedDataSchemaResponse) error {
+	reply.Types = map[string][]byte{}
+	for key, t := range s.reader.Schema() {
+		raw, err := ctyjson.MarshalType(t)
+		if err != nil {
+			return NewBasicError(fmt.Errorf("%s: %s", key, err))
+		}
+		reply.Types[key] = raw
+	}
+	return nil
+}
+
+func (s *GeneratedDataServer) Get(args *GeneratedDataGetArgs, reply *GeneratedDataGetResponse) error {
+	v, ok := s.reader.Get(args.Key)
+	if !ok {
+		return nil
+	}
+	t, err := ctyjson.MarshalType(v.Type())
+	if err != nil {
+		return NewBasicError(err)
+	}
+	value, err := ctyjson.Marshal(v, v.Type())
+	if err != nil {
+		return NewBasicError(err)
+	}
+	reply.Set, reply.Type, reply.Value = true, t, value
+	return nil
+}
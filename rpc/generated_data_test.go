@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestGeneratedData_RPC(t *testing.T) {
+	store := packerbuilderdata.NewStore(packerbuilderdata.Schema{
+		"InstanceId": cty.String,
+		"PrivateIPs": cty.List(cty.String),
+	})
+	if err := store.Set("PrivateIPs", cty.TupleVal([]cty.Value{cty.StringVal("10.0.0.2")})); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	if err := server.RegisterGeneratedData(store); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	remote := client.GeneratedData()
+
+	schema := remote.Schema()
+	if len(schema) != 2 || !schema["PrivateIPs"].Equals(cty.List(cty.String)) {
+		t.Fatalf("bad schema: %#v", schema)
+	}
+	v, ok := remote.Get("PrivateIPs")
+	if !ok || !v.RawEquals(cty.ListVal([]cty.Value{cty.StringVal("10.0.0.2")})) {
+		t.Fatalf("bad value: %#v", v)
+	}
+	if _, ok := remote.Get("InstanceId"); ok {
+		t.Fatalf("InstanceId should not be set")
+	}
+}
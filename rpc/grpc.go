@@ -0,0 +1,277 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"github.com/hashicorp/yamux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// grpcBroker is one end of a gRPC plugin connection. It serves the objects
+// this end passes to the other one, like a Ui or a Communicator, under an ID,
+// and creates the clients of the objects the other end passed to this one.
+type grpcBroker struct {
+	session *yamux.Session
+	server  *grpc.Server
+	conn    *grpc.ClientConn
+
+	sync.Mutex
+	nextId  uint32
+	objects map[uint32]interface{}
+	// base, when set, cancels the calls served by the broker once done.
+	base context.Context
+}
+
+func newGRPCBroker(session *yamux.Session) (*grpcBroker, error) {
+	conn, err := grpc.Dial("yamux",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(traceClientCall),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return session.Open()
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	b := &grpcBroker{
+		session: session,
+		conn:    conn,
+		objects: make(map[uint32]interface{}),
+	}
+	b.server = grpc.NewServer(grpc.ChainUnaryInterceptor(b.withBaseContext, traceServerCall))
+	pluginproto.RegisterUiServer(b.server, &grpcUiServer{broker: b})
+	pluginproto.RegisterHookServer(b.server, &grpcHookServer{broker: b})
+	pluginproto.RegisterCommunicatorServer(b.server, &grpcCommunicatorServer{broker: b})
+	pluginproto.RegisterArtifactServer(b.server, &grpcArtifactServer{broker: b})
+	return b, nil
+}
+
+// withBaseContext cancels the context of the unary calls served by b once
+// its base context is done. The context also carries the namespace of the
+// call.
+func (b *grpcBroker) withBaseContext(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = grpcNamespace(ctx)
+	b.Lock()
+	base := b.base
+	b.Unlock()
+	if base == nil {
+		return handler(ctx, req)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(base, cancel)
+	defer stop()
+	return handler(ctx, req)
+}
+
+// serve serves the calls of the other end until the connection is closed.
+func (b *grpcBroker) serve() {
+	if err := b.server.Serve(b.session); err != nil && !b.session.IsClosed() {
+		log.Printf("[ERR] Error serving gRPC: %s", err)
+	}
+}
+
+func (b *grpcBroker) Close() error {
+	b.server.Stop()
+	b.conn.Close()
+	return b.session.Close()
+}
+
+// register makes o callable by the other end and returns its ID, 0 when o is
+// nil.
+func (b *grpcBroker) register(o interface{}) uint32 {
+	if o == nil {
+		return 0
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.nextId++
+	b.objects[b.nextId] = o
+	return b.nextId
+}
+
+func (b *grpcBroker) unregister(id uint32) {
+	b.Lock()
+	defer b.Unlock()
+	delete(b.objects, id)
+}
+
+func (b *grpcBroker) lookup(id uint32) (interface{}, error) {
+	b.Lock()
+	defer b.Unlock()
+	o, ok := b.objects[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no object with ID %d", id)
+	}
+	return o, nil
+}
+
+func (b *grpcBroker) ui(id uint32) packer.Ui {
+	if id == 0 {
+		return nil
+	}
+	return &grpcUi{client: pluginproto.NewUiClient(b.conn), id: id}
+}
+
+func (b *grpcBroker) hook(id uint32) packer.Hook {
+	if id == 0 {
+		return nil
+	}
+	return &grpcHook{client: pluginproto.NewHookClient(b.conn), broker: b, id: id}
+}
+
+func (b *grpcBroker) communicator(id uint32) packer.Communicator {
+	if id == 0 {
+		return nil
+	}
+	return &grpcCommunicator{client: pluginproto.NewCommunicatorClient(b.conn), id: id}
+}
+
+func (b *grpcBroker) artifact(id uint32) packer.Artifact {
+	if id == 0 {
+		return nil
+	}
+	return &grpcArtifact{client: pluginproto.NewArtifactClient(b.conn), id: id}
+}
+
+// grpcError turns the error of a gRPC call back into the error returned by
+// the remote component.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	s := status.Convert(err)
+	switch s.Code() {
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	}
+	return errors.New(s.Message())
+}
+
+// GRPCServer is the plugin end of a gRPC connection with Packer, the
+// counterpart of a GRPCClient. It is the gRPC alternative to PluginServer,
+// which plugins use when Packer supports it.
+type GRPCServer struct {
+	broker *grpcBroker
+}
+
+// NewGRPCServer returns a gRPC server serving over conn. The components
+// must be registered before calling Serve.
+func NewGRPCServer(conn io.ReadWriteCloser) (*GRPCServer, error) {
+	session, err := yamux.Server(conn, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating yamux server: %s", err)
+	}
+	broker, err := newGRPCBroker(session)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &GRPCServer{broker: broker}, nil
+}
+
+// SetContext makes the calls served by s, like the builds, cancelled when
+// ctx is done, as when Packer cancels them.
+func (s *GRPCServer) SetContext(ctx context.Context) {
+	s.broker.Lock()
+	defer s.broker.Unlock()
+	s.broker.base = ctx
+}
+
+func (s *GRPCServer) RegisterBuilder(b packer.Builder) {
+	pluginproto.RegisterBuilderServer(s.broker.server, &grpcBuilderServer{
+		grpcComponentServer: grpcComponentServer{component: b},
+		broker:              s.broker,
+		builder:             b,
+	})
+}
+
+func (s *GRPCServer) RegisterProvisioner(p packer.Provisioner) {
+	pluginproto.RegisterProvisionerServer(s.broker.server, &grpcProvisionerServer{
+		grpcComponentServer: grpcComponentServer{component: p},
+		broker:              s.broker,
+		p:                   p,
+	})
+}
+
+func (s *GRPCServer) RegisterPostProcessor(p packer.PostProcessor) {
+	pluginproto.RegisterPostProcessorServer(s.broker.server, &grpcPostProcessorServer{
+		grpcComponentServer: grpcComponentServer{component: p},
+		broker:              s.broker,
+		p:                   p,
+	})
+}
+
+func (s *GRPCServer) RegisterDatasource(d packer.Datasource) {
+	pluginproto.RegisterDatasourceServer(s.broker.server, &grpcDatasourceServer{
+		grpcComponentServer: grpcComponentServer{component: d},
+		d:                   d,
+	})
+}
+
+// Serve serves Packer until the connection is closed.
+func (s *GRPCServer) Serve() {
+	s.broker.serve()
+}
+
+func (s *GRPCServer) Close() error {
+	return s.broker.Close()
+}
+
+// GRPCClient is the Packer end of a gRPC connection with a plugin, the
+// counterpart of a GRPCServer.
+type GRPCClient struct {
+	broker *grpcBroker
+}
+
+// NewGRPCClient returns a client of the components served over conn by a
+// GRPCServer.
+func NewGRPCClient(conn io.ReadWriteCloser) (*GRPCClient, error) {
+	session, err := yamux.Client(conn, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating yamux client: %s", err)
+	}
+	broker, err := newGRPCBroker(session)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	go broker.serve()
+	return &GRPCClient{broker: broker}, nil
+}
+
+func (c *GRPCClient) Builder() packer.Builder {
+	return newGRPCBuilder(c.broker)
+}
+
+func (c *GRPCClient) Provisioner() packer.Provisioner {
+	return newGRPCProvisioner(c.broker)
+}
+
+func (c *GRPCClient) PostProcessor() packer.PostProcessor {
+	return newGRPCPostProcessor(c.broker)
+}
+
+func (c *GRPCClient) Datasource() packer.Datasource {
+	return newGRPCDatasource(c.broker)
+}
+
+func (c *GRPCClient) Close() error {
+	return c.broker.Close()
+}
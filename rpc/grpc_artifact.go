@@ -0,0 +1,273 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// An implementation of packersdk.Artifact where the artifact is available
+// over a gRPC connection. Its description is fetched once, on first use.
+type grpcArtifact struct {
+	client pluginproto.ArtifactClient
+	id     uint32
+
+	describeOnce sync.Once
+	info         *pluginproto.ArtifactInfo
+}
+
+func (a *grpcArtifact) describe() *pluginproto.ArtifactInfo {
+	a.describeOnce.Do(func() {
+		info, err := a.client.Describe(context.Background(), &pluginproto.ArtifactRequest{Artifact: a.id})
+		if err != nil {
+			log.Printf("Error in Artifact.Describe gRPC call: %s", err)
+		}
+		a.info = info
+	})
+	return a.info
+}
+
+func (a *grpcArtifact) BuilderId() string { return a.describe().GetBuilderId() }
+
+func (a *grpcArtifact) Files() []string { return a.describe().GetFiles() }
+
+func (a *grpcArtifact) Id() string { return a.describe().GetId() }
+
+func (a *grpcArtifact) String() string { return a.describe().GetDescription() }
+
+func (a *grpcArtifact) State(name string) interface{} {
+	resp, err := a.client.State(context.Background(), &pluginproto.ArtifactStateRequest{Artifact: a.id, Name: name})
+	if err != nil {
+		log.Printf("Error in Artifact.State gRPC call: %s", err)
+		return nil
+	}
+	if len(resp.GetJson()) == 0 {
+		return nil
+	}
+	var state interface{}
+	if err := json.Unmarshal(resp.GetJson(), &state); err != nil {
+		log.Printf("[ERR] Invalid state %q of artifact: %s", name, err)
+		return nil
+	}
+	return state
+}
+
+func (a *grpcArtifact) Destroy() error {
+	_, err := a.client.Destroy(context.Background(), &pluginproto.ArtifactRequest{Artifact: a.id})
+	return grpcError(err)
+}
+
+// grpcArtifactServer serves the artifacts registered in a grpcBroker.
+type grpcArtifactServer struct {
+	pluginproto.UnimplementedArtifactServer
+	broker *grpcBroker
+}
+
+func (s *grpcArtifactServer) lookup(id uint32) (packersdk.Artifact, error) {
+	o, err := s.broker.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return o.(packersdk.Artifact), nil
+}
+
+func (s *grpcArtifactServer) Describe(_ context.Context, req *pluginproto.ArtifactRequest) (*pluginproto.ArtifactInfo, error) {
+	a, err := s.lookup(req.GetArtifact())
+	if err != nil {
+		return nil, err
+	}
+	return &pluginproto.ArtifactInfo{
+		BuilderId:   a.BuilderId(),
+		Files:       a.Files(),
+		Id:          a.Id(),
+		Description: a.String(),
+	}, nil
+}
+
+func (s *grpcArtifactServer) State(_ context.Context, req *pluginproto.ArtifactStateRequest) (*pluginproto.ArtifactState, error) {
+	a, err := s.lookup(req.GetArtifact())
+	if err != nil {
+		return nil, err
+	}
+	state := a.State(req.GetName())
+	if state == nil {
+		return &pluginproto.ArtifactState{}, nil
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	return &pluginproto.ArtifactState{Json: b}, nil
+}
+
+func (s *grpcArtifactServer) Destroy(_ context.Context, req *pluginproto.ArtifactRequest) (*pluginproto.Empty, error) {
+	a, err := s.lookup(req.GetArtifact())
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Destroy(); err != nil {
+		return nil, err
+	}
+	return &pluginproto.Empty{}, nil
+}
+
+var _ packersdk.ArtifactV2 = new(grpcArtifact)
+
+// Metadata returns the metadata of the remote artifact, nil when it has none
+// or the plugin predates packersdk.ArtifactV2.
+func (a *grpcArtifact) Metadata() *packersdk.ArtifactMetadata {
+	resp, err := a.client.Metadata(context.Background(), &pluginproto.ArtifactRequest{Artifact: a.id})
+	if err != nil {
+		log.Printf("[DEBUG] Artifact does not support Metadata: %s", err)
+		return nil
+	}
+	if !resp.GetSupported() {
+		return nil
+	}
+	md := &packersdk.ArtifactMetadata{
+		Size: resp.GetSize(),
+		Provenance: packersdk.ArtifactProvenance{
+			BuildName:     resp.GetProvenance().GetBuildName(),
+			Sources:       resp.GetProvenance().GetSources(),
+			PluginVersion: resp.GetProvenance().GetPluginVersion(),
+		},
+	}
+	if resp.GetCreatedAt() != 0 {
+		md.CreatedAt = time.Unix(0, resp.GetCreatedAt())
+	}
+	for _, c := range resp.GetChecksums() {
+		if md.Checksums == nil {
+			md.Checksums = map[string]string{}
+		}
+		md.Checksums[c.GetAlgorithm()] = c.GetValue()
+	}
+	if resp.GetValues() != nil {
+		values, err := decodeValue(resp.GetValues())
+		if err != nil || !values.Type().IsObjectType() {
+			log.Printf("[ERR] Invalid metadata values of artifact: %v", err)
+		} else {
+			md.Values = values.AsValueMap()
+		}
+	}
+	return md
+}
+
+func (s *grpcArtifactServer) Metadata(_ context.Context, req *pluginproto.ArtifactRequest) (*pluginproto.ArtifactMetadata, error) {
+	a, err := s.lookup(req.GetArtifact())
+	if err != nil {
+		return nil, err
+	}
+	v2, ok := a.(packersdk.ArtifactV2)
+	if !ok {
+		return &pluginproto.ArtifactMetadata{}, nil
+	}
+	md := v2.Metadata()
+	if md == nil {
+		return &pluginproto.ArtifactMetadata{}, nil
+	}
+
+	resp := &pluginproto.ArtifactMetadata{
+		Supported: true,
+		Size:      md.Size,
+		Provenance: &pluginproto.ArtifactProvenance{
+			BuildName:     md.Provenance.BuildName,
+			Sources:       md.Provenance.Sources,
+			PluginVersion: md.Provenance.PluginVersion,
+		},
+	}
+	if !md.CreatedAt.IsZero() {
+		resp.CreatedAt = md.CreatedAt.UnixNano()
+	}
+	algorithms := make([]string, 0, len(md.Checksums))
+	for algorithm := range md.Checksums {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	for _, algorithm := range algorithms {
+		resp.Checksums = append(resp.Checksums, &pluginproto.Checksum{Algorithm: algorithm, Value: md.Checksums[algorithm]})
+	}
+	if len(md.Values) > 0 {
+		if resp.Values, err = encodeValue(cty.ObjectVal(md.Values)); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+var _ packersdk.DestroyableArtifact = new(grpcArtifact)
+
+// DestroyWithOptions destroys the remote artifact with opts. Plugins
+// predating packersdk.DestroyableArtifact report their artifact as a single
+// resource, as packersdk.DestroyArtifact does.
+func (a *grpcArtifact) DestroyWithOptions(ctx context.Context, opts packersdk.DestroyOptions) ([]packersdk.DestroyResult, error) {
+	resp, err := a.client.DestroyWithOptions(ctx, &pluginproto.ArtifactDestroyRequest{
+		Artifact: a.id,
+		DryRun:   opts.DryRun,
+		Force:    opts.Force,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		// Hide DestroyWithOptions so that the artifact is destroyed with
+		// Destroy.
+		return packersdk.DestroyArtifact(ctx, struct{ packersdk.Artifact }{a}, opts)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	var results []packersdk.DestroyResult
+	for _, r := range resp.GetResults() {
+		results = append(results, packersdk.DestroyResult{
+			Type:       r.GetType(),
+			ID:         r.GetId(),
+			Status:     packersdk.DestroyStatus(r.GetStatus()),
+			Dependents: r.GetDependents(),
+			Error:      r.GetError(),
+		})
+	}
+	if resp.GetError() == "" {
+		return results, nil
+	}
+	if err := packersdk.DestroyResultsError(results); err != nil {
+		return results, err
+	}
+	return results, errors.New(resp.GetError())
+}
+
+func (s *grpcArtifactServer) DestroyWithOptions(ctx context.Context, req *pluginproto.ArtifactDestroyRequest) (*pluginproto.ArtifactDestroyResponse, error) {
+	a, err := s.lookup(req.GetArtifact())
+	if err != nil {
+		return nil, err
+	}
+	results, err := packersdk.DestroyArtifact(ctx, a, packersdk.DestroyOptions{
+		DryRun: req.GetDryRun(),
+		Force:  req.GetForce(),
+	})
+
+	resp := new(pluginproto.ArtifactDestroyResponse)
+	for _, r := range results {
+		resp.Results = append(resp.Results, &pluginproto.ArtifactDestroyResult{
+			Type:       r.Type,
+			Id:         r.ID,
+			Status:     string(r.Status),
+			Dependents: r.Dependents,
+			Error:      r.Error,
+		})
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp, nil
+}
@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/hcl/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// An implementation of packersdk.Builder where the builder is executed over
+// a gRPC connection.
+type grpcBuilder struct {
+	grpcComponent
+	client pluginproto.BuilderClient
+	broker *grpcBroker
+}
+
+var (
+	_ packersdk.OptionalBuilderWithEstimate = new(grpcBuilder)
+	_ packersdk.DiagnosticsBuilder          = new(grpcBuilder)
+)
+
+func newGRPCBuilder(broker *grpcBroker) *grpcBuilder {
+	client := pluginproto.NewBuilderClient(broker.conn)
+	return &grpcBuilder{
+		grpcComponent: grpcComponent{component: client},
+		client:        client,
+		broker:        broker,
+	}
+}
+
+func (b *grpcBuilder) Prepare(config ...interface{}) ([]string, []string, error) {
+	configs, err := encodeConfigs(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := b.client.Prepare(context.Background(), &pluginproto.PrepareRequest{Configs: configs})
+	if err != nil {
+		return nil, nil, grpcError(err)
+	}
+	warnings, err := decodeGRPCPrepareResponse(resp)
+	return resp.GetGeneratedVars(), warnings, err
+}
+
+func (b *grpcBuilder) PrepareWithDiagnostics(config ...interface{}) ([]string, hcl.Diagnostics) {
+	generated, warnings, err := b.Prepare(config...)
+	return generated, packersdk.PrepareDiagnostics(warnings, err)
+}
+
+func (b *grpcBuilder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	uiId := b.broker.register(ui)
+	defer b.broker.unregister(uiId)
+	hookId := b.broker.register(hook)
+	defer b.broker.unregister(hookId)
+
+	resp, err := b.client.Run(ctx, &pluginproto.RunRequest{Ui: uiId, Hook: hookId})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return b.broker.artifact(resp.GetArtifact()), nil
+}
+
+// grpcBuilderServer wraps a packersdk.Builder implementation and makes it
+// callable over gRPC.
+type grpcBuilderServer struct {
+	pluginproto.UnsafeBuilderServer
+	grpcComponentServer
+	broker  *grpcBroker
+	builder packersdk.Builder
+}
+
+func (b *grpcBuilderServer) Prepare(_ context.Context, req *pluginproto.PrepareRequest) (*pluginproto.PrepareResponse, error) {
+	configs, err := decodeConfigs(req.GetConfigs())
+	if err != nil {
+		return nil, err
+	}
+	return grpcPrepareResponse(b.builder.Prepare(configs...)), nil
+}
+
+func (b *grpcBuilderServer) Run(ctx context.Context, req *pluginproto.RunRequest) (*pluginproto.RunResponse, error) {
+	artifact, err := b.builder.Run(ctx, b.broker.ui(req.GetUi()), b.broker.hook(req.GetHook()))
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &pluginproto.RunResponse{Artifact: b.broker.register(artifact)}, nil
+}
+
+func (b *grpcBuilder) SupportsEstimate() bool {
+	resp, err := b.client.SupportsEstimate(context.Background(), &pluginproto.Empty{})
+	if err != nil {
+		log.Printf("[DEBUG] Builder does not support Estimate: %s", grpcError(err))
+		return false
+	}
+	return resp.GetSupported()
+}
+
+func (b *grpcBuilder) Estimate() (*packersdk.Estimate, error) {
+	resp, err := b.client.Estimate(context.Background(), &pluginproto.Empty{})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if resp.GetError() != "" {
+		return nil, &BasicError{resp.GetError()}
+	}
+	if !resp.GetEstimated() {
+		return nil, nil
+	}
+	estimate := &packersdk.Estimate{Currency: resp.GetCurrency()}
+	for _, r := range resp.GetResources() {
+		estimate.Resources = append(estimate.Resources, packersdk.ResourceEstimate{
+			Kind:     r.GetKind(),
+			Name:     r.GetName(),
+			Quantity: r.GetQuantity(),
+			Unit:     r.GetUnit(),
+			Cost:     r.GetCost(),
+		})
+	}
+	return estimate, nil
+}
+
+func (b *grpcBuilderServer) SupportsEstimate(context.Context, *pluginproto.Empty) (*pluginproto.Supported, error) {
+	_, supported := b.builder.(packersdk.BuilderWithEstimate)
+	return &pluginproto.Supported{Supported: supported}, nil
+}
+
+func (b *grpcBuilderServer) Estimate(context.Context, *pluginproto.Empty) (*pluginproto.EstimateResponse, error) {
+	e, ok := b.builder.(packersdk.BuilderWithEstimate)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "build estimation is not supported by this plugin")
+	}
+	estimate, err := e.Estimate()
+	if err != nil {
+		return &pluginproto.EstimateResponse{Error: err.Error()}, nil
+	}
+	resp := &pluginproto.EstimateResponse{}
+	if estimate == nil {
+		return resp, nil
+	}
+	resp.Estimated = true
+	resp.Currency = estimate.Currency
+	for _, r := range estimate.Resources {
+		resp.Resources = append(resp.Resources, &pluginproto.ResourceEstimate{
+			Kind:     r.Kind,
+			Name:     r.Name,
+			Quantity: r.Quantity,
+			Unit:     r.Unit,
+			Cost:     r.Cost,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcComponentClient holds the calls shared by the gRPC services of
+// builders, provisioners, post-processors and datasources.
+type grpcComponentClient interface {
+	ConfigSpec(ctx context.Context, in *pluginproto.Empty, opts ...grpc.CallOption) (*pluginproto.Spec, error)
+	SupportsValidateConfig(ctx context.Context, in *pluginproto.Empty, opts ...grpc.CallOption) (*pluginproto.Supported, error)
+	ValidateConfig(ctx context.Context, in *pluginproto.PrepareRequest, opts ...grpc.CallOption) (*pluginproto.ValidateConfigResponse, error)
+}
+
+// grpcComponent is the gRPC counterpart of commonClient.
+type grpcComponent struct {
+	component grpcComponentClient
+}
+
+func (c *grpcComponent) ConfigSpec() hcldec.ObjectSpec {
+	// Like with net/rpc, ConfigSpec has no way of returning an error.
+	resp, err := c.component.ConfigSpec(context.Background(), &pluginproto.Empty{})
+	if err != nil {
+		panic(fmt.Sprintf("ConfigSpec failed: %v", grpcError(err)))
+	}
+	spec, err := decodeObjectSpec(resp)
+	if err != nil {
+		panic(fmt.Sprintf("ConfigSpec failed: %v", err))
+	}
+	return spec
+}
+
+func (c *grpcComponent) SupportsValidateConfig() bool {
+	resp, err := c.component.SupportsValidateConfig(context.Background(), &pluginproto.Empty{})
+	if err != nil {
+		log.Printf("[DEBUG] Component does not support ValidateConfig: %s", grpcError(err))
+		return false
+	}
+	return resp.GetSupported()
+}
+
+func (c *grpcComponent) ValidateConfig(configs ...interface{}) hcl.Diagnostics {
+	req, err := encodeConfigs(configs)
+	if err != nil {
+		return packersdk.ErrorDiagnostics(err)
+	}
+	resp, err := c.component.ValidateConfig(context.Background(), &pluginproto.PrepareRequest{Configs: req})
+	if err != nil {
+		return packersdk.ErrorDiagnostics(grpcError(err))
+	}
+	return hclDiagnostics(decodeDiagnostics(resp.GetDiagnostics()))
+}
+
+// grpcComponentServer is the gRPC counterpart of commonServer. The servers
+// embedding it embed the Unsafe interface of their service rather than its
+// Unimplemented struct, whose methods would be ambiguous with these.
+type grpcComponentServer struct {
+	component packersdk.HCL2Speccer
+}
+
+func (s *grpcComponentServer) ConfigSpec(context.Context, *pluginproto.Empty) (*pluginproto.Spec, error) {
+	return encodeSpec(s.component.ConfigSpec())
+}
+
+func (s *grpcComponentServer) SupportsValidateConfig(context.Context, *pluginproto.Empty) (*pluginproto.Supported, error) {
+	_, supported := s.component.(packersdk.ConfigValidator)
+	return &pluginproto.Supported{Supported: supported}, nil
+}
+
+func (s *grpcComponentServer) ValidateConfig(_ context.Context, req *pluginproto.PrepareRequest) (*pluginproto.ValidateConfigResponse, error) {
+	validator, ok := s.component.(packersdk.ConfigValidator)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "configuration validation is not supported by this plugin")
+	}
+	configs, err := decodeConfigs(req.GetConfigs())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	diags := validator.ValidateConfig(configs...)
+	return &pluginproto.ValidateConfigResponse{Diagnostics: encodeDiagnostics(newDiagnostics(diags))}, nil
+}
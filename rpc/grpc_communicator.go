@@ -0,0 +1,401 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/iohelper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcChunkSize is the size of the chunks of files and standard input sent
+// over gRPC.
+const grpcChunkSize = 32 * 1024
+
+// An implementation of packersdk.Communicator where the communicator is
+// executed over a gRPC connection.
+type grpcCommunicator struct {
+	client pluginproto.CommunicatorClient
+	id     uint32
+}
+
+func (c *grpcCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	stream, err := c.client.Start(ctx)
+	if err != nil {
+		return grpcError(err)
+	}
+	err = stream.Send(&pluginproto.StartInput{Input: &pluginproto.StartInput_Start{Start: &pluginproto.StartRequest{
+		Communicator: c.id,
+		Command:      cmd.Command,
+		Stdin:        cmd.Stdin != nil,
+	}}})
+	if err != nil {
+		return grpcError(err)
+	}
+
+	// Output can be streamed before the command is reported started.
+	for {
+		out, err := stream.Recv()
+		if err != nil {
+			return grpcError(err)
+		}
+		if out.GetStarted() {
+			break
+		}
+		writeStartOutput(cmd, out)
+	}
+
+	if cmd.Stdin != nil {
+		go func() {
+			buf := make([]byte, grpcChunkSize)
+			for {
+				n, err := cmd.Stdin.Read(buf)
+				if n > 0 {
+					if err := stream.Send(&pluginproto.StartInput{Input: &pluginproto.StartInput_Stdin{Stdin: buf[:n]}}); err != nil {
+						return
+					}
+				}
+				if err != nil {
+					if err != io.EOF {
+						log.Printf("[ERR] Error reading stdin of %q: %s", cmd.Command, err)
+					}
+					break
+				}
+			}
+			if err := stream.Send(&pluginproto.StartInput{Input: &pluginproto.StartInput_CloseStdin{CloseStdin: true}}); err == nil {
+				stream.CloseSend()
+			}
+		}()
+	} else {
+		stream.CloseSend()
+	}
+
+	go func() {
+		for {
+			out, err := stream.Recv()
+			if err != nil {
+				log.Printf("[ERR] Lost the output of %q: %s", cmd.Command, grpcError(err))
+				cmd.SetExited(packersdk.CmdDisconnect)
+				return
+			}
+			if exited := writeStartOutput(cmd, out); exited {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// writeStartOutput writes the output of a remote command to cmd, and returns
+// whether the command exited.
+func writeStartOutput(cmd *packersdk.RemoteCmd, out *pluginproto.StartOutput) bool {
+	switch o := out.GetOutput().(type) {
+	case *pluginproto.StartOutput_Stdout:
+		if cmd.Stdout != nil {
+			cmd.Stdout.Write(o.Stdout)
+		}
+	case *pluginproto.StartOutput_Stderr:
+		if cmd.Stderr != nil {
+			cmd.Stderr.Write(o.Stderr)
+		}
+	case *pluginproto.StartOutput_ExitStatus:
+		cmd.SetExited(int(o.ExitStatus))
+		return true
+	}
+	return false
+}
+
+func (c *grpcCommunicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	return c.UploadContext(context.Background(), path, r, fi)
+}
+
+// UploadContext uploads r to path, cancelling the call once ctx is done.
+func (c *grpcCommunicator) UploadContext(ctx context.Context, path string, r io.Reader, fi *os.FileInfo) error {
+	stream, err := c.client.Upload(ctx)
+	if err != nil {
+		return grpcError(err)
+	}
+	req := &pluginproto.UploadRequest{Communicator: c.id, Path: path}
+	if fi != nil && *fi != nil {
+		info := *fi
+		req.FileInfo = &pluginproto.FileInfo{
+			Name:    info.Name(),
+			Size:    info.Size(),
+			Mode:    uint32(info.Mode()),
+			ModTime: info.ModTime().UnixNano(),
+		}
+	}
+	if err := stream.Send(&pluginproto.UploadInput{Input: &pluginproto.UploadInput_Start{Start: req}}); err != nil {
+		_, err = stream.CloseAndRecv()
+		return grpcError(err)
+	}
+
+	buf := make([]byte, grpcChunkSize)
+	r = iohelper.NewContextReader(ctx, r)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pluginproto.UploadInput{Input: &pluginproto.UploadInput_Data{Data: buf[:n]}}); err != nil {
+				// The error of the upload is returned by CloseAndRecv.
+				break
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return grpcError(err)
+}
+
+func (c *grpcCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	return c.UploadDirContext(context.Background(), dst, src, exclude)
+}
+
+func (c *grpcCommunicator) UploadDirContext(ctx context.Context, dst string, src string, exclude []string) error {
+	_, err := c.client.UploadDir(ctx, &pluginproto.DirRequest{Communicator: c.id, Src: src, Dst: dst, Exclude: exclude})
+	return grpcError(err)
+}
+
+func (c *grpcCommunicator) Download(path string, w io.Writer) error {
+	return c.DownloadContext(context.Background(), path, w)
+}
+
+func (c *grpcCommunicator) DownloadContext(ctx context.Context, path string, w io.Writer) error {
+	stream, err := c.client.Download(ctx, &pluginproto.DownloadRequest{Communicator: c.id, Path: path})
+	if err != nil {
+		return grpcError(err)
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return grpcError(err)
+		}
+		if _, err := w.Write(chunk.GetData()); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *grpcCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	return c.DownloadDirContext(context.Background(), src, dst, exclude)
+}
+
+func (c *grpcCommunicator) DownloadDirContext(ctx context.Context, src string, dst string, exclude []string) error {
+	_, err := c.client.DownloadDir(ctx, &pluginproto.DirRequest{Communicator: c.id, Src: src, Dst: dst, Exclude: exclude})
+	return grpcError(err)
+}
+
+// grpcCommunicatorServer serves the communicators registered in a
+// grpcBroker.
+type grpcCommunicatorServer struct {
+	pluginproto.UnimplementedCommunicatorServer
+	broker *grpcBroker
+}
+
+func (s *grpcCommunicatorServer) lookup(id uint32) (packersdk.Communicator, error) {
+	o, err := s.broker.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return o.(packersdk.Communicator), nil
+}
+
+// startOutputWriter sends what a remote command writes to the caller of
+// Start. The writers of stdout and stderr share the same stream.
+type startOutputWriter struct {
+	l      *sync.Mutex
+	stream pluginproto.Communicator_StartServer
+	stderr bool
+}
+
+func (w *startOutputWriter) send(out *pluginproto.StartOutput) error {
+	w.l.Lock()
+	defer w.l.Unlock()
+	return w.stream.Send(out)
+}
+
+func (w *startOutputWriter) Write(p []byte) (int, error) {
+	out := &pluginproto.StartOutput{Output: &pluginproto.StartOutput_Stdout{Stdout: p}}
+	if w.stderr {
+		out = &pluginproto.StartOutput{Output: &pluginproto.StartOutput_Stderr{Stderr: p}}
+	}
+	if err := w.send(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *grpcCommunicatorServer) Start(stream pluginproto.Communicator_StartServer) error {
+	in, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	req := in.GetStart()
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "the command to start must be sent first")
+	}
+	comm, err := s.lookup(req.GetCommunicator())
+	if err != nil {
+		return err
+	}
+
+	l := new(sync.Mutex)
+	stdout := &startOutputWriter{l: l, stream: stream}
+	cmd := &packersdk.RemoteCmd{
+		Command: req.GetCommand(),
+		Stdout:  stdout,
+		Stderr:  &startOutputWriter{l: l, stream: stream, stderr: true},
+	}
+	if req.GetStdin() {
+		stdinR, stdinW := io.Pipe()
+		cmd.Stdin = stdinR
+		go func() {
+			for {
+				in, err := stream.Recv()
+				if err != nil {
+					stdinW.CloseWithError(err)
+					return
+				}
+				if in.GetCloseStdin() {
+					stdinW.Close()
+					return
+				}
+				if _, err := stdinW.Write(in.GetStdin()); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	if err := comm.Start(stream.Context(), cmd); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if err := stdout.send(&pluginproto.StartOutput{Output: &pluginproto.StartOutput_Started{Started: true}}); err != nil {
+		return err
+	}
+	exitStatus := cmd.Wait()
+	return stdout.send(&pluginproto.StartOutput{Output: &pluginproto.StartOutput_ExitStatus{ExitStatus: int32(exitStatus)}})
+}
+
+func (s *grpcCommunicatorServer) Upload(stream pluginproto.Communicator_UploadServer) error {
+	in, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	req := in.GetStart()
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "the destination of the file must be sent first")
+	}
+	comm, err := s.lookup(req.GetCommunicator())
+	if err != nil {
+		return err
+	}
+	var fi *os.FileInfo
+	if info := req.GetFileInfo(); info != nil {
+		var modTime time.Time
+		if info.GetModTime() != 0 {
+			modTime = time.Unix(0, info.GetModTime())
+		}
+		var osfi os.FileInfo = &fileInfo{
+			N: info.GetName(),
+			S: info.GetSize(),
+			M: os.FileMode(info.GetMode()),
+			T: modTime,
+		}
+		fi = &osfi
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		for {
+			in, err := stream.Recv()
+			if err == io.EOF {
+				w.Close()
+				return
+			}
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+			if _, err := w.Write(in.GetData()); err != nil {
+				return
+			}
+		}
+	}()
+	// Unblock the goroutine above if the communicator stops reading early.
+	defer r.Close()
+
+	if err := packersdk.UploadContext(stream.Context(), comm, req.GetPath(), r, fi); err != nil {
+		return err
+	}
+	return stream.SendAndClose(&pluginproto.Empty{})
+}
+
+func (s *grpcCommunicatorServer) UploadDir(ctx context.Context, req *pluginproto.DirRequest) (*pluginproto.Empty, error) {
+	comm, err := s.lookup(req.GetCommunicator())
+	if err != nil {
+		return nil, err
+	}
+	if err := packersdk.UploadDirContext(ctx, comm, req.GetDst(), req.GetSrc(), req.GetExclude()); err != nil {
+		return nil, err
+	}
+	return &pluginproto.Empty{}, nil
+}
+
+// chunkWriter sends what is written to it in chunks of at most
+// grpcChunkSize bytes.
+type chunkWriter struct {
+	stream pluginproto.Communicator_DownloadServer
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > grpcChunkSize {
+			n = grpcChunkSize
+		}
+		if err := w.stream.Send(&pluginproto.Chunk{Data: p[:n]}); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (s *grpcCommunicatorServer) Download(req *pluginproto.DownloadRequest, stream pluginproto.Communicator_DownloadServer) error {
+	comm, err := s.lookup(req.GetCommunicator())
+	if err != nil {
+		return err
+	}
+	return packersdk.DownloadContext(stream.Context(), comm, req.GetPath(), &chunkWriter{stream: stream})
+}
+
+func (s *grpcCommunicatorServer) DownloadDir(ctx context.Context, req *pluginproto.DirRequest) (*pluginproto.Empty, error) {
+	comm, err := s.lookup(req.GetCommunicator())
+	if err != nil {
+		return nil, err
+	}
+	if err := packersdk.DownloadDirContext(ctx, comm, req.GetSrc(), req.GetDst(), req.GetExclude()); err != nil {
+		return nil, err
+	}
+	return &pluginproto.Empty{}, nil
+}
@@ -0,0 +1,298 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+func encodeValue(v cty.Value) (*pluginproto.Value, error) {
+	t, err := ctyjson.MarshalType(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	b, err := ctyjson.Marshal(v, v.Type())
+	if err != nil {
+		return nil, err
+	}
+	return &pluginproto.Value{Json: b, Type: t}, nil
+}
+
+func decodeValue(v *pluginproto.Value) (cty.Value, error) {
+	t, err := ctyjson.UnmarshalType(v.GetType())
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(v.GetJson(), t)
+}
+
+// encodeConfigs encodes the configurations passed to Prepare or Configure:
+// the cty values of HCL2 templates keep their type, the maps of legacy JSON
+// templates are sent as JSON.
+func encodeConfigs(configs []interface{}) ([]*pluginproto.Config, error) {
+	res := make([]*pluginproto.Config, 0, len(configs))
+	for _, config := range configs {
+		if v, ok := config.(cty.Value); ok {
+			value, err := encodeValue(v)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, &pluginproto.Config{Config: &pluginproto.Config_Value{Value: value}})
+			continue
+		}
+		b, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &pluginproto.Config{Config: &pluginproto.Config_Json{Json: b}})
+	}
+	return res, nil
+}
+
+func decodeConfigs(configs []*pluginproto.Config) ([]interface{}, error) {
+	res := make([]interface{}, 0, len(configs))
+	for _, config := range configs {
+		if value := config.GetValue(); value != nil {
+			v, err := decodeValue(value)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+			continue
+		}
+		var raw interface{}
+		if err := json.Unmarshal(config.GetJson(), &raw); err != nil {
+			return nil, err
+		}
+		res = append(res, raw)
+	}
+	return res, nil
+}
+
+// encodeSpec encodes the specs generated by packer-sdc, other specs cannot
+// be sent to Packer.
+func encodeSpec(spec hcldec.Spec) (*pluginproto.Spec, error) {
+	switch s := spec.(type) {
+	case nil:
+		return nil, nil
+	case hcldec.ObjectSpec:
+		names := make([]string, 0, len(s))
+		for name := range s {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		object := &pluginproto.ObjectSpec{}
+		for _, name := range names {
+			nested, err := encodeSpec(s[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			object.Attributes = append(object.Attributes, &pluginproto.ObjectSpecAttribute{Name: name, Spec: nested})
+		}
+		return &pluginproto.Spec{Spec: &pluginproto.Spec_Object{Object: object}}, nil
+	case *hcldec.AttrSpec:
+		t, err := ctyjson.MarshalType(s.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &pluginproto.Spec{Spec: &pluginproto.Spec_Attr{Attr: &pluginproto.AttrSpec{
+			Name:     s.Name,
+			Type:     t,
+			Required: s.Required,
+		}}}, nil
+	case *hcldec.BlockSpec:
+		nested, err := encodeSpec(s.Nested)
+		if err != nil {
+			return nil, err
+		}
+		return &pluginproto.Spec{Spec: &pluginproto.Spec_Block{Block: &pluginproto.BlockSpec{
+			TypeName: s.TypeName,
+			Nested:   nested,
+			Required: s.Required,
+		}}}, nil
+	case *hcldec.BlockListSpec:
+		nested, err := encodeSpec(s.Nested)
+		if err != nil {
+			return nil, err
+		}
+		return &pluginproto.Spec{Spec: &pluginproto.Spec_BlockList{BlockList: &pluginproto.BlockListSpec{
+			TypeName: s.TypeName,
+			Nested:   nested,
+			MinItems: int64(s.MinItems),
+			MaxItems: int64(s.MaxItems),
+		}}}, nil
+	case *hcldec.BlockAttrsSpec:
+		t, err := ctyjson.MarshalType(s.ElementType)
+		if err != nil {
+			return nil, err
+		}
+		return &pluginproto.Spec{Spec: &pluginproto.Spec_BlockAttrs{BlockAttrs: &pluginproto.BlockAttrsSpec{
+			TypeName:    s.TypeName,
+			ElementType: t,
+			Required:    s.Required,
+		}}}, nil
+	case *hcldec.BlockObjectSpec:
+		nested, err := encodeSpec(s.Nested)
+		if err != nil {
+			return nil, err
+		}
+		return &pluginproto.Spec{Spec: &pluginproto.Spec_BlockObject{BlockObject: &pluginproto.BlockObjectSpec{
+			TypeName:   s.TypeName,
+			LabelNames: s.LabelNames,
+			Nested:     nested,
+		}}}, nil
+	}
+	return nil, fmt.Errorf("unsupported spec type %T", spec)
+}
+
+func decodeSpec(spec *pluginproto.Spec) (hcldec.Spec, error) {
+	switch s := spec.GetSpec().(type) {
+	case nil:
+		return nil, nil
+	case *pluginproto.Spec_Object:
+		return decodeObjectSpec(spec)
+	case *pluginproto.Spec_Attr:
+		t, err := ctyjson.UnmarshalType(s.Attr.GetType())
+		if err != nil {
+			return nil, err
+		}
+		return &hcldec.AttrSpec{Name: s.Attr.GetName(), Type: t, Required: s.Attr.GetRequired()}, nil
+	case *pluginproto.Spec_Block:
+		nested, err := decodeSpec(s.Block.GetNested())
+		if err != nil {
+			return nil, err
+		}
+		return &hcldec.BlockSpec{TypeName: s.Block.GetTypeName(), Nested: nested, Required: s.Block.GetRequired()}, nil
+	case *pluginproto.Spec_BlockList:
+		nested, err := decodeSpec(s.BlockList.GetNested())
+		if err != nil {
+			return nil, err
+		}
+		return &hcldec.BlockListSpec{
+			TypeName: s.BlockList.GetTypeName(),
+			Nested:   nested,
+			MinItems: int(s.BlockList.GetMinItems()),
+			MaxItems: int(s.BlockList.GetMaxItems()),
+		}, nil
+	case *pluginproto.Spec_BlockAttrs:
+		t, err := ctyjson.UnmarshalType(s.BlockAttrs.GetElementType())
+		if err != nil {
+			return nil, err
+		}
+		return &hcldec.BlockAttrsSpec{TypeName: s.BlockAttrs.GetTypeName(), ElementType: t, Required: s.BlockAttrs.GetRequired()}, nil
+	case *pluginproto.Spec_BlockObject:
+		nested, err := decodeSpec(s.BlockObject.GetNested())
+		if err != nil {
+			return nil, err
+		}
+		return &hcldec.BlockObjectSpec{TypeName: s.BlockObject.GetTypeName(), LabelNames: s.BlockObject.GetLabelNames(), Nested: nested}, nil
+	}
+	return nil, fmt.Errorf("unsupported spec type %T", spec.GetSpec())
+}
+
+func decodeObjectSpec(spec *pluginproto.Spec) (hcldec.ObjectSpec, error) {
+	object := spec.GetObject()
+	if object == nil {
+		return nil, fmt.Errorf("expected an object spec, got %T", spec.GetSpec())
+	}
+	res := hcldec.ObjectSpec{}
+	for _, attr := range object.GetAttributes() {
+		nested, err := decodeSpec(attr.GetSpec())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", attr.GetName(), err)
+		}
+		res[attr.GetName()] = nested
+	}
+	return res, nil
+}
+
+func encodeRange(r *hcl.Range) *pluginproto.Range {
+	if r == nil {
+		return nil
+	}
+	return &pluginproto.Range{
+		Filename: r.Filename,
+		Start:    &pluginproto.Pos{Line: int64(r.Start.Line), Column: int64(r.Start.Column), Byte: int64(r.Start.Byte)},
+		End:      &pluginproto.Pos{Line: int64(r.End.Line), Column: int64(r.End.Column), Byte: int64(r.End.Byte)},
+	}
+}
+
+func decodeRange(r *pluginproto.Range) *hcl.Range {
+	if r == nil {
+		return nil
+	}
+	pos := func(p *pluginproto.Pos) hcl.Pos {
+		return hcl.Pos{Line: int(p.GetLine()), Column: int(p.GetColumn()), Byte: int(p.GetByte())}
+	}
+	return &hcl.Range{Filename: r.GetFilename(), Start: pos(r.GetStart()), End: pos(r.GetEnd())}
+}
+
+func encodeDiagnostics(diags []Diagnostic) []*pluginproto.Diagnostic {
+	var res []*pluginproto.Diagnostic
+	for _, d := range diags {
+		severity := pluginproto.Severity_SEVERITY_ERROR
+		if d.Severity == hcl.DiagWarning {
+			severity = pluginproto.Severity_SEVERITY_WARNING
+		}
+		res = append(res, &pluginproto.Diagnostic{
+			Severity:  severity,
+			Summary:   d.Summary,
+			Detail:    d.Detail,
+			Subject:   encodeRange(d.Subject),
+			Context:   encodeRange(d.Context),
+			Attribute: d.Attribute,
+		})
+	}
+	return res
+}
+
+func decodeDiagnostics(diags []*pluginproto.Diagnostic) []Diagnostic {
+	var res []Diagnostic
+	for _, d := range diags {
+		severity := hcl.DiagError
+		if d.GetSeverity() == pluginproto.Severity_SEVERITY_WARNING {
+			severity = hcl.DiagWarning
+		}
+		res = append(res, Diagnostic{
+			Severity:  severity,
+			Summary:   d.GetSummary(),
+			Detail:    d.GetDetail(),
+			Subject:   decodeRange(d.GetSubject()),
+			Context:   decodeRange(d.GetContext()),
+			Attribute: d.GetAttribute(),
+		})
+	}
+	return res
+}
+
+// grpcPrepareResponse encodes the result of Prepare or Configure the same
+// way the net/rpc servers do.
+func grpcPrepareResponse(generated, warnings []string, err error) *pluginproto.PrepareResponse {
+	warnings, berr, diags := encodePrepareResult(warnings, err)
+	resp := &pluginproto.PrepareResponse{
+		GeneratedVars: generated,
+		Warnings:      warnings,
+		Diagnostics:   encodeDiagnostics(diags),
+	}
+	if berr != nil {
+		resp.Error = berr.Error()
+	}
+	return resp
+}
+
+func decodeGRPCPrepareResponse(resp *pluginproto.PrepareResponse) ([]string, error) {
+	var berr *BasicError
+	if resp.GetError() != "" {
+		berr = &BasicError{resp.GetError()}
+	}
+	return decodePrepareResult(resp.GetWarnings(), berr, decodeDiagnostics(resp.GetDiagnostics()))
+}
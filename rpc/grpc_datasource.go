@@ -0,0 +1,232 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// An implementation of packer.Datasource where the data source is executed
+// over a gRPC connection.
+type grpcDatasource struct {
+	grpcComponent
+	client pluginproto.DatasourceClient
+	// noStream is set once the plugin failed to find ExecuteStream.
+	noStream atomic.Bool
+}
+
+func newGRPCDatasource(broker *grpcBroker) *grpcDatasource {
+	client := pluginproto.NewDatasourceClient(broker.conn)
+	return &grpcDatasource{
+		grpcComponent: grpcComponent{component: client},
+		client:        client,
+	}
+}
+
+func (d *grpcDatasource) Configure(configs ...interface{}) error {
+	req, err := encodeConfigs(configs)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Configure(context.Background(), &pluginproto.PrepareRequest{Configs: req})
+	if err != nil {
+		return grpcError(err)
+	}
+	_, err = decodeGRPCPrepareResponse(resp)
+	return err
+}
+
+func (d *grpcDatasource) OutputSpec() hcldec.ObjectSpec {
+	resp, err := d.client.OutputSpec(context.Background(), &pluginproto.Empty{})
+	if err != nil {
+		panic(fmt.Sprintf("Datasource.OutputSpec failed: %v", grpcError(err)))
+	}
+	spec, err := decodeObjectSpec(resp)
+	if err != nil {
+		panic(fmt.Sprintf("Datasource.OutputSpec failed: %v", err))
+	}
+	return spec
+}
+
+func (d *grpcDatasource) Execute() (cty.Value, error) {
+	return d.ExecuteContext(context.Background())
+}
+
+func (d *grpcDatasource) ExecuteContext(ctx context.Context) (cty.Value, error) {
+	if v, ok, err := d.executeStream(ctx); ok {
+		return v, err
+	}
+	resp, err := d.client.Execute(ctx, &pluginproto.Empty{})
+	if err != nil {
+		return cty.NilVal, grpcError(err)
+	}
+	return decodeValue(resp)
+}
+
+// executeStream executes the remote datasource with ExecuteStream. It
+// returns false when the plugin predates it.
+func (d *grpcDatasource) executeStream(ctx context.Context) (cty.Value, bool, error) {
+	if d.noStream.Load() {
+		return cty.NilVal, false, nil
+	}
+	stream, err := d.client.ExecuteStream(ctx, &pluginproto.ExecuteStreamRequest{ChunkSize: DefaultDatasourceChunkSize})
+	if err != nil {
+		return cty.NilVal, true, grpcError(err)
+	}
+
+	var elements []cty.Value
+	for {
+		out, err := stream.Recv()
+		if status.Code(err) == codes.Unimplemented && elements == nil {
+			log.Printf("[DEBUG] The plugin does not stream datasource outputs")
+			d.noStream.Store(true)
+			return cty.NilVal, false, nil
+		}
+		if err == io.EOF {
+			return cty.NilVal, true, fmt.Errorf("the datasource output ended early")
+		}
+		if err != nil {
+			return cty.NilVal, true, grpcError(err)
+		}
+		for _, e := range out.GetElements() {
+			v, err := decodeValue(e)
+			if err != nil {
+				return cty.NilVal, true, err
+			}
+			elements = append(elements, v)
+		}
+		if out.GetRest() == nil {
+			continue
+		}
+
+		rest, err := decodeValue(out.GetRest())
+		if err != nil || out.GetAttribute() == "" {
+			return rest, true, err
+		}
+		v, err := packer.SetStreamedAttribute(d.OutputSpec(), rest, out.GetAttribute(), elements)
+		return v, true, err
+	}
+}
+
+func (d *grpcDatasource) Dependencies() []packer.DatasourceRef {
+	resp, err := d.client.Dependencies(context.Background(), &pluginproto.Empty{})
+	if err != nil {
+		log.Printf("[DEBUG] Could not get datasource dependencies: %s", grpcError(err))
+		return nil
+	}
+	var refs []packer.DatasourceRef
+	for _, dep := range resp.GetDatasources() {
+		ref, err := packer.ParseDatasourceRef(dep)
+		if err != nil {
+			log.Printf("[ERR] %s", err)
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func (d *grpcDatasource) Release() error {
+	_, err := d.client.Release(context.Background(), &pluginproto.Empty{})
+	return grpcError(err)
+}
+
+// grpcDatasourceServer wraps a packer.Datasource implementation and makes it
+// callable over gRPC.
+type grpcDatasourceServer struct {
+	pluginproto.UnsafeDatasourceServer
+	grpcComponentServer
+	d packer.Datasource
+}
+
+func (d *grpcDatasourceServer) Configure(_ context.Context, req *pluginproto.PrepareRequest) (*pluginproto.PrepareResponse, error) {
+	configs, err := decodeConfigs(req.GetConfigs())
+	if err != nil {
+		return nil, err
+	}
+	return grpcPrepareResponse(nil, nil, d.d.Configure(configs...)), nil
+}
+
+func (d *grpcDatasourceServer) OutputSpec(context.Context, *pluginproto.Empty) (*pluginproto.Spec, error) {
+	return encodeSpec(d.d.OutputSpec())
+}
+
+func (d *grpcDatasourceServer) Execute(ctx context.Context, _ *pluginproto.Empty) (*pluginproto.Value, error) {
+	v, err := packer.ExecuteDatasource(ctx, d.d)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return encodeValue(v)
+}
+
+// ExecuteStream sends the elements of the output of a StreamingDatasource in
+// messages of req.ChunkSize elements, the rest of the output in the last one.
+func (d *grpcDatasourceServer) ExecuteStream(req *pluginproto.ExecuteStreamRequest, stream pluginproto.Datasource_ExecuteStreamServer) error {
+	ctx := stream.Context()
+	streaming, ok := d.d.(packer.StreamingDatasource)
+	if !ok {
+		v, err := packer.ExecuteDatasource(ctx, d.d)
+		if err != nil {
+			return status.FromContextError(err).Err()
+		}
+		rest, err := encodeValue(v)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&pluginproto.DatasourceOutput{Rest: rest})
+	}
+
+	chunkSize := int(req.GetChunkSize())
+	if chunkSize <= 0 {
+		chunkSize = DefaultDatasourceChunkSize
+	}
+	out := &pluginproto.DatasourceOutput{}
+	v, err := streaming.ExecuteStream(ctx, func(element cty.Value) error {
+		e, err := encodeValue(element)
+		if err != nil {
+			return err
+		}
+		out.Elements = append(out.Elements, e)
+		if len(out.Elements) < chunkSize {
+			return nil
+		}
+		err = stream.Send(out)
+		out = &pluginproto.DatasourceOutput{}
+		return err
+	})
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if out.Rest, err = encodeValue(v); err != nil {
+		return err
+	}
+	out.Attribute = streaming.StreamedAttribute()
+	return stream.Send(out)
+}
+
+func (d *grpcDatasourceServer) Dependencies(context.Context, *pluginproto.Empty) (*pluginproto.Dependencies, error) {
+	resp := &pluginproto.Dependencies{}
+	for _, ref := range packer.DatasourceDependencies(d.d) {
+		resp.Datasources = append(resp.Datasources, ref.String())
+	}
+	return resp, nil
+}
+
+func (d *grpcDatasourceServer) Release(context.Context, *pluginproto.Empty) (*pluginproto.Empty, error) {
+	if err := packer.ReleaseDatasource(d.d); err != nil {
+		return nil, err
+	}
+	return &pluginproto.Empty{}, nil
+}
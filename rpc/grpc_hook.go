@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// An implementation of packersdk.Hook where the hook is executed over a gRPC
+// connection.
+type grpcHook struct {
+	client pluginproto.HookClient
+	broker *grpcBroker
+	id     uint32
+}
+
+func (h *grpcHook) Run(ctx context.Context, name string, ui packersdk.Ui, comm packersdk.Communicator, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	uiId := h.broker.register(ui)
+	defer h.broker.unregister(uiId)
+	commId := h.broker.register(comm)
+	defer h.broker.unregister(commId)
+
+	_, err = h.client.Run(ctx, &pluginproto.HookRunRequest{
+		Hook:         h.id,
+		Name:         name,
+		Ui:           uiId,
+		Communicator: commId,
+		Data:         b,
+	})
+	return grpcError(err)
+}
+
+// grpcHookServer serves the hooks registered in a grpcBroker.
+type grpcHookServer struct {
+	pluginproto.UnimplementedHookServer
+	broker *grpcBroker
+}
+
+func (s *grpcHookServer) Run(ctx context.Context, req *pluginproto.HookRunRequest) (*pluginproto.Empty, error) {
+	o, err := s.broker.lookup(req.GetHook())
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(req.GetData(), &data); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid hook data: %s", err)
+	}
+	err = o.(packersdk.Hook).Run(ctx, req.GetName(), s.broker.ui(req.GetUi()), s.broker.communicator(req.GetCommunicator()), data)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &pluginproto.Empty{}, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"google.golang.org/grpc/status"
+)
+
+// An implementation of packersdk.PostProcessor where the post-processor is
+// executed over a gRPC connection.
+type grpcPostProcessor struct {
+	grpcComponent
+	client pluginproto.PostProcessorClient
+	broker *grpcBroker
+}
+
+func newGRPCPostProcessor(broker *grpcBroker) *grpcPostProcessor {
+	client := pluginproto.NewPostProcessorClient(broker.conn)
+	return &grpcPostProcessor{
+		grpcComponent: grpcComponent{component: client},
+		client:        client,
+		broker:        broker,
+	}
+}
+
+func (p *grpcPostProcessor) Configure(raws ...interface{}) error {
+	configs, err := encodeConfigs(raws)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Configure(context.Background(), &pluginproto.PrepareRequest{Configs: configs})
+	if err != nil {
+		return grpcError(err)
+	}
+	_, err = decodeGRPCPrepareResponse(resp)
+	return err
+}
+
+func (p *grpcPostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	uiId := p.broker.register(ui)
+	defer p.broker.unregister(uiId)
+	// The artifact stays registered as Packer may destroy it once the
+	// post-processor is done with it.
+	artifactId := p.broker.register(a)
+
+	resp, err := p.client.PostProcess(ctx, &pluginproto.PostProcessRequest{Ui: uiId, Artifact: artifactId})
+	if err != nil {
+		return nil, false, false, grpcError(err)
+	}
+	return p.broker.artifact(resp.GetArtifact()), resp.GetKeep(), resp.GetForceOverride(), nil
+}
+
+// grpcPostProcessorServer wraps a packersdk.PostProcessor implementation and
+// makes it callable over gRPC.
+type grpcPostProcessorServer struct {
+	pluginproto.UnsafePostProcessorServer
+	grpcComponentServer
+	broker *grpcBroker
+	p      packersdk.PostProcessor
+}
+
+func (p *grpcPostProcessorServer) Configure(_ context.Context, req *pluginproto.PrepareRequest) (*pluginproto.PrepareResponse, error) {
+	configs, err := decodeConfigs(req.GetConfigs())
+	if err != nil {
+		return nil, err
+	}
+	return grpcPrepareResponse(nil, nil, p.p.Configure(configs...)), nil
+}
+
+func (p *grpcPostProcessorServer) PostProcess(ctx context.Context, req *pluginproto.PostProcessRequest) (*pluginproto.PostProcessResponse, error) {
+	artifact, keep, forceOverride, err := p.p.PostProcess(ctx, p.broker.ui(req.GetUi()), p.broker.artifact(req.GetArtifact()))
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &pluginproto.PostProcessResponse{
+		Artifact:      p.broker.register(artifact),
+		Keep:          keep,
+		ForceOverride: forceOverride,
+	}, nil
+}
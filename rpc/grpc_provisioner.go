@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// An implementation of packersdk.Provisioner where the provisioner is
+// executed over a gRPC connection.
+type grpcProvisioner struct {
+	grpcComponent
+	client pluginproto.ProvisionerClient
+	broker *grpcBroker
+}
+
+func newGRPCProvisioner(broker *grpcBroker) *grpcProvisioner {
+	client := pluginproto.NewProvisionerClient(broker.conn)
+	return &grpcProvisioner{
+		grpcComponent: grpcComponent{component: client},
+		client:        client,
+		broker:        broker,
+	}
+}
+
+func (p *grpcProvisioner) Prepare(configs ...interface{}) error {
+	req, err := encodeConfigs(configs)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Prepare(context.Background(), &pluginproto.PrepareRequest{Configs: req})
+	if err != nil {
+		return grpcError(err)
+	}
+	_, err = decodeGRPCPrepareResponse(resp)
+	return err
+}
+
+func (p *grpcProvisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, generatedData map[string]interface{}) error {
+	data, err := json.Marshal(generatedData)
+	if err != nil {
+		return err
+	}
+	uiId := p.broker.register(ui)
+	defer p.broker.unregister(uiId)
+	commId := p.broker.register(comm)
+	defer p.broker.unregister(commId)
+
+	_, err = p.client.Provision(ctx, &pluginproto.ProvisionRequest{
+		Ui:            uiId,
+		Communicator:  commId,
+		GeneratedData: data,
+	})
+	return grpcError(err)
+}
+
+// grpcProvisionerServer wraps a packersdk.Provisioner implementation and
+// makes it callable over gRPC.
+type grpcProvisionerServer struct {
+	pluginproto.UnsafeProvisionerServer
+	grpcComponentServer
+	broker *grpcBroker
+	p      packersdk.Provisioner
+}
+
+func (p *grpcProvisionerServer) Prepare(_ context.Context, req *pluginproto.PrepareRequest) (*pluginproto.PrepareResponse, error) {
+	configs, err := decodeConfigs(req.GetConfigs())
+	if err != nil {
+		return nil, err
+	}
+	return grpcPrepareResponse(nil, nil, p.p.Prepare(configs...)), nil
+}
+
+func (p *grpcProvisionerServer) Provision(ctx context.Context, req *pluginproto.ProvisionRequest) (*pluginproto.Empty, error) {
+	var generatedData map[string]interface{}
+	if err := json.Unmarshal(req.GetGeneratedData(), &generatedData); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid generated data: %s", err)
+	}
+	err := p.p.Provision(ctx, p.broker.ui(req.GetUi()), p.broker.communicator(req.GetCommunicator()), generatedData)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &pluginproto.Empty{}, nil
+}
@@ -0,0 +1,315 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func testGRPCClientServer(t *testing.T, register func(*GRPCServer)) *GRPCClient {
+	clientConn, serverConn := testConn(t)
+
+	server, err := NewGRPCServer(serverConn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	register(server)
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	client, err := NewGRPCClient(clientConn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestGRPCBuilder(t *testing.T) {
+	b := &packersdk.MockBuilder{ArtifactId: "foo", GeneratedVars: []string{"Gen"}}
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterBuilder(b) })
+	bClient := client.Builder()
+
+	if !reflect.DeepEqual(bClient.ConfigSpec(), b.ConfigSpec()) {
+		t.Fatalf("bad spec: %#v", bClient.ConfigSpec())
+	}
+
+	config := cty.ObjectVal(map[string]cty.Value{
+		"foo":  cty.StringVal("bar"),
+		"tags": cty.MapVal(map[string]cty.Value{"Name": cty.StringVal("x")}),
+	})
+	generated, _, err := bClient.Prepare(config, map[string]interface{}{"baz": "qux"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(generated, []string{"Gen"}) {
+		t.Fatalf("bad generated vars: %#v", generated)
+	}
+	if v, ok := b.PrepareConfig[0].(cty.Value); !ok || !v.RawEquals(config) {
+		t.Fatalf("bad config: %#v", b.PrepareConfig[0])
+	}
+	if !reflect.DeepEqual(b.PrepareConfig[1], map[string]interface{}{"baz": "qux"}) {
+		t.Fatalf("bad config: %#v", b.PrepareConfig[1])
+	}
+
+	ui := new(packersdk.MockUi)
+	var stdout bytes.Buffer
+	hook := &packersdk.MockHook{}
+	hook.RunFunc = func(ctx context.Context) error {
+		hook.RunUi.Say("provisioning")
+		cmd := &packersdk.RemoteCmd{Command: "ls", Stdout: &stdout}
+		if err := hook.RunComm.Start(ctx, cmd); err != nil {
+			return err
+		}
+		cmd.Wait()
+		return nil
+	}
+	artifact, err := bClient.Run(context.Background(), ui, hook)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !hook.RunCalled || hook.RunName != packersdk.HookProvision {
+		t.Fatalf("bad hook run: %#v", hook)
+	}
+	if len(ui.SayMessages) != 1 || ui.SayMessages[0].Message != "provisioning" {
+		t.Fatalf("bad ui: %#v", ui.SayMessages)
+	}
+	if artifact.Id() != "foo" || artifact.BuilderId() != "bid" {
+		t.Fatalf("bad artifact: %s %s", artifact.Id(), artifact.BuilderId())
+	}
+
+	b.RunErrResult = true
+	if _, err := bClient.Run(context.Background(), ui, hook); err == nil || err.Error() != "foo" {
+		t.Fatalf("expected the error of the builder, got %v", err)
+	}
+}
+
+func TestGRPCBuilderRun_Cancel(t *testing.T) {
+	b := &packersdk.MockBuilder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.RunFn = func(ctx context.Context) {
+		cancel()
+		<-ctx.Done()
+	}
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterBuilder(b) })
+
+	if _, err := client.Builder().Run(ctx, new(packersdk.MockUi), nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestGRPCServer_SetContext(t *testing.T) {
+	base, cancelBase := context.WithCancel(context.Background())
+	b := &packersdk.MockBuilder{}
+	cancelled := false
+	b.RunFn = func(ctx context.Context) {
+		cancelBase()
+		<-ctx.Done()
+		cancelled = true
+	}
+	client := testGRPCClientServer(t, func(s *GRPCServer) {
+		s.SetContext(base)
+		s.RegisterBuilder(b)
+	})
+
+	// Packer does not cancel the build, the plugin does.
+	if _, err := client.Builder().Run(context.Background(), new(packersdk.MockUi), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !cancelled {
+		t.Fatal("context should have been cancelled")
+	}
+}
+
+func TestGRPCBuilderEstimate(t *testing.T) {
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterBuilder(new(estimatingBuilder)) })
+	estimate, err := packersdk.EstimateBuild(client.Builder())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(estimate, testEstimate) {
+		t.Fatalf("bad estimate: %#v", estimate)
+	}
+
+	client = testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterBuilder(new(packersdk.MockBuilder)) })
+	if estimate, err := packersdk.EstimateBuild(client.Builder()); err != nil || estimate != nil {
+		t.Fatalf("expected no estimate, got %#v, %v", estimate, err)
+	}
+}
+
+func TestGRPCProvisioner(t *testing.T) {
+	p := &packersdk.MockProvisioner{}
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterProvisioner(p) })
+	pClient := client.Provisioner()
+
+	if err := pClient.Prepare(cty.ObjectVal(map[string]cty.Value{"foo": cty.NumberIntVal(1)})); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := &packersdk.MockCommunicator{StartStdout: "out", StartStderr: "err", StartExitStatus: 42}
+	var stdout, stderr bytes.Buffer
+	p.ProvFunc = func(ctx context.Context) error {
+		pc := p.ProvCommunicator
+		cmd := &packersdk.RemoteCmd{
+			Command: "cat",
+			Stdin:   strings.NewReader("in"),
+			Stdout:  &stdout,
+			Stderr:  &stderr,
+		}
+		if err := pc.Start(ctx, cmd); err != nil {
+			return err
+		}
+		if status := cmd.Wait(); status != 42 {
+			t.Errorf("bad exit status: %d", status)
+		}
+		if err := pc.Upload("/dst", strings.NewReader("data"), nil); err != nil {
+			return err
+		}
+		var downloaded bytes.Buffer
+		if err := pc.Download("/src", &downloaded); err != nil {
+			return err
+		}
+		if downloaded.String() != comm.DownloadData {
+			t.Errorf("bad download: %q", downloaded.String())
+		}
+		return pc.UploadDir("/dst", "/src", []string{"x"})
+	}
+	comm.DownloadData = "downloaded"
+
+	err := pClient.Provision(context.Background(), new(packersdk.MockUi), comm, map[string]interface{}{"ID": "i-1"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if comm.StartCmd.Command != "cat" || comm.StartStdin != "in" {
+		t.Fatalf("bad command: %q, stdin %q", comm.StartCmd.Command, comm.StartStdin)
+	}
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Fatalf("bad output: %q %q", stdout.String(), stderr.String())
+	}
+	if comm.UploadPath != "/dst" || comm.UploadData != "data" {
+		t.Fatalf("bad upload: %q %q", comm.UploadPath, comm.UploadData)
+	}
+	if comm.UploadDirSrc != "/src" || comm.UploadDirDst != "/dst" || !reflect.DeepEqual(comm.UploadDirExclude, []string{"x"}) {
+		t.Fatalf("bad upload dir: %#v", comm)
+	}
+}
+
+func TestGRPCProvisioner_cancelTransfers(t *testing.T) {
+	p := &packersdk.MockProvisioner{}
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterProvisioner(p) })
+	pClient := client.Provisioner()
+
+	comm := &blockingMockCommunicator{cancelled: make(chan string, 2)}
+	p.ProvFunc = func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		w := &cancellingWriter{cancel: cancel}
+		if err := packersdk.DownloadContext(ctx, p.ProvCommunicator, "/src", w); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the download to be cancelled, got %v", err)
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := packersdk.UploadDirContext(ctx, p.ProvCommunicator, "/dst", "/src", nil); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the upload to be cancelled, got %v", err)
+		}
+		return nil
+	}
+	if err := pClient.Provision(context.Background(), new(packersdk.MockUi), comm, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, expected := range []string{"download /src", "upload dir /dst"} {
+		if cancelled := <-comm.cancelled; cancelled != expected {
+			t.Fatalf("expected %s to be cancelled, got %s", expected, cancelled)
+		}
+	}
+}
+
+type diagnosticsPostProcessor struct {
+	TestPostProcessor
+}
+
+func (p *diagnosticsPostProcessor) Configure(...interface{}) error {
+	return &packersdk.DiagnosticsError{Diagnostics: hcl.Diagnostics{
+		packersdk.AttributeDiagnostic(hcl.DiagError, "output", "Invalid output", "output must be set."),
+	}}
+}
+
+func TestGRPCPostProcessor(t *testing.T) {
+	p := new(diagnosticsPostProcessor)
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterPostProcessor(p) })
+	ppClient := client.PostProcessor()
+
+	a := &packersdk.MockArtifact{IdValue: "in", MetadataValue: testArtifactMetadata}
+	artifact, _, _, err := ppClient.PostProcess(context.Background(), new(packersdk.MockUi), a)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.ppArtifactId != "in" {
+		t.Fatalf("bad input artifact: %q", p.ppArtifactId)
+	}
+	if artifact.Id() != testPostProcessorArtifact.Id() {
+		t.Fatalf("bad artifact: %q", artifact.Id())
+	}
+	checkArtifactMetadata(t, p.ppArtifact.(packersdk.ArtifactV2).Metadata())
+	if err := p.ppArtifact.Destroy(); err != nil || !a.DestroyCalled {
+		t.Fatalf("input artifact not destroyed: %v", err)
+	}
+
+	diags := packersdk.PrepareDiagnostics(nil, ppClient.Configure(map[string]interface{}{}))
+	if !diags.HasErrors() || len(diags) != 1 {
+		t.Fatalf("bad diagnostics: %#v", diags)
+	}
+	if name, _ := packersdk.DiagnosticAttributeName(diags[0]); name != "output" || diags[0].Detail != "output must be set." {
+		t.Fatalf("bad diagnostic: %#v", diags[0])
+	}
+}
+
+func TestGRPCArtifact_DestroyWithOptions(t *testing.T) {
+	p := new(diagnosticsPostProcessor)
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterPostProcessor(p) })
+
+	a := new(destroyableArtifact)
+	if _, _, _, err := client.PostProcessor().PostProcess(context.Background(), new(packersdk.MockUi), a); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testDestroyableArtifact(t, a, p.ppArtifact)
+}
+
+func TestGRPCSpec(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: true},
+		"any":  &hcldec.AttrSpec{Name: "any", Type: cty.DynamicPseudoType},
+		"tags": &hcldec.BlockAttrsSpec{TypeName: "tags", ElementType: cty.String},
+		"disk": &hcldec.BlockListSpec{TypeName: "disk", Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+		}, MaxItems: 2},
+		"boot": &hcldec.BlockSpec{TypeName: "boot", Nested: hcldec.ObjectSpec{}},
+		"obj":  &hcldec.BlockObjectSpec{TypeName: "obj", LabelNames: []string{"name"}, Nested: hcldec.ObjectSpec{}},
+	}
+	encoded, err := encodeSpec(spec)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	decoded, err := decodeObjectSpec(encoded)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(decoded, spec) {
+		t.Fatalf("bad spec: %#v", decoded)
+	}
+
+	if _, err := encodeSpec(hcldec.ObjectSpec{"x": &hcldec.LiteralSpec{}}); err == nil {
+		t.Fatal("expected an error for an unsupported spec")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+)
+
+// An implementation of packersdk.Ui where the Ui is executed over a gRPC
+// connection. Progress is not tracked over gRPC yet.
+type grpcUi struct {
+	packersdk.NoopProgressTracker
+	client pluginproto.UiClient
+	id     uint32
+}
+
+var _ packersdk.Ui = new(grpcUi)
+
+func (u *grpcUi) Askf(query string, args ...any) (string, error) {
+	return u.Ask(i18n.Sprintf(query, args...))
+}
+
+func (u *grpcUi) Ask(query string) (string, error) {
+	resp, err := u.client.Ask(context.Background(), &pluginproto.UiRequest{Ui: u.id, Message: query})
+	if err != nil {
+		return "", grpcError(err)
+	}
+	return resp.GetAnswer(), nil
+}
+
+func (u *grpcUi) Sayf(message string, args ...any) {
+	u.Say(i18n.Sprintf(message, args...))
+}
+
+func (u *grpcUi) Say(message string) {
+	if _, err := u.client.Say(context.Background(), &pluginproto.UiRequest{Ui: u.id, Message: redact(message)}); err != nil {
+		log.Printf("Error in Ui.Say gRPC call: %s", err)
+	}
+}
+
+func (u *grpcUi) Message(message string) {
+	if _, err := u.client.Message(context.Background(), &pluginproto.UiRequest{Ui: u.id, Message: redact(message)}); err != nil {
+		log.Printf("Error in Ui.Message gRPC call: %s", err)
+	}
+}
+
+func (u *grpcUi) Errorf(message string, args ...any) {
+	u.Error(i18n.Sprintf(message, args...))
+}
+
+func (u *grpcUi) Error(message string) {
+	if _, err := u.client.Error(context.Background(), &pluginproto.UiRequest{Ui: u.id, Message: redact(message)}); err != nil {
+		log.Printf("Error in Ui.Error gRPC call: %s", err)
+	}
+}
+
+func (u *grpcUi) Machine(t string, args ...string) {
+	if _, err := u.client.Machine(context.Background(), &pluginproto.MachineRequest{Ui: u.id, Category: t, Args: args}); err != nil {
+		log.Printf("Error in Ui.Machine gRPC call: %s", err)
+	}
+}
+
+// grpcUiServer serves the Uis registered in a grpcBroker.
+type grpcUiServer struct {
+	pluginproto.UnimplementedUiServer
+	broker *grpcBroker
+}
+
+func (s *grpcUiServer) lookup(id uint32) (packersdk.Ui, error) {
+	o, err := s.broker.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return o.(packersdk.Ui), nil
+}
+
+func (s *grpcUiServer) Ask(_ context.Context, req *pluginproto.UiRequest) (*pluginproto.AskResponse, error) {
+	ui, err := s.lookup(req.GetUi())
+	if err != nil {
+		return nil, err
+	}
+	answer, err := ui.Ask(req.GetMessage())
+	if err != nil {
+		return nil, err
+	}
+	return &pluginproto.AskResponse{Answer: answer}, nil
+}
+
+func (s *grpcUiServer) Say(_ context.Context, req *pluginproto.UiRequest) (*pluginproto.Empty, error) {
+	ui, err := s.lookup(req.GetUi())
+	if err != nil {
+		return nil, err
+	}
+	ui.Say(redact(req.GetMessage()))
+	return &pluginproto.Empty{}, nil
+}
+
+func (s *grpcUiServer) Message(_ context.Context, req *pluginproto.UiRequest) (*pluginproto.Empty, error) {
+	ui, err := s.lookup(req.GetUi())
+	if err != nil {
+		return nil, err
+	}
+	ui.Message(redact(req.GetMessage()))
+	return &pluginproto.Empty{}, nil
+}
+
+func (s *grpcUiServer) Error(_ context.Context, req *pluginproto.UiRequest) (*pluginproto.Empty, error) {
+	ui, err := s.lookup(req.GetUi())
+	if err != nil {
+		return nil, err
+	}
+	ui.Error(redact(req.GetMessage()))
+	return &pluginproto.Empty{}, nil
+}
+
+func (s *grpcUiServer) Machine(_ context.Context, req *pluginproto.MachineRequest) (*pluginproto.Empty, error) {
+	ui, err := s.lookup(req.GetUi())
+	if err != nil {
+		return nil, err
+	}
+	ui.Machine(req.GetCategory(), req.GetArgs()...)
+	return &pluginproto.Empty{}, nil
+}
@@ -5,6 +5,7 @@ package rpc
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 
@@ -29,9 +30,13 @@ type HookServer struct {
 }
 
 type HookRunArgs struct {
-	Name     string
-	Data     interface{}
-	StreamId uint32
+	Name string
+	Data interface{}
+	// TypedData is Data encoded by encodeGeneratedData, preferred to it when
+	// set. It is only set when Data is generated data, a
+	// map[string]interface{}.
+	TypedData []byte
+	StreamId  uint32
 }
 
 func (h *hook) Run(ctx context.Context, name string, ui packersdk.Ui, comm packersdk.Communicator, data interface{}) error {
@@ -59,6 +64,13 @@ func (h *hook) Run(ctx context.Context, name string, ui packersdk.Ui, comm packe
 		Data:     data,
 		StreamId: nextId,
 	}
+	if generatedData, ok := data.(map[string]interface{}); ok {
+		typed, err := encodeGeneratedData(generatedData)
+		if err != nil {
+			log.Printf("[WARN] Sending the generated data untyped: %s", err)
+		}
+		args.TypedData = typed
+	}
 
 	return h.client.Call(h.endpoint+".Run", &args, new(interface{}))
 }
@@ -72,10 +84,18 @@ func (h *HookServer) Run(args *HookRunArgs, reply *interface{}) error {
 
 	h.lock.Lock()
 	if h.context == nil {
-		h.context, h.contextCancel = context.WithCancel(context.Background())
+		h.context, h.contextCancel = h.mux.newContext()
 	}
 	h.lock.Unlock()
-	if err := h.hook.Run(h.context, args.Name, client.Ui(), client.Communicator(), args.Data); err != nil {
+
+	data := args.Data
+	if len(args.TypedData) > 0 {
+		data, err = decodeGeneratedData(args.TypedData)
+		if err != nil {
+			return NewBasicError(fmt.Errorf("Error decoding the generated data: %s", err))
+		}
+	}
+	if err := h.hook.Run(h.context, args.Name, client.Ui(), client.Communicator(), data); err != nil {
 		return NewBasicError(err)
 	}
 
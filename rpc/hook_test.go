@@ -7,6 +7,8 @@ import (
 	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -39,3 +41,27 @@ func TestHook_cancelWhileRun(t *testing.T) {
 		t.Fatal("should have errored")
 	}
 }
+
+func TestHook_generatedData(t *testing.T) {
+	h := new(packersdk.MockHook)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterHook(h)
+
+	data := map[string]interface{}{
+		"ID":         "ami-1234",
+		"SSHPort":    22,
+		"Ratio":      0.5,
+		"Public":     true,
+		"Tags":       []string{"a", "b"},
+		"Labels":     map[string]string{"env": "test"},
+		"PackerHTTP": nil,
+	}
+	if err := client.Hook().Run(context.Background(), packersdk.HookProvision, nil, nil, data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff := cmp.Diff(data, h.RunData); diff != "" {
+		t.Fatalf("the generated data should keep its types: %s", diff)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// DefaultLoggingEndpoint is the endpoint of plugins Packer asks to
+	// forward their logs.
+	DefaultLoggingEndpoint string = "Logging"
+	// DefaultLogEndpoint is the endpoint of Packer receiving the logs of a
+	// plugin.
+	DefaultLogEndpoint string = "Log"
+)
+
+const (
+	// logQueueSize is the number of records a plugin queues before dropping
+	// them, when Packer doesn't receive them fast enough.
+	logQueueSize = 1024
+	// logBatchSize is the maximum number of records sent in one call.
+	logBatchSize = 128
+)
+
+// LogRecord is a log record of a plugin, as sent to Packer.
+type LogRecord struct {
+	Level hclog.Level
+	// Name is the name of the sub-logger the record was logged with.
+	Name    string
+	Message string
+	// Args are the key/value pairs of the record, formatted.
+	Args []string
+}
+
+type LoggingStartArgs struct {
+	StreamId uint32
+	// Level is the lowest level of the records to forward.
+	Level hclog.Level
+}
+
+type LogArgs struct {
+	Records []LogRecord
+}
+
+// LogLevel returns the level of the logs of the plugin name Packer keeps, from
+// the PACKER_LOG environment variable, overridden for the plugin by
+// PACKER_LOG_<NAME>, NAME being name in upper case with dashes replaced by
+// underscores. The variables are a level, like "debug", or 1 to keep
+// everything; logs are off when they are unset or 0.
+func LogLevel(name string) hclog.Level {
+	value := os.Getenv("PACKER_LOG")
+	key := "PACKER_LOG_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	if v := os.Getenv(key); v != "" {
+		value = v
+	}
+	switch strings.TrimSpace(strings.ToLower(value)) {
+	case "", "0", "false", "off":
+		return hclog.Off
+	case "1", "true":
+		return hclog.Trace
+	}
+	level := hclog.LevelFromString(value)
+	if level == hclog.NoLevel {
+		log.Printf("[WARN] Invalid log level %q, logging everything", value)
+		return hclog.Trace
+	}
+	return level
+}
+
+// ForwardLogs asks the plugin to send its log records of at least level to
+// logger, each with the sub-logger of logger named after the one the plugin
+// logged it with. The plugin stops writing them to its stderr. Plugins built
+// with an SDK that can't forward their logs return an error, and keep
+// writing them to stderr.
+func (c *Client) ForwardLogs(logger hclog.Logger, level hclog.Level) error {
+	nextId := c.mux.NextId()
+	server := newServerWithMux(c.mux, nextId)
+	if err := server.server.RegisterName(DefaultLogEndpoint, &LogServer{logger: logger, level: level}); err != nil {
+		return err
+	}
+	go server.Serve()
+
+	args := &LoggingStartArgs{StreamId: nextId, Level: level}
+	if err := c.client.Call(DefaultLoggingEndpoint+".Start", args, new(interface{})); err != nil {
+		if isMethodNotFound(err) {
+			return fmt.Errorf("the plugin can't forward its logs: %s", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// LogServer receives the log records of a plugin, on the Packer side.
+type LogServer struct {
+	logger hclog.Logger
+	level  hclog.Level
+}
+
+func (s *LogServer) Log(args *LogArgs, reply *interface{}) error {
+	for _, r := range args.Records {
+		if r.Level < s.level {
+			continue
+		}
+		logger := s.logger
+		if r.Name != "" {
+			logger = logger.Named(r.Name)
+		}
+		kv := make([]interface{}, len(r.Args))
+		for i, arg := range r.Args {
+			kv[i] = arg
+		}
+		logger.Log(r.Level, r.Message, kv...)
+	}
+	return nil
+}
+
+// RegisterLogging lets Packer ask the plugin to forward the records of logger
+// over RPC, see Client.ForwardLogs.
+func (s *PluginServer) RegisterLogging(logger hclog.InterceptLogger) error {
+	return s.server.RegisterName(DefaultLoggingEndpoint, &LoggingServer{
+		logger: logger,
+		mux:    s.mux,
+	})
+}
+
+// LoggingServer forwards the logs of a plugin to Packer once it asks for them.
+type LoggingServer struct {
+	logger hclog.InterceptLogger
+	mux    *muxBroker
+
+	l    sync.Mutex
+	sink *logSink
+	// stderrLevel is the level of logger before it stopped writing to
+	// stderr, restored when forwarding fails.
+	stderrLevel hclog.Level
+}
+
+func (s *LoggingServer) Start(args *LoggingStartArgs, reply *interface{}) error {
+	client, err := newClientWithMux(s.mux, args.StreamId)
+	if err != nil {
+		return NewBasicError(err)
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.sink != nil {
+		s.logger.DeregisterSink(s.sink)
+		s.sink.stop()
+	} else {
+		s.stderrLevel = s.logger.GetLevel()
+	}
+	sink := &logSink{
+		level:   args.Level,
+		client:  client,
+		records: make(chan LogRecord, logQueueSize),
+		done:    make(chan struct{}),
+	}
+	s.sink = sink
+	s.logger.RegisterSink(sink)
+	// Packer logs the records now, writing them to stderr too would
+	// duplicate them.
+	s.logger.SetLevel(hclog.Off)
+	go sink.run(func() {
+		s.l.Lock()
+		defer s.l.Unlock()
+		if s.sink == sink {
+			s.logger.DeregisterSink(sink)
+			s.logger.SetLevel(s.stderrLevel)
+			s.sink = nil
+		}
+	})
+	return nil
+}
+
+// logSink queues the records of the logger of a plugin, for run to send them
+// to Packer. Records are dropped when the queue is full, rather than blocking
+// the plugin.
+type logSink struct {
+	level   hclog.Level
+	client  *Client
+	records chan LogRecord
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+var _ hclog.SinkAdapter = new(logSink)
+
+func (s *logSink) Accept(name string, level hclog.Level, msg string, args ...interface{}) {
+	if level < s.level {
+		return
+	}
+	r := LogRecord{Level: level, Name: name, Message: msg}
+	for _, arg := range args {
+		r.Args = append(r.Args, fmt.Sprint(arg))
+	}
+	select {
+	case s.records <- r:
+	default:
+	}
+}
+
+func (s *logSink) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+// run sends the queued records in batches until the sink is stopped, or
+// until Packer can't be reached, in which case it calls restore to write the
+// logs to stderr again.
+func (s *logSink) run(restore func()) {
+	defer s.client.Close()
+	for {
+		var batch []LogRecord
+		select {
+		case r := <-s.records:
+			batch = append(batch, r)
+		case <-s.done:
+			return
+		}
+	fill:
+		for len(batch) < logBatchSize {
+			select {
+			case r := <-s.records:
+				batch = append(batch, r)
+			default:
+				break fill
+			}
+		}
+		if err := s.client.client.Call(DefaultLogEndpoint+".Log", &LogArgs{Records: batch}, new(interface{})); err != nil {
+			s.stop()
+			restore()
+			log.Printf("[WARN] Stopped forwarding logs to Packer: %s", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// lockedBuffer is a buffer loggers can write to concurrently.
+type lockedBuffer struct {
+	l   sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.buf.String()
+}
+
+func TestClient_ForwardLogs(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	pluginOut := new(lockedBuffer)
+	pluginLogger := hclog.NewInterceptLogger(&hclog.LoggerOptions{
+		Output: pluginOut,
+		Level:  hclog.Trace,
+	})
+	if err := server.RegisterLogging(pluginLogger); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	coreOut := new(lockedBuffer)
+	coreLogger := hclog.New(&hclog.LoggerOptions{
+		Name:   "packer-plugin-foo",
+		Output: coreOut,
+		Level:  hclog.Trace,
+	})
+	if err := client.ForwardLogs(coreLogger, hclog.Info); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	pluginLogger.Debug("dropped")
+	pluginLogger.Named("ssh").Warn("connection lost", "attempt", 2)
+	pluginLogger.Info("done")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(coreOut.String(), "done") {
+		if time.Now().After(deadline) {
+			t.Fatalf("logs not forwarded: %q", coreOut.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	out := coreOut.String()
+	if strings.Contains(out, "dropped") {
+		t.Fatalf("debug record forwarded: %q", out)
+	}
+	if !strings.Contains(out, "[WARN]  packer-plugin-foo.ssh: connection lost: attempt=2") {
+		t.Fatalf("bad forwarded logs: %q", out)
+	}
+	if pluginOut.String() != "" {
+		t.Fatalf("plugin still writes its logs: %q", pluginOut.String())
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	cases := []struct {
+		log, pluginLog string
+		expected       hclog.Level
+	}{
+		{"", "", hclog.Off},
+		{"0", "", hclog.Off},
+		{"1", "", hclog.Trace},
+		{"debug", "", hclog.Debug},
+		{"1", "warn", hclog.Warn},
+		{"", "INFO", hclog.Info},
+		{"debug", "off", hclog.Off},
+		{"bogus", "", hclog.Trace},
+	}
+	for _, tc := range cases {
+		t.Setenv("PACKER_LOG", tc.log)
+		t.Setenv("PACKER_LOG_AMAZON_EBS", tc.pluginLog)
+		if level := LogLevel("amazon-ebs"); level != tc.expected {
+			t.Errorf("PACKER_LOG=%q PACKER_LOG_AMAZON_EBS=%q: expected %s, got %s", tc.log, tc.pluginLog, tc.expected, level)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -24,6 +25,17 @@ type muxBroker struct {
 	nextId  uint32
 	session *yamux.Session
 	streams map[uint32]*muxBrokerPending
+	// uiBuffer, when set, makes the Uis of the clients using the broker
+	// buffer their messages.
+	uiBuffer *UiBufferConfig
+	// retry, when set, makes the clients using the broker retry their
+	// idempotent calls.
+	retry *RetryConfig
+	// base, when set, is the context the builds, provisionings,
+	// post-processings and hooks served through the broker derive from.
+	base context.Context
+	// noTrace is set once the other end failed to find SetTraceContext.
+	noTrace bool
 
 	sync.Mutex
 }
@@ -122,6 +134,48 @@ func (m *muxBroker) NextId() uint32 {
 	return atomic.AddUint32(&m.nextId, 1)
 }
 
+func (m *muxBroker) setUiBufferConfig(config *UiBufferConfig) {
+	m.Lock()
+	defer m.Unlock()
+	m.uiBuffer = config
+}
+
+func (m *muxBroker) uiBufferConfig() *UiBufferConfig {
+	m.Lock()
+	defer m.Unlock()
+	return m.uiBuffer
+}
+
+func (m *muxBroker) setRetryConfig(config *RetryConfig) {
+	m.Lock()
+	defer m.Unlock()
+	m.retry = config
+}
+
+func (m *muxBroker) retryConfig() *RetryConfig {
+	m.Lock()
+	defer m.Unlock()
+	return m.retry
+}
+
+func (m *muxBroker) setBaseContext(ctx context.Context) {
+	m.Lock()
+	defer m.Unlock()
+	m.base = ctx
+}
+
+// newContext returns the context of a build, provisioning, post-processing
+// or hook run, cancelled by Packer calling Cancel or by the base context.
+func (m *muxBroker) newContext() (context.Context, context.CancelFunc) {
+	m.Lock()
+	base := m.base
+	m.Unlock()
+	if base == nil {
+		base = context.Background()
+	}
+	return context.WithCancel(base)
+}
+
 // Run starts the brokering and should be executed in a goroutine, since it
 // blocks forever, or until the session closes.
 func (m *muxBroker) Run() {
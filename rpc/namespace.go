@@ -0,0 +1,148 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// NamespaceMetadataKey is the gRPC metadata of the calls telling the
+// namespace they belong to, the counterpart of SetNamespace.
+const NamespaceMetadataKey = "packer-namespace"
+
+// A Namespace holds the state of one build served by a plugin process. When
+// Packer serves concurrent builds from one process, globals like caches are
+// shared by all of them: the components should keep such state in the
+// namespace of their build instead, found with NamespaceFromContext in the
+// context passed to Run, Provision and PostProcess.
+type Namespace struct {
+	name string
+
+	lock   sync.Mutex
+	values map[interface{}]interface{}
+}
+
+// Name returns the name of the namespace, usually the name of the build, ""
+// for the default namespace of the calls without one.
+func (n *Namespace) Name() string {
+	return n.name
+}
+
+// Value returns the value of key in the namespace, created with init the
+// first time it is asked for. Keys are compared like map keys, and should be
+// of an unexported type, like the keys of context values.
+func (n *Namespace) Value(key interface{}, init func() interface{}) interface{} {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if v, ok := n.values[key]; ok {
+		return v
+	}
+	if n.values == nil {
+		n.values = make(map[interface{}]interface{})
+	}
+	v := init()
+	n.values[key] = v
+	return v
+}
+
+// Logger returns a logger writing to the output of the log package, with
+// the lines of the namespace prefixed with its name, so that the logs of
+// concurrent builds can be told apart.
+func (n *Namespace) Logger() *log.Logger {
+	prefix := ""
+	if n.name != "" {
+		prefix = "[" + n.name + "] "
+	}
+	return log.New(log.Writer(), prefix, log.Flags()|log.Lmsgprefix)
+}
+
+var namespaces = struct {
+	sync.Mutex
+	m map[string]*Namespace
+}{m: map[string]*Namespace{}}
+
+// namespace returns the namespace name of the process, created on first
+// use. The namespaces of a process are kept as long as it runs, as they
+// are shared by the servers of a build, and there are only so many builds.
+func namespace(name string) *Namespace {
+	namespaces.Lock()
+	defer namespaces.Unlock()
+	ns, ok := namespaces.m[name]
+	if !ok {
+		ns = &Namespace{name: name}
+		namespaces.m[name] = ns
+	}
+	return ns
+}
+
+type namespaceKey struct{}
+
+// WithNamespace returns a copy of ctx carrying ns.
+func WithNamespace(ctx context.Context, ns *Namespace) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, ns)
+}
+
+// NamespaceFromContext returns the namespace of the call ctx is the context
+// of, the default namespace when Packer didn't set one.
+func NamespaceFromContext(ctx context.Context) *Namespace {
+	if ns, ok := ctx.Value(namespaceKey{}).(*Namespace); ok {
+		return ns
+	}
+	return namespace("")
+}
+
+// Namespaced is implemented by the clients of the components whose plugins
+// can isolate the state of concurrent builds, see Namespace.
+type Namespaced interface {
+	// SetNamespace makes the next calls of the client belong to the
+	// namespace name, like the name of the build. Plugins that don't
+	// support namespaces serve them in the default one.
+	SetNamespace(name string) error
+}
+
+func (c *commonClient) SetNamespace(name string) error {
+	err := c.client.Call(c.endpoint+".SetNamespace", name, new(interface{}))
+	if isMethodNotFound(err) {
+		log.Printf("[DEBUG] The plugin does not support namespaces")
+		return nil
+	}
+	return err
+}
+
+// SetNamespace makes the calls served from then on belong to the namespace
+// name.
+func (s *commonServer) SetNamespace(name string, reply *interface{}) error {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	s.ns = namespace(name)
+	return nil
+}
+
+// newContext returns the context of a build, provisioning or
+// post-processing, like muxBroker.newContext, carrying the namespace of the
+// server.
+func (s *commonServer) newContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := s.mux.newContext()
+	s.nsLock.Lock()
+	ns := s.ns
+	s.nsLock.Unlock()
+	if ns == nil {
+		return ctx, cancel
+	}
+	return WithNamespace(ctx, ns), cancel
+}
+
+// grpcNamespace returns ctx carrying the namespace of the gRPC call it is
+// the context of, as sent in NamespaceMetadataKey.
+func grpcNamespace(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if names := md.Get(NamespaceMetadataKey); len(names) > 0 {
+		return WithNamespace(ctx, namespace(names[0]))
+	}
+	return ctx
+}
@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"google.golang.org/grpc/metadata"
+)
+
+type testCacheKey struct{}
+
+func TestNamespace_Value(t *testing.T) {
+	a, b := namespace("test-value.a"), namespace("test-value.b")
+	if namespace("test-value.a") != a {
+		t.Fatal("a namespace should be shared by the calls of a build")
+	}
+
+	inits := 0
+	newCache := func() interface{} {
+		inits++
+		return map[string]string{}
+	}
+	a.Value(testCacheKey{}, newCache).(map[string]string)["image"] = "ami-1"
+	if got := a.Value(testCacheKey{}, newCache).(map[string]string)["image"]; got != "ami-1" {
+		t.Fatalf("bad value: %q", got)
+	}
+	if got := b.Value(testCacheKey{}, newCache).(map[string]string)["image"]; got != "" {
+		t.Fatalf("namespaces should not share values: %q", got)
+	}
+	if inits != 2 {
+		t.Fatalf("bad inits: %d", inits)
+	}
+}
+
+func TestNamespace_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	defer log.SetFlags(log.Flags())
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+
+	namespace("amazon-ebs.ubuntu").Logger().Printf("[INFO] Creating the image")
+	namespace("").Logger().Printf("[INFO] Done")
+	if got := buf.String(); got != "[amazon-ebs.ubuntu] [INFO] Creating the image\n[INFO] Done\n" {
+		t.Fatalf("bad logs: %q", got)
+	}
+}
+
+func TestNamespaceFromContext(t *testing.T) {
+	if ns := NamespaceFromContext(context.Background()); ns.Name() != "" {
+		t.Fatalf("bad default namespace: %q", ns.Name())
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(NamespaceMetadataKey, "qemu.debian"))
+	if ns := NamespaceFromContext(grpcNamespace(ctx)); ns.Name() != "qemu.debian" {
+		t.Fatalf("bad gRPC namespace: %q", ns.Name())
+	}
+}
+
+func TestBuilder_namespace(t *testing.T) {
+	b := new(packersdk.MockBuilder)
+	var name string
+	b.RunFn = func(ctx context.Context) {
+		name = NamespaceFromContext(ctx).Name()
+	}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	if err := bClient.(Namespaced).SetNamespace("docker.alpine"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := bClient.Run(context.Background(), new(testUi), new(packersdk.MockHook)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if name != "docker.alpine" {
+		t.Fatalf("bad namespace: %q", name)
+	}
+}
+
+func TestSetNamespace_unsupported(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(new(packersdk.MockArtifact))
+
+	// Servers without SetNamespace serve the calls in the default
+	// namespace.
+	c := &commonClient{endpoint: DefaultArtifactEndpoint, client: client.client, mux: client.mux}
+	if err := c.SetNamespace("docker.alpine"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,9 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package pluginproto holds the protocol buffers of the gRPC protocol spoken
+// between Packer and the plugins that opt into it, see plugin.proto. The rpc
+// package implements it for Go plugins.
+package pluginproto
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative plugin.proto
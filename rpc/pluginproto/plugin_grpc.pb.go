@@ -0,0 +1,2144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// The gRPC protocol spoken between Packer and its plugins, an alternative to
+// the net/rpc protocol of the rpc package that can be implemented in any
+// language.
+//
+// Both ends serve gRPC over a single yamux multiplexed connection: Packer
+// calls the component the plugin serves (Builder, Provisioner,
+// PostProcessor or Datasource) and both sides serve the Ui, Hook,
+// Communicator and Artifact services for the objects they pass to each
+// other, which are referenced by an ID unique to the side serving them.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: plugin.proto
+
+package pluginproto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Builder_ConfigSpec_FullMethodName             = "/packer.plugin.Builder/ConfigSpec"
+	Builder_Prepare_FullMethodName                = "/packer.plugin.Builder/Prepare"
+	Builder_SupportsValidateConfig_FullMethodName = "/packer.plugin.Builder/SupportsValidateConfig"
+	Builder_ValidateConfig_FullMethodName         = "/packer.plugin.Builder/ValidateConfig"
+	Builder_Run_FullMethodName                    = "/packer.plugin.Builder/Run"
+	Builder_SupportsEstimate_FullMethodName       = "/packer.plugin.Builder/SupportsEstimate"
+	Builder_Estimate_FullMethodName               = "/packer.plugin.Builder/Estimate"
+)
+
+// BuilderClient is the client API for Builder service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BuilderClient interface {
+	ConfigSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error)
+	Prepare(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error)
+	SupportsValidateConfig(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error)
+	ValidateConfig(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*ValidateConfigResponse, error)
+	// Run builds the artifact; cancelling the call cancels the build.
+	Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*RunResponse, error)
+	SupportsEstimate(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error)
+	Estimate(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*EstimateResponse, error)
+}
+
+type builderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBuilderClient(cc grpc.ClientConnInterface) BuilderClient {
+	return &builderClient{cc}
+}
+
+func (c *builderClient) ConfigSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error) {
+	out := new(Spec)
+	err := c.cc.Invoke(ctx, Builder_ConfigSpec_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) Prepare(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error) {
+	out := new(PrepareResponse)
+	err := c.cc.Invoke(ctx, Builder_Prepare_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) SupportsValidateConfig(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error) {
+	out := new(Supported)
+	err := c.cc.Invoke(ctx, Builder_SupportsValidateConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) ValidateConfig(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*ValidateConfigResponse, error) {
+	out := new(ValidateConfigResponse)
+	err := c.cc.Invoke(ctx, Builder_ValidateConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*RunResponse, error) {
+	out := new(RunResponse)
+	err := c.cc.Invoke(ctx, Builder_Run_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) SupportsEstimate(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error) {
+	out := new(Supported)
+	err := c.cc.Invoke(ctx, Builder_SupportsEstimate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) Estimate(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*EstimateResponse, error) {
+	out := new(EstimateResponse)
+	err := c.cc.Invoke(ctx, Builder_Estimate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BuilderServer is the server API for Builder service.
+// All implementations must embed UnimplementedBuilderServer
+// for forward compatibility
+type BuilderServer interface {
+	ConfigSpec(context.Context, *Empty) (*Spec, error)
+	Prepare(context.Context, *PrepareRequest) (*PrepareResponse, error)
+	SupportsValidateConfig(context.Context, *Empty) (*Supported, error)
+	ValidateConfig(context.Context, *PrepareRequest) (*ValidateConfigResponse, error)
+	// Run builds the artifact; cancelling the call cancels the build.
+	Run(context.Context, *RunRequest) (*RunResponse, error)
+	SupportsEstimate(context.Context, *Empty) (*Supported, error)
+	Estimate(context.Context, *Empty) (*EstimateResponse, error)
+	mustEmbedUnimplementedBuilderServer()
+}
+
+// UnimplementedBuilderServer must be embedded to have forward compatible implementations.
+type UnimplementedBuilderServer struct {
+}
+
+func (UnimplementedBuilderServer) ConfigSpec(context.Context, *Empty) (*Spec, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfigSpec not implemented")
+}
+func (UnimplementedBuilderServer) Prepare(context.Context, *PrepareRequest) (*PrepareResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Prepare not implemented")
+}
+func (UnimplementedBuilderServer) SupportsValidateConfig(context.Context, *Empty) (*Supported, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SupportsValidateConfig not implemented")
+}
+func (UnimplementedBuilderServer) ValidateConfig(context.Context, *PrepareRequest) (*ValidateConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateConfig not implemented")
+}
+func (UnimplementedBuilderServer) Run(context.Context, *RunRequest) (*RunResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Run not implemented")
+}
+func (UnimplementedBuilderServer) SupportsEstimate(context.Context, *Empty) (*Supported, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SupportsEstimate not implemented")
+}
+func (UnimplementedBuilderServer) Estimate(context.Context, *Empty) (*EstimateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Estimate not implemented")
+}
+func (UnimplementedBuilderServer) mustEmbedUnimplementedBuilderServer() {}
+
+// UnsafeBuilderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BuilderServer will
+// result in compilation errors.
+type UnsafeBuilderServer interface {
+	mustEmbedUnimplementedBuilderServer()
+}
+
+func RegisterBuilderServer(s grpc.ServiceRegistrar, srv BuilderServer) {
+	s.RegisterService(&Builder_ServiceDesc, srv)
+}
+
+func _Builder_ConfigSpec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).ConfigSpec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Builder_ConfigSpec_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).ConfigSpec(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_Prepare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).Prepare(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Builder_Prepare_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).Prepare(ctx, req.(*PrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_SupportsValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).SupportsValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Builder_SupportsValidateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).SupportsValidateConfig(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_ValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).ValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Builder_ValidateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).ValidateConfig(ctx, req.(*PrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_Run_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).Run(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Builder_Run_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).Run(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_SupportsEstimate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).SupportsEstimate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Builder_SupportsEstimate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).SupportsEstimate(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_Estimate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).Estimate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Builder_Estimate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).Estimate(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Builder_ServiceDesc is the grpc.ServiceDesc for Builder service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Builder_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "packer.plugin.Builder",
+	HandlerType: (*BuilderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ConfigSpec",
+			Handler:    _Builder_ConfigSpec_Handler,
+		},
+		{
+			MethodName: "Prepare",
+			Handler:    _Builder_Prepare_Handler,
+		},
+		{
+			MethodName: "SupportsValidateConfig",
+			Handler:    _Builder_SupportsValidateConfig_Handler,
+		},
+		{
+			MethodName: "ValidateConfig",
+			Handler:    _Builder_ValidateConfig_Handler,
+		},
+		{
+			MethodName: "Run",
+			Handler:    _Builder_Run_Handler,
+		},
+		{
+			MethodName: "SupportsEstimate",
+			Handler:    _Builder_SupportsEstimate_Handler,
+		},
+		{
+			MethodName: "Estimate",
+			Handler:    _Builder_Estimate_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "plugin.proto",
+}
+
+const (
+	Provisioner_ConfigSpec_FullMethodName             = "/packer.plugin.Provisioner/ConfigSpec"
+	Provisioner_Prepare_FullMethodName                = "/packer.plugin.Provisioner/Prepare"
+	Provisioner_SupportsValidateConfig_FullMethodName = "/packer.plugin.Provisioner/SupportsValidateConfig"
+	Provisioner_ValidateConfig_FullMethodName         = "/packer.plugin.Provisioner/ValidateConfig"
+	Provisioner_Provision_FullMethodName              = "/packer.plugin.Provisioner/Provision"
+)
+
+// ProvisionerClient is the client API for Provisioner service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ProvisionerClient interface {
+	ConfigSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error)
+	Prepare(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error)
+	SupportsValidateConfig(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error)
+	ValidateConfig(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*ValidateConfigResponse, error)
+	Provision(ctx context.Context, in *ProvisionRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type provisionerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProvisionerClient(cc grpc.ClientConnInterface) ProvisionerClient {
+	return &provisionerClient{cc}
+}
+
+func (c *provisionerClient) ConfigSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error) {
+	out := new(Spec)
+	err := c.cc.Invoke(ctx, Provisioner_ConfigSpec_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *provisionerClient) Prepare(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error) {
+	out := new(PrepareResponse)
+	err := c.cc.Invoke(ctx, Provisioner_Prepare_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *provisionerClient) SupportsValidateConfig(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error) {
+	out := new(Supported)
+	err := c.cc.Invoke(ctx, Provisioner_SupportsValidateConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *provisionerClient) ValidateConfig(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*ValidateConfigResponse, error) {
+	out := new(ValidateConfigResponse)
+	err := c.cc.Invoke(ctx, Provisioner_ValidateConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *provisionerClient) Provision(ctx context.Context, in *ProvisionRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Provisioner_Provision_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ProvisionerServer is the server API for Provisioner service.
+// All implementations must embed UnimplementedProvisionerServer
+// for forward compatibility
+type ProvisionerServer interface {
+	ConfigSpec(context.Context, *Empty) (*Spec, error)
+	Prepare(context.Context, *PrepareRequest) (*PrepareResponse, error)
+	SupportsValidateConfig(context.Context, *Empty) (*Supported, error)
+	ValidateConfig(context.Context, *PrepareRequest) (*ValidateConfigResponse, error)
+	Provision(context.Context, *ProvisionRequest) (*Empty, error)
+	mustEmbedUnimplementedProvisionerServer()
+}
+
+// UnimplementedProvisionerServer must be embedded to have forward compatible implementations.
+type UnimplementedProvisionerServer struct {
+}
+
+func (UnimplementedProvisionerServer) ConfigSpec(context.Context, *Empty) (*Spec, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfigSpec not implemented")
+}
+func (UnimplementedProvisionerServer) Prepare(context.Context, *PrepareRequest) (*PrepareResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Prepare not implemented")
+}
+func (UnimplementedProvisionerServer) SupportsValidateConfig(context.Context, *Empty) (*Supported, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SupportsValidateConfig not implemented")
+}
+func (UnimplementedProvisionerServer) ValidateConfig(context.Context, *PrepareRequest) (*ValidateConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateConfig not implemented")
+}
+func (UnimplementedProvisionerServer) Provision(context.Context, *ProvisionRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Provision not implemented")
+}
+func (UnimplementedProvisionerServer) mustEmbedUnimplementedProvisionerServer() {}
+
+// UnsafeProvisionerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProvisionerServer will
+// result in compilation errors.
+type UnsafeProvisionerServer interface {
+	mustEmbedUnimplementedProvisionerServer()
+}
+
+func RegisterProvisionerServer(s grpc.ServiceRegistrar, srv ProvisionerServer) {
+	s.RegisterService(&Provisioner_ServiceDesc, srv)
+}
+
+func _Provisioner_ConfigSpec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProvisionerServer).ConfigSpec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provisioner_ConfigSpec_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProvisionerServer).ConfigSpec(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provisioner_Prepare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProvisionerServer).Prepare(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provisioner_Prepare_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProvisionerServer).Prepare(ctx, req.(*PrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provisioner_SupportsValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProvisionerServer).SupportsValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provisioner_SupportsValidateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProvisionerServer).SupportsValidateConfig(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provisioner_ValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProvisionerServer).ValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provisioner_ValidateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProvisionerServer).ValidateConfig(ctx, req.(*PrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provisioner_Provision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProvisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProvisionerServer).Provision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provisioner_Provision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProvisionerServer).Provision(ctx, req.(*ProvisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Provisioner_ServiceDesc is the grpc.ServiceDesc for Provisioner service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Provisioner_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "packer.plugin.Provisioner",
+	HandlerType: (*ProvisionerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ConfigSpec",
+			Handler:    _Provisioner_ConfigSpec_Handler,
+		},
+		{
+			MethodName: "Prepare",
+			Handler:    _Provisioner_Prepare_Handler,
+		},
+		{
+			MethodName: "SupportsValidateConfig",
+			Handler:    _Provisioner_SupportsValidateConfig_Handler,
+		},
+		{
+			MethodName: "ValidateConfig",
+			Handler:    _Provisioner_ValidateConfig_Handler,
+		},
+		{
+			MethodName: "Provision",
+			Handler:    _Provisioner_Provision_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "plugin.proto",
+}
+
+const (
+	PostProcessor_ConfigSpec_FullMethodName             = "/packer.plugin.PostProcessor/ConfigSpec"
+	PostProcessor_Configure_FullMethodName              = "/packer.plugin.PostProcessor/Configure"
+	PostProcessor_SupportsValidateConfig_FullMethodName = "/packer.plugin.PostProcessor/SupportsValidateConfig"
+	PostProcessor_ValidateConfig_FullMethodName         = "/packer.plugin.PostProcessor/ValidateConfig"
+	PostProcessor_PostProcess_FullMethodName            = "/packer.plugin.PostProcessor/PostProcess"
+)
+
+// PostProcessorClient is the client API for PostProcessor service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PostProcessorClient interface {
+	ConfigSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error)
+	Configure(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error)
+	SupportsValidateConfig(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error)
+	ValidateConfig(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*ValidateConfigResponse, error)
+	PostProcess(ctx context.Context, in *PostProcessRequest, opts ...grpc.CallOption) (*PostProcessResponse, error)
+}
+
+type postProcessorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPostProcessorClient(cc grpc.ClientConnInterface) PostProcessorClient {
+	return &postProcessorClient{cc}
+}
+
+func (c *postProcessorClient) ConfigSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error) {
+	out := new(Spec)
+	err := c.cc.Invoke(ctx, PostProcessor_ConfigSpec_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *postProcessorClient) Configure(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error) {
+	out := new(PrepareResponse)
+	err := c.cc.Invoke(ctx, PostProcessor_Configure_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *postProcessorClient) SupportsValidateConfig(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error) {
+	out := new(Supported)
+	err := c.cc.Invoke(ctx, PostProcessor_SupportsValidateConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *postProcessorClient) ValidateConfig(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*ValidateConfigResponse, error) {
+	out := new(ValidateConfigResponse)
+	err := c.cc.Invoke(ctx, PostProcessor_ValidateConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *postProcessorClient) PostProcess(ctx context.Context, in *PostProcessRequest, opts ...grpc.CallOption) (*PostProcessResponse, error) {
+	out := new(PostProcessResponse)
+	err := c.cc.Invoke(ctx, PostProcessor_PostProcess_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PostProcessorServer is the server API for PostProcessor service.
+// All implementations must embed UnimplementedPostProcessorServer
+// for forward compatibility
+type PostProcessorServer interface {
+	ConfigSpec(context.Context, *Empty) (*Spec, error)
+	Configure(context.Context, *PrepareRequest) (*PrepareResponse, error)
+	SupportsValidateConfig(context.Context, *Empty) (*Supported, error)
+	ValidateConfig(context.Context, *PrepareRequest) (*ValidateConfigResponse, error)
+	PostProcess(context.Context, *PostProcessRequest) (*PostProcessResponse, error)
+	mustEmbedUnimplementedPostProcessorServer()
+}
+
+// UnimplementedPostProcessorServer must be embedded to have forward compatible implementations.
+type UnimplementedPostProcessorServer struct {
+}
+
+func (UnimplementedPostProcessorServer) ConfigSpec(context.Context, *Empty) (*Spec, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfigSpec not implemented")
+}
+func (UnimplementedPostProcessorServer) Configure(context.Context, *PrepareRequest) (*PrepareResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Configure not implemented")
+}
+func (UnimplementedPostProcessorServer) SupportsValidateConfig(context.Context, *Empty) (*Supported, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SupportsValidateConfig not implemented")
+}
+func (UnimplementedPostProcessorServer) ValidateConfig(context.Context, *PrepareRequest) (*ValidateConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateConfig not implemented")
+}
+func (UnimplementedPostProcessorServer) PostProcess(context.Context, *PostProcessRequest) (*PostProcessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PostProcess not implemented")
+}
+func (UnimplementedPostProcessorServer) mustEmbedUnimplementedPostProcessorServer() {}
+
+// UnsafePostProcessorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PostProcessorServer will
+// result in compilation errors.
+type UnsafePostProcessorServer interface {
+	mustEmbedUnimplementedPostProcessorServer()
+}
+
+func RegisterPostProcessorServer(s grpc.ServiceRegistrar, srv PostProcessorServer) {
+	s.RegisterService(&PostProcessor_ServiceDesc, srv)
+}
+
+func _PostProcessor_ConfigSpec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostProcessorServer).ConfigSpec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PostProcessor_ConfigSpec_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostProcessorServer).ConfigSpec(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PostProcessor_Configure_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostProcessorServer).Configure(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PostProcessor_Configure_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostProcessorServer).Configure(ctx, req.(*PrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PostProcessor_SupportsValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostProcessorServer).SupportsValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PostProcessor_SupportsValidateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostProcessorServer).SupportsValidateConfig(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PostProcessor_ValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostProcessorServer).ValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PostProcessor_ValidateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostProcessorServer).ValidateConfig(ctx, req.(*PrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PostProcessor_PostProcess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PostProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PostProcessorServer).PostProcess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PostProcessor_PostProcess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PostProcessorServer).PostProcess(ctx, req.(*PostProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PostProcessor_ServiceDesc is the grpc.ServiceDesc for PostProcessor service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PostProcessor_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "packer.plugin.PostProcessor",
+	HandlerType: (*PostProcessorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ConfigSpec",
+			Handler:    _PostProcessor_ConfigSpec_Handler,
+		},
+		{
+			MethodName: "Configure",
+			Handler:    _PostProcessor_Configure_Handler,
+		},
+		{
+			MethodName: "SupportsValidateConfig",
+			Handler:    _PostProcessor_SupportsValidateConfig_Handler,
+		},
+		{
+			MethodName: "ValidateConfig",
+			Handler:    _PostProcessor_ValidateConfig_Handler,
+		},
+		{
+			MethodName: "PostProcess",
+			Handler:    _PostProcessor_PostProcess_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "plugin.proto",
+}
+
+const (
+	Datasource_ConfigSpec_FullMethodName             = "/packer.plugin.Datasource/ConfigSpec"
+	Datasource_Configure_FullMethodName              = "/packer.plugin.Datasource/Configure"
+	Datasource_SupportsValidateConfig_FullMethodName = "/packer.plugin.Datasource/SupportsValidateConfig"
+	Datasource_ValidateConfig_FullMethodName         = "/packer.plugin.Datasource/ValidateConfig"
+	Datasource_OutputSpec_FullMethodName             = "/packer.plugin.Datasource/OutputSpec"
+	Datasource_Execute_FullMethodName                = "/packer.plugin.Datasource/Execute"
+	Datasource_ExecuteStream_FullMethodName          = "/packer.plugin.Datasource/ExecuteStream"
+	Datasource_Dependencies_FullMethodName           = "/packer.plugin.Datasource/Dependencies"
+	Datasource_Release_FullMethodName                = "/packer.plugin.Datasource/Release"
+)
+
+// DatasourceClient is the client API for Datasource service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DatasourceClient interface {
+	ConfigSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error)
+	Configure(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error)
+	SupportsValidateConfig(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error)
+	ValidateConfig(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*ValidateConfigResponse, error)
+	OutputSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error)
+	// Execute executes the datasource; cancelling the call cancels it.
+	Execute(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Value, error)
+	// ExecuteStream executes the datasource like Execute, sending the
+	// elements of the streamed attribute of a StreamingDatasource in chunks.
+	ExecuteStream(ctx context.Context, in *ExecuteStreamRequest, opts ...grpc.CallOption) (Datasource_ExecuteStreamClient, error)
+	Dependencies(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Dependencies, error)
+	Release(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type datasourceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDatasourceClient(cc grpc.ClientConnInterface) DatasourceClient {
+	return &datasourceClient{cc}
+}
+
+func (c *datasourceClient) ConfigSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error) {
+	out := new(Spec)
+	err := c.cc.Invoke(ctx, Datasource_ConfigSpec_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceClient) Configure(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error) {
+	out := new(PrepareResponse)
+	err := c.cc.Invoke(ctx, Datasource_Configure_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceClient) SupportsValidateConfig(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Supported, error) {
+	out := new(Supported)
+	err := c.cc.Invoke(ctx, Datasource_SupportsValidateConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceClient) ValidateConfig(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*ValidateConfigResponse, error) {
+	out := new(ValidateConfigResponse)
+	err := c.cc.Invoke(ctx, Datasource_ValidateConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceClient) OutputSpec(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Spec, error) {
+	out := new(Spec)
+	err := c.cc.Invoke(ctx, Datasource_OutputSpec_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceClient) Execute(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Value, error) {
+	out := new(Value)
+	err := c.cc.Invoke(ctx, Datasource_Execute_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceClient) ExecuteStream(ctx context.Context, in *ExecuteStreamRequest, opts ...grpc.CallOption) (Datasource_ExecuteStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Datasource_ServiceDesc.Streams[0], Datasource_ExecuteStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &datasourceExecuteStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Datasource_ExecuteStreamClient interface {
+	Recv() (*DatasourceOutput, error)
+	grpc.ClientStream
+}
+
+type datasourceExecuteStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *datasourceExecuteStreamClient) Recv() (*DatasourceOutput, error) {
+	m := new(DatasourceOutput)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *datasourceClient) Dependencies(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Dependencies, error) {
+	out := new(Dependencies)
+	err := c.cc.Invoke(ctx, Datasource_Dependencies_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceClient) Release(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Datasource_Release_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DatasourceServer is the server API for Datasource service.
+// All implementations must embed UnimplementedDatasourceServer
+// for forward compatibility
+type DatasourceServer interface {
+	ConfigSpec(context.Context, *Empty) (*Spec, error)
+	Configure(context.Context, *PrepareRequest) (*PrepareResponse, error)
+	SupportsValidateConfig(context.Context, *Empty) (*Supported, error)
+	ValidateConfig(context.Context, *PrepareRequest) (*ValidateConfigResponse, error)
+	OutputSpec(context.Context, *Empty) (*Spec, error)
+	// Execute executes the datasource; cancelling the call cancels it.
+	Execute(context.Context, *Empty) (*Value, error)
+	// ExecuteStream executes the datasource like Execute, sending the
+	// elements of the streamed attribute of a StreamingDatasource in chunks.
+	ExecuteStream(*ExecuteStreamRequest, Datasource_ExecuteStreamServer) error
+	Dependencies(context.Context, *Empty) (*Dependencies, error)
+	Release(context.Context, *Empty) (*Empty, error)
+	mustEmbedUnimplementedDatasourceServer()
+}
+
+// UnimplementedDatasourceServer must be embedded to have forward compatible implementations.
+type UnimplementedDatasourceServer struct {
+}
+
+func (UnimplementedDatasourceServer) ConfigSpec(context.Context, *Empty) (*Spec, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfigSpec not implemented")
+}
+func (UnimplementedDatasourceServer) Configure(context.Context, *PrepareRequest) (*PrepareResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Configure not implemented")
+}
+func (UnimplementedDatasourceServer) SupportsValidateConfig(context.Context, *Empty) (*Supported, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SupportsValidateConfig not implemented")
+}
+func (UnimplementedDatasourceServer) ValidateConfig(context.Context, *PrepareRequest) (*ValidateConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateConfig not implemented")
+}
+func (UnimplementedDatasourceServer) OutputSpec(context.Context, *Empty) (*Spec, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OutputSpec not implemented")
+}
+func (UnimplementedDatasourceServer) Execute(context.Context, *Empty) (*Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Execute not implemented")
+}
+func (UnimplementedDatasourceServer) ExecuteStream(*ExecuteStreamRequest, Datasource_ExecuteStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExecuteStream not implemented")
+}
+func (UnimplementedDatasourceServer) Dependencies(context.Context, *Empty) (*Dependencies, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Dependencies not implemented")
+}
+func (UnimplementedDatasourceServer) Release(context.Context, *Empty) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Release not implemented")
+}
+func (UnimplementedDatasourceServer) mustEmbedUnimplementedDatasourceServer() {}
+
+// UnsafeDatasourceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DatasourceServer will
+// result in compilation errors.
+type UnsafeDatasourceServer interface {
+	mustEmbedUnimplementedDatasourceServer()
+}
+
+func RegisterDatasourceServer(s grpc.ServiceRegistrar, srv DatasourceServer) {
+	s.RegisterService(&Datasource_ServiceDesc, srv)
+}
+
+func _Datasource_ConfigSpec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServer).ConfigSpec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datasource_ConfigSpec_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServer).ConfigSpec(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datasource_Configure_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServer).Configure(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datasource_Configure_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServer).Configure(ctx, req.(*PrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datasource_SupportsValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServer).SupportsValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datasource_SupportsValidateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServer).SupportsValidateConfig(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datasource_ValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServer).ValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datasource_ValidateConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServer).ValidateConfig(ctx, req.(*PrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datasource_OutputSpec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServer).OutputSpec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datasource_OutputSpec_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServer).OutputSpec(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datasource_Execute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServer).Execute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datasource_Execute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServer).Execute(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datasource_ExecuteStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecuteStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DatasourceServer).ExecuteStream(m, &datasourceExecuteStreamServer{stream})
+}
+
+type Datasource_ExecuteStreamServer interface {
+	Send(*DatasourceOutput) error
+	grpc.ServerStream
+}
+
+type datasourceExecuteStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *datasourceExecuteStreamServer) Send(m *DatasourceOutput) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Datasource_Dependencies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServer).Dependencies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datasource_Dependencies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServer).Dependencies(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Datasource_Release_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServer).Release(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Datasource_Release_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServer).Release(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Datasource_ServiceDesc is the grpc.ServiceDesc for Datasource service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Datasource_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "packer.plugin.Datasource",
+	HandlerType: (*DatasourceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ConfigSpec",
+			Handler:    _Datasource_ConfigSpec_Handler,
+		},
+		{
+			MethodName: "Configure",
+			Handler:    _Datasource_Configure_Handler,
+		},
+		{
+			MethodName: "SupportsValidateConfig",
+			Handler:    _Datasource_SupportsValidateConfig_Handler,
+		},
+		{
+			MethodName: "ValidateConfig",
+			Handler:    _Datasource_ValidateConfig_Handler,
+		},
+		{
+			MethodName: "OutputSpec",
+			Handler:    _Datasource_OutputSpec_Handler,
+		},
+		{
+			MethodName: "Execute",
+			Handler:    _Datasource_Execute_Handler,
+		},
+		{
+			MethodName: "Dependencies",
+			Handler:    _Datasource_Dependencies_Handler,
+		},
+		{
+			MethodName: "Release",
+			Handler:    _Datasource_Release_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExecuteStream",
+			Handler:       _Datasource_ExecuteStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "plugin.proto",
+}
+
+const (
+	Ui_Ask_FullMethodName     = "/packer.plugin.Ui/Ask"
+	Ui_Say_FullMethodName     = "/packer.plugin.Ui/Say"
+	Ui_Message_FullMethodName = "/packer.plugin.Ui/Message"
+	Ui_Error_FullMethodName   = "/packer.plugin.Ui/Error"
+	Ui_Machine_FullMethodName = "/packer.plugin.Ui/Machine"
+)
+
+// UiClient is the client API for Ui service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UiClient interface {
+	Ask(ctx context.Context, in *UiRequest, opts ...grpc.CallOption) (*AskResponse, error)
+	Say(ctx context.Context, in *UiRequest, opts ...grpc.CallOption) (*Empty, error)
+	Message(ctx context.Context, in *UiRequest, opts ...grpc.CallOption) (*Empty, error)
+	Error(ctx context.Context, in *UiRequest, opts ...grpc.CallOption) (*Empty, error)
+	Machine(ctx context.Context, in *MachineRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type uiClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUiClient(cc grpc.ClientConnInterface) UiClient {
+	return &uiClient{cc}
+}
+
+func (c *uiClient) Ask(ctx context.Context, in *UiRequest, opts ...grpc.CallOption) (*AskResponse, error) {
+	out := new(AskResponse)
+	err := c.cc.Invoke(ctx, Ui_Ask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uiClient) Say(ctx context.Context, in *UiRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Ui_Say_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uiClient) Message(ctx context.Context, in *UiRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Ui_Message_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uiClient) Error(ctx context.Context, in *UiRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Ui_Error_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uiClient) Machine(ctx context.Context, in *MachineRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Ui_Machine_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UiServer is the server API for Ui service.
+// All implementations must embed UnimplementedUiServer
+// for forward compatibility
+type UiServer interface {
+	Ask(context.Context, *UiRequest) (*AskResponse, error)
+	Say(context.Context, *UiRequest) (*Empty, error)
+	Message(context.Context, *UiRequest) (*Empty, error)
+	Error(context.Context, *UiRequest) (*Empty, error)
+	Machine(context.Context, *MachineRequest) (*Empty, error)
+	mustEmbedUnimplementedUiServer()
+}
+
+// UnimplementedUiServer must be embedded to have forward compatible implementations.
+type UnimplementedUiServer struct {
+}
+
+func (UnimplementedUiServer) Ask(context.Context, *UiRequest) (*AskResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ask not implemented")
+}
+func (UnimplementedUiServer) Say(context.Context, *UiRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Say not implemented")
+}
+func (UnimplementedUiServer) Message(context.Context, *UiRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Message not implemented")
+}
+func (UnimplementedUiServer) Error(context.Context, *UiRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Error not implemented")
+}
+func (UnimplementedUiServer) Machine(context.Context, *MachineRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Machine not implemented")
+}
+func (UnimplementedUiServer) mustEmbedUnimplementedUiServer() {}
+
+// UnsafeUiServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UiServer will
+// result in compilation errors.
+type UnsafeUiServer interface {
+	mustEmbedUnimplementedUiServer()
+}
+
+func RegisterUiServer(s grpc.ServiceRegistrar, srv UiServer) {
+	s.RegisterService(&Ui_ServiceDesc, srv)
+}
+
+func _Ui_Ask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UiRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UiServer).Ask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ui_Ask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UiServer).Ask(ctx, req.(*UiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ui_Say_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UiRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UiServer).Say(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ui_Say_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UiServer).Say(ctx, req.(*UiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ui_Message_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UiRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UiServer).Message(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ui_Message_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UiServer).Message(ctx, req.(*UiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ui_Error_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UiRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UiServer).Error(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ui_Error_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UiServer).Error(ctx, req.(*UiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ui_Machine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MachineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UiServer).Machine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ui_Machine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UiServer).Machine(ctx, req.(*MachineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Ui_ServiceDesc is the grpc.ServiceDesc for Ui service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Ui_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "packer.plugin.Ui",
+	HandlerType: (*UiServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ask",
+			Handler:    _Ui_Ask_Handler,
+		},
+		{
+			MethodName: "Say",
+			Handler:    _Ui_Say_Handler,
+		},
+		{
+			MethodName: "Message",
+			Handler:    _Ui_Message_Handler,
+		},
+		{
+			MethodName: "Error",
+			Handler:    _Ui_Error_Handler,
+		},
+		{
+			MethodName: "Machine",
+			Handler:    _Ui_Machine_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "plugin.proto",
+}
+
+const (
+	Hook_Run_FullMethodName = "/packer.plugin.Hook/Run"
+)
+
+// HookClient is the client API for Hook service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HookClient interface {
+	Run(ctx context.Context, in *HookRunRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type hookClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHookClient(cc grpc.ClientConnInterface) HookClient {
+	return &hookClient{cc}
+}
+
+func (c *hookClient) Run(ctx context.Context, in *HookRunRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Hook_Run_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HookServer is the server API for Hook service.
+// All implementations must embed UnimplementedHookServer
+// for forward compatibility
+type HookServer interface {
+	Run(context.Context, *HookRunRequest) (*Empty, error)
+	mustEmbedUnimplementedHookServer()
+}
+
+// UnimplementedHookServer must be embedded to have forward compatible implementations.
+type UnimplementedHookServer struct {
+}
+
+func (UnimplementedHookServer) Run(context.Context, *HookRunRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Run not implemented")
+}
+func (UnimplementedHookServer) mustEmbedUnimplementedHookServer() {}
+
+// UnsafeHookServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HookServer will
+// result in compilation errors.
+type UnsafeHookServer interface {
+	mustEmbedUnimplementedHookServer()
+}
+
+func RegisterHookServer(s grpc.ServiceRegistrar, srv HookServer) {
+	s.RegisterService(&Hook_ServiceDesc, srv)
+}
+
+func _Hook_Run_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HookRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HookServer).Run(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Hook_Run_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HookServer).Run(ctx, req.(*HookRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Hook_ServiceDesc is the grpc.ServiceDesc for Hook service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Hook_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "packer.plugin.Hook",
+	HandlerType: (*HookServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Run",
+			Handler:    _Hook_Run_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "plugin.proto",
+}
+
+const (
+	Communicator_Start_FullMethodName       = "/packer.plugin.Communicator/Start"
+	Communicator_Upload_FullMethodName      = "/packer.plugin.Communicator/Upload"
+	Communicator_UploadDir_FullMethodName   = "/packer.plugin.Communicator/UploadDir"
+	Communicator_Download_FullMethodName    = "/packer.plugin.Communicator/Download"
+	Communicator_DownloadDir_FullMethodName = "/packer.plugin.Communicator/DownloadDir"
+)
+
+// CommunicatorClient is the client API for Communicator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CommunicatorClient interface {
+	Start(ctx context.Context, opts ...grpc.CallOption) (Communicator_StartClient, error)
+	Upload(ctx context.Context, opts ...grpc.CallOption) (Communicator_UploadClient, error)
+	UploadDir(ctx context.Context, in *DirRequest, opts ...grpc.CallOption) (*Empty, error)
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (Communicator_DownloadClient, error)
+	DownloadDir(ctx context.Context, in *DirRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type communicatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCommunicatorClient(cc grpc.ClientConnInterface) CommunicatorClient {
+	return &communicatorClient{cc}
+}
+
+func (c *communicatorClient) Start(ctx context.Context, opts ...grpc.CallOption) (Communicator_StartClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Communicator_ServiceDesc.Streams[0], Communicator_Start_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &communicatorStartClient{stream}
+	return x, nil
+}
+
+type Communicator_StartClient interface {
+	Send(*StartInput) error
+	Recv() (*StartOutput, error)
+	grpc.ClientStream
+}
+
+type communicatorStartClient struct {
+	grpc.ClientStream
+}
+
+func (x *communicatorStartClient) Send(m *StartInput) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *communicatorStartClient) Recv() (*StartOutput, error) {
+	m := new(StartOutput)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *communicatorClient) Upload(ctx context.Context, opts ...grpc.CallOption) (Communicator_UploadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Communicator_ServiceDesc.Streams[1], Communicator_Upload_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &communicatorUploadClient{stream}
+	return x, nil
+}
+
+type Communicator_UploadClient interface {
+	Send(*UploadInput) error
+	CloseAndRecv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type communicatorUploadClient struct {
+	grpc.ClientStream
+}
+
+func (x *communicatorUploadClient) Send(m *UploadInput) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *communicatorUploadClient) CloseAndRecv() (*Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *communicatorClient) UploadDir(ctx context.Context, in *DirRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Communicator_UploadDir_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *communicatorClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (Communicator_DownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Communicator_ServiceDesc.Streams[2], Communicator_Download_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &communicatorDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Communicator_DownloadClient interface {
+	Recv() (*Chunk, error)
+	grpc.ClientStream
+}
+
+type communicatorDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *communicatorDownloadClient) Recv() (*Chunk, error) {
+	m := new(Chunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *communicatorClient) DownloadDir(ctx context.Context, in *DirRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Communicator_DownloadDir_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CommunicatorServer is the server API for Communicator service.
+// All implementations must embed UnimplementedCommunicatorServer
+// for forward compatibility
+type CommunicatorServer interface {
+	Start(Communicator_StartServer) error
+	Upload(Communicator_UploadServer) error
+	UploadDir(context.Context, *DirRequest) (*Empty, error)
+	Download(*DownloadRequest, Communicator_DownloadServer) error
+	DownloadDir(context.Context, *DirRequest) (*Empty, error)
+	mustEmbedUnimplementedCommunicatorServer()
+}
+
+// UnimplementedCommunicatorServer must be embedded to have forward compatible implementations.
+type UnimplementedCommunicatorServer struct {
+}
+
+func (UnimplementedCommunicatorServer) Start(Communicator_StartServer) error {
+	return status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (UnimplementedCommunicatorServer) Upload(Communicator_UploadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedCommunicatorServer) UploadDir(context.Context, *DirRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadDir not implemented")
+}
+func (UnimplementedCommunicatorServer) Download(*DownloadRequest, Communicator_DownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedCommunicatorServer) DownloadDir(context.Context, *DirRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DownloadDir not implemented")
+}
+func (UnimplementedCommunicatorServer) mustEmbedUnimplementedCommunicatorServer() {}
+
+// UnsafeCommunicatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CommunicatorServer will
+// result in compilation errors.
+type UnsafeCommunicatorServer interface {
+	mustEmbedUnimplementedCommunicatorServer()
+}
+
+func RegisterCommunicatorServer(s grpc.ServiceRegistrar, srv CommunicatorServer) {
+	s.RegisterService(&Communicator_ServiceDesc, srv)
+}
+
+func _Communicator_Start_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CommunicatorServer).Start(&communicatorStartServer{stream})
+}
+
+type Communicator_StartServer interface {
+	Send(*StartOutput) error
+	Recv() (*StartInput, error)
+	grpc.ServerStream
+}
+
+type communicatorStartServer struct {
+	grpc.ServerStream
+}
+
+func (x *communicatorStartServer) Send(m *StartOutput) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *communicatorStartServer) Recv() (*StartInput, error) {
+	m := new(StartInput)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Communicator_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CommunicatorServer).Upload(&communicatorUploadServer{stream})
+}
+
+type Communicator_UploadServer interface {
+	SendAndClose(*Empty) error
+	Recv() (*UploadInput, error)
+	grpc.ServerStream
+}
+
+type communicatorUploadServer struct {
+	grpc.ServerStream
+}
+
+func (x *communicatorUploadServer) SendAndClose(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *communicatorUploadServer) Recv() (*UploadInput, error) {
+	m := new(UploadInput)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Communicator_UploadDir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommunicatorServer).UploadDir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Communicator_UploadDir_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommunicatorServer).UploadDir(ctx, req.(*DirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Communicator_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CommunicatorServer).Download(m, &communicatorDownloadServer{stream})
+}
+
+type Communicator_DownloadServer interface {
+	Send(*Chunk) error
+	grpc.ServerStream
+}
+
+type communicatorDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *communicatorDownloadServer) Send(m *Chunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Communicator_DownloadDir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommunicatorServer).DownloadDir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Communicator_DownloadDir_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommunicatorServer).DownloadDir(ctx, req.(*DirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Communicator_ServiceDesc is the grpc.ServiceDesc for Communicator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Communicator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "packer.plugin.Communicator",
+	HandlerType: (*CommunicatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "UploadDir",
+			Handler:    _Communicator_UploadDir_Handler,
+		},
+		{
+			MethodName: "DownloadDir",
+			Handler:    _Communicator_DownloadDir_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Start",
+			Handler:       _Communicator_Start_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Upload",
+			Handler:       _Communicator_Upload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Download",
+			Handler:       _Communicator_Download_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "plugin.proto",
+}
+
+const (
+	Artifact_Describe_FullMethodName           = "/packer.plugin.Artifact/Describe"
+	Artifact_State_FullMethodName              = "/packer.plugin.Artifact/State"
+	Artifact_Destroy_FullMethodName            = "/packer.plugin.Artifact/Destroy"
+	Artifact_Metadata_FullMethodName           = "/packer.plugin.Artifact/Metadata"
+	Artifact_DestroyWithOptions_FullMethodName = "/packer.plugin.Artifact/DestroyWithOptions"
+)
+
+// ArtifactClient is the client API for Artifact service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ArtifactClient interface {
+	Describe(ctx context.Context, in *ArtifactRequest, opts ...grpc.CallOption) (*ArtifactInfo, error)
+	State(ctx context.Context, in *ArtifactStateRequest, opts ...grpc.CallOption) (*ArtifactState, error)
+	Destroy(ctx context.Context, in *ArtifactRequest, opts ...grpc.CallOption) (*Empty, error)
+	Metadata(ctx context.Context, in *ArtifactRequest, opts ...grpc.CallOption) (*ArtifactMetadata, error)
+	// DestroyWithOptions destroys the artifact, or reports what it would
+	// destroy, with a result per resource.
+	DestroyWithOptions(ctx context.Context, in *ArtifactDestroyRequest, opts ...grpc.CallOption) (*ArtifactDestroyResponse, error)
+}
+
+type artifactClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewArtifactClient(cc grpc.ClientConnInterface) ArtifactClient {
+	return &artifactClient{cc}
+}
+
+func (c *artifactClient) Describe(ctx context.Context, in *ArtifactRequest, opts ...grpc.CallOption) (*ArtifactInfo, error) {
+	out := new(ArtifactInfo)
+	err := c.cc.Invoke(ctx, Artifact_Describe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *artifactClient) State(ctx context.Context, in *ArtifactStateRequest, opts ...grpc.CallOption) (*ArtifactState, error) {
+	out := new(ArtifactState)
+	err := c.cc.Invoke(ctx, Artifact_State_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *artifactClient) Destroy(ctx context.Context, in *ArtifactRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Artifact_Destroy_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *artifactClient) Metadata(ctx context.Context, in *ArtifactRequest, opts ...grpc.CallOption) (*ArtifactMetadata, error) {
+	out := new(ArtifactMetadata)
+	err := c.cc.Invoke(ctx, Artifact_Metadata_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *artifactClient) DestroyWithOptions(ctx context.Context, in *ArtifactDestroyRequest, opts ...grpc.CallOption) (*ArtifactDestroyResponse, error) {
+	out := new(ArtifactDestroyResponse)
+	err := c.cc.Invoke(ctx, Artifact_DestroyWithOptions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ArtifactServer is the server API for Artifact service.
+// All implementations must embed UnimplementedArtifactServer
+// for forward compatibility
+type ArtifactServer interface {
+	Describe(context.Context, *ArtifactRequest) (*ArtifactInfo, error)
+	State(context.Context, *ArtifactStateRequest) (*ArtifactState, error)
+	Destroy(context.Context, *ArtifactRequest) (*Empty, error)
+	Metadata(context.Context, *ArtifactRequest) (*ArtifactMetadata, error)
+	// DestroyWithOptions destroys the artifact, or reports what it would
+	// destroy, with a result per resource.
+	DestroyWithOptions(context.Context, *ArtifactDestroyRequest) (*ArtifactDestroyResponse, error)
+	mustEmbedUnimplementedArtifactServer()
+}
+
+// UnimplementedArtifactServer must be embedded to have forward compatible implementations.
+type UnimplementedArtifactServer struct {
+}
+
+func (UnimplementedArtifactServer) Describe(context.Context, *ArtifactRequest) (*ArtifactInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Describe not implemented")
+}
+func (UnimplementedArtifactServer) State(context.Context, *ArtifactStateRequest) (*ArtifactState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method State not implemented")
+}
+func (UnimplementedArtifactServer) Destroy(context.Context, *ArtifactRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Destroy not implemented")
+}
+func (UnimplementedArtifactServer) Metadata(context.Context, *ArtifactRequest) (*ArtifactMetadata, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Metadata not implemented")
+}
+func (UnimplementedArtifactServer) DestroyWithOptions(context.Context, *ArtifactDestroyRequest) (*ArtifactDestroyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DestroyWithOptions not implemented")
+}
+func (UnimplementedArtifactServer) mustEmbedUnimplementedArtifactServer() {}
+
+// UnsafeArtifactServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ArtifactServer will
+// result in compilation errors.
+type UnsafeArtifactServer interface {
+	mustEmbedUnimplementedArtifactServer()
+}
+
+func RegisterArtifactServer(s grpc.ServiceRegistrar, srv ArtifactServer) {
+	s.RegisterService(&Artifact_ServiceDesc, srv)
+}
+
+func _Artifact_Describe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArtifactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArtifactServer).Describe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Artifact_Describe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArtifactServer).Describe(ctx, req.(*ArtifactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Artifact_State_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArtifactStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArtifactServer).State(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Artifact_State_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArtifactServer).State(ctx, req.(*ArtifactStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Artifact_Destroy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArtifactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArtifactServer).Destroy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Artifact_Destroy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArtifactServer).Destroy(ctx, req.(*ArtifactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Artifact_Metadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArtifactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArtifactServer).Metadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Artifact_Metadata_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArtifactServer).Metadata(ctx, req.(*ArtifactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Artifact_DestroyWithOptions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArtifactDestroyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArtifactServer).DestroyWithOptions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Artifact_DestroyWithOptions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArtifactServer).DestroyWithOptions(ctx, req.(*ArtifactDestroyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Artifact_ServiceDesc is the grpc.ServiceDesc for Artifact service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Artifact_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "packer.plugin.Artifact",
+	HandlerType: (*ArtifactServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Describe",
+			Handler:    _Artifact_Describe_Handler,
+		},
+		{
+			MethodName: "State",
+			Handler:    _Artifact_State_Handler,
+		},
+		{
+			MethodName: "Destroy",
+			Handler:    _Artifact_Destroy_Handler,
+		},
+		{
+			MethodName: "Metadata",
+			Handler:    _Artifact_Metadata_Handler,
+		},
+		{
+			MethodName: "DestroyWithOptions",
+			Handler:    _Artifact_DestroyWithOptions_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "plugin.proto",
+}
@@ -28,6 +28,7 @@ type PostProcessorServer struct {
 
 type PostProcessorConfigureArgs struct {
 	Configs []interface{}
+	TraceContext
 }
 
 type PostProcessorProcessResponse struct {
@@ -37,16 +38,98 @@ type PostProcessorProcessResponse struct {
 	StreamId      uint32
 }
 
+type PostProcessorProcessArtifactsResponse struct {
+	Err       *BasicError
+	Artifacts []PostProcessedArtifactResponse
+}
+
+// PostProcessedArtifactResponse is an artifact produced by a post-processor,
+// served on StreamId.
+type PostProcessedArtifactResponse struct {
+	StreamId      uint32
+	Keep          bool
+	ForceOverride bool
+}
+
+var _ packersdk.MultiArtifactPostProcessor = new(postProcessor)
+
 func (p *postProcessor) Configure(raw ...interface{}) error {
 	raw, err := encodeCTYValues(raw)
 	if err != nil {
 		return err
 	}
 	args := &PostProcessorConfigureArgs{Configs: raw}
-	return p.client.Call(p.endpoint+".Configure", args, new(interface{}))
+	resp := new(PrepareDiagnosticsResponse)
+	err = p.tracedCall(context.Background(), p.endpoint+".ConfigureWithDiagnostics", args, resp)
+	if isUnknownMethod(err) {
+		return p.client.Call(p.endpoint+".Configure", args, new(interface{}))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = decodePrepareResult(nil, resp.Error, resp.Diagnostics)
+	return err
 }
 
 func (p *postProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	var response PostProcessorProcessResponse
+	if err := p.call(ctx, ui, a, "PostProcess", &response); err != nil {
+		return nil, false, false, err
+	}
+
+	if response.Err != nil {
+		return nil, false, false, response.Err
+	}
+
+	if response.StreamId == 0 {
+		return nil, false, false, nil
+	}
+
+	client, err := newClientWithMux(p.mux, response.StreamId)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	return client.Artifact(), response.Keep, response.ForceOverride, nil
+}
+
+// PostProcessArtifacts calls PostProcess for plugins built with an SDK
+// predating packersdk.MultiArtifactPostProcessor.
+func (p *postProcessor) PostProcessArtifacts(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) ([]packersdk.PostProcessedArtifact, error) {
+	var response PostProcessorProcessArtifactsResponse
+	err := p.call(ctx, ui, a, "PostProcessArtifacts", &response)
+	if isMethodNotFound(err) {
+		artifact, keep, forceOverride, err := p.PostProcess(ctx, ui, a)
+		if err != nil || artifact == nil {
+			return nil, err
+		}
+		return []packersdk.PostProcessedArtifact{{Artifact: artifact, Keep: keep, ForceOverride: forceOverride}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if response.Err != nil {
+		return nil, response.Err
+	}
+
+	artifacts := make([]packersdk.PostProcessedArtifact, len(response.Artifacts))
+	for i, r := range response.Artifacts {
+		client, err := newClientWithMux(p.mux, r.StreamId)
+		if err != nil {
+			return nil, err
+		}
+		artifacts[i] = packersdk.PostProcessedArtifact{
+			Artifact:      client.Artifact(),
+			Keep:          r.Keep,
+			ForceOverride: r.ForceOverride,
+		}
+	}
+	return artifacts, nil
+}
+
+// call serves ui and a to the plugin for the post-processing method, and
+// cancels it when ctx is done.
+func (p *postProcessor) call(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact, method string, reply interface{}) error {
 	nextId := p.mux.NextId()
 	server := newServerWithMux(p.mux, nextId)
 	server.RegisterArtifact(a)
@@ -67,34 +150,27 @@ func (p *postProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, a pack
 		}
 	}()
 
-	var response PostProcessorProcessResponse
-	if err := p.client.Call(p.endpoint+".PostProcess", nextId, &response); err != nil {
-		return nil, false, false, err
-	}
-
-	if response.Err != nil {
-		return nil, false, false, response.Err
-	}
-
-	if response.StreamId == 0 {
-		return nil, false, false, nil
-	}
+	return p.tracedCall(ctx, p.endpoint+"."+method, nextId, reply)
+}
 
-	client, err := newClientWithMux(p.mux, response.StreamId)
+func (p *PostProcessorServer) Configure(args *PostProcessorConfigureArgs, reply *interface{}) (err error) {
+	config, err := decodeCTYValues(args.Configs)
 	if err != nil {
-		return nil, false, false, err
+		return err
 	}
-
-	return client.Artifact(), response.Keep, response.ForceOverride, nil
+	return legacyPrepareError(p.p.Configure(config...))
 }
 
-func (p *PostProcessorServer) Configure(args *PostProcessorConfigureArgs, reply *interface{}) (err error) {
+func (p *PostProcessorServer) ConfigureWithDiagnostics(args *PostProcessorConfigureArgs, reply *PrepareDiagnosticsResponse) error {
 	config, err := decodeCTYValues(args.Configs)
 	if err != nil {
 		return err
 	}
+	_, span := p.startSpan(context.Background(), "PostProcessor.ConfigureWithDiagnostics", args.TraceContext.TraceContext)
 	err = p.p.Configure(config...)
-	return err
+	span.End(err)
+	_, reply.Error, reply.Diagnostics = encodePrepareResult(nil, err)
+	return nil
 }
 
 func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorProcessResponse) error {
@@ -104,11 +180,16 @@ func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorP
 	}
 
 	if p.context == nil {
-		p.context, p.contextCancel = context.WithCancel(context.Background())
+		p.context, p.contextCancel = p.newContext()
 	}
 
 	artifact := client.Artifact()
-	artifactResult, keep, forceOverride, err := p.p.PostProcess(p.context, client.Ui(), artifact)
+	ctx, span := p.startSpan(p.context, "PostProcessor.PostProcess", nil)
+	artifactResult, keep, forceOverride, err := p.p.PostProcess(ctx, client.Ui(), artifact)
+	span.End(err)
+	// client may stay open for the artifact, so don't rely on Close to
+	// send the last messages.
+	client.flushUi()
 	*reply = PostProcessorProcessResponse{
 		Err:           NewBasicError(err),
 		Keep:          keep,
@@ -141,6 +222,54 @@ func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorP
 	return nil
 }
 
+func (p *PostProcessorServer) PostProcessArtifacts(streamId uint32, reply *PostProcessorProcessArtifactsResponse) error {
+	client, err := newClientWithMux(p.mux, streamId)
+	if err != nil {
+		return NewBasicError(err)
+	}
+
+	if p.context == nil {
+		p.context, p.contextCancel = p.newContext()
+	}
+
+	artifact := client.Artifact()
+	ctx, span := p.startSpan(p.context, "PostProcessor.PostProcessArtifacts", nil)
+	artifacts, err := packersdk.PostProcessArtifacts(ctx, p.p, client.Ui(), artifact)
+	span.End(err)
+	client.flushUi()
+	*reply = PostProcessorProcessArtifactsResponse{Err: NewBasicError(err)}
+	if err != nil {
+		log.Printf("error: %v", err)
+		client.Close()
+		return nil
+	}
+
+	// As for PostProcess, client stays open when it serves one of the
+	// artifacts.
+	keepClient := false
+	for _, a := range artifacts {
+		if a.Artifact == nil {
+			continue
+		}
+		keepClient = keepClient || a.Artifact == artifact
+		streamId := p.mux.NextId()
+		server := newServerWithMux(p.mux, streamId)
+		if err := server.RegisterArtifact(a.Artifact); err != nil {
+			return err
+		}
+		go server.Serve()
+		reply.Artifacts = append(reply.Artifacts, PostProcessedArtifactResponse{
+			StreamId:      streamId,
+			Keep:          a.Keep,
+			ForceOverride: a.ForceOverride,
+		})
+	}
+	if !keepClient {
+		client.Close()
+	}
+	return nil
+}
+
 func (b *PostProcessorServer) Cancel(args *interface{}, reply *interface{}) error {
 	if b.contextCancel != nil {
 		b.contextCancel()
@@ -139,3 +139,54 @@ func TestPostProcessor_Implements(t *testing.T) {
 		t.Fatal("not a postprocessor")
 	}
 }
+
+type testMultiArtifactPostProcessor struct {
+	TestPostProcessor
+}
+
+func (pp *testMultiArtifactPostProcessor) PostProcessArtifacts(_ context.Context, _ packersdk.Ui, a packersdk.Artifact) ([]packersdk.PostProcessedArtifact, error) {
+	return []packersdk.PostProcessedArtifact{
+		{Artifact: &packersdk.MockArtifact{IdValue: "us-east-1:" + a.Id()}, Keep: true},
+		{Artifact: &packersdk.MockArtifact{IdValue: "eu-west-1:" + a.Id()}, ForceOverride: true},
+	}, nil
+}
+
+func TestPostProcessorRPC_artifacts(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterPostProcessor(new(testMultiArtifactPostProcessor))
+
+	ppClient := client.PostProcessor().(packersdk.MultiArtifactPostProcessor)
+	a := &packersdk.MockArtifact{IdValue: "ami-1"}
+	artifacts, err := ppClient.PostProcessArtifacts(context.Background(), new(testUi), a)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(artifacts) != 2 {
+		t.Fatalf("expected 2 artifacts, got %d", len(artifacts))
+	}
+	if id := artifacts[0].Artifact.Id(); id != "us-east-1:ami-1" || !artifacts[0].Keep || artifacts[0].ForceOverride {
+		t.Fatalf("bad first artifact: %s %#v", id, artifacts[0])
+	}
+	if id := artifacts[1].Artifact.Id(); id != "eu-west-1:ami-1" || artifacts[1].Keep || !artifacts[1].ForceOverride {
+		t.Fatalf("bad second artifact: %s %#v", id, artifacts[1])
+	}
+}
+
+func TestPostProcessorRPC_artifactsSingle(t *testing.T) {
+	p := new(TestPostProcessor)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterPostProcessor(p)
+
+	ppClient := client.PostProcessor().(packersdk.MultiArtifactPostProcessor)
+	artifacts, err := ppClient.PostProcessArtifacts(context.Background(), new(testUi), new(packersdk.MockArtifact))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.ppCalled || len(artifacts) != 1 || artifacts[0].Artifact.Id() != "id" {
+		t.Fatalf("bad artifacts: %#v", artifacts)
+	}
+}
@@ -5,6 +5,7 @@ package rpc
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -28,6 +29,7 @@ type ProvisionerServer struct {
 
 type ProvisionerPrepareArgs struct {
 	Configs []interface{}
+	TraceContext
 }
 
 func (p *provisioner) Prepare(configs ...interface{}) error {
@@ -35,13 +37,26 @@ func (p *provisioner) Prepare(configs ...interface{}) error {
 	if err != nil {
 		return err
 	}
-	args := &ProvisionerPrepareArgs{configs}
-	return p.client.Call(p.endpoint+".Prepare", args, new(interface{}))
+	args := &ProvisionerPrepareArgs{Configs: configs}
+	resp := new(PrepareDiagnosticsResponse)
+	err = p.tracedCall(context.Background(), p.endpoint+".PrepareWithDiagnostics", args, resp)
+	if isUnknownMethod(err) {
+		return p.client.Call(p.endpoint+".Prepare", args, new(interface{}))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = decodePrepareResult(nil, resp.Error, resp.Diagnostics)
+	return err
 }
 
 type ProvisionerProvisionArgs struct {
 	GeneratedData map[string]interface{}
-	StreamID      uint32
+	// TypedGeneratedData is GeneratedData encoded by encodeGeneratedData,
+	// preferred to it when set.
+	TypedGeneratedData []byte
+	StreamID           uint32
+	TraceContext
 }
 
 func (p *provisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, generatedData map[string]interface{}) error {
@@ -65,8 +80,35 @@ func (p *provisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packe
 		}
 	}()
 
-	args := &ProvisionerProvisionArgs{generatedData, nextId}
-	return p.client.Call(p.endpoint+".Provision", args, new(interface{}))
+	args := &ProvisionerProvisionArgs{GeneratedData: generatedData, StreamID: nextId}
+	typed, err := encodeGeneratedData(generatedData)
+	if err != nil {
+		log.Printf("[WARN] Sending the generated data untyped: %s", err)
+	}
+	args.TypedGeneratedData = typed
+	return p.tracedCall(ctx, p.endpoint+".Provision", args, new(interface{}))
+}
+
+type ProvisionerRequirementsResponse struct {
+	Requirements packersdk.ProvisionerRequirements
+}
+
+var _ packersdk.RequirementsProvisioner = new(provisioner)
+
+// Requirements returns the requirements of the remote provisioner. Plugins
+// built with an SDK that doesn't support requirements have none.
+func (p *provisioner) Requirements() packersdk.ProvisionerRequirements {
+	resp := new(ProvisionerRequirementsResponse)
+	if err := p.client.Call(p.endpoint+".Requirements", new(interface{}), resp); err != nil {
+		log.Printf("[DEBUG] Could not get provisioner requirements: %s", err)
+		return packersdk.ProvisionerRequirements{}
+	}
+	return resp.Requirements
+}
+
+func (p *ProvisionerServer) Requirements(args *interface{}, reply *ProvisionerRequirementsResponse) error {
+	reply.Requirements = packersdk.ProvisionerRequirementsOf(p.p)
+	return nil
 }
 
 func (p *ProvisionerServer) Prepare(args *ProvisionerPrepareArgs, reply *interface{}) error {
@@ -74,7 +116,19 @@ func (p *ProvisionerServer) Prepare(args *ProvisionerPrepareArgs, reply *interfa
 	if err != nil {
 		return err
 	}
-	return p.p.Prepare(config...)
+	return legacyPrepareError(p.p.Prepare(config...))
+}
+
+func (p *ProvisionerServer) PrepareWithDiagnostics(args *ProvisionerPrepareArgs, reply *PrepareDiagnosticsResponse) error {
+	config, err := decodeCTYValues(args.Configs)
+	if err != nil {
+		return err
+	}
+	_, span := p.startSpan(context.Background(), "Provisioner.PrepareWithDiagnostics", args.TraceContext.TraceContext)
+	err = p.p.Prepare(config...)
+	span.End(err)
+	_, reply.Error, reply.Diagnostics = encodePrepareResult(nil, err)
+	return nil
 }
 
 func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *interface{}) error {
@@ -86,9 +140,20 @@ func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *int
 	defer client.Close()
 
 	if p.context == nil {
-		p.context, p.contextCancel = context.WithCancel(context.Background())
+		p.context, p.contextCancel = p.newContext()
+	}
+	generatedData := args.GeneratedData
+	if len(args.TypedGeneratedData) > 0 {
+		generatedData, err = decodeGeneratedData(args.TypedGeneratedData)
+		if err != nil {
+			return NewBasicError(fmt.Errorf("Error decoding the generated data: %s", err))
+		}
 	}
-	if err := p.p.Provision(p.context, client.Ui(), client.Communicator(), args.GeneratedData); err != nil {
+
+	ctx, span := p.startSpan(p.context, "Provisioner.Provision", args.TraceContext.TraceContext)
+	err = p.p.Provision(ctx, client.Ui(), client.Communicator(), generatedData)
+	span.End(err)
+	if err != nil {
 		return NewBasicError(err)
 	}
 
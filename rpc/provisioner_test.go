@@ -54,3 +54,33 @@ func TestProvisionerRPC(t *testing.T) {
 func TestProvisioner_Implements(t *testing.T) {
 	var _ packersdk.Provisioner = new(provisioner)
 }
+
+type testRequirementsProvisioner struct {
+	packersdk.MockProvisioner
+}
+
+func (*testRequirementsProvisioner) Requirements() packersdk.ProvisionerRequirements {
+	return packersdk.ProvisionerRequirements{
+		Requires:     []string{"package-install"},
+		Communicator: packersdk.CommunicatorCapabilities{Reboot: true},
+	}
+}
+
+func TestProvisioner_Requirements(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterProvisioner(new(packersdk.MockProvisioner))
+	if reqs := packersdk.ProvisionerRequirementsOf(client.Provisioner()); !reflect.DeepEqual(reqs, packersdk.ProvisionerRequirements{}) {
+		t.Fatalf("the mock has no requirements: %#v", reqs)
+	}
+
+	client, server = testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterProvisioner(new(testRequirementsProvisioner))
+	reqs := packersdk.ProvisionerRequirementsOf(client.Provisioner())
+	if !reflect.DeepEqual(reqs, (*testRequirementsProvisioner)(nil).Requirements()) {
+		t.Fatalf("bad: %#v", reqs)
+	}
+}
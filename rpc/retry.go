@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/rpc"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+// RetryConfig configures how the calls that can safely be made twice, like
+// ConfigSpec or the getters of an artifact, are retried when they fail to
+// reach the other end. Calls the other end answered with an error, and calls
+// over a connection that is closed, are never retried.
+type RetryConfig struct {
+	// MaxAttempts is the number of times a call is made before giving up.
+	MaxAttempts int
+	// InitialBackoff is the time waited before the first retry. It doubles
+	// after each retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryConfig suits the connection between Packer and its plugins.
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts:    4,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// SetRetryConfig makes the idempotent calls of the clients created over the
+// connection of c retried as configured. They are not retried by default.
+func (c *Client) SetRetryConfig(config RetryConfig) {
+	c.mux.setRetryConfig(&config)
+}
+
+// idempotentCall makes a call that can safely be made again, retrying it
+// when the connection is configured so.
+func (p *commonClient) idempotentCall(serviceMethod string, args interface{}, reply interface{}) error {
+	var config *RetryConfig
+	if p.mux != nil {
+		config = p.mux.retryConfig()
+	}
+	if config == nil || config.MaxAttempts <= 1 {
+		return p.client.Call(serviceMethod, args, reply)
+	}
+
+	backoff := retry.Backoff{
+		InitialBackoff: config.InitialBackoff,
+		MaxBackoff:     config.MaxBackoff,
+		Multiplier:     2,
+	}
+	err := retry.Config{
+		Tries:       config.MaxAttempts,
+		RetryDelay:  backoff.Linear,
+		ShouldRetry: isTransientError,
+	}.Run(context.Background(), func(context.Context) error {
+		return p.client.Call(serviceMethod, args, reply)
+	})
+	var exhausted *retry.RetryExhaustedError
+	if errors.As(err, &exhausted) {
+		return exhausted.Err
+	}
+	return err
+}
+
+// isTransientError returns whether the call that failed with err may succeed
+// if made again. net/rpc clients survive errors sending a request, but not
+// errors reading a response, after which they return rpc.ErrShutdown.
+func isTransientError(err error) bool {
+	if _, ok := err.(rpc.ServerError); ok {
+		return false
+	}
+	return err != rpc.ErrShutdown
+}
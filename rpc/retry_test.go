@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"errors"
+	"net/rpc"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/ugorji/go/codec"
+)
+
+// flakyCodec fails to send the first requests, like a connection with
+// hiccups.
+type flakyCodec struct {
+	rpc.ClientCodec
+	failures int
+	sent     int
+}
+
+func (c *flakyCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	c.sent++
+	if c.failures > 0 {
+		c.failures--
+		return errors.New("write: broken pipe")
+	}
+	return c.ClientCodec.WriteRequest(r, body)
+}
+
+func testFlakyArtifact(t *testing.T, failures int) (packersdk.Artifact, *flakyCodec, *Client) {
+	client, server := testClientServer(t)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	streamId := server.mux.NextId()
+	artifactServer := newServerWithMux(server.mux, streamId)
+	artifactServer.RegisterArtifact(new(packersdk.MockArtifact))
+	go artifactServer.Serve()
+
+	conn, err := client.mux.Dial(streamId)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	flaky := &flakyCodec{
+		ClientCodec: codec.GoRpc.ClientCodec(conn, &codec.MsgpackHandle{WriteExt: true}),
+		failures:    failures,
+	}
+	rpcClient := rpc.NewClientWithCodec(flaky)
+	t.Cleanup(func() { rpcClient.Close() })
+
+	a := &artifact{commonClient: commonClient{
+		endpoint: DefaultArtifactEndpoint,
+		client:   rpcClient,
+		mux:      client.mux,
+	}}
+	return a, flaky, client
+}
+
+func TestCommonClient_Retry(t *testing.T) {
+	a, flaky, client := testFlakyArtifact(t, 2)
+	client.SetRetryConfig(RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	if id := a.Id(); id != "id" {
+		t.Fatalf("bad id: %q", id)
+	}
+	if flaky.sent != 3 {
+		t.Fatalf("expected 3 attempts, got %d", flaky.sent)
+	}
+}
+
+func TestCommonClient_RetryExhausted(t *testing.T) {
+	a, flaky, client := testFlakyArtifact(t, 5)
+	client.SetRetryConfig(RetryConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+
+	if id := a.Id(); id != "" {
+		t.Fatalf("bad id: %q", id)
+	}
+	if flaky.sent != 2 {
+		t.Fatalf("expected 2 attempts, got %d", flaky.sent)
+	}
+}
+
+func TestCommonClient_NoRetry(t *testing.T) {
+	a, flaky, _ := testFlakyArtifact(t, 1)
+
+	if id := a.Id(); id != "" {
+		t.Fatalf("bad id: %q", id)
+	}
+	if flaky.sent != 1 {
+		t.Fatalf("calls should not be retried by default, got %d attempts", flaky.sent)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	if isTransientError(rpc.ServerError("failed")) {
+		t.Fatal("errors of the server should not be retried")
+	}
+	if isTransientError(rpc.ErrShutdown) {
+		t.Fatal("calls over a closed connection should not be retried")
+	}
+	if !isTransientError(errors.New("write: broken pipe")) {
+		t.Fatal("errors sending a call should be retried")
+	}
+}
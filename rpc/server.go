@@ -4,6 +4,7 @@
 package rpc
 
 import (
+	"context"
 	"io"
 	"log"
 	"net/rpc"
@@ -23,6 +24,7 @@ const (
 	DefaultPostProcessorEndpoint string = "PostProcessor"
 	DefaultProvisionerEndpoint   string = "Provisioner"
 	DefaultDatasourceEndpoint    string = "Datasource"
+	DefaultFunctionEndpoint      string = "Function"
 	DefaultUiEndpoint            string = "Ui"
 )
 
@@ -67,6 +69,20 @@ func (s *PluginServer) Close() error {
 	return nil
 }
 
+// BufferUi makes the Uis passed to the components served by s buffer their
+// messages and send them to Packer in batches, instead of making one call
+// per message. Messages are received in the order they were written, and
+// are flushed before a component returns or asks a question.
+func (s *PluginServer) BufferUi(config UiBufferConfig) {
+	s.mux.setUiBufferConfig(&config)
+}
+
+// SetContext makes the builds, provisionings, post-processings and hooks
+// served by s cancelled when ctx is done, as when Packer cancels them.
+func (s *PluginServer) SetContext(ctx context.Context) {
+	s.mux.setBaseContext(ctx)
+}
+
 func (s *PluginServer) RegisterArtifact(a packer.Artifact) error {
 	return s.server.RegisterName(DefaultArtifactEndpoint, &ArtifactServer{
 		artifact: a,
@@ -136,6 +152,12 @@ func (s *PluginServer) RegisterDatasource(d packer.Datasource) error {
 	})
 }
 
+func (s *PluginServer) RegisterFunction(f packer.Function) error {
+	return s.server.RegisterName(DefaultFunctionEndpoint, &FunctionServer{
+		f: f,
+	})
+}
+
 func (s *PluginServer) RegisterUi(ui packer.Ui) error {
 	return s.server.RegisterName(DefaultUiEndpoint, &UiServer{
 		ui:       ui,
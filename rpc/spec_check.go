@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/rpc/pluginproto"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/protobuf/proto"
+)
+
+// CheckSpec sends spec through the encodings Packer receives specs with, gob
+// over net/rpc and protobuf over gRPC, and returns an error describing what
+// can't be sent, or what comes out different. Specs that don't survive the
+// trip are only noticed when Packer decodes a configuration with them, so
+// plugins should call it from their tests on the ConfigSpec of each of their
+// components, and on the OutputSpec of their datasources:
+//
+//	if err := rpc.CheckSpec(builder.ConfigSpec()); err != nil {
+//		t.Fatal(err)
+//	}
+func CheckSpec(spec hcldec.ObjectSpec) error {
+	var errs []string
+
+	got, err := gobRoundTrip(spec)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("gob: %s", err))
+	} else {
+		for _, diff := range specDiff("", spec, got) {
+			errs = append(errs, "gob: "+diff)
+		}
+	}
+
+	got, err = protobufRoundTrip(spec)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("protobuf: %s", err))
+	} else {
+		for _, diff := range specDiff("", spec, got) {
+			errs = append(errs, "protobuf: "+diff)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("the spec does not survive being sent to Packer:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// gobRoundTrip encodes and decodes spec like ConfigSpec over net/rpc does.
+func gobRoundTrip(spec hcldec.ObjectSpec) (hcldec.ObjectSpec, error) {
+	b := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(b).Encode(spec); err != nil {
+		return nil, err
+	}
+	var res hcldec.ObjectSpec
+	if err := gob.NewDecoder(b).Decode(&res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// protobufRoundTrip encodes and decodes spec like ConfigSpec over gRPC does.
+func protobufRoundTrip(spec hcldec.ObjectSpec) (hcldec.ObjectSpec, error) {
+	encoded, err := encodeSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	b, err := proto.Marshal(encoded)
+	if err != nil {
+		return nil, err
+	}
+	decoded := new(pluginproto.Spec)
+	if err := proto.Unmarshal(b, decoded); err != nil {
+		return nil, err
+	}
+	return decodeObjectSpec(decoded)
+}
+
+// specDiff returns the differences changing how want and got decode a
+// configuration, prefixed with their path.
+func specDiff(path string, want, got hcldec.Spec) []string {
+	if path == "" {
+		path = "."
+	}
+	diff := func(format string, args ...interface{}) []string {
+		return []string{path + ": " + fmt.Sprintf(format, args...)}
+	}
+	if want == nil || got == nil {
+		if want == nil && got == nil {
+			return nil
+		}
+		return diff("%T became %T", want, got)
+	}
+	// hcldec.ObjectSpec is a map, other specs are pointers.
+	if fmt.Sprintf("%T", want) != fmt.Sprintf("%T", got) {
+		return diff("%T became %T", want, got)
+	}
+	typeDiff := func(field string, want, got cty.Type) []string {
+		if want.Equals(got) {
+			return nil
+		}
+		return diff("%s %s became %s", field, want.FriendlyName(), got.FriendlyName())
+	}
+
+	var diffs []string
+	switch w := want.(type) {
+	case hcldec.ObjectSpec:
+		g := got.(hcldec.ObjectSpec)
+		names := make([]string, 0, len(w))
+		for name := range w {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, ok := g[name]; !ok {
+				diffs = append(diffs, diff("%s is missing", name)...)
+				continue
+			}
+			diffs = append(diffs, specDiff(strings.TrimSuffix(path, ".")+"."+name, w[name], g[name])...)
+		}
+		var unexpected []string
+		for name := range g {
+			if _, ok := w[name]; !ok {
+				unexpected = append(unexpected, name)
+			}
+		}
+		sort.Strings(unexpected)
+		for _, name := range unexpected {
+			diffs = append(diffs, diff("unexpected %s", name)...)
+		}
+	case *hcldec.AttrSpec:
+		g := got.(*hcldec.AttrSpec)
+		if w.Name != g.Name || w.Required != g.Required {
+			diffs = append(diffs, diff("attribute %q (required: %t) became %q (required: %t)", w.Name, w.Required, g.Name, g.Required)...)
+		}
+		diffs = append(diffs, typeDiff("type", w.Type, g.Type)...)
+	case *hcldec.BlockSpec:
+		g := got.(*hcldec.BlockSpec)
+		if w.TypeName != g.TypeName || w.Required != g.Required {
+			diffs = append(diffs, diff("block %q (required: %t) became %q (required: %t)", w.TypeName, w.Required, g.TypeName, g.Required)...)
+		}
+		diffs = append(diffs, specDiff(path, w.Nested, g.Nested)...)
+	case *hcldec.BlockListSpec:
+		g := got.(*hcldec.BlockListSpec)
+		if w.TypeName != g.TypeName || w.MinItems != g.MinItems || w.MaxItems != g.MaxItems {
+			diffs = append(diffs, diff("block list %q (%d to %d items) became %q (%d to %d items)", w.TypeName, w.MinItems, w.MaxItems, g.TypeName, g.MinItems, g.MaxItems)...)
+		}
+		diffs = append(diffs, specDiff(path, w.Nested, g.Nested)...)
+	case *hcldec.BlockAttrsSpec:
+		g := got.(*hcldec.BlockAttrsSpec)
+		if w.TypeName != g.TypeName || w.Required != g.Required {
+			diffs = append(diffs, diff("block %q (required: %t) became %q (required: %t)", w.TypeName, w.Required, g.TypeName, g.Required)...)
+		}
+		diffs = append(diffs, typeDiff("element type", w.ElementType, g.ElementType)...)
+	case *hcldec.BlockObjectSpec:
+		g := got.(*hcldec.BlockObjectSpec)
+		if w.TypeName != g.TypeName || strings.Join(w.LabelNames, ",") != strings.Join(g.LabelNames, ",") {
+			diffs = append(diffs, diff("block %q labeled %v became %q labeled %v", w.TypeName, w.LabelNames, g.TypeName, g.LabelNames)...)
+		}
+		diffs = append(diffs, specDiff(path, w.Nested, g.Nested)...)
+	default:
+		// The encoders refuse other specs, so this is not reached.
+		diffs = append(diffs, diff("unsupported spec type %T", want)...)
+	}
+	return diffs
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// randomType returns a random type, like the ones of the fields of the
+// configs generated by packer-sdc.
+func randomType(r *rand.Rand, depth int) cty.Type {
+	primitives := []cty.Type{cty.String, cty.Number, cty.Bool}
+	if depth > 2 {
+		return primitives[r.Intn(len(primitives))]
+	}
+	switch r.Intn(6) {
+	case 0:
+		return cty.List(randomType(r, depth+1))
+	case 1:
+		return cty.Map(randomType(r, depth+1))
+	case 2:
+		attrs := map[string]cty.Type{}
+		for i := r.Intn(3); i >= 0; i-- {
+			attrs[fmt.Sprintf("attr%d", i)] = randomType(r, depth+1)
+		}
+		return cty.Object(attrs)
+	default:
+		return primitives[r.Intn(len(primitives))]
+	}
+}
+
+// randomSpec returns a random object spec made of the specs supported by the
+// encoders.
+func randomSpec(r *rand.Rand, depth int) hcldec.ObjectSpec {
+	spec := hcldec.ObjectSpec{}
+	for i := r.Intn(5); i >= 0; i-- {
+		name := fmt.Sprintf("field%d", i)
+		kind := r.Intn(6)
+		if depth > 2 {
+			kind = 0
+		}
+		switch kind {
+		case 1:
+			spec[name] = &hcldec.BlockSpec{TypeName: name, Nested: randomSpec(r, depth+1), Required: r.Intn(2) == 0}
+		case 2:
+			spec[name] = &hcldec.BlockListSpec{TypeName: name, Nested: randomSpec(r, depth+1), MinItems: r.Intn(2), MaxItems: r.Intn(3)}
+		case 3:
+			spec[name] = &hcldec.BlockAttrsSpec{TypeName: name, ElementType: randomType(r, depth), Required: r.Intn(2) == 0}
+		case 4:
+			spec[name] = &hcldec.BlockObjectSpec{TypeName: name, LabelNames: []string{"name"}, Nested: randomSpec(r, depth+1)}
+		default:
+			spec[name] = &hcldec.AttrSpec{Name: name, Type: randomType(r, depth), Required: r.Intn(2) == 0}
+		}
+	}
+	return spec
+}
+
+func TestCheckSpec_roundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		spec := randomSpec(r, 0)
+		if err := CheckSpec(spec); err != nil {
+			t.Fatalf("spec %d: %s", i, err)
+		}
+	}
+
+	if err := CheckSpec(new(hcl2helper.MockConfig).FlatMapstructure().HCL2Spec()); err != nil {
+		t.Fatalf("mock config: %s", err)
+	}
+	if err := CheckSpec(new(packersdk.MockBuilder).ConfigSpec()); err != nil {
+		t.Fatalf("mock builder: %s", err)
+	}
+}
+
+func TestCheckSpec_unsupported(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"name": &hcldec.DefaultSpec{
+			Primary: &hcldec.AttrSpec{Name: "name", Type: cty.String},
+			Default: &hcldec.LiteralSpec{Value: cty.StringVal("packer")},
+		},
+	}
+	err := CheckSpec(spec)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !strings.Contains(err.Error(), "gob:") || !strings.Contains(err.Error(), "protobuf:") {
+		t.Fatalf("expected both encodings to fail, got: %s", err)
+	}
+}
+
+func TestSpecDiff(t *testing.T) {
+	want := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: true},
+		"disk": &hcldec.BlockSpec{TypeName: "disk", Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+		}},
+		"tags": &hcldec.AttrSpec{Name: "tags", Type: cty.Map(cty.String)},
+	}
+	got := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{Name: "name", Type: cty.String},
+		"disk": &hcldec.BlockSpec{TypeName: "disk", Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.String},
+		}},
+		"extra": &hcldec.AttrSpec{Name: "extra", Type: cty.String},
+	}
+	diffs := specDiff("", want, got)
+	expected := []string{
+		".disk.size: type number became string",
+		".name: attribute \"name\" (required: true) became \"name\" (required: false)",
+		".: tags is missing",
+		".: unexpected extra",
+	}
+	if strings.Join(diffs, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("bad diffs:\n%s", strings.Join(diffs, "\n"))
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Tracer starts the spans around the calls of components made over RPC, on
+// both ends: the client span in Packer, and the server span, its child, in
+// the plugin. It follows the OpenTelemetry API, without the SDK depending on
+// it: a Tracer is a few lines wrapping an OpenTelemetry trace.Tracer and its
+// propagation.TextMapPropagator.
+type Tracer interface {
+	// Start starts the span called name, like "Builder.Run", a child of the
+	// span of ctx if any, and returns a context holding it.
+	Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span)
+	// Inject writes the trace context of ctx to carrier, usually the W3C
+	// traceparent and tracestate headers.
+	Inject(ctx context.Context, carrier map[string]string)
+	// Extract returns ctx with the trace context read from carrier.
+	Extract(ctx context.Context, carrier map[string]string) context.Context
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// End ends the span, recording err when the call failed.
+	End(err error)
+}
+
+var tracing struct {
+	sync.RWMutex
+	tracer Tracer
+}
+
+// SetTracer sets the Tracer of the RPC calls, nil to stop tracing them.
+// Calls aren't traced by default.
+func SetTracer(t Tracer) {
+	tracing.Lock()
+	defer tracing.Unlock()
+	tracing.tracer = t
+}
+
+func currentTracer() Tracer {
+	tracing.RLock()
+	defer tracing.RUnlock()
+	return tracing.tracer
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
+
+// startSpan starts the span of a call made or served, when tracing.
+func startSpan(ctx context.Context, name string) (context.Context, Span) {
+	t := currentTracer()
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+	return t.Start(ctx, name, map[string]string{"rpc.system": "packer", "rpc.method": name})
+}
+
+// injectTrace returns the trace context of ctx to send along a call, nil
+// when not tracing.
+func injectTrace(ctx context.Context) map[string]string {
+	t := currentTracer()
+	if t == nil {
+		return nil
+	}
+	carrier := map[string]string{}
+	t.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+func extractTrace(ctx context.Context, carrier map[string]string) context.Context {
+	t := currentTracer()
+	if t == nil || len(carrier) == 0 {
+		return ctx
+	}
+	return t.Extract(ctx, carrier)
+}
+
+// tracedArgs are the arguments of the net/rpc calls carrying the trace
+// context of the client. Servers predating tracing ignore it.
+type tracedArgs interface {
+	setTraceContext(carrier map[string]string)
+}
+
+// tracedCall makes a net/rpc call within a span. The trace context is sent
+// in the arguments of the call when they are tracedArgs. Otherwise, as
+// net/rpc calls have no metadata, it is first sent with a SetTraceContext
+// call, for the server to use as the parent of the span of the next call it
+// serves; only builds, provisionings and post-processings, which aren't run
+// concurrently, are traced like this. Servers predating tracing are only
+// sent SetTraceContext once.
+func (c *commonClient) tracedCall(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	ctx, span := startSpan(ctx, method)
+	if carrier := injectTrace(ctx); carrier != nil {
+		if args, ok := args.(tracedArgs); ok {
+			args.setTraceContext(carrier)
+		} else if !c.mux.traceUnsupported() {
+			err := c.client.Call(c.endpoint+".SetTraceContext", carrier, new(interface{}))
+			if isMethodNotFound(err) {
+				log.Printf("[DEBUG] The plugin does not support tracing")
+				c.mux.setTraceUnsupported()
+			} else if err != nil {
+				log.Printf("Error in %s.SetTraceContext RPC call: %s", c.endpoint, err)
+			}
+		}
+	}
+	err := c.client.Call(method, args, reply)
+	span.End(err)
+	return err
+}
+
+// TraceContext is embedded in the arguments of the calls carrying the trace
+// context of the client.
+type TraceContext struct {
+	TraceContext map[string]string
+}
+
+func (t *TraceContext) setTraceContext(carrier map[string]string) {
+	t.TraceContext = carrier
+}
+
+// serverTrace holds the trace context sent by the client of a server for
+// its next call.
+type serverTrace struct {
+	l       sync.Mutex
+	carrier map[string]string
+}
+
+// SetTraceContext sets the parent of the span of the next call.
+func (s *commonServer) SetTraceContext(carrier map[string]string, reply *interface{}) error {
+	s.trace.l.Lock()
+	defer s.trace.l.Unlock()
+	s.trace.carrier = carrier
+	return nil
+}
+
+// startSpan starts the span of the call served, a child of the span of the
+// client: the one in carrier, from the arguments of the call, or the one
+// sent by SetTraceContext otherwise.
+func (s *commonServer) startSpan(ctx context.Context, name string, carrier map[string]string) (context.Context, Span) {
+	if carrier == nil {
+		s.trace.l.Lock()
+		carrier = s.trace.carrier
+		s.trace.carrier = nil
+		s.trace.l.Unlock()
+	}
+	return startSpan(extractTrace(ctx, carrier), name)
+}
+
+// grpcSpanName turns the full name of a gRPC method, like
+// "/packer.plugin.Builder/Run", into the span name it shares with net/rpc.
+func grpcSpanName(fullMethod string) string {
+	name := strings.TrimPrefix(fullMethod, "/packer.plugin.")
+	return strings.Replace(name, "/", ".", 1)
+}
+
+func (m *muxBroker) traceUnsupported() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.noTrace
+}
+
+func (m *muxBroker) setTraceUnsupported() {
+	m.Lock()
+	defer m.Unlock()
+	m.noTrace = true
+}
+
+// traceClientCall starts the span of a gRPC call, sending its trace context
+// in the metadata of the call.
+func traceClientCall(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if currentTracer() == nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	ctx, span := startSpan(ctx, grpcSpanName(method))
+	for k, v := range injectTrace(ctx) {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	span.End(err)
+	return err
+}
+
+// traceServerCall starts the span of a gRPC call served, a child of the span
+// of the client, read from the metadata of the call.
+func traceServerCall(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if currentTracer() == nil {
+		return handler(ctx, req)
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		carrier := make(map[string]string, len(md))
+		for k, v := range md {
+			if len(v) > 0 {
+				carrier[k] = v[0]
+			}
+		}
+		ctx = extractTrace(ctx, carrier)
+	}
+	ctx, span := startSpan(ctx, grpcSpanName(info.FullMethod))
+	resp, err := handler(ctx, req)
+	span.End(err)
+	return resp, err
+}
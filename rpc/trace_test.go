@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type spanKey struct{}
+
+// recordingTracer records the spans started, with the name of their parent.
+type recordingTracer struct {
+	l     sync.Mutex
+	spans []string
+	ended int
+}
+
+type recordingSpan struct {
+	t    *recordingTracer
+	name string
+}
+
+func (s *recordingSpan) End(error) {
+	s.t.l.Lock()
+	defer s.t.l.Unlock()
+	s.t.ended++
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, _ map[string]string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	t.l.Lock()
+	defer t.l.Unlock()
+	name = fmt.Sprintf("%s#%d", name, len(t.spans))
+	t.spans = append(t.spans, parent+" > "+name)
+	return context.WithValue(ctx, spanKey{}, name), &recordingSpan{t: t, name: name}
+}
+
+func (t *recordingTracer) Inject(ctx context.Context, carrier map[string]string) {
+	if span, ok := ctx.Value(spanKey{}).(string); ok {
+		carrier["traceparent"] = span
+	}
+}
+
+func (t *recordingTracer) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if span, ok := carrier["traceparent"]; ok {
+		return context.WithValue(ctx, spanKey{}, span)
+	}
+	return ctx
+}
+
+func (t *recordingTracer) recorded() ([]string, int) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	return append([]string(nil), t.spans...), t.ended
+}
+
+func testTracer(t *testing.T) *recordingTracer {
+	tracer := new(recordingTracer)
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+	return tracer
+}
+
+func TestBuilder_trace(t *testing.T) {
+	tracer := testTracer(t)
+	b := new(packersdk.MockBuilder)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	if _, _, err := bClient.Prepare(42); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ctx := context.WithValue(context.Background(), spanKey{}, "build")
+	if _, err := bClient.Run(ctx, new(testUi), new(packersdk.MockHook)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	spans, ended := tracer.recorded()
+	expected := []string{
+		" > Builder.Prepare#0",
+		"Builder.Prepare#0 > Builder.Prepare#1",
+		"build > Builder.Run#2",
+		"Builder.Run#2 > Builder.Run#3",
+	}
+	if !reflect.DeepEqual(spans, expected) {
+		t.Fatalf("expected spans %q, got %q", expected, spans)
+	}
+	if ended != len(spans) {
+		t.Fatalf("%d spans of %d ended", ended, len(spans))
+	}
+}
+
+func TestProvisioner_trace(t *testing.T) {
+	tracer := testTracer(t)
+	p := new(packersdk.MockProvisioner)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterProvisioner(p)
+	pClient := client.Provisioner()
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "build")
+	if err := pClient.Provision(ctx, new(testUi), new(packersdk.MockCommunicator), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	spans, _ := tracer.recorded()
+	expected := []string{
+		"build > Provisioner.Provision#0",
+		"Provisioner.Provision#0 > Provisioner.Provision#1",
+	}
+	if !reflect.DeepEqual(spans, expected) {
+		t.Fatalf("expected spans %q, got %q", expected, spans)
+	}
+}
+
+func TestGRPCBuilder_trace(t *testing.T) {
+	tracer := testTracer(t)
+	b := &packersdk.MockBuilder{ArtifactId: "foo"}
+	client := testGRPCClientServer(t, func(s *GRPCServer) { s.RegisterBuilder(b) })
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "build")
+	if _, err := client.Builder().Run(ctx, new(testUi), new(packersdk.MockHook)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	spans, _ := tracer.recorded()
+	if len(spans) < 2 || spans[0] != "build > Builder.Run#0" || spans[1] != "Builder.Run#0 > Builder.Run#1" {
+		t.Fatalf("bad spans: %q", spans)
+	}
+}
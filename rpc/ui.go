@@ -6,7 +6,11 @@ package rpc
 import (
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -15,15 +19,40 @@ import (
 type Ui struct {
 	commonClient
 	endpoint string
+	// buffer, when set, queues the messages instead of sending them right
+	// away. See UiBufferConfig.
+	buffer *uiBuffer
+	// eventsUnsupported is set once Packer failed to find Ui.Event.
+	eventsUnsupported atomic.Bool
+	// askOptionsUnsupported is set once Packer failed to find
+	// Ui.AskWithOptions.
+	askOptionsUnsupported atomic.Bool
+	// batchUnsupported is set once Packer failed to find Ui.Batch.
+	batchUnsupported atomic.Bool
 }
 
 var _ packersdk.Ui = new(Ui)
+var _ packersdk.ProgressUi = new(Ui)
+var _ packersdk.EventUi = new(Ui)
+var _ packersdk.AskOptionsUi = new(Ui)
+var _ packersdk.BatchUi = new(Ui)
 
 // UiServer wraps a packersdk.Ui implementation and makes it exportable
 // as part of a Golang RPC server.
 type UiServer struct {
 	ui       packersdk.Ui
 	register func(name string, rcvr interface{}) error
+
+	// trackers are the progress trackers started with StartProgress.
+	l        sync.Mutex
+	trackers map[string]packersdk.ProgressTracker
+}
+
+// The arguments sent to Ui.AskWithOptions
+type UiAskArgs struct {
+	Query   string
+	Timeout time.Duration
+	Default string
 }
 
 // The arguments sent to Ui.Machine
@@ -32,24 +61,58 @@ type UiMachineArgs struct {
 	Args     []string
 }
 
+// redact scrubs the secrets registered with packersdk.LogSecretFilter from a
+// message. Both ends of a Ui connection redact the messages: the plugin knows
+// the secrets of its configuration, and Packer the sensitive variables.
+func redact(message string) string {
+	return packersdk.LogSecretFilter.FilterString(message)
+}
+
 func (u *Ui) Askf(query string, args ...any) (string, error) {
-	return u.Ask(fmt.Sprintf(query, args...))
+	return u.Ask(i18n.Sprintf(query, args...))
 }
 func (u *Ui) Ask(query string) (result string, err error) {
+	u.Flush()
 	err = u.client.Call("Ui.Ask", query, &result)
 	return
 }
 
+// AskWithOptions asks Packer to apply opts, so that the question stops being
+// displayed when it times out. Versions of Packer that can't are asked
+// without them, and the default answer is used on their behalf.
+func (u *Ui) AskWithOptions(query string, opts packersdk.AskOptions) (string, error) {
+	u.Flush()
+	if !u.askOptionsUnsupported.Load() {
+		var result string
+		args := &UiAskArgs{Query: query, Timeout: opts.Timeout, Default: opts.Default}
+		err := u.client.Call("Ui.AskWithOptions", args, &result)
+		if !isMethodNotFound(err) {
+			return result, err
+		}
+		u.askOptionsUnsupported.Store(true)
+	}
+	return packersdk.AskWithOptions(uiMethods{u}, query, opts)
+}
+
 func (u *Ui) Errorf(message string, args ...any) {
-	u.Error(fmt.Sprintf(message, args...))
+	u.Error(i18n.Sprintf(message, args...))
 }
 func (u *Ui) Error(message string) {
+	message = redact(message)
+	if u.buffer != nil {
+		u.buffer.add(UiFrame{Method: "Error", Message: message})
+		return
+	}
 	if err := u.client.Call("Ui.Error", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui.Error RPC call: %s", err)
 	}
 }
 
 func (u *Ui) Machine(t string, args ...string) {
+	if u.buffer != nil {
+		u.buffer.add(UiFrame{Method: "Machine", Category: t, Args: args})
+		return
+	}
 	rpcArgs := &UiMachineArgs{
 		Category: t,
 		Args:     args,
@@ -61,27 +124,109 @@ func (u *Ui) Machine(t string, args ...string) {
 }
 
 func (u *Ui) Message(message string) {
+	message = redact(message)
+	if u.buffer != nil {
+		u.buffer.add(UiFrame{Method: "Message", Message: message})
+		return
+	}
 	if err := u.client.Call("Ui.Message", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui.Message RPC call: %s", err)
 	}
 }
 
+// SayBatch sends messages to Packer in one call.
+func (u *Ui) SayBatch(messages []string) {
+	u.batch("Say", messages)
+}
+
+// MessageBatch sends messages to Packer in one call.
+func (u *Ui) MessageBatch(messages []string) {
+	u.batch("Message", messages)
+}
+
+func (u *Ui) batch(method string, messages []string) {
+	now := time.Now()
+	frames := make([]UiFrame, len(messages))
+	for i, m := range messages {
+		frames[i] = UiFrame{Method: method, Message: redact(m), Time: now}
+	}
+	if u.buffer != nil {
+		for _, frame := range frames {
+			u.buffer.add(frame)
+		}
+		return
+	}
+	sendFrames(u.client, frames, &u.batchUnsupported)
+}
+
 func (u *Ui) Sayf(message string, args ...any) {
-	u.Say(fmt.Sprintf(message, args...))
+	u.Say(i18n.Sprintf(message, args...))
 }
 func (u *Ui) Say(message string) {
+	message = redact(message)
+	if u.buffer != nil {
+		u.buffer.add(UiFrame{Method: "Say", Message: message})
+		return
+	}
 	if err := u.client.Call("Ui.Say", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui.Say RPC call: %s", err)
 	}
 }
 
+// Event sends e to the Ui of Packer, as is when it is a packersdk.EventUi.
+// Events are sent as the calls of their Ui method to versions of Packer that
+// don't support them.
+func (u *Ui) Event(e packersdk.UiEvent) {
+	e.Payload.Message = redact(e.Payload.Message)
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	frame, canBatch := eventFrame(e)
+	if u.buffer != nil && canBatch {
+		u.buffer.add(frame)
+		return
+	}
+	if !u.eventsUnsupported.Load() {
+		err := u.client.Call("Ui.Event", &e, new(interface{}))
+		if err == nil {
+			return
+		}
+		if !isMethodNotFound(err) {
+			log.Printf("Error in Ui.Event RPC call: %s", err)
+			return
+		}
+		u.eventsUnsupported.Store(true)
+	}
+	e.Timestamp = time.Time{}
+	packersdk.SendEvent(uiMethods{u}, e)
+}
+
+// uiMethods hides the Event method of a Ui, to send events as method calls.
+type uiMethods struct{ packersdk.Ui }
+
+// Flush sends the messages buffered by u, if any, and returns once Packer
+// received them.
+func (u *Ui) Flush() {
+	if u.buffer != nil {
+		u.buffer.flush()
+	}
+}
+
 func (u *UiServer) Ask(query string, reply *string) (err error) {
 	*reply, err = u.ui.Ask(query)
 	return
 }
 
+func (u *UiServer) AskWithOptions(args *UiAskArgs, reply *string) (err error) {
+	*reply, err = packersdk.AskWithOptions(u.ui, args.Query, packersdk.AskOptions{
+		Timeout: args.Timeout,
+		Default: args.Default,
+	})
+	return
+}
+
 func (u *UiServer) Error(message *string, reply *interface{}) error {
-	u.ui.Error(*message)
+	u.ui.Error(redact(*message))
 
 	*reply = nil
 	return nil
@@ -95,13 +240,50 @@ func (u *UiServer) Machine(args *UiMachineArgs, reply *interface{}) error {
 }
 
 func (u *UiServer) Message(message *string, reply *interface{}) error {
-	u.ui.Message(*message)
+	u.ui.Message(redact(*message))
 	*reply = nil
 	return nil
 }
 
 func (u *UiServer) Say(message *string, reply *interface{}) error {
-	u.ui.Say(*message)
+	u.ui.Say(redact(*message))
+
+	*reply = nil
+	return nil
+}
+
+// Event runs the Ui call of e, or sends it as is to a packersdk.EventUi.
+func (u *UiServer) Event(e *packersdk.UiEvent, reply *interface{}) error {
+	e.Payload.Message = redact(e.Payload.Message)
+	packersdk.SendEvent(u.ui, *e)
+
+	*reply = nil
+	return nil
+}
+
+// Batch runs the Ui calls of frames in order. They are sent as events, with
+// the time they were made, to a packersdk.EventUi.
+func (u *UiServer) Batch(frames []UiFrame, reply *interface{}) error {
+	eui, isEventUi := u.ui.(packersdk.EventUi)
+	for _, frame := range frames {
+		if e, ok := frame.event(); ok && isEventUi && !frame.Time.IsZero() {
+			e.Payload.Message = redact(e.Payload.Message)
+			eui.Event(e)
+			continue
+		}
+		switch frame.Method {
+		case "Say":
+			u.ui.Say(redact(frame.Message))
+		case "Message":
+			u.ui.Message(redact(frame.Message))
+		case "Error":
+			u.ui.Error(redact(frame.Message))
+		case "Machine":
+			u.ui.Machine(frame.Category, frame.Args...)
+		default:
+			return fmt.Errorf("unknown Ui method %q", frame.Method)
+		}
+	}
 
 	*reply = nil
 	return nil
@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"log"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// UiBufferConfig configures how the messages a plugin writes to its Ui are
+// buffered before being sent to Packer in batches.
+type UiBufferConfig struct {
+	// FlushInterval is the longest a message waits before being sent.
+	FlushInterval time.Duration
+	// MaxMessages is the number of waiting messages that triggers a flush.
+	// The calls writing messages block while a full buffer is flushed, so
+	// that a chatty plugin cannot outpace Packer.
+	MaxMessages int
+}
+
+// DefaultUiBufferConfig suits most plugins, and fills the fields left unset
+// in the configuration passed to PluginServer.BufferUi.
+var DefaultUiBufferConfig = UiBufferConfig{
+	FlushInterval: 100 * time.Millisecond,
+	MaxMessages:   64,
+}
+
+// UiFrame is one of the Ui calls sent by Ui.Batch.
+type UiFrame struct {
+	// Method is the name of the Ui method: Say, Message, Error or Machine.
+	Method   string
+	Message  string
+	Category string
+	Args     []string
+	// Time is when the call was made, for Packer to timestamp the events of
+	// the batch right.
+	Time time.Time
+}
+
+// frameMethods are the Ui methods of the event types that can be sent in a
+// batch.
+var frameMethods = map[packersdk.UiEventType]string{
+	packersdk.UiEventSay:     "Say",
+	packersdk.UiEventMessage: "Message",
+	packersdk.UiEventError:   "Error",
+	packersdk.UiEventMachine: "Machine",
+}
+
+func eventFrame(e packersdk.UiEvent) (UiFrame, bool) {
+	method, ok := frameMethods[e.Type]
+	return UiFrame{
+		Method:   method,
+		Message:  e.Payload.Message,
+		Category: e.Payload.Category,
+		Args:     e.Payload.Args,
+		Time:     e.Timestamp,
+	}, ok
+}
+
+func (f UiFrame) event() (packersdk.UiEvent, bool) {
+	for t, method := range frameMethods {
+		if method == f.Method {
+			return packersdk.UiEvent{
+				Type:      t,
+				Timestamp: f.Time,
+				Payload:   packersdk.UiEventPayload{Message: f.Message, Category: f.Category, Args: f.Args},
+			}, true
+		}
+	}
+	return packersdk.UiEvent{}, false
+}
+
+// uiBuffer queues the messages of a Ui and sends them in order, in batches.
+type uiBuffer struct {
+	client *rpc.Client
+	config UiBufferConfig
+
+	// sendLock is held while a batch is sent, so batches are received in
+	// the order they were taken from the buffer.
+	sendLock sync.Mutex
+	// unsupported is set once Packer failed to find Ui.Batch, in which
+	// case frames are sent one call at a time.
+	unsupported atomic.Bool
+
+	l      sync.Mutex
+	frames []UiFrame
+	timer  *time.Timer
+}
+
+func newUiBuffer(client *rpc.Client, config UiBufferConfig) *uiBuffer {
+	if config.MaxMessages <= 0 {
+		config.MaxMessages = DefaultUiBufferConfig.MaxMessages
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultUiBufferConfig.FlushInterval
+	}
+	return &uiBuffer{client: client, config: config}
+}
+
+func (b *uiBuffer) add(frame UiFrame) {
+	if frame.Time.IsZero() {
+		frame.Time = time.Now()
+	}
+	b.l.Lock()
+	b.frames = append(b.frames, frame)
+	if len(b.frames) >= b.config.MaxMessages {
+		b.l.Unlock()
+		b.flush()
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.config.FlushInterval, b.flush)
+	}
+	b.l.Unlock()
+}
+
+// flush sends the waiting messages and returns once Packer received them.
+func (b *uiBuffer) flush() {
+	b.sendLock.Lock()
+	defer b.sendLock.Unlock()
+
+	b.l.Lock()
+	frames := b.frames
+	b.frames = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.l.Unlock()
+
+	if len(frames) == 0 {
+		return
+	}
+	sendFrames(b.client, frames, &b.unsupported)
+}
+
+// sendFrames sends frames in one Ui.Batch call, or one call at a time once
+// unsupported is set, when Packer failed to find Ui.Batch.
+func sendFrames(client *rpc.Client, frames []UiFrame, unsupported *atomic.Bool) {
+	if !unsupported.Load() {
+		err := client.Call("Ui.Batch", frames, new(interface{}))
+		if err == nil {
+			return
+		}
+		if !isMethodNotFound(err) {
+			log.Printf("Error in Ui.Batch RPC call: %s", err)
+			return
+		}
+		log.Printf("[DEBUG] Packer does not support Ui.Batch, sending messages one at a time")
+		unsupported.Store(true)
+	}
+	for _, frame := range frames {
+		var args interface{} = frame.Message
+		if frame.Method == "Machine" {
+			args = &UiMachineArgs{Category: frame.Category, Args: frame.Args}
+		}
+		if err := client.Call("Ui."+frame.Method, args, new(interface{})); err != nil {
+			log.Printf("Error in Ui.%s RPC call: %s", frame.Method, err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// recordingUi records the order of the calls it receives.
+type recordingUi struct {
+	testUi
+
+	l     sync.Mutex
+	calls []string
+}
+
+func (u *recordingUi) record(call string) {
+	u.l.Lock()
+	defer u.l.Unlock()
+	u.calls = append(u.calls, call)
+}
+
+func (u *recordingUi) recorded() []string {
+	u.l.Lock()
+	defer u.l.Unlock()
+	return append([]string(nil), u.calls...)
+}
+
+func (u *recordingUi) Ask(query string) (string, error) {
+	u.record("ask " + query)
+	return "foo", nil
+}
+
+func (u *recordingUi) Error(message string)   { u.record("error " + message) }
+func (u *recordingUi) Message(message string) { u.record("message " + message) }
+func (u *recordingUi) Say(message string)     { u.record("say " + message) }
+
+func (u *recordingUi) Machine(t string, args ...string) {
+	u.record("machine " + t)
+}
+
+// legacyUiServer is the Ui server of a Packer that doesn't know Ui.Batch.
+type legacyUiServer struct {
+	ui *recordingUi
+}
+
+func (u *legacyUiServer) Say(message *string, reply *interface{}) error {
+	u.ui.Say(*message)
+	*reply = nil
+	return nil
+}
+
+func (u *legacyUiServer) Machine(args *UiMachineArgs, reply *interface{}) error {
+	u.ui.Machine(args.Category, args.Args...)
+	*reply = nil
+	return nil
+}
+
+func TestUiRPC_Buffered(t *testing.T) {
+	ui := new(recordingUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	client.mux.setUiBufferConfig(&UiBufferConfig{FlushInterval: time.Hour, MaxMessages: 3})
+	uiClient := client.Ui()
+
+	uiClient.Say("1")
+	uiClient.Message("2")
+	if calls := ui.recorded(); len(calls) != 0 {
+		t.Fatalf("messages should be buffered: %#v", calls)
+	}
+	uiClient.Error("3")
+	expected := []string{"say 1", "message 2", "error 3"}
+	if calls := ui.recorded(); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad calls: %#v", calls)
+	}
+
+	uiClient.Machine("foo", "bar")
+	if _, err := uiClient.Ask("query"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	uiClient.Say("4")
+	client.flushUi()
+	expected = append(expected, "machine foo", "ask query", "say 4")
+	if calls := ui.recorded(); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad calls: %#v", calls)
+	}
+}
+
+func TestUiRPC_BufferedInterval(t *testing.T) {
+	ui := new(recordingUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	client.mux.setUiBufferConfig(&UiBufferConfig{FlushInterval: time.Millisecond, MaxMessages: 100})
+	client.Ui().Say("1")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ui.recorded()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("message was not flushed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUiRPC_BufferedLegacy(t *testing.T) {
+	ui := new(recordingUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	if err := server.server.RegisterName(DefaultUiEndpoint, &legacyUiServer{ui: ui}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client.mux.setUiBufferConfig(&UiBufferConfig{FlushInterval: time.Hour, MaxMessages: 2})
+	uiClient := client.Ui()
+	uiClient.Say("1")
+	uiClient.Machine("foo")
+	uiClient.Say("2")
+	client.flushUi()
+
+	expected := []string{"say 1", "machine foo", "say 2"}
+	if calls := ui.recorded(); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad calls: %#v", calls)
+	}
+}
+
+func TestUiRPC_Events(t *testing.T) {
+	var out bytes.Buffer
+	ui := &packersdk.MachineReadableUi{Writer: &out, BuildName: "docker.ubuntu"}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	sent := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	uiClient := client.Ui().(packersdk.EventUi)
+	uiClient.Event(packersdk.UiEvent{
+		Type:      packersdk.UiEventSay,
+		Timestamp: sent,
+		Payload:   packersdk.UiEventPayload{Message: "1"},
+	})
+
+	client.mux.setUiBufferConfig(&UiBufferConfig{FlushInterval: time.Hour, MaxMessages: 100})
+	uiClient = client.Ui().(packersdk.EventUi)
+	before := time.Now()
+	uiClient.Machine("artifact", "0", "id")
+	time.Sleep(10 * time.Millisecond)
+	flushed := time.Now()
+	client.flushUi()
+
+	dec := packersdk.NewUiEventDecoder(&out)
+	var events []packersdk.UiEvent
+	for {
+		e, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 {
+		t.Fatalf("bad events: %#v", events)
+	}
+	if e := events[0]; e.Type != packersdk.UiEventSay || !e.Timestamp.Equal(sent) || e.Payload.Message != "1" || e.BuildName != "docker.ubuntu" {
+		t.Fatalf("bad event: %#v", e)
+	}
+	e := events[1]
+	if e.Type != packersdk.UiEventMachine || e.Payload.Category != "artifact" || !reflect.DeepEqual(e.Payload.Args, []string{"0", "id"}) {
+		t.Fatalf("bad event: %#v", e)
+	}
+	// The event is timestamped when the plugin made the call, not when the
+	// batch was received.
+	if e.Timestamp.Before(before) || !e.Timestamp.Before(flushed) {
+		t.Fatalf("bad timestamp: %s, expected between %s and %s", e.Timestamp, before, flushed)
+	}
+}
+
+func TestUiRPC_EventsLegacy(t *testing.T) {
+	ui := new(recordingUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	if err := server.server.RegisterName(DefaultUiEndpoint, &legacyUiServer{ui: ui}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	uiClient := client.Ui().(packersdk.EventUi)
+	uiClient.Event(packersdk.UiEvent{Type: packersdk.UiEventSay, Payload: packersdk.UiEventPayload{Message: "1"}})
+	uiClient.Event(packersdk.UiEvent{Type: packersdk.UiEventSay, Payload: packersdk.UiEventPayload{Message: "2"}})
+
+	expected := []string{"say 1", "say 2"}
+	if calls := ui.recorded(); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad calls: %#v", calls)
+	}
+}
+
+// countingUiServer counts the Ui.Batch calls.
+type countingUiServer struct {
+	*UiServer
+	batches int
+}
+
+func (s *countingUiServer) Batch(frames []UiFrame, reply *interface{}) error {
+	s.batches++
+	return s.UiServer.Batch(frames, reply)
+}
+
+func TestUiRPC_SayBatch(t *testing.T) {
+	ui := new(recordingUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	uiServer := &countingUiServer{UiServer: &UiServer{ui: ui}}
+	if err := server.server.RegisterName(DefaultUiEndpoint, uiServer); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	uiClient := client.Ui()
+	packersdk.SayBatch(uiClient, []string{"1", "2"})
+	packersdk.MessageBatch(uiClient, []string{"3"})
+	expected := []string{"say 1", "say 2", "message 3"}
+	if calls := ui.recorded(); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad calls: %#v", calls)
+	}
+	if uiServer.batches != 2 {
+		t.Fatalf("expected 2 batches, got %d", uiServer.batches)
+	}
+}
+
+func TestUiRPC_SayBatchLegacy(t *testing.T) {
+	ui := new(recordingUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	if err := server.server.RegisterName(DefaultUiEndpoint, &legacyUiServer{ui: ui}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	packersdk.SayBatch(client.Ui(), []string{"1", "2"})
+	expected := []string{"say 1", "say 2"}
+	if calls := ui.recorded(); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad calls: %#v", calls)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"fmt"
+	"log"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/random"
+)
+
+// The arguments sent to Ui.StartProgress
+type UiStartProgressArgs struct {
+	Label string
+	Total int64
+}
+
+// The arguments sent to Ui.AddProgress
+type UiAddProgressArgs struct {
+	ID string
+	N  int64
+}
+
+// NewProgressTracker returns a tracker whose calls are made over RPC, so
+// Packer receives them as they are rather than as a stream of bytes.
+func (u *Ui) NewProgressTracker() packersdk.ProgressTracker {
+	return &progressTracker{ui: u}
+}
+
+// progressTracker is a packersdk.ProgressTracker whose calls are executed
+// over RPC. It falls back on Ui.TrackProgress when Packer doesn't support
+// Ui.StartProgress.
+type progressTracker struct {
+	ui       *Ui
+	id       string
+	fallback packersdk.ProgressTracker
+}
+
+func (t *progressTracker) Start(label string, total int64) {
+	// Messages written before the operation started are displayed first.
+	t.ui.Flush()
+	args := &UiStartProgressArgs{Label: label, Total: total}
+	err := t.ui.client.Call("Ui.StartProgress", args, &t.id)
+	if err == nil {
+		return
+	}
+	if !isMethodNotFound(err) {
+		log.Printf("Error in Ui.StartProgress RPC call: %s", err)
+		return
+	}
+	t.fallback = packersdk.NewUiProgressTracker(t.ui)
+	t.fallback.Start(label, total)
+}
+
+func (t *progressTracker) Add(n int64) {
+	if t.fallback != nil {
+		t.fallback.Add(n)
+		return
+	}
+	if t.id == "" {
+		return
+	}
+	if err := t.ui.client.Call("Ui.AddProgress", &UiAddProgressArgs{ID: t.id, N: n}, new(interface{})); err != nil {
+		log.Printf("Error in Ui.AddProgress RPC call: %s", err)
+	}
+}
+
+func (t *progressTracker) Finish() {
+	if t.fallback != nil {
+		t.fallback.Finish()
+		return
+	}
+	if t.id == "" {
+		return
+	}
+	if err := t.ui.client.Call("Ui.FinishProgress", t.id, new(interface{})); err != nil {
+		log.Printf("Error in Ui.FinishProgress RPC call: %s", err)
+	}
+	t.id = ""
+}
+
+func (u *UiServer) StartProgress(args *UiStartProgressArgs, reply *string) error {
+	t := packersdk.NewProgressTracker(u.ui)
+	t.Start(args.Label, args.Total)
+
+	u.l.Lock()
+	defer u.l.Unlock()
+	if u.trackers == nil {
+		u.trackers = make(map[string]packersdk.ProgressTracker)
+	}
+	*reply = random.AlphaNum(8)
+	u.trackers[*reply] = t
+	return nil
+}
+
+func (u *UiServer) progressTracker(id string) (packersdk.ProgressTracker, error) {
+	u.l.Lock()
+	defer u.l.Unlock()
+	t, ok := u.trackers[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown progress tracker %q", id)
+	}
+	return t, nil
+}
+
+func (u *UiServer) AddProgress(args *UiAddProgressArgs, reply *interface{}) error {
+	t, err := u.progressTracker(args.ID)
+	if err != nil {
+		return err
+	}
+	t.Add(args.N)
+
+	*reply = nil
+	return nil
+}
+
+func (u *UiServer) FinishProgress(id *string, reply *interface{}) error {
+	t, err := u.progressTracker(*id)
+	if err != nil {
+		return err
+	}
+	t.Finish()
+
+	u.l.Lock()
+	delete(u.trackers, *id)
+	u.l.Unlock()
+
+	*reply = nil
+	return nil
+}
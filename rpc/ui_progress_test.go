@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// progressUi records the calls of the progress trackers it creates.
+type progressUi struct {
+	recordingUi
+}
+
+func (u *progressUi) NewProgressTracker() packersdk.ProgressTracker {
+	return &recordingTracker{ui: &u.recordingUi}
+}
+
+type recordingTracker struct {
+	ui *recordingUi
+}
+
+func (t *recordingTracker) Start(label string, total int64) {
+	t.ui.record(fmt.Sprintf("start %s %d", label, total))
+}
+
+func (t *recordingTracker) Add(n int64) { t.ui.record(fmt.Sprintf("add %d", n)) }
+func (t *recordingTracker) Finish()     { t.ui.record("finish") }
+
+func TestUiRPC_ProgressTracker(t *testing.T) {
+	ui := new(progressUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	tracker := packersdk.NewProgressTracker(client.Ui())
+	tracker.Start("Uploading iso", 10)
+	tracker.Add(4)
+	tracker.Add(6)
+	tracker.Finish()
+
+	expected := []string{"start Uploading iso 10", "add 4", "add 6", "finish"}
+	if calls := ui.recorded(); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad calls: %#v", calls)
+	}
+}
+
+func TestUiRPC_ProgressTrackerLegacy(t *testing.T) {
+	ui := new(testUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	// The Ui server of a Packer that only knows Ui.TrackProgress.
+	uiServer := &UiServer{ui: ui, register: server.server.RegisterName}
+	if err := server.server.RegisterName(DefaultUiEndpoint, &legacyProgressUiServer{uiServer}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	tracker := packersdk.NewProgressTracker(client.Ui())
+	tracker.Start("Uploading iso", 10)
+	tracker.Add(4)
+	tracker.Finish()
+
+	if !ui.trackProgressCalled || !ui.progressBarAddCalled || !ui.progressBarCloseCalled {
+		t.Fatalf("progress should be tracked with TrackProgress: %#v", ui)
+	}
+}
+
+type legacyProgressUiServer struct {
+	s *UiServer
+}
+
+func (u *legacyProgressUiServer) NewTrackProgress(pl *TrackProgressParameters, reply *string) error {
+	return u.s.NewTrackProgress(pl, reply)
+}
@@ -15,6 +15,7 @@ import (
 // that will send the size of each read bytes of stream.
 // In order to track an operation on the terminal side.
 func (u *Ui) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	u.Flush()
 	pl := &TrackProgressParameters{
 		Src:         src,
 		CurrentSize: currentSize,
@@ -9,6 +9,9 @@ import (
 	"io"
 	"reflect"
 	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 type testUi struct {
@@ -163,3 +166,65 @@ func TestUiRPC(t *testing.T) {
 		t.Fatalf("bad: %#v", ui.machineArgs)
 	}
 }
+
+func TestUiRPC_redact(t *testing.T) {
+	ui := new(testUi)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+	uiClient := client.Ui()
+
+	packersdk.LogSecretFilter.Set("hunter2")
+
+	uiClient.Say("password: hunter2")
+	if ui.sayMessage != "password: <sensitive>" {
+		t.Fatalf("bad: %#v", ui.sayMessage)
+	}
+	uiClient.Message("hunter2")
+	if ui.messageMessage != "<sensitive>" {
+		t.Fatalf("bad: %#v", ui.messageMessage)
+	}
+
+	// Packer redacts the messages of the plugins too.
+	var reply interface{}
+	message := "hunter2 rejected"
+	if err := (&UiServer{ui: ui}).Error(&message, &reply); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ui.errorMessage != "<sensitive> rejected" {
+		t.Fatalf("bad: %#v", ui.errorMessage)
+	}
+}
+
+// silentTTY never answers.
+type silentTTY struct {
+	done chan struct{}
+}
+
+func (t *silentTTY) ReadString() (string, error) {
+	<-t.done
+	return "", io.EOF
+}
+
+func (t *silentTTY) Close() error { return nil }
+
+func TestUiRPC_AskWithOptions(t *testing.T) {
+	tty := &silentTTY{done: make(chan struct{})}
+	defer close(tty.done)
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: io.Discard, ErrorWriter: io.Discard, TTY: tty}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+	uiClient := client.Ui()
+
+	answer, err := packersdk.AskWithOptions(uiClient, "Continue?", packersdk.AskOptions{
+		Timeout: 10 * time.Millisecond,
+		Default: "yes",
+	})
+	if err != nil || answer != "yes" {
+		t.Fatalf("answer: %q, err: %v", answer, err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"log"
+
+	"github.com/hashicorp/hcl/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type ValidateConfigArgs struct {
+	Configs []interface{}
+}
+
+type ValidateConfigResponse struct {
+	Diagnostics []Diagnostic
+	// Unsupported is set when the component does not implement
+	// packersdk.ConfigValidator.
+	Unsupported bool
+}
+
+// SupportsValidateConfig tells whether the remote component can validate its
+// configuration without being prepared. It is false for plugins built with
+// an SDK predating packersdk.ConfigValidator.
+func (p *commonClient) SupportsValidateConfig() bool {
+	var supported bool
+	if err := p.client.Call(p.endpoint+".SupportsValidateConfig", new(interface{}), &supported); err != nil {
+		log.Printf("[DEBUG] %s does not support ValidateConfig: %s", p.endpoint, err)
+		return false
+	}
+	return supported
+}
+
+func (p *commonClient) ValidateConfig(configs ...interface{}) hcl.Diagnostics {
+	configs, err := encodeCTYValues(configs)
+	if err != nil {
+		return packersdk.ErrorDiagnostics(err)
+	}
+	resp := new(ValidateConfigResponse)
+	if err := p.client.Call(p.endpoint+".ValidateConfig", &ValidateConfigArgs{configs}, resp); err != nil {
+		return packersdk.ErrorDiagnostics(err)
+	}
+	if resp.Unsupported {
+		return hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Configuration validation is not supported by this plugin",
+		}}
+	}
+	return hclDiagnostics(resp.Diagnostics)
+}
+
+func (s *commonServer) SupportsValidateConfig(_ interface{}, reply *bool) error {
+	_, *reply = s.selfConfigurable.(packersdk.ConfigValidator)
+	return nil
+}
+
+func (s *commonServer) ValidateConfig(args *ValidateConfigArgs, reply *ValidateConfigResponse) error {
+	validator, ok := s.selfConfigurable.(packersdk.ConfigValidator)
+	if !ok {
+		reply.Unsupported = true
+		return nil
+	}
+	configs, err := decodeCTYValues(args.Configs)
+	if err != nil {
+		return err
+	}
+	reply.Diagnostics = newDiagnostics(validator.ValidateConfig(configs...))
+	return nil
+}
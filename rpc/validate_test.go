@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type validatingBuilder struct {
+	packersdk.MockBuilder
+
+	validateConfig []interface{}
+}
+
+func (b *validatingBuilder) ValidateConfig(raws ...interface{}) hcl.Diagnostics {
+	b.validateConfig = raws
+	return hcl.Diagnostics{
+		packersdk.AttributeDiagnostic(hcl.DiagError, "ssh_port", "Invalid ssh_port", "ssh_port must be between 1 and 65535"),
+		{
+			Severity: hcl.DiagWarning,
+			Summary:  "Deprecated option",
+			Subject:  &hcl.Range{Filename: "build.pkr.hcl", Start: hcl.Pos{Line: 3, Column: 3}},
+		},
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	b := new(validatingBuilder)
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	config := map[string]interface{}{"ssh_port": 0}
+	diags, ok := packersdk.ValidateConfig(bClient, config)
+	if !ok {
+		t.Fatal("expected the builder to support ValidateConfig")
+	}
+	if b.PrepareCalled {
+		t.Fatal("Prepare should not be called")
+	}
+	if len(b.validateConfig) != 1 {
+		t.Fatalf("bad config: %#v", b.validateConfig)
+	}
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %#v", diags)
+	}
+	if name, ok := packersdk.DiagnosticAttributeName(diags[0]); !ok || name != "ssh_port" {
+		t.Fatalf("expected a diagnostic about ssh_port, got %#v", diags[0])
+	}
+	if diags[0].Severity != hcl.DiagError || diags[0].Detail != "ssh_port must be between 1 and 65535" {
+		t.Fatalf("bad diagnostic: %#v", diags[0])
+	}
+	if diags[1].Severity != hcl.DiagWarning || diags[1].Subject == nil || diags[1].Subject.Start.Line != 3 {
+		t.Fatalf("bad diagnostic: %#v", diags[1])
+	}
+}
+
+func TestValidateConfig_unsupported(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+
+	server.RegisterBuilder(new(packersdk.MockBuilder))
+	if _, ok := packersdk.ValidateConfig(client.Builder(), map[string]interface{}{}); ok {
+		t.Fatal("a builder not implementing ConfigValidator should not support ValidateConfig")
+	}
+}
@@ -47,6 +47,10 @@ func (c *comm) Download(path string, output io.Writer) error {
 	return errors.New("Download is not implemented when communicator = 'none'")
 }
 
+func (c *comm) Capabilities() (packersdk.CommunicatorCapabilities, bool) {
+	return packersdk.CommunicatorCapabilities{}, true
+}
+
 func (c *comm) DownloadDir(dst string, src string, excl []string) error {
 	return errors.New("DownloadDir is not implemented when communicator = 'none'")
 }
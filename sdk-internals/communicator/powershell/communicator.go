@@ -0,0 +1,181 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package powershell implements a communicator running the commands of
+// another one, usually the SSH communicator connected to a Windows OpenSSH
+// server, in PowerShell. Plugin maintainers should not import this package
+// directly, instead using the tooling in the "packer-plugin-sdk/communicator"
+// module.
+//
+// Commands are run by powershell.exe over the sessions of the underlying
+// communicator, this is not an implementation of the PowerShell Remoting
+// Protocol. It however gives the guarantees provisioners expect from a
+// PowerShell remoting session: the exit code of the command is the one of
+// the remote command, errors terminate the command with a non-zero exit
+// code, output is UTF-8, and commands can be run elevated.
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"unicode/utf16"
+
+	"github.com/hashicorp/packer-plugin-sdk/guestexec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// maxCommandLength is the length above which commands are uploaded as a
+// script instead of being passed to powershell.exe, to stay below the
+// length limit of cmd.exe command lines.
+const maxCommandLength = 8000
+
+const prelude = `$ProgressPreference = 'SilentlyContinue'
+[Console]::OutputEncoding = [System.Text.Encoding]::UTF8
+$OutputEncoding = [System.Text.Encoding]::UTF8
+$ErrorActionPreference = 'Stop'
+try {
+`
+
+const epilogue = `
+  $ok = $?
+  if ($LASTEXITCODE) { exit $LASTEXITCODE }
+  if (!$ok) { exit 1 }
+} catch {
+  [Console]::Error.WriteLine(($_ | Out-String))
+  exit 1
+}
+exit 0
+`
+
+// Config is the configuration of the PowerShell communicator.
+type Config struct {
+	// ElevatedUser, if set, is the user commands are run as, with the
+	// highest privileges available, through a scheduled task.
+	ElevatedUser string
+
+	// ElevatedPassword is the password of ElevatedUser.
+	ElevatedPassword string
+}
+
+// Communicator runs the commands of the wrapped communicator in PowerShell.
+// File transfers are left to the wrapped communicator.
+type Communicator struct {
+	packersdk.Communicator
+	config *Config
+}
+
+// New returns a communicator running the commands of comm in PowerShell.
+func New(comm packersdk.Communicator, config *Config) *Communicator {
+	return &Communicator{Communicator: comm, config: config}
+}
+
+func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	command, err := c.command(cmd.Command)
+	if err != nil {
+		return err
+	}
+
+	remote := &packersdk.RemoteCmd{
+		Command: command,
+		Stdin:   cmd.Stdin,
+		Stdout:  cmd.Stdout,
+		Stderr:  cmd.Stderr,
+	}
+	log.Printf("[DEBUG] running in PowerShell: %s", cmd.Command)
+	if err := c.Communicator.Start(ctx, remote); err != nil {
+		return err
+	}
+	go func() {
+		cmd.SetExited(remote.Wait())
+	}()
+	return nil
+}
+
+// Capabilities returns the ones of the wrapped communicator, but pseudo
+// terminals, which PowerShell does not use.
+func (c *Communicator) Capabilities() (packersdk.CommunicatorCapabilities, bool) {
+	caps, ok := packersdk.CapabilitiesOf(c.Communicator)
+	caps.PTY = false
+	return caps, ok
+}
+
+// Tunnel opens a tunnel with the wrapped communicator.
+func (c *Communicator) Tunnel(ctx context.Context, local, remote string) (packersdk.Tunnel, error) {
+	return packersdk.OpenTunnel(ctx, c.Communicator, local, remote)
+}
+
+// ReverseTunnel opens a reverse tunnel with the wrapped communicator.
+func (c *Communicator) ReverseTunnel(ctx context.Context, remote, local string) (packersdk.Tunnel, error) {
+	return packersdk.OpenReverseTunnel(ctx, c.Communicator, remote, local)
+}
+
+// command returns the command running script in PowerShell.
+func (c *Communicator) command(script string) (string, error) {
+	command, err := c.powershell(prelude + script + epilogue)
+	if err != nil {
+		return "", err
+	}
+	if c.config.ElevatedUser == "" {
+		return command, nil
+	}
+
+	// GenerateElevatedRunner returns the command running the runner it
+	// uploaded.
+	runner, err := guestexec.GenerateElevatedRunner(command, &elevatedRunner{comm: c.Communicator, config: c.config})
+	if err != nil {
+		return "", fmt.Errorf("Error generating the elevated runner: %s", err)
+	}
+	return runner, nil
+}
+
+// powershell returns the powershell.exe command line running script. Long
+// scripts are uploaded as files first.
+func (c *Communicator) powershell(script string) (string, error) {
+	command := "powershell.exe -NoProfile -NonInteractive -ExecutionPolicy Bypass -EncodedCommand " + encodeCommand(script)
+	if len(command) <= maxCommandLength {
+		return command, nil
+	}
+
+	path := fmt.Sprintf("C:/Windows/Temp/packer-ps-%s.ps1", uuid.TimeOrderedUUID())
+	// The BOM makes Windows PowerShell read the script as UTF-8.
+	body := append([]byte("\xef\xbb\xbf"), script...)
+	if err := c.Communicator.Upload(path, bytes.NewReader(body), nil); err != nil {
+		return "", fmt.Errorf("Error uploading PowerShell script: %s", err)
+	}
+	return fmt.Sprintf(`powershell.exe -NoProfile -NonInteractive -ExecutionPolicy Bypass -Command "& '%[1]s'; $code = $LASTEXITCODE; `+
+		`Remove-Item -LiteralPath '%[1]s' -Force -ErrorAction SilentlyContinue; exit $code"`, path), nil
+}
+
+// encodeCommand encodes script for the -EncodedCommand flag of
+// powershell.exe: base64 of UTF-16LE.
+func encodeCommand(script string) string {
+	codes := utf16.Encode([]rune(script))
+	b := make([]byte, 0, 2*len(codes))
+	for _, c := range codes {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// elevatedRunner is the guestexec.ElevatedProvisioner generating the
+// elevated runners of commands.
+type elevatedRunner struct {
+	comm   packersdk.Communicator
+	config *Config
+}
+
+func (r *elevatedRunner) Communicator() packersdk.Communicator {
+	return r.comm
+}
+
+func (r *elevatedRunner) ElevatedUser() string {
+	return r.config.ElevatedUser
+}
+
+func (r *elevatedRunner) ElevatedPassword() string {
+	return r.config.ElevatedPassword
+}
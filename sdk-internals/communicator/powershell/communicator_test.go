@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func decodeCommand(t *testing.T, command string) string {
+	const flag = "-EncodedCommand "
+	i := strings.Index(command, flag)
+	if i < 0 {
+		t.Fatalf("not an encoded command: %s", command)
+	}
+	b, err := base64.StdEncoding.DecodeString(command[i+len(flag):])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	codes := make([]uint16, len(b)/2)
+	for i := range codes {
+		codes[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+	}
+	return string(utf16.Decode(codes))
+}
+
+func TestCommunicator_Start(t *testing.T) {
+	mock := &packersdk.MockCommunicator{StartStdout: "héllo", StartExitStatus: 3}
+	comm := New(mock, &Config{})
+
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{Command: "Write-Output 'héllo'; exit 3", Stdout: &stdout}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if status := cmd.Wait(); status != 3 {
+		t.Fatalf("bad exit status: %d", status)
+	}
+
+	script := decodeCommand(t, mock.StartCmd.Command)
+	if !strings.Contains(script, "\nWrite-Output 'héllo'; exit 3\n") {
+		t.Fatalf("the command is missing from the script: %s", script)
+	}
+	if !strings.Contains(script, "[Console]::OutputEncoding = [System.Text.Encoding]::UTF8") {
+		t.Fatalf("the output should be UTF-8: %s", script)
+	}
+	if mock.UploadCalled {
+		t.Fatal("short commands should not be uploaded")
+	}
+}
+
+func TestCommunicator_Start_long(t *testing.T) {
+	mock := new(packersdk.MockCommunicator)
+	comm := New(mock, &Config{})
+
+	cmd := &packersdk.RemoteCmd{Command: "Write-Output '" + strings.Repeat("x", maxCommandLength) + "'"}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+
+	if !mock.UploadCalled || !strings.HasPrefix(mock.UploadData, "\xef\xbb\xbf") || !strings.Contains(mock.UploadData, cmd.Command) {
+		t.Fatalf("the script should be uploaded, got %q", mock.UploadPath)
+	}
+	if !strings.Contains(mock.StartCmd.Command, "& '"+mock.UploadPath+"'") {
+		t.Fatalf("the uploaded script should be run, got %s", mock.StartCmd.Command)
+	}
+}
+
+func TestCommunicator_Start_elevated(t *testing.T) {
+	mock := new(packersdk.MockCommunicator)
+	comm := New(mock, &Config{ElevatedUser: "Administrator", ElevatedPassword: "secret"})
+
+	cmd := &packersdk.RemoteCmd{Command: "Install-WindowsFeature Web-Server"}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+
+	if !strings.Contains(mock.UploadData, "Administrator") || !strings.Contains(mock.UploadData, "-EncodedCommand") {
+		t.Fatalf("the elevated runner should run the command, got %s", mock.UploadData)
+	}
+	if !strings.Contains(mock.StartCmd.Command, `-file "`+mock.UploadPath+`"`) {
+		t.Fatalf("the elevated runner should be run, got %s", mock.StartCmd.Command)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// The file a chunked upload writes to until it is complete.
+	chunkedUploadPartSuffix = ".packer-part"
+	// The file recording how much of a chunked upload is complete, so that
+	// the upload can be resumed.
+	chunkedUploadStateSuffix = ".packer-upload"
+)
+
+// ChunkedUploadConfig configures the upload of files over sftp in chunks,
+// which can be uploaded in parallel and resumed after a failure.
+type ChunkedUploadConfig struct {
+	// ChunkSize is the size in bytes of the chunks files are uploaded in.
+	ChunkSize int64
+
+	// Parallelism is the number of chunks uploaded at the same time.
+	// Defaults to 1.
+	Parallelism int
+
+	// Resume, if true, continues an interrupted upload of a file where it
+	// stopped instead of starting over.
+	Resume bool
+
+	// VerifyChecksum, if true, reads uploaded files back to compare their
+	// SHA-256 checksum with the one of the source.
+	VerifyChecksum bool
+}
+
+// chunkedSource returns what a chunked upload of input needs, and false when
+// input can only be uploaded in one stream: when it cannot be read at random
+// offsets or its size is unknown.
+func chunkedSource(input io.Reader, fi *os.FileInfo) (io.ReaderAt, int64, bool) {
+	r, ok := input.(io.ReaderAt)
+	if !ok {
+		return nil, 0, false
+	}
+	if fi != nil && *fi != nil && (*fi).Mode().IsRegular() {
+		return r, (*fi).Size(), true
+	}
+	if f, ok := input.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			return r, info.Size(), true
+		}
+	}
+	return nil, 0, false
+}
+
+// chunkedUploadState is what is written in the state file of an upload.
+type chunkedUploadState struct {
+	size      int64
+	chunkSize int64
+	done      int64
+}
+
+func (s chunkedUploadState) String() string {
+	return fmt.Sprintf("%d %d %d\n", s.size, s.chunkSize, s.done)
+}
+
+// readChunkedUploadState returns the number of chunks of the upload of a
+// file of size bytes already written to the remote part file, or 0 when the
+// upload cannot be resumed.
+func readChunkedUploadState(client *sftp.Client, path string, size, chunkSize int64) int64 {
+	f, err := client.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	var s chunkedUploadState
+	if _, err := fmt.Fscanf(f, "%d %d %d", &s.size, &s.chunkSize, &s.done); err != nil {
+		log.Printf("[DEBUG] sftp: ignoring invalid upload state %s: %s", path, err)
+		return 0
+	}
+	if s.size != size || s.chunkSize != chunkSize {
+		log.Printf("[DEBUG] sftp: ignoring upload state %s of a different upload", path)
+		return 0
+	}
+	return s.done
+}
+
+func writeChunkedUploadState(client *sftp.Client, path string, s chunkedUploadState) error {
+	f, err := client.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.WriteString(f, s.String())
+	return err
+}
+
+// chunkTracker records which chunks of an upload are written, to know how
+// many leading chunks are complete.
+type chunkTracker struct {
+	l       sync.Mutex
+	done    int64
+	written map[int64]bool
+}
+
+// complete marks chunk i as written. When the number of leading complete
+// chunks changes, it is passed to advanced, which is called with t locked.
+func (t *chunkTracker) complete(i int64, advanced func(done int64) error) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+	t.written[i] = true
+	if !t.written[t.done] {
+		return nil
+	}
+	for t.written[t.done] {
+		delete(t.written, t.done)
+		t.done++
+	}
+	return advanced(t.done)
+}
+
+func (c *comm) sftpChunkedUploadFile(path string, input io.ReaderAt, size int64, client *sftp.Client) error {
+	config := c.config.ChunkedUpload
+	chunkSize := config.ChunkSize
+	chunks := (size + chunkSize - 1) / chunkSize
+	part := path + chunkedUploadPartSuffix
+	statePath := path + chunkedUploadStateSuffix
+
+	var start int64
+	if config.Resume {
+		start = readChunkedUploadState(client, statePath, size, chunkSize)
+		if start > chunks {
+			start = 0
+		}
+		if start > 0 {
+			log.Printf("[INFO] sftp: resuming the upload of %s after %d of %d chunks", path, start, chunks)
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if start == 0 {
+		flags |= os.O_TRUNC
+	}
+	f, err := client.OpenFile(part, flags)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	parallelism := config.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	log.Printf("[DEBUG] sftp: uploading %s in %d chunks of %d bytes, %d at a time", path, chunks-start, chunkSize, parallelism)
+
+	tracker := &chunkTracker{done: start, written: make(map[int64]bool)}
+	indexes := make(chan int64)
+	g := new(errgroup.Group)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	for w := 0; w < parallelism; w++ {
+		g.Go(func() error {
+			err := c.sftpUploadChunks(client, part, statePath, input, size, chunkSize, tracker, indexes)
+			if err != nil {
+				stopOnce.Do(func() { close(stop) })
+			}
+			return err
+		})
+	}
+	go func() {
+		defer close(indexes)
+		for i := start; i < chunks; i++ {
+			select {
+			case indexes <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	if err := g.Wait(); err != nil {
+		if !config.Resume {
+			client.Remove(part)
+		}
+		return fmt.Errorf("Error uploading %s: %s", path, err)
+	}
+
+	if config.VerifyChecksum {
+		if err := sftpVerifyChecksum(client, part, input, size); err != nil {
+			return fmt.Errorf("Error uploading %s: %s", path, err)
+		}
+	}
+
+	if err := client.PosixRename(part, path); err != nil {
+		// Plain renames fail when path exists.
+		client.Remove(path)
+		if err := client.Rename(part, path); err != nil {
+			return err
+		}
+	}
+	if config.Resume {
+		client.Remove(statePath)
+	}
+	return nil
+}
+
+// sftpUploadChunks writes the chunks sent on indexes to the remote file part.
+func (c *comm) sftpUploadChunks(client *sftp.Client, part, statePath string, input io.ReaderAt, size, chunkSize int64, tracker *chunkTracker, indexes <-chan int64) error {
+	f, err := client.OpenFile(part, os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for i := range indexes {
+		offset := i * chunkSize
+		n := chunkSize
+		if offset+n > size {
+			n = size - offset
+		}
+		w := io.NewOffsetWriter(f, offset)
+		if _, err := io.Copy(w, io.NewSectionReader(input, offset, n)); err != nil {
+			return fmt.Errorf("chunk %d: %s", i, err)
+		}
+
+		// Chunks complete out of order, so only the leading complete chunks
+		// can be trusted when resuming.
+		err := tracker.complete(i, func(done int64) error {
+			if !c.config.ChunkedUpload.Resume {
+				return nil
+			}
+			state := chunkedUploadState{size: size, chunkSize: chunkSize, done: done}
+			if err := writeChunkedUploadState(client, statePath, state); err != nil {
+				return fmt.Errorf("Error saving upload state: %s", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sftpVerifyChecksum reads path back to compare its checksum with the one of
+// the size bytes of input.
+func sftpVerifyChecksum(client *sftp.Client, path string, input io.ReaderAt, size int64) error {
+	expected := sha256.New()
+	if _, err := io.Copy(expected, io.NewSectionReader(input, 0, size)); err != nil {
+		return err
+	}
+
+	f, err := client.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	actual := sha256.New()
+	if _, err := io.Copy(actual, io.LimitReader(f, size+1)); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(expected.Sum(nil), actual.Sum(nil)) {
+		return fmt.Errorf("checksum mismatch: expected %x, got %x", expected.Sum(nil), actual.Sum(nil))
+	}
+	log.Printf("[DEBUG] sftp: checksum of %s verified: %x", strings.TrimSuffix(path, chunkedUploadPartSuffix), actual.Sum(nil))
+	return nil
+}
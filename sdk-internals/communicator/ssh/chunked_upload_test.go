@@ -0,0 +1,207 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !race
+
+package ssh
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// newMockSftpServer starts an SSH server serving the sftp subsystem over the
+// local filesystem.
+func newMockSftpServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen for connection: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveMockSftp(c)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveMockSftp(c net.Conn) {
+	defer c.Close()
+	_, chans, reqs, err := ssh.NewServerConn(c, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				go func() {
+					defer channel.Close()
+					server, err := sftp.NewServer(channel)
+					if err != nil {
+						return
+					}
+					server.Serve()
+				}()
+			}
+		}()
+	}
+}
+
+func newChunkedComm(t *testing.T, config *ChunkedUploadConfig) *comm {
+	address := newMockSftpServer(t)
+	c, err := New(address, &Config{
+		Connection: func() (net.Conn, error) { return net.Dial("tcp", address) },
+		SSHConfig: &ssh.ClientConfig{
+			User:            "user",
+			Auth:            []ssh.AuthMethod{ssh.Password("pass")},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		},
+		UseSftp:       true,
+		ChunkedUpload: config,
+	})
+	if err != nil {
+		t.Fatalf("error connecting to SSH: %s", err)
+	}
+	return c
+}
+
+// countingReaderAt counts the reads below offset limit.
+type countingReaderAt struct {
+	*bytes.Reader
+	limit int64
+	below int64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < r.limit {
+		atomic.AddInt64(&r.below, 1)
+	}
+	return r.Reader.ReadAt(p, off)
+}
+
+type fileInfo struct {
+	size int64
+}
+
+func (fi *fileInfo) Name() string       { return "src" }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return 0640 }
+func (fi *fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *fileInfo) IsDir() bool        { return false }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(42)).Read(data)
+	return data
+}
+
+func TestChunkedUpload(t *testing.T) {
+	c := newChunkedComm(t, &ChunkedUploadConfig{ChunkSize: 64 * 1024, Parallelism: 4, VerifyChecksum: true})
+	data := testData(1024*1024 + 123)
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.WriteFile(src, data, 0640); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := c.Upload(dst, f, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	uploaded, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(uploaded, data) {
+		t.Fatalf("bad upload of %d bytes", len(uploaded))
+	}
+	if _, err := os.Stat(dst + chunkedUploadPartSuffix); !os.IsNotExist(err) {
+		t.Fatalf("the part file should be gone: %v", err)
+	}
+}
+
+func TestChunkedUpload_resume(t *testing.T) {
+	const chunkSize = 16 * 1024
+	c := newChunkedComm(t, &ChunkedUploadConfig{ChunkSize: chunkSize, Parallelism: 2, Resume: true})
+	data := testData(10*chunkSize + 1)
+
+	// A previous upload stopped after 3 chunks, and some of the 4th.
+	dst := filepath.Join(t.TempDir(), "dst")
+	partial := append(append([]byte{}, data[:3*chunkSize]...), make([]byte, 100)...)
+	if err := os.WriteFile(dst+chunkedUploadPartSuffix, partial, 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state := chunkedUploadState{size: int64(len(data)), chunkSize: chunkSize, done: 3}
+	if err := os.WriteFile(dst+chunkedUploadStateSuffix, []byte(state.String()), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r := &countingReaderAt{Reader: bytes.NewReader(data), limit: 3 * chunkSize}
+	var fi os.FileInfo = &fileInfo{size: int64(len(data))}
+	if err := c.Upload(dst, r, &fi); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	uploaded, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(uploaded, data) {
+		t.Fatalf("bad upload of %d bytes", len(uploaded))
+	}
+	if r.below != 0 {
+		t.Fatalf("the complete chunks were uploaded again")
+	}
+	if _, err := os.Stat(dst + chunkedUploadStateSuffix); !os.IsNotExist(err) {
+		t.Fatalf("the state file should be gone: %v", err)
+	}
+}
+
+func TestChunkedUpload_stream(t *testing.T) {
+	c := newChunkedComm(t, &ChunkedUploadConfig{ChunkSize: 1024})
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	// Readers without random access are uploaded in one stream.
+	if err := c.Upload(dst, bytes.NewBufferString("hello"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	uploaded, err := os.ReadFile(dst)
+	if err != nil || string(uploaded) != "hello" {
+		t.Fatalf("bad upload: %q, %v", uploaded, err)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/text/encoding"
 )
 
 // ErrHandshakeTimeout is returned from New() whenever we're unable to establish
@@ -38,6 +39,11 @@ type comm struct {
 	config  *Config
 	conn    net.Conn
 	address string
+
+	// The encoding of the output of commands, nil for UTF-8.
+	outputEncoding encoding.Encoding
+
+	metrics metrics
 }
 
 // TunnelDirection is the supported tunnel directions
@@ -81,6 +87,10 @@ type Config struct {
 	// UseSftp, if true, sftp will be used instead of scp for file transfers
 	UseSftp bool
 
+	// ChunkedUpload, if set, uploads files over sftp in chunks. Files that
+	// cannot be read at random offsets are still uploaded in one stream.
+	ChunkedUpload *ChunkedUploadConfig
+
 	// KeepAliveInterval sets how often we send a channel request to the
 	// server. A value < 0 disables.
 	KeepAliveInterval time.Duration
@@ -89,6 +99,15 @@ type Config struct {
 	Timeout time.Duration
 
 	Tunnels []TunnelSpec
+
+	// OutputEncoding, if set, is the encoding of the output of commands,
+	// which is converted to UTF-8. See LookupEncoding.
+	OutputEncoding encoding.Encoding
+
+	// DetectOutputEncoding, if true, detects the encoding of the output of
+	// commands from the locale of the guest when connecting. OutputEncoding
+	// is used when it cannot be detected.
+	DetectOutputEncoding bool
 }
 
 // Creates a new packersdk.Communicator implementation over SSH. This takes
@@ -105,7 +124,17 @@ func New(address string, config *Config) (result *comm, err error) {
 		return
 	}
 
-	return
+	result.outputEncoding = config.OutputEncoding
+	if config.DetectOutputEncoding {
+		enc, err := result.detectOutputEncoding()
+		if err != nil {
+			log.Printf("[WARN] Could not detect the encoding of the output of commands: %s", err)
+		} else {
+			result.outputEncoding = enc
+		}
+	}
+
+	return result, nil
 }
 
 func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error) {
@@ -119,6 +148,20 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 	session.Stdout = cmd.Stdout
 	session.Stderr = cmd.Stderr
 
+	var outputs []io.Closer
+	if c.outputEncoding != nil {
+		if cmd.Stdout != nil {
+			w := transcode(cmd.Stdout, c.outputEncoding)
+			session.Stdout = w
+			outputs = append(outputs, w)
+		}
+		if cmd.Stderr != nil {
+			w := transcode(cmd.Stderr, c.outputEncoding)
+			session.Stderr = w
+			outputs = append(outputs, w)
+		}
+	}
+
 	if c.config.Pty {
 		// Request a PTY
 		termModes := ssh.TerminalModes{
@@ -171,29 +214,64 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 				log.Printf("[ERROR] Error occurred waiting for ssh session: %s", err.Error())
 			}
 		}
+		for _, w := range outputs {
+			w.Close()
+		}
 		cmd.SetExited(exitStatus)
 	}()
 	return
 }
 
 func (c *comm) Upload(path string, input io.Reader, fi *os.FileInfo) error {
+	return c.UploadContext(context.Background(), path, input, fi)
+}
+
+// UploadContext uploads input to path, closing the session of the upload
+// once ctx is done.
+func (c *comm) UploadContext(ctx context.Context, path string, input io.Reader, fi *os.FileInfo) (err error) {
+	input, uploaded := measureUpload(input, fi)
+	start := time.Now()
+	defer func() {
+		if err == nil {
+			c.recordUpload(uploaded(), time.Since(start))
+		}
+	}()
+
 	if c.config.UseSftp {
-		return c.sftpUploadSession(path, input, fi)
+		return c.sftpUploadSession(ctx, path, input, fi)
 	} else {
-		return c.scpUploadSession(path, input, fi)
+		return c.scpUploadSession(ctx, path, input, fi)
 	}
 }
 
 func (c *comm) UploadDir(dst string, src string, excl []string) error {
+	return c.UploadDirContext(context.Background(), dst, src, excl)
+}
+
+func (c *comm) UploadDirContext(ctx context.Context, dst string, src string, excl []string) error {
 	log.Printf("[DEBUG] Upload dir '%s' to '%s'", src, dst)
 	if c.config.UseSftp {
-		return c.sftpUploadDirSession(dst, src, excl)
+		return c.sftpUploadDirSession(ctx, dst, src, excl)
 	} else {
-		return c.scpUploadDirSession(dst, src, excl)
+		return c.scpUploadDirSession(ctx, dst, src, excl)
 	}
 }
 
+func (c *comm) Capabilities() (packersdk.CommunicatorCapabilities, bool) {
+	return packersdk.CommunicatorCapabilities{
+		DownloadDir: true,
+		PTY:         true,
+		UnixSockets: true,
+		Tunnels:     true,
+		Reboot:      true,
+	}, true
+}
+
 func (c *comm) DownloadDir(src string, dst string, excl []string) error {
+	return c.DownloadDirContext(context.Background(), src, dst, excl)
+}
+
+func (c *comm) DownloadDirContext(ctx context.Context, src string, dst string, excl []string) error {
 	log.Printf("[DEBUG] Download dir '%s' to '%s'", src, dst)
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
 		dirStack := []string{dst}
@@ -262,14 +340,18 @@ func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 			}
 		}
 	}
-	return c.scpSession("scp -vrf "+src, scpFunc)
+	return c.scpSession(ctx, "scp -vrf "+src, scpFunc)
 }
 
 func (c *comm) Download(path string, output io.Writer) error {
+	return c.DownloadContext(context.Background(), path, output)
+}
+
+func (c *comm) DownloadContext(ctx context.Context, path string, output io.Writer) error {
 	if c.config.UseSftp {
-		return c.sftpDownloadSession(path, output)
+		return c.sftpDownloadSession(ctx, path, output)
 	}
-	return c.scpDownloadSession(path, output)
+	return c.scpDownloadSession(ctx, path, output)
 }
 
 func (c *comm) newSession() (session *ssh.Session, err error) {
@@ -307,6 +389,7 @@ func (c *comm) reconnect() (err error) {
 	c.client = nil
 
 	log.Printf("[DEBUG] reconnecting to TCP connection for SSH")
+	start := time.Now()
 	c.conn, err = c.config.Connection()
 	if err != nil {
 		// Explicitly set this to the REAL nil. Connection() can return
@@ -325,6 +408,7 @@ func (c *comm) reconnect() (err error) {
 		c.conn = &timeoutConn{c.conn, c.config.Timeout, c.config.Timeout}
 	}
 
+	connected := time.Now()
 	log.Printf("[DEBUG] handshaking with SSH")
 
 	// Default timeout to 1 minute if it wasn't specified (zero value). For
@@ -365,6 +449,7 @@ func (c *comm) reconnect() (err error) {
 		return
 	}
 	log.Printf("[DEBUG] handshake complete!")
+	c.recordConnect(connected.Sub(start), time.Since(connected))
 	if sshConn != nil {
 		c.client = ssh.NewClient(sshConn, sshChan, req)
 	}
@@ -439,6 +524,49 @@ func shutdownProxyTunnel(sshConn ssh.Conn, done chan struct{}, listener net.List
 	listener.Close()
 }
 
+// Tunnel listens on local and forwards the connections to remote, dialed by
+// the SSH server.
+func (c *comm) Tunnel(ctx context.Context, local, remote string) (packersdk.Tunnel, error) {
+	if c.client == nil {
+		return nil, errors.New("Tunnel: not connected")
+	}
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", local)
+	if err != nil {
+		return nil, fmt.Errorf("Tunnel: Failed to bind local (%q): %s", local, err)
+	}
+	log.Printf("[INFO] Tunnel: Local bound on %s forwarding to %s", listener.Addr(), remote)
+	return c.serveTunnel(listener, func() (net.Conn, error) {
+		return c.client.Dial("tcp", remote)
+	}), nil
+}
+
+// ReverseTunnel asks the SSH server to listen on remote and forwards the
+// connections to local.
+func (c *comm) ReverseTunnel(ctx context.Context, remote, local string) (packersdk.Tunnel, error) {
+	if c.client == nil {
+		return nil, errors.New("Tunnel: not connected")
+	}
+	listener, err := c.client.Listen("tcp", remote)
+	if err != nil {
+		return nil, fmt.Errorf("Tunnel: Failed to bind remote (%q): %s", remote, err)
+	}
+	log.Printf("[INFO] Tunnel: Remote bound on %s forwarding to %s", listener.Addr(), local)
+	return c.serveTunnel(listener, ConnectFunc("tcp", local)), nil
+}
+
+// serveTunnel forwards the connections accepted by listener until the
+// returned tunnel is closed or the connection to the SSH server is lost.
+func (c *comm) serveTunnel(listener net.Listener, dialer func() (net.Conn, error)) *listenerTunnel {
+	t := newListenerTunnel(listener, dialer)
+	client := c.client
+	go func() {
+		client.Wait()
+		t.Close()
+	}()
+	return t
+}
+
 func (c *comm) connectToAgent() {
 	if c.client == nil {
 		return
@@ -491,16 +619,26 @@ func (c *comm) connectToAgent() {
 	log.Printf("[INFO] agent forwarding enabled")
 }
 
-func (c *comm) sftpUploadSession(path string, input io.Reader, fi *os.FileInfo) error {
+func (c *comm) sftpUploadSession(ctx context.Context, path string, input io.Reader, fi *os.FileInfo) error {
 	sftpFunc := func(client *sftp.Client) error {
 		return c.sftpUploadFile(path, input, client, fi)
 	}
 
-	return c.sftpSession(sftpFunc)
+	return c.sftpSession(ctx, sftpFunc)
 }
 
 func (c *comm) sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.FileInfo) error {
 	log.Printf("[DEBUG] sftp: uploading %s", path)
+	if c.config.ChunkedUpload != nil {
+		if r, size, ok := chunkedSource(input, fi); ok {
+			if err := c.sftpChunkedUploadFile(path, r, size, client); err != nil {
+				return err
+			}
+			return sftpChmod(path, client, fi)
+		}
+		log.Printf("[DEBUG] sftp: the source of %s cannot be uploaded in chunks", path)
+	}
+
 	f, err := client.Create(path)
 	if err != nil {
 		return err
@@ -511,18 +649,21 @@ func (c *comm) sftpUploadFile(path string, input io.Reader, client *sftp.Client,
 		return err
 	}
 
+	return sftpChmod(path, client, fi)
+}
+
+// sftpChmod gives the uploaded file at path the mode of fi.
+func sftpChmod(path string, client *sftp.Client, fi *os.FileInfo) error {
 	if fi != nil && (*fi).Mode().IsRegular() {
 		mode := (*fi).Mode().Perm()
-		err = client.Chmod(path, mode)
-		if err != nil {
+		if err := client.Chmod(path, mode); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
-func (c *comm) sftpUploadDirSession(dst string, src string, excl []string) error {
+func (c *comm) sftpUploadDirSession(ctx context.Context, dst string, src string, excl []string) error {
 	sftpFunc := func(client *sftp.Client) error {
 		rootDst := dst
 		if src[len(src)-1] != '/' {
@@ -561,7 +702,7 @@ func (c *comm) sftpUploadDirSession(dst string, src string, excl []string) error
 		return filepath.Walk(src, walkFunc)
 	}
 
-	return c.sftpSession(sftpFunc)
+	return c.sftpSession(ctx, sftpFunc)
 }
 
 func (c *comm) sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error {
@@ -596,7 +737,7 @@ func (c *comm) sftpVisitFile(dst string, src string, fi os.FileInfo, client *sft
 	}
 }
 
-func (c *comm) sftpDownloadSession(path string, output io.Writer) error {
+func (c *comm) sftpDownloadSession(ctx context.Context, path string, output io.Writer) error {
 	sftpFunc := func(client *sftp.Client) error {
 		f, err := client.Open(path)
 		if err != nil {
@@ -611,19 +752,34 @@ func (c *comm) sftpDownloadSession(path string, output io.Writer) error {
 		return nil
 	}
 
-	return c.sftpSession(sftpFunc)
+	return c.sftpSession(ctx, sftpFunc)
 }
 
-func (c *comm) sftpSession(f func(*sftp.Client) error) error {
+func (c *comm) sftpSession(ctx context.Context, f func(*sftp.Client) error) (err error) {
 	client, err := c.newSftpClient()
 	if err != nil {
 		return fmt.Errorf("sftpSession error: %s", err.Error())
 	}
 	defer client.Close()
+	defer cancelOnDone(ctx, client, &err)()
 
 	return f(client)
 }
 
+// cancelOnDone closes c once ctx is done, to stop the transfer using it. The
+// returned function stops waiting for ctx and, if c was closed, sets *err to
+// ctx.Err().
+func cancelOnDone(ctx context.Context, c io.Closer, err *error) func() {
+	stop := context.AfterFunc(ctx, func() {
+		c.Close()
+	})
+	return func() {
+		if !stop() {
+			*err = ctx.Err()
+		}
+	}
+}
+
 func (c *comm) newSftpClient() (*sftp.Client, error) {
 	session, err := c.newSession()
 	if err != nil {
@@ -654,7 +810,7 @@ func (c *comm) newSftpClient() (*sftp.Client, error) {
 	return client, err
 }
 
-func (c *comm) scpUploadSession(path string, input io.Reader, fi *os.FileInfo) error {
+func (c *comm) scpUploadSession(ctx context.Context, path string, input io.Reader, fi *os.FileInfo) error {
 
 	// The target directory and file for talking the SCP protocol
 	target_dir := filepath.Dir(path)
@@ -672,10 +828,10 @@ func (c *comm) scpUploadSession(path string, input io.Reader, fi *os.FileInfo) e
 		return scpUploadFile(target_file, input, w, stdoutR, fi)
 	}
 
-	return c.scpSession("scp -vt "+target_dir, scpFunc)
+	return c.scpSession(ctx, "scp -vt "+target_dir, scpFunc)
 }
 
-func (c *comm) scpUploadDirSession(dst string, src string, excl []string) error {
+func (c *comm) scpUploadDirSession(ctx context.Context, dst string, src string, excl []string) error {
 	scpFunc := func(w io.Writer, r *bufio.Reader) error {
 		uploadEntries := func() error {
 			f, err := os.Open(src)
@@ -706,10 +862,10 @@ func (c *comm) scpUploadDirSession(dst string, src string, excl []string) error
 		}
 	}
 
-	return c.scpSession("scp -rvt "+dst, scpFunc)
+	return c.scpSession(ctx, "scp -rvt "+dst, scpFunc)
 }
 
-func (c *comm) scpDownloadSession(path string, output io.Writer) error {
+func (c *comm) scpDownloadSession(ctx context.Context, path string, output io.Writer) error {
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
 		fmt.Fprint(w, "\x00")
 
@@ -756,17 +912,18 @@ func (c *comm) scpDownloadSession(path string, output io.Writer) error {
 	}
 
 	if !strings.Contains(path, " ") {
-		return c.scpSession("scp -vf "+path, scpFunc)
+		return c.scpSession(ctx, "scp -vf "+path, scpFunc)
 	}
-	return c.scpSession("scp -vf "+strconv.Quote(path), scpFunc)
+	return c.scpSession(ctx, "scp -vf "+strconv.Quote(path), scpFunc)
 }
 
-func (c *comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) error) error {
+func (c *comm) scpSession(ctx context.Context, scpCommand string, f func(io.Writer, *bufio.Reader) error) (err error) {
 	session, err := c.newSession()
 	if err != nil {
 		return err
 	}
 	defer session.Close()
+	defer cancelOnDone(ctx, session, &err)()
 
 	// Get a pipe to stdin so that we can send data down
 	stdinW, err := session.StdinPipe()
@@ -8,8 +8,10 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -127,6 +129,9 @@ func TestCommIsCommunicator(t *testing.T) {
 	if _, ok := raw.(packersdk.Communicator); !ok {
 		t.Fatalf("comm must be a communicator")
 	}
+	if _, ok := raw.(packersdk.CommunicatorWithContext); !ok {
+		t.Fatalf("comm must be a communicator with context")
+	}
 }
 
 func TestNew_Invalid(t *testing.T) {
@@ -229,3 +234,49 @@ func TestHandshakeTimeout(t *testing.T) {
 		t.Fatalf("Expected handshake timeout, got: %s", err)
 	}
 }
+
+// endlessReader cancels the transfer reading it on its first read, and never
+// ends.
+type endlessReader struct {
+	cancel context.CancelFunc
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	r.cancel()
+	for i := range p {
+		p[i] = 'x'
+	}
+	return len(p), nil
+}
+
+func TestUploadContext_cancel(t *testing.T) {
+	c := newChunkedComm(t, nil)
+	dst := filepath.Join(t.TempDir(), "dst")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UploadContext(ctx, dst, &endlessReader{cancel: cancel}, nil)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the upload to be cancelled, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the upload was not cancelled")
+	}
+
+	// The communicator still works after a cancelled transfer.
+	if err := c.Upload(dst, bytes.NewBufferString("hello"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var b bytes.Buffer
+	if err := c.DownloadContext(context.Background(), dst, &b); err != nil || b.String() != "hello" {
+		t.Fatalf("bad download: %q, %v", b.String(), err)
+	}
+	if err := c.DownloadContext(ctx, dst, new(bytes.Buffer)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the download to be cancelled, got %v", err)
+	}
+}
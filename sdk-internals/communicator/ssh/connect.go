@@ -4,13 +4,19 @@
 package ssh
 
 import (
+	"bufio"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/fips"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/net/proxy"
+	"golang.org/x/net/websocket"
 )
 
 // ConnectFunc is a convenience method for returning a function
@@ -51,6 +57,110 @@ func ProxyConnectFunc(socksProxy string, socksAuth *proxy.Auth, network, addr st
 	}
 }
 
+// HTTPConnectFunc is a convenience method for returning a function that
+// connects to addr through the HTTP CONNECT method of the http or https
+// endpoint. If token is set, it is sent as a bearer token in the
+// Proxy-Authorization header.
+func HTTPConnectFunc(endpoint *url.URL, token string, addr string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		c, err := dialEndpoint(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("Can't connect to the tunnel endpoint: %s", err)
+		}
+
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: make(http.Header),
+		}
+		if token != "" {
+			req.Header.Set("Proxy-Authorization", "Bearer "+token)
+		}
+		if err := req.Write(c); err != nil {
+			c.Close()
+			return nil, err
+		}
+
+		br := bufio.NewReader(c)
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("Error reading HTTP CONNECT response: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			c.Close()
+			return nil, fmt.Errorf("HTTP CONNECT to %s through %s failed: %s", addr, endpoint.Host, resp.Status)
+		}
+
+		// The SSH server speaks first, so part of its banner may already be
+		// buffered in br.
+		return &bufferedConn{Conn: c, r: br}, nil
+	}
+}
+
+// WebSocketConnectFunc is a convenience method for returning a function that
+// opens a ws or wss connection to endpoint and uses it as the transport for
+// the SSH connection. The endpoint is expected to forward the stream to the
+// SSH server. If token is set, it is sent as a bearer token in the
+// Authorization header.
+func WebSocketConnectFunc(endpoint *url.URL, token string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		origin := &url.URL{Scheme: "http", Host: endpoint.Host}
+		if endpoint.Scheme == "wss" {
+			origin.Scheme = "https"
+		}
+		config, err := websocket.NewConfig(endpoint.String(), origin.String())
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			config.Header.Set("Authorization", "Bearer "+token)
+		}
+		if endpoint.Scheme == "wss" {
+			config.TlsConfig = &tls.Config{ServerName: endpoint.Hostname()}
+			fips.ApplyTLS(config.TlsConfig)
+		}
+		config.Dialer = &net.Dialer{Timeout: 15 * time.Second, KeepAlive: 5 * time.Second}
+
+		ws, err := websocket.DialConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("Can't connect to the tunnel endpoint: %s", err)
+		}
+		ws.PayloadType = websocket.BinaryFrame
+		return ws, nil
+	}
+}
+
+func dialEndpoint(endpoint *url.URL) (net.Conn, error) {
+	addr := endpoint.Host
+	if endpoint.Port() == "" {
+		port := "80"
+		if endpoint.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(endpoint.Hostname(), port)
+	}
+
+	dialer := &net.Dialer{Timeout: 15 * time.Second, KeepAlive: 5 * time.Second}
+	if endpoint.Scheme != "https" {
+		return dialer.Dial("tcp", addr)
+	}
+	tlsConfig := &tls.Config{ServerName: endpoint.Hostname()}
+	fips.ApplyTLS(tlsConfig)
+	return tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
 // BastionConnectFunc is a convenience method for returning a function
 // that connects to a host over a bastion connection.
 func BastionConnectFunc(
@@ -84,6 +194,77 @@ func BastionConnectFunc(
 	}
 }
 
+// BastionHop is one of the bastion hosts of BastionChainConnectFunc.
+type BastionHop struct {
+	Proto  string
+	Addr   string
+	Config *ssh.ClientConfig
+}
+
+// BastionChainConnectFunc is like BastionConnectFunc, but goes through each
+// of hops in order, every hop being connected to through the previous one.
+func BastionChainConnectFunc(hops []BastionHop, proto string, addr string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		bastions := make([]*ssh.Client, 0, len(hops))
+		closeBastions := func() {
+			for i := len(bastions) - 1; i >= 0; i-- {
+				bastions[i].Close()
+			}
+		}
+
+		for i, hop := range hops {
+			var bastion *ssh.Client
+			var err error
+			if i == 0 {
+				bastion, err = ssh.Dial(hop.Proto, hop.Addr, hop.Config)
+			} else {
+				bastion, err = dialThrough(bastions[i-1], hop)
+			}
+			if err != nil {
+				closeBastions()
+				return nil, fmt.Errorf("Error connecting to bastion %s: %s", hop.Addr, err)
+			}
+			log.Printf("[DEBUG] connected to bastion host %s", hop.Addr)
+			bastions = append(bastions, bastion)
+		}
+
+		log.Println("[DEBUG] attempting connection to destination host")
+		conn, err := bastions[len(bastions)-1].Dial(proto, addr)
+		if err != nil {
+			closeBastions()
+			return nil, err
+		}
+		return &bastionChainConn{Conn: conn, bastions: bastions}, nil
+	}
+}
+
+// dialThrough opens an SSH connection to hop through bastion.
+func dialThrough(bastion *ssh.Client, hop BastionHop) (*ssh.Client, error) {
+	conn, err := bastion.Dial(hop.Proto, hop.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, hop.Addr, hop.Config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+type bastionChainConn struct {
+	net.Conn
+	bastions []*ssh.Client
+}
+
+func (c *bastionChainConn) Close() error {
+	err := c.Conn.Close()
+	for i := len(c.bastions) - 1; i >= 0; i-- {
+		c.bastions[i].Close()
+	}
+	return err
+}
+
 type bastionConn struct {
 	net.Conn
 	Bastion *ssh.Client
@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+const testBanner = "SSH-2.0-Test\r\n"
+
+func TestHTTPConnectFunc(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+
+	reqCh := make(chan *http.Request, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		req, err := http.ReadRequest(bufio.NewReader(c))
+		if err != nil {
+			return
+		}
+		reqCh <- req
+		// Send the banner in the same write as the response to make sure
+		// bytes buffered while reading the response are not lost.
+		io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n"+testBanner)
+	}()
+
+	endpoint := &url.URL{Scheme: "http", Host: l.Addr().String()}
+	conn, err := HTTPConnectFunc(endpoint, "s3cr3t", "10.0.0.1:22")()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+
+	req := <-reqCh
+	if req.Method != http.MethodConnect || req.Host != "10.0.0.1:22" {
+		t.Fatalf("unexpected request: %s %s", req.Method, req.Host)
+	}
+	if auth := req.Header.Get("Proxy-Authorization"); auth != "Bearer s3cr3t" {
+		t.Fatalf("unexpected Proxy-Authorization header: %q", auth)
+	}
+
+	banner, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if banner != testBanner {
+		t.Fatalf("unexpected banner: %q", banner)
+	}
+}
+
+func TestHTTPConnectFunc_refused(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	}))
+	defer ts.Close()
+
+	endpoint, _ := url.Parse(ts.URL)
+	_, err := HTTPConnectFunc(endpoint, "", "10.0.0.1:22")()
+	if err == nil || !strings.Contains(err.Error(), "407") {
+		t.Fatalf("expected a 407 error, got: %v", err)
+	}
+}
+
+func TestWebSocketConnectFunc(t *testing.T) {
+	authCh := make(chan string, 1)
+	ts := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		authCh <- ws.Request().Header.Get("Authorization")
+		ws.PayloadType = websocket.BinaryFrame
+		io.Copy(ws, ws)
+	}))
+	defer ts.Close()
+
+	endpoint, _ := url.Parse(strings.Replace(ts.URL, "http://", "ws://", 1))
+	conn, err := WebSocketConnectFunc(endpoint, "s3cr3t")()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+
+	if auth := <-authCh; auth != "Bearer s3cr3t" {
+		t.Fatalf("unexpected Authorization header: %q", auth)
+	}
+
+	if _, err := io.WriteString(conn, testBanner); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	buf := make([]byte, len(testBanner))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(buf) != testBanner {
+		t.Fatalf("unexpected echo: %q", buf)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// AutoOutputEncoding is the name of the encoding detected from the locale of
+// the guest, see LookupEncoding.
+const AutoOutputEncoding = "auto"
+
+// windowsCodePages are the encodings of the Windows code pages, as printed
+// by chcp.
+var windowsCodePages = map[int]encoding.Encoding{
+	437:  charmap.CodePage437,
+	850:  charmap.CodePage850,
+	852:  charmap.CodePage852,
+	855:  charmap.CodePage855,
+	858:  charmap.CodePage858,
+	860:  charmap.CodePage860,
+	862:  charmap.CodePage862,
+	863:  charmap.CodePage863,
+	865:  charmap.CodePage865,
+	866:  charmap.CodePage866,
+	874:  charmap.Windows874,
+	932:  japanese.ShiftJIS,
+	936:  simplifiedchinese.GBK,
+	949:  korean.EUCKR,
+	950:  traditionalchinese.Big5,
+	1250: charmap.Windows1250,
+	1251: charmap.Windows1251,
+	1252: charmap.Windows1252,
+	1253: charmap.Windows1253,
+	1254: charmap.Windows1254,
+	1255: charmap.Windows1255,
+	1256: charmap.Windows1256,
+	1257: charmap.Windows1257,
+	1258: charmap.Windows1258,
+	// UTF-8
+	65001: nil,
+}
+
+// LookupEncoding returns the encoding of name, which is either an IANA
+// name, like `ISO-8859-1` or `Shift_JIS`, or a Windows code page, like
+// `cp850` or `850`. The encoding is nil for UTF-8 and ASCII, whose output
+// needs no conversion.
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	switch n {
+	case "utf-8", "utf8", "us-ascii", "ascii", "ansi_x3.4-1968":
+		return nil, nil
+	}
+
+	if cp, err := strconv.Atoi(strings.TrimPrefix(n, "cp")); err == nil {
+		enc, ok := windowsCodePages[cp]
+		if !ok {
+			return nil, fmt.Errorf("unsupported code page %d", cp)
+		}
+		return enc, nil
+	}
+
+	enc, err := ianaindex.IANA.Encoding(n)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+	return enc, nil
+}
+
+// codePageRe matches the code page at the end of the output of chcp, which
+// is localized, like "Active code page: 850".
+var codePageRe = regexp.MustCompile(`(\d+)\s*$`)
+
+// detectOutputEncoding returns the encoding of the output of the commands run
+// on the guest: the charmap of the locale on Unix, the active code page on
+// Windows.
+func (c *comm) detectOutputEncoding() (encoding.Encoding, error) {
+	if out, err := c.output("locale charmap"); err == nil {
+		name := strings.TrimSpace(out)
+		log.Printf("[DEBUG] guest locale charmap: %s", name)
+		return LookupEncoding(name)
+	}
+
+	out, err := c.output("chcp")
+	if err != nil {
+		return nil, fmt.Errorf("could not find the locale charmap or code page of the guest: %s", err)
+	}
+	m := codePageRe.FindStringSubmatch(strings.TrimSpace(out))
+	if m == nil {
+		return nil, fmt.Errorf("unexpected output of chcp: %q", out)
+	}
+	log.Printf("[DEBUG] guest code page: %s", m[1])
+	return LookupEncoding(m[1])
+}
+
+// output returns the standard output of command.
+func (c *comm) output(command string) (string, error) {
+	session, err := c.newSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	out, err := session.Output(command)
+	return string(out), err
+}
+
+// transcode returns a writer converting what is written to it from enc to
+// UTF-8 before writing it to w. The writer must be closed to write what is
+// left of incomplete characters.
+func transcode(w io.Writer, enc encoding.Encoding) io.WriteCloser {
+	return transform.NewWriter(w, enc.NewDecoder())
+}
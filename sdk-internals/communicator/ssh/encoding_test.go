@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestLookupEncoding(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected encoding.Encoding
+	}{
+		{"UTF-8", nil},
+		{"ANSI_X3.4-1968", nil},
+		{"65001", nil},
+		{"cp850", charmap.CodePage850},
+		{"1252", charmap.Windows1252},
+		{"ISO-8859-1", charmap.ISO8859_1},
+		{"shift_jis", japanese.ShiftJIS},
+	}
+	for _, tc := range cases {
+		enc, err := LookupEncoding(tc.name)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if enc != tc.expected {
+			t.Fatalf("%s: bad encoding %v", tc.name, enc)
+		}
+	}
+
+	for _, name := range []string{"cp12345", "klingon"} {
+		if _, err := LookupEncoding(name); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	var out bytes.Buffer
+	w := transcode(&out, japanese.ShiftJIS)
+
+	// "日本" split in the middle of a character.
+	sjis := []byte{0x93, 0xfa, 0x96, 0x7b}
+	w.Write(sjis[:3])
+	w.Write(sjis[3:])
+	if err := w.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.String() != "日本" {
+		t.Fatalf("bad output: %q", out.String())
+	}
+
+	out.Reset()
+	w = transcode(&out, charmap.CodePage850)
+	w.Write([]byte("caf\x82\r\n"))
+	w.Close()
+	if out.String() != "café\r\n" {
+		t.Fatalf("bad output: %q", out.String())
+	}
+}
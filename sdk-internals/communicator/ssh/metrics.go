@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Metrics are measures of the network conditions of an SSH connection, to
+// tell the builds slowed down by the network from the others.
+type Metrics struct {
+	// ConnectTime is how long opening the connection took, bastion and
+	// proxy included, the last time the communicator connected.
+	ConnectTime time.Duration
+	// HandshakeTime is how long the SSH handshake and the authentication
+	// took, the last time the communicator connected.
+	HandshakeTime time.Duration
+	// Latency is the round trip time measured by the last Ping, 0 before
+	// the first one.
+	Latency time.Duration
+	// UploadedBytes is the size of the files uploaded successfully with
+	// Upload, and UploadTime the time these uploads took.
+	UploadedBytes int64
+	UploadTime    time.Duration
+}
+
+// UploadThroughput returns the average throughput of the uploads in bytes
+// per second, 0 when nothing was uploaded.
+func (m Metrics) UploadThroughput() float64 {
+	if m.UploadTime <= 0 {
+		return 0
+	}
+	return float64(m.UploadedBytes) / m.UploadTime.Seconds()
+}
+
+// A MetricsCommunicator measures the network conditions of its connection.
+// The communicators returned by New implement it.
+type MetricsCommunicator interface {
+	// Ping measures the round trip time of the connection, which the
+	// next Metrics report as their Latency.
+	Ping(ctx context.Context) (time.Duration, error)
+	// Metrics returns the measures made so far.
+	Metrics() Metrics
+}
+
+var _ MetricsCommunicator = new(comm)
+
+// metrics are the Metrics of a comm, updated by concurrent uploads.
+type metrics struct {
+	sync.Mutex
+	m Metrics
+}
+
+// Ping sends a keepalive request and waits for the reply. Servers reply to
+// requests they don't know with a failure, so it measures the round trip
+// time as well.
+func (c *comm) Ping(ctx context.Context) (time.Duration, error) {
+	if c.client == nil {
+		return 0, errors.New("Ping: not connected")
+	}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return 0, err
+		}
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	rtt := time.Since(start)
+
+	c.metrics.Lock()
+	c.metrics.m.Latency = rtt
+	c.metrics.Unlock()
+	return rtt, nil
+}
+
+// Metrics returns the measures of the connection of c.
+func (c *comm) Metrics() Metrics {
+	c.metrics.Lock()
+	defer c.metrics.Unlock()
+	return c.metrics.m
+}
+
+func (c *comm) recordConnect(connect, handshake time.Duration) {
+	c.metrics.Lock()
+	defer c.metrics.Unlock()
+	c.metrics.m.ConnectTime = connect
+	c.metrics.m.HandshakeTime = handshake
+}
+
+func (c *comm) recordUpload(n int64, d time.Duration) {
+	c.metrics.Lock()
+	defer c.metrics.Unlock()
+	c.metrics.m.UploadedBytes += n
+	c.metrics.m.UploadTime += d
+}
+
+// measureUpload returns the input to upload in place of input, and a
+// function returning the number of bytes uploaded once it is done. The size
+// of the files is known up front; other inputs are counted as they are read.
+func measureUpload(input io.Reader, fi *os.FileInfo) (io.Reader, func() int64) {
+	if _, size, ok := chunkedSource(input, fi); ok {
+		return input, func() int64 { return size }
+	}
+	r := &countingReader{Reader: input}
+	return r, func() int64 { return r.n }
+}
+
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
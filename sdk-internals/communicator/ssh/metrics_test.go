@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !race
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComm_Metrics(t *testing.T) {
+	c := newChunkedComm(t, nil)
+	m := c.Metrics()
+	if m.ConnectTime <= 0 || m.HandshakeTime <= 0 {
+		t.Fatalf("the connection should be measured: %#v", m)
+	}
+	if m.Latency != 0 || m.UploadedBytes != 0 || m.UploadThroughput() != 0 {
+		t.Fatalf("nothing else should be measured yet: %#v", m)
+	}
+
+	rtt, err := c.Ping(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if rtt <= 0 || c.Metrics().Latency != rtt {
+		t.Fatalf("bad latency %s: %#v", rtt, c.Metrics())
+	}
+
+	dir := t.TempDir()
+	// A reader of unknown size is counted as it is read.
+	if err := c.Upload(filepath.Join(dir, "a"), strings.NewReader("hello"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, bytes.Repeat([]byte("x"), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := c.Upload(filepath.Join(dir, "b"), f, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Failed uploads are not.
+	if err := c.Upload(filepath.Join(dir, "missing", "c"), strings.NewReader("lost"), nil); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	m = c.Metrics()
+	if m.UploadedBytes != 1005 {
+		t.Fatalf("expected 1005 bytes uploaded, got %d", m.UploadedBytes)
+	}
+	if m.UploadTime <= 0 || m.UploadThroughput() <= 0 {
+		t.Fatalf("the uploads should be timed: %#v", m)
+	}
+}
+
+func TestMetrics_UploadThroughput(t *testing.T) {
+	m := Metrics{UploadedBytes: 3000, UploadTime: 2 * time.Second}
+	if m.UploadThroughput() != 1500 {
+		t.Fatalf("bad throughput: %f", m.UploadThroughput())
+	}
+}
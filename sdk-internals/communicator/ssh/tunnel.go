@@ -7,8 +7,36 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync"
 )
 
+// listenerTunnel is a packersdk.Tunnel forwarding the connections accepted by
+// a listener with ProxyServe.
+type listenerTunnel struct {
+	listener net.Listener
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newListenerTunnel(l net.Listener, dialer func() (net.Conn, error)) *listenerTunnel {
+	t := &listenerTunnel{listener: l, done: make(chan struct{})}
+	go ProxyServe(l, t.done, dialer)
+	return t
+}
+
+func (t *listenerTunnel) Addr() string {
+	return t.listener.Addr().String()
+}
+
+func (t *listenerTunnel) Close() error {
+	var err error
+	t.once.Do(func() {
+		close(t.done)
+		err = t.listener.Close()
+	})
+	return err
+}
+
 // ProxyServe starts Accepting connections
 func ProxyServe(l net.Listener, done <-chan struct{}, dialer func() (net.Conn, error)) {
 	for {
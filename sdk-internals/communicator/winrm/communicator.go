@@ -18,6 +18,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/hashicorp/packer-plugin-sdk/iohelper"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/masterzen/winrm"
 	"github.com/packer-community/winrmcp/winrmcp"
@@ -28,6 +29,11 @@ type Communicator struct {
 	config   *Config
 	client   *winrm.Client
 	endpoint *winrm.Endpoint
+
+	// runspaceLock guards runspace, the PowerShell process running the
+	// commands when Config.PersistentRunspace is set.
+	runspaceLock sync.Mutex
+	runspace     *runspace
 }
 
 // New creates a new communicator implementation over WinRM.
@@ -82,6 +88,14 @@ func New(config *Config) (*Communicator, error) {
 
 // Start implementation of communicator.Communicator interface
 func (c *Communicator) Start(ctx context.Context, rc *packersdk.RemoteCmd) error {
+	if c.config.PersistentRunspace && rc.Stdin == nil {
+		err := c.startInRunspace(rc)
+		if err == nil {
+			return nil
+		}
+		log.Printf("[WARN] could not run the command in the PowerShell runspace, starting a shell: %s", err)
+	}
+
 	shell, err := c.client.CreateShell()
 	if err != nil {
 		return err
@@ -114,8 +128,15 @@ func runCommand(shell *winrm.Shell, cmd *winrm.Command, rc *packersdk.RemoteCmd)
 	}
 
 	if rc.Stderr != nil && cmd.Stderr != nil {
+		// The errors of commands are logged, for the logs to tell why a
+		// provisioner failed.
+		stderr := iohelper.TeeToLog(rc.Stderr, "[DEBUG] stderr: ")
 		wg.Add(1)
-		go copyFunc(rc.Stderr, cmd.Stderr)
+		go func() {
+			defer wg.Done()
+			io.Copy(stderr, cmd.Stderr)
+			stderr.Close()
+		}()
 	} else {
 		log.Printf("[WARN] Failed to read stderr for command '%s'", rc.Command)
 	}
@@ -128,8 +149,54 @@ func runCommand(shell *winrm.Shell, cmd *winrm.Command, rc *packersdk.RemoteCmd)
 	rc.SetExited(code)
 }
 
+// startInRunspace starts the command of rc in the runspace, starting one if
+// there is none or the last one exited.
+func (c *Communicator) startInRunspace(rc *packersdk.RemoteCmd) error {
+	c.runspaceLock.Lock()
+	if c.runspace == nil || !c.runspace.alive() {
+		log.Printf("[DEBUG] starting a PowerShell runspace")
+		r, err := startRunspace(c.client)
+		if err != nil {
+			c.runspaceLock.Unlock()
+			return err
+		}
+		c.runspace = r
+	}
+	r := c.runspace
+	c.runspaceLock.Unlock()
+
+	log.Printf("[INFO] starting remote command in the runspace: %s", rc.Command)
+	if err := r.start(rc); err != nil {
+		return err
+	}
+	go func() {
+		code := r.wait()
+		log.Printf("[INFO] command '%s' exited with code: %d", rc.Command, code)
+		rc.SetExited(code)
+	}()
+	return nil
+}
+
+// Close stops the PowerShell runspace, if any.
+func (c *Communicator) Close() error {
+	c.runspaceLock.Lock()
+	defer c.runspaceLock.Unlock()
+	if c.runspace == nil {
+		return nil
+	}
+	err := c.runspace.close()
+	c.runspace = nil
+	return err
+}
+
 // Upload implementation of communicator.Communicator interface
 func (c *Communicator) Upload(path string, input io.Reader, fi *os.FileInfo) error {
+	return c.UploadContext(context.Background(), path, input, fi)
+}
+
+// UploadContext uploads input to path, stopping at the next chunk read from
+// input once ctx is done.
+func (c *Communicator) UploadContext(ctx context.Context, path string, input io.Reader, fi *os.FileInfo) error {
 	wcp, err := c.newCopyClient()
 	if err != nil {
 		return fmt.Errorf("Was unable to create winrm client: %s", err)
@@ -143,11 +210,23 @@ func (c *Communicator) Upload(path string, input io.Reader, fi *os.FileInfo) err
 		}
 	}
 	log.Printf("Uploading file to '%s'", path)
-	return wcp.Write(path, input)
+	if err := wcp.Write(path, iohelper.NewContextReader(ctx, input)); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
 }
 
 // UploadDir implementation of communicator.Communicator interface
 func (c *Communicator) UploadDir(dst string, src string, exclude []string) error {
+	return c.UploadDirContext(context.Background(), dst, src, exclude)
+}
+
+// UploadDirContext uploads the files of src to dst one by one, like
+// winrmcp, stopping once ctx is done.
+func (c *Communicator) UploadDirContext(ctx context.Context, dst string, src string, exclude []string) error {
 	if !strings.HasSuffix(src, "/") {
 		dst = fmt.Sprintf("%s\\%s", dst, filepath.Base(src))
 	}
@@ -156,26 +235,126 @@ func (c *Communicator) UploadDir(dst string, src string, exclude []string) error
 	if err != nil {
 		return err
 	}
-	return wcp.Copy(src, dst)
+	root, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Directories are created with the files they hold, and the hidden
+		// files of OS X are skipped.
+		if info.IsDir() || info.Name() == ".DS_Store" {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Couldn't read file %s: %v", path, err)
+		}
+		defer f.Close()
+		if err := wcp.Write(filepath.Join(dst, rel), iohelper.NewContextReader(ctx, f)); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		return nil
+	})
 }
 
 func (c *Communicator) Download(src string, dst io.Writer) error {
+	return c.DownloadContext(context.Background(), src, dst)
+}
+
+// DownloadContext downloads src to dst, terminating the command reading src
+// once ctx is done. Nothing is written to dst after it returns.
+func (c *Communicator) DownloadContext(ctx context.Context, src string, dst io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	client, err := c.newWinRMClient()
 	if err != nil {
 		return err
 	}
+	shell, err := client.CreateShell()
+	if err != nil {
+		return err
+	}
+	defer shell.Close()
 
 	encodeScript := `$file=[System.IO.File]::ReadAllBytes("%s"); Write-Output $([System.Convert]::ToBase64String($file))`
 
-	base64DecodePipe := &Base64Pipe{w: dst}
+	cmd, err := shell.Execute(winrm.Powershell(fmt.Sprintf(encodeScript, src)))
+	if err != nil {
+		return err
+	}
+	defer cmd.Close()
+
+	out := &stoppableWriter{w: dst}
+	defer out.stop()
+	var wg sync.WaitGroup
+	var copyErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// The errors of the command end its output.
+		_, copyErr = io.Copy(&Base64Pipe{w: out}, cmd.Stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(io.Discard, cmd.Stderr)
+	}()
+
+	// Terminating the command doesn't end its output, so the copies are
+	// only waited for when it exits by itself.
+	stop := context.AfterFunc(ctx, func() {
+		cmd.Close()
+	})
+	cmd.Wait()
+	if !stop() {
+		return ctx.Err()
+	}
+	wg.Wait()
+	return copyErr
+}
+
+// stoppableWriter writes to w until it is stopped.
+type stoppableWriter struct {
+	lock    sync.Mutex
+	w       io.Writer
+	stopped bool
+}
 
-	cmd := winrm.Powershell(fmt.Sprintf(encodeScript, src))
-	_, err = client.Run(cmd, base64DecodePipe, io.Discard)
+func (w *stoppableWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stopped {
+		return 0, io.ErrClosedPipe
+	}
+	return w.w.Write(p)
+}
 
-	return err
+func (w *stoppableWriter) stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.stopped = true
+}
+
+func (c *Communicator) Capabilities() (packersdk.CommunicatorCapabilities, bool) {
+	// Each command uses a new connection, which survives reboots.
+	return packersdk.CommunicatorCapabilities{Reboot: true}, true
 }
 
 func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	return c.DownloadDirContext(context.Background(), src, dst, exclude)
+}
+
+func (c *Communicator) DownloadDirContext(ctx context.Context, src string, dst string, exclude []string) error {
 	return fmt.Errorf("WinRM doesn't support download dir.")
 }
 
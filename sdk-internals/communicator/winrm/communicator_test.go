@@ -6,6 +6,7 @@ package winrm
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"strings"
 	"testing"
@@ -145,3 +146,51 @@ func TestUpload_nilFileInfo(t *testing.T) {
 		t.Fatalf("Should have errored because of nil fileinfo")
 	}
 }
+
+func TestSplitRelayAddr(t *testing.T) {
+	host, port, err := splitRelayAddr("localhost:8080")
+	if err != nil || host != "localhost" || port != 8080 {
+		t.Fatalf("unexpected result: %q %d %v", host, port, err)
+	}
+	for _, addr := range []string{"localhost", "localhost:http", "local'host:80", ":80"} {
+		if _, _, err := splitRelayAddr(addr); err == nil {
+			t.Fatalf("%q should be rejected", addr)
+		}
+	}
+}
+
+func TestReverseTunnel(t *testing.T) {
+	c := new(Communicator)
+	_, err := packersdk.OpenReverseTunnel(context.Background(), c, "0.0.0.0:8080", "127.0.0.1:80")
+	if !errors.Is(err, packersdk.ErrTunnelsNotSupported) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDownloadContext_cancel(t *testing.T) {
+	wrm := newMockWinRMServer(t)
+	defer wrm.Close()
+
+	c, err := New(&Config{
+		Host:     wrm.Host,
+		Port:     wrm.Port,
+		Username: "user",
+		Password: "pass",
+		Timeout:  30 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("error creating communicator: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.UploadContext(ctx, "C:/Temp/packer.cmd", strings.NewReader(PAYLOAD), nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the upload to be cancelled, got %v", err)
+	}
+	dest := new(bytes.Buffer)
+	if err := c.DownloadContext(ctx, "C:/Temp/packer.cmd", dest); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the download to be cancelled, got %v", err)
+	}
+	if dest.Len() != 0 {
+		t.Fatalf("nothing should be written once cancelled, got %q", dest.String())
+	}
+}
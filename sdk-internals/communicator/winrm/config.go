@@ -19,4 +19,12 @@ type Config struct {
 	Https              bool
 	Insecure           bool
 	TransportDecorator func() winrm.Transporter
+
+	// PersistentRunspace, if true, runs the commands in a PowerShell
+	// process kept running between commands instead of starting a shell
+	// and a process for each. Commands are then run as PowerShell script
+	// blocks, and the state they leave, like variables, is seen by the next
+	// ones. Commands reading their standard input are still run in a shell
+	// of their own.
+	PersistentRunspace bool
 }
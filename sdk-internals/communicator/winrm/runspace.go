@@ -0,0 +1,220 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+	"github.com/masterzen/winrm"
+)
+
+// runspaceCommand is the PowerShell process kept running in the shell of a
+// runspace. It runs the script blocks written to its standard input, one per
+// line.
+const runspaceCommand = "powershell.exe -NoProfile -NonInteractive -NoLogo -ExecutionPolicy Bypass -Command -"
+
+const runspacePrelude = "$ProgressPreference = 'SilentlyContinue'; " +
+	"[Console]::OutputEncoding = [System.Text.Encoding]::UTF8\r\n"
+
+// runspaceScript runs a base64 encoded script block in the global scope, so
+// that the variables, functions and modules it defines are kept for the next
+// ones, then writes the marker followed by its exit code on stdout, and the
+// marker alone on stderr.
+const runspaceScript = "$global:LASTEXITCODE = 0; " +
+	"try { . ([ScriptBlock]::Create([Text.Encoding]::UTF8.GetString([Convert]::FromBase64String('%[1]s')))); " +
+	"$__packer_ok = $?; $__packer_status = if ($LASTEXITCODE) { $LASTEXITCODE } elseif ($__packer_ok) { 0 } else { 1 } } " +
+	"catch { [Console]::Error.WriteLine(($_ | Out-String)); $__packer_status = 1 }; " +
+	"[Console]::Out.WriteLine('%[2]s' + $__packer_status); [Console]::Error.WriteLine('%[2]s')\r\n"
+
+// runspace is a PowerShell process running the commands of a communicator
+// one after the other, which saves starting a shell and a process for each
+// of them.
+type runspace struct {
+	// l is held from the start of a script block to its end.
+	l sync.Mutex
+
+	stdin  io.Writer
+	marker string
+	stdout targetWriter
+	stderr targetWriter
+
+	// status receives the exit code of each script block, and stderrDone
+	// the end of its error output.
+	status     chan int
+	stderrDone chan struct{}
+
+	// exited is closed when the process ends, after which exitCode is its
+	// exit code.
+	exited   chan struct{}
+	exitCode int
+
+	close func() error
+}
+
+// startRunspace starts a runspace process in a new shell of client.
+func startRunspace(client *winrm.Client) (*runspace, error) {
+	shell, err := client.CreateShell()
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := shell.Execute(runspaceCommand)
+	if err != nil {
+		shell.Close()
+		return nil, err
+	}
+
+	closeFunc := func() error {
+		cmd.Stdin.Close()
+		cmd.Close()
+		return shell.Close()
+	}
+	r := newRunspace(cmd.Stdin, cmd.Stdout, cmd.Stderr, func() int {
+		cmd.Wait()
+		return cmd.ExitCode()
+	}, closeFunc)
+	if _, err := io.WriteString(r.stdin, runspacePrelude); err != nil {
+		r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// newRunspace returns the runspace of the process with the given pipes. wait
+// blocks until the process ends and returns its exit code.
+func newRunspace(stdin io.Writer, stdout, stderr io.Reader, wait func() int, closeFunc func() error) *runspace {
+	r := &runspace{
+		stdin:      stdin,
+		marker:     fmt.Sprintf("__packer_end_%s_", uuid.TimeOrderedUUID()),
+		status:     make(chan int, 1),
+		stderrDone: make(chan struct{}, 1),
+		exited:     make(chan struct{}),
+		close:      closeFunc,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r.demux(stdout, &r.stdout, func(rest string) {
+			status, err := strconv.Atoi(strings.TrimSpace(rest))
+			if err != nil {
+				log.Printf("[WARN] runspace: unexpected exit status %q", rest)
+				status = packersdk.CmdDisconnect
+			}
+			r.status <- status
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		r.demux(stderr, &r.stderr, func(string) { r.stderrDone <- struct{}{} })
+	}()
+	go func() {
+		code := wait()
+		wg.Wait()
+		r.exitCode = code
+		close(r.exited)
+	}()
+	return r
+}
+
+// demux copies the lines of in to w, calling end with what follows the marker
+// when it finds one.
+func (r *runspace) demux(in io.Reader, w *targetWriter, end func(rest string)) {
+	br := bufio.NewReader(in)
+	for {
+		line, err := br.ReadString('\n')
+		if i := strings.Index(line, r.marker); i >= 0 {
+			w.Write([]byte(line[:i]))
+			end(line[i+len(r.marker):])
+		} else if line != "" {
+			w.Write([]byte(line))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// alive returns whether the process of r is still running.
+func (r *runspace) alive() bool {
+	select {
+	case <-r.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// start starts running the command of rc as a script block. Unless it
+// returns an error, wait must be called to get the exit code of the command.
+func (r *runspace) start(rc *packersdk.RemoteCmd) error {
+	r.l.Lock()
+	if !r.alive() {
+		r.l.Unlock()
+		return fmt.Errorf("the runspace exited with code %d", r.exitCode)
+	}
+
+	r.stdout.set(rc.Stdout)
+	r.stderr.set(rc.Stderr)
+	script := base64.StdEncoding.EncodeToString([]byte(rc.Command))
+	if _, err := fmt.Fprintf(r.stdin, runspaceScript, script, r.marker); err != nil {
+		r.stdout.set(nil)
+		r.stderr.set(nil)
+		r.l.Unlock()
+		return err
+	}
+	return nil
+}
+
+// wait returns the exit code of the command started last. When the command
+// ends the process, with exit for example, this is the exit code of the
+// process.
+func (r *runspace) wait() int {
+	defer r.l.Unlock()
+	defer r.stdout.set(nil)
+	defer r.stderr.set(nil)
+
+	var status int
+	select {
+	case status = <-r.status:
+	case <-r.exited:
+		log.Printf("[DEBUG] runspace exited with code %d", r.exitCode)
+		return r.exitCode
+	}
+	select {
+	case <-r.stderrDone:
+	case <-r.exited:
+	}
+	return status
+}
+
+// targetWriter writes to the writer it is set to, if any.
+type targetWriter struct {
+	l sync.Mutex
+	w io.Writer
+}
+
+func (t *targetWriter) set(w io.Writer) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	t.w = w
+}
+
+func (t *targetWriter) Write(p []byte) (int, error) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	if t.w == nil || len(p) == 0 {
+		return len(p), nil
+	}
+	return t.w.Write(p)
+}
@@ -0,0 +1,108 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+var runspaceScriptRe = regexp.MustCompile(`FromBase64String\('([^']*)'\).*WriteLine\('([^']*)' \+`)
+
+// newMockRunspace returns a runspace whose process runs the script blocks
+// "echo <text>", "fail <text>" and "exit <code>".
+func newMockRunspace(t *testing.T) *runspace {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	exitCode := make(chan int, 1)
+
+	go func() {
+		defer stdoutW.Close()
+		defer stderrW.Close()
+		code := 0
+		defer func() { exitCode <- code }()
+
+		lines := bufio.NewScanner(stdinR)
+		for lines.Scan() {
+			m := runspaceScriptRe.FindStringSubmatch(lines.Text())
+			if m == nil {
+				continue
+			}
+			script, _ := base64.StdEncoding.DecodeString(m[1])
+			verb, arg, _ := strings.Cut(string(script), " ")
+			status := 0
+			switch verb {
+			case "echo":
+				// The marker is written right after the output when
+				// it does not end with a new line.
+				fmt.Fprint(stdoutW, arg)
+			case "fail":
+				fmt.Fprintf(stderrW, "%s\r\n", arg)
+				status = 1
+			case "exit":
+				fmt.Sscan(arg, &code)
+				return
+			}
+			fmt.Fprintf(stdoutW, "%s%d\r\n", m[2], status)
+			fmt.Fprintf(stderrW, "%s\r\n", m[2])
+		}
+	}()
+
+	r := newRunspace(stdinW, stdoutR, stderrR, func() int { return <-exitCode }, stdinW.Close)
+	t.Cleanup(func() { r.close() })
+	return r
+}
+
+func runInRunspace(t *testing.T, r *runspace, command string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	rc := &packersdk.RemoteCmd{Command: command, Stdout: &stdout, Stderr: &stderr}
+	if err := r.start(rc); err != nil {
+		t.Fatalf("error starting %q: %s", command, err)
+	}
+	code := r.wait()
+	return stdout.String(), stderr.String(), code
+}
+
+func TestRunspace(t *testing.T) {
+	r := newMockRunspace(t)
+
+	stdout, stderr, code := runInRunspace(t, r, "echo foo")
+	if stdout != "foo" || stderr != "" || code != 0 {
+		t.Fatalf("bad result: %q %q %d", stdout, stderr, code)
+	}
+
+	stdout, stderr, code = runInRunspace(t, r, "fail oops")
+	if stdout != "" || stderr != "oops\r\n" || code != 1 {
+		t.Fatalf("bad result: %q %q %d", stdout, stderr, code)
+	}
+
+	// The output of a command is not mixed with the one of the previous.
+	stdout, _, code = runInRunspace(t, r, "echo bar")
+	if stdout != "bar" || code != 0 {
+		t.Fatalf("bad result: %q %d", stdout, code)
+	}
+}
+
+func TestRunspace_exit(t *testing.T) {
+	r := newMockRunspace(t)
+
+	if _, _, code := runInRunspace(t, r, "exit 5"); code != 5 {
+		t.Fatalf("expected the exit code of the process, got %d", code)
+	}
+	if r.alive() {
+		t.Fatal("the runspace should have exited")
+	}
+	if err := r.start(&packersdk.RemoteCmd{Command: "echo foo"}); err == nil {
+		t.Fatal("expected an error starting a command in an exited runspace")
+	}
+}
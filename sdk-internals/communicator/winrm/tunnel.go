@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/masterzen/winrm"
+)
+
+// relayScript connects to a host and port from the remote machine and copies
+// the connection to and from the standard streams of the command.
+const relayScript = `$client = New-Object System.Net.Sockets.TcpClient('%s', %d)
+$stream = $client.GetStream()
+$copy = [Console]::OpenStandardInput().CopyToAsync($stream)
+$stream.CopyTo([Console]::OpenStandardOutput())
+$client.Close()`
+
+// tunnel forwards the connections accepted by a listener over WinRM.
+type tunnel struct {
+	listener net.Listener
+	once     sync.Once
+}
+
+func (t *tunnel) Addr() string {
+	return t.listener.Addr().String()
+}
+
+func (t *tunnel) Close() error {
+	var err error
+	t.once.Do(func() {
+		err = t.listener.Close()
+	})
+	return err
+}
+
+// Tunnel forwards the connections to local to remote. WinRM cannot forward
+// ports, so each connection is relayed by a PowerShell command connecting to
+// remote: this is slow, but enough to reach a service of the machine, like a
+// health check endpoint.
+func (c *Communicator) Tunnel(ctx context.Context, local, remote string) (packersdk.Tunnel, error) {
+	host, port, err := splitRelayAddr(remote)
+	if err != nil {
+		return nil, err
+	}
+	var lc net.ListenConfig
+	l, err := lc.Listen(ctx, "tcp", local)
+	if err != nil {
+		return nil, fmt.Errorf("Tunnel: Failed to bind local (%q): %s", local, err)
+	}
+	log.Printf("[INFO] Tunnel: Local bound on %s relaying to %s over WinRM", l.Addr(), remote)
+
+	script := winrm.Powershell(fmt.Sprintf(relayScript, host, port))
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Printf("[DEBUG] Tunnel: %s closed: %s", l.Addr(), err)
+				return
+			}
+			go c.relay(conn, script)
+		}
+	}()
+	return &tunnel{listener: l}, nil
+}
+
+// ReverseTunnel is not supported: WinRM cannot listen on the remote machine
+// for us.
+func (c *Communicator) ReverseTunnel(ctx context.Context, remote, local string) (packersdk.Tunnel, error) {
+	return nil, fmt.Errorf("WinRM: reverse tunnels: %w", packersdk.ErrTunnelsNotSupported)
+}
+
+func (c *Communicator) relay(conn net.Conn, script string) {
+	defer conn.Close()
+
+	shell, err := c.client.CreateShell()
+	if err != nil {
+		log.Printf("[ERROR] Tunnel: failed to create a shell: %s", err)
+		return
+	}
+	defer shell.Close()
+
+	cmd, err := shell.Execute(script)
+	if err != nil {
+		log.Printf("[ERROR] Tunnel: failed to start the relay: %s", err)
+		return
+	}
+	defer cmd.Close()
+
+	go func() {
+		io.Copy(cmd.Stdin, conn)
+		cmd.Stdin.Close()
+	}()
+	if _, err := io.Copy(conn, cmd.Stdout); err != nil {
+		log.Printf("[ERROR] Tunnel: Copy error: %s", err)
+	}
+	cmd.Wait()
+}
+
+// splitRelayAddr splits addr into a host that can be quoted in relayScript
+// and a port.
+func splitRelayAddr(addr string) (string, int, error) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("Tunnel: invalid remote address %q: %s", addr, err)
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("Tunnel: invalid remote port %q", p)
+	}
+	if host == "" || strings.ContainsAny(host, "'\r\n") {
+		return "", 0, fmt.Errorf("Tunnel: invalid remote host %q", host)
+	}
+	return host, port, nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package secret holds secrets, like passwords or private keys, in memory
+// for no longer than they are needed.
+//
+// A Value is zeroed once destroyed, so that the secret doesn't linger in
+// memory, and in core dumps, after use. It also can't be printed by mistake:
+// it is redacted when formatted with the fmt package, logged or encoded in
+// JSON.
+//
+// Go strings can't be zeroed: prefer Bytes to Reveal, which returns a copy
+// of the secret living until it is garbage collected.
+package secret
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Redacted is what a Value is printed as.
+const Redacted = "<sensitive>"
+
+// Value is a secret. The zero value and nil are empty secrets.
+type Value struct {
+	l sync.Mutex
+	b []byte
+}
+
+// New returns a Value holding b, which it owns from then on: b is zeroed
+// when the Value is destroyed.
+func New(b []byte) *Value {
+	v := &Value{b: b}
+	// Secrets that are never destroyed are still zeroed before their
+	// memory is reused.
+	runtime.SetFinalizer(v, (*Value).Destroy)
+	return v
+}
+
+// FromString returns a Value holding a copy of s. The memory of s itself
+// can't be zeroed.
+func FromString(s string) *Value {
+	if s == "" {
+		return New(nil)
+	}
+	return New([]byte(s))
+}
+
+// Bytes returns the secret, nil once destroyed. The slice is zeroed by
+// Destroy: don't use it afterwards, and don't keep copies of it.
+func (v *Value) Bytes() []byte {
+	if v == nil {
+		return nil
+	}
+	v.l.Lock()
+	defer v.l.Unlock()
+	return v.b
+}
+
+// Reveal returns a copy of the secret, for the APIs that only take strings.
+// Unlike the Value, the copy isn't zeroed on Destroy.
+func (v *Value) Reveal() string {
+	return string(v.Bytes())
+}
+
+// Empty tells whether the secret is empty or destroyed.
+func (v *Value) Empty() bool {
+	return len(v.Bytes()) == 0
+}
+
+// Destroy zeroes the secret and empties v. It can be called more than once.
+func (v *Value) Destroy() {
+	if v == nil {
+		return
+	}
+	v.l.Lock()
+	defer v.l.Unlock()
+	clear(v.b)
+	v.b = nil
+}
+
+// String returns Redacted, never the secret.
+func (v *Value) String() string {
+	return Redacted
+}
+
+// GoString returns Redacted, for %#v.
+func (v *Value) GoString() string {
+	return Redacted
+}
+
+// Format redacts v whatever the verb, like %x or %q.
+func (v *Value) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, Redacted)
+}
+
+// MarshalJSON encodes v as Redacted: secrets are not written to files or
+// logs encoded in JSON.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Redacted + `"`), nil
+}
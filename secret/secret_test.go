@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestValue_Destroy(t *testing.T) {
+	b := []byte("s3cr3t")
+	v := New(b)
+	if v.Reveal() != "s3cr3t" || v.Empty() {
+		t.Fatalf("bad secret: %q", v.Reveal())
+	}
+
+	v.Destroy()
+	if !v.Empty() || v.Bytes() != nil || v.Reveal() != "" {
+		t.Fatal("the secret should be empty once destroyed")
+	}
+	for i, c := range b {
+		if c != 0 {
+			t.Fatalf("byte %d was not zeroed: %q", i, b)
+		}
+	}
+	v.Destroy()
+
+	var nilValue *Value
+	nilValue.Destroy()
+	if !nilValue.Empty() {
+		t.Fatal("nil should be empty")
+	}
+}
+
+func TestValue_redacted(t *testing.T) {
+	v := FromString("s3cr3t")
+	defer v.Destroy()
+
+	config := struct {
+		Username string
+		Password *Value
+	}{"packer", v}
+
+	var logs strings.Builder
+	logger := log.New(&logs, "", 0)
+	logger.Printf("%s %v %+v %#v %q %x", v, v, config, config, v, v)
+	if strings.Contains(logs.String(), "s3cr3t") || strings.Contains(logs.String(), fmt.Sprintf("%x", "s3cr3t")) {
+		t.Fatalf("the secret was printed: %s", logs.String())
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var decoded map[string]string
+	if err := json.Unmarshal(b, &decoded); err != nil || decoded["Password"] != Redacted {
+		t.Fatalf("bad JSON: %s", b)
+	}
+}
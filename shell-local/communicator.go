@@ -10,7 +10,6 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"syscall"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -42,14 +41,11 @@ func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) erro
 		var exitStatus int
 		err := localCmd.Wait()
 		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				exitStatus = 1
-
-				// There is no process-independent way to get the REAL
-				// exit status so we just try to go deeper.
-				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-					exitStatus = status.ExitStatus()
-				}
+			// Commands that could not be waited for, or were killed by a
+			// signal, have no exit code but must not look successful.
+			exitStatus = 1
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+				exitStatus = exitErr.ExitCode()
 			}
 		}
 
@@ -71,6 +67,12 @@ func (c *Communicator) Download(string, io.Writer) error {
 	return fmt.Errorf("download not supported")
 }
 
+// Capabilities reports that commands run with the environment of the Packer
+// process, since they are executed locally.
+func (c *Communicator) Capabilities() (packersdk.CommunicatorCapabilities, bool) {
+	return packersdk.CommunicatorCapabilities{Env: true}, true
+}
+
 func (c *Communicator) DownloadDir(string, string, []string) error {
 	return fmt.Errorf("downloadDir not supported")
 }
@@ -19,9 +19,24 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
+// These are the interpreters shell-local knows the defaults of.
+const (
+	InterpreterSh         = "sh"
+	InterpreterCmd        = "cmd"
+	InterpreterPwsh       = "pwsh"
+	InterpreterPowershell = "powershell"
+)
+
 type Config struct {
 	shell.Provisioner `mapstructure:",squash"`
 
+	// The interpreter running the scripts, which sets the defaults of
+	// execute_command, env_var_format and tempfile_extension, and how the
+	// values of environment variables are quoted: `sh`, `cmd`, `pwsh` for
+	// PowerShell Core, or `powershell` for Windows PowerShell. Defaults to
+	// `cmd` on Windows and `sh` elsewhere.
+	Interpreter string `mapstructure:"interpreter"`
+
 	// ** DEPRECATED: USE INLINE INSTEAD **
 	// ** Only Present for backwards compatibility **
 	// Command is the command to execute
@@ -72,7 +87,15 @@ func Decode(config *Config, raws ...interface{}) error {
 func Validate(config *Config) error {
 	var errs *packersdk.MultiError
 
-	if runtime.GOOS == "windows" {
+	if config.Interpreter == "" {
+		config.Interpreter = InterpreterSh
+		if runtime.GOOS == "windows" {
+			config.Interpreter = InterpreterCmd
+		}
+	}
+
+	switch config.Interpreter {
+	case InterpreterCmd:
 		if len(config.ExecuteCommand) == 0 {
 			config.ExecuteCommand = []string{
 				"cmd",
@@ -86,7 +109,33 @@ func Validate(config *Config) error {
 		if len(config.TempfileExtension) == 0 {
 			config.TempfileExtension = ".cmd"
 		}
-	} else {
+		if config.EnvVarFormat == "" {
+			config.EnvVarFormat = "set %s=%s && "
+			if config.UseLinuxPathing {
+				config.EnvVarFormat = "%s='%s' "
+			}
+		}
+	case InterpreterPwsh, InterpreterPowershell:
+		if len(config.ExecuteCommand) == 0 {
+			// Exiting with $LASTEXITCODE propagates the exit code of the
+			// script, which -Command would turn into 0 or 1.
+			config.ExecuteCommand = []string{
+				config.Interpreter,
+				"-NoProfile",
+				"-NonInteractive",
+				"-ExecutionPolicy",
+				"Bypass",
+				"-Command",
+				"{{.Vars}}& '{{.Script}}'; exit $LASTEXITCODE",
+			}
+		}
+		if len(config.TempfileExtension) == 0 {
+			config.TempfileExtension = ".ps1"
+		}
+		if config.EnvVarFormat == "" {
+			config.EnvVarFormat = "$env:%s='%s'; "
+		}
+	case InterpreterSh:
 		if config.InlineShebang == "" {
 			config.InlineShebang = "/bin/sh -e"
 		}
@@ -97,6 +146,13 @@ func Validate(config *Config) error {
 				"{{.Vars}} {{.Script}}",
 			}
 		}
+		if config.EnvVarFormat == "" {
+			config.EnvVarFormat = "%s='%s' "
+		}
+	default:
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("interpreter is invalid. Must be one of: %v",
+				[]string{InterpreterSh, InterpreterCmd, InterpreterPwsh, InterpreterPowershell}))
 	}
 
 	// Verify that the user has given us a command to run
@@ -179,17 +235,13 @@ func Validate(config *Config) error {
 		}
 	}
 
-	if config.EnvVarFormat == "" {
-		if (runtime.GOOS == "windows") && !config.UseLinuxPathing {
-			config.EnvVarFormat = "set %s=%s && "
-		} else {
-			config.EnvVarFormat = "%s='%s' "
-		}
-	}
-
 	// drop unnecessary "." in extension; we add this later.
 	config.TempfileExtension = strings.TrimPrefix(config.TempfileExtension, ".")
 
+	for _, err := range config.ValidateExitCodeActions() {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
 	// Do a check for bad environment variables, such as '=foo', 'foobar'
 	for _, kv := range config.Vars {
 		vs := strings.SplitN(kv, "=", 2)
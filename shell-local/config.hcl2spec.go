@@ -22,9 +22,11 @@ type FlatConfig struct {
 	Script              *string           `cty:"script" hcl:"script"`
 	Scripts             []string          `cty:"scripts" hcl:"scripts"`
 	ValidExitCodes      []int             `mapstructure:"valid_exit_codes" cty:"valid_exit_codes" hcl:"valid_exit_codes"`
+	ExitCodeActions     map[string]string `mapstructure:"exit_code_actions" cty:"exit_code_actions" hcl:"exit_code_actions"`
 	Vars                []string          `mapstructure:"environment_vars" cty:"environment_vars" hcl:"environment_vars"`
 	Env                 map[string]string `mapstructure:"env" cty:"env" hcl:"env"`
 	EnvVarFormat        *string           `mapstructure:"env_var_format" cty:"env_var_format" hcl:"env_var_format"`
+	Interpreter         *string           `mapstructure:"interpreter" cty:"interpreter" hcl:"interpreter"`
 	Command             *string           `cty:"command" hcl:"command"`
 	ExecuteCommand      []string          `mapstructure:"execute_command" cty:"execute_command" hcl:"execute_command"`
 	InlineShebang       *string           `mapstructure:"inline_shebang" cty:"inline_shebang" hcl:"inline_shebang"`
@@ -57,9 +59,11 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"script":                     &hcldec.AttrSpec{Name: "script", Type: cty.String, Required: false},
 		"scripts":                    &hcldec.AttrSpec{Name: "scripts", Type: cty.List(cty.String), Required: false},
 		"valid_exit_codes":           &hcldec.AttrSpec{Name: "valid_exit_codes", Type: cty.List(cty.Number), Required: false},
+		"exit_code_actions":          &hcldec.AttrSpec{Name: "exit_code_actions", Type: cty.Map(cty.String), Required: false},
 		"environment_vars":           &hcldec.AttrSpec{Name: "environment_vars", Type: cty.List(cty.String), Required: false},
 		"env":                        &hcldec.AttrSpec{Name: "env", Type: cty.Map(cty.String), Required: false},
 		"env_var_format":             &hcldec.AttrSpec{Name: "env_var_format", Type: cty.String, Required: false},
+		"interpreter":                &hcldec.AttrSpec{Name: "interpreter", Type: cty.String, Required: false},
 		"command":                    &hcldec.AttrSpec{Name: "command", Type: cty.String, Required: false},
 		"execute_command":            &hcldec.AttrSpec{Name: "execute_command", Type: cty.List(cty.String), Required: false},
 		"inline_shebang":             &hcldec.AttrSpec{Name: "inline_shebang", Type: cty.String, Required: false},
@@ -4,6 +4,8 @@
 package shell_local
 
 import (
+	"os"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,3 +19,76 @@ func TestConvertToLinuxPath(t *testing.T) {
 		"Should have converted %s to %s -- not %s", winPath, winBashPath, converted)
 
 }
+
+func TestValidate_Interpreter(t *testing.T) {
+	config := &Config{Interpreter: InterpreterPwsh}
+	config.Inline = []string{"Write-Output hello"}
+	if err := Validate(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "pwsh", config.ExecuteCommand[0])
+	assert.Equal(t, "ps1", config.TempfileExtension)
+	assert.Equal(t, "$env:%s='%s'; ", config.EnvVarFormat)
+	assert.Equal(t, "", config.InlineShebang)
+
+	config = &Config{Interpreter: "fish"}
+	config.Inline = []string{"echo hello"}
+	if err := Validate(config); err == nil {
+		t.Fatal("unknown interpreters should be rejected")
+	}
+}
+
+func TestCreateFlattenedEnvVars_Quoting(t *testing.T) {
+	tests := map[string]string{
+		InterpreterSh:   `FOO='it'"'"'s' `,
+		InterpreterPwsh: `$env:FOO='it''s'; `,
+	}
+	for interpreter, expected := range tests {
+		config := &Config{Interpreter: interpreter}
+		config.Inline = []string{"true"}
+		config.Env = map[string]string{"FOO": "it's"}
+		if err := Validate(config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		config.generatedData = map[string]interface{}{}
+		flattened, err := createFlattenedEnvVars(config)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assert.Contains(t, flattened, expected, "interpreter %s", interpreter)
+	}
+}
+
+func TestCreateInlineScriptFile_Heredoc(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("line endings differ on windows")
+	}
+	config := &Config{}
+	config.Inline = []string{"#!/usr/bin/env bash\nset -eu\r\necho one\n", "echo two"}
+	if err := Validate(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path, err := createInlineScriptFile(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(path)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// The shebang of the heredoc replaces inline_shebang.
+	assert.Equal(t, "#!/usr/bin/env bash\nset -eu\necho one\necho two\n", string(b))
+
+	config = &Config{Interpreter: InterpreterPwsh}
+	config.Inline = []string{"Write-Output one"}
+	if err := Validate(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path, err = createInlineScriptFile(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(path)
+	assert.Regexp(t, `packer-shell[0-9]+\.ps1$`, path)
+}
@@ -0,0 +1,248 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package localexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// DefaultKillGracePeriod is how long a Supervisor waits for a process it
+	// stopped to exit before killing it.
+	DefaultKillGracePeriod = 10 * time.Second
+	// DefaultTailLines is the number of lines of output a Supervisor reports
+	// when a process fails.
+	DefaultTailLines = 20
+)
+
+// Supervisor runs the external tools builders shell out to, like qemu or
+// VBoxManage. The tool runs in its own process group, so that the interrupts
+// of the terminal reach Packer only, which then cancels the context: the
+// whole group is asked to stop, and killed if it does not within the grace
+// period. The output of the tool is streamed to the Ui, and its last lines
+// reported when it fails.
+type Supervisor struct {
+	// Ui, if set, receives the output of the tool, line by line.
+	Ui packersdk.Ui
+	// Prefix, like "qemu: ", begins the lines sent to the Ui.
+	Prefix string
+	// Timeout, if set, stops the tool after this long.
+	Timeout time.Duration
+	// KillGracePeriod is how long the tool has to exit once stopped. It
+	// defaults to DefaultKillGracePeriod.
+	KillGracePeriod time.Duration
+	// TailLines is the number of last lines of output reported in the
+	// errors. It defaults to DefaultTailLines.
+	TailLines int
+	// LogPath, if set, is the file the whole output is written to, to
+	// investigate crashes.
+	LogPath string
+	// Sensitive values are scrubbed from the output.
+	Sensitive []string
+}
+
+// ExitError is returned by Supervisor.Run when the tool failed.
+type ExitError struct {
+	// Command is the name of the tool.
+	Command string
+	// ExitStatus is the status of the tool, -1 when it was killed.
+	ExitStatus int
+	// TimedOut is true when the tool did not finish before the timeout.
+	TimedOut bool
+	// Tail are the last lines of output of the tool.
+	Tail []string
+	// Err is the error of cmd.Wait.
+	Err error
+}
+
+func (e *ExitError) Error() string {
+	var b strings.Builder
+	if e.TimedOut {
+		fmt.Fprintf(&b, "%s timed out", e.Command)
+	} else {
+		fmt.Fprintf(&b, "%s exited with status %d", e.Command, e.ExitStatus)
+	}
+	if len(e.Tail) > 0 {
+		fmt.Fprintf(&b, ", last output:\n%s", strings.Join(e.Tail, "\n"))
+	}
+	return b.String()
+}
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// Run runs cmd until it exits, ctx is done, or the timeout expires. The
+// Stdout and Stderr of cmd are replaced. It returns an *ExitError when the
+// tool exits with a non-zero status or timed out, and the error of ctx when
+// it is done first.
+func (s *Supervisor) Run(ctx context.Context, cmd *exec.Cmd) error {
+	grace := s.KillGracePeriod
+	if grace <= 0 {
+		grace = DefaultKillGracePeriod
+	}
+	tailLines := s.TailLines
+	if tailLines <= 0 {
+		tailLines = DefaultTailLines
+	}
+	packersdk.LogSecretFilter.Set(s.Sensitive...)
+
+	out := &outputLines{ui: s.Ui, prefix: s.Prefix, max: tailLines}
+	if s.LogPath != "" {
+		f, err := os.Create(s.LogPath)
+		if err != nil {
+			return fmt.Errorf("Error creating log file: %s", err)
+		}
+		defer f.Close()
+		out.log = f
+	}
+	cmd.Stdout = out.writer()
+	cmd.Stderr = out.writer()
+	// Pipes kept open by children that outlive the tool don't block Wait.
+	cmd.WaitDelay = grace
+	setProcessGroup(cmd)
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	log.Printf("Executing: %s %v", cmd.Path, cmd.Args[1:])
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		log.Printf("[INFO] Stopping %s: %s", cmd.Path, ctx.Err())
+		if err := stopProcessGroup(cmd); err != nil {
+			log.Printf("[DEBUG] Could not stop %s: %s", cmd.Path, err)
+		}
+		select {
+		case err = <-done:
+		case <-time.After(grace):
+			log.Printf("[WARN] %s did not stop within %s, killing it", cmd.Path, grace)
+			if err := killProcessGroup(cmd); err != nil {
+				log.Printf("[DEBUG] Could not kill %s: %s", cmd.Path, err)
+			}
+			err = <-done
+		}
+	}
+	out.flush()
+
+	timedOut := s.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if ctx.Err() != nil && !timedOut {
+		return ctx.Err()
+	}
+	if err == nil && !timedOut {
+		return nil
+	}
+	exitErr := &ExitError{
+		Command:    cmd.Path,
+		ExitStatus: cmd.ProcessState.ExitCode(),
+		TimedOut:   timedOut,
+		Tail:       out.tail(),
+		Err:        err,
+	}
+	if s.LogPath != "" {
+		log.Printf("[ERR] %s failed, its output is in %s", cmd.Path, s.LogPath)
+	}
+	return exitErr
+}
+
+// outputLines splits the output of a tool in lines, sends them to the Ui
+// and keeps the last ones.
+type outputLines struct {
+	ui     packersdk.Ui
+	prefix string
+	max    int
+	log    io.Writer
+
+	mu      sync.Mutex
+	last    []string
+	writers []*lineWriter
+}
+
+func (o *outputLines) writer() io.Writer {
+	w := &lineWriter{o: o}
+	o.writers = append(o.writers, w)
+	return w
+}
+
+func (o *outputLines) line(line string) {
+	line = packersdk.LogSecretFilter.FilterString(cleanOutputLine(line))
+	if line == "" {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.log != nil {
+		fmt.Fprintln(o.log, line)
+	}
+	o.last = append(o.last, line)
+	if len(o.last) > o.max {
+		o.last = o.last[len(o.last)-o.max:]
+	}
+	if o.ui != nil {
+		o.ui.Message(o.prefix + line)
+	}
+}
+
+// flush sends the last lines not ended by a newline.
+func (o *outputLines) flush() {
+	for _, w := range o.writers {
+		w.flush()
+	}
+}
+
+func (o *outputLines) tail() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.last...)
+}
+
+// lineWriter is the stdout or the stderr of a tool.
+type lineWriter struct {
+	o   *outputLines
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf.Next(i + 1))
+		w.o.line(line)
+	}
+}
+
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.o.line(w.buf.String())
+		w.buf.Reset()
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package localexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testShell(t *testing.T, script string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	return exec.Command("/bin/sh", "-c", script)
+}
+
+func TestSupervisor_Run(t *testing.T) {
+	var out bytes.Buffer
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: &out, ErrorWriter: io.Discard}
+	logPath := filepath.Join(t.TempDir(), "tool.log")
+	s := &Supervisor{Ui: ui, Prefix: "tool: ", LogPath: logPath, Sensitive: []string{"hunter2"}}
+
+	err := s.Run(context.Background(), testShell(t, "echo one; echo password hunter2 >&2; printf two"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, line := range []string{"tool: one\n", "tool: password <sensitive>\n", "tool: two\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("missing %q in output:\n%s", line, out.String())
+		}
+	}
+	b, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(string(b), "hunter2") || !strings.Contains(string(b), "two\n") {
+		t.Fatalf("bad log:\n%s", b)
+	}
+}
+
+func TestSupervisor_Run_failure(t *testing.T) {
+	s := &Supervisor{TailLines: 2}
+
+	err := s.Run(context.Background(), testShell(t, "echo one; echo two; echo three; exit 3"))
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected an ExitError, got %v", err)
+	}
+	if exitErr.ExitStatus != 3 || exitErr.TimedOut {
+		t.Fatalf("bad: %#v", exitErr)
+	}
+	if strings.Join(exitErr.Tail, ",") != "two,three" {
+		t.Fatalf("bad tail: %q", exitErr.Tail)
+	}
+	if !strings.HasSuffix(err.Error(), "status 3, last output:\ntwo\nthree") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestSupervisor_Run_timeout(t *testing.T) {
+	s := &Supervisor{Timeout: 100 * time.Millisecond, KillGracePeriod: time.Second}
+
+	start := time.Now()
+	err := s.Run(context.Background(), testShell(t, "echo started; sleep 30"))
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || !exitErr.TimedOut {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatalf("the tool was not stopped")
+	}
+}
+
+func TestSupervisor_Run_kill(t *testing.T) {
+	s := &Supervisor{KillGracePeriod: 100 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	// The shell ignores SIGTERM, so it is killed after the grace period.
+	err := s.Run(ctx, testShell(t, "trap '' TERM; sleep 30 & wait; sleep 30"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the error of the context, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatalf("the tool was not killed")
+	}
+}
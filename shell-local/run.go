@@ -16,6 +16,7 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/shell"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
 )
@@ -56,12 +57,6 @@ func Run(ctx context.Context, ui packersdk.Ui, config *Config, generatedData map
 			return false, err
 		}
 
-		// figure out what extension the file should have, and rename it.
-		if config.TempfileExtension != "" {
-			os.Rename(tempScriptFileName, fmt.Sprintf("%s.%s", tempScriptFileName, config.TempfileExtension))
-			tempScriptFileName = fmt.Sprintf("%s.%s", tempScriptFileName, config.TempfileExtension)
-		}
-
 		scripts = append(scripts, tempScriptFileName)
 
 		defer os.Remove(tempScriptFileName)
@@ -108,36 +103,61 @@ func Run(ctx context.Context, ui packersdk.Ui, config *Config, generatedData map
 				absScript)
 		}
 
-		if err := config.ValidExitCode(cmd.ExitStatus()); err != nil {
+		action, err := config.ExitCodeAction(cmd.ExitStatus())
+		if err != nil {
 			return false, err
 		}
+		if action == shell.ExitCodeReboot {
+			ui.Say(fmt.Sprintf("Script exited with status %d, which requests a reboot; "+
+				"shell-local does not reboot the local machine and will continue.",
+				cmd.ExitStatus()))
+		}
 	}
 
 	return true, nil
 }
 
 func createInlineScriptFile(config *Config) (string, error) {
-	tf, err := tmp.File("packer-shell")
+	// Create the file with its extension, rather than renaming it, which can
+	// fail on Windows while the file is still open.
+	pattern := "packer-shell*"
+	if config.TempfileExtension != "" {
+		pattern += "." + config.TempfileExtension
+	}
+	tf, err := tmp.File(pattern)
 	if err != nil {
 		return "", fmt.Errorf("Error preparing shell script: %s", err)
 	}
 	defer tf.Close()
-	// Write our contents to it
-	writer := bufio.NewWriter(tf)
-	if config.InlineShebang != "" {
-		shebang := fmt.Sprintf("#!%s\n", config.InlineShebang)
-		log.Printf("[INFO] (shell-local): Prepending inline script with %s", shebang)
-		writer.WriteString(shebang)
-	}
 
+	var lines []string
 	for _, command := range config.Inline {
 		// interpolate command to check for template variables.
 		command, err := interpolate.Render(command, &config.ctx)
 		if err != nil {
 			return "", err
 		}
+		// A command can be a heredoc of several lines.
+		for _, line := range strings.Split(strings.TrimSuffix(command, "\n"), "\n") {
+			lines = append(lines, strings.TrimSuffix(line, "\r"))
+		}
+	}
+
+	// cmd and PowerShell scripts get Windows line endings on Windows.
+	newline := "\n"
+	if runtime.GOOS == "windows" && config.Interpreter != InterpreterSh && !config.UseLinuxPathing {
+		newline = "\r\n"
+	}
 
-		if _, err := writer.WriteString(command + "\n"); err != nil {
+	// Write our contents to it
+	writer := bufio.NewWriter(tf)
+	if config.InlineShebang != "" && (len(lines) == 0 || !strings.HasPrefix(lines[0], "#!")) {
+		shebang := fmt.Sprintf("#!%s", config.InlineShebang)
+		log.Printf("[INFO] (shell-local): Prepending inline script with %s", shebang)
+		writer.WriteString(shebang + newline)
+	}
+	for _, line := range lines {
+		if _, err := writer.WriteString(line + newline); err != nil {
 			return "", fmt.Errorf("Error preparing shell script: %s", err)
 		}
 	}
@@ -204,15 +224,15 @@ func createFlattenedEnvVars(config *Config) (string, error) {
 		}
 		// Split vars into key/value components
 		keyValue := strings.SplitN(envVar, "=", 2)
-		// Store pair, replacing any single quotes in value so they parse
+		// Store pair, escaping any single quotes in value so they parse
 		// correctly with required environment variable format
-		envVars[keyValue[0]] = strings.Replace(keyValue[1], "'", `'"'"'`, -1)
+		envVars[keyValue[0]] = config.quoteEnvValue(keyValue[1])
 	}
 
 	for k, v := range config.Env {
-		// Store pair, replacing any single quotes in value so they parse
+		// Store pair, escaping any single quotes in value so they parse
 		// correctly with required environment variable format
-		envVars[k] = strings.Replace(v, "'", `'"'"'`, -1)
+		envVars[k] = config.quoteEnvValue(v)
 	}
 
 	// Create a list of env var keys in sorted order
@@ -227,3 +247,17 @@ func createFlattenedEnvVars(config *Config) (string, error) {
 	}
 	return flattened, nil
 }
+
+// quoteEnvValue escapes the single quotes of v, to be set in the single
+// quoted strings of the default env_var_format of the interpreter.
+func (c *Config) quoteEnvValue(v string) string {
+	switch {
+	case c.Interpreter == InterpreterPwsh || c.Interpreter == InterpreterPowershell:
+		return strings.ReplaceAll(v, "'", "''")
+	case c.Interpreter == InterpreterCmd && !c.UseLinuxPathing:
+		// set takes the rest of the line as is.
+		return v
+	default:
+		return strings.ReplaceAll(v, "'", `'"'"'`)
+	}
+}
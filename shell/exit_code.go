@@ -3,28 +3,110 @@
 
 package shell
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ExitCodeAction is what a provisioner should do after a script exited with
+// a given code.
+type ExitCodeAction string
+
+const (
+	// ExitCodeContinue carries on with the next script.
+	ExitCodeContinue ExitCodeAction = "continue"
+	// ExitCodeReboot restarts the machine, waits for the communicator to
+	// come back and then carries on with the next script. Provisioners
+	// running their scripts with RunWithExitCodeActions act on it.
+	ExitCodeReboot ExitCodeAction = "reboot"
+	// ExitCodeFail stops the provisioner with an error.
+	ExitCodeFail ExitCodeAction = "fail"
+)
 
 func (p *Provisioner) ValidExitCode(code int) error {
-	// Check exit code against allowed codes (likely just 0)
-	validCodes := p.ValidExitCodes
-	if len(validCodes) == 0 {
-		validCodes = []int{0}
+	_, err := p.ExitCodeAction(code)
+	return err
+}
+
+// ExitCodeAction returns the action configured for code. Codes without an
+// action continue when they are valid exit codes and fail otherwise. The
+// returned error is set if and only if the action is ExitCodeFail.
+func (p *Provisioner) ExitCodeAction(code int) (ExitCodeAction, error) {
+	action := p.actionFor(code)
+	if action == ExitCodeFail {
+		return action, &ErrorInvalidExitCode{
+			Code:    code,
+			Allowed: p.allowedExitCodes(),
+		}
 	}
-	validExitCode := false
-	for _, v := range validCodes {
+	return action, nil
+}
+
+func (p *Provisioner) actionFor(code int) ExitCodeAction {
+	if action, ok := p.ExitCodeActions[strconv.Itoa(code)]; ok {
+		return ExitCodeAction(action)
+	}
+	// Check exit code against allowed codes (likely just 0)
+	for _, v := range p.validExitCodes() {
 		if code == v {
-			validExitCode = true
-			break
+			return ExitCodeContinue
 		}
 	}
-	if !validExitCode {
-		return &ErrorInvalidExitCode{
-			Code:    code,
-			Allowed: validCodes,
+	return ExitCodeFail
+}
+
+func (p *Provisioner) validExitCodes() []int {
+	if len(p.ValidExitCodes) == 0 {
+		return []int{0}
+	}
+	return p.ValidExitCodes
+}
+
+// allowedExitCodes lists the codes that don't fail, for error messages.
+func (p *Provisioner) allowedExitCodes() []int {
+	candidates := append([]int{}, p.validExitCodes()...)
+	for k := range p.ExitCodeActions {
+		if code, err := strconv.Atoi(k); err == nil {
+			candidates = append(candidates, code)
+		}
+	}
+	sort.Ints(candidates)
+
+	var allowed []int
+	for i, code := range candidates {
+		if i > 0 && candidates[i-1] == code {
+			continue
+		}
+		if p.actionFor(code) != ExitCodeFail {
+			allowed = append(allowed, code)
+		}
+	}
+	return allowed
+}
+
+// ValidateExitCodeActions checks that the keys of ExitCodeActions are exit
+// codes and its values known actions. It is meant to be called from Prepare.
+func (p *Provisioner) ValidateExitCodeActions() []error {
+	var errs []error
+	keys := make([]string, 0, len(p.ExitCodeActions))
+	for k := range p.ExitCodeActions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, err := strconv.Atoi(k); err != nil {
+			errs = append(errs, fmt.Errorf("exit_code_actions: %q is not an exit code", k))
+		}
+		switch ExitCodeAction(p.ExitCodeActions[k]) {
+		case ExitCodeContinue, ExitCodeReboot, ExitCodeFail:
+		default:
+			errs = append(errs, fmt.Errorf("exit_code_actions: unknown action %q for exit code %s, "+
+				"expected one of %q, %q or %q", p.ExitCodeActions[k], k,
+				ExitCodeContinue, ExitCodeReboot, ExitCodeFail))
 		}
 	}
-	return nil
+	return errs
 }
 
 type ErrorInvalidExitCode struct {
@@ -32,3 +32,54 @@ func TestProvisioner_ValidExitCode(t *testing.T) {
 		})
 	}
 }
+
+func TestProvisioner_ExitCodeAction(t *testing.T) {
+	p := Provisioner{
+		ValidExitCodes: []int{0, 1},
+		ExitCodeActions: map[string]string{
+			"1":    "fail",
+			"3010": "reboot",
+			"2":    "continue",
+		},
+	}
+
+	tests := []struct {
+		code   int
+		action ExitCodeAction
+	}{
+		{0, ExitCodeContinue},
+		{1, ExitCodeFail},
+		{2, ExitCodeContinue},
+		{3010, ExitCodeReboot},
+		{4, ExitCodeFail},
+	}
+	for _, tt := range tests {
+		action, err := p.ExitCodeAction(tt.code)
+		if action != tt.action {
+			t.Errorf("ExitCodeAction(%d) = %q, want %q", tt.code, action, tt.action)
+		}
+		if (err != nil) != (tt.action == ExitCodeFail) {
+			t.Errorf("ExitCodeAction(%d) error = %v", tt.code, err)
+		}
+	}
+
+	_, err := p.ExitCodeAction(4)
+	expected := "Script exited with non-zero exit status: 4. Allowed exit codes are: [0 2 3010]"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvisioner_ValidateExitCodeActions(t *testing.T) {
+	p := Provisioner{
+		ExitCodeActions: map[string]string{
+			"3010":   "reboot",
+			"reboot": "continue",
+			"5":      "retry",
+		},
+	}
+	errs := p.ValidateExitCodeActions()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got: %v", errs)
+	}
+}
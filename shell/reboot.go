@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The commands restarting a guest right away, for Reboot.Command. Commands
+// that need elevated privileges are run with the provisioner's own mechanism,
+// like its execute_command.
+const (
+	UnixRebootCommand    = "shutdown -r now"
+	WindowsRebootCommand = `shutdown /r /f /t 0 /c "packer restart"`
+)
+
+// Reboot tells RunWithExitCodeActions how to restart the guest when a script
+// exits with a code whose action is ExitCodeReboot.
+type Reboot struct {
+	// Command restarts the guest, like UnixRebootCommand or
+	// WindowsRebootCommand. The connection it closes is not an error.
+	Command string
+	// Delay is how long to wait after running Command for the guest to go
+	// down, before connecting to it again. Defaults to 10 seconds.
+	Delay time.Duration
+	// Reconnect waits for the communicator to come back once the guest
+	// restarted, typically the Reconnect method of the
+	// communicator.ReconnectingCommunicator that StepConnect puts in the
+	// state when its Reconnect policy is set.
+	Reconnect func(ctx context.Context) error
+}
+
+// RunWithExitCodeActions runs cmd with RunWithUi, and applies the action
+// configured for its exit status: it returns nil to continue, the
+// ErrorInvalidExitCode of ExitCodeAction to fail, and restarts the guest with
+// reboot, then waits for it to come back, to continue after a reboot. Scripts
+// asking for a reboot fail when reboot is nil, like for provisioners that
+// can't restart their guest.
+func (p *Provisioner) RunWithExitCodeActions(ctx context.Context, comm packersdk.Communicator, ui packersdk.Ui, cmd *packersdk.RemoteCmd, reboot *Reboot) error {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	status := cmd.ExitStatus()
+	action, err := p.ExitCodeAction(status)
+	if err != nil || action != ExitCodeReboot {
+		return err
+	}
+	if reboot == nil || reboot.Command == "" || reboot.Reconnect == nil {
+		return fmt.Errorf("Script exited with status %d, which requests a reboot, "+
+			"but this provisioner can't restart the machine", status)
+	}
+	return reboot.run(ctx, comm, ui, status)
+}
+
+func (r *Reboot) run(ctx context.Context, comm packersdk.Communicator, ui packersdk.Ui, status int) error {
+	ui.Say(fmt.Sprintf("Script exited with status %d, restarting the machine...", status))
+	restart := &packersdk.RemoteCmd{Command: r.Command}
+	if err := restart.RunWithUi(ctx, comm, ui); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		// The restart may close the connection before the command
+		// returns.
+		log.Printf("[INFO] Restart command ended with: %s", err)
+	} else if s := restart.ExitStatus(); s != 0 && s != packersdk.CmdDisconnect {
+		return fmt.Errorf("Restart command %q exited with status %d", r.Command, s)
+	}
+
+	delay := r.Delay
+	if delay <= 0 {
+		delay = 10 * time.Second
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+	}
+
+	ui.Say("Waiting for the machine to restart...")
+	if err := r.Reconnect(ctx); err != nil {
+		return fmt.Errorf("Error connecting to the machine after its restart: %s", err)
+	}
+	ui.Say("Machine restarted, continuing.")
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// guest is a machine the scripts of a test run on. Restarting it closes the
+// connections to it.
+type guest struct {
+	mu       sync.Mutex
+	commands []string
+	statuses map[string]int
+	restarts int
+}
+
+// guestComm is a connection to a guest, lost once the guest restarts.
+type guestComm struct {
+	packersdk.MockCommunicator
+	guest *guest
+	boot  int
+}
+
+func (c *guestComm) Start(_ context.Context, rc *packersdk.RemoteCmd) error {
+	g := c.guest
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.restarts != c.boot {
+		return errors.New("connection refused")
+	}
+	g.commands = append(g.commands, rc.Command)
+	if rc.Command == UnixRebootCommand {
+		g.restarts++
+		rc.SetExited(packersdk.CmdDisconnect)
+		return nil
+	}
+	rc.SetExited(g.statuses[rc.Command])
+	return nil
+}
+
+func testUi() packersdk.Ui {
+	return &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)}
+}
+
+func TestProvisioner_RunWithExitCodeActions(t *testing.T) {
+	g := &guest{statuses: map[string]int{"install-updates": 3010, "broken": 1}}
+	connects := 0
+	comm := communicator.NewReconnectingCommunicator(&guestComm{guest: g},
+		func(context.Context) (packersdk.Communicator, error) {
+			connects++
+			if connects == 1 {
+				// The guest is still booting.
+				return nil, errors.New("connection refused")
+			}
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			return &guestComm{guest: g, boot: g.restarts}, nil
+		},
+		communicator.ReconnectPolicy{Delay: time.Millisecond},
+	)
+	p := &Provisioner{ExitCodeActions: map[string]string{"3010": string(ExitCodeReboot)}}
+	reboot := &Reboot{Command: UnixRebootCommand, Delay: time.Millisecond, Reconnect: comm.Reconnect}
+	ctx := context.Background()
+
+	cmd := &packersdk.RemoteCmd{Command: "install-updates"}
+	if err := p.RunWithExitCodeActions(ctx, comm, testUi(), cmd, reboot); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if g.restarts != 1 || connects != 2 {
+		t.Fatalf("expected a restart and a reconnection, got %d restarts and %d connections", g.restarts, connects)
+	}
+
+	// The next script runs on the restarted guest.
+	if err := p.RunWithExitCodeActions(ctx, comm, testUi(), &packersdk.RemoteCmd{Command: "next"}, reboot); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{"install-updates", UnixRebootCommand, "next"}
+	if strings.Join(g.commands, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected commands %q, got %q", expected, g.commands)
+	}
+
+	var exitErr *ErrorInvalidExitCode
+	if err := p.RunWithExitCodeActions(ctx, comm, testUi(), &packersdk.RemoteCmd{Command: "broken"}, reboot); !errors.As(err, &exitErr) {
+		t.Fatalf("expected an invalid exit code, got %v", err)
+	}
+
+	// Provisioners that can't restart the guest fail.
+	err := p.RunWithExitCodeActions(ctx, comm, testUi(), &packersdk.RemoteCmd{Command: "install-updates"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "requests a reboot") {
+		t.Fatalf("expected an error, got %v", err)
+	}
+}
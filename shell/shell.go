@@ -39,6 +39,12 @@ type Provisioner struct {
 	// for examples such as 3010 - "The requested operation is successful.
 	ValidExitCodes []int `mapstructure:"valid_exit_codes"`
 
+	// Actions to take for specific exit codes, keyed by exit code. An action
+	// is one of `continue`, `reboot` to restart the machine and carry on with
+	// the next script, or `fail`. For example `{ "3010" = "reboot" }`. Codes
+	// with an action take precedence over `valid_exit_codes`.
+	ExitCodeActions map[string]string `mapstructure:"exit_code_actions"`
+
 	// An array of environment variables that will be injected before your
 	// command(s) are executed. Any duplicate vars will be overridden by `env`.
 	Vars []string `mapstructure:"environment_vars"`
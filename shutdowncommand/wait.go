@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shutdowncommand
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ErrShutdownTimeout is returned by PowerOffWaiter.Wait when the machine is
+// still running once the timeout expired.
+var ErrShutdownTimeout = errors.New("timed out waiting for the machine to shut down")
+
+const (
+	defaultPowerOffInterval = 2 * time.Second
+	defaultUnreachableFor   = 30 * time.Second
+)
+
+// PowerOffWaiter waits for a machine to power off once the shutdown command
+// ran, from what the hypervisor tells of it, or from its communicator going
+// away for the builders that can't ask the hypervisor.
+type PowerOffWaiter struct {
+	// PoweredOff asks the hypervisor whether the machine is powered off. It
+	// is authoritative when set, errors being retried until the timeout.
+	PoweredOff func(context.Context) (bool, error)
+	// Reachable tells whether the machine still answers through its
+	// communicator, see TCPReachable. When PoweredOff is not set, the
+	// machine is considered powered off once it has been unreachable for
+	// UnreachableFor.
+	Reachable func(context.Context) bool
+	// UnreachableFor defaults to 30 seconds.
+	UnreachableFor time.Duration
+
+	// Timeout is how long to wait, usually the ShutdownTimeout of the
+	// ShutdownConfig. Defaults to 5 minutes.
+	Timeout time.Duration
+	// Interval is how often the machine is checked. Defaults to 2 seconds.
+	Interval time.Duration
+}
+
+// Wait waits for the machine to power off, telling ui when it becomes
+// unreachable. It returns ErrShutdownTimeout, along with the last error of
+// PoweredOff, when the timeout expires first.
+func (w *PowerOffWaiter) Wait(ctx context.Context, ui packersdk.Ui) error {
+	if w.PoweredOff == nil && w.Reachable == nil {
+		return fmt.Errorf("no way to tell whether the machine is powered off")
+	}
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultPowerOffInterval
+	}
+	unreachableFor := w.UnreachableFor
+	if unreachableFor <= 0 {
+		unreachableFor = defaultUnreachableFor
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	var unreachableSince time.Time
+	for {
+		if w.PoweredOff != nil {
+			off, err := w.PoweredOff(ctx)
+			switch {
+			case err != nil:
+				log.Printf("[DEBUG] Error getting the power state of the machine: %s", err)
+				lastErr = err
+			case off:
+				log.Printf("[INFO] The machine is powered off")
+				return nil
+			}
+		}
+
+		if w.Reachable != nil {
+			reachable := w.Reachable(ctx)
+			switch {
+			case reachable:
+				unreachableSince = time.Time{}
+			case unreachableSince.IsZero():
+				unreachableSince = time.Now()
+				ui.Say(i18n.T("The machine is unreachable, waiting for it to power off..."))
+			case w.PoweredOff == nil && time.Since(unreachableSince) >= unreachableFor:
+				log.Printf("[INFO] The machine has been unreachable for %s, considering it powered off", unreachableFor)
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				if lastErr != nil {
+					return fmt.Errorf("%w after %s, last error: %s", ErrShutdownTimeout, timeout, lastErr)
+				}
+				return fmt.Errorf("%w after %s", ErrShutdownTimeout, timeout)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// TCPReachable returns a Reachable function telling whether address, like
+// the SSH or WinRM address of the machine, accepts connections within
+// timeout.
+func TCPReachable(address string, timeout time.Duration) func(context.Context) bool {
+	return func(ctx context.Context) bool {
+		d := net.Dialer{Timeout: timeout}
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shutdowncommand
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestPowerOffWaiter_poweredOff(t *testing.T) {
+	calls := 0
+	w := &PowerOffWaiter{
+		PoweredOff: func(context.Context) (bool, error) {
+			calls++
+			if calls == 1 {
+				return false, errors.New("transient")
+			}
+			return calls == 3, nil
+		},
+		Interval: time.Millisecond,
+	}
+	if err := w.Wait(context.Background(), packersdk.TestUi(t)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if calls != 3 {
+		t.Fatalf("bad calls: %d", calls)
+	}
+}
+
+func TestPowerOffWaiter_timeout(t *testing.T) {
+	w := &PowerOffWaiter{
+		PoweredOff: func(context.Context) (bool, error) { return false, errors.New("hypervisor unavailable") },
+		Timeout:    20 * time.Millisecond,
+		Interval:   time.Millisecond,
+	}
+	err := w.Wait(context.Background(), packersdk.TestUi(t))
+	if !errors.Is(err, ErrShutdownTimeout) || !strings.Contains(err.Error(), "hypervisor unavailable") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestPowerOffWaiter_unreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	reachable := TCPReachable(l.Addr().String(), time.Second)
+	if !reachable(context.Background()) {
+		t.Fatalf("the listener should be reachable")
+	}
+
+	checks := 0
+	w := &PowerOffWaiter{
+		Reachable: func(ctx context.Context) bool {
+			checks++
+			if checks == 2 {
+				l.Close()
+			}
+			return reachable(ctx)
+		},
+		UnreachableFor: 10 * time.Millisecond,
+		Interval:       time.Millisecond,
+		Timeout:        5 * time.Second,
+	}
+	if err := w.Wait(context.Background(), packersdk.TestUi(t)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if checks < 3 {
+		t.Fatalf("bad checks: %d", checks)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package tags
+
+import "fmt"
+
+// Config holds the tag options of a builder. Embed it in your builder config
+// using the `mapstructure:",squash"` struct tag, call Prepare with the rules
+// of your cloud from the Prepare of the builder and tag resources with
+// BuildTags.
+type Config struct {
+	// Key/value pairs to tag the resources and artifacts created by the
+	// build with. Keys and values are adjusted to the rules of the cloud:
+	// forbidden characters are replaced with underscores and long values
+	// are truncated.
+	Tags map[string]string `mapstructure:"tags" required:"false"`
+	// Do not tag resources with the name of the build, the digest of the
+	// template and the time of the build, in the `packer_build_name`,
+	// `packer_template_digest` and `packer_build_time` tags. Defaults to
+	// false.
+	SkipBuildMetadataTags bool `mapstructure:"skip_build_metadata_tags" required:"false"`
+}
+
+// Prepare normalizes the tags and validates them against rules. The build
+// metadata tags are accounted for in the number of tags unless they are
+// skipped.
+func (c *Config) Prepare(rules Rules) []error {
+	c.Tags = Normalize(c.Tags, rules)
+	errs := Validate(c.Tags, rules)
+	if c.SkipBuildMetadataTags || rules.MaxTags == 0 || len(c.Tags) > rules.MaxTags {
+		return errs
+	}
+	added := 0
+	for _, k := range []string{BuildNameKey, TemplateDigestKey, BuildTimeKey} {
+		if _, found := c.Tags[k]; !found {
+			added++
+		}
+	}
+	if len(c.Tags)+added > rules.MaxTags {
+		errs = append(errs, fmt.Errorf("%d tags are set and %d build metadata tags are added, "+
+			"at most %d are allowed: remove some tags or set skip_build_metadata_tags",
+			len(c.Tags), added, rules.MaxTags))
+	}
+	return errs
+}
+
+// BuildTags returns the tags to apply: the build metadata tags, unless they
+// are skipped, overridden by the tags set by the user.
+func (c *Config) BuildTags(m BuildMetadata, rules Rules) map[string]string {
+	tags := map[string]string{}
+	if !c.SkipBuildMetadataTags {
+		for k, v := range Normalize(m.Tags(), rules) {
+			tags[k] = v
+		}
+	}
+	for k, v := range c.Tags {
+		tags[k] = v
+	}
+	return tags
+}
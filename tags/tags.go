@@ -0,0 +1,192 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package tags normalizes and validates the tags, or labels, that builders
+// apply to the resources and artifacts they create, and adds the standard
+// build metadata tags to them, so that every cloud builder handles tags the
+// same way.
+package tags
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules are the constraints a cloud puts on tags. Zero values mean no
+// constraint.
+type Rules struct {
+	// MaxTags is the maximum number of tags of a resource.
+	MaxTags int
+	// MaxKeyLength and MaxValueLength are counted in characters.
+	MaxKeyLength   int
+	MaxValueLength int
+	// ValidChar tells whether a character is allowed in keys and values;
+	// all characters are allowed when it is nil.
+	ValidChar func(r rune) bool
+	// ReservedPrefixes are prefixes, compared case insensitively, that
+	// keys must not start with.
+	ReservedPrefixes []string
+	// LowerCase is set when keys and values must be lower case.
+	LowerCase bool
+}
+
+var (
+	// AWS are the rules of AWS tags.
+	AWS = Rules{
+		MaxTags:          50,
+		MaxKeyLength:     128,
+		MaxValueLength:   256,
+		ReservedPrefixes: []string{"aws:"},
+	}
+
+	// Azure are the rules of Azure tags.
+	Azure = Rules{
+		MaxTags:        50,
+		MaxKeyLength:   512,
+		MaxValueLength: 256,
+		ValidChar: func(r rune) bool {
+			return !strings.ContainsRune(`<>%&\?/`, r)
+		},
+		ReservedPrefixes: []string{"azure", "microsoft", "windows"},
+	}
+
+	// GCP are the rules of Google Cloud labels.
+	GCP = Rules{
+		MaxTags:        64,
+		MaxKeyLength:   63,
+		MaxValueLength: 63,
+		ValidChar: func(r rune) bool {
+			return unicode.IsLower(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+		},
+		LowerCase: true,
+	}
+)
+
+// replacement replaces the characters forbidden by the rules.
+const replacement = '_'
+
+// Normalize returns tags adjusted to the rules: lower cased if needed, with
+// forbidden characters replaced by underscores and truncated to the maximum
+// lengths. When two keys end up the same, the value of the first key in
+// lexical order is kept. Reserved prefixes and the number of tags are left
+// for Validate to report.
+func Normalize(tags map[string]string, rules Rules) map[string]string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make(map[string]string, len(tags))
+	for _, k := range keys {
+		key := rules.normalize(k, rules.MaxKeyLength)
+		if _, found := res[key]; found {
+			continue
+		}
+		res[key] = rules.normalize(tags[k], rules.MaxValueLength)
+	}
+	return res
+}
+
+func (r Rules) normalize(s string, maxLength int) string {
+	if r.LowerCase {
+		s = strings.ToLower(s)
+	}
+	if r.ValidChar != nil {
+		s = strings.Map(func(c rune) rune {
+			if r.ValidChar(c) {
+				return c
+			}
+			return replacement
+		}, s)
+	}
+	if maxLength > 0 && utf8.RuneCountInString(s) > maxLength {
+		s = string([]rune(s)[:maxLength])
+	}
+	return s
+}
+
+// Validate returns an error for each tag that doesn't follow the rules, for
+// Prepare to report. Keys and values are reported in lexical order.
+func Validate(tags map[string]string, rules Rules) []error {
+	var errs []error
+	if rules.MaxTags > 0 && len(tags) > rules.MaxTags {
+		errs = append(errs, fmt.Errorf("%d tags are set, at most %d are allowed", len(tags), rules.MaxTags))
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "" {
+			errs = append(errs, fmt.Errorf("tag keys must not be empty"))
+			continue
+		}
+		errs = append(errs, rules.validate(fmt.Sprintf("tag key %q", k), k, rules.MaxKeyLength)...)
+		errs = append(errs, rules.validate(fmt.Sprintf("value of tag %q", k), tags[k], rules.MaxValueLength)...)
+		for _, prefix := range rules.ReservedPrefixes {
+			if strings.HasPrefix(strings.ToLower(k), strings.ToLower(prefix)) {
+				errs = append(errs, fmt.Errorf("tag key %q must not start with the reserved prefix %q", k, prefix))
+			}
+		}
+	}
+	return errs
+}
+
+func (r Rules) validate(what, s string, maxLength int) []error {
+	var errs []error
+	if maxLength > 0 && utf8.RuneCountInString(s) > maxLength {
+		errs = append(errs, fmt.Errorf("%s is longer than %d characters", what, maxLength))
+	}
+	if r.LowerCase && strings.ToLower(s) != s {
+		errs = append(errs, fmt.Errorf("%s must be lower case", what))
+	}
+	if r.ValidChar != nil {
+		for _, c := range s {
+			if !r.ValidChar(c) && !(r.LowerCase && unicode.IsUpper(c)) {
+				errs = append(errs, fmt.Errorf("%s contains the forbidden character %q", what, c))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// The keys of the build metadata tags.
+const (
+	BuildNameKey      = "packer_build_name"
+	TemplateDigestKey = "packer_template_digest"
+	BuildTimeKey      = "packer_build_time"
+)
+
+// BuildMetadata describes the build tagging the resources.
+type BuildMetadata struct {
+	BuildName string
+	// TemplateDigest is a digest of the template, for example the one
+	// computed by manifest.InputsDigest.
+	TemplateDigest string
+	Timestamp      time.Time
+}
+
+// Tags returns the build metadata tags. The timestamp is in seconds since
+// the Unix epoch so that it is a valid value on every cloud.
+func (m BuildMetadata) Tags() map[string]string {
+	tags := map[string]string{}
+	if m.BuildName != "" {
+		tags[BuildNameKey] = m.BuildName
+	}
+	if m.TemplateDigest != "" {
+		tags[TemplateDigestKey] = m.TemplateDigest
+	}
+	if !m.Timestamp.IsZero() {
+		tags[BuildTimeKey] = strconv.FormatInt(m.Timestamp.Unix(), 10)
+	}
+	return tags
+}
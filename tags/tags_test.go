@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tags
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalize(t *testing.T) {
+	tags := map[string]string{
+		"Name":      "My Image",
+		"name":      "duplicate",
+		"team/team": strings.Repeat("x", 70),
+	}
+	expected := map[string]string{
+		"name":      "my_image",
+		"team_team": strings.Repeat("x", 63),
+	}
+	if diff := cmp.Diff(expected, Normalize(tags, GCP)); diff != "" {
+		t.Fatalf("unexpected tags: %s", diff)
+	}
+
+	// AWS allows anything that isn't too long.
+	if diff := cmp.Diff(map[string]string{"Name": "My Image"}, Normalize(map[string]string{"Name": "My Image"}, AWS)); diff != "" {
+		t.Fatalf("unexpected tags: %s", diff)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	errs := Validate(map[string]string{
+		"aws:created": "x",
+		"Owner":       strings.Repeat("x", 257),
+	}, AWS)
+	expected := []string{
+		`value of tag "Owner" is longer than 256 characters`,
+		`tag key "aws:created" must not start with the reserved prefix "aws:"`,
+	}
+	if diff := cmp.Diff(expected, errorStrings(errs)); diff != "" {
+		t.Fatalf("unexpected errors: %s", diff)
+	}
+
+	errs = Validate(map[string]string{"Env": "a&b"}, Azure)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "forbidden character '&'") {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	errs = Validate(map[string]string{"Env": "prod"}, GCP)
+	if diff := cmp.Diff([]string{`tag key "Env" must be lower case`}, errorStrings(errs)); diff != "" {
+		t.Fatalf("unexpected errors: %s", diff)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := &Config{Tags: map[string]string{"Owner": "Team A", BuildNameKey: "custom"}}
+	if errs := c.Prepare(GCP); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	m := BuildMetadata{
+		BuildName:      "ubuntu",
+		TemplateDigest: "sha256:abc",
+		Timestamp:      time.Unix(1700000000, 0),
+	}
+	expected := map[string]string{
+		"owner":           "team_a",
+		BuildNameKey:      "custom",
+		TemplateDigestKey: "sha256_abc",
+		BuildTimeKey:      "1700000000",
+	}
+	if diff := cmp.Diff(expected, c.BuildTags(m, GCP)); diff != "" {
+		t.Fatalf("unexpected tags: %s", diff)
+	}
+
+	c.SkipBuildMetadataTags = true
+	if diff := cmp.Diff(map[string]string{"owner": "team_a", BuildNameKey: "custom"}, c.BuildTags(m, GCP)); diff != "" {
+		t.Fatalf("unexpected tags: %s", diff)
+	}
+
+	// The build metadata tags count against the limit.
+	c = &Config{Tags: map[string]string{}}
+	for i := 0; i < AWS.MaxTags-1; i++ {
+		c.Tags[fmt.Sprintf("tag%d", i)] = "x"
+	}
+	if errs := c.Prepare(AWS); len(errs) != 1 {
+		t.Fatalf("expected the number of tags to be reported, got %v", errs)
+	}
+}
+
+func errorStrings(errs []error) []string {
+	var res []string
+	for _, err := range errs {
+		res = append(res, err.Error())
+	}
+	return res
+}
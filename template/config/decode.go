@@ -21,6 +21,31 @@ import (
 	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
+// provenance is implemented by the marks telling where the values of the
+// configuration come from, like the *hcl2helper.Provenance of the values
+// decoded by hcl2helper.DecodeBody.
+type provenance interface {
+	ProvenanceString() string
+}
+
+// provenanceAt describes where the value at path, or the closest value
+// containing it, comes from, according to the marks of pvm, or returns "".
+func provenanceAt(pvm []cty.PathValueMarks, path cty.Path) string {
+	found := ""
+	longest := -1
+	for _, m := range pvm {
+		if len(m.Path) <= longest || len(m.Path) > len(path) || !path[:len(m.Path)].Equals(m.Path) {
+			continue
+		}
+		for mark := range m.Marks {
+			if p, ok := mark.(provenance); ok {
+				found, longest = p.ProvenanceString(), len(m.Path)
+			}
+		}
+	}
+	return found
+}
+
 // DecodeOpts are the options for decoding configuration.
 type DecodeOpts struct {
 	// Metadata, if non-nil, will be set to the metadata post-decode
@@ -40,6 +65,11 @@ type DecodeOpts struct {
 	PluginType string
 
 	DecodeHooks []mapstructure.DecodeHookFunc
+
+	// Migrated, if non-nil, will be set to the descriptions of the
+	// migrations registered for PluginType that were applied, so that the
+	// plugin can tell users to update their configuration.
+	Migrated *[]string
 }
 
 var DefaultDecodeHookFuncs = []mapstructure.DecodeHookFunc{
@@ -65,10 +95,14 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 		}
 		ctarget := target.(flatConfigurer)
 		flatCfg := ctarget.FlatMapstructure()
+		cval, pvm := cval.UnmarkDeepWithPaths()
 		err := gocty.FromCtyValue(cval, flatCfg)
 		if err != nil {
 			switch err := err.(type) {
 			case cty.PathError:
+				if p := provenanceAt(pvm, err.Path); p != "" {
+					return fmt.Errorf("%v: %v (%s)", err, err.Path, p)
+				}
 				return fmt.Errorf("%v: %v", err, err.Path)
 			}
 			return err
@@ -102,6 +136,31 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 		config = &DecodeOpts{Interpolate: true}
 	}
 
+	// Upgrade configurations written for older versions of the plugin
+	if ms := Migrations(config.PluginType); config.PluginType != "" && len(ms) > 0 {
+		var migrated []string
+		for i, raw := range raws {
+			m, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			// Work on a copy, the raw configuration belongs to the caller.
+			cp := make(map[string]interface{}, len(m))
+			for k, v := range m {
+				cp[k] = v
+			}
+			applied, err := MigrateRaw(cp, ms)
+			if err != nil {
+				return err
+			}
+			migrated = append(migrated, applied...)
+			raws[i] = cp
+		}
+		if config.Migrated != nil {
+			*config.Migrated = migrated
+		}
+	}
+
 	// Detect user variables from the raws and merge them into our context
 	ctxData, raws := DetectContextData(raws...)
 
@@ -214,6 +273,8 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 		}
 	}
 
+	registerSensitive(target)
+
 	// Set the metadata if it is set
 	if config.Metadata != nil {
 		*config.Metadata = md
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestDecode(t *testing.T) {
@@ -207,3 +208,90 @@ func TestDecode_fixerRecommendations(t *testing.T) {
 		}
 	}
 }
+
+func TestDecode_sensitive(t *testing.T) {
+	type Credentials struct {
+		User     string
+		Password string `sensitive:"true"`
+	}
+	type TestConfig struct {
+		Name        string
+		Token       string            `mapstructure:"token" sensitive:"true"`
+		Credentials *Credentials      `mapstructure:"credentials"`
+		Headers     map[string]string `mapstructure:"headers" sensitive:"true"`
+		Unset       string            `sensitive:"true"`
+	}
+
+	var registered []string
+	RegisterSecretFilter(func(secrets ...string) { registered = append(registered, secrets...) })
+	defer RegisterSecretFilter(nil)
+
+	var result TestConfig
+	err := Decode(&result, nil, map[string]interface{}{
+		"name":        "bar",
+		"token":       "t0k3n",
+		"credentials": map[string]interface{}{"user": "root", "password": "hunter2"},
+		"headers":     map[string]interface{}{"X-Auth": "s3cr3t"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{"t0k3n", "hunter2", "s3cr3t"}
+	if !reflect.DeepEqual(registered, expected) {
+		t.Fatalf("expected %q to be registered, got %q", expected, registered)
+	}
+}
+
+func TestSensitiveValues_cycle(t *testing.T) {
+	type Node struct {
+		Name     string `sensitive:"true"`
+		Parent   *Node
+		Children []*Node
+		Extra    map[string]interface{}
+	}
+	root := &Node{Name: "root-secret", Extra: map[string]interface{}{}}
+	child := &Node{Name: "child-secret", Parent: root}
+	root.Children = []*Node{child}
+	root.Extra["self"] = root.Extra
+
+	secrets := SensitiveValues(root)
+	expected := []string{"root-secret", "child-secret"}
+	if !reflect.DeepEqual(secrets, expected) {
+		t.Fatalf("expected %q, got %q", expected, secrets)
+	}
+}
+
+type testProvenance string
+
+func (p testProvenance) ProvenanceString() string { return string(p) }
+
+func TestDecode_provenance(t *testing.T) {
+	raw := cty.ObjectVal(map[string]cty.Value{
+		"key":   cty.ListValEmpty(cty.String),
+		"value": cty.StringVal("v"),
+	}).MarkWithPaths([]cty.PathValueMarks{{
+		Path:  cty.GetAttrPath("key"),
+		Marks: cty.NewValueMarks(testProvenance("from var.key at build.pkr.hcl:3")),
+	}})
+
+	var result KeyValue
+	err := Decode(&result, nil, raw)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "(from var.key at build.pkr.hcl:3)") {
+		t.Fatalf("error doesn't tell where the value comes from: %s", err)
+	}
+
+	// Marked values that decode are unmarked.
+	raw = cty.ObjectVal(map[string]cty.Value{
+		"key":   cty.StringVal("k"),
+		"value": cty.StringVal("v").Mark(testProvenance("at build.pkr.hcl:4")),
+	})
+	if err := Decode(&result, nil, raw); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Key != "k" || result.Value != "v" {
+		t.Fatalf("bad result: %#v", result)
+	}
+}
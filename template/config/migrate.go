@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Migration upgrades a configuration written for an older version of a
+// plugin to the shape its current version expects, so that a plugin can
+// rename or restructure options without breaking existing templates.
+type Migration struct {
+	// Version of the configuration the migration upgrades to. Migrations are
+	// applied in increasing Version order.
+	Version int
+	// Description tells users what was changed, for example
+	// `"disk_size" is now set in the "disk" block`.
+	Description string
+	// Needed reports whether raw has the shape that the migration upgrades.
+	Needed func(raw map[string]interface{}) bool
+	// Migrate upgrades raw in place.
+	Migrate func(raw map[string]interface{}) error
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[string][]Migration{}
+)
+
+// RegisterMigrations registers the configuration migrations of the plugin
+// with the given type, the same one passed as DecodeOpts.PluginType. It is
+// usually called from an init function.
+func RegisterMigrations(pluginType string, ms ...Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	registered := append(migrations[pluginType], ms...)
+	sort.SliceStable(registered, func(i, j int) bool {
+		return registered[i].Version < registered[j].Version
+	})
+	migrations[pluginType] = registered
+}
+
+// Migrations returns the migrations registered for pluginType.
+func Migrations(pluginType string) []Migration {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	return append([]Migration(nil), migrations[pluginType]...)
+}
+
+// MigrateRaw applies the migrations that raw needs, in order, and returns a
+// description of each migration applied.
+func MigrateRaw(raw map[string]interface{}, ms []Migration) ([]string, error) {
+	var applied []string
+	for _, m := range ms {
+		if m.Needed != nil && !m.Needed(raw) {
+			continue
+		}
+		if err := m.Migrate(raw); err != nil {
+			return applied, fmt.Errorf("Error migrating configuration to version %d (%s): %s",
+				m.Version, m.Description, err)
+		}
+		log.Printf("[WARN] Configuration migrated to version %d: %s", m.Version, m.Description)
+		applied = append(applied, m.Description)
+	}
+	return applied, nil
+}
+
+// RenameMigration returns a Migration for an option renamed from oldKey to
+// newKey. Setting both is an error.
+func RenameMigration(version int, oldKey, newKey string) Migration {
+	return Migration{
+		Version:     version,
+		Description: fmt.Sprintf("%q was renamed to %q", oldKey, newKey),
+		Needed: func(raw map[string]interface{}) bool {
+			_, ok := raw[oldKey]
+			return ok
+		},
+		Migrate: func(raw map[string]interface{}) error {
+			if _, ok := raw[newKey]; ok {
+				return fmt.Errorf("%q and %q cannot both be set", oldKey, newKey)
+			}
+			raw[newKey] = raw[oldKey]
+			delete(raw, oldKey)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode_migrations(t *testing.T) {
+	type Disk struct {
+		Size int `mapstructure:"size"`
+	}
+	type Target struct {
+		SSHTimeout string `mapstructure:"ssh_timeout"`
+		Disk       Disk   `mapstructure:"disk"`
+	}
+
+	pluginType := "test.migrations"
+	RegisterMigrations(pluginType,
+		Migration{
+			Version:     3,
+			Description: `"disk_size" is now set in the "disk" block`,
+			Needed: func(raw map[string]interface{}) bool {
+				_, ok := raw["disk_size"]
+				return ok
+			},
+			Migrate: func(raw map[string]interface{}) error {
+				raw["disk"] = map[string]interface{}{"size": raw["disk_size"]}
+				delete(raw, "disk_size")
+				return nil
+			},
+		},
+		RenameMigration(2, "ssh_wait_timeout", "ssh_timeout"),
+	)
+
+	raw := map[string]interface{}{
+		"ssh_wait_timeout": "5m",
+		"disk_size":        40960,
+	}
+	var result Target
+	var migrated []string
+	err := Decode(&result, &DecodeOpts{PluginType: pluginType, Migrated: &migrated}, raw)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := Target{SSHTimeout: "5m", Disk: Disk{Size: 40960}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	expectedMigrated := []string{
+		`"ssh_wait_timeout" was renamed to "ssh_timeout"`,
+		`"disk_size" is now set in the "disk" block`,
+	}
+	if !reflect.DeepEqual(migrated, expectedMigrated) {
+		t.Fatalf("unexpected migrations: %#v", migrated)
+	}
+	if _, ok := raw["ssh_wait_timeout"]; !ok {
+		t.Fatal("the raw configuration of the caller should not be modified")
+	}
+
+	// Up to date configurations are left as is.
+	migrated = nil
+	err = Decode(&result, &DecodeOpts{PluginType: pluginType, Migrated: &migrated},
+		map[string]interface{}{"ssh_timeout": "1m"})
+	if err != nil || len(migrated) != 0 {
+		t.Fatalf("nothing should be migrated, got %v, %v", err, migrated)
+	}
+
+	err = Decode(&result, &DecodeOpts{PluginType: pluginType},
+		map[string]interface{}{"ssh_timeout": "1m", "ssh_wait_timeout": "5m"})
+	if err == nil || !strings.Contains(err.Error(), "cannot both be set") {
+		t.Fatalf("expected a migration error, got: %v", err)
+	}
+}
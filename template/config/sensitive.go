@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// SensitiveTag is the struct tag marking the configuration fields holding
+// secrets, with the value "true". When Decode sets such a field, its value is
+// registered with the secret filter, so that it never shows in the logs or
+// the Ui. Every string in the field counts: the elements of a slice or a map,
+// and the fields of a struct.
+const SensitiveTag = "sensitive"
+
+var secretFilter struct {
+	sync.Mutex
+	set func(secrets ...string)
+}
+
+// RegisterSecretFilter sets the function the sensitive values decoded by
+// Decode are registered with. The packer package registers its
+// LogSecretFilter, which can't be referred to from here without an import
+// cycle.
+func RegisterSecretFilter(set func(secrets ...string)) {
+	secretFilter.Lock()
+	defer secretFilter.Unlock()
+	secretFilter.set = set
+}
+
+// registerSensitive registers the sensitive values of the decoded target.
+func registerSensitive(target interface{}) {
+	secrets := SensitiveValues(target)
+	if len(secrets) == 0 {
+		return
+	}
+	secretFilter.Lock()
+	set := secretFilter.set
+	secretFilter.Unlock()
+	if set != nil {
+		set(secrets...)
+	}
+}
+
+// SensitiveValues returns the non-empty strings in the fields of config
+// tagged with SensitiveTag.
+func SensitiveValues(config interface{}) []string {
+	w := sensitiveWalker{visited: map[visit]bool{}}
+	w.walk(reflect.ValueOf(config), false)
+	return w.secrets
+}
+
+// visit is a pointer or a map walked through. The type tells apart a struct
+// and its first field, which have the same address, and a value shared by a
+// sensitive field and another one is walked through for each.
+type visit struct {
+	ptr       uintptr
+	typ       reflect.Type
+	sensitive bool
+}
+
+type sensitiveWalker struct {
+	secrets []string
+	// visited are the pointers and maps already walked through, so that
+	// values referring to themselves, like a parent pointer, are walked
+	// once.
+	visited map[visit]bool
+}
+
+// seen tells whether v, a pointer or a map, was already walked through,
+// marking it as such.
+func (w *sensitiveWalker) seen(v reflect.Value, sensitive bool) bool {
+	k := visit{ptr: v.Pointer(), typ: v.Type(), sensitive: sensitive}
+	if w.visited[k] {
+		return true
+	}
+	w.visited[k] = true
+	return false
+}
+
+func (w *sensitiveWalker) walk(v reflect.Value, sensitive bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() && !w.seen(v, sensitive) {
+			w.walk(v.Elem(), sensitive)
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			w.walk(v.Elem(), sensitive)
+		}
+	case reflect.String:
+		if sensitive && v.Len() > 0 {
+			w.secrets = append(w.secrets, v.String())
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			if sensitive && v.Len() > 0 {
+				w.secrets = append(w.secrets, string(v.Bytes()))
+			}
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			w.walk(v.Index(i), sensitive)
+		}
+	case reflect.Map:
+		if v.IsNil() || w.seen(v, sensitive) {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			w.walk(iter.Value(), sensitive)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			w.walk(v.Field(i), sensitive || strings.EqualFold(field.Tag.Get(SensitiveTag), "true"))
+		}
+	}
+}
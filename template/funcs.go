@@ -4,14 +4,19 @@
 package template
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
 
 	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
 	awssmapi "github.com/hashicorp/packer-plugin-sdk/template/interpolate/aws/secretsmanager"
 	vaultapi "github.com/hashicorp/vault/api"
 )
@@ -119,3 +124,49 @@ func GetAWSSecret(name, key string) (string, error) {
 
 	return client.GetSecret(spec)
 }
+
+// HTTPMaxSize is the largest document HTTP accepts to fetch, in bytes.
+const HTTPMaxSize = 1 << 20
+
+// NewHTTPClient returns the client HTTP fetches documents with. Plugins
+// configured with a net.ProxyConfig can set it to its HTTPClient method for
+// the template functions to use the same proxies as the rest of the plugin.
+var NewHTTPClient = retry.NewHTTPClient
+
+// HTTP fetches a small document from url and returns its content. The
+// SHA-256 checksum of the document, as hex optionally prefixed by
+// "sha256:", is required so that a template always builds from the same
+// inputs. Requests are retried on transient failures.
+func HTTP(url string, checksum string) (string, error) {
+	want := strings.ToLower(strings.TrimPrefix(checksum, "sha256:"))
+	if len(want) != 2*sha256.Size {
+		return "", fmt.Errorf("a sha256 checksum of %d hex characters is required to fetch %s, got %q",
+			2*sha256.Size, url, checksum)
+	}
+	if _, err := hex.DecodeString(want); err != nil {
+		return "", fmt.Errorf("invalid sha256 checksum %q: %s", checksum, err)
+	}
+
+	resp, err := NewHTTPClient().Get(url)
+	if err != nil {
+		return "", fmt.Errorf("error fetching %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching %s: %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, HTTPMaxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %s", url, err)
+	}
+	if len(body) > HTTPMaxSize {
+		return "", fmt.Errorf("%s is larger than %d bytes", url, HTTPMaxSize)
+	}
+
+	sum := sha256.Sum256(body)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return "", fmt.Errorf("checksum mismatch for %s: expected sha256:%s, got sha256:%s", url, want, got)
+	}
+	return string(body), nil
+}
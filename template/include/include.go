@@ -0,0 +1,224 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package include resolves the paths of the files templates include, like
+// scripts, `cd_files` or `http_directory` files, so that every step and
+// template function resolves `./files/...` the same way: relative to a
+// root directory, optionally confined to it, with glob patterns where `**`
+// matches any number of directories.
+package include
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Resolver resolves the paths of included files. The zero Resolver
+// resolves relative paths from the current directory, like the steps always
+// did.
+type Resolver struct {
+	// Root is the directory relative paths are resolved from, the current
+	// directory when empty.
+	Root string
+	// Confined rejects the paths leaving Root, symbolic links included, so
+	// that templates can't read files they are not shipped with.
+	Confined bool
+}
+
+// ForTemplate returns a Resolver confined to the directory of the template
+// at templatePath, like the `file` and `fileset` template functions.
+func ForTemplate(templatePath string) (*Resolver, error) {
+	if templatePath == "" {
+		return nil, fmt.Errorf("template path not available")
+	}
+	dir, err := filepath.Abs(filepath.Dir(templatePath))
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{Root: dir, Confined: true}, nil
+}
+
+// Path resolves path, relative to Root unless absolute. Confined resolvers
+// return the real path, without symbolic links, and fail when it is outside
+// of Root or doesn't exist.
+func (r *Resolver) Path(path string) (string, error) {
+	if !filepath.IsAbs(path) && r.Root != "" {
+		path = filepath.Join(r.Root, path)
+	}
+	path = filepath.Clean(path)
+	if !r.Confined {
+		return path, nil
+	}
+
+	root := r.Root
+	if root == "" {
+		root = "."
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !within(realRoot, realPath) {
+		return "", fmt.Errorf("%s is outside of the template directory %s", path, root)
+	}
+	return realPath, nil
+}
+
+// within tells whether path is dir or in it.
+func within(dir, path string) bool {
+	absDir, err1 := filepath.Abs(dir)
+	absPath, err2 := filepath.Abs(path)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// IsGlob tells whether path is a glob pattern rather than a path.
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// Glob resolves pattern like Path, and returns the files and directories it
+// matches, sorted. `**` matches any number of directories. Paths that are
+// not patterns are returned resolved, with an error when they don't exist.
+func (r *Resolver) Glob(pattern string) ([]string, error) {
+	if !IsGlob(pattern) {
+		path, err := r.Path(pattern)
+		if err != nil {
+			return []string{pattern}, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return []string{path}, err
+		}
+		return []string{path}, nil
+	}
+
+	// Walk from the longest directory without patterns.
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(parts)-1 && !IsGlob(parts[i]) {
+		i++
+	}
+	base := strings.Join(parts[:i], "/")
+	if base == "" && strings.HasPrefix(pattern, "/") {
+		base = "/"
+	} else if base == "" {
+		base = "."
+	}
+	rest := parts[i:]
+	for _, part := range rest {
+		if part == ".." {
+			return nil, fmt.Errorf("pattern %q can't go up a directory after a wildcard", pattern)
+		}
+		if _, err := filepath.Match(part, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", pattern, err)
+		}
+	}
+	root, err := r.Path(filepath.FromSlash(base))
+	if err != nil {
+		return nil, err
+	}
+	rels, err := match(root, rest, true)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, rel := range rels {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if r.Confined {
+			// Symbolic links may lead out of the root.
+			if _, err := r.Path(p); err != nil {
+				continue
+			}
+		}
+		matches = append(matches, p)
+	}
+	return matches, nil
+}
+
+// Fileset returns the files under dir matching pattern, relative to dir and
+// with slashes, sorted, like the `fileset` function of HCL templates.
+func (r *Resolver) Fileset(dir, pattern string) ([]string, error) {
+	root, err := r.Path(dir)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	for _, part := range parts {
+		if part == ".." {
+			return nil, fmt.Errorf("fileset pattern %q leaves %s", pattern, dir)
+		}
+		if _, err := filepath.Match(part, ""); err != nil {
+			return nil, fmt.Errorf("invalid fileset pattern %q: %s", pattern, err)
+		}
+	}
+	return match(root, parts, false)
+}
+
+// match returns the paths under root, relative and with slashes, matching
+// the pattern parts, sorted. Directories only match when dirs is set.
+func match(root string, pattern []string, dirs bool) ([]string, error) {
+	deep := false
+	for _, part := range pattern {
+		deep = deep || part == "**"
+	}
+	var matches []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		parts := strings.Split(rel, "/")
+		if (dirs || !d.IsDir()) && matchParts(pattern, parts) {
+			matches = append(matches, rel)
+		}
+		if d.IsDir() && !deep && len(parts) >= len(pattern) {
+			// Without `**`, nothing deeper can match.
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func matchParts(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchParts(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+		return false
+	}
+	return matchParts(pattern[1:], path[1:])
+}
@@ -0,0 +1,161 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package include
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testTree creates a template directory with files, and a secret outside of
+// it, and returns the directory.
+func testTree(t *testing.T) string {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "template")
+	for _, name := range []string{
+		"template/template.pkr.json",
+		"template/files/install.sh",
+		"template/files/readme.txt",
+		"template/files/nested/deep/setup.sh",
+		"secret",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "files", "leak.sh")); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	return root
+}
+
+func TestResolver_Path(t *testing.T) {
+	root := testTree(t)
+	r, err := ForTemplate(filepath.Join(root, "template.pkr.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	realRoot, _ := filepath.EvalSymlinks(root)
+
+	path, err := r.Path("./files/../files/install.sh")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if want := filepath.Join(realRoot, "files", "install.sh"); path != want {
+		t.Fatalf("bad path %q, expected %q", path, want)
+	}
+
+	for _, p := range []string{"../secret", filepath.Join(root, "..", "secret"), "files/missing.sh"} {
+		if _, err := r.Path(p); err == nil {
+			t.Errorf("%s should not resolve", p)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if _, err := r.Path("files/leak.sh"); err == nil {
+			t.Error("a symbolic link should not lead out of the template directory")
+		}
+	}
+
+	// Unconfined resolvers resolve anything.
+	free := &Resolver{Root: root}
+	if path, err := free.Path("../secret"); err != nil || path != filepath.Join(filepath.Dir(root), "secret") {
+		t.Fatalf("bad path %q: %v", path, err)
+	}
+}
+
+func TestResolver_Glob(t *testing.T) {
+	root := testTree(t)
+	r, err := ForTemplate(filepath.Join(root, "template.pkr.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	realRoot, _ := filepath.EvalSymlinks(root)
+	abs := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(realRoot, filepath.FromSlash(name)))
+		}
+		return paths
+	}
+
+	tests := map[string][]string{
+		"files/*.sh":    abs("files/install.sh"),
+		"files/**/*.sh": abs("files/install.sh", "files/nested/deep/setup.sh"),
+		"files/n*":      abs("files/nested"),
+		"*/*.txt":       abs("files/readme.txt"),
+		"files/*.iso":   nil,
+	}
+	for pattern, want := range tests {
+		got, err := r.Glob(pattern)
+		if err != nil {
+			t.Fatalf("%s: %s", pattern, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s: unexpected matches: %s", pattern, diff)
+		}
+	}
+
+	if got, err := r.Glob("files/readme.txt"); err != nil || len(got) != 1 {
+		t.Fatalf("bad matches %q: %v", got, err)
+	}
+	for _, pattern := range []string{"files/missing.txt", "../*", "files/*/../../*", "files/[.sh"} {
+		if _, err := r.Glob(pattern); err == nil {
+			t.Errorf("%s should fail", pattern)
+		}
+	}
+}
+
+func TestResolver_Glob_relative(t *testing.T) {
+	root := testTree(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(filepath.Join(root, "files")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(wd)
+
+	got, err := new(Resolver).Glob("*.sh")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := []string{"install.sh"}
+	if runtime.GOOS != "windows" {
+		want = []string{"install.sh", "leak.sh"}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("the matches should stay relative: %s", diff)
+	}
+}
+
+func TestResolver_Fileset(t *testing.T) {
+	root := testTree(t)
+	r := &Resolver{Root: root, Confined: true}
+
+	got, err := r.Fileset("files", "**/*.sh")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := []string{"install.sh", "nested/deep/setup.sh"}
+	if runtime.GOOS != "windows" {
+		want = []string{"install.sh", "leak.sh", "nested/deep/setup.sh"}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected files: %s", diff)
+	}
+	if _, err := r.Fileset("files", "../*"); err == nil {
+		t.Fatal("fileset patterns should not go up")
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	commontpl "github.com/hashicorp/packer-plugin-sdk/template"
+	"github.com/hashicorp/packer-plugin-sdk/template/include"
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
 	strftime "github.com/jehiah/go-strftime"
 )
@@ -38,15 +39,19 @@ var FuncGens = map[string]interface{}{
 	"pwd":                funcGenPwd,
 	"split":              funcGenSplitter,
 	"template_dir":       funcGenTemplateDir,
+	"file":               funcGenFile,
+	"fileset":            funcGenFileset,
 	"timestamp":          funcGenTimestamp,
 	"uuid":               funcGenUuid,
 	"user":               funcGenUser,
 	"packer_version":     funcGenPackerVersion,
 	"consul_key":         funcGenConsul,
 	"vault":              funcGenVault,
+	"http":               funcGenHTTP,
 	"sed":                funcGenSed,
 	"build":              funcGenBuild,
 	"aws_secretsmanager": funcGenAwsSecrets,
+	"keychain":           funcGenKeychain,
 
 	"replace":     replace,
 	"replace_all": replace_all,
@@ -62,7 +67,8 @@ var ErrVariableNotSetString = "Error: variable not set:"
 type FuncGenerator func(*Context) interface{}
 
 // Funcs returns the functions that can be used for interpolation given
-// a context.
+// a context. The functions its FuncPolicy or the one of the operator forbid
+// return a *FuncPolicyError.
 func Funcs(ctx *Context) template.FuncMap {
 	result := make(map[string]interface{})
 	for k, v := range FuncGens {
@@ -78,6 +84,12 @@ func Funcs(ctx *Context) template.FuncMap {
 			result[k] = v
 		}
 	}
+	env := FuncPolicyFromEnv()
+	for k := range result {
+		if !funcAllowed(ctx, env, k) {
+			result[k] = deniedFunc(k)
+		}
+	}
 
 	return template.FuncMap(result)
 }
@@ -153,16 +165,61 @@ func funcGenPwd(ctx *Context) interface{} {
 
 func funcGenTemplateDir(ctx *Context) interface{} {
 	return func() (string, error) {
-		if ctx == nil || ctx.TemplatePath == "" {
-			return "", errors.New("template path not available")
-		}
+		return templateDir(ctx)
+	}
+}
 
-		path, err := filepath.Abs(filepath.Dir(ctx.TemplatePath))
+func templateDir(ctx *Context) (string, error) {
+	if ctx == nil || ctx.TemplatePath == "" {
+		return "", errors.New("template path not available")
+	}
+
+	return filepath.Abs(filepath.Dir(ctx.TemplatePath))
+}
+
+// templateDirPath resolves path relative to the template directory and
+// makes sure that it does not leave it, symbolic links included.
+func templateDirPath(ctx *Context, path string) (string, error) {
+	r, err := templateResolver(ctx)
+	if err != nil {
+		return "", err
+	}
+	return r.Path(path)
+}
+
+// templateResolver returns the resolver of the files of the template,
+// confined to its directory.
+func templateResolver(ctx *Context) (*include.Resolver, error) {
+	if ctx == nil {
+		return nil, errors.New("template path not available")
+	}
+	return include.ForTemplate(ctx.TemplatePath)
+}
+
+func funcGenFile(ctx *Context) interface{} {
+	return func(path string) (string, error) {
+		path, err := templateDirPath(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		b, err := os.ReadFile(path)
 		if err != nil {
 			return "", err
 		}
+		return string(b), nil
+	}
+}
 
-		return path, nil
+// funcGenFileset returns the files under path matching pattern, relative to
+// path and sorted. As in HCL templates, `**` in pattern matches any number
+// of directories.
+func funcGenFileset(ctx *Context) interface{} {
+	return func(path string, pattern string) ([]string, error) {
+		r, err := templateResolver(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return r.Fileset(path, pattern)
 	}
 }
 
@@ -278,6 +335,18 @@ func funcGenVault(ctx *Context) interface{} {
 	}
 }
 
+func funcGenHTTP(ctx *Context) interface{} {
+	return func(url string, checksum string) (string, error) {
+		if !ctx.EnableEnv {
+			// The error message doesn't have to be that detailed since
+			// semantic checks should catch this.
+			return "", errors.New("http is only allowed in the variables section")
+		}
+
+		return commontpl.HTTP(url, checksum)
+	}
+}
+
 func funcGenAwsSecrets(ctx *Context) interface{} {
 	return func(secret ...string) (string, error) {
 		if !ctx.EnableEnv {
@@ -298,6 +367,24 @@ func funcGenAwsSecrets(ctx *Context) interface{} {
 	}
 }
 
+func funcGenKeychain(ctx *Context) interface{} {
+	return func(service string, account ...string) (string, error) {
+		if !ctx.EnableEnv {
+			// The error message doesn't have to be that detailed since
+			// semantic checks should catch this.
+			return "", errors.New("keychain is only allowed in the variables section")
+		}
+		switch len(account) {
+		case 0:
+			return commontpl.Keychain(service, "")
+		case 1:
+			return commontpl.Keychain(service, account[0])
+		default:
+			return "", errors.New("only a service and an optional account can be provided")
+		}
+	}
+}
+
 func funcGenSed(ctx *Context) interface{} {
 	return func(expression string, inputString string) (string, error) {
 		return "", errors.New("template function `sed` is deprecated " +
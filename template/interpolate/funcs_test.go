@@ -4,6 +4,9 @@
 package interpolate
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -125,6 +128,60 @@ func TestFuncEnv_disable(t *testing.T) {
 	}
 }
 
+func TestFuncHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			w.Write(make([]byte, 2<<20))
+			return
+		}
+		w.Write([]byte("1.2.3"))
+	}))
+	defer server.Close()
+
+	sum := "sha256:c47f5b18b8a430e698b9fe15e51f6119984e78334bcf3f45e210d30c37ef2f9e"
+	wrongSum := "sha256:e5f1dd0e9c90c2a1aa1f3b9ed15d55ff0bc7a1df5c1b1d8bcff9e68f1a81df0f"
+
+	cases := []struct {
+		Input  string
+		Output string
+		Error  bool
+	}{
+		{fmt.Sprintf(`{{http "%s/version" "%s"}}`, server.URL, sum), "1.2.3", false},
+		{fmt.Sprintf(`{{http "%s/version" "%s"}}`, server.URL, wrongSum), "", true},
+		{fmt.Sprintf(`{{http "%s/version" ""}}`, server.URL), "", true},
+		{fmt.Sprintf(`{{http "%s/large" "%s"}}`, server.URL, sum), "", true},
+	}
+
+	ctx := &Context{EnableEnv: true}
+	for _, tc := range cases {
+		i := &I{Value: tc.Input}
+		result, err := i.Render(ctx)
+		if (err != nil) != tc.Error {
+			t.Fatalf("Input: %s\n\nerr: %s", tc.Input, err)
+		}
+		if result != tc.Output {
+			t.Fatalf("Input: %s\n\nGot: %s", tc.Input, result)
+		}
+	}
+
+	i := &I{Value: cases[0].Input}
+	if _, err := i.Render(&Context{}); err == nil {
+		t.Fatal("http should only be allowed in the variables section")
+	}
+}
+
+func TestFuncKeychain(t *testing.T) {
+	i := &I{Value: `{{keychain "registry" "packer"}}`}
+	if _, err := i.Render(&Context{}); err == nil || !strings.Contains(err.Error(), "only allowed in the variables section") {
+		t.Fatalf("keychain should only be allowed in the variables section: %v", err)
+	}
+
+	i = &I{Value: `{{keychain "registry" "packer" "extra"}}`}
+	if _, err := i.Render(&Context{EnableEnv: true}); err == nil {
+		t.Fatal("keychain should take a service and an account at most")
+	}
+}
+
 func TestFuncIsotime(t *testing.T) {
 	ctx := &Context{}
 	i := &I{Value: "{{isotime}}"}
@@ -223,6 +280,59 @@ func TestFuncTemplatePath(t *testing.T) {
 	}
 }
 
+func TestFuncFile(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "template")
+	for name, content := range map[string]string{
+		"template/scripts/install.sh":       "echo install",
+		"template/scripts/win/setup.ps1":    "Write-Host setup",
+		"template/scripts/nested/deep/a.sh": "echo a",
+		"secret.txt":                        "secret",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	// Creating symbolic links may require privileges on Windows; without
+	// the link, reading it still fails.
+	_ = os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(dir, "link.txt"))
+
+	cases := []struct {
+		Input  string
+		Output string
+		Error  bool
+	}{
+		{`{{file "scripts/install.sh"}}`, "echo install", false},
+		{`{{file "../secret.txt"}}`, "", true},
+		{`{{file "link.txt"}}`, "", true},
+		{`{{file "` + filepath.ToSlash(filepath.Join(root, "secret.txt")) + `"}}`, "", true},
+		{`{{join (fileset "scripts" "*.sh") ","}}`, "install.sh", false},
+		{`{{join (fileset "scripts" "**/*.sh") ","}}`, "install.sh,nested/deep/a.sh", false},
+		{`{{join (fileset "." "scripts/*/*.ps1") ","}}`, "scripts/win/setup.ps1", false},
+		{`{{fileset ".." "*.txt"}}`, "", true},
+		{`{{fileset "scripts" "../*"}}`, "", true},
+	}
+
+	ctx := &Context{
+		TemplatePath: filepath.Join(dir, "template.json"),
+		Funcs:        map[string]interface{}{"join": strings.Join},
+	}
+	for _, tc := range cases {
+		i := &I{Value: tc.Input}
+		result, err := i.Render(ctx)
+		if (err != nil) != tc.Error {
+			t.Fatalf("Input: %s\n\nerr: %s", tc.Input, err)
+		}
+		if result != tc.Output {
+			t.Fatalf("Input: %s\n\nGot: %s", tc.Input, result)
+		}
+	}
+}
+
 func TestFuncSplit(t *testing.T) {
 	cases := []struct {
 		Input         string
@@ -476,3 +586,47 @@ func TestReplaceFuncs(t *testing.T) {
 		}
 	}
 }
+
+func TestRegisterFunc(t *testing.T) {
+	RegisterFunc("test_join", Func{
+		Arity: -1,
+		Impl: func(ctx *Context, args []string) (string, error) {
+			return ctx.BuildName + ":" + strings.Join(args, ","), nil
+		},
+	})
+	RegisterFunc("test_pair", Func{
+		Arity: 2,
+		Impl: func(_ *Context, args []string) (string, error) {
+			return args[0] + "=" + args[1], nil
+		},
+	})
+	defer delete(FuncGens, "test_join")
+	defer delete(FuncGens, "test_pair")
+
+	ctx := &Context{BuildName: "ubuntu"}
+	cases := []struct {
+		Input  string
+		Output string
+		Error  bool
+	}{
+		{`{{test_join "a" "b"}}`, "ubuntu:a,b", false},
+		{`{{test_pair "a" "b"}}`, "a=b", false},
+		{`{{test_pair "a"}}`, "", true},
+	}
+	for _, tc := range cases {
+		result, err := Render(tc.Input, ctx)
+		if (err != nil) != tc.Error {
+			t.Fatalf("Input: %s\n\nerr: %v", tc.Input, err)
+		}
+		if result != tc.Output {
+			t.Fatalf("Input: %s\n\nGot: %s", tc.Input, result)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a built-in function should panic")
+		}
+	}()
+	RegisterFunc("upper", Func{Impl: func(*Context, []string) (string, error) { return "", nil }})
+}
@@ -10,6 +10,7 @@ import (
 	"text/template"
 
 	"github.com/google/uuid"
+	"github.com/hashicorp/packer-plugin-sdk/secret"
 )
 
 // Context is the context that an interpolation is done in. This defines
@@ -31,6 +32,11 @@ type Context struct {
 	// EnableEnv enables the env function
 	EnableEnv bool
 
+	// FuncPolicy, if set, restricts the functions the interpolations can
+	// call, on top of the policy the operator sets with FuncsAllowEnv and
+	// FuncsDenyEnv.
+	FuncPolicy *FuncPolicy
+
 	// All the fields below are used for built-in functions.
 	//
 	// BuildName and BuildType are the name and type, respectively,
@@ -95,6 +101,11 @@ func RenderRegex(v string, ctx *Context, regex string) (string, error) {
 	return rendered, nil
 }
 
+// RenderSecret is shorthand for constructing an I and calling RenderSecret.
+func RenderSecret(v string, ctx *Context) (*secret.Value, error) {
+	return (&I{Value: v}).RenderSecret(ctx)
+}
+
 // Validate is shorthand for constructing an I and calling Validate.
 func Validate(v string, ctx *Context) error {
 	return (&I{Value: v}).Validate(ctx)
@@ -125,6 +136,28 @@ func (i *I) Render(ictx *Context) (string, error) {
 	return result.String(), nil
 }
 
+// RenderSecret renders the interpolation like Render, for values that are
+// secrets, like a password read from Vault: the result is returned as a
+// secret.Value, zeroed once destroyed, instead of a string.
+func (i *I) RenderSecret(ictx *Context) (*secret.Value, error) {
+	tpl, err := i.template(ictx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result bytes.Buffer
+	var data interface{}
+	if ictx != nil {
+		data = ictx.Data
+	}
+	if err := tpl.Execute(&result, data); err != nil {
+		secret.New(result.Bytes()).Destroy()
+		return nil, err
+	}
+
+	return secret.New(result.Bytes()), nil
+}
+
 // Validate validates that the template is syntactically valid.
 func (i *I) Validate(ctx *Context) error {
 	_, err := i.template(ctx)
@@ -132,5 +165,12 @@ func (i *I) Validate(ctx *Context) error {
 }
 
 func (i *I) template(ctx *Context) (*template.Template, error) {
-	return template.New("root").Funcs(Funcs(ctx)).Parse(i.Value)
+	tpl, err := template.New("root").Funcs(Funcs(ctx)).Parse(i.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFuncPolicy(ctx, tpl); err != nil {
+		return nil, err
+	}
+	return tpl, nil
 }
@@ -33,3 +33,23 @@ func TestIRender(t *testing.T) {
 		}
 	}
 }
+
+func TestIRenderSecret(t *testing.T) {
+	ctx := &Context{Data: map[string]string{"Password": "s3cr3t"}}
+	v, err := RenderSecret("{{ .Password }}", ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v.Reveal() != "s3cr3t" {
+		t.Fatalf("bad secret: %q", v.Reveal())
+	}
+	b := v.Bytes()
+	v.Destroy()
+	if string(b) != "\x00\x00\x00\x00\x00\x00" {
+		t.Fatalf("the rendered secret was not zeroed: %q", b)
+	}
+
+	if _, err := RenderSecret("{{ .Password", ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+}
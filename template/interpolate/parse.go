@@ -4,7 +4,6 @@
 package interpolate
 
 import (
-	"fmt"
 	"text/template"
 	"text/template/parse"
 )
@@ -13,7 +12,9 @@ import (
 // that are called from the given text template.
 func functionsCalled(t *template.Template) map[string]struct{} {
 	result := make(map[string]struct{})
-	functionsCalledWalk(t.Tree.Root, result)
+	if t.Tree != nil {
+		functionsCalledWalk(t.Tree.Root, result)
+	}
 	return result
 }
 
@@ -22,24 +23,44 @@ func functionsCalledWalk(raw parse.Node, r map[string]struct{}) {
 	case *parse.ActionNode:
 		functionsCalledWalk(node.Pipe, r)
 	case *parse.CommandNode:
-		if in, ok := node.Args[0].(*parse.IdentifierNode); ok {
-			r[in.Ident] = struct{}{}
-		}
-
-		for _, n := range node.Args[1:] {
+		// Identifiers are function calls wherever they are in a command,
+		// like uuid in {{printf "%s" uuid}}.
+		for _, n := range node.Args {
 			functionsCalledWalk(n, r)
 		}
+	case *parse.IdentifierNode:
+		r[node.Ident] = struct{}{}
+	case *parse.ChainNode:
+		functionsCalledWalk(node.Node, r)
 	case *parse.ListNode:
+		if node == nil {
+			return
+		}
 		for _, n := range node.Nodes {
 			functionsCalledWalk(n, r)
 		}
 	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
 		for _, n := range node.Cmds {
 			functionsCalledWalk(n, r)
 		}
-	case *parse.StringNode, *parse.TextNode:
-		// Ignore
+	case *parse.IfNode:
+		functionsCalledBranch(&node.BranchNode, r)
+	case *parse.RangeNode:
+		functionsCalledBranch(&node.BranchNode, r)
+	case *parse.WithNode:
+		functionsCalledBranch(&node.BranchNode, r)
+	case *parse.TemplateNode:
+		functionsCalledWalk(node.Pipe, r)
 	default:
-		panic(fmt.Sprintf("unknown type: %T", node))
+		// Text, fields, variables and constants call nothing.
 	}
 }
+
+func functionsCalledBranch(node *parse.BranchNode, r map[string]struct{}) {
+	functionsCalledWalk(node.Pipe, r)
+	functionsCalledWalk(node.List, r)
+	functionsCalledWalk(node.ElseList, r)
+}
@@ -25,6 +25,13 @@ func TestFunctionsCalled(t *testing.T) {
 				"user": {},
 			},
 		},
+
+		{
+			"{{if eq (env `CI`) `true`}}{{printf `%s-%s` (upper build_name) uuid}}{{else}}{{range split `a,b` `,` 0}}{{.}}{{end}}{{end}}",
+			map[string]struct{}{
+				"eq": {}, "env": {}, "printf": {}, "upper": {}, "build_name": {}, "uuid": {}, "split": {},
+			},
+		},
 	}
 
 	funcs := Funcs(&Context{})
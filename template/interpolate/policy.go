@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// The environment variables operators restrict the interpolation functions
+// with, like in shared CI, as comma separated lists of function names. The
+// plugins Packer starts inherit them.
+const (
+	// FuncsAllowEnv, if set, lists the only functions interpolations can
+	// call.
+	FuncsAllowEnv = "PACKER_INTERPOLATION_ALLOW"
+	// FuncsDenyEnv lists functions interpolations can't call, like
+	// "env,consul_key,vault".
+	FuncsDenyEnv = "PACKER_INTERPOLATION_DENY"
+)
+
+// FuncPolicy restricts the functions interpolations can call. The nil
+// policy allows them all.
+type FuncPolicy struct {
+	// Allow, when not empty, lists the only functions that can be called.
+	Allow []string
+	// Deny lists functions that can't be called, even when in Allow.
+	Deny []string
+}
+
+// Allowed tells whether p lets interpolations call the function name.
+func (p *FuncPolicy) Allowed(name string) bool {
+	if p == nil {
+		return true
+	}
+	for _, denied := range p.Deny {
+		if denied == name {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, allowed := range p.Allow {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FuncPolicyFromEnv returns the policy set by the operator with FuncsAllowEnv
+// and FuncsDenyEnv, nil when neither is set.
+func FuncPolicyFromEnv() *FuncPolicy {
+	allow := splitFuncNames(os.Getenv(FuncsAllowEnv))
+	deny := splitFuncNames(os.Getenv(FuncsDenyEnv))
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+	return &FuncPolicy{Allow: allow, Deny: deny}
+}
+
+func splitFuncNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// FuncPolicyError is returned when an interpolation calls a function a
+// FuncPolicy forbids.
+type FuncPolicyError struct {
+	Func string
+}
+
+func (e *FuncPolicyError) Error() string {
+	return fmt.Sprintf("interpolation function %q is not allowed by the function policy, see %s and %s",
+		e.Func, FuncsAllowEnv, FuncsDenyEnv)
+}
+
+// funcAllowed tells whether the function name can be called in ctx: both
+// the policy of the operator and the one of ctx must allow it.
+func funcAllowed(ctx *Context, env *FuncPolicy, name string) bool {
+	if !env.Allowed(name) {
+		return false
+	}
+	return ctx == nil || ctx.FuncPolicy.Allowed(name)
+}
+
+// isInterpolationFunc tells whether name is a function of the interpolations
+// rather than one built in text/template, like eq, that policies don't
+// restrict.
+func isInterpolationFunc(ctx *Context, name string) bool {
+	if _, ok := FuncGens[name]; ok {
+		return true
+	}
+	if ctx != nil {
+		_, ok := ctx.Funcs[name]
+		return ok
+	}
+	return false
+}
+
+// deniedFunc replaces the function name when it is not allowed, so that
+// the templates calling it still parse and fail when executed.
+func deniedFunc(name string) interface{} {
+	return func(...interface{}) (string, error) {
+		return "", &FuncPolicyError{Func: name}
+	}
+}
+
+// checkFuncPolicy returns a *FuncPolicyError when tpl calls a function ctx
+// doesn't allow, so that templates are rejected before anything is called,
+// and by Validate.
+func checkFuncPolicy(ctx *Context, tpl *template.Template) error {
+	env := FuncPolicyFromEnv()
+	if env == nil && (ctx == nil || ctx.FuncPolicy == nil) {
+		return nil
+	}
+	var denied []string
+	for _, t := range tpl.Templates() {
+		for name := range functionsCalled(t) {
+			if isInterpolationFunc(ctx, name) && !funcAllowed(ctx, env, name) {
+				denied = append(denied, name)
+			}
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	// The first in order, for the error to be reproducible.
+	sort.Strings(denied)
+	return &FuncPolicyError{Func: denied[0]}
+}
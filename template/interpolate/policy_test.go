@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFuncPolicy_Allowed(t *testing.T) {
+	p := &FuncPolicy{Allow: []string{"user", "env"}, Deny: []string{"env"}}
+	for name, expected := range map[string]bool{"user": true, "env": false, "uuid": false} {
+		if p.Allowed(name) != expected {
+			t.Errorf("%s: expected allowed %t", name, expected)
+		}
+	}
+	if !(&FuncPolicy{Deny: []string{"env"}}).Allowed("uuid") {
+		t.Fatal("functions not denied should be allowed without an allowlist")
+	}
+	if !(*FuncPolicy)(nil).Allowed("env") {
+		t.Fatal("the nil policy should allow everything")
+	}
+}
+
+func TestRender_funcPolicy(t *testing.T) {
+	ctx := &Context{
+		EnableEnv:     true,
+		UserVariables: map[string]string{"name": "packer"},
+		FuncPolicy:    &FuncPolicy{Deny: []string{"env", "consul_key"}},
+	}
+
+	for _, tpl := range []string{
+		"{{env `HOME`}}",
+		"{{if true}}{{consul_key `a/b`}}{{end}}",
+		"{{upper (env `HOME`)}}",
+	} {
+		_, err := Render(tpl, ctx)
+		var perr *FuncPolicyError
+		if !errors.As(err, &perr) {
+			t.Fatalf("%s: expected a policy error, got %v", tpl, err)
+		}
+		if err := Validate(tpl, ctx); err == nil {
+			t.Fatalf("%s: Validate should reject the template", tpl)
+		}
+	}
+
+	// Functions built in templates are not restricted by allowlists.
+	ctx.FuncPolicy = &FuncPolicy{Allow: []string{"user", "upper"}}
+	out, err := Render("{{if eq (user `name`) `packer`}}{{upper (user `name`)}}{{end}}", ctx)
+	if err != nil || out != "PACKER" {
+		t.Fatalf("bad render %q: %v", out, err)
+	}
+	if _, err := Render("{{uuid}}", ctx); err == nil {
+		t.Fatal("uuid is not in the allowlist")
+	}
+
+	// Calls bypassing the template check still fail.
+	f := Funcs(ctx)["timestamp"].(func(...interface{}) (string, error))
+	if _, err := f(); err == nil {
+		t.Fatal("expected an error calling a denied function")
+	}
+}
+
+func TestRender_funcPolicyFromEnv(t *testing.T) {
+	t.Setenv(FuncsDenyEnv, "env, vault")
+	ctx := &Context{EnableEnv: true, FuncPolicy: &FuncPolicy{Deny: []string{"uuid"}}}
+
+	_, err := Render("{{vault `secret/a` `b`}}", ctx)
+	if perr := (*FuncPolicyError)(nil); !errors.As(err, &perr) || perr.Func != "vault" {
+		t.Fatalf("expected a policy error on vault, got %v", err)
+	}
+	// The operator's policy and the one of the context both apply.
+	if _, err := Render("{{uuid}}", ctx); err == nil {
+		t.Fatal("uuid is denied by the context")
+	}
+	if _, err := Render("{{timestamp}}", &Context{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	t.Setenv(FuncsAllowEnv, "user")
+	if _, err := Render("{{timestamp}}", nil); err == nil {
+		t.Fatal("timestamp is not in the allowlist of the operator")
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"fmt"
+)
+
+// Func is a function a plugin adds to its interpolations, see RegisterFunc.
+type Func struct {
+	// Arity is the number of arguments of the function, -1 when it takes
+	// any number of them.
+	Arity int
+	// Impl computes the result of the function from its arguments. ctx is
+	// the context of the interpolation, nil outside of one.
+	Impl func(ctx *Context, args []string) (string, error)
+}
+
+// RegisterFunc makes f available as name in the interpolations rendered by
+// the plugin, like the built-in functions. It is meant to be called from the
+// init or main function of the plugin, and panics when name is taken.
+func RegisterFunc(name string, f Func) {
+	if _, found := FuncGens[name]; found {
+		panic(fmt.Errorf("registering duplicate %s interpolation function", name))
+	}
+	if f.Impl == nil {
+		panic(fmt.Errorf("interpolation function %s has no implementation", name))
+	}
+	FuncGens[name] = func(ctx *Context) interface{} {
+		return func(args ...string) (string, error) {
+			if f.Arity >= 0 && len(args) != f.Arity {
+				return "", fmt.Errorf("%s takes %d arguments, got %d", name, f.Arity, len(args))
+			}
+			return f.Impl(ctx, args)
+		}
+	}
+}
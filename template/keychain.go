@@ -0,0 +1,46 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package template
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeychainNotFound is returned by Keychain when the credential store has
+// no secret for the service and account.
+var ErrKeychainNotFound = errors.New("no such secret in the OS credential store")
+
+// Keychain reads the secret of account for service from the credential
+// store of the OS, so that developers can keep the secrets of their
+// templates there rather than in environment variables or files:
+//
+//   - on macOS, the generic password of the service and account in the
+//     login keychain, as read by `security find-generic-password`,
+//   - on Windows, the generic credential named `service:account`, or
+//     `service` without account, of the Credential Manager,
+//   - elsewhere, the secret with the service and account attributes of the
+//     Secret Service, like GNOME Keyring or KWallet, as read by
+//     `secret-tool lookup`.
+//
+// account can be empty to match any account of the service.
+func Keychain(service, account string) (string, error) {
+	if service == "" {
+		return "", errors.New("a service name must be provided")
+	}
+	secret, err := keychainGet(service, account)
+	if err != nil {
+		return "", fmt.Errorf("reading %q from the OS credential store: %w", keychainTarget(service, account), err)
+	}
+	return secret, nil
+}
+
+// keychainTarget names the secret of account for service, like the
+// credentials of the Windows Credential Manager.
+func keychainTarget(service, account string) string {
+	if account == "" {
+		return service
+	}
+	return service + ":" + account
+}
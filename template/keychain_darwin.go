@@ -0,0 +1,16 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build darwin
+
+package template
+
+func keychainGet(service, account string) (string, error) {
+	args := []string{"find-generic-password", "-s", service}
+	if account != "" {
+		args = append(args, "-a", account)
+	}
+	// security exits with errSecItemNotFound, 44, when there is no such
+	// password.
+	return keychainCommand(44, "security", append(args, "-w")...)
+}
@@ -0,0 +1,22 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !darwin && !windows
+
+package template
+
+import "errors"
+
+func keychainGet(service, account string) (string, error) {
+	args := []string{"lookup", "service", service}
+	if account != "" {
+		args = append(args, "account", account)
+	}
+	// secret-tool exits with 1, and prints nothing, when there is no such
+	// secret.
+	secret, err := keychainCommand(1, "secret-tool", args...)
+	if err == nil && secret == "" {
+		err = errors.New("the secret is empty")
+	}
+	return secret, err
+}
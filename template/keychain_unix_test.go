@@ -0,0 +1,62 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !darwin && !windows
+
+package template
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSecretTool puts a secret-tool in the PATH knowing the password of
+// packer for the registry service.
+func fakeSecretTool(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ "$*" = "lookup service registry account packer" ] || [ "$*" = "lookup service registry" ]; then
+	printf 'hunter2\n'
+	exit 0
+fi
+exit 1
+`
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestKeychain(t *testing.T) {
+	fakeSecretTool(t)
+
+	for _, account := range []string{"packer", ""} {
+		secret, err := Keychain("registry", account)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if secret != "hunter2" {
+			t.Fatalf("bad secret: %q", secret)
+		}
+	}
+
+	_, err := Keychain("registry", "root")
+	if !errors.Is(err, ErrKeychainNotFound) || !strings.Contains(err.Error(), `"registry:root"`) {
+		t.Fatalf("bad error: %v", err)
+	}
+	if _, err := Keychain("", "packer"); err == nil {
+		t.Fatal("a service should be required")
+	}
+}
+
+func TestKeychain_notInstalled(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := Keychain("registry", "packer")
+	if err == nil || !strings.Contains(err.Error(), "secret-tool is not installed") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
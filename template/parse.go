@@ -79,6 +79,7 @@ func (r *rawTemplate) decodeProvisioner(raw interface{}) (Provisioner, error) {
 	delete(p.Config, "only")
 	delete(p.Config, "override")
 	delete(p.Config, "pause_before")
+	delete(p.Config, "pause_after")
 	delete(p.Config, "max_retries")
 	delete(p.Config, "type")
 	delete(p.Config, "timeout")
@@ -109,6 +109,19 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-provisioner-pause-after.json",
+			&Template{
+				Provisioners: []*Provisioner{
+					{
+						Type:       "something",
+						PauseAfter: 30 * time.Second,
+					},
+				},
+			},
+			false,
+		},
+
 		{
 			"parse-provisioner-retry.json",
 			&Template{
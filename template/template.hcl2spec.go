@@ -16,6 +16,7 @@ type FlatProvisioner struct {
 	Config      map[string]interface{} `json:"config,omitempty" cty:"config" hcl:"config"`
 	Override    map[string]interface{} `json:"override,omitempty" cty:"override" hcl:"override"`
 	PauseBefore *string                `mapstructure:"pause_before" json:"pause_before,omitempty" cty:"pause_before" hcl:"pause_before"`
+	PauseAfter  *string                `mapstructure:"pause_after" json:"pause_after,omitempty" cty:"pause_after" hcl:"pause_after"`
 	MaxRetries  *string                `mapstructure:"max_retries" json:"max_retries,omitempty" cty:"max_retries" hcl:"max_retries"`
 	Timeout     *string                `mapstructure:"timeout" json:"timeout,omitempty" cty:"timeout" hcl:"timeout"`
 }
@@ -38,6 +39,7 @@ func (*FlatProvisioner) HCL2Spec() map[string]hcldec.Spec {
 		"config":       &hcldec.AttrSpec{Name: "config", Type: cty.Map(cty.String), Required: false},
 		"override":     &hcldec.AttrSpec{Name: "override", Type: cty.Map(cty.String), Required: false},
 		"pause_before": &hcldec.AttrSpec{Name: "pause_before", Type: cty.String, Required: false},
+		"pause_after":  &hcldec.AttrSpec{Name: "pause_after", Type: cty.String, Required: false},
 		"max_retries":  &hcldec.AttrSpec{Name: "max_retries", Type: cty.String, Required: false},
 		"timeout":      &hcldec.AttrSpec{Name: "timeout", Type: cty.String, Required: false},
 	}
@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Timeouts
+
+// Package timeouts is a helper module for builder plugin configuration that
+// gathers the timeouts of a build in one block:
+//
+//	timeouts {
+//	  connect   = "10m"
+//	  provision = "1h"
+//	  shutdown  = "5m"
+//	  overall   = "2h"
+//	}
+//
+// A timeout set in the block overrides the older, builder specific, options
+// like ssh_timeout or shutdown_timeout, which the builder passes to
+// Timeouts.Inherit along with its defaults. No phase can outlast the overall
+// timeout.
+package timeouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Phase is a part of a build bounded by one of the Timeouts.
+type Phase string
+
+const (
+	// Connect is waiting for the communicator to connect to the machine.
+	Connect Phase = "connect"
+	// Provision is running the provisioners.
+	Provision Phase = "provision"
+	// Shutdown is waiting for the machine to stop.
+	Shutdown Phase = "shutdown"
+	// Overall is the whole build.
+	Overall Phase = "overall"
+)
+
+// Config holds the timeouts block. Embed it in your builder config using the
+// `mapstructure:",squash"` struct tag.
+type Config struct {
+	// The timeouts of the phases of the build. Unset timeouts fall back to
+	// the options of the builder they replace, like `ssh_timeout`, then to
+	// the defaults of the builder.
+	Timeouts Timeouts `mapstructure:"timeouts" required:"false"`
+}
+
+// Timeouts bounds how long each phase of a build can take. A zero timeout is
+// unset, and inherited.
+type Timeouts struct {
+	// How long to wait for the communicator to connect to the machine, for
+	// example "10m".
+	Connect time.Duration `mapstructure:"connect" required:"false"`
+	// How long the provisioners can run, all together.
+	Provision time.Duration `mapstructure:"provision" required:"false"`
+	// How long to wait for the machine to stop once shut down.
+	Shutdown time.Duration `mapstructure:"shutdown" required:"false"`
+	// How long the whole build can run. The other timeouts can't exceed it.
+	Overall time.Duration `mapstructure:"overall" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	t := c.Timeouts
+	for _, phase := range []Phase{Connect, Provision, Shutdown, Overall} {
+		if d := t.Get(phase); d < 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s must not be negative, got %s", phase, d))
+		}
+	}
+	if t.Overall > 0 {
+		for _, phase := range []Phase{Connect, Provision, Shutdown} {
+			if d := t.Get(phase); d > t.Overall {
+				errs = append(errs, fmt.Errorf("timeouts.%s (%s) exceeds timeouts.overall (%s)", phase, d, t.Overall))
+			}
+		}
+	}
+	return errs
+}
+
+// Inherit returns t with its unset timeouts taken from the first of parents
+// setting them: parents go from the most to the least specific, usually the
+// legacy options of the builder and then its defaults. Call it before
+// Config.Prepare, for the inherited timeouts to be validated too.
+func (t Timeouts) Inherit(parents ...Timeouts) Timeouts {
+	inherit := func(d *time.Duration, get func(Timeouts) time.Duration) {
+		for _, p := range parents {
+			if *d != 0 {
+				return
+			}
+			*d = get(p)
+		}
+	}
+	inherit(&t.Connect, func(p Timeouts) time.Duration { return p.Connect })
+	inherit(&t.Provision, func(p Timeouts) time.Duration { return p.Provision })
+	inherit(&t.Shutdown, func(p Timeouts) time.Duration { return p.Shutdown })
+	inherit(&t.Overall, func(p Timeouts) time.Duration { return p.Overall })
+	return t
+}
+
+// Get returns the timeout of phase, zero when unset.
+func (t Timeouts) Get(phase Phase) time.Duration {
+	switch phase {
+	case Connect:
+		return t.Connect
+	case Provision:
+		return t.Provision
+	case Shutdown:
+		return t.Shutdown
+	case Overall:
+		return t.Overall
+	}
+	return 0
+}
+
+// ExceededError is the cause of the contexts of the phases that timed out.
+type ExceededError struct {
+	Phase   Phase
+	Timeout time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s timeout of %s exceeded", e.Phase, e.Timeout)
+}
+
+// Context returns a copy of ctx cancelled once the timeout of phase elapsed,
+// with an ExceededError as cause, or cancelled with ctx when it is unset.
+func (t Timeouts) Context(ctx context.Context, phase Phase) (context.Context, context.CancelFunc) {
+	d := t.Get(phase)
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, d, &ExceededError{Phase: phase, Timeout: d})
+}
+
+// Err returns the ExceededError of ctx when one of its phases timed out, nil
+// otherwise.
+func Err(ctx context.Context) error {
+	var exceeded *ExceededError
+	if errors.As(context.Cause(ctx), &exceeded) {
+		return exceeded
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package timeouts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestConfig_decode(t *testing.T) {
+	var c struct {
+		Config     `mapstructure:",squash"`
+		SSHTimeout time.Duration `mapstructure:"ssh_timeout"`
+	}
+	err := config.Decode(&c, nil, map[string]interface{}{
+		"ssh_timeout": "3m",
+		"timeouts": map[string]interface{}{
+			"provision": "1h",
+			"overall":   "2h",
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	defaults := Timeouts{Connect: 5 * time.Minute, Shutdown: 5 * time.Minute, Overall: 4 * time.Hour}
+	c.Timeouts = c.Timeouts.Inherit(Timeouts{Connect: c.SSHTimeout}, defaults)
+	expected := Timeouts{
+		Connect:   3 * time.Minute,
+		Provision: time.Hour,
+		Shutdown:  5 * time.Minute,
+		Overall:   2 * time.Hour,
+	}
+	if c.Timeouts != expected {
+		t.Fatalf("expected %#v, got %#v", expected, c.Timeouts)
+	}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+}
+
+func TestConfigPrepare(t *testing.T) {
+	c := &Config{Timeouts: Timeouts{Connect: -time.Minute, Provision: 3 * time.Hour, Overall: 2 * time.Hour}}
+	errs := c.Prepare(interpolate.NewContext())
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %#v", errs)
+	}
+	if errs[1].Error() != "timeouts.provision (3h0m0s) exceeds timeouts.overall (2h0m0s)" {
+		t.Fatalf("bad error: %s", errs[1])
+	}
+}
+
+func TestTimeouts_Context(t *testing.T) {
+	ts := Timeouts{Connect: time.Millisecond}
+
+	ctx, cancel := ts.Context(context.Background(), Connect)
+	defer cancel()
+	<-ctx.Done()
+	var exceeded *ExceededError
+	if err := Err(ctx); !errors.As(err, &exceeded) || exceeded.Phase != Connect {
+		t.Fatalf("expected the connect timeout to be exceeded, got %v", err)
+	}
+
+	ctx, cancel = ts.Context(context.Background(), Provision)
+	cancel()
+	if err := Err(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
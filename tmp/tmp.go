@@ -14,6 +14,10 @@
 //
 // The directory is neither guaranteed to exist nor have accessible
 // permissions.
+//
+// On Windows, items are created in a directory of the system's temporary
+// directory dedicated to the current user, which only the user and the
+// system can access, and short (8.3) paths are expanded.
 package tmp
 
 import (
@@ -30,7 +34,11 @@ var tmpDir = os.TempDir()
 // It is the caller's responsibility
 // to remove the file when no longer needed.
 func Dir(prefix string) (string, error) {
-	return os.MkdirTemp(tmpDir, prefix)
+	dir, err := userDir()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, prefix)
 }
 
 // File creates a new temporary file in the system temporary
@@ -44,5 +52,9 @@ func Dir(prefix string) (string, error) {
 // to find the pathname of the file. It is the caller's responsibility
 // to remove the file when no longer needed.
 func File(pattern string) (*os.File, error) {
-	return os.CreateTemp(tmpDir, pattern)
+	dir, err := userDir()
+	if err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, pattern)
 }
@@ -0,0 +1,12 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+
+package tmp
+
+// userDir returns the directory to create temporary items in. os.MkdirTemp
+// and os.CreateTemp already restrict their items to the current user.
+func userDir() (string, error) {
+	return tmpDir, nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package tmp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	userDirOnce sync.Once
+	userDirPath string
+	userDirErr  error
+)
+
+// userDir returns the directory of the system temporary directory dedicated
+// to the current user, creating it the first time. Items created in the
+// system temporary directory inherit its permissions, which are often too
+// open when it is shared, like C:\Windows\Temp, or too closed on
+// hardened hosts.
+func userDir() (string, error) {
+	userDirOnce.Do(func() {
+		userDirPath, userDirErr = createUserDir()
+	})
+	return userDirPath, userDirErr
+}
+
+func createUserDir() (string, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", fmt.Errorf("Error looking up the current user: %s", err)
+	}
+	sid := user.User.Sid
+
+	dir := filepath.Join(longPath(tmpDir), "packer-"+sid.String())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	if err := restrictToUser(dir, sid); err != nil {
+		return "", fmt.Errorf("Error restricting access to %s: %s", dir, err)
+	}
+	return dir, nil
+}
+
+// restrictToUser replaces the ACL of path, and stops it from inheriting the
+// one of its parent, so that only the user of sid and the system have access
+// to path and to what is created in it.
+func restrictToUser(path string, sid *windows.SID) error {
+	system, err := windows.CreateWellKnownSid(windows.WinLocalSystemSid)
+	if err != nil {
+		return err
+	}
+	grant := func(sid *windows.SID, trusteeType windows.TRUSTEE_TYPE) windows.EXPLICIT_ACCESS {
+		return windows.EXPLICIT_ACCESS{
+			AccessPermissions: windows.GENERIC_ALL,
+			AccessMode:        windows.GRANT_ACCESS,
+			Inheritance:       windows.SUB_CONTAINERS_AND_OBJECTS_INHERIT,
+			Trustee: windows.TRUSTEE{
+				TrusteeForm:  windows.TRUSTEE_IS_SID,
+				TrusteeType:  trusteeType,
+				TrusteeValue: windows.TrusteeValueFromSID(sid),
+			},
+		}
+	}
+	acl, err := windows.ACLFromEntries([]windows.EXPLICIT_ACCESS{
+		grant(sid, windows.TRUSTEE_IS_USER),
+		grant(system, windows.TRUSTEE_IS_WELL_KNOWN_GROUP),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION,
+		nil, nil, acl, nil)
+}
+
+// longPath expands the short (8.3) names in path, like the RUNNER~1 of
+// C:\Users\RUNNER~1\AppData\Local\Temp, which some tools cannot handle. path
+// is returned as is when it cannot be expanded.
+func longPath(path string) string {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return path
+	}
+	buf := make([]uint16, windows.MAX_PATH)
+	for {
+		n, err := windows.GetLongPathName(p, &buf[0], uint32(len(buf)))
+		if err != nil || n == 0 {
+			return path
+		}
+		if n < uint32(len(buf)) {
+			return windows.UTF16ToString(buf[:n])
+		}
+		buf = make([]uint16, n)
+	}
+}
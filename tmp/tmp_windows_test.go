@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package tmp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDir_userDir(t *testing.T) {
+	dir, err := Dir("packer-test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	parent := filepath.Dir(dir)
+	if !strings.HasPrefix(filepath.Base(parent), "packer-S-1-") {
+		t.Fatalf("%s should be in the directory of the current user", dir)
+	}
+	if strings.Contains(dir, "~") {
+		t.Fatalf("%s should not contain short names", dir)
+	}
+}
@@ -5,18 +5,19 @@
 package uuid
 
 import (
-	"crypto/rand"
 	"fmt"
 	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/random"
 )
 
 // Generates a time ordered UUID. Top 32 bits are a timestamp,
-// bottom 96 are random.
+// bottom 96 are random, read from the source of the random package.
 func TimeOrderedUUID() string {
 	unix := uint32(time.Now().UTC().Unix())
 
 	b := make([]byte, 12)
-	n, err := rand.Read(b)
+	n, err := random.Reader.Read(b)
 	if n != len(b) {
 		err = fmt.Errorf("Not enough entropy available")
 	}
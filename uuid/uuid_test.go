@@ -4,7 +4,11 @@
 package uuid
 
 import (
+	"bytes"
+	"strings"
 	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/random"
 )
 
 func TestTimeOrderedUuid(t *testing.T) {
@@ -13,3 +17,13 @@ func TestTimeOrderedUuid(t *testing.T) {
 		t.Fatalf("bad: %s", uuid)
 	}
 }
+
+func TestTimeOrderedUuid_source(t *testing.T) {
+	previous := random.SetSource(bytes.NewReader(bytes.Repeat([]byte{0xab}, 12)))
+	defer random.SetSource(previous)
+
+	uuid := TimeOrderedUUID()
+	if !strings.HasSuffix(uuid, "-abab-abab-abab-abababababab") {
+		t.Fatalf("the uuid should be read from the source: %s", uuid)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package verify
+
+import (
+	"context"
+	"fmt"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/mitchellh/mapstructure"
+)
+
+// StateKey is the name under which artifacts return their verification
+// report from State.
+const StateKey = "verification_report"
+
+// reportArtifact is an artifact holding its verification report.
+type reportArtifact struct {
+	packersdk.Artifact
+	report *Report
+}
+
+func (a *reportArtifact) State(name string) interface{} {
+	if name == StateKey {
+		return a.report
+	}
+	return a.Artifact.State(name)
+}
+
+// WithReport returns a, returning report from State under StateKey in place
+// of the report it may already hold.
+func WithReport(a packersdk.Artifact, report *Report) packersdk.Artifact {
+	if r, ok := a.(*reportArtifact); ok {
+		a = r.Artifact
+	}
+	return &reportArtifact{Artifact: a, report: report}
+}
+
+// FromArtifact returns the verification report of an artifact, nil when it
+// was not verified.
+func FromArtifact(a packersdk.Artifact) (*Report, error) {
+	state := a.State(StateKey)
+	switch s := state.(type) {
+	case nil:
+		return nil, nil
+	case *Report:
+		return s, nil
+	}
+	// Artifacts of plugins are received over RPC as generic maps.
+	report := new(Report)
+	if err := mapstructure.Decode(state, report); err != nil {
+		return nil, fmt.Errorf("invalid verification report of artifact %s: %s", a.Id(), err)
+	}
+	return report, nil
+}
+
+// VerifyArtifact verifies a with v, and returns a with the report attached.
+// The artifact is returned along with the *VerificationError when checks
+// failed, for builders and post-processors to fail the build with while
+// keeping the artifact, for it to be destroyed or investigated.
+func VerifyArtifact(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact, v *Verifier) (packersdk.Artifact, error) {
+	report, err := v.Verify(ctx, ui, a)
+	return WithReport(a, report), err
+}
@@ -0,0 +1,151 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package verify
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/cache"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// HookVerify is the name of the hook BootTest runs on the booted artifact.
+const HookVerify = "packer_verify"
+
+// ChecksumCheck verifies the checksums of the files of an artifact.
+type ChecksumCheck struct {
+	// Expected are the checksums of the files, by file name or path, as
+	// "type:value", like "sha256:8d4b...". When it is empty, the checksums
+	// of the metadata of the artifact are verified, for artifacts of one
+	// file.
+	Expected map[string]string
+}
+
+func (c *ChecksumCheck) Verify(_ context.Context, _ packersdk.Ui, a packersdk.Artifact) error {
+	files := a.Files()
+	if len(c.Expected) == 0 {
+		md := packersdk.ArtifactMetadataOf(a)
+		if len(md.Checksums) == 0 || len(files) != 1 {
+			return Skip("no checksum to verify")
+		}
+		for _, typ := range sortedKeys(md.Checksums) {
+			if err := verifyChecksum(files[0], typ+":"+md.Checksums[typ]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(files) == 0 {
+		return Skip("the artifact has no local files")
+	}
+	for _, name := range sortedKeys(c.Expected) {
+		path := ""
+		for _, f := range files {
+			if f == name || filepath.Base(f) == name {
+				path = f
+				break
+			}
+		}
+		if path == "" {
+			return fmt.Errorf("the artifact has no file %s", name)
+		}
+		if err := verifyChecksum(path, c.Expected[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyChecksum(path, checksum string) error {
+	sum, err := cache.ParseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+	if sum == nil {
+		return nil
+	}
+	return sum.Verify(path)
+}
+
+// SizeCheck verifies the size of an artifact is within bounds, like to
+// catch an image missing its data or one where logs were left behind. The
+// size is the one of the metadata of the artifact, see
+// packersdk.ArtifactMetadataOf.
+type SizeCheck struct {
+	// Min and Max are the bounds of the size in bytes, 0 for none.
+	Min int64
+	Max int64
+}
+
+func (c *SizeCheck) Verify(_ context.Context, _ packersdk.Ui, a packersdk.Artifact) error {
+	size := packersdk.ArtifactMetadataOf(a).Size
+	if size == 0 {
+		return Skip("the size of the artifact is unknown")
+	}
+	if c.Min > 0 && size < c.Min {
+		return fmt.Errorf("the artifact is %d bytes, less than the minimum of %d bytes", size, c.Min)
+	}
+	if c.Max > 0 && size > c.Max {
+		return fmt.Errorf("the artifact is %d bytes, more than the maximum of %d bytes", size, c.Max)
+	}
+	return nil
+}
+
+// MetadataCheck verifies an artifact has metadata, like the region of a
+// cloud image that a registry publishing it needs.
+type MetadataCheck struct {
+	// Required are the names of the metadata, looked up in the values of
+	// the metadata of the artifact, and then in its State.
+	Required []string
+}
+
+func (c *MetadataCheck) Verify(_ context.Context, _ packersdk.Ui, a packersdk.Artifact) error {
+	md := packersdk.ArtifactMetadataOf(a)
+	var missing []string
+	for _, name := range c.Required {
+		if v, ok := md.Values[name]; ok && !v.IsNull() {
+			continue
+		}
+		if a.State(name) != nil {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("the artifact is missing the metadata %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// BootTest boots an artifact and runs Hook on it, to test that the image
+// works, like that its services start.
+type BootTest struct {
+	// Boot is specific to the builder: it starts a machine from the
+	// artifact and returns a communicator connected to it, and a function
+	// destroying the machine, called once Hook ran.
+	Boot func(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) (packersdk.Communicator, func(), error)
+	// Hook runs the tests on the machine, as HookVerify. The check only
+	// boots the machine when it is nil.
+	Hook packersdk.Hook
+}
+
+func (c *BootTest) Verify(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) error {
+	comm, destroy, err := c.Boot(ctx, ui, a)
+	if err != nil {
+		return fmt.Errorf("error booting the artifact: %s", err)
+	}
+	if destroy != nil {
+		defer destroy()
+	}
+	if c.Hook == nil {
+		return nil
+	}
+	return c.Hook.Run(ctx, HookVerify, ui, comm, nil)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func testArtifactFile(t *testing.T, content string) (string, string) {
+	path := filepath.Join(t.TempDir(), "image.qcow2")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return path, hex.EncodeToString(sum[:])
+}
+
+func isSkipped(err error) bool {
+	var skip *skipError
+	return errors.As(err, &skip)
+}
+
+func TestChecksumCheck(t *testing.T) {
+	path, sum := testArtifactFile(t, "image")
+	a := &packersdk.MockArtifact{FilesValue: []string{path}}
+	ctx, ui := context.Background(), packersdk.TestUi(t)
+
+	if err := (&ChecksumCheck{Expected: map[string]string{"image.qcow2": "sha256:" + sum}}).Verify(ctx, ui, a); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := (&ChecksumCheck{Expected: map[string]string{path: "sha256:" + strings.Repeat("0", 64)}}).Verify(ctx, ui, a)
+	if err == nil || !strings.Contains(err.Error(), "did not match") {
+		t.Fatalf("expected a mismatch, got %v", err)
+	}
+	if err := (&ChecksumCheck{Expected: map[string]string{"disk.vmdk": sum}}).Verify(ctx, ui, a); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+
+	// Without expected checksums, the ones of the metadata are verified.
+	if err := new(ChecksumCheck).Verify(ctx, ui, a); !isSkipped(err) {
+		t.Fatalf("expected the check to be skipped, got %v", err)
+	}
+	a.MetadataValue = &packersdk.ArtifactMetadata{Checksums: map[string]string{"sha256": sum}}
+	if err := new(ChecksumCheck).Verify(ctx, ui, a); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestSizeCheck(t *testing.T) {
+	path, _ := testArtifactFile(t, "0123456789")
+	a := &packersdk.MockArtifact{FilesValue: []string{path}}
+	ctx, ui := context.Background(), packersdk.TestUi(t)
+
+	if err := (&SizeCheck{Min: 5, Max: 10}).Verify(ctx, ui, a); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := (&SizeCheck{Min: 11}).Verify(ctx, ui, a); err == nil || !strings.Contains(err.Error(), "less than the minimum") {
+		t.Fatalf("bad error: %v", err)
+	}
+	if err := (&SizeCheck{Max: 9}).Verify(ctx, ui, a); err == nil || !strings.Contains(err.Error(), "more than the maximum") {
+		t.Fatalf("bad error: %v", err)
+	}
+	remote := &packersdk.MockArtifact{FilesValue: []string{}}
+	if err := (&SizeCheck{Min: 1}).Verify(ctx, ui, remote); !isSkipped(err) {
+		t.Fatalf("expected the check to be skipped, got %v", err)
+	}
+}
+
+func TestMetadataCheck(t *testing.T) {
+	a := &packersdk.MockArtifact{
+		StateValues:   map[string]interface{}{"region": "eu-west-1"},
+		MetadataValue: &packersdk.ArtifactMetadata{Values: map[string]cty.Value{"source_image": cty.StringVal("ami-0")}},
+	}
+	c := &MetadataCheck{Required: []string{"region", "source_image", "owner", "account"}}
+	err := c.Verify(context.Background(), packersdk.TestUi(t), a)
+	if err == nil || err.Error() != "the artifact is missing the metadata account, owner" {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+type recordingHook struct {
+	name string
+	comm packersdk.Communicator
+}
+
+func (h *recordingHook) Run(_ context.Context, name string, _ packersdk.Ui, comm packersdk.Communicator, _ interface{}) error {
+	h.name, h.comm = name, comm
+	return errors.New("nginx is not running")
+}
+
+func TestBootTest(t *testing.T) {
+	comm := new(packersdk.MockCommunicator)
+	destroyed := false
+	hook := new(recordingHook)
+	c := &BootTest{
+		Boot: func(context.Context, packersdk.Ui, packersdk.Artifact) (packersdk.Communicator, func(), error) {
+			return comm, func() { destroyed = true }, nil
+		},
+		Hook: hook,
+	}
+	err := c.Verify(context.Background(), packersdk.TestUi(t), new(packersdk.MockArtifact))
+	if err == nil || err.Error() != "nginx is not running" {
+		t.Fatalf("bad error: %v", err)
+	}
+	if hook.name != HookVerify || hook.comm != comm || !destroyed {
+		t.Fatalf("the hook should run on the booted machine, destroyed after: %#v, %t", hook, destroyed)
+	}
+}
+
+func TestConfig_Prepare(t *testing.T) {
+	c := &Config{VerifyArtifact: VerifyConfig{
+		Checksums: map[string]string{"image.qcow2": "sha256:nope"},
+		MinSize:   10,
+		MaxSize:   5,
+	}}
+	if errs := c.Prepare(nil); len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+
+	c = &Config{VerifyArtifact: VerifyConfig{MinSize: 10, RequiredMetadata: []string{"region"}}}
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("errs: %v", errs)
+	}
+	if names := c.VerifyArtifact.Verifier().Names(); strings.Join(names, ",") != "size,metadata" {
+		t.Fatalf("bad checks: %v", names)
+	}
+	if new(VerifyConfig).Enabled() {
+		t.Fatal("an empty block should not verify the artifact")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type VerifyConfig
+
+package verify
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/cache"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Config holds the verify_artifact block, verifying the artifact once it is
+// created:
+//
+//	verify_artifact {
+//	  min_size          = 1073741824
+//	  required_metadata = ["region"]
+//	  checksums = {
+//	    "image.qcow2" = "sha256:8d4b..."
+//	  }
+//	}
+//
+// Embed it in your builder or post-processor config using the
+// `mapstructure:",squash"` struct tag.
+type Config struct {
+	// Verifies the artifact once it is created, failing the build when a
+	// check fails. The artifact isn't verified when the block is unset.
+	VerifyArtifact VerifyConfig `mapstructure:"verify_artifact" required:"false"`
+}
+
+// VerifyConfig configures the checks of the artifact.
+type VerifyConfig struct {
+	// The expected checksums of the files of the artifact, by file name, as
+	// `type:value`, like `sha256:8d4b...`.
+	Checksums map[string]string `mapstructure:"checksums" required:"false"`
+	// The minimum size of the artifact, in bytes.
+	MinSize int64 `mapstructure:"min_size" required:"false"`
+	// The maximum size of the artifact, in bytes.
+	MaxSize int64 `mapstructure:"max_size" required:"false"`
+	// The metadata the artifact must have, like `region`.
+	RequiredMetadata []string `mapstructure:"required_metadata" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	v := c.VerifyArtifact
+	var errs []error
+	for _, name := range sortedKeys(v.Checksums) {
+		if _, err := cache.ParseChecksum(v.Checksums[name]); err != nil {
+			errs = append(errs, fmt.Errorf("verify_artifact.checksums[%q]: %s", name, err))
+		}
+	}
+	if v.MinSize < 0 {
+		errs = append(errs, fmt.Errorf("verify_artifact.min_size must not be negative"))
+	}
+	if v.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("verify_artifact.max_size must not be negative"))
+	}
+	if v.MaxSize > 0 && v.MinSize > v.MaxSize {
+		errs = append(errs, fmt.Errorf("verify_artifact.min_size must not be greater than max_size"))
+	}
+	return errs
+}
+
+// Enabled tells whether the artifact is verified.
+func (c *VerifyConfig) Enabled() bool {
+	return len(c.Checksums) > 0 || c.MinSize > 0 || c.MaxSize > 0 || len(c.RequiredMetadata) > 0
+}
+
+// Verifier returns a Verifier with the checks configured, named "checksum",
+// "size" and "metadata". Builders register their own checks on top, like a
+// BootTest.
+func (c *VerifyConfig) Verifier() *Verifier {
+	v := NewVerifier()
+	if len(c.Checksums) > 0 {
+		v.Register("checksum", &ChecksumCheck{Expected: c.Checksums})
+	}
+	if c.MinSize > 0 || c.MaxSize > 0 {
+		v.Register("size", &SizeCheck{Min: c.MinSize, Max: c.MaxSize})
+	}
+	if len(c.RequiredMetadata) > 0 {
+		v.Register("metadata", &MetadataCheck{Required: c.RequiredMetadata})
+	}
+	return v
+}
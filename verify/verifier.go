@@ -0,0 +1,231 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package verify verifies the artifacts of builds once they are created,
+// like that their checksums match, that their size is sane or that they
+// boot. Builders and post-processors register the checks of an artifact in a
+// Verifier, whose consolidated report tells which ones passed and fails the
+// build when any did not.
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ReportMachineCategory is the category of the machine readable event the
+// verification report is written as.
+const ReportMachineCategory = "verification-report"
+
+// A Check verifies an artifact. It returns an error telling what is wrong
+// with the artifact when it fails, or one made with Skip when it doesn't
+// apply to the artifact.
+type Check interface {
+	Verify(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) error
+}
+
+// CheckFunc is a function used as a Check.
+type CheckFunc func(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) error
+
+func (f CheckFunc) Verify(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) error {
+	return f(ctx, ui, a)
+}
+
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string { return e.reason }
+
+// Skip returns the error of a Check that doesn't apply to an artifact, like
+// a checksum check on an artifact without local files.
+func Skip(format string, args ...interface{}) error {
+	return &skipError{reason: fmt.Sprintf(format, args...)}
+}
+
+// Status is the outcome of a Check.
+type Status string
+
+const (
+	Passed  Status = "passed"
+	Failed  Status = "failed"
+	Skipped Status = "skipped"
+)
+
+// Result is the outcome of a Check of a Report.
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	// Message tells why the check failed or was skipped.
+	Message string `json:"message,omitempty"`
+}
+
+// Report is the outcome of the checks of an artifact.
+type Report struct {
+	Artifact string   `json:"artifact"`
+	Results  []Result `json:"results"`
+}
+
+// Passed tells whether none of the checks of r failed.
+func (r *Report) Passed() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the results of the checks of r that failed.
+func (r *Report) Failures() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if res.Status == Failed {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Summary describes r in one line, for the Ui.
+func (r *Report) Summary() string {
+	counts := map[Status]int{}
+	for _, res := range r.Results {
+		counts[res.Status]++
+	}
+	return i18n.Sprintf("Verification of %s: %d passed, %d failed, %d skipped",
+		r.Artifact, counts[Passed], counts[Failed], counts[Skipped])
+}
+
+// Err returns a *VerificationError when checks of r failed, nil otherwise.
+func (r *Report) Err() error {
+	if failed := r.Failures(); len(failed) > 0 {
+		return &VerificationError{Artifact: r.Artifact, Failures: failed}
+	}
+	return nil
+}
+
+// VerificationError lists the checks an artifact failed.
+type VerificationError struct {
+	Artifact string
+	Failures []Result
+}
+
+func (e *VerificationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Artifact %s failed %d verification checks:", e.Artifact, len(e.Failures))
+	for _, f := range e.Failures {
+		fmt.Fprintf(&b, "\n  - %s: %s", f.Check, f.Message)
+	}
+	return b.String()
+}
+
+// machineDataUi is implemented by the Uis writing typed machine events, like
+// packersdk.MachineReadableUi.
+type machineDataUi interface {
+	MachineData(t string, data interface{}) error
+}
+
+// Verifier holds the checks of artifacts, run in the order they were
+// registered. A Verifier is safe to use from multiple goroutines.
+type Verifier struct {
+	mu     sync.Mutex
+	checks map[string]Check
+	// order is the order checks were registered in.
+	order []string
+}
+
+// NewVerifier returns a Verifier without checks.
+func NewVerifier() *Verifier {
+	return &Verifier{checks: map[string]Check{}}
+}
+
+// Register adds the check c named name to v. It panics if a check of the
+// same name is already registered.
+func (v *Verifier) Register(name string, c Check) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.checks == nil {
+		v.checks = map[string]Check{}
+	}
+	if _, ok := v.checks[name]; ok {
+		panic(fmt.Sprintf("verify: check %q registered twice", name))
+	}
+	v.checks[name] = c
+	v.order = append(v.order, name)
+}
+
+// Names returns the names of the checks of v, in the order they were
+// registered.
+func (v *Verifier) Names() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]string(nil), v.order...)
+}
+
+// Verify runs all the checks of v on a, even once one failed, and reports
+// their outcome to ui, both for people and as a machine readable event of
+// category ReportMachineCategory. The error is the *VerificationError of
+// the report when checks failed, or ctx.Err() when it is done: the checks
+// left are not run.
+func (v *Verifier) Verify(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) (*Report, error) {
+	v.mu.Lock()
+	names := append([]string(nil), v.order...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = v.checks[name]
+	}
+	v.mu.Unlock()
+
+	report := &Report{Artifact: a.Id(), Results: []Result{}}
+	for i, c := range checks {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		ui.Say(i18n.Sprintf("Verifying artifact: %s", names[i]))
+		res := Result{Check: names[i], Status: Passed}
+		var skip *skipError
+		switch err := c.Verify(ctx, ui, a); {
+		case err == nil:
+		case errors.As(err, &skip):
+			res.Status, res.Message = Skipped, skip.reason
+		default:
+			res.Status, res.Message = Failed, err.Error()
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	ui.Say(report.Summary())
+	for _, res := range report.Results {
+		switch res.Status {
+		case Failed:
+			ui.Error(i18n.Sprintf("  %s: failed: %s", res.Check, res.Message))
+		case Skipped:
+			ui.Message(i18n.Sprintf("  %s: skipped: %s", res.Check, res.Message))
+		default:
+			ui.Message(i18n.Sprintf("  %s: passed", res.Check))
+		}
+	}
+	if mui, ok := ui.(machineDataUi); ok {
+		if err := mui.MachineData(ReportMachineCategory, report); err != nil {
+			ui.Error(i18n.Sprintf("Error writing the verification report: %s", err))
+		}
+	} else if b, err := json.Marshal(report); err == nil {
+		ui.Machine(ReportMachineCategory, string(b))
+	}
+	return report, report.Err()
+}
+
+// sortedKeys returns the keys of m in order, for the checks to be
+// reproducible.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,113 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	var ran []string
+	check := func(name string, err error) Check {
+		return CheckFunc(func(context.Context, packersdk.Ui, packersdk.Artifact) error {
+			ran = append(ran, name)
+			return err
+		})
+	}
+	v := NewVerifier()
+	v.Register("boot", check("boot", errors.New("sshd did not start")))
+	v.Register("size", check("size", nil))
+	v.Register("checksum", check("checksum", Skip("no checksum to verify")))
+
+	var out bytes.Buffer
+	ui := &packersdk.MachineReadableUi{Writer: &out}
+	report, err := v.Verify(context.Background(), ui, &packersdk.MockArtifact{IdValue: "ami-1"})
+
+	if !reflect.DeepEqual(ran, []string{"boot", "size", "checksum"}) {
+		t.Fatalf("every check should run in order, ran %v", ran)
+	}
+	expected := &Report{Artifact: "ami-1", Results: []Result{
+		{Check: "boot", Status: Failed, Message: "sshd did not start"},
+		{Check: "size", Status: Passed},
+		{Check: "checksum", Status: Skipped, Message: "no checksum to verify"},
+	}}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, report)
+	}
+	var verr *VerificationError
+	if !errors.As(err, &verr) || len(verr.Failures) != 1 || !strings.Contains(err.Error(), "boot: sshd did not start") {
+		t.Fatalf("bad error: %v", err)
+	}
+	if report.Passed() {
+		t.Fatal("the report should not pass")
+	}
+	if s := report.Summary(); s != "Verification of ami-1: 1 passed, 1 failed, 1 skipped" {
+		t.Fatalf("bad summary: %s", s)
+	}
+
+	dec := packersdk.NewUiEventDecoder(&out)
+	var machine *Report
+	for {
+		e, err := dec.Decode()
+		if err != nil {
+			break
+		}
+		if e.Type == packersdk.UiEventMachine && e.Payload.Category == ReportMachineCategory {
+			machine = new(Report)
+			if err := json.Unmarshal(e.Payload.Data, machine); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+	}
+	if !reflect.DeepEqual(machine, expected) {
+		t.Fatalf("bad machine readable report: %#v", machine)
+	}
+}
+
+func TestVerifier_Register(t *testing.T) {
+	v := NewVerifier()
+	v.Register("size", &SizeCheck{})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a check twice should panic")
+		}
+	}()
+	v.Register("size", &SizeCheck{})
+}
+
+func TestVerifyArtifact(t *testing.T) {
+	v := NewVerifier()
+	v.Register("metadata", &MetadataCheck{Required: []string{"region"}})
+	a := &packersdk.MockArtifact{StateValues: map[string]interface{}{"region": "eu-west-1"}}
+
+	verified, err := VerifyArtifact(context.Background(), packersdk.TestUi(t), a, v)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	report, err := FromArtifact(verified)
+	if err != nil || report == nil || !report.Passed() {
+		t.Fatalf("bad report %#v: %v", report, err)
+	}
+	if verified.State("region") != "eu-west-1" {
+		t.Fatal("the state of the artifact should be kept")
+	}
+
+	// Reports received over RPC are generic maps.
+	a.StateValues[StateKey] = map[string]interface{}{
+		"Artifact": "id",
+		"Results":  []interface{}{map[string]interface{}{"Check": "size", "Status": "failed", "Message": "too big"}},
+	}
+	report, err = FromArtifact(a)
+	if err != nil || report.Passed() || report.Results[0].Message != "too big" {
+		t.Fatalf("bad report %#v: %v", report, err)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ScanConfig
+
+package vulnscan
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Config holds the vulnerability_scan block, scanning the guest once it is
+// provisioned:
+//
+//	vulnerability_scan {
+//	  scanner          = "trivy"
+//	  fail_on_severity = "HIGH"
+//	  ignore_unfixed   = true
+//	}
+//
+// Embed it in your builder config using the `mapstructure:",squash"` struct
+// tag.
+type Config struct {
+	// Scans the guest for known vulnerabilities once it is provisioned.
+	// The guest isn't scanned when the block is unset.
+	VulnerabilityScan ScanConfig `mapstructure:"vulnerability_scan" required:"false"`
+}
+
+// ScanConfig configures the scan of the guest and the policy gating the
+// build on its results.
+type ScanConfig struct {
+	// The scanner installed on the guest: `trivy` or `grype`.
+	Scanner string `mapstructure:"scanner" required:"true"`
+	// The directory of the guest to scan. Defaults to `/`.
+	Path string `mapstructure:"path" required:"false"`
+	// The command running the scanner, when it isn't in the PATH, like
+	// `/usr/local/bin/trivy`.
+	Command string `mapstructure:"command" required:"false"`
+	// Runs the scanner with sudo.
+	Sudo bool `mapstructure:"sudo" required:"false"`
+	// The lowest severity of the vulnerabilities failing the build: `LOW`,
+	// `MEDIUM`, `HIGH` or `CRITICAL`. By default the vulnerabilities are
+	// only reported.
+	FailOnSeverity string `mapstructure:"fail_on_severity" required:"false"`
+	// The IDs of the vulnerabilities that never fail the build, like
+	// `CVE-2023-4911`.
+	IgnoreVulnerabilities []string `mapstructure:"ignore_vulnerabilities" required:"false"`
+	// Don't fail the build on vulnerabilities without a fix yet.
+	IgnoreUnfixed bool `mapstructure:"ignore_unfixed" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	s := c.VulnerabilityScan
+	if !s.Enabled() {
+		if s.FailOnSeverity != "" {
+			return []error{fmt.Errorf("vulnerability_scan.scanner must be set")}
+		}
+		return nil
+	}
+	var errs []error
+	if err := Tool(s.Scanner).Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("vulnerability_scan.scanner: %s", err))
+	}
+	if s.FailOnSeverity != "" {
+		if _, err := ParseSeverity(s.FailOnSeverity); err != nil {
+			errs = append(errs, fmt.Errorf("vulnerability_scan.fail_on_severity: %s", err))
+		}
+	}
+	return errs
+}
+
+// Enabled tells whether the guest is scanned.
+func (c *ScanConfig) Enabled() bool {
+	return c.Scanner != ""
+}
+
+// GuestScanner returns the scanner of the guest, nil when it isn't scanned.
+func (c *ScanConfig) GuestScanner() *GuestScanner {
+	if !c.Enabled() {
+		return nil
+	}
+	return &GuestScanner{
+		Tool:    Tool(c.Scanner),
+		Path:    c.Path,
+		Command: c.Command,
+		Sudo:    c.Sudo,
+	}
+}
+
+// Policy returns the policy gating the build on the results of the scan.
+// Call it once the config is prepared.
+func (c *ScanConfig) Policy() *Policy {
+	p := &Policy{Ignore: c.IgnoreVulnerabilities, IgnoreUnfixed: c.IgnoreUnfixed}
+	if c.FailOnSeverity != "" {
+		p.FailOn, _ = ParseSeverity(c.FailOnSeverity)
+	}
+	return p
+}
+
+// Hook returns the hook scanning the guest, nil when it isn't scanned.
+func (c *ScanConfig) Hook() *Hook {
+	if !c.Enabled() {
+		return nil
+	}
+	return &Hook{Scanner: c.GuestScanner(), Policy: c.Policy()}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vulnscan
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Hook is a packer.Hook scanning the guest with Scanner, to run once the
+// guest is provisioned: builders run it right after the hook of
+// packer.HookProvision, through the same communicator. The reports of the
+// scans are kept for the builder to attach to its artifact with
+// WithReports, and the hook fails when they violate Policy.
+type Hook struct {
+	Scanner Scanner
+	// Policy, when set, fails the hook on the vulnerabilities it forbids.
+	Policy *Policy
+
+	l       sync.Mutex
+	reports []Report
+}
+
+func (h *Hook) Run(ctx context.Context, _ string, ui packersdk.Ui, comm packersdk.Communicator, _ interface{}) error {
+	if comm == nil {
+		return fmt.Errorf("a communicator is required to scan the guest for vulnerabilities")
+	}
+	report, err := h.Scanner.Scan(ctx, ui, comm)
+	if err != nil {
+		return fmt.Errorf("error scanning for vulnerabilities: %s", err)
+	}
+	ui.Say(report.Summary())
+
+	h.l.Lock()
+	h.reports = append(h.reports, *report)
+	h.l.Unlock()
+
+	if h.Policy == nil {
+		return nil
+	}
+	return h.Policy.Evaluate(*report)
+}
+
+// Reports returns the reports of the scans made by h.
+func (h *Hook) Reports() []Report {
+	h.l.Lock()
+	defer h.l.Unlock()
+	return append([]Report(nil), h.reports...)
+}
+
+// ScanArtifact scans the files of a with s, and returns a with the reports
+// attached. Post-processors use it to scan exported images; the artifact is
+// returned along with the *PolicyError when policy, if any, is violated.
+func ScanArtifact(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact, s *LocalScanner, policy *Policy) (packersdk.Artifact, error) {
+	reports, err := s.ScanArtifact(ctx, ui, a)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reports {
+		ui.Say(r.Summary())
+	}
+	a, err = WithReports(a, reports...)
+	if err != nil || policy == nil {
+		return a, err
+	}
+	return a, policy.Evaluate(reports...)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vulnscan
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestHook(t *testing.T) {
+	comm := &packersdk.MockCommunicator{StartStdout: trivyOutput}
+	hook := &Hook{Scanner: &GuestScanner{Tool: Trivy}, Policy: &Policy{FailOn: High}}
+	err := hook.Run(context.Background(), packersdk.HookProvision, packersdk.TestUi(t), comm, nil)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || len(policyErr.Violations) != 1 {
+		t.Fatalf("expected a policy violation, got %v", err)
+	}
+
+	reports := hook.Reports()
+	if len(reports) != 1 || len(reports[0].Vulnerabilities) != 2 {
+		t.Fatalf("bad reports: %#v", reports)
+	}
+	a, err := WithReports(&packersdk.MockArtifact{StateValues: map[string]interface{}{"foo": "bar"}}, reports...)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	a, err = WithReports(a, Report{Scanner: "grype", Target: "disk.qcow2"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	got, err := FromArtifact(a)
+	if err != nil || len(got) != 2 || !reflect.DeepEqual(got[0], reports[0]) {
+		t.Fatalf("bad reports: %#v, %v", got, err)
+	}
+	if a.State("foo") != "bar" {
+		t.Fatal("the state of the artifact should be kept")
+	}
+}
+
+func TestFromArtifact(t *testing.T) {
+	if reports, err := FromArtifact(&packersdk.MockArtifact{}); err != nil || reports != nil {
+		t.Fatalf("unexpected: %#v, %v", reports, err)
+	}
+
+	// The state of artifacts received over RPC.
+	a := &packersdk.MockArtifact{StateValues: map[string]interface{}{
+		StateKey: []interface{}{map[string]interface{}{
+			"Scanner": "trivy",
+			"Target":  "/",
+			"Vulnerabilities": []interface{}{
+				map[string]interface{}{"ID": "CVE-2023-4911", "Severity": "HIGH"},
+			},
+		}},
+	}}
+	reports, err := FromArtifact(a)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(reports) != 1 || reports[0].Vulnerabilities[0].Severity != High {
+		t.Fatalf("bad reports: %#v", reports)
+	}
+}
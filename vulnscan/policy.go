@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vulnscan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy is the gate failing builds whose images have vulnerabilities.
+type Policy struct {
+	// FailOn is the lowest severity failing the build. Builds never fail
+	// when it is empty.
+	FailOn Severity
+	// Ignore are the IDs of the vulnerabilities that never fail the build,
+	// once triaged.
+	Ignore []string
+	// IgnoreUnfixed doesn't fail the build on vulnerabilities without a
+	// fix yet.
+	IgnoreUnfixed bool
+}
+
+// PolicyError lists the vulnerabilities violating a Policy.
+type PolicyError struct {
+	FailOn     Severity
+	Violations []Vulnerability
+}
+
+func (e *PolicyError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d vulnerabilities of severity %s or higher:", len(e.Violations), e.FailOn)
+	for _, v := range e.Violations {
+		fmt.Fprintf(&b, "\n  - %s (%s): %s %s", v.ID, v.Severity, v.Package, v.InstalledVersion)
+		if v.FixedVersion != "" {
+			fmt.Fprintf(&b, ", fixed in %s", v.FixedVersion)
+		}
+	}
+	return b.String()
+}
+
+// Evaluate returns a *PolicyError when reports hold vulnerabilities failing
+// the build.
+func (p *Policy) Evaluate(reports ...Report) error {
+	if p.FailOn == "" {
+		return nil
+	}
+	ignored := make(map[string]bool, len(p.Ignore))
+	for _, id := range p.Ignore {
+		ignored[id] = true
+	}
+
+	failed := &PolicyError{FailOn: p.FailOn}
+	for _, r := range reports {
+		for _, v := range r.Vulnerabilities {
+			if ignored[v.ID] || (p.IgnoreUnfixed && v.FixedVersion == "") || !v.Severity.AtLeast(p.FailOn) {
+				continue
+			}
+			failed.Violations = append(failed.Violations, v)
+		}
+	}
+	if len(failed.Violations) > 0 {
+		return failed
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vulnscan
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	report := Report{Scanner: "trivy", Target: "/", Vulnerabilities: expectedVulnerabilities}
+	for _, tc := range []struct {
+		name       string
+		policy     Policy
+		violations []string
+	}{
+		{"no gate", Policy{}, nil},
+		{"high", Policy{FailOn: High}, []string{"CVE-2023-4911"}},
+		{"low", Policy{FailOn: Low}, []string{"CVE-2023-4911", "CVE-2011-3374"}},
+		{"critical", Policy{FailOn: Critical}, nil},
+		{"ignored", Policy{FailOn: Low, Ignore: []string{"CVE-2023-4911"}}, []string{"CVE-2011-3374"}},
+		{"unfixed", Policy{FailOn: Low, IgnoreUnfixed: true}, []string{"CVE-2023-4911"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Evaluate(report)
+			if tc.violations == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("expected a *PolicyError, got %v", err)
+			}
+			var ids []string
+			for _, v := range policyErr.Violations {
+				ids = append(ids, v.ID)
+			}
+			if len(ids) != len(tc.violations) || ids[0] != tc.violations[0] {
+				t.Fatalf("bad violations: %v", ids)
+			}
+		})
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for s, expected := range map[string]Severity{"high": High, "Critical": Critical, "NEGLIGIBLE": Low, " medium ": Medium} {
+		if severity, err := ParseSeverity(s); err != nil || severity != expected {
+			t.Fatalf("%q: got %s, %v", s, severity, err)
+		}
+	}
+	if _, err := ParseSeverity("severe"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestConfig_Prepare(t *testing.T) {
+	c := &Config{}
+	if errs := c.Prepare(nil); len(errs) != 0 || c.VulnerabilityScan.Hook() != nil {
+		t.Fatalf("unexpected: %v", errs)
+	}
+
+	c = &Config{VulnerabilityScan: ScanConfig{Scanner: "clair", FailOnSeverity: "severe"}}
+	if errs := c.Prepare(nil); len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+
+	c = &Config{VulnerabilityScan: ScanConfig{Scanner: "grype", FailOnSeverity: "high"}}
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	hook := c.VulnerabilityScan.Hook()
+	if hook.Policy.FailOn != High || hook.Scanner.(*GuestScanner).Tool != Grype {
+		t.Fatalf("bad hook: %#v", hook)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package vulnscan scans the images built by Packer for known
+// vulnerabilities, with scanners like trivy or grype, either on the guest
+// once it is provisioned or against the exported artifact. Builders attach
+// the reports to their artifact with WithReports, and fail the build with a
+// Policy.
+package vulnscan
+
+import (
+	"fmt"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/mitchellh/mapstructure"
+)
+
+// StateKey is the name under which artifacts return their reports from
+// State.
+const StateKey = "vulnerability_reports"
+
+// Severity is the severity of a vulnerability, normalized across scanners.
+type Severity string
+
+// Severities, from the lowest to the highest.
+const (
+	Unknown  Severity = "UNKNOWN"
+	Low      Severity = "LOW"
+	Medium   Severity = "MEDIUM"
+	High     Severity = "HIGH"
+	Critical Severity = "CRITICAL"
+)
+
+var severities = []Severity{Unknown, Low, Medium, High, Critical}
+
+// ParseSeverity parses the severity reported by a scanner, whatever its
+// case. The negligible severity of grype is Low.
+func ParseSeverity(s string) (Severity, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "NEGLIGIBLE" {
+		return Low, nil
+	}
+	for _, severity := range severities {
+		if s == string(severity) {
+			return severity, nil
+		}
+	}
+	return Unknown, fmt.Errorf("unknown severity %q, expected one of %s", s, severities)
+}
+
+func (s Severity) rank() int {
+	for i, severity := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return 0
+}
+
+// AtLeast tells whether s is as severe as other or more.
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// Vulnerability is a vulnerability found in a package.
+type Vulnerability struct {
+	// ID is the identifier of the vulnerability, like a CVE.
+	ID string
+	// Package is the name of the vulnerable package.
+	Package string
+	// InstalledVersion is the version of the package installed.
+	InstalledVersion string
+	// FixedVersion is the first version of the package fixing the
+	// vulnerability, empty when there is no fix yet.
+	FixedVersion string
+	Severity     Severity
+	Title        string
+}
+
+// Report lists the vulnerabilities found by a scan.
+type Report struct {
+	// Scanner is the tool that made the scan, like "trivy".
+	Scanner string
+	// Target is what was scanned: a path on the guest or an artifact file.
+	Target          string
+	Vulnerabilities []Vulnerability
+}
+
+// Counts returns the number of vulnerabilities of r by severity.
+func (r *Report) Counts() map[Severity]int {
+	counts := make(map[Severity]int)
+	for _, v := range r.Vulnerabilities {
+		counts[v.Severity]++
+	}
+	return counts
+}
+
+// Summary describes r in one line, for the Ui.
+func (r *Report) Summary() string {
+	counts := r.Counts()
+	parts := make([]string, 0, len(severities))
+	for i := len(severities) - 1; i >= 0; i-- {
+		if n := counts[severities[i]]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ToLower(string(severities[i]))))
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%s found no vulnerabilities in %s", r.Scanner, r.Target)
+	}
+	return fmt.Sprintf("%s found %d vulnerabilities in %s: %s", r.Scanner, len(r.Vulnerabilities), r.Target, strings.Join(parts, ", "))
+}
+
+// reportsArtifact is an artifact holding scan reports.
+type reportsArtifact struct {
+	packersdk.Artifact
+	reports []Report
+}
+
+func (a *reportsArtifact) State(name string) interface{} {
+	if name == StateKey {
+		return a.reports
+	}
+	return a.Artifact.State(name)
+}
+
+// WithReports returns a, returning reports from State under StateKey, along
+// with the ones it already holds.
+func WithReports(a packersdk.Artifact, reports ...Report) (packersdk.Artifact, error) {
+	existing, err := FromArtifact(a)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := a.(*reportsArtifact); ok {
+		a = r.Artifact
+	}
+	return &reportsArtifact{
+		Artifact: a,
+		reports:  append(existing, reports...),
+	}, nil
+}
+
+// FromArtifact returns the reports attached to an artifact, nil when it was
+// not scanned.
+func FromArtifact(a packersdk.Artifact) ([]Report, error) {
+	state := a.State(StateKey)
+	switch s := state.(type) {
+	case nil:
+		return nil, nil
+	case []Report:
+		return append([]Report(nil), s...), nil
+	}
+	// Artifacts of plugins are received over RPC as generic lists and maps.
+	var reports []Report
+	if err := mapstructure.Decode(state, &reports); err != nil {
+		return nil, fmt.Errorf("invalid vulnerability reports of artifact %s: %s", a.Id(), err)
+	}
+	return reports, nil
+}
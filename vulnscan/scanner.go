@@ -0,0 +1,236 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vulnscan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Scanner scans the guest of a build once it is provisioned. Builders run
+// scanners through a Hook, or implement their own, for example to scan a
+// snapshot with the API of their cloud.
+type Scanner interface {
+	// Scan scans the guest, running commands through comm, and returns the
+	// vulnerabilities found. It fails when the scan couldn't be made, not
+	// when vulnerabilities are found: that is the job of a Policy.
+	Scan(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator) (*Report, error)
+}
+
+// Tool is a vulnerability scanner whose JSON output can be parsed.
+type Tool string
+
+const (
+	// Trivy is https://github.com/aquasecurity/trivy.
+	Trivy Tool = "trivy"
+	// Grype is https://github.com/anchore/grype.
+	Grype Tool = "grype"
+)
+
+// Validate returns an error when t is not a supported tool.
+func (t Tool) Validate() error {
+	switch t {
+	case Trivy, Grype:
+		return nil
+	}
+	return fmt.Errorf("unsupported scanner %q, expected %q or %q", t, Trivy, Grype)
+}
+
+// guestArgs returns the arguments scanning the file system of the guest
+// under path.
+func (t Tool) guestArgs(path string) []string {
+	if t == Grype {
+		return []string{"dir:" + path, "--output", "json", "--quiet"}
+	}
+	return []string{"rootfs", "--format", "json", "--quiet", path}
+}
+
+// artifactArgs returns the arguments scanning an artifact file, like a disk
+// image.
+func (t Tool) artifactArgs(path string) []string {
+	if t == Grype {
+		return []string{"file:" + path, "--output", "json", "--quiet"}
+	}
+	return []string{"vm", "--format", "json", "--quiet", path}
+}
+
+// Parse parses the JSON output of t scanning target.
+func (t Tool) Parse(target string, output []byte) (*Report, error) {
+	report := &Report{Scanner: string(t), Target: target}
+	var err error
+	switch t {
+	case Trivy:
+		report.Vulnerabilities, err = parseTrivy(output)
+	case Grype:
+		report.Vulnerabilities, err = parseGrype(output)
+	default:
+		err = t.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the output of %s: %s", t, err)
+	}
+	return report, nil
+}
+
+func parseTrivy(output []byte) ([]Vulnerability, error) {
+	var out struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID  string
+				PkgName          string
+				InstalledVersion string
+				FixedVersion     string
+				Severity         string
+				Title            string
+			}
+		}
+	}
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, err
+	}
+	var vulns []Vulnerability
+	for _, result := range out.Results {
+		for _, v := range result.Vulnerabilities {
+			severity, err := ParseSeverity(v.Severity)
+			if err != nil {
+				log.Printf("[WARN] %s: %s", v.VulnerabilityID, err)
+			}
+			vulns = append(vulns, Vulnerability{
+				ID:               v.VulnerabilityID,
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Severity:         severity,
+				Title:            v.Title,
+			})
+		}
+	}
+	return vulns, nil
+}
+
+func parseGrype(output []byte) ([]Vulnerability, error) {
+	var out struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID          string `json:"id"`
+				Severity    string `json:"severity"`
+				Description string `json:"description"`
+				Fix         struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, err
+	}
+	var vulns []Vulnerability
+	for _, m := range out.Matches {
+		severity, err := ParseSeverity(m.Vulnerability.Severity)
+		if err != nil {
+			log.Printf("[WARN] %s: %s", m.Vulnerability.ID, err)
+		}
+		vulns = append(vulns, Vulnerability{
+			ID:               m.Vulnerability.ID,
+			Package:          m.Artifact.Name,
+			InstalledVersion: m.Artifact.Version,
+			FixedVersion:     strings.Join(m.Vulnerability.Fix.Versions, ", "),
+			Severity:         severity,
+			Title:            m.Vulnerability.Description,
+		})
+	}
+	return vulns, nil
+}
+
+// GuestScanner is a Scanner running Tool on a Unix guest, where it must be
+// installed.
+type GuestScanner struct {
+	Tool Tool
+	// Path is the directory of the guest scanned, "/" by default.
+	Path string
+	// Command is the command running the tool, in front of its arguments,
+	// like "/usr/local/bin/trivy". It defaults to the name of the tool.
+	Command string
+	// Sudo runs the tool with sudo, to read every file of the guest.
+	Sudo bool
+}
+
+func (s *GuestScanner) Scan(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator) (*Report, error) {
+	if err := s.Tool.Validate(); err != nil {
+		return nil, err
+	}
+	path := s.Path
+	if path == "" {
+		path = "/"
+	}
+	command := s.Command
+	if command == "" {
+		command = string(s.Tool)
+	}
+	for _, arg := range s.Tool.guestArgs(path) {
+		command += fmt.Sprintf(" '%s'", arg)
+	}
+	if s.Sudo {
+		command = "sudo " + command
+	}
+
+	ui.Say(fmt.Sprintf("Scanning %s for vulnerabilities with %s...", path, s.Tool))
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{Command: command, Stdout: &stdout, Stderr: &stderr}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return nil, err
+	}
+	if status := cmd.Wait(); status != 0 {
+		return nil, fmt.Errorf("%s exited with status %d: %s", s.Tool, status, strings.TrimSpace(stderr.String()))
+	}
+	return s.Tool.Parse(path, stdout.Bytes())
+}
+
+// LocalScanner runs Tool where Packer runs, against the files of exported
+// artifacts, like disk images.
+type LocalScanner struct {
+	Tool Tool
+	// Executable is the path of the tool, looked up in the PATH by default.
+	Executable string
+}
+
+// ScanArtifact scans every file of a and returns a report per file.
+func (s *LocalScanner) ScanArtifact(ctx context.Context, ui packersdk.Ui, a packersdk.Artifact) ([]Report, error) {
+	if err := s.Tool.Validate(); err != nil {
+		return nil, err
+	}
+	executable := s.Executable
+	if executable == "" {
+		executable = string(s.Tool)
+	}
+
+	var reports []Report
+	for _, file := range a.Files() {
+		ui.Say(fmt.Sprintf("Scanning %s for vulnerabilities with %s...", file, s.Tool))
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, executable, s.Tool.artifactArgs(file)...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("error scanning %s: %s: %s", file, err, strings.TrimSpace(stderr.String()))
+		}
+		report, err := s.Tool.Parse(file, stdout.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
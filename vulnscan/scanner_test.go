@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package vulnscan
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const trivyOutput = `{
+  "SchemaVersion": 2,
+  "ArtifactName": "/",
+  "Results": [
+    {
+      "Target": "debian 12.1",
+      "Vulnerabilities": [
+        {
+          "VulnerabilityID": "CVE-2023-4911",
+          "PkgName": "libc6",
+          "InstalledVersion": "2.36-9",
+          "FixedVersion": "2.36-9+deb12u3",
+          "Severity": "HIGH",
+          "Title": "glibc: buffer overflow in ld.so"
+        },
+        {
+          "VulnerabilityID": "CVE-2011-3374",
+          "PkgName": "apt",
+          "InstalledVersion": "2.6.1",
+          "Severity": "LOW"
+        }
+      ]
+    },
+    {"Target": "usr/local/bin/tool"}
+  ]
+}`
+
+const grypeOutput = `{
+  "matches": [
+    {
+      "vulnerability": {
+        "id": "CVE-2023-4911",
+        "severity": "High",
+        "description": "glibc: buffer overflow in ld.so",
+        "fix": {"versions": ["2.36-9+deb12u3"], "state": "fixed"}
+      },
+      "artifact": {"name": "libc6", "version": "2.36-9"}
+    },
+    {
+      "vulnerability": {"id": "CVE-2011-3374", "severity": "Negligible", "fix": {"versions": [], "state": "not-fixed"}},
+      "artifact": {"name": "apt", "version": "2.6.1"}
+    }
+  ]
+}`
+
+var expectedVulnerabilities = []Vulnerability{
+	{
+		ID:               "CVE-2023-4911",
+		Package:          "libc6",
+		InstalledVersion: "2.36-9",
+		FixedVersion:     "2.36-9+deb12u3",
+		Severity:         High,
+		Title:            "glibc: buffer overflow in ld.so",
+	},
+	{
+		ID:               "CVE-2011-3374",
+		Package:          "apt",
+		InstalledVersion: "2.6.1",
+		Severity:         Low,
+	},
+}
+
+func TestTool_Parse(t *testing.T) {
+	for _, tc := range []struct {
+		tool   Tool
+		output string
+	}{
+		{Trivy, trivyOutput},
+		{Grype, grypeOutput},
+	} {
+		report, err := tc.tool.Parse("/", []byte(tc.output))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.tool, err)
+		}
+		if report.Scanner != string(tc.tool) || report.Target != "/" {
+			t.Fatalf("%s: bad report: %#v", tc.tool, report)
+		}
+		if !reflect.DeepEqual(report.Vulnerabilities, expectedVulnerabilities) {
+			t.Fatalf("%s: bad vulnerabilities: %#v", tc.tool, report.Vulnerabilities)
+		}
+	}
+
+	if _, err := Trivy.Parse("/", []byte("not json")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := Tool("clair").Parse("/", []byte("{}")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestGuestScanner(t *testing.T) {
+	comm := &packersdk.MockCommunicator{StartStdout: trivyOutput}
+	s := &GuestScanner{Tool: Trivy, Sudo: true}
+	report, err := s.Scan(context.Background(), packersdk.TestUi(t), comm)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected := "sudo trivy 'rootfs' '--format' 'json' '--quiet' '/'"; comm.StartCmd.Command != expected {
+		t.Fatalf("bad command: %s", comm.StartCmd.Command)
+	}
+	if len(report.Vulnerabilities) != 2 {
+		t.Fatalf("bad report: %#v", report)
+	}
+
+	comm = &packersdk.MockCommunicator{StartExitStatus: 127, StartStderr: "grype: command not found"}
+	s = &GuestScanner{Tool: Grype, Path: "/opt"}
+	if _, err := s.Scan(context.Background(), packersdk.TestUi(t), comm); err == nil {
+		t.Fatal("expected an error")
+	}
+	if expected := "grype 'dir:/opt' '--output' 'json' '--quiet'"; comm.StartCmd.Command != expected {
+		t.Fatalf("bad command: %s", comm.StartCmd.Command)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type WebhookConfig
+
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Config holds the webhook blocks, notified of the life of the build:
+//
+//	webhook {
+//	  url    = "https://events.example.com/packer"
+//	  secret = var.webhook_secret
+//	  events = ["build_started", "artifact_created", "build_finished"]
+//	}
+//
+// Embed it in your builder config using the `mapstructure:",squash"` struct
+// tag.
+type Config struct {
+	// The webhooks the events of the build are POSTed to, as JSON.
+	Webhooks []WebhookConfig `mapstructure:"webhook" required:"false"`
+}
+
+// WebhookConfig configures a webhook.
+type WebhookConfig struct {
+	// The URL the events are POSTed to.
+	URL string `mapstructure:"url" required:"true"`
+	// The key the requests are signed with: the `X-Packer-Signature` header
+	// holds `sha256=` followed by the hexadecimal HMAC-SHA256 of the body.
+	Secret string `mapstructure:"secret" required:"false" sensitive:"true"`
+	// The events sent: `build_started`, `step_completed`, `step_failed`,
+	// `provision_failed`, `artifact_created` and `build_finished`. Defaults
+	// to all of them.
+	Events []string `mapstructure:"events" required:"false"`
+	// Headers set in the requests, for authentication for example.
+	Headers map[string]string `mapstructure:"headers" required:"false"`
+	// How long a request can take. Defaults to `10s`.
+	Timeout time.Duration `mapstructure:"timeout" required:"false"`
+	// The number of times a failed request is sent again. Defaults to 3;
+	// set it to -1 not to retry.
+	Retries int `mapstructure:"retries" required:"false"`
+}
+
+func (c *Config) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	for i := range c.Webhooks {
+		w := &c.Webhooks[i]
+		if w.URL == "" {
+			errs = append(errs, fmt.Errorf("webhook[%d].url must be set", i))
+		} else if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("webhook[%d].url must be an http or https URL, got %q", i, w.URL))
+		}
+		for _, e := range w.Events {
+			if !validEventType(EventType(e)) {
+				errs = append(errs, fmt.Errorf("webhook[%d].events: unknown event %q, expected one of %v", i, e, EventTypes))
+			}
+		}
+		if w.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("webhook[%d].timeout must be positive", i))
+		}
+		if w.Retries == 0 {
+			w.Retries = 3
+		}
+	}
+	return errs
+}
+
+func validEventType(t EventType) bool {
+	for _, valid := range EventTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier returns the notifier of the webhooks, nil when there are none.
+// Call it once the config is prepared.
+func (c *Config) Notifier(buildName, builderType string) *Notifier {
+	if len(c.Webhooks) == 0 {
+		return nil
+	}
+	n := &Notifier{BuildName: buildName, BuilderType: builderType}
+	for _, w := range c.Webhooks {
+		endpoint := Endpoint{
+			URL:     w.URL,
+			Secret:  w.Secret,
+			Headers: w.Headers,
+			Timeout: w.Timeout,
+		}
+		if w.Retries > 0 {
+			endpoint.Retries = w.Retries
+		}
+		for _, e := range w.Events {
+			endpoint.Events = append(endpoint.Events, EventType(e))
+		}
+		n.Endpoints = append(n.Endpoints, endpoint)
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	EventBuildStarted    EventType = "build_started"
+	EventStepCompleted   EventType = "step_completed"
+	EventStepFailed      EventType = "step_failed"
+	EventProvisionFailed EventType = "provision_failed"
+	EventArtifactCreated EventType = "artifact_created"
+	EventBuildFinished   EventType = "build_finished"
+)
+
+// EventTypes are all the types of events, in the order they happen in a
+// build.
+var EventTypes = []EventType{
+	EventBuildStarted,
+	EventStepCompleted,
+	EventStepFailed,
+	EventProvisionFailed,
+	EventArtifactCreated,
+	EventBuildFinished,
+}
+
+// Event is a step of the life of a build, POSTed as JSON to the webhooks.
+type Event struct {
+	Type EventType `json:"type"`
+	// ID identifies the event, and is the same across its retries, for
+	// receivers to drop the duplicates.
+	ID          string    `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	BuildName   string    `json:"build_name,omitempty"`
+	BuilderType string    `json:"builder_type,omitempty"`
+	// Step is the name of the step of step events.
+	Step string `json:"step,omitempty"`
+	// Error is why the step, provisioning or build failed.
+	Error string `json:"error,omitempty"`
+	// Artifact is the artifact of artifact_created events.
+	Artifact *Artifact `json:"artifact,omitempty"`
+}
+
+// Artifact describes an artifact in an Event.
+type Artifact struct {
+	BuilderID   string   `json:"builder_id"`
+	ID          string   `json:"id"`
+	Files       []string `json:"files,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// ArtifactOf describes a.
+func ArtifactOf(a packersdk.Artifact) *Artifact {
+	return &Artifact{
+		BuilderID:   a.BuilderId(),
+		ID:          a.Id(),
+		Files:       a.Files(),
+		Description: a.String(),
+	}
+}
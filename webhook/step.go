@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// WithEvents returns steps, each wrapped to send a step_completed event
+// when it continues and a step_failed event when it halts, with the error it
+// put in the state bag. Failing to send events doesn't fail the build.
+func WithEvents(n *Notifier, steps ...multistep.Step) []multistep.Step {
+	if n == nil {
+		return steps
+	}
+	wrapped := make([]multistep.Step, len(steps))
+	for i, step := range steps {
+		if step == nil {
+			continue
+		}
+		wrapped[i] = &eventStep{step: step, notifier: n}
+	}
+	return wrapped
+}
+
+type eventStep struct {
+	step     multistep.Step
+	notifier *Notifier
+}
+
+// InnerStepName makes the DebugRunner name the wrapped step.
+func (s *eventStep) InnerStepName() string {
+	if wrapped, ok := s.step.(multistep.StepWrapper); ok {
+		return wrapped.InnerStepName()
+	}
+	return reflect.Indirect(reflect.ValueOf(s.step)).Type().Name()
+}
+
+func (s *eventStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	action := s.step.Run(ctx, state)
+
+	e := Event{Type: EventStepCompleted, Step: s.InnerStepName()}
+	if action == multistep.ActionHalt {
+		e.Type = EventStepFailed
+		if err, ok := state.Get("error").(error); ok {
+			e.Error = err.Error()
+		}
+	}
+	s.notifier.notify(ctx, e)
+	return action
+}
+
+func (s *eventStep) Cleanup(state multistep.StateBag) {
+	s.step.Cleanup(state)
+}
+
+// Hook is a packer.Hook running Hook, and sending a provision_failed event
+// when it fails to provision the guest.
+type Hook struct {
+	Hook     packersdk.Hook
+	Notifier *Notifier
+}
+
+func (h *Hook) Run(ctx context.Context, name string, ui packersdk.Ui, comm packersdk.Communicator, data interface{}) error {
+	err := h.Hook.Run(ctx, name, ui, comm, data)
+	if err != nil && name == packersdk.HookProvision {
+		h.Notifier.notify(ctx, Event{Type: EventProvisionFailed, Error: err.Error()})
+	}
+	return err
+}
+
+// BuildStarted sends a build_started event.
+func (n *Notifier) BuildStarted(ctx context.Context) {
+	n.notify(ctx, Event{Type: EventBuildStarted})
+}
+
+// ArtifactCreated sends an artifact_created event for a.
+func (n *Notifier) ArtifactCreated(ctx context.Context, a packersdk.Artifact) {
+	n.notify(ctx, Event{Type: EventArtifactCreated, Artifact: ArtifactOf(a)})
+}
+
+// BuildFinished sends a build_finished event, with the error of the build
+// if it failed.
+func (n *Notifier) BuildFinished(ctx context.Context, err error) {
+	e := Event{Type: EventBuildFinished}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	n.notify(ctx, e)
+}
+
+// notify sends e, only logging failures: webhooks don't fail builds.
+func (n *Notifier) notify(ctx context.Context, e Event) {
+	if err := n.Notify(ctx, e); err != nil {
+		log.Printf("[WARN] %s", fmt.Errorf("webhook: %w", err))
+	}
+}
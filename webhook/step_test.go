@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type stepOK struct{}
+
+func (stepOK) Run(context.Context, multistep.StateBag) multistep.StepAction {
+	return multistep.ActionContinue
+}
+
+func (stepOK) Cleanup(multistep.StateBag) {}
+
+type stepFail struct{}
+
+func (stepFail) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	state.Put("error", errors.New("boom"))
+	return multistep.ActionHalt
+}
+
+func (stepFail) Cleanup(multistep.StateBag) {}
+
+type failingHook struct{}
+
+func (failingHook) Run(context.Context, string, packersdk.Ui, packersdk.Communicator, interface{}) error {
+	return errors.New("script failed")
+}
+
+func TestWithEvents(t *testing.T) {
+	r := new(testReceiver)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	n := testNotifier(server.URL, Endpoint{})
+
+	runner := &multistep.BasicRunner{Steps: WithEvents(n, &stepOK{}, nil, &stepFail{}, &stepOK{})}
+	runner.Run(context.Background(), new(multistep.BasicStateBag))
+
+	hook := &Hook{Hook: failingHook{}, Notifier: n}
+	if err := hook.Run(context.Background(), packersdk.HookProvision, nil, nil, nil); err == nil {
+		t.Fatal("the error of the hook should be returned")
+	}
+	n.ArtifactCreated(context.Background(), &packersdk.MockArtifact{IdValue: "ami-1"})
+
+	if len(r.events) != 4 {
+		t.Fatalf("bad events: %#v", r.events)
+	}
+	if e := r.events[0]; e.Type != EventStepCompleted || e.Step != "stepOK" {
+		t.Fatalf("bad event: %#v", e)
+	}
+	if e := r.events[1]; e.Type != EventStepFailed || e.Step != "stepFail" || e.Error != "boom" {
+		t.Fatalf("bad event: %#v", e)
+	}
+	if e := r.events[2]; e.Type != EventProvisionFailed || e.Error != "script failed" {
+		t.Fatalf("bad event: %#v", e)
+	}
+	if e := r.events[3]; e.Type != EventArtifactCreated || e.Artifact.ID != "ami-1" {
+		t.Fatalf("bad event: %#v", e)
+	}
+}
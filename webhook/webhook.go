@@ -0,0 +1,198 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package webhook POSTs the events of the life of a build, like its start
+// or the creation of its artifact, to webhooks, for platform teams to feed
+// their event pipelines. Requests are signed with HMAC-SHA256 and retried
+// on failure.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+const (
+	// SignatureHeader holds the signature of the body of the requests, see
+	// Sign.
+	SignatureHeader = "X-Packer-Signature"
+	// EventHeader holds the type of the event.
+	EventHeader = "X-Packer-Event"
+	// DeliveryHeader holds the ID of the event.
+	DeliveryHeader = "X-Packer-Delivery"
+)
+
+// DefaultTimeout is how long a request to a webhook can take by default.
+const DefaultTimeout = 10 * time.Second
+
+// Endpoint is a webhook events are POSTed to.
+type Endpoint struct {
+	URL string
+	// Secret, if set, is the key the requests are signed with.
+	Secret string
+	// Events are the types of the events sent, all of them when empty.
+	Events []EventType
+	// Headers are set in the requests, for authentication for example.
+	Headers map[string]string
+	// Timeout is how long each request can take, DefaultTimeout when 0.
+	Timeout time.Duration
+	// Retries is the number of times a failed request is sent again.
+	// Requests failing with a client error, other than 429, are not.
+	Retries int
+}
+
+// Wants tells whether events of type t are sent to e.
+func (e *Endpoint) Wants(t EventType) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, want := range e.Events {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier sends events to webhooks. It is safe to be used from multiple
+// goroutines.
+type Notifier struct {
+	Endpoints []Endpoint
+	// BuildName and BuilderType are set in the events that don't have them.
+	BuildName   string
+	BuilderType string
+	// Client sends the requests, http.DefaultClient when nil.
+	Client *http.Client
+	// Backoff returns how long to wait before a retry, 2s when nil.
+	Backoff func() time.Duration
+}
+
+// Notify sends e to the endpoints that want it, completing it with an ID
+// and a timestamp when it has none. It returns the errors of the endpoints
+// e couldn't be sent to once retried, joined.
+func (n *Notifier) Notify(ctx context.Context, e Event) error {
+	if n == nil {
+		return nil
+	}
+	if e.ID == "" {
+		e.ID = uuid.TimeOrderedUUID()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	e.Timestamp = e.Timestamp.UTC()
+	if e.BuildName == "" {
+		e.BuildName = n.BuildName
+	}
+	if e.BuilderType == "" {
+		e.BuilderType = n.BuilderType
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range n.Endpoints {
+		endpoint := &n.Endpoints[i]
+		if !endpoint.Wants(e.Type) {
+			continue
+		}
+		if err := n.send(ctx, endpoint, e, body); err != nil {
+			errs = append(errs, fmt.Errorf("sending %s event to %s: %w", e.Type, endpoint.URL, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// statusError is a request answered with an unsuccessful status code.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.code, http.StatusText(e.code))
+}
+
+func (n *Notifier) send(ctx context.Context, endpoint *Endpoint, e Event, body []byte) error {
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	timeout := endpoint.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	attempts := 0
+	return retry.Config{
+		RetryDelay: n.Backoff,
+		ShouldRetry: func(err error) bool {
+			if attempts > endpoint.Retries {
+				return false
+			}
+			var serr *statusError
+			if errors.As(err, &serr) {
+				return serr.code >= 500 || serr.code == http.StatusTooManyRequests
+			}
+			return ctx.Err() == nil
+		},
+	}.Run(ctx, func(ctx context.Context) error {
+		attempts++
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for k, v := range endpoint.Headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(EventHeader, string(e.Type))
+		req.Header.Set(DeliveryHeader, e.ID)
+		if endpoint.Secret != "" {
+			req.Header.Set(SignatureHeader, Sign(endpoint.Secret, body))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &statusError{code: resp.StatusCode}
+		}
+		log.Printf("[DEBUG] Sent %s event %s to webhook", e.Type, e.ID)
+		return nil
+	})
+}
+
+// Sign returns the signature of body with secret, as sent in the
+// SignatureHeader: "sha256=" followed by the hexadecimal HMAC-SHA256 of
+// body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify tells whether signature is the signature of body with secret, for
+// receivers to authenticate requests.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+)
+
+type testReceiver struct {
+	l        sync.Mutex
+	statuses []int
+	events   []Event
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	body, _ := io.ReadAll(req.Body)
+	var e Event
+	_ = json.Unmarshal(body, &e)
+	r.events = append(r.events, e)
+	r.headers = append(r.headers, req.Header)
+	r.bodies = append(r.bodies, body)
+
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func testNotifier(url string, endpoint Endpoint) *Notifier {
+	endpoint.URL = url
+	return &Notifier{
+		Endpoints: []Endpoint{endpoint},
+		BuildName: "ubuntu",
+		Backoff:   func() time.Duration { return time.Millisecond },
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	r := new(testReceiver)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	n := testNotifier(server.URL, Endpoint{
+		Secret:  "s3cr3t",
+		Events:  []EventType{EventBuildStarted},
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err := n.Notify(context.Background(), Event{Type: EventBuildStarted}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := n.Notify(context.Background(), Event{Type: EventStepCompleted}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(r.events) != 1 {
+		t.Fatalf("only the events the webhook wants should be sent: %#v", r.events)
+	}
+	e, h := r.events[0], r.headers[0]
+	if e.Type != EventBuildStarted || e.BuildName != "ubuntu" || e.ID == "" || e.Timestamp.IsZero() {
+		t.Fatalf("bad event: %#v", e)
+	}
+	if h.Get(EventHeader) != "build_started" || h.Get(DeliveryHeader) != e.ID || h.Get("Authorization") != "Bearer token" {
+		t.Fatalf("bad headers: %#v", h)
+	}
+	if sig := h.Get(SignatureHeader); !strings.HasPrefix(sig, "sha256=") || !Verify("s3cr3t", r.bodies[0], sig) {
+		t.Fatalf("bad signature: %q", sig)
+	}
+	if Verify("other", r.bodies[0], h.Get(SignatureHeader)) {
+		t.Fatal("the signature should depend on the secret")
+	}
+}
+
+func TestNotifier_Notify_retries(t *testing.T) {
+	r := &testReceiver{statuses: []int{http.StatusBadGateway, http.StatusTooManyRequests}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	n := testNotifier(server.URL, Endpoint{Retries: 3})
+	if err := n.Notify(context.Background(), Event{Type: EventBuildStarted}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(r.events) != 3 || r.events[0].ID != r.events[2].ID {
+		t.Fatalf("the event should be sent again, with the same ID: %#v", r.events)
+	}
+
+	r = &testReceiver{statuses: []int{http.StatusBadRequest}}
+	server = httptest.NewServer(r)
+	defer server.Close()
+	n = testNotifier(server.URL, Endpoint{Retries: 3})
+	err := n.Notify(context.Background(), Event{Type: EventBuildStarted})
+	if err == nil || !strings.Contains(err.Error(), "400") || len(r.events) != 1 {
+		t.Fatalf("client errors should not be retried: %v, %d requests", err, len(r.events))
+	}
+
+	r = &testReceiver{statuses: []int{500, 500, 500}}
+	server = httptest.NewServer(r)
+	defer server.Close()
+	n = testNotifier(server.URL, Endpoint{Retries: 1})
+	if err := n.Notify(context.Background(), Event{Type: EventBuildStarted}); err == nil || len(r.events) != 2 {
+		t.Fatalf("the retries should be exhausted: %v, %d requests", err, len(r.events))
+	}
+}
+
+func TestConfig_Prepare(t *testing.T) {
+	c := &Config{Webhooks: []WebhookConfig{
+		{URL: "https://example.com/hook", Events: []string{"build_started"}},
+		{URL: "ftp://example.com", Events: []string{"build_exploded"}},
+		{},
+	}}
+	errs := c.Prepare(nil)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+
+	c.Webhooks = c.Webhooks[:1]
+	c.Webhooks = append(c.Webhooks, WebhookConfig{URL: "http://example.com", Retries: -1})
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	n := c.Notifier("ubuntu", "null")
+	if len(n.Endpoints) != 2 || n.Endpoints[0].Retries != 3 || n.Endpoints[1].Retries != 0 {
+		t.Fatalf("bad endpoints: %#v", n.Endpoints)
+	}
+	if !n.Endpoints[0].Wants(EventBuildStarted) || n.Endpoints[0].Wants(EventBuildFinished) || !n.Endpoints[1].Wants(EventBuildFinished) {
+		t.Fatalf("bad events: %#v", n.Endpoints)
+	}
+}
+
+func TestConfig_sensitive(t *testing.T) {
+	c := &Config{Webhooks: []WebhookConfig{{URL: "https://example.com/hook", Secret: "s3cr3t"}}}
+	if secrets := config.SensitiveValues(c); len(secrets) != 1 || secrets[0] != "s3cr3t" {
+		t.Fatalf("expected the secret to be sensitive, got %q", secrets)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package windowsupdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	scriptPath = "C:/Windows/Temp/packer-windows-update.ps1"
+	logPath    = "C:/Windows/Temp/packer-windows-update.log"
+	// taskName is the scheduled task running the script: the Windows Update
+	// API refuses to install updates from a WinRM session, and the updates
+	// may restart the WinRM service.
+	taskName = "packer-windows-update"
+)
+
+// script searches, downloads and installs the updates, one at a time, and
+// reports what it does to the log, one line per event:
+//
+//	FOUND <count>
+//	UPDATE <index> <id> <KBs, comma separated or -> <size> <title>
+//	DOWNLOADING
+//	INSTALLING <index>
+//	RESULT <index> <result code> <HRESULT>
+//	REBOOT_REQUIRED
+//	ERROR <HRESULT> <message>
+//	DONE
+const script = `# Written by Packer
+$ErrorActionPreference = 'Stop'
+$log = %[1]s
+function Report([string]$line) { Add-Content -Path $log -Value $line -Encoding UTF8 }
+try {
+  if ((New-Object -ComObject Microsoft.Update.SystemInfo).RebootRequired) {
+    Report 'REBOOT_REQUIRED'
+    Report 'DONE'
+    exit 0
+  }
+  $session = New-Object -ComObject Microsoft.Update.Session
+  $session.ClientApplicationID = 'packer'
+  $search = $session.CreateUpdateSearcher().Search(%[2]s)
+  $updates = New-Object -ComObject Microsoft.Update.UpdateColl
+  foreach ($u in $search.Updates) {
+    if ($updates.Count -ge %[3]d) { break }
+    if (%[4]s -and $u.Title -notmatch %[4]s) { continue }
+    if (%[5]s -and $u.Title -match %[5]s) { continue }
+    if (-not $u.EulaAccepted) { $u.AcceptEula() }
+    [void]$updates.Add($u)
+  }
+  Report "FOUND $($updates.Count)"
+  for ($i = 0; $i -lt $updates.Count; $i++) {
+    $u = $updates.Item($i)
+    $kbs = ($u.KBArticleIDs | ForEach-Object { "KB$_" }) -join ','
+    if (-not $kbs) { $kbs = '-' }
+    Report "UPDATE $i $($u.Identity.UpdateID) $kbs $([int64]$u.MaxDownloadSize) $($u.Title)"
+  }
+  if ($updates.Count -gt 0) {
+    Report 'DOWNLOADING'
+    $downloader = $session.CreateUpdateDownloader()
+    $downloader.Updates = $updates
+    [void]$downloader.Download()
+    $reboot = $false
+    for ($i = 0; $i -lt $updates.Count; $i++) {
+      Report "INSTALLING $i"
+      $one = New-Object -ComObject Microsoft.Update.UpdateColl
+      [void]$one.Add($updates.Item($i))
+      $installer = $session.CreateUpdateInstaller()
+      $installer.Updates = $one
+      $result = $installer.Install()
+      Report ('RESULT {0} {1} 0x{2:X8}' -f $i, $result.ResultCode, $result.HResult)
+      if ($result.RebootRequired) { $reboot = $true }
+    }
+    if ($reboot) { Report 'REBOOT_REQUIRED' }
+  }
+} catch {
+  Report ('ERROR 0x{0:X8} {1}' -f $_.Exception.HResult, ($_.Exception.Message -replace '\s+', ' '))
+}
+Report 'DONE'
+`
+
+func renderScript(opts *Options) string {
+	return fmt.Sprintf(script, psQuote(logPath), psQuote(opts.SearchCriteria), opts.UpdateLimit, psQuote(opts.Include), psQuote(opts.Exclude))
+}
+
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Result codes of IUpdateInstaller.Install.
+const (
+	resultSucceeded          = 2
+	resultSucceededWithError = 3
+)
+
+// cycleLog is what the log of a cycle tells so far.
+type cycleLog struct {
+	found          int
+	updates        []Update
+	installing     int
+	results        map[int]result
+	rebootRequired bool
+	err            *Error
+	done           bool
+}
+
+type result struct {
+	code    int
+	hresult uint32
+}
+
+// parseLog parses the log of the script. Incomplete lines, which are being
+// written, are ignored.
+func parseLog(log string) *cycleLog {
+	l := &cycleLog{installing: -1, results: map[int]result{}}
+	log = strings.TrimPrefix(log, "\ufeff")
+	if i := strings.LastIndexByte(log, '\n'); i >= 0 {
+		log = log[:i]
+	} else {
+		log = ""
+	}
+	for _, line := range strings.Split(log, "\n") {
+		fields := strings.Fields(strings.TrimSpace(line))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "FOUND":
+			if len(fields) == 2 {
+				l.found, _ = strconv.Atoi(fields[1])
+			}
+		case "UPDATE":
+			if len(fields) < 5 {
+				continue
+			}
+			u := Update{ID: fields[2], Title: strings.Join(fields[5:], " ")}
+			if fields[3] != "-" {
+				u.KBs = strings.Split(fields[3], ",")
+			}
+			u.Size, _ = strconv.ParseInt(fields[4], 10, 64)
+			l.updates = append(l.updates, u)
+		case "INSTALLING":
+			if len(fields) == 2 {
+				l.installing, _ = strconv.Atoi(fields[1])
+			}
+		case "RESULT":
+			if len(fields) != 4 {
+				continue
+			}
+			i, _ := strconv.Atoi(fields[1])
+			code, _ := strconv.Atoi(fields[2])
+			l.results[i] = result{code: code, hresult: parseHResult(fields[3])}
+		case "REBOOT_REQUIRED":
+			l.rebootRequired = true
+		case "ERROR":
+			if len(fields) >= 2 {
+				l.err = &Error{Code: parseHResult(fields[1]), Message: strings.Join(fields[2:], " ")}
+			}
+		case "DONE":
+			l.done = true
+		}
+	}
+	return l
+}
+
+func parseHResult(s string) uint32 {
+	code, _ := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 32)
+	return uint32(code)
+}
@@ -0,0 +1,377 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package windowsupdate drives the Windows Update cycles of a Windows guest
+// from a provisioner: search the updates, install them, restart when they
+// require it, and search again until none is left.
+//
+// The updates are installed by a scheduled task running as SYSTEM, since the
+// Windows Update API refuses to install updates from a WinRM session, and
+// its progress is read from a log on the guest, so that the WinRM service
+// restarting during the installation doesn't lose it.
+package windowsupdate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/i18n"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+// DefaultSearchCriteria selects the software updates not installed yet.
+const DefaultSearchCriteria = "IsInstalled=0 and Type='Software' and IsHidden=0"
+
+// Options tell Run which updates to install and how.
+type Options struct {
+	// SearchCriteria is the criteria of the search of the updates, in the
+	// syntax of IUpdateSearcher.Search. Defaults to DefaultSearchCriteria.
+	SearchCriteria string
+	// Include and Exclude are .NET regular expressions the titles of the
+	// updates installed must and must not match, when set.
+	Include string
+	Exclude string
+	// UpdateLimit is the maximum number of updates installed per cycle,
+	// 1000 by default. A lower limit makes the cycles, and the time
+	// between restarts, shorter.
+	UpdateLimit int
+	// MaxCycles is the maximum number of cycles, each searching and
+	// installing updates then restarting if they require it, 10 by
+	// default. Run fails with ErrMaxCycles once they are exhausted.
+	MaxCycles int
+	// Tries is the maximum number of times a cycle is run when it fails
+	// with a transient Windows Update error, see IsTransient. Defaults to 3.
+	Tries int
+	// RetryDelay is the time to wait before running the cycle again, 1
+	// minute by default.
+	RetryDelay time.Duration
+	// PollInterval is how often the progress is read, 10 seconds by
+	// default.
+	PollInterval time.Duration
+	// RestartTimeout bounds the time the guest takes to restart, 30
+	// minutes by default.
+	RestartTimeout time.Duration
+	// Reconnect, if set, connects the communicator to the guest again after
+	// a restart, like communicator.ReconnectingCommunicator.Reconnect. It is
+	// called until it succeeds.
+	Reconnect func(ctx context.Context) error
+	// Ui, if set, is told about the progress.
+	Ui packersdk.Ui
+}
+
+func (o *Options) setDefaults() {
+	if o.SearchCriteria == "" {
+		o.SearchCriteria = DefaultSearchCriteria
+	}
+	if o.UpdateLimit <= 0 {
+		o.UpdateLimit = 1000
+	}
+	if o.MaxCycles <= 0 {
+		o.MaxCycles = 10
+	}
+	if o.Tries <= 0 {
+		o.Tries = 3
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = time.Minute
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 10 * time.Second
+	}
+	if o.RestartTimeout <= 0 {
+		o.RestartTimeout = 30 * time.Minute
+	}
+}
+
+// Update is a Windows update.
+type Update struct {
+	// ID is the update ID of the update.
+	ID    string
+	Title string
+	// KBs are the knowledge base articles of the update, like "KB5034441".
+	KBs []string
+	// Size is the maximum download size of the update, in bytes.
+	Size int64
+	// HResult is the error the installation failed with, for failed
+	// updates.
+	HResult uint32
+}
+
+func (u Update) String() string {
+	if len(u.KBs) > 0 {
+		return fmt.Sprintf("%s (%s)", u.Title, strings.Join(u.KBs, ", "))
+	}
+	return u.Title
+}
+
+// Result is what Run did.
+type Result struct {
+	// Cycles is the number of cycles run, Restarts the number of restarts.
+	Cycles   int
+	Restarts int
+	// Installed and Failed are the updates installed and the ones that
+	// failed to, in order.
+	Installed []Update
+	Failed    []Update
+}
+
+// ErrMaxCycles is wrapped by the error of Run when updates were still found
+// after Options.MaxCycles cycles.
+var ErrMaxCycles = errors.New("updates are still available after the maximum number of cycles")
+
+// Error is a Windows Update error, failing a whole cycle.
+type Error struct {
+	// Code is the HRESULT of the error, like 0x8024402C.
+	Code    uint32
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Windows Update error 0x%08X: %s", e.Code, e.Message)
+}
+
+// transientErrors are the HRESULTs of the errors the next try may not have:
+// network failures and the Windows Update service being busy.
+var transientErrors = map[uint32]bool{
+	0x8024001E: true, // WU_E_SERVICE_STOP
+	0x80240016: true, // WU_E_INSTALL_NOT_ALLOWED, another installation running
+	0x80240438: true, // WU_E_PT_ENDPOINT_UNREACHABLE
+	0x8024401C: true, // WU_E_PT_HTTP_STATUS_REQUEST_TIMEOUT
+	0x8024402C: true, // WU_E_PT_WINHTTP_NAME_NOT_RESOLVED
+	0x8024402F: true, // WU_E_PT_ECP_SUCCEEDED_WITH_ERRORS
+	0x80244010: true, // WU_E_PT_EXCEEDED_MAX_SERVER_TRIPS
+	0x80244022: true, // WU_E_PT_HTTP_STATUS_SERVICE_UNAVAIL
+	0x8024A000: true, // WU_E_AU_NOSERVICE
+	0x80072EE2: true, // ERROR_INTERNET_TIMEOUT
+	0x80072EE7: true, // ERROR_INTERNET_NAME_NOT_RESOLVED
+	0x80072EFD: true, // ERROR_INTERNET_CANNOT_CONNECT
+	0x80072EFE: true, // ERROR_INTERNET_CONNECTION_ABORTED
+	0x80072F8F: true, // ERROR_INTERNET_SECURE_FAILURE, a clock not synced yet
+}
+
+// IsTransient reports whether the Windows Update error of HRESULT code may
+// not happen again when retrying, like a network failure.
+func IsTransient(code uint32) bool {
+	return transientErrors[code]
+}
+
+// Run runs the update cycles on the guest of comm until no update is left,
+// restarting it when the updates require it. The updates that failed to
+// install are in the Result; Run only fails when a cycle fails, when no
+// update of a cycle could be installed, or after Options.MaxCycles cycles.
+func Run(ctx context.Context, comm packersdk.Communicator, opts Options) (*Result, error) {
+	opts.setDefaults()
+	r := &runner{comm: comm, opts: opts}
+	if err := comm.Upload(scriptPath, strings.NewReader(renderScript(&opts)), nil); err != nil {
+		return nil, fmt.Errorf("Error uploading the Windows Update script: %s", err)
+	}
+	defer r.cleanup(ctx)
+
+	res := &Result{}
+	for {
+		if res.Cycles == opts.MaxCycles {
+			return res, fmt.Errorf("%w (%d)", ErrMaxCycles, opts.MaxCycles)
+		}
+		res.Cycles++
+		r.say(i18n.Sprintf("Searching for Windows updates (cycle %d/%d)...", res.Cycles, opts.MaxCycles))
+
+		var cycle *cycleLog
+		err := retry.Config{
+			Tries:      opts.Tries,
+			RetryDelay: func() time.Duration { return opts.RetryDelay },
+			ShouldRetry: func(err error) bool {
+				var wuErr *Error
+				return errors.As(err, &wuErr) && IsTransient(wuErr.Code)
+			},
+		}.Run(ctx, func(ctx context.Context) error {
+			var err error
+			cycle, err = r.runCycle(ctx)
+			if err != nil {
+				log.Printf("[WARN] Windows Update cycle failed: %s", err)
+			}
+			return err
+		})
+		if err != nil {
+			return res, err
+		}
+
+		installed := 0
+		for i, u := range cycle.updates {
+			result := cycle.results[i]
+			switch result.code {
+			case resultSucceeded, resultSucceededWithError:
+				installed++
+				res.Installed = append(res.Installed, u)
+			default:
+				u.HResult = result.hresult
+				res.Failed = append(res.Failed, u)
+			}
+		}
+
+		if cycle.rebootRequired {
+			if err := r.restart(ctx); err != nil {
+				return res, err
+			}
+			res.Restarts++
+			continue
+		}
+		if len(cycle.updates) == 0 {
+			r.say(i18n.T("No Windows updates left to install"))
+			return res, nil
+		}
+		if installed == 0 {
+			return res, fmt.Errorf("none of the %d Windows updates found could be installed", len(cycle.updates))
+		}
+	}
+}
+
+type runner struct {
+	comm packersdk.Communicator
+	opts Options
+}
+
+func (r *runner) say(message string) {
+	if r.opts.Ui != nil {
+		r.opts.Ui.Say(message)
+	}
+}
+
+func (r *runner) message(message string) {
+	if r.opts.Ui != nil {
+		r.opts.Ui.Message(message)
+	}
+}
+
+// runCycle starts the script in the scheduled task and follows its log until
+// it is done.
+func (r *runner) runCycle(ctx context.Context) (*cycleLog, error) {
+	start := fmt.Sprintf("powershell.exe -NoProfile -Command \"Remove-Item -Force -ErrorAction SilentlyContinue %s; "+
+		"$a = New-ScheduledTaskAction -Execute powershell.exe -Argument '-NoProfile -ExecutionPolicy Bypass -File %s'; "+
+		"Register-ScheduledTask -TaskName %s -Action $a -User SYSTEM -RunLevel Highest -Force | Out-Null; "+
+		"Start-ScheduledTask -TaskName %s\"", logPath, scriptPath, taskName, taskName)
+	if _, err := r.run(ctx, start); err != nil {
+		return nil, fmt.Errorf("Error starting the Windows Update task: %s", err)
+	}
+
+	read := fmt.Sprintf("powershell.exe -NoProfile -Command \"if (Test-Path %[1]s) { Get-Content -Raw %[1]s }\"", logPath)
+	reported := &cycleLog{installing: -1}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(r.opts.PollInterval):
+		}
+		out, err := r.run(ctx, read)
+		if err != nil {
+			// The updates may restart the WinRM service.
+			log.Printf("[WARN] Error reading the Windows Update log, trying again: %s", err)
+			if r.opts.Reconnect != nil {
+				if err := r.opts.Reconnect(ctx); err != nil {
+					log.Printf("[WARN] Error connecting to the guest again: %s", err)
+				}
+			}
+			continue
+		}
+		cycle := parseLog(out)
+		r.report(reported, cycle)
+		reported = cycle
+		if cycle.done {
+			if cycle.err != nil {
+				return nil, cycle.err
+			}
+			return cycle, nil
+		}
+	}
+}
+
+// report tells the Ui what happened since the log last read, last.
+func (r *runner) report(last, cycle *cycleLog) {
+	if len(last.updates) == 0 && len(cycle.updates) > 0 {
+		r.message(i18n.Sprintf("Found %d Windows updates", len(cycle.updates)))
+	}
+	for i := range cycle.updates {
+		if i > last.installing && i <= cycle.installing {
+			r.message(i18n.Sprintf("Installing %d/%d: %s", i+1, len(cycle.updates), cycle.updates[i]))
+		}
+		if _, ok := last.results[i]; ok {
+			continue
+		}
+		if res, ok := cycle.results[i]; ok && res.code != resultSucceeded && res.code != resultSucceededWithError {
+			r.message(i18n.Sprintf("Failed to install %s: 0x%08X", cycle.updates[i], res.hresult))
+		}
+	}
+}
+
+// restart restarts the guest and waits for it to be back, which is when its
+// boot time changed.
+func (r *runner) restart(ctx context.Context) error {
+	r.say(i18n.T("Restarting the guest to complete the installation of the Windows updates..."))
+	bootTime := "powershell.exe -NoProfile -Command \"(Get-CimInstance Win32_OperatingSystem).LastBootUpTime.ToFileTimeUtc()\""
+	before, err := r.run(ctx, bootTime)
+	if err != nil {
+		return fmt.Errorf("Error reading the boot time of the guest: %s", err)
+	}
+	// The connection may be lost before the command returns.
+	if _, err := r.run(ctx, `shutdown.exe /r /f /t 5 /c "Packer Windows Update restart" /d p:2:17`); err != nil {
+		log.Printf("[WARN] Restarting the guest: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.RestartTimeout)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the guest didn't restart within %s: %w", r.opts.RestartTimeout, ctx.Err())
+		case <-time.After(r.opts.PollInterval):
+		}
+		if r.opts.Reconnect != nil {
+			if err := r.opts.Reconnect(ctx); err != nil {
+				log.Printf("[DEBUG] Waiting for the guest to restart: %s", err)
+				continue
+			}
+		}
+		after, err := r.run(ctx, bootTime)
+		if err != nil {
+			log.Printf("[DEBUG] Waiting for the guest to restart: %s", err)
+			continue
+		}
+		if strings.TrimSpace(after) != strings.TrimSpace(before) {
+			r.say(i18n.T("The guest restarted"))
+			return nil
+		}
+	}
+}
+
+// cleanup removes the scheduled task, the script and its log from the
+// guest.
+func (r *runner) cleanup(ctx context.Context) {
+	command := fmt.Sprintf("powershell.exe -NoProfile -Command \"Unregister-ScheduledTask -TaskName %s -Confirm:$false -ErrorAction SilentlyContinue; "+
+		"Remove-Item -Force -ErrorAction SilentlyContinue %s, %s\"", taskName, scriptPath, logPath)
+	if _, err := r.run(ctx, command); err != nil {
+		log.Printf("[WARN] Error removing the Windows Update task: %s", err)
+	}
+}
+
+// run runs command and returns its output, or an error if it exits with a
+// non-zero status.
+func (r *runner) run(ctx context.Context, command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := r.comm.Start(ctx, cmd); err != nil {
+		return "", err
+	}
+	if status := cmd.Wait(); status != 0 {
+		return "", fmt.Errorf("%q exited with status %d: %s", command, status, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package windowsupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fakeGuest runs the cycles whose logs it is given, in order, and restarts
+// when told to.
+type fakeGuest struct {
+	packersdk.MockCommunicator
+
+	cycles   []string
+	started  int
+	bootTime int
+	commands []string
+	script   string
+}
+
+func (g *fakeGuest) Start(_ context.Context, rc *packersdk.RemoteCmd) error {
+	g.commands = append(g.commands, rc.Command)
+	switch {
+	case strings.Contains(rc.Command, "Start-ScheduledTask"):
+		g.started++
+	case strings.Contains(rc.Command, "Get-Content"):
+		io.WriteString(rc.Stdout, g.cycles[g.started-1])
+	case strings.Contains(rc.Command, "LastBootUpTime"):
+		fmt.Fprintf(rc.Stdout, "%d\r\n", g.bootTime)
+	case strings.HasPrefix(rc.Command, "shutdown.exe"):
+		g.bootTime++
+		rc.SetExited(packersdk.CmdDisconnect)
+		return nil
+	}
+	rc.SetExited(0)
+	return nil
+}
+
+func (g *fakeGuest) Upload(path string, r io.Reader, _ *os.FileInfo) error {
+	b, _ := io.ReadAll(r)
+	g.script = string(b)
+	return nil
+}
+
+func testOptions() Options {
+	return Options{PollInterval: time.Millisecond, RetryDelay: time.Millisecond}
+}
+
+func TestParseLog(t *testing.T) {
+	l := parseLog("\ufeffFOUND 2\r\n" +
+		"UPDATE 0 a1b2 KB5034441 104857600 2024-01 Security Update for Windows Server 2022\r\n" +
+		"UPDATE 1 c3d4 - 1024 Microsoft Defender Antivirus - Update\r\n" +
+		"DOWNLOADING\r\n" +
+		"INSTALLING 0\r\n" +
+		"RESULT 0 2 0x00000000\r\n" +
+		"INSTALLING 1\r\n" +
+		"RESULT 1 4 0x80070643\r\n" +
+		"REBOOT_REQUIRED\r\n" +
+		"DON")
+
+	expected := []Update{
+		{ID: "a1b2", Title: "2024-01 Security Update for Windows Server 2022", KBs: []string{"KB5034441"}, Size: 104857600},
+		{ID: "c3d4", Title: "Microsoft Defender Antivirus - Update", Size: 1024},
+	}
+	if l.found != 2 || !reflect.DeepEqual(l.updates, expected) {
+		t.Fatalf("bad updates: %#v", l.updates)
+	}
+	if l.installing != 1 || l.results[1] != (result{code: 4, hresult: 0x80070643}) || !l.rebootRequired {
+		t.Fatalf("bad log: %#v", l)
+	}
+	if l.done {
+		t.Fatal("an incomplete line should be ignored")
+	}
+
+	l = parseLog("ERROR 0x8024402C The server name could not be resolved\nDONE\n")
+	if !l.done || l.err == nil || l.err.Code != 0x8024402C || !IsTransient(l.err.Code) {
+		t.Fatalf("bad error: %#v", l.err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	g := &fakeGuest{cycles: []string{
+		"FOUND 2\n" +
+			"UPDATE 0 a1b2 KB5034441 100 Security Update\n" +
+			"UPDATE 1 c3d4 KB890830 10 Malicious Software Removal Tool\n" +
+			"INSTALLING 0\nRESULT 0 2 0x00000000\n" +
+			"INSTALLING 1\nRESULT 1 4 0x80070643\n" +
+			"REBOOT_REQUIRED\nDONE\n",
+		"ERROR 0x80072EE2 The operation timed out\nDONE\n",
+		"FOUND 1\n" +
+			"UPDATE 0 c3d4 KB890830 10 Malicious Software Removal Tool\n" +
+			"INSTALLING 0\nRESULT 0 2 0x00000000\nDONE\n",
+		"FOUND 0\nDONE\n",
+	}}
+	ui := &packersdk.MockUi{}
+	opts := testOptions()
+	opts.Exclude, opts.Ui = "Preview", ui
+	res, err := Run(context.Background(), g, opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if res.Cycles != 3 || res.Restarts != 1 || g.started != 4 {
+		t.Fatalf("bad result: %#v, %d tasks started", res, g.started)
+	}
+	var installed []string
+	for _, u := range res.Installed {
+		installed = append(installed, u.ID)
+	}
+	if !reflect.DeepEqual(installed, []string{"a1b2", "c3d4"}) {
+		t.Fatalf("bad installed updates: %v", installed)
+	}
+	if len(res.Failed) != 1 || res.Failed[0].HResult != 0x80070643 {
+		t.Fatalf("bad failed updates: %#v", res.Failed)
+	}
+	if !strings.Contains(g.script, "$u.Title -match 'Preview'") || !strings.Contains(g.script, psQuote(DefaultSearchCriteria)) {
+		t.Fatalf("bad script:\n%s", g.script)
+	}
+	if last := g.commands[len(g.commands)-1]; !strings.Contains(last, "Unregister-ScheduledTask") {
+		t.Fatalf("the task should be removed, last command: %s", last)
+	}
+	if ui.SayMessages[len(ui.SayMessages)-1].Message != "No Windows updates left to install" {
+		t.Fatalf("bad messages: %#v", ui.SayMessages)
+	}
+}
+
+func TestRun_errors(t *testing.T) {
+	g := &fakeGuest{cycles: []string{"ERROR 0x80240017 Operation did not complete because there is no logged-on interactive user\nDONE\n"}}
+	_, err := Run(context.Background(), g, testOptions())
+	var wuErr *Error
+	if !errors.As(err, &wuErr) || wuErr.Code != 0x80240017 || g.started != 1 {
+		t.Fatalf("a permanent error should not be retried: %v", err)
+	}
+
+	cycle := "FOUND 1\nUPDATE 0 a1b2 KB1 1 Update\nINSTALLING 0\nRESULT 0 2 0x00000000\nREBOOT_REQUIRED\nDONE\n"
+	g = &fakeGuest{cycles: []string{cycle, cycle, cycle}}
+	opts := testOptions()
+	opts.MaxCycles = 2
+	res, err := Run(context.Background(), g, opts)
+	if !errors.Is(err, ErrMaxCycles) || res.Cycles != 2 {
+		t.Fatalf("expected the cycles to be exhausted, got %v", err)
+	}
+
+	g = &fakeGuest{cycles: []string{"FOUND 1\nUPDATE 0 a1b2 KB1 1 Update\nINSTALLING 0\nRESULT 0 4 0x80070643\nDONE\n"}}
+	if _, err := Run(context.Background(), g, testOptions()); err == nil || !strings.Contains(err.Error(), "could be installed") {
+		t.Fatalf("expected an error when nothing could be installed, got %v", err)
+	}
+}